@@ -0,0 +1,87 @@
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RequestMatcher reports whether a request matches a condition. Request matchers select the
+// requests that are allowlisted with [WithAllowlist] or denylisted with [WithDenylist].
+type RequestMatcher func(*http.Request) bool
+
+// MatchIPs returns a [RequestMatcher] that matches requests from the given IP addresses and
+// CIDR ranges, such as "192.0.2.1", "2001:db8::1", or "10.0.0.0/8". The IP address of a request
+// is the one of the client's connection. Behind reverse proxies, use the MatchIPs method of a
+// [ClientIPResolver] trusting the proxies instead.
+//
+// It panics if an entry is neither a valid IP address nor a valid CIDR range.
+func MatchIPs(entries ...string) RequestMatcher {
+	return connectionIPResolver.MatchIPs(entries...)
+}
+
+// MatchKey returns a [RequestMatcher] that matches requests whose key, as extracted by keyFunc,
+// satisfies predicate. Requests for which keyFunc returns an error do not match.
+//
+// Example usage: MatchKey(KeyByHeader("X-API-Key"), func(key string) bool { return key == internalKey })
+func MatchKey(keyFunc KeyFunc, predicate func(key string) bool) RequestMatcher {
+	return func(r *http.Request) bool {
+		key, err := keyFunc(r)
+		return err == nil && predicate(key)
+	}
+}
+
+// WithAllowlist exempts requests matching any of matchers from rate limiting. Allowlisted
+// requests are forwarded to the next handler without consulting the rate limiter, so they do
+// not consume any of its resources.
+func WithAllowlist(matchers ...RequestMatcher) Option {
+	return func(o *options) {
+		o.allowlist = append(o.allowlist, matchers...)
+	}
+}
+
+// WithSkipFunc exempts requests for which skip returns true from rate limiting, such as health
+// checks, internal service calls, or CORS preflight requests. It is equivalent to [WithAllowlist]
+// with skip as the request matcher.
+//
+// Example usage: WithSkipFunc(func(r *http.Request) bool { return r.Method == http.MethodOptions })
+func WithSkipFunc(skip func(*http.Request) bool) Option {
+	return WithAllowlist(skip)
+}
+
+// WithDenylist rejects requests matching any of matchers with an HTTP 403 (Forbidden), without
+// consulting the rate limiter. The denylist takes precedence over the allowlist.
+func WithDenylist(matchers ...RequestMatcher) Option {
+	return func(o *options) {
+		o.denylist = append(o.denylist, matchers...)
+	}
+}
+
+// matchAny reports whether r matches any of matchers.
+func matchAny(matchers []RequestMatcher, r *http.Request) bool {
+	for _, match := range matchers {
+		if match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefix parses entry as a CIDR range, or as a single IP address which is turned into a
+// range containing only that address. It panics if entry is neither.
+func parsePrefix(entry string) netip.Prefix {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			panic(fmt.Sprintf("cerberus: invalid CIDR range %q: %v", entry, err))
+		}
+		return prefix.Masked()
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		panic(fmt.Sprintf("cerberus: invalid IP address %q: %v", entry, err))
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
@@ -0,0 +1,105 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test MatchIPs matches exact addresses and CIDR ranges
+func TestMatchIPs(t *testing.T) {
+	match := MatchIPs("192.0.2.1", "10.0.0.0/8", "2001:db8::/32")
+
+	tests := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{"192.0.2.1:1234", true},
+		{"192.0.2.2:1234", false},
+		{"10.1.2.3:1234", true},
+		{"[::ffff:10.1.2.3]:1234", true},
+		{"[2001:db8::42]:1234", true},
+		{"[2001:db9::42]:1234", false},
+		{"invalid", false},
+	}
+	for _, test := range tests {
+		if got := match(newRequestFrom(test.remoteAddr)); got != test.want {
+			t.Errorf("%s: expected %v; got %v", test.remoteAddr, test.want, got)
+		}
+	}
+}
+
+// Test MatchKey applies the predicate to the extracted key
+func TestMatchKey(t *testing.T) {
+	match := MatchKey(KeyByHeader("X-API-Key"), func(key string) bool {
+		return key == "internal"
+	})
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if match(req) {
+		t.Errorf("expected request without key not to match")
+	}
+	req.Header.Set("X-API-Key", "internal")
+	if !match(req) {
+		t.Errorf("expected request with internal key to match")
+	}
+}
+
+// Test allowlisted requests bypass the limiter and denylisted requests are rejected
+func TestMiddlewareAllowlistAndDenylist(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := Middleware(mockLimiter, handler,
+		WithAllowlist(MatchIPs("10.0.0.0/8")),
+		WithDenylist(MatchIPs("10.6.6.6", "203.0.113.0/24")),
+	)
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"10.1.2.3:1234", http.StatusOK},
+		{"10.6.6.6:1234", http.StatusForbidden},
+		{"203.0.113.9:1234", http.StatusForbidden},
+		{"192.0.2.1:1234", http.StatusTooManyRequests},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, newRequestFrom(test.remoteAddr))
+		if rr.Code != test.want {
+			t.Errorf("%s: expected status %v; got %v", test.remoteAddr, test.want, rr.Code)
+		}
+	}
+}
+
+// Test requests selected by the skip function bypass the limiter
+func TestMiddlewareSkipFunc(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := Middleware(mockLimiter, handler, WithSkipFunc(func(r *http.Request) bool {
+		return r.URL.Path == "/healthz"
+	}))
+
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected skipped request to get status OK; got %v", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected other request to get status Too Many Requests; got %v", rr.Code)
+	}
+}
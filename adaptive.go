@@ -0,0 +1,205 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SignalFunc reports a load signal of the protected service, such as its p99 latency in seconds,
+// its CPU utilization, or its error rate. Larger values must indicate more load. It is called by
+// an [AdaptiveLimiter] at most once per window, and should return quickly.
+type SignalFunc func() float64
+
+// AdaptiveLimiter is a [RateLimiter] and [AdvancedRateLimiter] whose limit tracks the actual
+// capacity of the protected service. Like [FixedWindowLimiter], it allows each client, identified
+// by its key (its IP address by default), up to a limit of requests per window. Unlike it, the
+// limit is not fixed: it is adjusted once per window based on a load signal, using additive
+// increase, multiplicative decrease (AIMD), the scheme TCP uses to find the capacity of a
+// network path.
+//
+// Behavior:
+//   - If the signal is at or below the target, the limit grows by 1% of maxLimit (at least 1), up
+//     to maxLimit.
+//   - If the signal exceeds the target, the limit is halved, down to minLimit.
+//   - The limit starts at maxLimit, and is adjusted lazily, when the first request of a window
+//     is checked.
+//
+// The limit is shared by all clients and held in memory, so each instance of a service adapts to
+// its own load even if the counters are kept in a shared [Store].
+//
+// An AdaptiveLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter := NewAdaptiveLimiter(10, 1000, time.Second, p99LatencySeconds, 0.25)
+//	http.Handle("/resource", AdvancedMiddleware(limiter, myHandler))
+type AdaptiveLimiter struct {
+	minLimit int64
+	maxLimit int64
+	signal   SignalFunc
+	target   float64
+	counter  *FixedWindowLimiter
+
+	mu         sync.Mutex
+	limit      int64
+	lastAdjust time.Time
+}
+
+// NewAdaptiveLimiter creates a new [AdaptiveLimiter] that allows between minLimit and maxLimit
+// requests per client within each window, adjusting the limit each window by comparing the value
+// returned by signal to target.
+//
+// It panics if minLimit or window is not positive, if maxLimit is less than minLimit, or if signal
+// is nil.
+func NewAdaptiveLimiter(minLimit, maxLimit int, window time.Duration, signal SignalFunc, target float64, opts ...LimiterOption) *AdaptiveLimiter {
+	if minLimit <= 0 {
+		panic("cerberus: adaptive minimum limit must be positive")
+	}
+	if maxLimit < minLimit {
+		panic("cerberus: adaptive maximum limit must not be less than the minimum limit")
+	}
+	if signal == nil {
+		panic("cerberus: adaptive signal function must not be nil")
+	}
+	return &AdaptiveLimiter{
+		minLimit: int64(minLimit),
+		maxLimit: int64(maxLimit),
+		signal:   signal,
+		target:   target,
+		counter:  NewFixedWindowLimiter(maxLimit, window, opts...),
+		limit:    int64(maxLimit),
+	}
+}
+
+// IsAllowed adjusts the limit if a new window has begun, and adds the cost of the request to the
+// counter of the client making it. It returns true if the counter did not exceed the current limit,
+// false otherwise. An error is returned if no key can be derived from the request or the store
+// fails.
+func (l *AdaptiveLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *AdaptiveLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.counter.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	limit := l.adjust()
+	isAllowed, err := l.counter.increment(ctx, key, int64(requestCost(l.counter.costFunc, r)), limit)
+	if !isAllowed && err == nil {
+		countDenied(l.counter.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// Peek reports whether the request would be allowed under the current limit, without counting it.
+// An error is returned if no key can be derived from the request or the store fails.
+func (l *AdaptiveLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.counter.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	return l.counter.peek(ctx, key, int64(requestCost(l.counter.costFunc, r)), l.adjust())
+}
+
+// Commit counts the request, even if that exceeds the current limit. An error is returned if no
+// key can be derived from the request or the store fails.
+func (l *AdaptiveLimiter) Commit(ctx context.Context, r *http.Request) error {
+	return l.counter.Commit(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. An error is returned
+// if no key can be derived from the request or the store fails.
+func (l *AdaptiveLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return l.counter.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the current state of the counter of the client making the request,
+// measured against the current limit. The zero RateLimitData is returned if no key can be derived
+// from the request or the store fails.
+func (l *AdaptiveLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.counter.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.counter.rateLimitData(r.Context(), key, int64(l.Limit()), int64(requestCost(l.counter.costFunc, r)))
+	return data
+}
+
+// Usage returns the current state of the counter of the client identified by key, measured against
+// the current limit. An error is returned if the store fails.
+func (l *AdaptiveLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.counter.rateLimitData(ctx, key, int64(l.Limit()), 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *AdaptiveLimiter) Reset(ctx context.Context, key string) error {
+	return l.counter.Reset(ctx, key)
+}
+
+// Block denies all requests of the client identified by key for d. An error is returned if the
+// store fails.
+func (l *AdaptiveLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	return l.counter.Block(ctx, key, d)
+}
+
+// Keys returns the keys of the clients with a counter in the current window, in no particular
+// order. An error wrapping [errors.ErrUnsupported] is returned if the store does not implement
+// [KeyScanner].
+func (l *AdaptiveLimiter) Keys(ctx context.Context) ([]string, error) {
+	return l.counter.Keys(ctx)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *AdaptiveLimiter) TopDenied() ([]KeyCount, error) {
+	return l.counter.TopDenied()
+}
+
+// Close closes the counter of the limiter, stopping the janitor of its default store, if no store
+// was set with [WithStore]. It implements [Closer].
+func (l *AdaptiveLimiter) Close(ctx context.Context) error {
+	return l.counter.Close(ctx)
+}
+
+// Limit returns the current limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// adjust updates the limit from the signal if it has not been adjusted in the current window yet,
+// and returns the current limit.
+func (l *AdaptiveLimiter) adjust() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start, _ := l.counter.period(l.counter.now())
+	if !start.After(l.lastAdjust) {
+		return l.limit
+	}
+	isFirstWindow := l.lastAdjust.IsZero()
+	l.lastAdjust = start
+	if isFirstWindow {
+		return l.limit
+	}
+	if l.signal() > l.target {
+		l.limit = max(l.limit/2, l.minLimit)
+	} else {
+		l.limit = min(l.limit+max(l.maxLimit/100, 1), l.maxLimit)
+	}
+	return l.limit
+}
+
+// key returns the key identifying the client making the request.
+func (l *AdaptiveLimiter) key(r *http.Request) (string, error) {
+	return l.counter.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *AdaptiveLimiter) timeSource() Clock {
+	return l.counter.clock
+}
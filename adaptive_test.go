@@ -0,0 +1,92 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the limit is halved while the signal exceeds the target
+func TestAdaptiveLimiterDecreasesUnderLoad(t *testing.T) {
+	clock := newFakeClock()
+	signal := 1.0
+	limiter := NewAdaptiveLimiter(10, 100, time.Second, func() float64 { return signal }, 0.5)
+	limiter.counter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	if limit := limiter.Limit(); limit != 100 {
+		t.Errorf("expected initial limit of 100; got %v", limit)
+	}
+	for _, want := range []int{50, 25, 12, 10} {
+		clock.Advance(time.Second)
+		limiter.IsAllowed(req)
+		if limit := limiter.Limit(); limit != want {
+			t.Errorf("expected limit of %v; got %v", want, limit)
+		}
+	}
+}
+
+// Test the limit grows additively once the signal recovers
+func TestAdaptiveLimiterIncreasesWhenHealthy(t *testing.T) {
+	clock := newFakeClock()
+	signal := 1.0
+	limiter := NewAdaptiveLimiter(10, 200, time.Second, func() float64 { return signal }, 0.5)
+	limiter.counter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+	clock.Advance(time.Second)
+	limiter.IsAllowed(req)
+
+	signal = 0.1
+	for _, want := range []int{102, 104} {
+		clock.Advance(time.Second)
+		limiter.IsAllowed(req)
+		if limit := limiter.Limit(); limit != want {
+			t.Errorf("expected limit of %v; got %v", want, limit)
+		}
+	}
+}
+
+// Test requests are denied beyond the current limit
+func TestAdaptiveLimiterEnforcesCurrentLimit(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewAdaptiveLimiter(1, 4, time.Second, func() float64 { return 1 }, 0)
+	limiter.counter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+	clock.Advance(time.Second)
+
+	allowed := 0
+	for range 4 {
+		if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+			allowed++
+		}
+	}
+
+	if allowed != 2 {
+		t.Errorf("expected 2 requests to be allowed; got %v", allowed)
+	}
+	if data := limiter.GetRateLimitData(req); data.Limit != 2 || data.Remaining != 0 {
+		t.Errorf("expected {2 0}; got %+v", data)
+	}
+}
+
+// Test the signal is read at most once per window
+func TestAdaptiveLimiterReadsSignalOncePerWindow(t *testing.T) {
+	clock := newFakeClock()
+	reads := 0
+	limiter := NewAdaptiveLimiter(1, 10, time.Second, func() float64 { reads++; return 0 }, 1)
+	limiter.counter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for range 3 {
+		limiter.IsAllowed(req)
+		clock.Advance(time.Second)
+		limiter.IsAllowed(req)
+		limiter.IsAllowed(req)
+	}
+
+	if reads != 3 {
+		t.Errorf("expected 3 signal reads; got %v", reads)
+	}
+}
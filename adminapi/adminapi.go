@@ -0,0 +1,304 @@
+// Package adminapi provides an HTTP API for inspecting and modifying the state of cerberus rate
+// limiters at runtime, so that on-call engineers can see who is being throttled, lift or impose
+// limits on specific clients, and tune limits during an incident.
+//
+// The API is served by the handler returned by [New], and exposes the following endpoints, which
+// accept and return JSON:
+//
+//	GET    /limiters                          list the rate limiters and their configs
+//	GET    /limiters/{name}/keys              list the keys of the clients a rate limiter tracks
+//	GET    /limiters/{name}/keys/{key}        view the usage of a client
+//	DELETE /limiters/{name}/keys/{key}        reset a client, restoring its full budget
+//	POST   /limiters/{name}/keys/{key}/block  ban a client for {"duration": "10m"}
+//	GET    /limiters/{name}/top-denied        list the keys of the clients denied the most
+//	GET    /limiters/{name}/config            view the config of a rate limiter
+//	PUT    /limiters/{name}/config            change the config of a rate limiter
+//	GET    /limiters/{name}/usage             report the usage of the clients over a billing period
+//
+// Keys must be escaped with [url.PathEscape]. Each endpoint requires the rate limiter to support
+// the operation: listing keys and viewing usage require a [cerberus.InspectableLimiter], resetting
+// and blocking clients a [cerberus.ManagedLimiter], listing the clients denied the most a
+// [cerberus.TopDeniedLimiter] tracking denials, configs a [cerberus.DynamicLimiter], and usage
+// reports a [cerberus.UsageReporter]. Unsupported operations fail with HTTP 501 (Not Implemented).
+//
+// Usage reports cover the period containing the time given by the "at" query parameter in RFC 3339
+// format, or the current period if it is not set. They are returned as JSON, or as CSV with
+// "format=csv", for billing systems to import.
+//
+// The API grants full control over rate limiting, so it must only be reachable by operators:
+//
+//	admin := adminapi.New(map[string]cerberus.RateLimiter{"api": apiLimiter}, requireOperator)
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin))
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// configurable is implemented by rate limiters whose config can be changed at runtime, such as
+// [cerberus.DynamicLimiter].
+type configurable interface {
+	Config() cerberus.Config
+	UpdateConfig(config cerberus.Config) error
+}
+
+// usageReporter is implemented by rate limiters reporting the usage of their clients over billing
+// periods, such as [cerberus.UsageReporter].
+type usageReporter interface {
+	Report(ctx context.Context, at time.Time) (cerberus.UsageReport, error)
+}
+
+// New returns a handler serving the admin API for limiters, identified in the API by their name
+// in the map. Every request goes through auth first, which must reject requests that are not from
+// authorized operators, typically by checking credentials and responding with HTTP 401
+// (Unauthorized) or 403 (Forbidden).
+//
+// It panics if auth is nil, so that the API cannot be exposed without protection by mistake.
+func New(limiters map[string]cerberus.RateLimiter, auth func(http.Handler) http.Handler) http.Handler {
+	if auth == nil {
+		panic("adminapi: auth middleware must not be nil")
+	}
+	a := &api{limiters: maps.Clone(limiters)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /limiters", a.listLimiters)
+	mux.HandleFunc("GET /limiters/{name}/keys", a.listKeys)
+	mux.HandleFunc("GET /limiters/{name}/keys/{key}", a.getUsage)
+	mux.HandleFunc("DELETE /limiters/{name}/keys/{key}", a.resetKey)
+	mux.HandleFunc("POST /limiters/{name}/keys/{key}/block", a.blockKey)
+	mux.HandleFunc("GET /limiters/{name}/top-denied", a.topDenied)
+	mux.HandleFunc("GET /limiters/{name}/config", a.getConfig)
+	mux.HandleFunc("PUT /limiters/{name}/config", a.updateConfig)
+	mux.HandleFunc("GET /limiters/{name}/usage", a.reportUsage)
+	return auth(mux)
+}
+
+// api serves the endpoints of the admin API.
+type api struct {
+	limiters map[string]cerberus.RateLimiter
+}
+
+// limiterInfo describes a rate limiter in the response of GET /limiters.
+type limiterInfo struct {
+	Name   string           `json:"name"`
+	Config *cerberus.Config `json:"config,omitempty"`
+}
+
+// usage is the response of GET /limiters/{name}/keys/{key}.
+type usage struct {
+	Key        string `json:"key"`
+	Limit      int    `json:"limit"`
+	Remaining  int    `json:"remaining"`
+	RetryAfter string `json:"retry_after"`
+}
+
+// blockRequest is the body of POST /limiters/{name}/keys/{key}/block.
+type blockRequest struct {
+	Duration string `json:"duration"`
+}
+
+func (a *api) listLimiters(w http.ResponseWriter, r *http.Request) {
+	infos := []limiterInfo{}
+	for _, name := range slices.Sorted(maps.Keys(a.limiters)) {
+		info := limiterInfo{Name: name}
+		if c, ok := a.limiters[name].(configurable); ok {
+			config := c.Config()
+			info.Config = &config
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"limiters": infos})
+}
+
+func (a *api) listKeys(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[cerberus.InspectableLimiter](a, w, r, "listing keys")
+	if !ok {
+		return
+	}
+	keys, err := limiter.Keys(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	slices.Sort(keys)
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+func (a *api) getUsage(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[cerberus.InspectableLimiter](a, w, r, "viewing usage")
+	if !ok {
+		return
+	}
+	key := r.PathValue("key")
+	data, err := limiter.Usage(r.Context(), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage{
+		Key:        key,
+		Limit:      data.Limit,
+		Remaining:  data.Remaining,
+		RetryAfter: data.RetryAfter.String(),
+	})
+}
+
+func (a *api) resetKey(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[cerberus.ManagedLimiter](a, w, r, "resetting keys")
+	if !ok {
+		return
+	}
+	if err := limiter.Reset(r.Context(), r.PathValue("key")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) blockKey(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[cerberus.ManagedLimiter](a, w, r, "blocking keys")
+	if !ok {
+		return
+	}
+	var body blockRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrorStatus(w, http.StatusBadRequest, fmt.Errorf("malformed body: %w", err))
+		return
+	}
+	d, err := time.ParseDuration(body.Duration)
+	if err != nil || d <= 0 {
+		writeErrorStatus(w, http.StatusBadRequest, fmt.Errorf("duration must be a positive duration such as \"10m\"; got %q", body.Duration))
+		return
+	}
+	if err := limiter.Block(r.Context(), r.PathValue("key"), d); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) topDenied(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[cerberus.TopDeniedLimiter](a, w, r, "listing the clients denied the most")
+	if !ok {
+		return
+	}
+	top, err := limiter.TopDenied()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if top == nil {
+		top = []cerberus.KeyCount{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": top})
+}
+
+func (a *api) getConfig(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[configurable](a, w, r, "configs")
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, limiter.Config())
+}
+
+func (a *api) updateConfig(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[configurable](a, w, r, "configs")
+	if !ok {
+		return
+	}
+	var config cerberus.Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeErrorStatus(w, http.StatusBadRequest, fmt.Errorf("malformed body: %w", err))
+		return
+	}
+	if err := limiter.UpdateConfig(config); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limiter.Config())
+}
+
+func (a *api) reportUsage(w http.ResponseWriter, r *http.Request) {
+	limiter, ok := limiterAs[usageReporter](a, w, r, "usage reports")
+	if !ok {
+		return
+	}
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			writeErrorStatus(w, http.StatusBadRequest, fmt.Errorf("at must be a time in RFC 3339 format; got %q", value))
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeErrorStatus(w, http.StatusBadRequest, fmt.Errorf("format must be \"json\" or \"csv\"; got %q", format))
+		return
+	}
+	report, err := limiter.Report(r.Context(), at)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		report.WriteCSV(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	report.WriteJSON(w)
+}
+
+// limiterAs returns the rate limiter named in the path of r as a T. If there is no such rate
+// limiter, or it is not a T, an error response is written and false is returned. operation
+// describes what T is required for, for the error message.
+func limiterAs[T any](a *api, w http.ResponseWriter, r *http.Request, operation string) (T, bool) {
+	name := r.PathValue("name")
+	rateLimiter, ok := a.limiters[name]
+	if !ok {
+		var zero T
+		writeErrorStatus(w, http.StatusNotFound, fmt.Errorf("unknown rate limiter %q", name))
+		return zero, false
+	}
+	limiter, ok := rateLimiter.(T)
+	if !ok {
+		writeErrorStatus(w, http.StatusNotImplemented, fmt.Errorf("rate limiter %q does not support %s", name, operation))
+	}
+	return limiter, ok
+}
+
+// writeError writes an error response for err, with a status code depending on its kind.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cerberus.ErrInvalidConfig):
+		writeErrorStatus(w, http.StatusBadRequest, err)
+	case errors.Is(err, errors.ErrUnsupported):
+		writeErrorStatus(w, http.StatusNotImplemented, err)
+	default:
+		writeErrorStatus(w, http.StatusInternalServerError, err)
+	}
+}
+
+// writeErrorStatus writes an error response with the given status code and err as message.
+func writeErrorStatus(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, map[string]string{"error": err.Error()})
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code.
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,205 @@
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// managedLimiter is a rate limiter recording resets and blocks.
+type managedLimiter struct {
+	cerberus.RateLimiter
+	reset   string
+	blocked map[string]time.Duration
+}
+
+func (l *managedLimiter) Reset(_ context.Context, key string) error {
+	l.reset = key
+	return nil
+}
+
+func (l *managedLimiter) Block(_ context.Context, key string, d time.Duration) error {
+	l.blocked[key] = d
+	return nil
+}
+
+func allowAll(next http.Handler) http.Handler {
+	return next
+}
+
+// do sends a request with the given method, path, and body to handler and returns the response.
+func do(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rr
+}
+
+// Test keys and usage of inspectable limiters are reported
+func TestKeysAndUsage(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(5, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByHeader("X-API-Key")))
+	for _, key := range []string{"bob", "alice/admin", "bob"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		limiter.IsAllowed(req)
+	}
+	handler := New(map[string]cerberus.RateLimiter{"api": limiter}, allowAll)
+
+	rr := do(handler, "GET", "/limiters/api/keys", "")
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"keys":["alice/admin","bob"]}` {
+		t.Errorf("expected both keys; got %v %s", rr.Code, body)
+	}
+
+	rr = do(handler, "GET", "/limiters/api/keys/"+url.PathEscape("alice/admin"), "")
+	var got usage
+	json.Unmarshal(rr.Body.Bytes(), &got)
+	if rr.Code != http.StatusOK || got.Key != "alice/admin" || got.Limit != 5 || got.Remaining != 4 {
+		t.Errorf("expected usage of alice/admin; got %v %+v", rr.Code, got)
+	}
+}
+
+// Test resets and blocks are forwarded to the limiter
+func TestResetAndBlock(t *testing.T) {
+	limiter := &managedLimiter{blocked: map[string]time.Duration{}}
+	handler := New(map[string]cerberus.RateLimiter{"login": limiter}, allowAll)
+
+	if rr := do(handler, "DELETE", "/limiters/login/keys/192.0.2.1", ""); rr.Code != http.StatusNoContent || limiter.reset != "192.0.2.1" {
+		t.Errorf("expected key to be reset; got %v %q", rr.Code, limiter.reset)
+	}
+	if rr := do(handler, "POST", "/limiters/login/keys/192.0.2.2/block", `{"duration": "10m"}`); rr.Code != http.StatusNoContent || limiter.blocked["192.0.2.2"] != 10*time.Minute {
+		t.Errorf("expected key to be blocked for 10m; got %v %v", rr.Code, limiter.blocked)
+	}
+	if rr := do(handler, "POST", "/limiters/login/keys/192.0.2.2/block", `{"duration": "forever"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid duration; got %v", rr.Code)
+	}
+}
+
+// Test the clients denied the most are listed if the limiter tracks denials
+func TestTopDenied(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithTopDenied(10))
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.1:1234", "192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.2:1234"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		limiter.IsAllowed(req)
+	}
+	handler := New(map[string]cerberus.RateLimiter{"api": limiter, "untracked": cerberus.NewFixedWindowLimiter(1, time.Minute)}, allowAll)
+
+	rr := do(handler, "GET", "/limiters/api/top-denied", "")
+	expected := `{"keys":[{"key":"192.0.2.1","count":2},{"key":"192.0.2.2","count":1}]}`
+	if body := strings.TrimSpace(rr.Body.String()); body != expected {
+		t.Errorf("expected %s; got %v %s", expected, rr.Code, body)
+	}
+	if rr := do(handler, "GET", "/limiters/untracked/top-denied", ""); rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501 for limiter not tracking denials; got %v", rr.Code)
+	}
+}
+
+// Test configs of dynamic limiters can be viewed and changed
+func TestConfig(t *testing.T) {
+	limiter, _ := cerberus.NewDynamicLimiter(cerberus.Config{Algorithm: cerberus.AlgorithmGCRA, Limit: 10, Window: time.Second})
+	handler := New(map[string]cerberus.RateLimiter{"api": limiter, "static": cerberus.NewGCRALimiter(time.Second, 0)}, allowAll)
+
+	rr := do(handler, "GET", "/limiters", "")
+	expected := `{"limiters":[{"name":"api","config":{"algorithm":"gcra","limit":10,"window":"1s"}},{"name":"static"}]}`
+	if body := strings.TrimSpace(rr.Body.String()); body != expected {
+		t.Errorf("expected %s; got %s", expected, body)
+	}
+
+	rr = do(handler, "PUT", "/limiters/api/config", `{"algorithm": "gcra", "limit": 5, "window": "1s"}`)
+	if rr.Code != http.StatusOK || limiter.Config().Limit != 5 {
+		t.Errorf("expected limit to be changed to 5; got %v %+v", rr.Code, limiter.Config())
+	}
+	if rr := do(handler, "PUT", "/limiters/api/config", `{"algorithm": "gcra", "limit": 0, "window": "1s"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid config; got %v", rr.Code)
+	}
+	if rr := do(handler, "GET", "/limiters/static/config", ""); rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501 for static limiter; got %v", rr.Code)
+	}
+}
+
+// Test usage reports are returned as JSON or CSV for the requested period
+func TestUsageReport(t *testing.T) {
+	usage := cerberus.NewUsageReporter(cerberus.NewTokenBucketLimiter(10, 1), cerberus.UsageReporterConfig{Period: cerberus.Monthly(time.UTC)})
+	defer usage.Close(context.Background())
+	handler := New(map[string]cerberus.RateLimiter{"api": usage, "static": cerberus.NewGCRALimiter(time.Second, 0)}, allowAll)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	usage.IsAllowed(req)
+	usage.IsAllowed(req)
+
+	rr := do(handler, "GET", "/limiters/api/usage", "")
+	var report cerberus.UsageReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected a JSON report; got %v %v", rr.Code, err)
+	}
+	if len(report.Records) != 1 || report.Records[0] != (cerberus.UsageRecord{Key: "192.0.2.1", Usage: 2}) {
+		t.Errorf("expected usage 2 for 192.0.2.1; got %+v", report.Records)
+	}
+
+	rr = do(handler, "GET", "/limiters/api/usage?format=csv&at="+url.QueryEscape(time.Now().AddDate(0, -1, 0).Format(time.RFC3339)), "")
+	if rr.Header().Get("Content-Type") != "text/csv" || strings.TrimSpace(rr.Body.String()) != "key,period_start,period_end,usage" {
+		t.Errorf("expected an empty CSV report for the previous month; got %q", rr.Body)
+	}
+	for path, status := range map[string]int{
+		"/limiters/api/usage?at=yesterday": http.StatusBadRequest,
+		"/limiters/api/usage?format=xml":   http.StatusBadRequest,
+		"/limiters/static/usage":           http.StatusNotImplemented,
+	} {
+		if rr := do(handler, "GET", path, ""); rr.Code != status {
+			t.Errorf("%s: expected status %v; got %v", path, status, rr.Code)
+		}
+	}
+}
+
+// Test unknown limiters, unsupported operations, and authorization
+func TestErrors(t *testing.T) {
+	limiter := cerberus.NewTokenBucketLimiter(10, 1, cerberus.WithStore(nonScanningStore{cerberus.NewMemoryStore()}))
+	handler := New(map[string]cerberus.RateLimiter{"api": limiter}, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer operator" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	if rr := do(handler, "GET", "/limiters", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 without credentials; got %v", rr.Code)
+	}
+	for path, status := range map[string]int{
+		"/limiters/unknown/keys":  http.StatusNotFound,
+		"/limiters/api/keys":      http.StatusNotImplemented,
+		"/limiters/api/keys/a/b":  http.StatusNotFound,
+		"/limiters/api/keys/a%2F": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer operator")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != status {
+			t.Errorf("%s: expected status %v; got %v %s", path, status, rr.Code, rr.Body)
+		}
+	}
+}
+
+// nonScanningStore hides the KeyScanner implementation of a store.
+type nonScanningStore struct {
+	cerberus.Store
+}
+
+// Test New refuses to serve the API without an auth middleware
+func TestNewWithoutAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	New(nil, nil)
+}
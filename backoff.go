@@ -0,0 +1,54 @@
+package cerberus
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithBackoffHints sets whether the middlewares tell the clients of requests denied by an
+// [AdvancedRateLimiter] how to back off, so that they can retry smarter than at a fixed interval
+// or as soon as Retry-After has elapsed. It is disabled by default.
+//
+// Behavior:
+//   - Responses to denied requests carry the X-RateLimit-Backoff header, the base delay of the
+//     exponential backoff the client should follow if its retries keep being denied, and the
+//     X-RateLimit-Jitter header, the upper bound of the random delay it should add to each retry,
+//     both in milliseconds, whatever the header style.
+//   - The hints are the Backoff and Jitter of the [RateLimitData] of the request. If the rate
+//     limiter gives no hint, the base delay is the time the budget of the client takes to recover
+//     one request, derived from Rate, and the jitter is equal to the base delay.
+//   - No hint is written if the rate limiter gives none and does not report its Rate, or if rate
+//     limit headers are disabled with [WithHeaders].
+//
+// Clients should wait for Retry-After, plus a random delay of up to the jitter, before retrying,
+// then for the base delay doubled after each denial, plus the jitter, before the next retries.
+//
+// Example usage: http.Handle("/resource", New(NewTokenBucketLimiter(10, 1), WithBackoffHints(true))(myHandler))
+func WithBackoffHints(enabled bool) Option {
+	return func(o *options) {
+		o.backoffHints = enabled
+	}
+}
+
+// writeBackoffHints sets the headers reporting the backoff hints of data on the response to a
+// denied request, if enabled with [WithBackoffHints].
+func (o *options) writeBackoffHints(h http.Header, data RateLimitData) {
+	if !o.backoffHints {
+		return
+	}
+	backoff, jitter := data.Backoff, data.Jitter
+	if backoff == 0 && data.Rate > 0 {
+		backoff = time.Duration(float64(time.Second) / data.Rate)
+	}
+	if jitter == 0 {
+		jitter = backoff
+	}
+	if backoff <= 0 {
+		return
+	}
+	var buf [32]byte
+	values := headerValues{text: buf[:0]}
+	values = values.appendInt(max(backoff.Milliseconds(), 1))
+	values = values.appendInt(max(jitter.Milliseconds(), 1))
+	values.writeTo(h, o.headerKeys.backoff, o.headerKeys.jitter)
+}
@@ -0,0 +1,49 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test backoff hints are written on denied responses, from the data or derived from the rate
+func TestWithBackoffHints(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    RateLimitData
+		backoff string
+		jitter  string
+	}{
+		{"hints", RateLimitData{Limit: 10, Rate: 10, Backoff: 2 * time.Second, Jitter: 500 * time.Millisecond}, "2000", "500"},
+		{"derived", RateLimitData{Limit: 10, Rate: 4}, "250", "250"},
+		{"unknown", RateLimitData{Limit: 10}, "", ""},
+	}
+	for _, test := range tests {
+		mockLimiter := &MockAdvancedRateLimiter{
+			IsAllowedFunc:        func(r *http.Request) (bool, error) { return false, nil },
+			GetRateLimitDataFunc: func(r *http.Request) RateLimitData { return test.data },
+		}
+		rr := httptest.NewRecorder()
+		New(mockLimiter, WithBackoffHints(true))(noContent).ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+		if backoff := rr.Header().Get("X-RateLimit-Backoff"); backoff != test.backoff {
+			t.Errorf("%s: expected X-RateLimit-Backoff %q; got %q", test.name, test.backoff, backoff)
+		}
+		if jitter := rr.Header().Get("X-RateLimit-Jitter"); jitter != test.jitter {
+			t.Errorf("%s: expected X-RateLimit-Jitter %q; got %q", test.name, test.jitter, jitter)
+		}
+	}
+}
+
+// Test backoff hints are not written by default
+func TestBackoffHintsDisabledByDefault(t *testing.T) {
+	middleware := New(NewTokenBucketLimiter(1, 1))(noContent)
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-RateLimit-Backoff") != "" {
+		t.Errorf("expected no backoff hints; got %v", rr.Header())
+	}
+}
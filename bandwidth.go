@@ -0,0 +1,319 @@
+package cerberus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// bandwidthSweepInterval is the minimum interval between the removals of the idle buckets of a
+// [BandwidthLimiter].
+const bandwidthSweepInterval = time.Minute
+
+// BandwidthLimiter throttles the bytes per second uploaded and downloaded by each client, by
+// slowing down the reads of request bodies and the writes of responses, rather than counting
+// requests. It protects against clients that abuse a service with few but large requests, such as
+// bulk downloads or floods of uploads, which request-based limiters let through.
+//
+// Behavior:
+//   - Each client, identified by the key function set with [WithBandwidthKeyFunc], has a bucket
+//     for uploads and a bucket for downloads, which refill at a given number of bytes per second
+//     up to a burst. Requests of the same client share its buckets.
+//   - Reads of the request body and writes of the response are split into chunks of at most the
+//     burst, and each chunk waits until its bytes are available in the bucket. Throttled requests
+//     are slowed down rather than denied.
+//   - A wait is cut short when the context of the request is done, and the read or write then
+//     fails with the error of the context.
+//   - Requests whose key cannot be computed are not throttled.
+//   - Buckets of idle clients are removed, so memory is bounded by the number of active clients.
+//
+// Buckets are kept in memory, so each instance of a service throttles its clients separately.
+//
+// A BandwidthLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter := NewBandwidthLimiter(1<<20, 64<<10, WithMaxBodySize(10<<20)) // 1 MiB/s, 64 KiB burst
+//	http.Handle("/files/", limiter.Middleware()(filesHandler))
+type BandwidthLimiter struct {
+	keyFunc     KeyFunc
+	clock       Clock
+	maxBodySize int64
+	upload      *bandwidthBuckets
+	download    *bandwidthBuckets
+}
+
+// BandwidthOption configures a [BandwidthLimiter].
+type BandwidthOption func(*BandwidthLimiter)
+
+// WithBandwidthKeyFunc sets the function identifying the clients whose bandwidth is throttled.
+// The default is [KeyByIP].
+func WithBandwidthKeyFunc(keyFunc KeyFunc) BandwidthOption {
+	return func(l *BandwidthLimiter) {
+		l.keyFunc = keyFunc
+	}
+}
+
+// WithBandwidthClock sets the [Clock] the limiter refills buckets and waits by. The default is
+// [SystemClock].
+func WithBandwidthClock(clock Clock) BandwidthOption {
+	return func(l *BandwidthLimiter) {
+		l.clock = clock
+	}
+}
+
+// WithUploadBandwidth sets the rate and burst of request bodies, in place of those passed to
+// [NewBandwidthLimiter]. A rate of zero disables the throttling of uploads.
+//
+// It panics if bytesPerSecond is negative, or if burst is not positive while bytesPerSecond is.
+func WithUploadBandwidth(bytesPerSecond float64, burst int) BandwidthOption {
+	if bytesPerSecond < 0 || bytesPerSecond > 0 && burst <= 0 {
+		panic("cerberus: bandwidth must not be negative, and burst must be positive")
+	}
+	return func(l *BandwidthLimiter) {
+		l.upload = newBandwidthBuckets(bytesPerSecond, burst)
+	}
+}
+
+// WithDownloadBandwidth sets the rate and burst of responses, in place of those passed to
+// [NewBandwidthLimiter]. A rate of zero disables the throttling of downloads.
+//
+// It panics if bytesPerSecond is negative, or if burst is not positive while bytesPerSecond is.
+func WithDownloadBandwidth(bytesPerSecond float64, burst int) BandwidthOption {
+	if bytesPerSecond < 0 || bytesPerSecond > 0 && burst <= 0 {
+		panic("cerberus: bandwidth must not be negative, and burst must be positive")
+	}
+	return func(l *BandwidthLimiter) {
+		l.download = newBandwidthBuckets(bytesPerSecond, burst)
+	}
+}
+
+// WithMaxBodySize caps the size of request bodies at n bytes, so that a single request cannot
+// upload an unbounded amount of data however slowly. Requests declaring a larger Content-Length
+// are rejected with an HTTP 413 (Content Too Large) before reaching the handler. Reading past n
+// bytes of other bodies fails with an [*http.MaxBytesError], on which the handler should respond
+// with an HTTP 413 too. Zero, the default, means no cap.
+//
+// It panics if n is negative.
+func WithMaxBodySize(n int64) BandwidthOption {
+	if n < 0 {
+		panic("cerberus: max body size must not be negative")
+	}
+	return func(l *BandwidthLimiter) {
+		l.maxBodySize = n
+	}
+}
+
+// NewBandwidthLimiter creates a new [BandwidthLimiter] throttling both the uploads and the
+// downloads of each client at bytesPerSecond, with bursts of up to burst bytes. The burst should be
+// at least the size of the buffers requests are read and responses written with, typically 32
+// KiB, for throughput to be smooth.
+//
+// It panics if bytesPerSecond or burst is not positive.
+func NewBandwidthLimiter(bytesPerSecond float64, burst int, opts ...BandwidthOption) *BandwidthLimiter {
+	if bytesPerSecond <= 0 || burst <= 0 {
+		panic("cerberus: bandwidth and burst must be positive")
+	}
+	l := &BandwidthLimiter{
+		keyFunc:  KeyByIP,
+		clock:    SystemClock,
+		upload:   newBandwidthBuckets(bytesPerSecond, burst),
+		download: newBandwidthBuckets(bytesPerSecond, burst),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Middleware returns a middleware throttling the request bodies and the responses of the requests
+// it handles. It can be combined with the middleware returned by [New] to limit both the number of
+// requests and the bytes of each client.
+//
+// Example usage:
+//
+//	handler := New(myRateLimiter)(bandwidthLimiter.Middleware()(myHandler))
+func (l *BandwidthLimiter) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.maxBodySize > 0 {
+				if r.ContentLength > l.maxBodySize {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, l.maxBodySize)
+			}
+			key, err := l.keyFunc(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if l.upload != nil && r.Body != nil && r.Body != http.NoBody {
+				r.Body = &throttledReader{
+					ReadCloser: r.Body,
+					ctx:        r.Context(),
+					limiter:    l,
+					buckets:    l.upload,
+					key:        key,
+				}
+			}
+			if l.download != nil {
+				w = &throttledWriter{
+					ResponseWriter: w,
+					ctx:            r.Context(),
+					limiter:        l,
+					buckets:        l.download,
+					key:            key,
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// wait takes n bytes from the bucket of key in buckets, and waits until they are available. It
+// returns the error of ctx if it is done first.
+func (l *BandwidthLimiter) wait(ctx context.Context, buckets *bandwidthBuckets, key string, n int) error {
+	delay := buckets.reserve(key, n, l.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := l.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// bandwidthBuckets are the token buckets of the clients of a [BandwidthLimiter] in one direction,
+// holding bytes.
+type bandwidthBuckets struct {
+	rate     float64
+	burst    int
+	fillTime time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bandwidthBucket
+	sweptAt time.Time
+}
+
+// bandwidthBucket is the state of the bucket of a client. tokens is negative while bytes taken
+// from the bucket are still being waited for.
+type bandwidthBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newBandwidthBuckets returns the buckets refilling at rate bytes per second up to burst, or nil if
+// rate is zero, which disables throttling.
+func newBandwidthBuckets(rate float64, burst int) *bandwidthBuckets {
+	if rate == 0 {
+		return nil
+	}
+	return &bandwidthBuckets{
+		rate:     rate,
+		burst:    burst,
+		buckets:  make(map[string]*bandwidthBucket),
+		fillTime: bytesDuration(burst, rate),
+	}
+}
+
+// reserve takes n bytes from the bucket of key at now, and returns how long to wait until they are
+// available. Bytes are taken even if they are not yet available, so that concurrent requests of
+// the same client wait in turn.
+func (b *bandwidthBuckets) reserve(key string, n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep(now)
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &bandwidthBucket{tokens: float64(b.burst), updated: now}
+		b.buckets[key] = bucket
+	}
+	bucket.refill(b, now)
+	bucket.tokens -= float64(n)
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens / b.rate * float64(time.Second))
+}
+
+// refill adds the bytes accumulated since the last update of the bucket, up to the burst of b.
+func (bucket *bandwidthBucket) refill(b *bandwidthBuckets, now time.Time) {
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = min(float64(b.burst), bucket.tokens+elapsed.Seconds()*b.rate)
+		bucket.updated = now
+	}
+}
+
+// sweep removes the buckets that have been idle long enough to be full again, since they are
+// equivalent to new ones, if the sweep interval has elapsed since the last sweep.
+func (b *bandwidthBuckets) sweep(now time.Time) {
+	if now.Sub(b.sweptAt) < max(bandwidthSweepInterval, b.fillTime) {
+		return
+	}
+	b.sweptAt = now
+	for key, bucket := range b.buckets {
+		if bucket.refill(b, now); bucket.tokens >= float64(b.burst) {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+// throttledReader is a request body whose reads are throttled by a [BandwidthLimiter].
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *BandwidthLimiter
+	buckets *bandwidthBuckets
+	key     string
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.buckets.burst {
+		p = p[:r.buckets.burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, r.buckets, r.key, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter is an [http.ResponseWriter] whose writes are throttled by a [BandwidthLimiter].
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *BandwidthLimiter
+	buckets *bandwidthBuckets
+	key     string
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), w.buckets.burst)]
+		if err := w.limiter.wait(w.ctx, w.buckets, w.key, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+// Unwrap returns the wrapped [http.ResponseWriter], for use by [http.ResponseController].
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
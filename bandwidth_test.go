@@ -0,0 +1,261 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitRecordingClock is a [Clock] whose timers fire immediately, advancing the time by their
+// duration, and which records the durations waited.
+type waitRecordingClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newWaitRecordingClock() *waitRecordingClock {
+	return &waitRecordingClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *waitRecordingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *waitRecordingClock) Sleep(d time.Duration) {
+	c.NewTimer(d)
+}
+
+func (c *waitRecordingClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return firedTimer(ch)
+}
+
+// total returns the sum of the durations waited.
+func (c *waitRecordingClock) total() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total time.Duration
+	for _, d := range c.waits {
+		total += d
+	}
+	return total
+}
+
+// firedTimer is a [Timer] that already fired.
+type firedTimer chan time.Time
+
+func (t firedTimer) C() <-chan time.Time      { return t }
+func (t firedTimer) Stop() bool               { return false }
+func (t firedTimer) Reset(time.Duration) bool { return false }
+
+// TestBandwidthLimiterThrottlesDownloads tests that responses beyond the burst are written at the
+// rate of the limiter, in chunks of at most the burst.
+func TestBandwidthLimiterThrottlesDownloads(t *testing.T) {
+	clock := newWaitRecordingClock()
+	limiter := NewBandwidthLimiter(1000, 500, WithBandwidthClock(clock))
+	body := strings.Repeat("x", 2500)
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Body.String() != body {
+		t.Errorf("expected the full body to be written; got %d bytes", rr.Body.Len())
+	}
+	// The first 500 bytes are the burst, and the other 2000 bytes take 2 seconds.
+	if got := clock.total(); got != 2*time.Second {
+		t.Errorf("expected a total wait of 2s; got %v", got)
+	}
+	if len(clock.waits) != 4 {
+		t.Errorf("expected 4 waits of a chunk each; got %d", len(clock.waits))
+	}
+}
+
+// TestBandwidthLimiterThrottlesUploads tests that request bodies beyond the burst are read at the
+// upload rate, separately from the download rate.
+func TestBandwidthLimiterThrottlesUploads(t *testing.T) {
+	clock := newWaitRecordingClock()
+	limiter := NewBandwidthLimiter(1000, 500, WithBandwidthClock(clock), WithUploadBandwidth(100, 100))
+	var read int
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("expected no error reading the body; got %v", err)
+		}
+		read = len(b)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("x", 300)))
+	req.RemoteAddr = "192.0.2.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if read != 300 {
+		t.Errorf("expected 300 bytes read; got %d", read)
+	}
+	if got := clock.total(); got != 2*time.Second {
+		t.Errorf("expected a total wait of 2s; got %v", got)
+	}
+}
+
+// TestBandwidthLimiterKeys tests that clients are throttled separately, and that buckets refill
+// over time.
+func TestBandwidthLimiterKeys(t *testing.T) {
+	clock := newWaitRecordingClock()
+	limiter := NewBandwidthLimiter(1000, 1000, WithBandwidthClock(clock))
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.2:1234"))
+	if got := clock.total(); got != 0 {
+		t.Errorf("expected no wait within the burst of each client; got %v", got)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	if got := clock.total(); got != time.Second {
+		t.Errorf("expected a wait of 1s once the burst is used; got %v", got)
+	}
+
+	clock.Sleep(time.Second)
+	before := clock.total()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	if got := clock.total() - before; got != 0 {
+		t.Errorf("expected no wait once the bucket refilled; got %v", got)
+	}
+}
+
+// TestBandwidthLimiterDisabledDirection tests that a rate of zero disables the throttling of a
+// direction.
+func TestBandwidthLimiterDisabledDirection(t *testing.T) {
+	clock := newWaitRecordingClock()
+	limiter := NewBandwidthLimiter(10, 10, WithBandwidthClock(clock), WithDownloadBandwidth(0, 0))
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*throttledWriter); ok {
+			t.Errorf("expected the response writer not to be throttled")
+		}
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+
+	if got := clock.total(); got != 0 {
+		t.Errorf("expected no wait; got %v", got)
+	}
+}
+
+// TestBandwidthLimiterContextDone tests that a wait fails with the error of the context of the
+// request once it is done.
+func TestBandwidthLimiterContextDone(t *testing.T) {
+	limiter := NewBandwidthLimiter(1, 1)
+	var writeErr error
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, writeErr = io.WriteString(w, "xxx")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234").WithContext(ctx))
+
+	if !errors.Is(writeErr, context.DeadlineExceeded) {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, writeErr)
+	}
+}
+
+// TestBandwidthLimiterMaxBodySize tests that requests declaring too large a body are rejected,
+// and that reading past the maximum size of other bodies fails.
+func TestBandwidthLimiterMaxBodySize(t *testing.T) {
+	limiter := NewBandwidthLimiter(1e9, 1<<20, WithMaxBodySize(10))
+	var readErr error
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("x", 11)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d; got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("x", 11)))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("expected an *http.MaxBytesError; got %v", readErr)
+	}
+}
+
+// TestBandwidthLimiterUnwrap tests that the throttled response writer can be unwrapped by
+// http.ResponseController.
+func TestBandwidthLimiterUnwrap(t *testing.T) {
+	limiter := NewBandwidthLimiter(1e9, 1<<20)
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected no error flushing; got %v", err)
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if !rr.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+}
+
+// TestBandwidthBucketsSweep tests that the buckets of idle clients are removed once full again.
+func TestBandwidthBucketsSweep(t *testing.T) {
+	buckets := newBandwidthBuckets(100, 100)
+	now := time.Unix(1700000000, 0)
+	buckets.reserve("a", 100, now)
+	buckets.reserve("b", 50, now)
+
+	now = now.Add(bandwidthSweepInterval)
+	buckets.reserve("b", 100, now)
+
+	if _, ok := buckets.buckets["a"]; ok {
+		t.Errorf("expected the idle bucket to be removed")
+	}
+	if _, ok := buckets.buckets["b"]; !ok {
+		t.Errorf("expected the active bucket to be kept")
+	}
+}
+
+// TestNewBandwidthLimiterPanics tests that invalid rates and bursts panic.
+func TestNewBandwidthLimiterPanics(t *testing.T) {
+	tests := map[string]func(){
+		"zero rate":           func() { NewBandwidthLimiter(0, 1) },
+		"zero burst":          func() { NewBandwidthLimiter(1, 0) },
+		"negative upload":     func() { WithUploadBandwidth(-1, 1) },
+		"zero download burst": func() { WithDownloadBandwidth(1, 0) },
+		"negative body size":  func() { WithMaxBodySize(-1) },
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			f()
+		})
+	}
+}
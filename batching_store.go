@@ -0,0 +1,363 @@
+package cerberus
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IncrementOp is an increment of a counter applied as part of a batch, with the arguments of
+// [Store.Increment].
+type IncrementOp struct {
+	Key   string
+	Delta int64
+	TTL   time.Duration
+}
+
+// IncrementResult is the outcome of an [IncrementOp]: the value of the counter after the increment,
+// or the error that prevented it.
+type IncrementResult struct {
+	Value int64
+	Err   error
+}
+
+// BatchIncrementer is implemented by stores that can apply several increments in a single round
+// trip, for example with a pipeline of commands. [BatchingStore] uses it to flush its batches.
+type BatchIncrementer interface {
+	// IncrementBatch applies each of ops as if by Increment, and returns their results in the
+	// same order. The increments need not be atomic as a whole.
+	IncrementBatch(ctx context.Context, ops []IncrementOp) []IncrementResult
+}
+
+// BatchingStore is a [Store] that coalesces the increments made within a short window before
+// applying them to a remote store, so that high traffic services make one round trip per batch
+// rather than one per request.
+//
+// Behavior:
+//   - Increments are queued, and the queue is flushed when the batch window has elapsed since the
+//     first increment queued, or when the batch is full.
+//   - Increments of the same key within a batch are coalesced into one, and each caller gets the
+//     value the counter would have had after its own increment had they been applied one by one.
+//   - Batches are applied with a single call to IncrementBatch if the store implements
+//     [BatchIncrementer], and with concurrent calls to Increment otherwise.
+//   - All other operations are passed through to the store.
+//
+// The batch window bounds the latency added to each increment, and trades it against the number
+// of round trips saved. With speculative increments, set with [WithSpeculativeIncrements],
+// increments do not wait for the flush at all: they return the last value read from the store plus
+// the increments queued since, so that decisions take no round trip, at the cost of admitting
+// requests made concurrently on other instances that the store would have counted first.
+//
+// A BatchingStore is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	store := NewBatchingStore(myRedisStore, WithBatchWindow(5*time.Millisecond), WithMaxBatchSize(500))
+//	limiter := NewFixedWindowLimiter(1000, time.Second, WithStore(store))
+type BatchingStore struct {
+	Store
+	window      time.Duration
+	maxBatch    int
+	speculative bool
+	onError     func(error)
+
+	mu      sync.Mutex
+	pending []*queuedIncrement
+	timer   *time.Timer
+
+	// queued holds the sum of the deltas queued for each key, and known the last value of each key
+	// read from the store. They are only maintained for speculative increments.
+	queued    map[string]int64
+	known     map[string]knownCount
+	nextPrune time.Time
+
+	now func() time.Time
+}
+
+// queuedIncrement is an increment waiting for its batch to be flushed. done is closed once result
+// is set.
+type queuedIncrement struct {
+	op     IncrementOp
+	done   chan struct{}
+	result IncrementResult
+}
+
+// knownCount is the value of a counter read from the store, and when it expires locally. A zero
+// expiration time means it does not expire.
+type knownCount struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// BatchingStoreOption configures a [BatchingStore].
+type BatchingStoreOption func(*BatchingStore)
+
+// WithBatchWindow sets how long a [BatchingStore] waits for more increments after the first one of
+// a batch, which is the latency added to increments that are not speculative. The default is 5ms.
+func WithBatchWindow(window time.Duration) BatchingStoreOption {
+	return func(s *BatchingStore) {
+		s.window = window
+	}
+}
+
+// WithMaxBatchSize sets the number of increments that makes a [BatchingStore] flush its batch
+// before the batch window has elapsed. The default is 1000.
+func WithMaxBatchSize(size int) BatchingStoreOption {
+	return func(s *BatchingStore) {
+		s.maxBatch = size
+	}
+}
+
+// WithSpeculativeIncrements makes the increments of a [BatchingStore] return immediately, with the
+// value estimated from the last value read from the store and the increments queued since. Errors
+// of the flushes are reported to onError, which may be nil to ignore them. Estimates of keys that
+// have not been read from the store yet start at zero, so every instance may admit up to a batch
+// worth of requests for a new key before seeing the counts of the others.
+func WithSpeculativeIncrements(onError func(error)) BatchingStoreOption {
+	return func(s *BatchingStore) {
+		s.speculative = true
+		s.onError = onError
+	}
+}
+
+// NewBatchingStore creates a new [BatchingStore] batching the increments made to store.
+//
+// It panics if the batch window or the maximum batch size is not positive.
+func NewBatchingStore(store Store, opts ...BatchingStoreOption) *BatchingStore {
+	s := &BatchingStore{
+		Store:    store,
+		window:   5 * time.Millisecond,
+		maxBatch: 1000,
+		queued:   make(map[string]int64),
+		known:    make(map[string]knownCount),
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.window <= 0 {
+		panic("cerberus: batch window must be positive")
+	}
+	if s.maxBatch <= 0 {
+		panic("cerberus: maximum batch size must be positive")
+	}
+	return s
+}
+
+// Increment queues the increment of key for the next batch. Unless increments are speculative, it
+// waits for the batch to be flushed, and returns the result of the increment or ctx.Err() if ctx
+// is done first.
+func (s *BatchingStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	increment := &queuedIncrement{
+		op:   IncrementOp{Key: key, Delta: delta, TTL: ttl},
+		done: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, increment)
+	var estimate int64
+	if s.speculative {
+		s.queued[key] += delta
+		estimate = s.queued[key]
+		if known, ok := s.known[key]; ok && (known.expiresAt.IsZero() || s.now().Before(known.expiresAt)) {
+			estimate += known.value
+		}
+	}
+	var batch []*queuedIncrement
+	if len(s.pending) >= s.maxBatch {
+		batch = s.take()
+	} else if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.Flush)
+	}
+	s.mu.Unlock()
+
+	if batch != nil {
+		if s.speculative {
+			go s.flush(batch)
+		} else {
+			s.flush(batch)
+		}
+	}
+	if s.speculative {
+		return estimate, nil
+	}
+	select {
+	case <-increment.done:
+		return increment.result.Value, increment.result.Err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// ScanKeys returns the keys of the store starting with prefix. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (s *BatchingStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	return scanKeys(ctx, s.Store, prefix)
+}
+
+// Flush applies the queued increments to the store immediately, and waits for them to be applied.
+// It should be called before shutting down, so that speculative increments are not lost.
+func (s *BatchingStore) Flush() {
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	s.flush(batch)
+}
+
+// Close flushes the queued increments, like Flush, waiting for them to be applied until ctx is
+// done, in which case it returns the error of ctx and the flush completes in the background. The
+// store remains usable afterwards, and the wrapped store is not closed. It implements [Closer].
+func (s *BatchingStore) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Flush()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// take removes the queued increments from the queue and returns them. s.mu must be held.
+func (s *BatchingStore) take() []*queuedIncrement {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	batch := s.pending
+	s.pending = nil
+	return batch
+}
+
+// flush coalesces the increments of batch by key, applies them to the store, and completes them.
+func (s *BatchingStore) flush(batch []*queuedIncrement) {
+	if len(batch) == 0 {
+		return
+	}
+	var ops []IncrementOp
+	index := make(map[string]int)
+	for _, increment := range batch {
+		i, ok := index[increment.op.Key]
+		if !ok {
+			i = len(ops)
+			index[increment.op.Key] = i
+			ops = append(ops, IncrementOp{Key: increment.op.Key, TTL: increment.op.TTL})
+		}
+		ops[i].Delta += increment.op.Delta
+	}
+	results := s.apply(ops)
+
+	// Each key's increments are completed from the last to the first, taking their deltas back
+	// off the final value of the counter.
+	values := make([]int64, len(results))
+	for i, result := range results {
+		values[i] = result.Value
+	}
+	for j := len(batch) - 1; j >= 0; j-- {
+		increment := batch[j]
+		i := index[increment.op.Key]
+		increment.result = IncrementResult{Value: values[i], Err: results[i].Err}
+		values[i] -= increment.op.Delta
+	}
+	if s.speculative {
+		s.settle(ops, results)
+	}
+	for _, increment := range batch {
+		close(increment.done)
+	}
+}
+
+// apply applies ops to the store.
+func (s *BatchingStore) apply(ops []IncrementOp) []IncrementResult {
+	return incrementBatch(context.Background(), s.Store, ops)
+}
+
+// incrementBatch applies ops to store, in a single call if it implements [BatchIncrementer], and
+// with concurrent calls to Increment otherwise.
+func incrementBatch(ctx context.Context, store Store, ops []IncrementOp) []IncrementResult {
+	if batcher, ok := store.(BatchIncrementer); ok {
+		return batcher.IncrementBatch(ctx, ops)
+	}
+	results := make([]IncrementResult, len(ops))
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Value, results[i].Err = store.Increment(ctx, op.Key, op.Delta, op.TTL)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// settle records the values read from the store by a flush, and takes the flushed deltas off the
+// queued ones. Expired values are pruned about once per second.
+func (s *BatchingStore) settle(ops []IncrementOp, results []IncrementResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for i, op := range ops {
+		if s.queued[op.Key] -= op.Delta; s.queued[op.Key] == 0 {
+			delete(s.queued, op.Key)
+		}
+		if results[i].Err != nil {
+			if s.onError != nil {
+				s.onError(results[i].Err)
+			}
+			continue
+		}
+		known, ok := s.known[op.Key]
+		if !ok || !known.expiresAt.IsZero() && !now.Before(known.expiresAt) {
+			// The counter was created by this batch or an earlier one, and expires no later than
+			// a TTL after now.
+			known.expiresAt = time.Time{}
+			if op.TTL > 0 {
+				known.expiresAt = now.Add(op.TTL)
+			}
+		}
+		known.value = results[i].Value
+		s.known[op.Key] = known
+	}
+	if now.After(s.nextPrune) {
+		for key, known := range s.known {
+			if !known.expiresAt.IsZero() && !now.Before(known.expiresAt) {
+				delete(s.known, key)
+			}
+		}
+		s.nextPrune = now.Add(time.Second)
+	}
+}
+
+// estimate returns the value of key estimated from the last value read from the store and the
+// increments queued since, and reports whether the key is tracked locally at all. It is only
+// meaningful for speculative increments.
+func (s *BatchingStore) estimate(key string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	estimate, queued := s.queued[key]
+	known, ok := s.known[key]
+	if ok && (known.expiresAt.IsZero() || s.now().Before(known.expiresAt)) {
+		return estimate + known.value, true
+	}
+	return estimate, queued
+}
+
+// forget drops the queued increments of key and its last known value, so that they do not outlive
+// the deletion of the key.
+func (s *BatchingStore) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = slices.DeleteFunc(s.pending, func(increment *queuedIncrement) bool {
+		if increment.op.Key != key {
+			return false
+		}
+		close(increment.done)
+		return true
+	})
+	delete(s.queued, key)
+	delete(s.known, key)
+}
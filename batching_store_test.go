@@ -0,0 +1,196 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore is a Store counting the increments applied to it.
+type countingStore struct {
+	Store
+	increments atomic.Int64
+	err        error
+}
+
+func (s *countingStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.increments.Add(1)
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.Store.Increment(ctx, key, delta, ttl)
+}
+
+// batchStore is a Store applying increments in batches.
+type batchStore struct {
+	countingStore
+	batches atomic.Int64
+}
+
+func (s *batchStore) IncrementBatch(ctx context.Context, ops []IncrementOp) []IncrementResult {
+	s.batches.Add(1)
+	results := make([]IncrementResult, len(ops))
+	for i, op := range ops {
+		results[i].Value, results[i].Err = s.Store.Increment(ctx, op.Key, op.Delta, op.TTL)
+	}
+	return results
+}
+
+// Test increments within a window are coalesced, and each caller gets its own result
+func TestBatchingStoreCoalescesIncrements(t *testing.T) {
+	store := &countingStore{Store: NewMemoryStore()}
+	batching := NewBatchingStore(store, WithBatchWindow(50*time.Millisecond))
+
+	var mu sync.Mutex
+	var values []int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := batching.Increment(context.Background(), "a", 1, time.Minute)
+			if err != nil {
+				t.Errorf("expected no error; got %v", err)
+			}
+			mu.Lock()
+			values = append(values, value)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	slices.Sort(values)
+	if !slices.Equal(values, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("expected values 1 to 10; got %v", values)
+	}
+	if n := store.increments.Load(); n != 1 {
+		t.Errorf("expected 1 increment of the store; got %d", n)
+	}
+	if value, _ := batching.Get(context.Background(), "a"); string(value) != "10" {
+		t.Errorf("expected 10; got %q", value)
+	}
+}
+
+// Test batches are flushed when full, with a single call to a batch incrementer
+func TestBatchingStoreFlushesFullBatches(t *testing.T) {
+	store := &batchStore{countingStore: countingStore{Store: NewMemoryStore()}}
+	batching := NewBatchingStore(store, WithBatchWindow(time.Hour), WithMaxBatchSize(3))
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := batching.Increment(context.Background(), key, 2, 0); err != nil || value != 2 {
+				t.Errorf("expected 2; got %d, %v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := store.batches.Load(); n != 1 {
+		t.Errorf("expected 1 batch; got %d", n)
+	}
+	if n := store.increments.Load(); n != 0 {
+		t.Errorf("expected no single increment; got %d", n)
+	}
+}
+
+// Test speculative increments return estimates without waiting for the store
+func TestBatchingStoreSpeculativeIncrements(t *testing.T) {
+	store := &countingStore{Store: NewMemoryStore()}
+	var flushErr error
+	batching := NewBatchingStore(store, WithBatchWindow(time.Hour), WithSpeculativeIncrements(func(err error) { flushErr = err }))
+	ctx := context.Background()
+	store.Store.Increment(ctx, "a", 5, time.Minute)
+
+	if value, _ := batching.Increment(ctx, "a", 1, time.Minute); value != 1 {
+		t.Errorf("expected an estimate of 1 for an unknown key; got %d", value)
+	}
+	batching.Flush()
+	if value, _ := batching.Increment(ctx, "a", 1, time.Minute); value != 7 {
+		t.Errorf("expected an estimate of 7 after a flush; got %d", value)
+	}
+	if n := store.increments.Load(); n != 1 {
+		t.Errorf("expected the second increment to be queued; got %d increments", n)
+	}
+	batching.Flush()
+	if value, _ := batching.Get(ctx, "a"); string(value) != "7" {
+		t.Errorf("expected 7; got %q", value)
+	}
+
+	store.err = errors.New("store unavailable")
+	batching.Increment(ctx, "a", 1, time.Minute)
+	batching.Flush()
+	if !errors.Is(flushErr, store.err) {
+		t.Errorf("expected the flush error to be reported; got %v", flushErr)
+	}
+}
+
+// Test waiting callers give up when their context is done
+func TestBatchingStoreContextCanceled(t *testing.T) {
+	batching := NewBatchingStore(NewMemoryStore(), WithBatchWindow(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := batching.Increment(ctx, "a", 1, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled; got %v", err)
+	}
+	batching.Flush()
+	if keys, err := batching.ScanKeys(context.Background(), ""); err != nil || !slices.Equal(keys, []string{"a"}) {
+		t.Errorf("expected the increment to be applied; got %v, %v", keys, err)
+	}
+}
+
+// Test limiters work on top of a batching store
+func TestBatchingStoreWithLimiter(t *testing.T) {
+	limiter := NewFixedWindowLimiter(2, time.Minute, WithStore(NewBatchingStore(NewMemoryStore(), WithBatchWindow(time.Millisecond))))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+}
+
+// Test closing the store flushes the queued increments
+func TestBatchingStoreClose(t *testing.T) {
+	store := NewMemoryStore()
+	batching := NewBatchingStore(store, WithBatchWindow(time.Hour), WithSpeculativeIncrements(nil))
+	ctx := context.Background()
+	batching.Increment(ctx, "a", 3, time.Minute)
+
+	if err := batching.Close(ctx); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if value, _ := store.Get(ctx, "a"); string(value) != "3" {
+		t.Errorf("expected the increment to be flushed; got %q", value)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	blocked := &blockingStore{Store: NewMemoryStore(), release: make(chan struct{})}
+	batching = NewBatchingStore(blocked, WithBatchWindow(time.Hour), WithSpeculativeIncrements(nil))
+	batching.Increment(ctx, "a", 1, time.Minute)
+	if err := batching.Close(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v; got %v", context.Canceled, err)
+	}
+	close(blocked.release)
+}
+
+// blockingStore is a Store whose increments block until release is closed.
+type blockingStore struct {
+	Store
+	release chan struct{}
+}
+
+func (s *blockingStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	<-s.release
+	return s.Store.Increment(ctx, key, delta, ttl)
+}
@@ -0,0 +1,95 @@
+package cerberus
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minSecretSize is the size, in bytes, of the shortest secret accepted to sign bypass headers,
+// browser ID cookies, and webhook notifications.
+const minSecretSize = 16
+
+// MatchSignedBypass returns a [RequestMatcher], to be passed to [WithAllowlist], that matches the
+// requests of trusted internal callers presenting a bypass header signed with a shared secret, as
+// set by [SetBypassHeader]. It suits service meshes, where callers cannot be told apart by their
+// IP address.
+//
+// Behavior:
+//   - The header holds the time it was signed at and an HMAC-SHA256 of that time, the method, and
+//     the path of the request, so that it cannot be forged without the secret, nor reused for
+//     other endpoints.
+//   - Headers signed more than maxAge before or after the current time do not match, so that
+//     captured headers cannot be replayed later. maxAge should cover the clock skew between
+//     callers plus the time requests take to arrive, such as 30 seconds.
+//   - Headers signed with any of secrets match, so that secrets can be rotated by first adding
+//     the new secret to the matcher, then signing with it, then removing the old secret.
+//
+// Headers can be replayed to the same endpoint within maxAge, so the bypass header must only be
+// sent over encrypted connections.
+//
+// It panics if no secret is given, or if a secret is shorter than 16 bytes.
+//
+// Example usage: http.Handle("/", New(limiter, WithAllowlist(MatchSignedBypass("X-Internal-Bypass", 30*time.Second, secret)))(myHandler))
+func MatchSignedBypass(header string, maxAge time.Duration, secrets ...[]byte) RequestMatcher {
+	if len(secrets) == 0 {
+		panic("cerberus: at least one bypass secret is required")
+	}
+	for _, secret := range secrets {
+		if len(secret) < minSecretSize {
+			panic("cerberus: bypass secret must be at least 16 bytes")
+		}
+	}
+	return func(r *http.Request) bool {
+		value := r.Header.Get(header)
+		timestamp, signature, ok := strings.Cut(value, ".")
+		if !ok {
+			return false
+		}
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		if age := time.Since(time.Unix(signedAt, 0)); age > maxAge || age < -maxAge {
+			return false
+		}
+		mac, err := hex.DecodeString(signature)
+		if err != nil {
+			return false
+		}
+		for _, secret := range secrets {
+			if hmac.Equal(mac, bypassMAC(secret, timestamp, r.Method, r.URL.Path)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SetBypassHeader sets the header of r matched by [MatchSignedBypass], signed with secret at the
+// current time, for internal callers to skip the rate limits of the services they call. It must
+// be called right before r is sent, and again for each retry.
+//
+// Example usage: SetBypassHeader(req, "X-Internal-Bypass", secret)
+func SetBypassHeader(r *http.Request, header string, secret []byte) {
+	r.Header.Set(header, signBypass(secret, time.Now(), r.Method, r.URL.Path))
+}
+
+// signBypass returns the value of a bypass header signed with secret at signedAt for a request
+// with the given method and path.
+func signBypass(secret []byte, signedAt time.Time, method, path string) string {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	return timestamp + "." + hex.EncodeToString(bypassMAC(secret, timestamp, method, path))
+}
+
+// bypassMAC returns the HMAC-SHA256, keyed with secret, of the timestamp, the method, and the path
+// of a bypass header.
+func bypassMAC(secret []byte, timestamp, method, path string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + strings.ToUpper(method) + "\n" + path))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,99 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// bypassSecret is a secret signing bypass headers in tests.
+var bypassSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// Test requests with a valid bypass header skip rate limiting
+func TestMatchSignedBypass(t *testing.T) {
+	deny := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	handler := New(deny, WithAllowlist(MatchSignedBypass("X-Internal-Bypass", 30*time.Second, bypassSecret)))(noContent)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	SetBypassHeader(req, "X-Internal-Bypass", bypassSecret)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected a signed request to bypass the limit; got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected an unsigned request to be limited; got %d", rr.Code)
+	}
+}
+
+// Test forged, expired, and misused bypass headers do not match
+func TestMatchSignedBypassRejects(t *testing.T) {
+	otherSecret := []byte("fedcba9876543210fedcba9876543210")
+	match := MatchSignedBypass("X-Internal-Bypass", 30*time.Second, bypassSecret)
+	now := time.Now()
+
+	for name, test := range map[string]struct {
+		value  string
+		method string
+	}{
+		"missing":      {"", http.MethodGet},
+		"malformed":    {"not a signature", http.MethodGet},
+		"bad hex":      {strconv.FormatInt(now.Unix(), 10) + ".zz", http.MethodGet},
+		"other secret": {signBypass(otherSecret, now, http.MethodGet, "/orders"), http.MethodGet},
+		"expired":      {signBypass(bypassSecret, now.Add(-time.Minute), http.MethodGet, "/orders"), http.MethodGet},
+		"future":       {signBypass(bypassSecret, now.Add(time.Minute), http.MethodGet, "/orders"), http.MethodGet},
+		"other path":   {signBypass(bypassSecret, now, http.MethodGet, "/admin"), http.MethodGet},
+		"other method": {signBypass(bypassSecret, now, http.MethodGet, "/orders"), http.MethodDelete},
+	} {
+		req := httptest.NewRequest(test.method, "/orders", nil)
+		req.Header.Set("X-Internal-Bypass", test.value)
+		if match(req) {
+			t.Errorf("%s: expected the bypass header not to match", name)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("X-Internal-Bypass", signBypass(bypassSecret, now.Add(-20*time.Second), http.MethodGet, "/orders"))
+	if !match(req) {
+		t.Errorf("expected a bypass header signed within the max age to match")
+	}
+}
+
+// Test headers signed with any of the secrets match, for rotation
+func TestMatchSignedBypassRotation(t *testing.T) {
+	newSecret := []byte("fedcba9876543210fedcba9876543210")
+	match := MatchSignedBypass("X-Internal-Bypass", time.Minute, newSecret, bypassSecret)
+
+	for _, secret := range [][]byte{bypassSecret, newSecret} {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		SetBypassHeader(req, "X-Internal-Bypass", secret)
+		if !match(req) {
+			t.Errorf("expected a header signed with either secret to match")
+		}
+	}
+}
+
+// Test short or missing secrets are rejected
+func TestMatchSignedBypassPanics(t *testing.T) {
+	for name, secrets := range map[string][][]byte{
+		"no secret":    nil,
+		"short secret": {[]byte("short")},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			MatchSignedBypass("X-Internal-Bypass", time.Minute, secrets...)
+		}()
+	}
+}
@@ -0,0 +1,172 @@
+package cerberus
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CachingStore is a write-behind cache in front of a remote [Store], which makes the decisions of
+// counter-based limiters, such as [FixedWindowLimiter] and [QuotaLimiter], without any round trip
+// to the store.
+//
+// Behavior:
+//   - Increments are applied to a local snapshot of the counters and return immediately. The
+//     deltas are flushed to the store asynchronously, once per sync interval, and the values read
+//     back from the store replace the snapshot, so that the counts of the other instances are
+//     seen after at most one sync interval.
+//   - Reads of counters tracked locally are served from the snapshot, including the deltas not
+//     flushed yet. Times to live, which limiters read to check for blocks, are cached for one sync
+//     interval. Other reads and writes are passed through to the store.
+//   - Errors of the flushes are reported to the error handler, and the deltas of failed flushes are
+//     lost, so that an unavailable store does not make the limiter deny every request.
+//
+// This keeps the latency added by the store near zero, at the cost of over-admission: each
+// instance admits the requests the store would have counted against the other instances since the
+// last sync, so the limit may be exceeded by up to the rate of the other instances times the sync
+// interval. Limiters keeping state other than counters, such as [TokenBucketLimiter], go through to
+// the store on every request, and gain nothing from the cache.
+//
+// A CachingStore is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	store := NewCachingStore(myRedisStore, 100*time.Millisecond, func(err error) { log.Print(err) })
+//	defer store.Close(context.Background())
+//	limiter := NewFixedWindowLimiter(1000, time.Minute, WithStore(store))
+type CachingStore struct {
+	store        Store
+	batching     *BatchingStore
+	syncInterval time.Duration
+
+	mu        sync.Mutex
+	ttls      map[string]cachedTTL
+	nextPrune time.Time
+
+	now func() time.Time
+}
+
+// cachedTTL is the time to live of a key read from the store at readAt.
+type cachedTTL struct {
+	ttl    time.Duration
+	readAt time.Time
+}
+
+// NewCachingStore creates a new [CachingStore] in front of store, flushing deltas every
+// syncInterval and reporting the errors of the flushes to onError, which may be nil to ignore
+// them.
+//
+// It panics if syncInterval is not positive.
+func NewCachingStore(store Store, syncInterval time.Duration, onError func(error)) *CachingStore {
+	if syncInterval <= 0 {
+		panic("cerberus: sync interval must be positive")
+	}
+	return &CachingStore{
+		store: store,
+		batching: NewBatchingStore(store,
+			WithBatchWindow(syncInterval),
+			WithMaxBatchSize(math.MaxInt),
+			WithSpeculativeIncrements(onError)),
+		syncInterval: syncInterval,
+		ttls:         make(map[string]cachedTTL),
+		now:          time.Now,
+	}
+}
+
+// Get returns the value of key from the local snapshot if it is a counter tracked locally, and from
+// the store otherwise.
+func (s *CachingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := s.batching.estimate(key); ok {
+		return []byte(strconv.FormatInt(value, 10)), nil
+	}
+	return s.store.Get(ctx, key)
+}
+
+// Set stores value under key in the store, dropping the deltas of key that were not flushed yet.
+func (s *CachingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.forget(key)
+	return s.store.Set(ctx, key, value, ttl)
+}
+
+// Increment adds delta to the counter of key in the local snapshot, and returns its estimated
+// value. The delta is flushed to the store at the end of the sync interval.
+func (s *CachingStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.batching.Increment(ctx, key, delta, ttl)
+}
+
+// CompareAndSwap swaps the value of key in the store, dropping the deltas of key that were not
+// flushed yet if the value was swapped.
+func (s *CachingStore) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	swapped, err := s.store.CompareAndSwap(ctx, key, old, new, ttl)
+	if swapped {
+		s.forget(key)
+	}
+	return swapped, err
+}
+
+// TTL returns the remaining time to live of key in the store, as read at most one sync interval
+// ago.
+func (s *CachingStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.ttls[key]
+	s.mu.Unlock()
+	if ok && now.Sub(cached.readAt) < s.syncInterval {
+		if cached.ttl == 0 {
+			return 0, nil
+		}
+		return max(cached.ttl-now.Sub(cached.readAt), 0), nil
+	}
+
+	ttl, err := s.store.TTL(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttls[key] = cachedTTL{ttl: ttl, readAt: now}
+	if now.After(s.nextPrune) {
+		for key, cached := range s.ttls {
+			if now.Sub(cached.readAt) >= s.syncInterval {
+				delete(s.ttls, key)
+			}
+		}
+		s.nextPrune = now.Add(s.syncInterval)
+	}
+	return ttl, nil
+}
+
+// Delete removes key from the store, dropping the deltas of key that were not flushed yet.
+func (s *CachingStore) Delete(ctx context.Context, key string) error {
+	s.forget(key)
+	return s.store.Delete(ctx, key)
+}
+
+// ScanKeys returns the keys of the store starting with prefix. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (s *CachingStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	return scanKeys(ctx, s.store, prefix)
+}
+
+// Flush flushes the deltas not flushed yet to the store immediately, and waits for the flush to
+// complete.
+func (s *CachingStore) Flush() {
+	s.batching.Flush()
+}
+
+// forget drops the local state of key, which is being written to the store.
+func (s *CachingStore) forget(key string) {
+	s.batching.forget(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ttls, key)
+}
+
+// Close flushes the deltas not flushed yet, waiting for the flush to complete until ctx is done, in
+// which case it returns the error of ctx. It should be called before shutting down, so that they
+// are not lost. The store is not closed. It implements [Closer].
+func (s *CachingStore) Close(ctx context.Context) error {
+	return s.batching.Close(ctx)
+}
@@ -0,0 +1,104 @@
+package cerberus
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// callCountingStore is a Store counting the calls made to it.
+type callCountingStore struct {
+	Store
+	calls atomic.Int64
+}
+
+func (s *callCountingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.calls.Add(1)
+	return s.Store.Get(ctx, key)
+}
+
+func (s *callCountingStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.calls.Add(1)
+	return s.Store.Increment(ctx, key, delta, ttl)
+}
+
+func (s *callCountingStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.calls.Add(1)
+	return s.Store.TTL(ctx, key)
+}
+
+// Test decisions are made locally and deltas are flushed to the store
+func TestCachingStoreDecidesLocally(t *testing.T) {
+	remote := &callCountingStore{Store: NewMemoryStore()}
+	store := NewCachingStore(remote, time.Hour, nil)
+	limiter := NewFixedWindowLimiter(3, time.Hour, WithStore(store))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 3; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 0 {
+		t.Errorf("expected no remaining requests; got %+v", data)
+	}
+	if calls := remote.calls.Load(); calls != 1 {
+		t.Errorf("expected a single read of the block; got %d calls", calls)
+	}
+
+	store.Close(context.Background())
+	if data, _ := NewFixedWindowLimiter(3, time.Hour, WithStore(remote)).Usage(context.Background(), "192.0.2.1"); data.Remaining != 0 {
+		t.Errorf("expected the deltas to be flushed; got %+v", data)
+	}
+}
+
+// Test instances see each other's counts after a sync
+func TestCachingStoreSyncsInstances(t *testing.T) {
+	remote := NewMemoryStore()
+	a, b := NewCachingStore(remote, time.Hour, nil), NewCachingStore(remote, time.Hour, nil)
+	ctx := context.Background()
+
+	a.Increment(ctx, "counter", 2, time.Minute)
+	a.Flush()
+	b.Increment(ctx, "counter", 1, time.Minute)
+	b.Flush()
+	if value, _ := b.Get(ctx, "counter"); string(value) != "3" {
+		t.Errorf("expected 3 after a sync; got %q", value)
+	}
+	if value, _ := b.Increment(ctx, "counter", 1, time.Minute); value != 4 {
+		t.Errorf("expected 4; got %d", value)
+	}
+
+	b.Delete(ctx, "counter")
+	b.Flush()
+	if value, _ := b.Get(ctx, "counter"); value != nil {
+		t.Errorf("expected a deleted counter to be missing; got %q", value)
+	}
+}
+
+// Test blocks are seen after at most one sync interval
+func TestCachingStoreCachesTTLs(t *testing.T) {
+	remote := NewMemoryStore()
+	store := NewCachingStore(remote, time.Minute, nil)
+	clock := newFakeClock()
+	store.now = clock.Now
+	ctx := context.Background()
+
+	store.TTL(ctx, "blocked")
+	remote.Set(ctx, "blocked", []byte{1}, time.Hour)
+	if ttl, _ := store.TTL(ctx, "blocked"); ttl != 0 {
+		t.Errorf("expected the cached TTL; got %v", ttl)
+	}
+	clock.Advance(time.Minute)
+	if ttl, _ := store.TTL(ctx, "blocked"); ttl <= 0 {
+		t.Errorf("expected the TTL to be read again; got %v", ttl)
+	}
+	store.Set(ctx, "blocked", []byte{1}, time.Second)
+	if ttl, _ := store.TTL(ctx, "blocked"); ttl <= 0 || ttl > time.Second {
+		t.Errorf("expected writes to invalidate the cached TTL; got %v", ttl)
+	}
+}
@@ -0,0 +1,172 @@
+// Package cerberus provides extensible rate limiting middleware for net/http servers.
+//
+// Rate limiting decisions are made by a [RateLimiter], or an [AdvancedRateLimiter] that also
+// reports the remaining quota of a client. The package ships several built-in limiters, such as
+// [TokenBucketLimiter] and [SlidingWindowLimiter], which keep their state in a pluggable [Store].
+//
+// Limiters are installed in front of a handler with [New], which accepts options to customize
+// headers, responses, and failure handling:
+//
+//	limiter := cerberus.NewTokenBucketLimiter(10, 1)
+//	http.Handle("/resource", cerberus.New(limiter, cerberus.WithHeaderStyle(cerberus.HeaderStyleIETF))(myHandler))
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// New returns a middleware constructor that applies rate limiting with the provided [RateLimiter]
+// to the handlers it wraps. It is the single entry point for all middleware features, which are
+// enabled through options rather than separate middleware variants.
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, it is forwarded to the next handler in the chain.
+//   - If the request exceeds the rate limit, it is passed to the denied handler, which responds with
+//     an HTTP 429 (Too Many Requests) by default. See [WithDeniedHandler].
+//   - If the rate limiter encounters an error, the request is handled according to the failure policy,
+//     which responds with an HTTP 500 (Internal Server Error) by default. See [WithFailurePolicy]
+//     and [WithErrorHandler].
+//
+// Requests can be exempted from rate limiting with [WithSkipFunc] or [WithAllowlist], or rejected
+// outright with [WithDenylist]. Denials and errors can be logged with [WithLogger], and hooks can
+// be attached to every decision with [WithHooks]. Requests exceeding the rate limit can be delayed
+// instead of denied with [WithWaitMode], and requests can be counted based on their response with
+// [WithCountIf], or refunded based on it with [WithRefundIf]. Part of the budget can be kept for
+// the most important requests with [WithPriorityReserve], and clients that keep exceeding it can be
+// banned with [WithPenaltyPolicy], or offered a challenge such as a CAPTCHA with
+// [WithChallengeHandler].
+// Long-lived connections, such as Server-Sent Events, can be limited by number rather than by rate
+// with [WithLongLived]. New limits can be tried out without enforcing them with [WithShadowMode],
+// or enforced for a percentage of clients with [WithRollout].
+//
+// If rateLimiter implements [RateLimiterContext], it is called with the request's context.
+// If rateLimiter also implements [AdvancedRateLimiter], rate limit headers are added to every
+// response, in the style selected with [WithHeaderStyle], and the standard Retry-After header can
+// be added to denied responses with [WithRetryAfter], along with backoff hints with
+// [WithBackoffHints] and the headers of allowed responses with [WithDeniedHeaders]. They can be
+// turned off with [WithHeaders].
+//
+// Example usage: http.Handle("/resource", New(myRateLimiter, WithFailurePolicy(FailOpen))(myHandler))
+func New(rateLimiter RateLimiter, opts ...Option) func(http.Handler) http.Handler {
+	return newMiddleware(rateLimiter, newOptions(opts))
+}
+
+// newMiddleware returns the middleware constructor of [New] for the options assembled from its
+// options.
+func newMiddleware(rateLimiter RateLimiter, options options) func(http.Handler) http.Handler {
+	advanced, isAdvanced := rateLimiter.(AdvancedRateLimiter)
+	withHeaders := isAdvanced && options.headers && !options.shadow
+	clock := clockOf(rateLimiter)
+	check := func(ctx context.Context, r *http.Request) (bool, error) {
+		return isAllowed(ctx, rateLimiter, r)
+	}
+	if options.reserve > 0 && !isAdvanced {
+		panic(fmt.Sprintf("cerberus: WithPriorityReserve requires an AdvancedRateLimiter; got %T", rateLimiter))
+	}
+	managed, isManaged := rateLimiter.(ManagedLimiter)
+	if options.penalty != nil && !isManaged {
+		panic(fmt.Sprintf("cerberus: WithPenaltyPolicy requires a ManagedLimiter; got %T", rateLimiter))
+	}
+	if options.challenge != nil {
+		if !isManaged {
+			panic(fmt.Sprintf("cerberus: WithChallengeHandler requires a ManagedLimiter; got %T", rateLimiter))
+		}
+		if options.challenge.Challenge != nil {
+			options.deniedHandler = options.challenge.Challenge
+		}
+	}
+	if options.rollout < rolloutBuckets {
+		options.allowlist = append(options.allowlist, rolloutMatcher(rateLimiter, options.rollout))
+	}
+	deferred, isDeferred := rateLimiter.(DeferredRateLimiter)
+	if options.countIf != nil {
+		if !isDeferred {
+			panic(fmt.Sprintf("cerberus: WithCountIf requires a DeferredRateLimiter; got %T", rateLimiter))
+		}
+		check = deferred.Peek
+	}
+	refundable, isRefundable := rateLimiter.(RefundableRateLimiter)
+	if options.refundIf != nil && !isRefundable {
+		panic(fmt.Sprintf("cerberus: WithRefundIf requires a RefundableRateLimiter; got %T", rateLimiter))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matchAny(options.denylist, r) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if matchAny(options.allowlist, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if options.longLived != nil && matchAny(options.longLived.matchers, r) {
+				options.serveLongLived(w, r, next, rateLimiter)
+				return
+			}
+			if options.challenge != nil {
+				options.verifyChallenge(r, managed)
+			}
+			isAllowed, err := check(r.Context(), r)
+			if !isAllowed && err == nil && options.maxWait > 0 && options.countIf == nil && !options.shadow {
+				isAllowed, err = options.wait(r, rateLimiter)
+			}
+			if err != nil {
+				options.logError(r, rateLimiter, err)
+				options.failed(r, err)
+				if options.shadow {
+					next.ServeHTTP(w, r)
+					return
+				}
+				options.handleError(w, r, next, err)
+				return
+			}
+			var data RateLimitData
+			if withHeaders || isAdvanced && (!isAllowed || options.wantsData(r, isAllowed) || isAllowed && options.reserve > 0) {
+				data = advanced.GetRateLimitData(r)
+			}
+			if isAllowed && options.reserved(r, data) {
+				isAllowed = false
+				if options.countIf == nil {
+					refund(r.Context(), []RateLimiter{rateLimiter}, r)
+				}
+			}
+			if !isAllowed {
+				options.logDenied(r, rateLimiter, data)
+				options.denied(r, data)
+				options.publishDenied(r, rateLimiter, data)
+				if !options.shadow {
+					if options.penalty != nil {
+						options.penalize(r, managed)
+					}
+					if withHeaders {
+						now := clock.Now()
+						options.headerKeys.writeDeniedHeaders(w.Header(), data, options.deniedHeaders, now)
+						options.writeRetryAfter(w.Header(), data, now)
+						options.writeBackoffHints(w.Header(), data)
+					}
+					if isAdvanced {
+						r = r.WithContext(context.WithValue(r.Context(), rateLimitDataKey{}, data))
+					}
+					options.deniedHandler.ServeHTTP(w, r)
+					return
+				}
+			} else {
+				options.allowed(r, data)
+			}
+			if withHeaders {
+				options.headerKeys.writeAllowedHeaders(w.Header(), data, clock.Now())
+			}
+			if options.countIf != nil {
+				options.serveDeferred(w, r, next, deferred)
+				return
+			}
+			if options.refundIf != nil && isAllowed {
+				options.serveRefundable(w, r, next, refundable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test New adds headers for advanced rate limiters
+func TestNewAddsHeadersForAdvancedRateLimiter(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{Limit: 10, Remaining: 9}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(mockLimiter)(handler)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "10" {
+		t.Errorf("expected X-RateLimit-Limit to be 10; got %v", limit)
+	}
+}
+
+// Test New omits headers when they are turned off
+func TestNewWithHeadersDisabled(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			t.Errorf("expected rate limit data not to be requested")
+			return RateLimitData{}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(mockLimiter, WithHeaders(false))(handler)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status OK; got %v", rr.Code)
+	}
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "" {
+		t.Errorf("expected no X-RateLimit-Limit header; got %v", limit)
+	}
+}
@@ -0,0 +1,164 @@
+// Package cerberuscaddy provides a Caddy HTTP handler module applying rate limiting with cerberus
+// rate limiters, so that sites served by Caddy can be rate limited without custom glue.
+//
+// The handler applies the limits of a declarative [cerberus.MiddlewareConfig], loaded from a JSON
+// or YAML file or given inline in the JSON config of Caddy. It is built into Caddy with xcaddy:
+//
+//	xcaddy build --with github.com/mxmlkzdh/cerberus/cerberuscaddy
+//
+// and enabled with the cerberus directive of the Caddyfile, which runs before basic_auth, so that
+// limits also guard authentication:
+//
+//	example.com {
+//		cerberus /etc/caddy/limits.yaml
+//		reverse_proxy localhost:3000
+//	}
+//
+// In the JSON config, the handler is the module http.handlers.cerberus:
+//
+//	{"handler": "cerberus", "config": {"default": {"limit": 100, "window": "1m"}}}
+package cerberuscaddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/mxmlkzdh/cerberus"
+	"go.uber.org/zap/exp/zapslog"
+)
+
+func init() {
+	caddy.RegisterModule(Handler{})
+	httpcaddyfile.RegisterHandlerDirective("cerberus", parseCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("cerberus", httpcaddyfile.Before, "basic_auth")
+}
+
+// Handler is a Caddy HTTP handler applying the limits of a [cerberus.MiddlewareConfig] to the
+// requests it handles, with the middleware of [cerberus.New]. Allowed requests are passed to the
+// next handler, and denied requests are answered with an HTTP 429 (Too Many Requests). Decisions
+// are logged to the logger of the module.
+//
+// Exactly one of ConfigFile and Config must be set. The limits are loaded when the module is
+// provisioned, so a change of the config file takes effect when the Caddy config is reloaded.
+type Handler struct {
+	// ConfigFile is the path of the JSON or YAML file describing the limits. See
+	// [cerberus.LoadConfig].
+	ConfigFile string `json:"config_file,omitempty"`
+
+	// Config describes the limits inline, in the JSON format of [cerberus.MiddlewareConfig].
+	Config json.RawMessage `json:"config,omitempty"`
+
+	handle *cerberus.Handle
+}
+
+// CaddyModule returns the Caddy module information.
+func (Handler) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.cerberus",
+		New: func() caddy.Module { return new(Handler) },
+	}
+}
+
+// Provision creates the middleware enforcing the limits of the handler.
+func (h *Handler) Provision(ctx caddy.Context) error {
+	logger := cerberus.WithLogger(slog.New(zapslog.NewHandler(ctx.Logger().Core(), zapslog.WithName("http.handlers.cerberus"))))
+	switch {
+	case h.ConfigFile != "" && h.Config != nil:
+		return errors.New("config_file and config cannot both be set")
+	case h.ConfigFile != "":
+		handle, err := cerberus.LoadConfig(h.ConfigFile, logger)
+		if err != nil {
+			return err
+		}
+		h.handle = handle
+	case h.Config != nil:
+		var config cerberus.MiddlewareConfig
+		decoder := json.NewDecoder(bytes.NewReader(h.Config))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			return fmt.Errorf("%w: %w", cerberus.ErrInvalidConfig, err)
+		}
+		handle, err := config.Middleware(logger)
+		if err != nil {
+			return err
+		}
+		h.handle = handle
+	default:
+		return errors.New("either config_file or config must be set")
+	}
+	return nil
+}
+
+// ServeHTTP checks r against the limits, and passes it to next if it is allowed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	var err error
+	h.handle.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err = next.ServeHTTP(w, r)
+	})).ServeHTTP(w, r)
+	return err
+}
+
+// Cleanup closes the store of the limits, once the handler is unloaded with its Caddy config.
+func (h *Handler) Cleanup() error {
+	if h.handle == nil {
+		return nil
+	}
+	return h.handle.Close(context.Background())
+}
+
+// UnmarshalCaddyfile sets up the handler from Caddyfile tokens. Syntax:
+//
+//	cerberus [<config_file>] {
+//		config_file <path>
+//	}
+func (h *Handler) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	d.Next() // consume the directive name
+	if d.NextArg() {
+		h.ConfigFile = d.Val()
+	}
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+	for d.NextBlock(0) {
+		switch d.Val() {
+		case "config_file":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			h.ConfigFile = d.Val()
+			if d.NextArg() {
+				return d.ArgErr()
+			}
+		default:
+			return d.Errf("unrecognized subdirective %q", d.Val())
+		}
+	}
+	if h.ConfigFile == "" {
+		return d.Err("missing config file")
+	}
+	return nil
+}
+
+// parseCaddyfile unmarshals the tokens of the cerberus directive into a new [Handler].
+func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	handler := new(Handler)
+	err := handler.UnmarshalCaddyfile(h.Dispenser)
+	return handler, err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Handler)(nil)
+	_ caddy.CleanerUpper          = (*Handler)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Handler)(nil)
+	_ caddyfile.Unmarshaler       = (*Handler)(nil)
+)
@@ -0,0 +1,108 @@
+package cerberuscaddy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// provision provisions h, failing the test on errors.
+func provision(t *testing.T, h *Handler) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := h.Provision(ctx); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+}
+
+// serve sends a request to h, and returns the status code and how many times next was reached.
+func serve(h *Handler, reached *int) int {
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		*reached++
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if err := h.ServeHTTP(rr, r, next); err != nil {
+		return http.StatusInternalServerError
+	}
+	return rr.Code
+}
+
+// Test the handler passes allowed requests to the next handler and denies the others
+func TestHandlerInlineConfig(t *testing.T) {
+	h := &Handler{Config: json.RawMessage(`{"default": {"algorithm": "fixed_window", "limit": 1, "window": "1m"}}`)}
+	provision(t, h)
+	reached := 0
+
+	if code := serve(h, &reached); code != http.StatusNoContent {
+		t.Errorf("expected status %d; got %d", http.StatusNoContent, code)
+	}
+	if code := serve(h, &reached); code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d; got %d", http.StatusTooManyRequests, code)
+	}
+	if reached != 1 {
+		t.Errorf("expected 1 request to reach the next handler; got %d", reached)
+	}
+}
+
+// Test the handler loads its limits from a config file
+func TestHandlerConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	os.WriteFile(path, []byte("default: {algorithm: fixed_window, limit: 1, window: 1m}\n"), 0o600)
+	h := &Handler{ConfigFile: path}
+	provision(t, h)
+	reached := 0
+
+	serve(h, &reached)
+	if code := serve(h, &reached); code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d; got %d", http.StatusTooManyRequests, code)
+	}
+}
+
+// Test invalid configurations are rejected when provisioning
+func TestHandlerInvalid(t *testing.T) {
+	for name, h := range map[string]*Handler{
+		"none":    {},
+		"both":    {ConfigFile: "limits.yaml", Config: json.RawMessage(`{}`)},
+		"unknown": {Config: json.RawMessage(`{"limits": {}}`)},
+	} {
+		ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+		if err := h.Provision(ctx); err == nil {
+			t.Errorf("expected %s config to be rejected", name)
+		}
+		cancel()
+	}
+}
+
+// Test the Caddyfile syntax
+func TestUnmarshalCaddyfile(t *testing.T) {
+	for input, expected := range map[string]string{
+		"cerberus /etc/caddy/limits.yaml":                     "/etc/caddy/limits.yaml",
+		"cerberus {\n\tconfig_file /etc/caddy/limits.json\n}": "/etc/caddy/limits.json",
+	} {
+		var h Handler
+		if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err != nil {
+			t.Errorf("expected no error for %q; got %v", input, err)
+		}
+		if h.ConfigFile != expected {
+			t.Errorf("expected config file %q; got %q", expected, h.ConfigFile)
+		}
+	}
+	for _, input := range []string{"cerberus", "cerberus a b", "cerberus {\n\tunknown\n}"} {
+		var h Handler
+		if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err == nil {
+			t.Errorf("expected %q to be rejected", input)
+		}
+	}
+}
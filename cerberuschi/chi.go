@@ -0,0 +1,49 @@
+// Package cerberuschi applies rate limiting with cerberus rate limiters to chi routers.
+//
+// chi middlewares are plain net/http middlewares, so this package is a thin layer over
+// [cerberus.New] that returns the middleware with the exact type chi expects, and that can rate
+// limit by route pattern:
+//
+//	r := chi.NewRouter()
+//	r.Use(cerberuschi.New(cerberus.NewTokenBucketLimiter(100, 10)))
+//	r.With(cerberuschi.New(loginLimiter)).Post("/login", login)
+package cerberuschi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// New returns a chi middleware that applies rate limiting with the provided
+// [cerberus.RateLimiter]. It is equivalent to [cerberus.New].
+//
+// Example usage: r.Use(New(myRateLimiter, cerberus.WithHeaderStyle(cerberus.HeaderStyleIETF)))
+func New(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) func(http.Handler) http.Handler {
+	return cerberus.New(rateLimiter, opts...)
+}
+
+// KeyByRoutePattern returns a [cerberus.KeyFunc] that combines the chi route pattern matched by
+// the request with the key derived by keyFunc, such as "/users/{id}|192.0.2.1", so that each
+// client gets a separate budget on each route. The route pattern is only known once chi has
+// routed the request, so rate limiters using it must be installed with r.With or inside a route
+// group rather than on the root router. [cerberus.ErrNoKey] is returned if the request has not
+// been routed by chi.
+func KeyByRoutePattern(keyFunc cerberus.KeyFunc) cerberus.KeyFunc {
+	return func(r *http.Request) (string, error) {
+		routeContext := chi.RouteContext(r.Context())
+		if routeContext == nil {
+			return "", cerberus.ErrNoKey
+		}
+		pattern := routeContext.RoutePattern()
+		if pattern == "" {
+			return "", cerberus.ErrNoKey
+		}
+		key, err := keyFunc(r)
+		if err != nil {
+			return "", err
+		}
+		return pattern + "|" + key, nil
+	}
+}
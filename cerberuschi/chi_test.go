@@ -0,0 +1,74 @@
+package cerberuschi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Test the middleware rate limits chi routes
+func TestNew(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(New(cerberus.NewFixedWindowLimiter(1, time.Minute)))
+	r.Get("/api", okHandler)
+
+	var codes []int
+	for range 2 {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+		codes = append(codes, rr.Code)
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 200 then 429; got %v", codes)
+	}
+}
+
+// Test KeyByRoutePattern gives each route pattern its own budget
+func TestKeyByRoutePattern(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(KeyByRoutePattern(cerberus.KeyByIP)))
+	r := chi.NewRouter()
+	r.With(New(limiter)).Get("/users/{id}", okHandler)
+	r.With(New(limiter)).Get("/orders/{id}", okHandler)
+
+	for _, path := range []string{"/users/1", "/orders/1", "/users/2"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		want := http.StatusOK
+		if path == "/users/2" {
+			want = http.StatusTooManyRequests
+		}
+		if rr.Code != want {
+			t.Errorf("expected status %v for %v; got %v", want, path, rr.Code)
+		}
+	}
+}
+
+// Test KeyByRoutePattern fails outside of chi
+func TestKeyByRoutePatternWithoutChi(t *testing.T) {
+	keyFunc := KeyByRoutePattern(cerberus.KeyByIP)
+
+	if _, err := keyFunc(httptest.NewRequest(http.MethodGet, "/", nil)); err != cerberus.ErrNoKey {
+		t.Errorf("expected ErrNoKey; got %v", err)
+	}
+}
+
+// Benchmark serving allowed requests through the middleware
+func BenchmarkNew(b *testing.B) {
+	r := chi.NewRouter()
+	r.Use(New(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour)))
+	r.Get("/api", okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/mxmlkzdh/cerberus/cerberuschi
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/go-chi/chi/v5 v5.1.0
+	github.com/mxmlkzdh/cerberus v0.0.0
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,148 @@
+// Package cerberusdebug exposes the internals of cerberus rate limiters for debugging, so that
+// on-call engineers can see what rate limiting is doing without a metrics pipeline.
+//
+// Rate limiters are instrumented by wrapping them with [Debug.Instrument], and their stores with
+// [Debug.ObserveStore], before installing them. A [Debug] then reports for each of them:
+//   - the number of allowed and denied requests and errors, and the latency of checks;
+//   - the keys of the clients denied the most, and the number of keys the rate limiter tracks,
+//     if it implements [cerberus.InspectableLimiter] and its store can list keys;
+//   - the number and latency of store operations, the number of entries of the store, and the
+//     runs of its janitor, if it is a [cerberus.MemoryStore].
+//
+// A Debug is both an [http.Handler] serving these stats as JSON, and an [expvar.Var] publishing
+// them along with the other variables of the process at /debug/vars:
+//
+//	debug := cerberusdebug.New()
+//	store := debug.ObserveStore("memory", cerberus.NewMemoryStore())
+//	limiter := debug.Instrument("api", cerberus.NewTokenBucketLimiter(100, 10, cerberus.WithStore(store)))
+//	http.Handle("/api/", cerberus.New(limiter)(apiHandler))
+//	http.Handle("/debug/cerberus", debug)
+//	expvar.Publish("cerberus", debug)
+//
+// The stats reveal the keys of clients, such as their IP addresses, so the handler must only be
+// reachable by operators.
+package cerberusdebug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keysTimeout bounds the time spent listing the keys of the rate limiters when the stats are
+// published with [expvar], which provides no context.
+const keysTimeout = time.Second
+
+// Debug collects the stats of the rate limiters and stores instrumented with it.
+type Debug struct {
+	topKeys int
+
+	mu       sync.Mutex
+	limiters []*limiterStats
+	stores   []*storeStats
+}
+
+// Option configures a [Debug].
+type Option func(*Debug)
+
+// WithTopKeys sets how many of the keys denied the most are reported for each rate limiter. The
+// default is 10. It panics if n is not positive.
+func WithTopKeys(n int) Option {
+	if n <= 0 {
+		panic("cerberusdebug: the number of top keys must be positive")
+	}
+	return func(d *Debug) {
+		d.topKeys = n
+	}
+}
+
+// New creates a [Debug] with no rate limiters or stores.
+func New(opts ...Option) *Debug {
+	d := &Debug{topKeys: 10}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Snapshot is the stats of the rate limiters and stores instrumented with a [Debug], in the order
+// they were instrumented in.
+type Snapshot struct {
+	Limiters []LimiterStats `json:"limiters"`
+	Stores   []StoreStats   `json:"stores"`
+}
+
+// Snapshot returns the current stats. ctx bounds the time spent listing the keys of the rate
+// limiters.
+func (d *Debug) Snapshot(ctx context.Context) Snapshot {
+	d.mu.Lock()
+	limiters, stores := d.limiters, d.stores
+	d.mu.Unlock()
+	snapshot := Snapshot{
+		Limiters: make([]LimiterStats, 0, len(limiters)),
+		Stores:   make([]StoreStats, 0, len(stores)),
+	}
+	for _, l := range limiters {
+		snapshot.Limiters = append(snapshot.Limiters, l.snapshot(ctx))
+	}
+	for _, s := range stores {
+		snapshot.Stores = append(snapshot.Stores, s.snapshot())
+	}
+	return snapshot
+}
+
+// String returns the current stats as JSON. It implements [expvar.Var].
+func (d *Debug) String() string {
+	ctx, cancel := context.WithTimeout(context.Background(), keysTimeout)
+	defer cancel()
+	b, err := json.Marshal(d.Snapshot(ctx))
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// ServeHTTP responds with the current stats as JSON.
+func (d *Debug) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(d.Snapshot(r.Context()))
+}
+
+// Latency summarizes the durations of a series of operations.
+type Latency struct {
+	// Mean is the mean duration, in nanoseconds in JSON.
+	Mean time.Duration `json:"mean_ns"`
+	// Max is the longest duration, in nanoseconds in JSON.
+	Max time.Duration `json:"max_ns"`
+}
+
+// latencyRecorder accumulates the durations of operations for a [Latency].
+type latencyRecorder struct {
+	mu    sync.Mutex
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+// record adds the duration of an operation.
+func (r *latencyRecorder) record(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count++
+	r.total += d
+	r.max = max(r.max, d)
+}
+
+// latency returns the summary of the durations recorded so far.
+func (r *latencyRecorder) latency() Latency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count == 0 {
+		return Latency{}
+	}
+	return Latency{Mean: r.total / time.Duration(r.count), Max: r.max}
+}
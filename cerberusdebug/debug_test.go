@@ -0,0 +1,86 @@
+package cerberusdebug
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Test the handler serves the stats of the instrumented rate limiters and stores as JSON
+func TestDebugServeHTTP(t *testing.T) {
+	debug := New()
+	store := debug.ObserveStore("memory", cerberus.NewMemoryStore())
+	limiter := debug.Instrument("api", cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithStore(store)))
+	for range 2 {
+		limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	rr := httptest.NewRecorder()
+
+	debug.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/cerberus", nil))
+	var snapshot Snapshot
+	err := json.NewDecoder(rr.Body).Decode(&snapshot)
+
+	if err != nil {
+		t.Fatalf("expected a JSON response; got %v", err)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected Content-Type application/json; got %q", contentType)
+	}
+	if len(snapshot.Limiters) != 1 || len(snapshot.Stores) != 1 {
+		t.Fatalf("expected 1 limiter and 1 store; got %+v", snapshot)
+	}
+	if l := snapshot.Limiters[0]; l.Name != "api" || l.Allowed != 1 || l.Denied != 1 {
+		t.Errorf("expected 1 allowed and 1 denied request; got %+v", l)
+	}
+	if l := snapshot.Limiters[0]; l.Keys == nil || *l.Keys != 1 {
+		t.Errorf("expected 1 key; got %v", l.Keys)
+	}
+	if s := snapshot.Stores[0]; s.Name != "memory" || s.Operations == 0 || s.Janitor == nil {
+		t.Errorf("expected the store operations and janitor to be reported; got %+v", s)
+	}
+}
+
+// Test the stats can be published with expvar, without publishing them so the test can rerun
+func TestDebugExpvar(t *testing.T) {
+	debug := New()
+	debug.Instrument("api", cerberus.NewTokenBucketLimiter(1, 1))
+	var v expvar.Var = debug
+
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(v.String()), &snapshot); err != nil {
+		t.Fatalf("expected the variable to be JSON; got %v", err)
+	}
+	if len(snapshot.Limiters) != 1 || snapshot.Limiters[0].Name != "api" {
+		t.Errorf("expected the api limiter to be published; got %+v", snapshot)
+	}
+}
+
+// Test the latency recorder reports the mean and max durations
+func TestLatencyRecorder(t *testing.T) {
+	var recorder latencyRecorder
+	if latency := recorder.latency(); latency != (Latency{}) {
+		t.Errorf("expected zero latency without operations; got %+v", latency)
+	}
+
+	recorder.record(time.Millisecond)
+	recorder.record(3 * time.Millisecond)
+
+	if latency := recorder.latency(); latency.Mean != 2*time.Millisecond || latency.Max != 3*time.Millisecond {
+		t.Errorf("expected a mean of 2ms and a max of 3ms; got %+v", latency)
+	}
+}
+
+// Test WithTopKeys panics on a non-positive number
+func TestWithTopKeysPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	WithTopKeys(0)
+}
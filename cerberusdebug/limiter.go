@@ -0,0 +1,127 @@
+package cerberusdebug
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// LimiterStats is the stats of a rate limiter instrumented with [Debug.Instrument].
+type LimiterStats struct {
+	Name    string `json:"name"`
+	Allowed int64  `json:"allowed"`
+	Denied  int64  `json:"denied"`
+	Errors  int64  `json:"errors"`
+	// Keys is the number of keys the rate limiter tracks, or nil if it cannot list them.
+	Keys *int `json:"keys,omitempty"`
+	// TopDenied is the keys denied the most, from the most denied.
+	TopDenied []cerberus.KeyCount `json:"top_denied"`
+	// Latency is the latency of checks.
+	Latency Latency `json:"latency"`
+}
+
+// Instrument wraps rateLimiter so that its decisions are recorded under the given name. The
+// returned rate limiter implements [cerberus.AdvancedRateLimiter] if and only if rateLimiter does,
+// so wrapping it does not change which headers a middleware emits.
+//
+// The keys of denied clients are reported if [cerberus.KeyOf] can tell them, which is the case of
+// the built-in limiters. They are tracked with a [cerberus.TopKeys], so that memory stays bounded
+// however many clients are denied, and their counts are approximate.
+func (d *Debug) Instrument(name string, rateLimiter cerberus.RateLimiter) cerberus.RateLimiter {
+	stats := &limiterStats{
+		name:        name,
+		rateLimiter: rateLimiter,
+		topDenied:   cerberus.NewTopKeys(d.topKeys),
+	}
+	d.mu.Lock()
+	d.limiters = append(d.limiters, stats)
+	d.mu.Unlock()
+	instrumented := &instrumentedLimiter{stats: stats, rateLimiter: rateLimiter}
+	if advanced, ok := rateLimiter.(cerberus.AdvancedRateLimiter); ok {
+		return &instrumentedAdvancedLimiter{instrumentedLimiter: instrumented, advanced: advanced}
+	}
+	return instrumented
+}
+
+// limiterStats accumulates the stats of a rate limiter.
+type limiterStats struct {
+	name        string
+	rateLimiter cerberus.RateLimiter
+
+	allowed   atomic.Int64
+	denied    atomic.Int64
+	errors    atomic.Int64
+	latency   latencyRecorder
+	topDenied *cerberus.TopKeys
+}
+
+// snapshot returns the stats of the rate limiter.
+func (s *limiterStats) snapshot(ctx context.Context) LimiterStats {
+	stats := LimiterStats{
+		Name:      s.name,
+		Allowed:   s.allowed.Load(),
+		Denied:    s.denied.Load(),
+		Errors:    s.errors.Load(),
+		TopDenied: s.topDenied.Top(),
+		Latency:   s.latency.latency(),
+	}
+	if inspectable, ok := s.rateLimiter.(cerberus.InspectableLimiter); ok {
+		if keys, err := inspectable.Keys(ctx); err == nil {
+			count := len(keys)
+			stats.Keys = &count
+		}
+	}
+	return stats
+}
+
+// instrumentedLimiter is a [cerberus.RateLimiterContext] recording the decisions of the rate
+// limiter it wraps.
+type instrumentedLimiter struct {
+	stats       *limiterStats
+	rateLimiter cerberus.RateLimiter
+}
+
+// IsAllowed checks the request against the wrapped rate limiter and records the decision.
+func (l *instrumentedLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the wrapped rate limiter.
+func (l *instrumentedLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	start := time.Now()
+	var isAllowed bool
+	var err error
+	if rateLimiterContext, ok := l.rateLimiter.(cerberus.RateLimiterContext); ok {
+		isAllowed, err = rateLimiterContext.IsAllowedContext(ctx, r)
+	} else {
+		isAllowed, err = l.rateLimiter.IsAllowed(r.WithContext(ctx))
+	}
+	l.stats.latency.record(time.Since(start))
+	switch {
+	case err != nil:
+		l.stats.errors.Add(1)
+	case isAllowed:
+		l.stats.allowed.Add(1)
+	default:
+		l.stats.denied.Add(1)
+		if key, err := cerberus.KeyOf(l.rateLimiter, r); err == nil {
+			l.stats.topDenied.Add(key)
+		}
+	}
+	return isAllowed, err
+}
+
+// instrumentedAdvancedLimiter is an [instrumentedLimiter] wrapping a
+// [cerberus.AdvancedRateLimiter].
+type instrumentedAdvancedLimiter struct {
+	*instrumentedLimiter
+	advanced cerberus.AdvancedRateLimiter
+}
+
+// GetRateLimitData returns the rate limit data reported by the wrapped rate limiter.
+func (l *instrumentedAdvancedLimiter) GetRateLimitData(r *http.Request) cerberus.RateLimitData {
+	return l.advanced.GetRateLimitData(r)
+}
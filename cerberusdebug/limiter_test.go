@@ -0,0 +1,70 @@
+package cerberusdebug
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// errorLimiter is a rate limiter failing every check.
+type errorLimiter struct{}
+
+func (errorLimiter) IsAllowed(*http.Request) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+// requestFrom returns a request from the given remote address.
+func requestFrom(addr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = addr
+	return r
+}
+
+// Test the keys denied the most are reported, from the most denied
+func TestInstrumentTopDenied(t *testing.T) {
+	debug := New(WithTopKeys(2))
+	limiter := debug.Instrument("api", cerberus.NewFixedWindowLimiter(1, time.Minute))
+	for addr, n := range map[string]int{"192.0.2.1:1": 4, "192.0.2.2:1": 3, "192.0.2.3:1": 2} {
+		for range n {
+			limiter.IsAllowed(requestFrom(addr))
+		}
+	}
+
+	stats := debug.Snapshot(context.Background()).Limiters[0]
+
+	want := []cerberus.KeyCount{{Key: "192.0.2.1", Count: 3}, {Key: "192.0.2.2", Count: 2}}
+	if !slices.Equal(stats.TopDenied, want) {
+		t.Errorf("expected %v; got %v", want, stats.TopDenied)
+	}
+	if stats.Allowed != 3 || stats.Denied != 6 {
+		t.Errorf("expected 3 allowed and 6 denied requests; got %d and %d", stats.Allowed, stats.Denied)
+	}
+}
+
+// Test errors are counted and the wrapper only implements AdvancedRateLimiter if the wrapped one does
+func TestInstrumentErrors(t *testing.T) {
+	debug := New()
+	limiter := debug.Instrument("failing", errorLimiter{})
+
+	_, err := limiter.IsAllowed(requestFrom("192.0.2.1:1"))
+	stats := debug.Snapshot(context.Background()).Limiters[0]
+
+	if err == nil {
+		t.Errorf("expected the error of the wrapped rate limiter")
+	}
+	if stats.Errors != 1 || stats.Keys != nil {
+		t.Errorf("expected 1 error and no key count; got %+v", stats)
+	}
+	if _, ok := limiter.(cerberus.AdvancedRateLimiter); ok {
+		t.Errorf("expected the wrapper not to implement AdvancedRateLimiter")
+	}
+	if _, ok := debug.Instrument("api", cerberus.NewTokenBucketLimiter(1, 1)).(cerberus.AdvancedRateLimiter); !ok {
+		t.Errorf("expected the wrapper to implement AdvancedRateLimiter")
+	}
+}
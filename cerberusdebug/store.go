@@ -0,0 +1,144 @@
+package cerberusdebug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// StoreStats is the stats of a store observed with [Debug.ObserveStore].
+type StoreStats struct {
+	Name       string `json:"name"`
+	Operations int64  `json:"operations"`
+	Errors     int64  `json:"errors"`
+	// Latency is the latency of operations.
+	Latency Latency `json:"latency"`
+	// Entries is the number of entries of the store, or nil if it does not report them.
+	Entries *int `json:"entries,omitempty"`
+	// Janitor is the work of the janitor of the store, or nil if it has none.
+	Janitor *JanitorStats `json:"janitor,omitempty"`
+}
+
+// JanitorStats is the work of the janitor removing the expired entries of a
+// [cerberus.MemoryStore].
+type JanitorStats struct {
+	Runs    int64 `json:"runs"`
+	Removed int64 `json:"removed"`
+}
+
+// ObserveStore wraps store so that the latency of its operations is recorded under the given
+// name. Stores with a Len method report their number of entries, and a [cerberus.MemoryStore] the
+// runs of its janitor as well.
+//
+// The returned store lists keys if store implements [cerberus.KeyScanner], so that rate limiters
+// using it can still report the number of keys they track.
+func (d *Debug) ObserveStore(name string, store cerberus.Store) cerberus.Store {
+	stats := &storeStats{name: name, store: store}
+	d.mu.Lock()
+	d.stores = append(d.stores, stats)
+	d.mu.Unlock()
+	return &observedStore{stats: stats, store: store}
+}
+
+// storeStats accumulates the stats of a store.
+type storeStats struct {
+	name  string
+	store cerberus.Store
+
+	operations atomic.Int64
+	errors     atomic.Int64
+	latency    latencyRecorder
+}
+
+// snapshot returns the stats of the store.
+func (s *storeStats) snapshot() StoreStats {
+	stats := StoreStats{
+		Name:       s.name,
+		Operations: s.operations.Load(),
+		Errors:     s.errors.Load(),
+		Latency:    s.latency.latency(),
+	}
+	switch store := s.store.(type) {
+	case *cerberus.MemoryStore:
+		memoryStats := store.Stats()
+		stats.Entries = &memoryStats.Entries
+		stats.Janitor = &JanitorStats{Runs: memoryStats.JanitorRuns, Removed: memoryStats.JanitorRemoved}
+	case interface{ Len() int }:
+		entries := store.Len()
+		stats.Entries = &entries
+	}
+	return stats
+}
+
+// record records an operation that started at start and failed with err, if not nil.
+func (s *storeStats) record(start time.Time, err error) {
+	s.latency.record(time.Since(start))
+	s.operations.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// observedStore is a [cerberus.Store] recording the latency of the operations of the store it
+// wraps.
+type observedStore struct {
+	stats *storeStats
+	store cerberus.Store
+}
+
+func (s *observedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	value, err := s.store.Get(ctx, key)
+	s.stats.record(start, err)
+	return value, err
+}
+
+func (s *observedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	start := time.Now()
+	err := s.store.Set(ctx, key, value, ttl)
+	s.stats.record(start, err)
+	return err
+}
+
+func (s *observedStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	start := time.Now()
+	n, err := s.store.Increment(ctx, key, delta, ttl)
+	s.stats.record(start, err)
+	return n, err
+}
+
+func (s *observedStore) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	swapped, err := s.store.CompareAndSwap(ctx, key, old, new, ttl)
+	s.stats.record(start, err)
+	return swapped, err
+}
+
+func (s *observedStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := s.store.TTL(ctx, key)
+	s.stats.record(start, err)
+	return ttl, err
+}
+
+func (s *observedStore) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.store.Delete(ctx, key)
+	s.stats.record(start, err)
+	return err
+}
+
+// ScanKeys lists the keys of the wrapped store if it implements [cerberus.KeyScanner], and returns
+// an error wrapping [errors.ErrUnsupported] otherwise. Scans are not recorded, as they are not
+// part of checking requests.
+func (s *observedStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	scanner, ok := s.store.(cerberus.KeyScanner)
+	if !ok {
+		return nil, fmt.Errorf("cerberusdebug: store %T cannot list keys: %w", s.store, errors.ErrUnsupported)
+	}
+	return scanner.ScanKeys(ctx, prefix)
+}
@@ -0,0 +1,59 @@
+package cerberusdebug
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// failingStore is a store failing every operation.
+type failingStore struct {
+	cerberus.Store
+}
+
+func (failingStore) Get(context.Context, string) ([]byte, error) {
+	return nil, errors.New("store unavailable")
+}
+
+// Test the operations of an observed store are recorded
+func TestObserveStore(t *testing.T) {
+	debug := New()
+	store := debug.ObserveStore("memory", cerberus.NewMemoryStore())
+	ctx := context.Background()
+
+	store.Set(ctx, "key", []byte("value"), time.Minute)
+	store.Get(ctx, "key")
+	keys, err := store.(cerberus.KeyScanner).ScanKeys(ctx, "")
+	stats := debug.Snapshot(ctx).Stores[0]
+
+	if err != nil || len(keys) != 1 {
+		t.Errorf("expected the keys of the wrapped store; got %v, %v", keys, err)
+	}
+	if stats.Operations != 2 || stats.Errors != 0 {
+		t.Errorf("expected 2 operations without errors; got %+v", stats)
+	}
+	if stats.Entries == nil || *stats.Entries != 1 {
+		t.Errorf("expected 1 entry; got %v", stats.Entries)
+	}
+}
+
+// Test failed operations are counted, and stores without stats report none
+func TestObserveStoreErrors(t *testing.T) {
+	debug := New()
+	store := debug.ObserveStore("failing", failingStore{})
+	ctx := context.Background()
+
+	store.Get(ctx, "key")
+	_, err := store.(cerberus.KeyScanner).ScanKeys(ctx, "")
+	stats := debug.Snapshot(ctx).Stores[0]
+
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported; got %v", err)
+	}
+	if stats.Errors != 1 || stats.Entries != nil || stats.Janitor != nil {
+		t.Errorf("expected 1 error and no entries or janitor; got %+v", stats)
+	}
+}
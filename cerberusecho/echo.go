@@ -0,0 +1,39 @@
+// Package cerberusecho applies rate limiting with cerberus rate limiters to Echo servers.
+//
+// The middleware runs the same middleware as [cerberus.New], so every [cerberus.Option] is
+// supported, and denied and error handlers write to the Echo response:
+//
+//	e := echo.New()
+//	e.Use(cerberusecho.New(cerberus.NewTokenBucketLimiter(100, 10)))
+package cerberusecho
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// New returns an Echo middleware that applies rate limiting with the provided
+// [cerberus.RateLimiter]. It is the Echo equivalent of [cerberus.New].
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, the next handler is called, with any rate
+//     limit headers already set on the response, and its error is returned.
+//   - If the request exceeds the rate limit or the rate limiter encounters an error, the denied or
+//     error handler writes the response and nil is returned.
+//
+// Example usage: e.GET("/resource", myHandler, New(myRateLimiter))
+func New(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) echo.MiddlewareFunc {
+	middleware := cerberus.New(rateLimiter, opts...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cerberusecho
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Test allowed requests reach the handler with rate limit headers
+func TestNewAllowed(t *testing.T) {
+	e := echo.New()
+	e.Use(New(cerberus.NewFixedWindowLimiter(10, time.Minute)))
+	e.GET("/api", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	rr := httptest.NewRecorder()
+
+	e.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Errorf("expected handler response; got %v %q", rr.Code, rr.Body.String())
+	}
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "10" {
+		t.Errorf("expected X-RateLimit-Limit to be 10; got %v", limit)
+	}
+}
+
+// Test denied requests get a 429 without reaching the handler
+func TestNewDenied(t *testing.T) {
+	var reached int
+	e := echo.New()
+	e.Use(New(cerberus.NewFixedWindowLimiter(1, time.Minute)))
+	e.GET("/api", func(c echo.Context) error {
+		reached++
+		return c.String(http.StatusOK, "ok")
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	rr := httptest.NewRecorder()
+
+	e.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", rr.Code)
+	}
+	if reached != 1 {
+		t.Errorf("expected handler to be reached once; got %v", reached)
+	}
+}
+
+// Test handler errors are returned to Echo
+func TestNewReturnsHandlerError(t *testing.T) {
+	handlerErr := errors.New("boom")
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api", nil), httptest.NewRecorder())
+	handler := New(cerberus.NewFixedWindowLimiter(10, time.Minute))(func(c echo.Context) error {
+		return handlerErr
+	})
+
+	if err := handler(c); !errors.Is(err, handlerErr) {
+		t.Errorf("expected handler error; got %v", err)
+	}
+}
+
+// Benchmark serving allowed requests through the middleware
+func BenchmarkNew(b *testing.B) {
+	e := echo.New()
+	e.Use(New(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour)))
+	e.GET("/api", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,198 @@
+// Package cerberusenvoy implements the gRPC services Envoy calls to rate limit requests, backed by
+// cerberus rate limiters, so that cerberus can serve as the rate limit service of Envoy proxies
+// and of service meshes built on Envoy, such as Istio:
+//
+//   - [AuthorizationServer] implements the external authorization service, called by the ext_authz
+//     HTTP filter for every request. It runs the middleware of [cerberus.New], so every
+//     [cerberus.Option] is supported, and rate limit headers are added to responses.
+//   - [RateLimitService] implements the global rate limit service, called by the ratelimit HTTP
+//     filter with the descriptors of each request, such as its remote address or a header.
+//
+// Both are registered on a gRPC server with the functions generated by go-control-plane:
+//
+//	server := grpc.NewServer()
+//	authv3.RegisterAuthorizationServer(server, cerberusenvoy.NewAuthorizationServer(limiter))
+//	ratelimitv3.RegisterRateLimitServiceServer(server, cerberusenvoy.NewRateLimitService(limiter))
+//
+// A [cerberus.Store] shared by the instances of the service, such as a Redis store, makes the
+// limits global to the mesh.
+package cerberusenvoy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+// AuthorizationServer is an Envoy external authorization service, implementing
+// authv3.AuthorizationServer, that lets requests through as long as they do not exceed the rate
+// limit.
+//
+// Rate limiters make their decisions based on an [http.Request]. For each check, the server builds
+// the request Envoy is asking about, from the method, path, host, and headers it sends, with the
+// address of the downstream client as its remote address, so that key functions such as
+// [cerberus.KeyByIP] and route patterns of a [cerberus.PolicyRouter] work unchanged. The ext_authz
+// filter must be configured to send the headers used by key functions.
+//
+// An AuthorizationServer is safe for concurrent use by multiple goroutines.
+type AuthorizationServer struct {
+	middleware func(http.Handler) http.Handler
+}
+
+// NewAuthorizationServer creates a new [AuthorizationServer] checking requests against
+// rateLimiter, with the middleware of [cerberus.New] configured with opts.
+//
+// Behavior:
+//   - If the request is allowed, the check succeeds, and the headers the middleware adds to
+//     allowed responses, such as rate limit headers, are added to the response of the upstream.
+//   - If the request is denied, the check fails with codes.ResourceExhausted, and Envoy answers
+//     the request with the response of the denied handler, an HTTP 429 (Too Many Requests) by
+//     default, including its headers and body.
+//   - If the rate limiter encounters an error, the request is handled according to the failure
+//     policy, and the check fails with codes.Unavailable if the response is an error.
+//
+// Example usage:
+//
+//	limiter := cerberus.NewTokenBucketLimiter(100, 10, cerberus.WithStore(redisStore))
+//	authv3.RegisterAuthorizationServer(server, cerberusenvoy.NewAuthorizationServer(limiter, cerberus.WithHeaderStyle(cerberus.HeaderStyleIETF)))
+func NewAuthorizationServer(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) *AuthorizationServer {
+	return &AuthorizationServer{middleware: cerberus.New(rateLimiter, opts...)}
+}
+
+// Check checks the request described by req against the rate limiter.
+func (s *AuthorizationServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	r, err := newCheckRequest(ctx, req)
+	if err != nil {
+		return deniedResponse(codes.InvalidArgument, http.StatusBadRequest, nil, err.Error()), nil
+	}
+	allowed := false
+	w := &responseRecorder{header: make(http.Header)}
+	s.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		allowed = true
+	})).ServeHTTP(w, r)
+	if allowed {
+		return &authv3.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{
+				ResponseHeadersToAdd: headerOptions(w.header),
+			}},
+		}, nil
+	}
+	code := codes.ResourceExhausted
+	if w.statusCode() >= http.StatusInternalServerError {
+		code = codes.Unavailable
+	}
+	return deniedResponse(code, w.statusCode(), w.header, w.body.String()), nil
+}
+
+// newCheckRequest builds the request described by the attributes of req.
+func newCheckRequest(ctx context.Context, req *authv3.CheckRequest) (*http.Request, error) {
+	attributes := req.GetAttributes()
+	httpRequest := attributes.GetRequest().GetHttp()
+	path := httpRequest.GetPath()
+	if path == "" {
+		path = "/"
+	}
+	method := httpRequest.GetMethod()
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequestWithContext(ctx, method, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.RequestURI = path
+	r.Host = httpRequest.GetHost()
+	for name, value := range httpRequest.GetHeaders() {
+		if !strings.HasPrefix(name, ":") {
+			r.Header.Add(name, value)
+		}
+	}
+	if len(httpRequest.GetHeaders()) == 0 {
+		for _, header := range httpRequest.GetHeaderMap().GetHeaders() {
+			value := header.GetValue()
+			if value == "" {
+				value = string(header.GetRawValue())
+			}
+			if !strings.HasPrefix(header.GetKey(), ":") {
+				r.Header.Add(header.GetKey(), value)
+			}
+		}
+	}
+	if address := attributes.GetSource().GetAddress().GetSocketAddress(); address != nil {
+		r.RemoteAddr = net.JoinHostPort(address.GetAddress(), strconv.FormatUint(uint64(address.GetPortValue()), 10))
+	}
+	return r, nil
+}
+
+// deniedResponse returns the response failing a check with code, with an HTTP response made of
+// statusCode, header, and body.
+func deniedResponse(code codes.Code, statusCode int, header http.Header, body string) *authv3.CheckResponse {
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code), Message: http.StatusText(statusCode)},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+			Status:  &typev3.HttpStatus{Code: typev3.StatusCode(statusCode)},
+			Headers: headerOptions(header),
+			Body:    body,
+		}},
+	}
+}
+
+// headerOptions converts header to the headers of an Envoy response, replacing any header with the
+// same name.
+func headerOptions(header http.Header) []*corev3.HeaderValueOption {
+	var options []*corev3.HeaderValueOption
+	for name, values := range header {
+		for i, value := range values {
+			action := corev3.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD
+			if i == 0 {
+				action = corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD
+			}
+			options = append(options, &corev3.HeaderValueOption{
+				Header:       &corev3.HeaderValue{Key: strings.ToLower(name), Value: value},
+				AppendAction: action,
+			})
+		}
+	}
+	return options
+}
+
+// responseRecorder is the [http.ResponseWriter] recording the response of the middleware to a
+// check.
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   strings.Builder
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// statusCode returns the status code of the response, HTTP 200 (OK) if none was written.
+func (w *responseRecorder) statusCode() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,102 @@
+package cerberusenvoy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/grpc/codes"
+)
+
+// newTestCheckRequest returns the check of a request for path from addr with headers.
+func newTestCheckRequest(addr, path string, headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Source: &authv3.AttributeContext_Peer{Address: &corev3.Address{Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{Address: addr, PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: 1234}},
+		}}},
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Method:  http.MethodGet,
+			Path:    path,
+			Host:    "example.com",
+			Headers: headers,
+		}},
+	}}
+}
+
+// header returns the value of the header named name in options, or "" if there is none.
+func header(options []*corev3.HeaderValueOption, name string) string {
+	for _, option := range options {
+		if option.GetHeader().GetKey() == name {
+			return option.GetHeader().GetValue()
+		}
+	}
+	return ""
+}
+
+// Test requests are allowed with rate limit headers, then denied with the response of the denied
+// handler
+func TestAuthorizationServerCheck(t *testing.T) {
+	server := NewAuthorizationServer(cerberus.NewFixedWindowLimiter(1, time.Minute))
+	req := newTestCheckRequest("192.0.2.1", "/api?page=2", nil)
+
+	resp, err := server.Check(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if code := codes.Code(resp.GetStatus().GetCode()); code != codes.OK {
+		t.Fatalf("expected OK; got %v", code)
+	}
+	if remaining := header(resp.GetOkResponse().GetResponseHeadersToAdd(), "x-ratelimit-remaining"); remaining != "0" {
+		t.Errorf("expected x-ratelimit-remaining 0; got %q", remaining)
+	}
+
+	resp, _ = server.Check(context.Background(), req)
+	if code := codes.Code(resp.GetStatus().GetCode()); code != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted; got %v", code)
+	}
+	denied := resp.GetDeniedResponse()
+	if code := denied.GetStatus().GetCode(); int(code) != http.StatusTooManyRequests {
+		t.Errorf("expected status %d; got %d", http.StatusTooManyRequests, code)
+	}
+	if header(denied.GetHeaders(), "x-ratelimit-retry-after") == "" {
+		t.Errorf("expected an x-ratelimit-retry-after header; got %v", denied.GetHeaders())
+	}
+
+	if resp, _ := server.Check(context.Background(), newTestCheckRequest("192.0.2.2", "/api", nil)); codes.Code(resp.GetStatus().GetCode()) != codes.OK {
+		t.Errorf("expected requests from another client to be allowed; got %v", resp.GetStatus())
+	}
+}
+
+// Test headers and routes of the original request are available to the rate limiter
+func TestAuthorizationServerRequest(t *testing.T) {
+	router := cerberus.NewPolicyRouter()
+	router.Handle("/api/*", cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByHeader("x-api-key"))))
+	server := NewAuthorizationServer(router)
+
+	for _, apiKey := range []string{"alice", "bob"} {
+		resp, _ := server.Check(context.Background(), newTestCheckRequest("192.0.2.1", "/api/items", map[string]string{"x-api-key": apiKey}))
+		if code := codes.Code(resp.GetStatus().GetCode()); code != codes.OK {
+			t.Errorf("expected request with key %v to be allowed; got %v", apiKey, code)
+		}
+	}
+	resp, _ := server.Check(context.Background(), newTestCheckRequest("192.0.2.1", "/api/items", map[string]string{"x-api-key": "alice"}))
+	if code := codes.Code(resp.GetStatus().GetCode()); code != codes.ResourceExhausted {
+		t.Errorf("expected the second request with key alice to be denied; got %v", code)
+	}
+}
+
+// Test rate limiter errors fail the check with codes.Unavailable
+func TestAuthorizationServerError(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByHeader("x-api-key")))
+	resp, err := NewAuthorizationServer(limiter).Check(context.Background(), newTestCheckRequest("192.0.2.1", "/", nil))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if code := codes.Code(resp.GetStatus().GetCode()); code != codes.Unavailable {
+		t.Errorf("expected Unavailable; got %v", code)
+	}
+}
@@ -0,0 +1,23 @@
+module github.com/mxmlkzdh/cerberus/cerberusenvoy
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/envoyproxy/go-control-plane/envoy v1.32.4
+	github.com/mxmlkzdh/cerberus v0.0.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.4
+)
+
+require (
+	github.com/cncf/xds/go v0.0.0-20241223141626-cff3c89139a3 // indirect
+	github.com/envoyproxy/protoc-gen-validate v1.2.1 // indirect
+	github.com/planetscale/vtprotobuf v0.6.1-0.20240319094008-0393e58bdf10 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
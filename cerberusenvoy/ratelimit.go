@@ -0,0 +1,158 @@
+package cerberusenvoy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	ratelimitcommonv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimitService is an Envoy global rate limit service, implementing
+// ratelimitv3.RateLimitServiceServer, that checks each descriptor Envoy sends against a rate
+// limiter.
+//
+// Rate limiters make their decisions based on an [http.Request]. For each descriptor, the service
+// builds a request for the path "/" followed by the domain of the call, carrying the entries of the
+// descriptor as headers, such as "remote_address" or "generic_key", and the value of the
+// "remote_address" entry, if any, as its remote address. [KeyByDescriptor] keys requests by the
+// whole descriptor, [cerberus.KeyByHeader] by one of its entries, and [cerberus.KeyByIP] by the
+// address of the client, while the route patterns of a [cerberus.PolicyRouter] select limits by
+// domain:
+//
+//	router := cerberus.NewPolicyRouter()
+//	router.Handle("/edge", cerberus.NewGCRALimiter(time.Second/10, time.Second, cerberus.WithKeyFunc(cerberusenvoy.KeyByDescriptor)))
+//	ratelimitv3.RegisterRateLimitServiceServer(server, cerberusenvoy.NewRateLimitService(router))
+//
+// A RateLimitService is safe for concurrent use by multiple goroutines.
+type RateLimitService struct {
+	rateLimiter cerberus.RateLimiter
+}
+
+// NewRateLimitService creates a new [RateLimitService] checking descriptors against rateLimiter.
+//
+// Behavior:
+//   - Every descriptor of a call is checked, and the call is over the limit if any descriptor is.
+//   - If the rate limiter implements [cerberus.AdvancedRateLimiter], the status of each descriptor
+//     reports the limit, the remaining requests, and, when over the limit, the time until the
+//     client may retry, from which Envoy can add rate limit headers to responses.
+//   - The number of hits of a descriptor, set by Envoy with hits_addend, is available to the
+//     rate limiter through [CostByHitsAddend].
+//   - If the rate limiter encounters an error, the call fails with codes.Unavailable, and Envoy
+//     lets the request through or denies it according to the failure_mode_deny setting of the
+//     filter.
+func NewRateLimitService(rateLimiter cerberus.RateLimiter) *RateLimitService {
+	return &RateLimitService{rateLimiter: rateLimiter}
+}
+
+// ShouldRateLimit checks the descriptors of req against the rate limiter.
+func (s *RateLimitService) ShouldRateLimit(ctx context.Context, req *ratelimitv3.RateLimitRequest) (*ratelimitv3.RateLimitResponse, error) {
+	resp := &ratelimitv3.RateLimitResponse{OverallCode: ratelimitv3.RateLimitResponse_OK}
+	for _, descriptor := range req.GetDescriptors() {
+		hits := uint64(req.GetHitsAddend())
+		if addend := descriptor.GetHitsAddend(); addend != nil {
+			hits = addend.GetValue()
+		}
+		if hits == 0 {
+			hits = 1
+		}
+		r := newDescriptorRequest(context.WithValue(ctx, hitsAddendKey{}, hits), req.GetDomain(), descriptor)
+		isAllowed, err := cerberus.Wait(r, s.rateLimiter, 0)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "rate limiter error: %v", err)
+		}
+		descriptorStatus := &ratelimitv3.RateLimitResponse_DescriptorStatus{Code: ratelimitv3.RateLimitResponse_OK}
+		if !isAllowed {
+			descriptorStatus.Code = ratelimitv3.RateLimitResponse_OVER_LIMIT
+			resp.OverallCode = ratelimitv3.RateLimitResponse_OVER_LIMIT
+		}
+		if advanced, ok := s.rateLimiter.(cerberus.AdvancedRateLimiter); ok {
+			data := advanced.GetRateLimitData(r)
+			descriptorStatus.CurrentLimit = currentLimit(data)
+			descriptorStatus.LimitRemaining = uint32(max(data.Remaining, 0))
+			if !isAllowed && data.RetryAfter > 0 {
+				descriptorStatus.DurationUntilReset = durationpb.New(data.RetryAfter)
+			}
+		}
+		resp.Statuses = append(resp.Statuses, descriptorStatus)
+	}
+	return resp, nil
+}
+
+// hitsAddendKey is the context key of the number of hits of the descriptor checked.
+type hitsAddendKey struct{}
+
+// CostByHitsAddend is a [cerberus.CostFunc] charging the requests of a [RateLimitService] the
+// number of hits Envoy set for their descriptor, such as the number of bytes of a response, and
+// other requests a cost of 1.
+//
+// Example usage: cerberus.NewTokenBucketLimiter(1000, 100, cerberus.WithCostFunc(cerberusenvoy.CostByHitsAddend))
+func CostByHitsAddend(r *http.Request) int {
+	if hits, ok := r.Context().Value(hitsAddendKey{}).(uint64); ok {
+		return int(min(hits, uint64(1<<31-1)))
+	}
+	return 1
+}
+
+// KeyByDescriptor is a [cerberus.KeyFunc] keying the requests of a [RateLimitService] by their
+// domain and all the entries of their descriptor, such as "edge|remote_address=192.0.2.1", so that
+// each distinct descriptor has its own limit.
+func KeyByDescriptor(r *http.Request) (string, error) {
+	entries, ok := r.Context().Value(descriptorKey{}).([]*ratelimitcommonv3.RateLimitDescriptor_Entry)
+	if !ok {
+		return "", cerberus.ErrNoKey
+	}
+	var key strings.Builder
+	key.WriteString(strings.TrimPrefix(r.URL.Path, "/"))
+	for _, entry := range entries {
+		key.WriteString("|")
+		key.WriteString(entry.GetKey())
+		key.WriteString("=")
+		key.WriteString(entry.GetValue())
+	}
+	return key.String(), nil
+}
+
+// descriptorKey is the context key of the entries of the descriptor checked.
+type descriptorKey struct{}
+
+// newDescriptorRequest builds the request describing descriptor of domain, carrying ctx.
+func newDescriptorRequest(ctx context.Context, domain string, descriptor *ratelimitcommonv3.RateLimitDescriptor) *http.Request {
+	entries := descriptor.GetEntries()
+	ctx = context.WithValue(ctx, descriptorKey{}, entries)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/"+domain, nil)
+	if err != nil {
+		r, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	}
+	r.RequestURI = r.URL.Path
+	for _, entry := range entries {
+		r.Header.Add(entry.GetKey(), entry.GetValue())
+		if entry.GetKey() == "remote_address" {
+			r.RemoteAddr = entry.GetValue()
+		}
+	}
+	return r
+}
+
+// currentLimit returns the limit described by data in the form of Envoy, or nil if its window is
+// not a unit Envoy supports.
+func currentLimit(data cerberus.RateLimitData) *ratelimitv3.RateLimitResponse_RateLimit {
+	units := map[time.Duration]ratelimitv3.RateLimitResponse_RateLimit_Unit{
+		time.Second:        ratelimitv3.RateLimitResponse_RateLimit_SECOND,
+		time.Minute:        ratelimitv3.RateLimitResponse_RateLimit_MINUTE,
+		time.Hour:          ratelimitv3.RateLimitResponse_RateLimit_HOUR,
+		24 * time.Hour:     ratelimitv3.RateLimitResponse_RateLimit_DAY,
+		7 * 24 * time.Hour: ratelimitv3.RateLimitResponse_RateLimit_WEEK,
+	}
+	unit, ok := units[data.Window]
+	if !ok || data.Limit <= 0 {
+		return nil
+	}
+	return &ratelimitv3.RateLimitResponse_RateLimit{RequestsPerUnit: uint32(data.Limit), Unit: unit}
+}
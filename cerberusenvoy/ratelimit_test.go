@@ -0,0 +1,110 @@
+package cerberusenvoy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	ratelimitcommonv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type stubLimiter struct {
+	isAllowed func(*http.Request) (bool, error)
+}
+
+func (l *stubLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.isAllowed(r)
+}
+
+// newDescriptor returns a descriptor made of the entries in pairs of keys and values.
+func newDescriptor(pairs ...string) *ratelimitcommonv3.RateLimitDescriptor {
+	descriptor := &ratelimitcommonv3.RateLimitDescriptor{}
+	for i := 0; i < len(pairs); i += 2 {
+		descriptor.Entries = append(descriptor.Entries, &ratelimitcommonv3.RateLimitDescriptor_Entry{Key: pairs[i], Value: pairs[i+1]})
+	}
+	return descriptor
+}
+
+// Test calls are over the limit when any of their descriptors is
+func TestRateLimitServiceShouldRateLimit(t *testing.T) {
+	service := NewRateLimitService(cerberus.NewFixedWindowLimiter(2, time.Minute, cerberus.WithKeyFunc(KeyByDescriptor)))
+	req := &ratelimitv3.RateLimitRequest{Domain: "edge", Descriptors: []*ratelimitcommonv3.RateLimitDescriptor{
+		newDescriptor("remote_address", "192.0.2.1"),
+		newDescriptor("generic_key", "checkout"),
+	}}
+	service.ShouldRateLimit(context.Background(), &ratelimitv3.RateLimitRequest{Domain: "edge", Descriptors: req.Descriptors[1:]})
+
+	resp, err := service.ShouldRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if resp.GetOverallCode() != ratelimitv3.RateLimitResponse_OK {
+		t.Fatalf("expected OK; got %v", resp.GetOverallCode())
+	}
+	resp, _ = service.ShouldRateLimit(context.Background(), req)
+	if resp.GetOverallCode() != ratelimitv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT; got %v", resp.GetOverallCode())
+	}
+	statuses := resp.GetStatuses()
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 descriptor statuses; got %d", len(statuses))
+	}
+	if statuses[0].GetCode() != ratelimitv3.RateLimitResponse_OK {
+		t.Errorf("expected the first descriptor to be under the limit; got %v", statuses[0].GetCode())
+	}
+	over := statuses[1]
+	if over.GetCode() != ratelimitv3.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("expected the second descriptor to be over the limit; got %v", over.GetCode())
+	}
+	if limit := over.GetCurrentLimit(); limit.GetRequestsPerUnit() != 2 || limit.GetUnit() != ratelimitv3.RateLimitResponse_RateLimit_MINUTE {
+		t.Errorf("expected a limit of 2 per minute; got %v", limit)
+	}
+	if over.GetDurationUntilReset().AsDuration() <= 0 {
+		t.Errorf("expected a positive duration until reset; got %v", over.GetDurationUntilReset())
+	}
+}
+
+// Test descriptor entries are available as headers and the remote address, and hits as the cost
+func TestRateLimitServiceRequest(t *testing.T) {
+	var key string
+	var cost int
+	limiter := &stubLimiter{isAllowed: func(r *http.Request) (bool, error) {
+		key, _ = cerberus.KeyByIP(r)
+		cost = CostByHitsAddend(r)
+		if r.URL.Path != "/edge" || r.Header.Get("path") != "/api" {
+			t.Errorf("expected the domain as path and the entries as headers; got %v, %v", r.URL.Path, r.Header)
+		}
+		return true, nil
+	}}
+	req := &ratelimitv3.RateLimitRequest{Domain: "edge", HitsAddend: 5, Descriptors: []*ratelimitcommonv3.RateLimitDescriptor{
+		newDescriptor("remote_address", "192.0.2.1", "path", "/api"),
+	}}
+
+	NewRateLimitService(limiter).ShouldRateLimit(context.Background(), req)
+
+	if key != "192.0.2.1" {
+		t.Errorf("expected the remote address as key; got %q", key)
+	}
+	if cost != 5 {
+		t.Errorf("expected a cost of 5; got %d", cost)
+	}
+}
+
+// Test rate limiter errors fail the call with codes.Unavailable
+func TestRateLimitServiceError(t *testing.T) {
+	limiter := &stubLimiter{isAllowed: func(*http.Request) (bool, error) {
+		return false, errors.New("store unavailable")
+	}}
+	req := &ratelimitv3.RateLimitRequest{Domain: "edge", Descriptors: []*ratelimitcommonv3.RateLimitDescriptor{newDescriptor("generic_key", "a")}}
+
+	_, err := NewRateLimitService(limiter).ShouldRateLimit(context.Background(), req)
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("expected Unavailable; got %v", code)
+	}
+}
@@ -0,0 +1,46 @@
+// Package cerberusfiber applies rate limiting with cerberus rate limiters to Fiber apps.
+//
+// Fiber is built on fasthttp, so the middleware is based on the [fasthttpadapter] package and
+// supports every [cerberus.Option]:
+//
+//	app := fiber.New()
+//	app.Use(cerberusfiber.New(cerberus.NewTokenBucketLimiter(100, 10)))
+package cerberusfiber
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/mxmlkzdh/cerberus/fasthttpadapter"
+	"github.com/valyala/fasthttp"
+)
+
+// New returns a Fiber middleware that applies rate limiting with the provided
+// [cerberus.RateLimiter]. It is the Fiber equivalent of [cerberus.New].
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, the next handler is called, with any rate
+//     limit headers already set on the response, and its error is returned.
+//   - If the request exceeds the rate limit or the rate limiter encounters an error, the denied or
+//     error handler writes the response and nil is returned.
+//
+// Example usage: app.Get("/resource", New(myRateLimiter), myHandler)
+func New(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) fiber.Handler {
+	middleware := fasthttpadapter.New(rateLimiter, opts...)(markAllowed)
+	return func(c *fiber.Ctx) error {
+		middleware(c.Context())
+		if c.Context().UserValue(allowedKey{}) == nil {
+			return nil
+		}
+		c.Context().RemoveUserValue(allowedKey{})
+		return c.Next()
+	}
+}
+
+// allowedKey is the user value key recording that the middleware forwarded the request.
+type allowedKey struct{}
+
+// markAllowed is the request handler wrapped by the middleware. It records that the request was
+// forwarded to it.
+func markAllowed(ctx *fasthttp.RequestCtx) {
+	ctx.SetUserValue(allowedKey{}, true)
+}
@@ -0,0 +1,72 @@
+package cerberusfiber
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/valyala/fasthttp"
+)
+
+func newApp(rateLimiter cerberus.RateLimiter, reached *int) *fiber.App {
+	app := fiber.New()
+	app.Use(New(rateLimiter))
+	app.Get("/api", func(c *fiber.Ctx) error {
+		*reached++
+		return c.SendString("ok")
+	})
+	return app
+}
+
+// Test allowed requests reach the handler with rate limit headers
+func TestNewAllowed(t *testing.T) {
+	var reached int
+	app := newApp(cerberus.NewFixedWindowLimiter(10, time.Minute), &reached)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api", nil))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || reached != 1 {
+		t.Errorf("expected handler to respond; got %v after %v calls", resp.StatusCode, reached)
+	}
+	if limit := resp.Header.Get("X-RateLimit-Limit"); limit != "10" {
+		t.Errorf("expected X-RateLimit-Limit to be 10; got %v", limit)
+	}
+}
+
+// Test denied requests get a 429 without reaching the handler
+func TestNewDenied(t *testing.T) {
+	var reached int
+	app := newApp(cerberus.NewFixedWindowLimiter(1, time.Minute), &reached)
+	app.Test(httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api", nil))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", resp.StatusCode)
+	}
+	if reached != 1 {
+		t.Errorf("expected handler to be reached once; got %v", reached)
+	}
+}
+
+// Benchmark serving allowed requests through the middleware
+func BenchmarkNew(b *testing.B) {
+	var reached int
+	handler := newApp(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour), &reached).Handler()
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/api")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler(&ctx)
+		ctx.Response.Reset()
+	}
+}
@@ -0,0 +1,28 @@
+module github.com/mxmlkzdh/cerberus/cerberusfiber
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+replace github.com/mxmlkzdh/cerberus/fasthttpadapter => ../fasthttpadapter
+
+require (
+	github.com/gofiber/fiber/v2 v2.52.5
+	github.com/mxmlkzdh/cerberus v0.0.0
+	github.com/mxmlkzdh/cerberus/fasthttpadapter v0.0.0
+	github.com/valyala/fasthttp v1.58.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/google/uuid v1.5.0 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/tcplisten v1.0.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
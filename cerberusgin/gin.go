@@ -0,0 +1,40 @@
+// Package cerberusgin applies rate limiting with cerberus rate limiters to Gin routers.
+//
+// The middleware runs the same middleware as [cerberus.New], so every [cerberus.Option] is
+// supported, and denied and error handlers write to the Gin response:
+//
+//	router := gin.Default()
+//	router.Use(cerberusgin.New(cerberus.NewTokenBucketLimiter(100, 10)))
+package cerberusgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// New returns a Gin middleware that applies rate limiting with the provided
+// [cerberus.RateLimiter]. It is the Gin equivalent of [cerberus.New].
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, the remaining handlers of the chain are run,
+//     with any rate limit headers already set on the response.
+//   - If the request exceeds the rate limit or the rate limiter encounters an error, the chain is
+//     aborted after the denied or error handler has written the response.
+//
+// Example usage: router.GET("/resource", New(myRateLimiter), myHandler)
+func New(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) gin.HandlerFunc {
+	middleware := cerberus.New(rateLimiter, opts...)
+	return func(c *gin.Context) {
+		allowed := false
+		middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !allowed {
+			c.Abort()
+		}
+	}
+}
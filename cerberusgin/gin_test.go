@@ -0,0 +1,66 @@
+package cerberusgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+func newRouter(rateLimiter cerberus.RateLimiter, reached *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(New(rateLimiter))
+	router.GET("/api", func(c *gin.Context) {
+		*reached++
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+// Test allowed requests reach the handler with rate limit headers
+func TestNewAllowed(t *testing.T) {
+	var reached int
+	router := newRouter(cerberus.NewFixedWindowLimiter(10, time.Minute), &reached)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusOK || reached != 1 {
+		t.Errorf("expected handler to respond; got %v after %v calls", rr.Code, reached)
+	}
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "10" {
+		t.Errorf("expected X-RateLimit-Limit to be 10; got %v", limit)
+	}
+}
+
+// Test denied requests abort the chain with a 429
+func TestNewDenied(t *testing.T) {
+	var reached int
+	router := newRouter(cerberus.NewFixedWindowLimiter(1, time.Minute), &reached)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", rr.Code)
+	}
+	if reached != 1 {
+		t.Errorf("expected handler to be reached once; got %v", reached)
+	}
+}
+
+// Benchmark serving allowed requests through the middleware
+func BenchmarkNew(b *testing.B) {
+	var reached int
+	router := newRouter(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour), &reached)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/mxmlkzdh/cerberus/cerberusgraphql
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/mxmlkzdh/cerberus v0.0.0
+	github.com/vektah/gqlparser/v2 v2.5.58
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
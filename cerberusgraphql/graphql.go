@@ -0,0 +1,327 @@
+// Package cerberusgraphql weighs GraphQL requests by the complexity of their operations, so that
+// cerberus rate limiters charge clients for the work they ask for rather than for the number of
+// requests they send: a single GraphQL request may fetch one field or a million.
+//
+// The cost of requests is computed by a [CostFunc] installed in a limiter with
+// [cerberus.WithCostFunc]:
+//
+//	costs := cerberusgraphql.CostFunc(cerberusgraphql.ComplexityAnalyzer{
+//		FieldCosts: map[string]int{"search": 10},
+//	})
+//	limiter := cerberus.NewTokenBucketLimiter(1000, 100, cerberus.WithCostFunc(costs))
+//	http.Handle("/graphql", cerberus.New(limiter)(graphqlHandler))
+//
+// The body of requests is read to compute their cost, and restored for the GraphQL handler.
+package cerberusgraphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// defaultMaxBodySize is the size of the largest body whose cost is computed by default.
+const defaultMaxBodySize = 1 << 20
+
+// Request is a GraphQL request, as sent by clients in the body of POST requests or in the query
+// parameters of GET requests.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Analyzer computes the complexity of GraphQL requests, which is the cost they are charged.
+type Analyzer interface {
+	// Complexity returns the complexity of the operation of req. It returns an error if req is
+	// not a valid GraphQL request.
+	Complexity(req Request) (int, error)
+}
+
+// AnalyzerFunc is an [Analyzer] implemented by a function, for example one calling the complexity
+// analysis of a GraphQL server library.
+type AnalyzerFunc func(req Request) (int, error)
+
+// Complexity calls f(req).
+func (f AnalyzerFunc) Complexity(req Request) (int, error) {
+	return f(req)
+}
+
+// Option configures the [cerberus.CostFunc] returned by [CostFunc].
+type Option func(*config)
+
+type config struct {
+	maxBodySize int64
+	invalidCost int
+}
+
+// WithMaxBodySize sets the size, in bytes, of the largest body whose cost is computed. Larger
+// bodies cost the invalid cost, and are left for the GraphQL handler to reject. The default is
+// 1 MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(c *config) {
+		c.maxBodySize = n
+	}
+}
+
+// WithInvalidCost sets the cost of requests that carry no valid GraphQL operation, such as
+// requests with a malformed body or query, which the GraphQL handler rejects without executing
+// them. The default is one.
+func WithInvalidCost(cost int) Option {
+	return func(c *config) {
+		c.invalidCost = cost
+	}
+}
+
+// CostFunc returns a [cerberus.CostFunc] costing GraphQL requests the complexity computed by
+// analyzer.
+//
+// Behavior:
+//   - GraphQL requests are read from the JSON body of POST requests, from the body of POST
+//     requests of type application/graphql, and from the query, operationName, and variables
+//     query parameters of GET requests.
+//   - Batched requests, sent as a JSON array, cost the sum of the complexities of their
+//     operations.
+//   - Requests cost at least one, even if their complexity is zero, so that no request escapes
+//     rate limiting.
+//   - The cost of a request is computed once, even if the rate limiter asks for it several times.
+func CostFunc(analyzer Analyzer, opts ...Option) cerberus.CostFunc {
+	c := &config{maxBodySize: defaultMaxBodySize, invalidCost: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return func(r *http.Request) int {
+		if body, ok := r.Body.(*analyzedBody); ok {
+			return body.cost
+		}
+		cost := c.cost(analyzer, r)
+		if body, ok := r.Body.(*analyzedBody); ok {
+			body.cost = cost
+		}
+		return cost
+	}
+}
+
+// cost returns the cost of r according to analyzer.
+func (c *config) cost(analyzer Analyzer, r *http.Request) int {
+	requests, err := c.readRequests(r)
+	if err != nil {
+		return c.invalidCost
+	}
+	cost := 0
+	for _, req := range requests {
+		complexity, err := analyzer.Complexity(req)
+		if err != nil {
+			return c.invalidCost
+		}
+		cost = saturatingAdd(cost, max(complexity, 0))
+	}
+	return max(cost, 1)
+}
+
+// analyzedBody is the body of a request whose cost was computed, restored for the handler.
+type analyzedBody struct {
+	io.Reader
+	io.Closer
+	cost int
+}
+
+// readRequests returns the GraphQL requests carried by r, restoring its body.
+func (c *config) readRequests(r *http.Request) ([]Request, error) {
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req := Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, err
+			}
+		}
+		return []Request{req}, nil
+	}
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return nil, errors.New("cerberusgraphql: no GraphQL request")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, c.maxBodySize+1))
+	r.Body = &analyzedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.maxBodySize {
+		return nil, fmt.Errorf("cerberusgraphql: body larger than %d bytes", c.maxBodySize)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/graphql" {
+		return []Request{{Query: string(body), OperationName: r.URL.Query().Get("operationName")}}, nil
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var requests []Request
+		err := json.Unmarshal(body, &requests)
+		return requests, err
+	}
+	var req Request
+	err = json.Unmarshal(body, &req)
+	return []Request{req}, err
+}
+
+// ComplexityAnalyzer is an [Analyzer] estimating the complexity of operations from their
+// selections alone, without a schema.
+//
+// Behavior:
+//   - Each field costs the cost set in FieldCosts, or one, plus the complexity of its selections.
+//     Meta fields, such as __typename, cost nothing.
+//   - The complexity of the selections of a field with a pagination argument, such as
+//     users(first: 100), is multiplied by the value of the argument, since they are resolved
+//     once per item of the page. Variables are resolved from the request.
+//   - Fragments cost as much as if their selections were inlined. Inline fragments on different
+//     types are all counted, which overestimates the complexity of queries on unions and
+//     interfaces.
+//
+// The zero ComplexityAnalyzer costs every field one, with the pagination arguments first, last,
+// and limit.
+type ComplexityAnalyzer struct {
+	// FieldCosts are the costs of fields by name, such as "search", for fields that are more
+	// expensive to resolve than others, or free, with a cost of zero. Fields that are not listed
+	// cost one.
+	FieldCosts map[string]int
+
+	// PaginationArguments are the names of the arguments holding the size of the lists returned
+	// by fields. The default is first, last, and limit.
+	PaginationArguments []string
+}
+
+// Complexity returns the complexity of the operation of req, the one named OperationName or the
+// only one of the document. It returns an error if the query is malformed, or if the operation is
+// missing or ambiguous.
+func (a ComplexityAnalyzer) Complexity(req Request) (int, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: req.Query})
+	if err != nil {
+		return 0, err
+	}
+	operation := doc.Operations.ForName(req.OperationName)
+	if operation == nil {
+		return 0, fmt.Errorf("cerberusgraphql: unknown operation %q", req.OperationName)
+	}
+	variables := make(map[string]any, len(req.Variables)+len(operation.VariableDefinitions))
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			variables[definition.Variable], _ = definition.DefaultValue.Value(nil)
+		}
+	}
+	for name, value := range req.Variables {
+		variables[name] = value
+	}
+	w := walker{analyzer: a, fragments: doc.Fragments, variables: variables, visiting: map[string]bool{}}
+	return w.selectionSet(operation.SelectionSet)
+}
+
+// walker computes the complexity of the selections of an operation.
+type walker struct {
+	analyzer  ComplexityAnalyzer
+	fragments ast.FragmentDefinitionList
+	variables map[string]any
+
+	// visiting holds the fragments being walked, to detect cycles.
+	visiting map[string]bool
+}
+
+// selectionSet returns the complexity of selections.
+func (w walker) selectionSet(selections ast.SelectionSet) (int, error) {
+	complexity := 0
+	for _, selection := range selections {
+		var cost int
+		var err error
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost, err = w.field(selection)
+		case *ast.InlineFragment:
+			cost, err = w.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			fragment := w.fragments.ForName(selection.Name)
+			if fragment == nil {
+				return 0, fmt.Errorf("cerberusgraphql: unknown fragment %q", selection.Name)
+			}
+			if w.visiting[selection.Name] {
+				return 0, fmt.Errorf("cerberusgraphql: fragment %q spreads itself", selection.Name)
+			}
+			w.visiting[selection.Name] = true
+			cost, err = w.selectionSet(fragment.SelectionSet)
+			delete(w.visiting, selection.Name)
+		}
+		if err != nil {
+			return 0, err
+		}
+		complexity = saturatingAdd(complexity, cost)
+	}
+	return complexity, nil
+}
+
+// field returns the complexity of field and its selections.
+func (w walker) field(field *ast.Field) (int, error) {
+	if strings.HasPrefix(field.Name, "__") {
+		return 0, nil
+	}
+	cost, ok := w.analyzer.FieldCosts[field.Name]
+	if !ok {
+		cost = 1
+	}
+	cost = max(cost, 0)
+	if len(field.SelectionSet) == 0 {
+		return cost, nil
+	}
+	complexity, err := w.selectionSet(field.SelectionSet)
+	if err != nil {
+		return 0, err
+	}
+	return saturatingAdd(cost, saturatingMul(complexity, w.pageSize(field))), nil
+}
+
+// pageSize returns the value of the pagination argument of field, or one if it has none.
+func (w walker) pageSize(field *ast.Field) int {
+	names := w.analyzer.PaginationArguments
+	if names == nil {
+		names = []string{"first", "last", "limit"}
+	}
+	for _, name := range names {
+		argument := field.Arguments.ForName(name)
+		if argument == nil {
+			continue
+		}
+		value, err := argument.Value.Value(w.variables)
+		if err != nil {
+			continue
+		}
+		switch value := value.(type) {
+		case int64:
+			return int(max(min(value, math.MaxInt32), 1))
+		case float64:
+			return int(max(min(value, math.MaxInt32), 1))
+		}
+	}
+	return 1
+}
+
+// saturatingAdd returns a + b, or the largest int if the sum overflows.
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// saturatingMul returns a * b, for non-negative a and positive b, or the largest int if the
+// product overflows.
+func saturatingMul(a, b int) int {
+	if a > math.MaxInt/b {
+		return math.MaxInt
+	}
+	return a * b
+}
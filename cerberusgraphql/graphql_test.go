@@ -0,0 +1,155 @@
+package cerberusgraphql
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// newPost returns a POST request to /graphql with the given body.
+func newPost(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// Test the analyzer counts fields, weighted by pagination arguments and field costs
+func TestComplexityAnalyzer(t *testing.T) {
+	analyzer := ComplexityAnalyzer{FieldCosts: map[string]int{"search": 10}}
+	for name, test := range map[string]struct {
+		req  Request
+		want int
+	}{
+		"flat":       {Request{Query: `{ me { id name } }`}, 3},
+		"typename":   {Request{Query: `{ me { __typename id } }`}, 2},
+		"pagination": {Request{Query: `{ users(first: 10) { id name } }`}, 21},
+		"nested": {Request{Query: `{ users(first: 10) { friends(last: 5) { id } } }`},
+			1 + 10*(1+5*1)},
+		"variable": {Request{Query: `query($n: Int) { users(first: $n) { id } }`, Variables: map[string]any{"n": 50.0}},
+			51},
+		"default variable": {Request{Query: `query($n: Int = 20) { users(limit: $n) { id } }`}, 21},
+		"field cost":       {Request{Query: `{ search(text: "a") { id } }`}, 11},
+		"fragment": {Request{Query: `{ me { ...user } } fragment user on User { id name }`},
+			3},
+		"inline fragment": {Request{Query: `{ node(id: 1) { ... on User { name } ... on Post { title } } }`},
+			3},
+		"named operation": {Request{Query: `query A { a } query B { b { c } }`, OperationName: "B"}, 2},
+	} {
+		got, err := analyzer.Complexity(test.req)
+
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected a complexity of %d; got %d, %v", name, test.want, got, err)
+		}
+	}
+}
+
+// Test the analyzer rejects malformed queries and ambiguous operations
+func TestComplexityAnalyzerErrors(t *testing.T) {
+	for name, req := range map[string]Request{
+		"malformed":          {Query: `{ me { id }`},
+		"ambiguous":          {Query: `query A { a } query B { b }`},
+		"unknown operation":  {Query: `query A { a }`, OperationName: "B"},
+		"unknown fragment":   {Query: `{ ...missing }`},
+		"recursive fragment": {Query: `{ ...a } fragment a on Query { b { ...a } }`},
+	} {
+		if _, err := (ComplexityAnalyzer{}).Complexity(req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Test the cost is read from POST, batched, application/graphql, and GET requests
+func TestCostFunc(t *testing.T) {
+	costs := CostFunc(ComplexityAnalyzer{})
+	graphql := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{ users(first: 5) { id } }`))
+	graphql.Header.Set("Content-Type", "application/graphql")
+	get := httptest.NewRequest(http.MethodGet, "/graphql?"+url.Values{
+		"query":     {`query($n: Int) { users(first: $n) { id } }`},
+		"variables": {`{"n": 3}`},
+	}.Encode(), nil)
+
+	for name, test := range map[string]struct {
+		req  *http.Request
+		want int
+	}{
+		"post":      {newPost(`{"query": "{ users(first: 5) { id name } }"}`), 11},
+		"batch":     {newPost(`[{"query": "{ a }"}, {"query": "{ b { c } }"}]`), 3},
+		"graphql":   {graphql, 6},
+		"get":       {get, 4},
+		"typename":  {newPost(`{"query": "{ __typename }"}`), 1},
+		"malformed": {newPost(`{"query": "{ a"}`), 1},
+		"not json":  {newPost(`query`), 1},
+		"put":       {httptest.NewRequest(http.MethodPut, "/graphql", nil), 1},
+	} {
+		if cost := costs(test.req); cost != test.want {
+			t.Errorf("%s: expected a cost of %d; got %d", name, test.want, cost)
+		}
+	}
+}
+
+// Test the body is restored for the handler, and analyzed only once
+func TestCostFuncRestoresBody(t *testing.T) {
+	calls := 0
+	costs := CostFunc(AnalyzerFunc(func(req Request) (int, error) {
+		calls++
+		return 7, nil
+	}))
+	body := `{"query": "{ a }"}`
+	req := newPost(body)
+
+	for range 2 {
+		if cost := costs(req); cost != 7 {
+			t.Errorf("expected a cost of 7; got %d", cost)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the request to be analyzed once; got %d", calls)
+	}
+	if restored, _ := io.ReadAll(req.Body); string(restored) != body {
+		t.Errorf("expected the body to be restored; got %q", restored)
+	}
+}
+
+// Test bodies over the size limit cost the invalid cost and are restored whole
+func TestCostFuncMaxBodySize(t *testing.T) {
+	costs := CostFunc(AnalyzerFunc(func(req Request) (int, error) {
+		return 0, errors.New("unexpected analysis")
+	}), WithMaxBodySize(8), WithInvalidCost(100))
+	body := `{"query": "{ a }"}`
+	req := newPost(body)
+
+	if cost := costs(req); cost != 100 {
+		t.Errorf("expected a cost of 100; got %d", cost)
+	}
+	if cost := costs(req); cost != 100 {
+		t.Errorf("expected the cost to be cached; got %d", cost)
+	}
+	if restored, _ := io.ReadAll(req.Body); string(restored) != body {
+		t.Errorf("expected the body to be restored; got %q", restored)
+	}
+}
+
+// Test a limiter charges requests their complexity
+func TestCostFuncWithLimiter(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(100, time.Minute, cerberus.WithCostFunc(CostFunc(ComplexityAnalyzer{})))
+	handler := cerberus.New(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	query := `{"query": "{ users(first: 30) { id name } }"}`
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newPost(query))
+		if rr.Code != want {
+			t.Errorf("request %d: expected status %d; got %d", i+1, want, rr.Code)
+		}
+	}
+}
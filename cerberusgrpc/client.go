@@ -0,0 +1,77 @@
+package cerberusgrpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor returns a client interceptor that checks every outbound unary call
+// against rateLimiter before sending it, so that a client respects the quota of the services it
+// calls without being throttled by them.
+//
+// Outbound calls are described to the rate limiter like inbound ones, except that the request
+// carries the outgoing metadata as headers, has the target of the connection, such as
+// "dns:///api.example.com:443", as its host, and has no remote address. With
+// [cerberus.KeyByIP], the default of the built-in limiters, all calls would therefore share a
+// single limit: [KeyByTargetMethod] limits each method of each target separately, and
+// [cerberus.KeyByPath] each method.
+//
+// Behavior:
+//   - If the call is allowed by the rate limiter, it is sent.
+//   - If the call exceeds the rate limit, it fails with codes.ResourceExhausted and retry
+//     information without being sent, unless waiting is enabled with [WithWait].
+//   - If the rate limiter encounters an error, the call is handled according to the failure policy.
+//     See [WithFailurePolicy].
+//
+// Example usage:
+//
+//	limiter := cerberus.NewTokenBucketLimiter(10, 5, cerberus.WithKeyFunc(cerberusgrpc.KeyByTargetMethod))
+//	conn, err := grpc.NewClient(target,
+//		grpc.WithUnaryInterceptor(cerberusgrpc.UnaryClientInterceptor(limiter, cerberusgrpc.WithWait(time.Second))),
+//		grpc.WithStreamInterceptor(cerberusgrpc.StreamClientInterceptor(limiter)),
+//	)
+func UnaryClientInterceptor(rateLimiter cerberus.RateLimiter, opts ...Option) grpc.UnaryClientInterceptor {
+	options := newOptions(opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if err := options.check(newClientRequest(ctx, method, cc), rateLimiter, nil); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor that checks every outbound stream against
+// rateLimiter before opening it. It behaves like [UnaryClientInterceptor]; messages sent on an
+// open stream are not rate limited.
+func StreamClientInterceptor(rateLimiter cerberus.RateLimiter, opts ...Option) grpc.StreamClientInterceptor {
+	options := newOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := options.check(newClientRequest(ctx, method, cc), rateLimiter, nil); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, callOpts...)
+	}
+}
+
+// KeyByTargetMethod is a [cerberus.KeyFunc] keying outbound calls by the target of their
+// connection and their full method name, such as
+// "dns:///api.example.com:443/helloworld.Greeter/SayHello", so that each method of each service
+// called has its own limit.
+func KeyByTargetMethod(r *http.Request) (string, error) {
+	return r.Host + r.URL.Path, nil
+}
+
+// newClientRequest builds the request describing the outbound call to fullMethod made with ctx on
+// cc.
+func newClientRequest(ctx context.Context, fullMethod string, cc *grpc.ClientConn) *http.Request {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	var target string
+	if cc != nil {
+		target = cc.Target()
+	}
+	return newRequest(ctx, fullMethod, md, target)
+}
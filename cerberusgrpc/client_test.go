@@ -0,0 +1,137 @@
+package cerberusgrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newClientConn returns a connection to target, which is never dialed since calls are sent with
+// stub invokers.
+func newClientConn(t *testing.T, target string) *grpc.ClientConn {
+	cc, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+// countingInvoker returns a unary invoker counting the calls it sends.
+func countingInvoker(sent *int) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*sent++
+		return nil
+	}
+}
+
+// Test the unary client interceptor fails calls exceeding the rate limit without sending them
+func TestUnaryClientInterceptorThrottles(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(KeyByTargetMethod))
+	interceptor := UnaryClientInterceptor(limiter)
+	cc := newClientConn(t, "passthrough:///api.example.com:443")
+	sent := 0
+
+	if err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, cc, countingInvoker(&sent)); err != nil {
+		t.Fatalf("expected first call to be sent; got %v", err)
+	}
+	err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, cc, countingInvoker(&sent))
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted; got %v", st.Code())
+	}
+	if details := st.Details(); len(details) != 1 {
+		t.Errorf("expected 1 status detail; got %v", len(details))
+	} else if _, ok := details[0].(*errdetails.RetryInfo); !ok {
+		t.Errorf("expected retry info; got %v", details[0])
+	}
+	if sent != 1 {
+		t.Errorf("expected 1 call to be sent; got %v", sent)
+	}
+}
+
+// Test calls are keyed by target and method with KeyByTargetMethod
+func TestUnaryClientInterceptorKeysByTargetMethod(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(KeyByTargetMethod))
+	interceptor := UnaryClientInterceptor(limiter)
+	sent := 0
+
+	for _, call := range []struct{ target, method string }{
+		{"passthrough:///a.example.com", "/helloworld.Greeter/SayHello"},
+		{"passthrough:///a.example.com", "/helloworld.Greeter/SayGoodbye"},
+		{"passthrough:///b.example.com", "/helloworld.Greeter/SayHello"},
+	} {
+		cc := newClientConn(t, call.target)
+		if err := interceptor(context.Background(), call.method, nil, nil, cc, countingInvoker(&sent)); err != nil {
+			t.Errorf("expected call to %v%v to be sent; got %v", call.target, call.method, err)
+		}
+	}
+}
+
+// Test outgoing metadata is available to key functions
+func TestUnaryClientInterceptorMetadataKey(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByHeader("x-tenant")))
+	interceptor := UnaryClientInterceptor(limiter)
+	sent := 0
+
+	for _, tenant := range []string{"alice", "bob"} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", tenant)
+		if err := interceptor(ctx, "/helloworld.Greeter/SayHello", nil, nil, nil, countingInvoker(&sent)); err != nil {
+			t.Errorf("expected call for tenant %v to be sent; got %v", tenant, err)
+		}
+	}
+}
+
+// Test calls exceeding the rate limit are delayed with WithWait
+func TestUnaryClientInterceptorWaits(t *testing.T) {
+	limiter := cerberus.NewTokenBucketLimiter(1, 20, cerberus.WithKeyFunc(cerberus.KeyByPath))
+	interceptor := UnaryClientInterceptor(limiter, WithWait(time.Second))
+	sent := 0
+
+	start := time.Now()
+	for i := range 2 {
+		if err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, nil, countingInvoker(&sent)); err != nil {
+			t.Fatalf("expected call %d to be sent; got %v", i+1, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("expected the second call to wait for a token; got %v", elapsed)
+	}
+	if sent != 2 {
+		t.Errorf("expected 2 calls to be sent; got %v", sent)
+	}
+}
+
+// Test the stream client interceptor fails streams exceeding the rate limit without opening them
+func TestStreamClientInterceptorThrottles(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByPath))
+	interceptor := StreamClientInterceptor(limiter)
+	opened := 0
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		opened++
+		return nil, nil
+	}
+	desc := &grpc.StreamDesc{StreamName: "SayHellos", ServerStreams: true}
+
+	if _, err := interceptor(context.Background(), desc, nil, "/helloworld.Greeter/SayHellos", streamer); err != nil {
+		t.Fatalf("expected first stream to be opened; got %v", err)
+	}
+	_, err := interceptor(context.Background(), desc, nil, "/helloworld.Greeter/SayHellos", streamer)
+
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted; got %v", code)
+	}
+	if opened != 1 {
+		t.Errorf("expected 1 stream to be opened; got %v", opened)
+	}
+}
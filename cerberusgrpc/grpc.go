@@ -0,0 +1,189 @@
+// Package cerberusgrpc provides gRPC server and client interceptors that apply rate limiting with
+// cerberus rate limiters, so gRPC and HTTP services can share the same limiter implementations.
+//
+// Rate limiters make their decisions based on an [http.Request]. For each call, the interceptors
+// build a request describing it: a POST of the full method name, such as
+// "/helloworld.Greeter/SayHello", carrying the incoming metadata as headers, the address of the
+// peer as its remote address, and the call's context. Key functions such as [cerberus.KeyByIP]
+// and [cerberus.KeyByHeader], and route patterns of a [cerberus.PolicyRouter], therefore work
+// unchanged:
+//
+//	limiter := cerberus.NewTokenBucketLimiter(100, 10, cerberus.WithKeyFunc(cerberus.KeyByHeader("x-api-key")))
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(cerberusgrpc.UnaryServerInterceptor(limiter)),
+//		grpc.StreamInterceptor(cerberusgrpc.StreamServerInterceptor(limiter)),
+//	)
+//
+// Throttled calls fail with codes.ResourceExhausted. If the rate limiter implements
+// [cerberus.AdvancedRateLimiter], the status carries a RetryInfo detail, and the time until the
+// client may retry is also sent, in whole seconds, in the retry-after trailer.
+//
+// Client interceptors limit outbound calls in the same way, before they are sent, so that clients
+// can respect the quotas of the services they call. See [UnaryClientInterceptor].
+package cerberusgrpc
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Option configures the behavior of the interceptors.
+type Option func(*options)
+
+// options holds the configuration assembled from a list of [Option] values.
+type options struct {
+	failurePolicy cerberus.FailurePolicy
+	maxWait       time.Duration
+}
+
+// WithFailurePolicy sets how calls are handled when the rate limiter returns an error. With
+// [cerberus.FailWithError], the default, calls fail with codes.Internal. With [cerberus.FailOpen]
+// they are handled as if they had been allowed, and with [cerberus.FailClosed] as if they had
+// been denied.
+func WithFailurePolicy(policy cerberus.FailurePolicy) Option {
+	return func(o *options) {
+		o.failurePolicy = policy
+	}
+}
+
+// WithWait makes the interceptors delay calls that exceed the rate limit until the rate limiter
+// allows them, instead of failing them right away, as long as waiting does not exceed maxWait.
+// Calls are checked again once the delay reported by a [cerberus.AdvancedRateLimiter] has elapsed,
+// or at short intervals otherwise, and stop waiting when their context is done. This suits client
+// interceptors in particular, which can usually wait for their quota rather than fail. A maxWait
+// of zero or less turns waiting off, which is the default. See [cerberus.WithWaitMode].
+func WithWait(maxWait time.Duration) Option {
+	return func(o *options) {
+		o.maxWait = maxWait
+	}
+}
+
+// UnaryServerInterceptor returns a server interceptor that checks every unary call against
+// rateLimiter before invoking its handler.
+//
+// Behavior:
+//   - If the call is allowed by the rate limiter, the handler is invoked.
+//   - If the call exceeds the rate limit, it fails with codes.ResourceExhausted and retry information.
+//   - If the rate limiter encounters an error, the call is handled according to the failure policy.
+//     See [WithFailurePolicy].
+func UnaryServerInterceptor(rateLimiter cerberus.RateLimiter, opts ...Option) grpc.UnaryServerInterceptor {
+	options := newOptions(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		r := newRequest(ctx, info.FullMethod, incomingMetadata(ctx), "")
+		if err := options.check(r, rateLimiter, func(md metadata.MD) error {
+			return grpc.SetTrailer(ctx, md)
+		}); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor that checks every stream against
+// rateLimiter when it is opened. It behaves like [UnaryServerInterceptor]; messages sent on an
+// allowed stream are not rate limited.
+func StreamServerInterceptor(rateLimiter cerberus.RateLimiter, opts ...Option) grpc.StreamServerInterceptor {
+	options := newOptions(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		r := newRequest(ss.Context(), info.FullMethod, incomingMetadata(ss.Context()), "")
+		if err := options.check(r, rateLimiter, func(md metadata.MD) error {
+			ss.SetTrailer(md)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// newOptions applies opts on top of the default configuration.
+func newOptions(opts []Option) options {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// check checks the call described by r against rateLimiter, waiting for it to be allowed if
+// configured to. It returns nil if the call may proceed, and the status error to fail it with
+// otherwise. setTrailer sets the trailer of the call; it is nil for outbound calls.
+func (o *options) check(r *http.Request, rateLimiter cerberus.RateLimiter, setTrailer func(metadata.MD) error) error {
+	isAllowed, err := cerberus.Wait(r, rateLimiter, o.maxWait)
+	if err != nil {
+		switch o.failurePolicy {
+		case cerberus.FailOpen:
+			return nil
+		case cerberus.FailClosed:
+			return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		default:
+			return status.Errorf(codes.Internal, "rate limiter error: %v", err)
+		}
+	}
+	if isAllowed {
+		return nil
+	}
+	advanced, ok := rateLimiter.(cerberus.AdvancedRateLimiter)
+	if !ok {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	retryAfter := advanced.GetRateLimitData(r).RetryAfter
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if setTrailer != nil {
+		_ = setTrailer(metadata.Pairs("retry-after", strconv.FormatInt(seconds, 10)))
+	}
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
+
+// incomingMetadata returns the metadata received with the call made with ctx, if any.
+func incomingMetadata(ctx context.Context) metadata.MD {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return md
+}
+
+// newRequest builds the request describing the call to fullMethod made with ctx, carrying md as
+// headers. host, if not empty, is the host of the request, unless md has an :authority.
+func newRequest(ctx context.Context, fullMethod string, md metadata.MD, host string) *http.Request {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fullMethod, nil)
+	if err != nil {
+		r, _ = http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	}
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	r.RequestURI = fullMethod
+	r.Host = host
+	for name, values := range md {
+		if name == ":authority" {
+			r.Host = values[0]
+			continue
+		}
+		if strings.HasPrefix(name, ":") {
+			continue
+		}
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
@@ -0,0 +1,147 @@
+package cerberusgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type stubLimiter struct {
+	isAllowed bool
+	err       error
+}
+
+func (l *stubLimiter) IsAllowed(*http.Request) (bool, error) {
+	return l.isAllowed, l.err
+}
+
+// newCallContext returns the context of a call from addr carrying md.
+func newCallContext(addr string, md metadata.MD) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return peer.NewContext(ctx, &peer.Peer{Addr: tcpAddr})
+}
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+
+func okHandler(ctx context.Context, req any) (any, error) {
+	return "ok", nil
+}
+
+// Test the unary interceptor throttles calls with retry info
+func TestUnaryServerInterceptorThrottles(t *testing.T) {
+	interceptor := UnaryServerInterceptor(cerberus.NewFixedWindowLimiter(1, time.Minute))
+	ctx := newCallContext("192.0.2.1:1234", nil)
+
+	if _, err := interceptor(ctx, nil, unaryInfo, okHandler); err != nil {
+		t.Fatalf("expected first call to be allowed; got %v", err)
+	}
+	_, err := interceptor(ctx, nil, unaryInfo, okHandler)
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted; got %v", st.Code())
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("expected 1 status detail; got %v", len(details))
+	}
+	if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("expected positive retry delay; got %v", details[0])
+	}
+}
+
+// Test calls are keyed by peer address
+func TestUnaryServerInterceptorKeysByPeer(t *testing.T) {
+	interceptor := UnaryServerInterceptor(cerberus.NewFixedWindowLimiter(1, time.Minute))
+
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		if _, err := interceptor(newCallContext(addr, nil), nil, unaryInfo, okHandler); err != nil {
+			t.Errorf("expected call from %v to be allowed; got %v", addr, err)
+		}
+	}
+}
+
+// Test metadata is available to key functions
+func TestUnaryServerInterceptorMetadataKey(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithKeyFunc(cerberus.KeyByHeader("x-api-key")))
+	interceptor := UnaryServerInterceptor(limiter)
+
+	for _, apiKey := range []string{"alice", "bob"} {
+		ctx := newCallContext("192.0.2.1:1234", metadata.Pairs("x-api-key", apiKey))
+		if _, err := interceptor(ctx, nil, unaryInfo, okHandler); err != nil {
+			t.Errorf("expected call with key %v to be allowed; got %v", apiKey, err)
+		}
+	}
+}
+
+// Test limiter errors are handled according to the failure policy
+func TestUnaryServerInterceptorFailurePolicy(t *testing.T) {
+	limiter := &stubLimiter{err: errors.New("store unavailable")}
+	ctx := newCallContext("192.0.2.1:1234", nil)
+
+	_, err := UnaryServerInterceptor(limiter)(ctx, nil, unaryInfo, okHandler)
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("expected Internal; got %v", code)
+	}
+	if _, err := UnaryServerInterceptor(limiter, WithFailurePolicy(cerberus.FailOpen))(ctx, nil, unaryInfo, okHandler); err != nil {
+		t.Errorf("expected call to be allowed; got %v", err)
+	}
+}
+
+type stubServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (s *stubServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stubServerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+// Test the stream interceptor sets the retry-after trailer
+func TestStreamServerInterceptorThrottles(t *testing.T) {
+	interceptor := StreamServerInterceptor(cerberus.NewFixedWindowLimiter(1, time.Minute))
+	info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/SayHellos"}
+	handler := func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}
+	stream := &stubServerStream{ctx: newCallContext("192.0.2.1:1234", nil)}
+
+	if err := interceptor(nil, stream, info, handler); err != nil {
+		t.Fatalf("expected first stream to be allowed; got %v", err)
+	}
+	err := interceptor(nil, stream, info, handler)
+
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted; got %v", code)
+	}
+	if retryAfter := stream.trailer.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] == "0" {
+		t.Errorf("expected positive retry-after trailer; got %v", retryAfter)
+	}
+}
+
+// Benchmark allowed calls through the unary interceptor
+func BenchmarkUnaryServerInterceptor(b *testing.B) {
+	interceptor := UnaryServerInterceptor(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour))
+	ctx := newCallContext("192.0.2.1:1234", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		interceptor(ctx, nil, unaryInfo, okHandler)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/mxmlkzdh/cerberus/cerberuskafka
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/mxmlkzdh/cerberus v0.0.0
+	github.com/segmentio/kafka-go v0.4.51
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,86 @@
+// Package cerberuskafka exports the events of cerberus rate limiters, such as the denials of
+// requests and the bans of clients, to a Kafka topic, so that security teams can feed throttling
+// data into their SIEM and abuse detection pipelines.
+//
+// The exporter is installed in a [cerberus.EventPublisher], which sends it batches of events in the
+// background:
+//
+//	writer := &kafka.Writer{Addr: kafka.TCP("kafka:9092"), Topic: "rate-limit-events"}
+//	publisher := cerberus.NewEventPublisher(cerberuskafka.New(writer, cerberuskafka.WithWriterOwnership()), nil)
+//	defer publisher.Close(context.Background())
+//	http.Handle("/", cerberus.New(limiter, cerberus.WithEventPublisher(publisher))(handler))
+//
+// Each event is written as a message holding its JSON encoding, keyed by the key of its client so
+// that the events of a client land in the same partition, in order. The type of the event is set
+// in the "event_type" header, for consumers to filter events without decoding them.
+package cerberuskafka
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/segmentio/kafka-go"
+)
+
+// Writer writes messages to Kafka. It is implemented by [kafka.Writer].
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Exporter is a [cerberus.EventExporter] writing events to Kafka.
+//
+// An Exporter is safe for concurrent use by multiple goroutines if its writer is.
+type Exporter struct {
+	writer     Writer
+	ownsWriter bool
+}
+
+// Option configures an [Exporter].
+type Option func(*Exporter)
+
+// WithWriterOwnership makes [Exporter.Close] close the writer of the exporter, if it has a Close
+// method, flushing the messages it buffers. By default, the writer is not closed by the exporter.
+func WithWriterOwnership() Option {
+	return func(e *Exporter) {
+		e.ownsWriter = true
+	}
+}
+
+// New creates a new [Exporter] writing events with writer, which sets the topic they are written
+// to. The writer is not closed by the exporter, unless it is created with [WithWriterOwnership].
+func New(writer Writer, opts ...Option) *Exporter {
+	e := &Exporter{writer: writer}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExportEvents writes events to Kafka in a single call to the writer. It implements
+// [cerberus.EventExporter].
+func (e *Exporter) ExportEvents(ctx context.Context, events []cerberus.Event) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msgs[i] = kafka.Message{
+			Key:     []byte(event.Key),
+			Value:   value,
+			Time:    event.Time,
+			Headers: []kafka.Header{{Key: "event_type", Value: []byte(event.Type)}},
+		}
+	}
+	return e.writer.WriteMessages(ctx, msgs...)
+}
+
+// Close closes the writer of the exporter if it was created with [WithWriterOwnership], and does
+// nothing otherwise. It implements [cerberus.Closer].
+func (e *Exporter) Close(context.Context) error {
+	if closer, ok := e.writer.(interface{ Close() error }); ok && e.ownsWriter {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cerberuskafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/segmentio/kafka-go"
+)
+
+// recordingWriter records the messages written to it.
+type recordingWriter struct {
+	msgs   []kafka.Message
+	err    error
+	closed bool
+}
+
+func (w *recordingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return w.err
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// Test denials are written as JSON messages keyed by client
+func TestExporter(t *testing.T) {
+	writer := &recordingWriter{}
+	publisher := cerberus.NewEventPublisher(New(writer), nil)
+	handler := cerberus.New(cerberus.NewTokenBucketLimiter(1, 0.001), cerberus.WithEventPublisher(publisher))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	publisher.Close(context.Background())
+
+	if len(writer.msgs) != 1 {
+		t.Fatalf("expected 1 message; got %d", len(writer.msgs))
+	}
+	msg := writer.msgs[0]
+	if string(msg.Key) != "192.0.2.1" {
+		t.Errorf("expected key 192.0.2.1; got %q", msg.Key)
+	}
+	if len(msg.Headers) != 1 || msg.Headers[0].Key != "event_type" || string(msg.Headers[0].Value) != "denied" {
+		t.Errorf("expected an event_type header of denied; got %v", msg.Headers)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event["type"] != "denied" || event["route"] != "/api" {
+		t.Errorf("expected the denial of /api; got %v", event)
+	}
+	if writer.closed {
+		t.Errorf("expected the writer not to be closed without ownership")
+	}
+}
+
+// Test write errors are returned and owned writers are closed
+func TestExporterErrorsAndClose(t *testing.T) {
+	errWrite := errors.New("broker down")
+	writer := &recordingWriter{err: errWrite}
+	exporter := New(writer, WithWriterOwnership())
+
+	err := exporter.ExportEvents(context.Background(), []cerberus.Event{{Type: cerberus.EventBanned, Time: time.Now(), Ban: time.Minute}})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("expected %v; got %v", errWrite, err)
+	}
+	if err := exporter.Close(context.Background()); err != nil || !writer.closed {
+		t.Errorf("expected the writer to be closed; got %v, closed %v", err, writer.closed)
+	}
+}
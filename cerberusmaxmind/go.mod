@@ -0,0 +1,16 @@
+module github.com/mxmlkzdh/cerberus/cerberusmaxmind
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/mxmlkzdh/cerberus v0.0.0
+	github.com/oschwald/geoip2-golang v1.13.0
+)
+
+require (
+	github.com/oschwald/maxminddb-golang v1.13.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
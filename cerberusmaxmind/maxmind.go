@@ -0,0 +1,90 @@
+// Package cerberusmaxmind implements [cerberus.GeoProvider] with the GeoIP2 and GeoLite2 databases
+// of MaxMind, so that [cerberus.GeoResolver] can key and match requests by the country and
+// autonomous system of their client:
+//
+//	provider, err := cerberusmaxmind.Open("GeoLite2-Country.mmdb", "GeoLite2-ASN.mmdb")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer provider.Close(context.Background())
+//	geo := cerberus.NewGeoResolver(provider, nil)
+package cerberusmaxmind
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Provider is a [cerberus.GeoProvider] looking addresses up in a country database, such as
+// GeoLite2-Country or GeoIP2-City, and an ASN database, such as GeoLite2-ASN. Either database may
+// be missing, in which case the fields it provides are left empty.
+//
+// A Provider is safe for concurrent use by multiple goroutines.
+type Provider struct {
+	country *geoip2.Reader
+	asn     *geoip2.Reader
+}
+
+// New creates a new [Provider] looking countries up in country and autonomous systems in asn,
+// either of which may be nil. The readers are closed by [Provider.Close].
+func New(country, asn *geoip2.Reader) *Provider {
+	return &Provider{country: country, asn: asn}
+}
+
+// Open creates a new [Provider] from the database files at countryPath and asnPath, either of which
+// may be empty to leave the fields it provides empty. It returns an error if a file cannot be
+// opened or is not a MaxMind database.
+func Open(countryPath, asnPath string) (*Provider, error) {
+	provider := &Provider{}
+	var err error
+	if countryPath != "" {
+		if provider.country, err = geoip2.Open(countryPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if provider.asn, err = geoip2.Open(asnPath); err != nil {
+			provider.Close(context.Background())
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+// Lookup returns the country and autonomous system of addr.
+func (p *Provider) Lookup(addr netip.Addr) (cerberus.GeoInfo, error) {
+	var info cerberus.GeoInfo
+	ip := net.IP(addr.AsSlice())
+	if p.country != nil {
+		country, err := p.country.Country(ip)
+		if err != nil {
+			return cerberus.GeoInfo{}, err
+		}
+		info.Country = country.Country.IsoCode
+	}
+	if p.asn != nil {
+		asn, err := p.asn.ASN(ip)
+		if err != nil {
+			return cerberus.GeoInfo{}, err
+		}
+		info.ASN = uint32(asn.AutonomousSystemNumber)
+		info.Organization = asn.AutonomousSystemOrganization
+	}
+	return info, nil
+}
+
+// Close closes the databases of the provider.
+func (p *Provider) Close(context.Context) error {
+	var errs []error
+	for _, reader := range []*geoip2.Reader{p.country, p.asn} {
+		if reader != nil {
+			errs = append(errs, reader.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
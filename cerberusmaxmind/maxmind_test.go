@@ -0,0 +1,49 @@
+package cerberusmaxmind
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Provider implements the interfaces of cerberus.
+var (
+	_ cerberus.GeoProvider = (*Provider)(nil)
+	_ cerberus.Closer      = (*Provider)(nil)
+)
+
+// Test opening a file that is not a MaxMind database fails
+func TestOpenInvalidDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	if err := os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path, ""); err == nil {
+		t.Errorf("expected an error for an invalid country database")
+	}
+	if _, err := Open("", path); err == nil {
+		t.Errorf("expected an error for an invalid ASN database")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"), ""); err == nil {
+		t.Errorf("expected an error for a missing database")
+	}
+}
+
+// Test a provider without databases leaves every field empty
+func TestProviderWithoutDatabases(t *testing.T) {
+	provider, err := Open("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close(context.Background())
+
+	info, err := provider.Lookup(netip.MustParseAddr("192.0.2.1"))
+	if err != nil || info != (cerberus.GeoInfo{}) {
+		t.Errorf("expected an empty GeoInfo; got %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package cerberusmetrics instruments cerberus rate limiters with Prometheus metrics.
+//
+// Rate limiters are instrumented by wrapping them with [Metrics.Instrument] before installing
+// them in a middleware. The wrapped rate limiter records every decision it makes:
+//
+//	metrics, err := cerberusmetrics.New()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	limiter := metrics.Instrument("api", cerberus.NewTokenBucketLimiter(100, 10))
+//	http.Handle("/api/", cerberus.New(limiter)(apiHandler))
+//
+// The following metrics are exported, all labeled with the name of the rate limiter:
+//   - cerberus_decisions_total, a counter of checks by route and result ("allowed", "denied", or "error").
+//   - cerberus_check_duration_seconds, a histogram of the latency of checks by route.
+//   - cerberus_active_keys, a gauge of the number of keys held by each store registered with
+//     [Metrics.ObserveStore].
+package cerberusmetrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors shared by all rate limiters instrumented with it.
+type Metrics struct {
+	registerer prometheus.Registerer
+	routeFunc  func(*http.Request) string
+	decisions  *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// Option configures [Metrics].
+type Option func(*Metrics)
+
+// WithRegisterer sets the registerer the collectors are registered with. The default is
+// [prometheus.DefaultRegisterer].
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(m *Metrics) {
+		m.registerer = registerer
+	}
+}
+
+// WithRouteFunc sets the function that determines the route label of a request. It must map
+// requests to a small, bounded set of values, such as route patterns rather than raw paths. The
+// default uses the pattern of the [http.ServeMux] route matching the request, which is empty if
+// the rate limiter runs outside of the mux.
+func WithRouteFunc(routeFunc func(*http.Request) string) Option {
+	return func(m *Metrics) {
+		m.routeFunc = routeFunc
+	}
+}
+
+// New creates the collectors and registers them. It returns an error if registration fails,
+// for example because the collectors are already registered with the same registerer.
+func New(opts ...Option) (*Metrics, error) {
+	m := &Metrics{
+		registerer: prometheus.DefaultRegisterer,
+		routeFunc: func(r *http.Request) string {
+			return r.Pattern
+		},
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cerberus",
+			Name:      "decisions_total",
+			Help:      "Number of rate limit checks by limiter, route, and result.",
+		}, []string{"limiter", "route", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cerberus",
+			Name:      "check_duration_seconds",
+			Help:      "Latency of rate limit checks by limiter and route.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+		}, []string{"limiter", "route"}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, collector := range []prometheus.Collector{m.decisions, m.duration} {
+		if err := m.registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Instrument wraps rateLimiter so that its decisions and their latency are recorded under the
+// given limiter name. The returned rate limiter implements [cerberus.AdvancedRateLimiter] if and
+// only if rateLimiter does, so wrapping it does not change which headers a middleware emits.
+func (m *Metrics) Instrument(name string, rateLimiter cerberus.RateLimiter) cerberus.RateLimiter {
+	instrumented := &instrumentedLimiter{metrics: m, name: name, rateLimiter: rateLimiter}
+	if advanced, ok := rateLimiter.(cerberus.AdvancedRateLimiter); ok {
+		return &instrumentedAdvancedLimiter{instrumentedLimiter: instrumented, advanced: advanced}
+	}
+	return instrumented
+}
+
+// ObserveStore exports the number of keys held by store as the cerberus_active_keys gauge under
+// the given limiter name. Any store with a Len method, such as [cerberus.MemoryStore], can be
+// observed. It returns an error if registration fails.
+func (m *Metrics) ObserveStore(name string, store interface{ Len() int }) error {
+	return m.registerer.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "cerberus",
+		Name:        "active_keys",
+		Help:        "Number of keys held by the store of a limiter.",
+		ConstLabels: prometheus.Labels{"limiter": name},
+	}, func() float64 {
+		return float64(store.Len())
+	}))
+}
+
+// instrumentedLimiter is a [cerberus.RateLimiterContext] recording the decisions of the rate
+// limiter it wraps.
+type instrumentedLimiter struct {
+	metrics     *Metrics
+	name        string
+	rateLimiter cerberus.RateLimiter
+}
+
+// IsAllowed checks the request against the wrapped rate limiter and records the decision.
+func (l *instrumentedLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the wrapped rate limiter.
+func (l *instrumentedLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	start := time.Now()
+	var isAllowed bool
+	var err error
+	if rateLimiterContext, ok := l.rateLimiter.(cerberus.RateLimiterContext); ok {
+		isAllowed, err = rateLimiterContext.IsAllowedContext(ctx, r)
+	} else {
+		isAllowed, err = l.rateLimiter.IsAllowed(r.WithContext(ctx))
+	}
+	route := l.metrics.routeFunc(r)
+	l.metrics.duration.WithLabelValues(l.name, route).Observe(time.Since(start).Seconds())
+	result := "allowed"
+	switch {
+	case err != nil:
+		result = "error"
+	case !isAllowed:
+		result = "denied"
+	}
+	l.metrics.decisions.WithLabelValues(l.name, route, result).Inc()
+	return isAllowed, err
+}
+
+// instrumentedAdvancedLimiter is an [instrumentedLimiter] wrapping a
+// [cerberus.AdvancedRateLimiter].
+type instrumentedAdvancedLimiter struct {
+	*instrumentedLimiter
+	advanced cerberus.AdvancedRateLimiter
+}
+
+// GetRateLimitData returns the rate limit data reported by the wrapped rate limiter.
+func (l *instrumentedAdvancedLimiter) GetRateLimitData(r *http.Request) cerberus.RateLimitData {
+	return l.advanced.GetRateLimitData(r)
+}
@@ -0,0 +1,90 @@
+package cerberusmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stubLimiter struct {
+	isAllowed bool
+	err       error
+}
+
+func (l *stubLimiter) IsAllowed(*http.Request) (bool, error) {
+	return l.isAllowed, l.err
+}
+
+// Test decisions are counted by result
+func TestInstrumentCountsDecisions(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := New(WithRegisterer(registry), WithRouteFunc(func(r *http.Request) string {
+		return "/api"
+	}))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	stub := &stubLimiter{isAllowed: true}
+	limiter := metrics.Instrument("api", stub)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+
+	limiter.IsAllowed(req)
+	stub.isAllowed = false
+	limiter.IsAllowed(req)
+	stub.err = errors.New("store unavailable")
+	limiter.IsAllowed(req)
+
+	for _, result := range []string{"allowed", "denied", "error"} {
+		if got := testutil.ToFloat64(metrics.decisions.WithLabelValues("api", "/api", result)); got != 1 {
+			t.Errorf("expected 1 %s decision; got %v", result, got)
+		}
+	}
+	if got := testutil.CollectAndCount(metrics.duration); got != 1 {
+		t.Errorf("expected 1 latency series; got %v", got)
+	}
+}
+
+// Test instrumenting preserves whether the rate limiter is advanced
+func TestInstrumentPreservesAdvancedRateLimiter(t *testing.T) {
+	metrics, _ := New(WithRegisterer(prometheus.NewRegistry()))
+
+	if _, ok := metrics.Instrument("basic", &stubLimiter{}).(cerberus.AdvancedRateLimiter); ok {
+		t.Errorf("expected basic rate limiter to stay basic")
+	}
+	advanced := metrics.Instrument("advanced", cerberus.NewFixedWindowLimiter(10, 1e9))
+	if _, ok := advanced.(cerberus.AdvancedRateLimiter); !ok {
+		t.Errorf("expected advanced rate limiter to stay advanced")
+	}
+}
+
+// Test the active keys gauge reports the size of the store
+func TestObserveStore(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, _ := New(WithRegisterer(registry))
+	store := cerberus.NewMemoryStore()
+	defer store.Close(context.Background())
+	limiter := cerberus.NewTokenBucketLimiter(10, 1, cerberus.WithStore(store))
+	if err := metrics.ObserveStore("api", store); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	for _, addr := range []string{"192.0.2.1:1", "192.0.2.2:1"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		limiter.IsAllowed(req)
+	}
+
+	families, err := registry.Gather()
+	if err != nil || len(families) != 1 {
+		t.Fatalf("expected 1 metric family; got %v, %v", len(families), err)
+	}
+	if got := families[0].GetMetric()[0].GetGauge().GetValue(); got != 2 {
+		t.Errorf("expected 2 active keys; got %v", got)
+	}
+}
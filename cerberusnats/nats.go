@@ -0,0 +1,107 @@
+// Package cerberusnats exports the events of cerberus rate limiters, such as the denials of
+// requests and the bans of clients, to NATS subjects, so that security teams can feed throttling
+// data into their SIEM and abuse detection pipelines.
+//
+// The exporter is installed in a [cerberus.EventPublisher], which sends it batches of events in the
+// background:
+//
+//	conn, err := nats.Connect("nats://nats:4222")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	publisher := cerberus.NewEventPublisher(cerberusnats.New(conn), nil)
+//	defer publisher.Close(context.Background())
+//	http.Handle("/", cerberus.New(limiter, cerberus.WithEventPublisher(publisher))(handler))
+//
+// Each event is published as a message holding its JSON encoding, on a subject made of the prefix
+// of the exporter and the type of the event, such as "cerberus.events.denied", so that consumers
+// can subscribe to the types of events they need, or to all of them with "cerberus.events.>".
+// Messages carry the key of their client in the "Cerberus-Key" header. Subjects captured by a
+// JetStream stream are persisted by the stream.
+package cerberusnats
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/nats-io/nats.go"
+)
+
+// Conn publishes messages to NATS. It is implemented by [nats.Conn].
+type Conn interface {
+	PublishMsg(msg *nats.Msg) error
+	FlushWithContext(ctx context.Context) error
+}
+
+// Exporter is a [cerberus.EventExporter] publishing events to NATS.
+//
+// An Exporter is safe for concurrent use by multiple goroutines.
+type Exporter struct {
+	conn     Conn
+	prefix   string
+	ownsConn bool
+}
+
+// Option configures an [Exporter].
+type Option func(*Exporter)
+
+// WithSubjectPrefix sets the prefix of the subjects events are published on, to which the type of
+// each event is appended after a dot. The default is "cerberus.events".
+func WithSubjectPrefix(prefix string) Option {
+	return func(e *Exporter) {
+		e.prefix = prefix
+	}
+}
+
+// WithConnOwnership makes [Exporter.Close] close the connection of the exporter, if it has a Close
+// method, for connections used by nothing else. By default, the connection is only flushed.
+func WithConnOwnership() Option {
+	return func(e *Exporter) {
+		e.ownsConn = true
+	}
+}
+
+// New creates a new [Exporter] publishing events with conn. The connection is not closed by the
+// exporter, unless it is created with [WithConnOwnership].
+func New(conn Conn, opts ...Option) *Exporter {
+	e := &Exporter{
+		conn:   conn,
+		prefix: "cerberus.events",
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExportEvents publishes events to NATS, and waits for the server to have received them until ctx
+// is done. It implements [cerberus.EventExporter].
+func (e *Exporter) ExportEvents(ctx context.Context, events []cerberus.Event) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(e.prefix + "." + string(event.Type))
+		msg.Data = data
+		if event.Key != "" {
+			msg.Header.Set("Cerberus-Key", event.Key)
+		}
+		if err := e.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	return e.conn.FlushWithContext(ctx)
+}
+
+// Close flushes the connection of the exporter, waiting for the server to have received the
+// events published until ctx is done, and closes it if the exporter was created with
+// [WithConnOwnership]. It implements [cerberus.Closer].
+func (e *Exporter) Close(ctx context.Context) error {
+	err := e.conn.FlushWithContext(ctx)
+	if closer, ok := e.conn.(interface{ Close() }); ok && e.ownsConn {
+		closer.Close()
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package cerberusnats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/nats-io/nats.go"
+)
+
+// recordingConn records the messages published on it.
+type recordingConn struct {
+	msgs    []*nats.Msg
+	err     error
+	flushes int
+	closed  bool
+}
+
+func (c *recordingConn) PublishMsg(msg *nats.Msg) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+func (c *recordingConn) FlushWithContext(ctx context.Context) error {
+	c.flushes++
+	return nil
+}
+
+func (c *recordingConn) Close() {
+	c.closed = true
+}
+
+// Test denials are published as JSON messages on the subject of their type
+func TestExporter(t *testing.T) {
+	conn := &recordingConn{}
+	publisher := cerberus.NewEventPublisher(New(conn, WithSubjectPrefix("security.ratelimit")), nil)
+	handler := cerberus.New(cerberus.NewTokenBucketLimiter(1, 0.001), cerberus.WithEventPublisher(publisher))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	publisher.Close(context.Background())
+
+	if len(conn.msgs) != 1 {
+		t.Fatalf("expected 1 message; got %d", len(conn.msgs))
+	}
+	msg := conn.msgs[0]
+	if msg.Subject != "security.ratelimit.denied" {
+		t.Errorf("expected subject security.ratelimit.denied; got %q", msg.Subject)
+	}
+	if key := msg.Header.Get("Cerberus-Key"); key != "192.0.2.1" {
+		t.Errorf("expected key 192.0.2.1; got %q", key)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event["type"] != "denied" || event["route"] != "/api" {
+		t.Errorf("expected the denial of /api; got %v", event)
+	}
+	if conn.flushes == 0 || conn.closed {
+		t.Errorf("expected the connection to be flushed but not closed; got %d flushes, closed %v", conn.flushes, conn.closed)
+	}
+}
+
+// Test publish errors are returned and owned connections are closed
+func TestExporterErrorsAndClose(t *testing.T) {
+	errPublish := errors.New("connection closed")
+	conn := &recordingConn{err: errPublish}
+	exporter := New(conn, WithConnOwnership())
+
+	err := exporter.ExportEvents(context.Background(), []cerberus.Event{{Type: cerberus.EventBanned, Time: time.Now(), Ban: time.Minute}})
+	if !errors.Is(err, errPublish) {
+		t.Errorf("expected %v; got %v", errPublish, err)
+	}
+	if err := exporter.Close(context.Background()); err != nil || !conn.closed {
+		t.Errorf("expected the connection to be closed; got %v, closed %v", err, conn.closed)
+	}
+}
@@ -0,0 +1,175 @@
+// Package cerberusotel instruments cerberus rate limiters with OpenTelemetry traces and metrics.
+//
+// Rate limiters are instrumented by wrapping them with [Instrumentation.Instrument] before
+// installing them in a middleware:
+//
+//	instrumentation, err := cerberusotel.New()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	limiter := instrumentation.Instrument("api", cerberus.NewTokenBucketLimiter(100, 10))
+//	http.Handle("/api/", otelhttp.NewHandler(cerberus.New(limiter)(apiHandler), "api"))
+//
+// Every check runs in a "cerberus.check" span, a child of the span found in the request context,
+// for example the server span started by otelhttp. Denied requests are additionally recorded as a
+// "cerberus.denied" event on that parent span, so throttled requests stand out in distributed
+// traces even when child spans are sampled away or collapsed.
+//
+// The following metrics are recorded, all with a cerberus.limiter attribute holding the name of
+// the rate limiter:
+//   - cerberus.decisions, a counter of checks by cerberus.result ("allowed", "denied", or "error").
+//   - cerberus.check.duration, a histogram of the latency of checks, in seconds.
+package cerberusotel
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of its spans and metrics.
+const instrumentationName = "github.com/mxmlkzdh/cerberus/cerberusotel"
+
+// Instrumentation holds the tracer and instruments shared by all rate limiters instrumented
+// with it.
+type Instrumentation struct {
+	tracer    trace.Tracer
+	decisions metric.Int64Counter
+	duration  metric.Float64Histogram
+}
+
+// Option configures [Instrumentation].
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// WithTracerProvider sets the provider of the tracer spans are started with. The default is the
+// global provider returned by [otel.GetTracerProvider].
+func WithTracerProvider(tracerProvider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tracerProvider
+	}
+}
+
+// WithMeterProvider sets the provider of the meter metrics are recorded with. The default is the
+// global provider returned by [otel.GetMeterProvider].
+func WithMeterProvider(meterProvider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = meterProvider
+	}
+}
+
+// New creates the tracer and instruments. It returns an error if an instrument cannot be created.
+func New(opts ...Option) (*Instrumentation, error) {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	meter := c.meterProvider.Meter(instrumentationName)
+	decisions, err := meter.Int64Counter("cerberus.decisions",
+		metric.WithDescription("Number of rate limit checks by limiter and result."),
+		metric.WithUnit("{check}"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("cerberus.check.duration",
+		metric.WithDescription("Latency of rate limit checks by limiter."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return &Instrumentation{
+		tracer:    c.tracerProvider.Tracer(instrumentationName),
+		decisions: decisions,
+		duration:  duration,
+	}, nil
+}
+
+// Instrument wraps rateLimiter so that its checks are traced and measured under the given
+// limiter name. The returned rate limiter implements [cerberus.AdvancedRateLimiter] if and only
+// if rateLimiter does, so wrapping it does not change which headers a middleware emits.
+func (i *Instrumentation) Instrument(name string, rateLimiter cerberus.RateLimiter) cerberus.RateLimiter {
+	instrumented := &instrumentedLimiter{
+		instrumentation: i,
+		rateLimiter:     rateLimiter,
+		limiterAttr:     attribute.String("cerberus.limiter", name),
+	}
+	if advanced, ok := rateLimiter.(cerberus.AdvancedRateLimiter); ok {
+		return &instrumentedAdvancedLimiter{instrumentedLimiter: instrumented, advanced: advanced}
+	}
+	return instrumented
+}
+
+// instrumentedLimiter is a [cerberus.RateLimiterContext] tracing and measuring the checks of the
+// rate limiter it wraps.
+type instrumentedLimiter struct {
+	instrumentation *Instrumentation
+	rateLimiter     cerberus.RateLimiter
+	limiterAttr     attribute.KeyValue
+}
+
+// IsAllowed checks the request against the wrapped rate limiter in a new span.
+func (l *instrumentedLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but starts the span from ctx and passes the span's context
+// to the wrapped rate limiter.
+func (l *instrumentedLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	parent := trace.SpanFromContext(ctx)
+	ctx, span := l.instrumentation.tracer.Start(ctx, "cerberus.check",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(l.limiterAttr))
+	defer span.End()
+
+	start := time.Now()
+	var isAllowed bool
+	var err error
+	if rateLimiterContext, ok := l.rateLimiter.(cerberus.RateLimiterContext); ok {
+		isAllowed, err = rateLimiterContext.IsAllowedContext(ctx, r)
+	} else {
+		isAllowed, err = l.rateLimiter.IsAllowed(r.WithContext(ctx))
+	}
+	elapsed := time.Since(start).Seconds()
+
+	result := "allowed"
+	switch {
+	case err != nil:
+		result = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case !isAllowed:
+		result = "denied"
+		parent.AddEvent("cerberus.denied", trace.WithAttributes(l.limiterAttr))
+	}
+	resultAttr := attribute.String("cerberus.result", result)
+	span.SetAttributes(resultAttr)
+	l.instrumentation.duration.Record(ctx, elapsed, metric.WithAttributes(l.limiterAttr))
+	l.instrumentation.decisions.Add(ctx, 1, metric.WithAttributes(l.limiterAttr, resultAttr))
+	return isAllowed, err
+}
+
+// instrumentedAdvancedLimiter is an [instrumentedLimiter] wrapping a
+// [cerberus.AdvancedRateLimiter].
+type instrumentedAdvancedLimiter struct {
+	*instrumentedLimiter
+	advanced cerberus.AdvancedRateLimiter
+}
+
+// GetRateLimitData returns the rate limit data reported by the wrapped rate limiter.
+func (l *instrumentedAdvancedLimiter) GetRateLimitData(r *http.Request) cerberus.RateLimitData {
+	return l.advanced.GetRateLimitData(r)
+}
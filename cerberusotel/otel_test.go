@@ -0,0 +1,132 @@
+package cerberusotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mxmlkzdh/cerberus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type stubLimiter struct {
+	isAllowed bool
+	err       error
+}
+
+func (l *stubLimiter) IsAllowed(*http.Request) (bool, error) {
+	return l.isAllowed, l.err
+}
+
+func newTestInstrumentation(t *testing.T) (*Instrumentation, *tracetest.SpanRecorder, *sdktrace.TracerProvider, *sdkmetric.ManualReader) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	instrumentation, err := New(WithTracerProvider(tracerProvider), WithMeterProvider(meterProvider))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	return instrumentation, recorder, tracerProvider, reader
+}
+
+// Test checks run in a child span of the request span
+func TestInstrumentStartsChildSpan(t *testing.T) {
+	instrumentation, recorder, tracerProvider, _ := newTestInstrumentation(t)
+	limiter := instrumentation.Instrument("api", &stubLimiter{isAllowed: false})
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "request")
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	limiter.IsAllowed(req)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans; got %v", len(spans))
+	}
+	check, request := spans[0], spans[1]
+	if check.Name() != "cerberus.check" || check.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("expected cerberus.check child span; got %v with parent %v", check.Name(), check.Parent().SpanID())
+	}
+	if !hasAttribute(check.Attributes(), attribute.String("cerberus.result", "denied")) {
+		t.Errorf("expected denied result attribute; got %v", check.Attributes())
+	}
+	if events := request.Events(); len(events) != 1 || events[0].Name != "cerberus.denied" {
+		t.Errorf("expected cerberus.denied event on request span; got %v", events)
+	}
+}
+
+// Test limiter errors are recorded on the span
+func TestInstrumentRecordsError(t *testing.T) {
+	instrumentation, recorder, _, _ := newTestInstrumentation(t)
+	limiter := instrumentation.Instrument("api", &stubLimiter{err: errors.New("store unavailable")})
+
+	if _, err := limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Errorf("expected error; got %v", err)
+	}
+
+	span := recorder.Ended()[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected error status; got %v", span.Status())
+	}
+}
+
+// Test decisions are counted by limiter and result
+func TestInstrumentRecordsMetrics(t *testing.T) {
+	instrumentation, _, _, reader := newTestInstrumentation(t)
+	stub := &stubLimiter{isAllowed: true}
+	limiter := instrumentation.Instrument("api", stub)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	limiter.IsAllowed(req)
+	limiter.IsAllowed(req)
+	stub.isAllowed = false
+	limiter.IsAllowed(req)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	counts := map[string]int64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "cerberus.decisions" {
+			continue
+		}
+		for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+			result, _ := point.Attributes.Value("cerberus.result")
+			counts[result.AsString()] = point.Value
+		}
+	}
+	if counts["allowed"] != 2 || counts["denied"] != 1 {
+		t.Errorf("expected 2 allowed and 1 denied; got %v", counts)
+	}
+}
+
+// Test instrumenting preserves whether the rate limiter is advanced
+func TestInstrumentPreservesAdvancedRateLimiter(t *testing.T) {
+	instrumentation, _, _, _ := newTestInstrumentation(t)
+
+	if _, ok := instrumentation.Instrument("basic", &stubLimiter{}).(cerberus.AdvancedRateLimiter); ok {
+		t.Errorf("expected basic rate limiter to stay basic")
+	}
+	advanced := instrumentation.Instrument("advanced", cerberus.NewFixedWindowLimiter(10, 1e9))
+	if _, ok := advanced.(cerberus.AdvancedRateLimiter); !ok {
+		t.Errorf("expected advanced rate limiter to stay advanced")
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
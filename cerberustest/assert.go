@@ -0,0 +1,72 @@
+package cerberustest
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// ServeN serves n copies of r with h, one after the other, and returns the recorded responses in
+// order, for example to exhaust the budget of a client.
+func ServeN(h http.Handler, r *http.Request, n int) []*httptest.ResponseRecorder {
+	responses := make([]*httptest.ResponseRecorder, n)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		h.ServeHTTP(responses[i], r.Clone(r.Context()))
+	}
+	return responses
+}
+
+// AssertRateLimited reports an error through t if rr is not the response to a request denied by
+// the default denied handler of the middleware, an HTTP 429 (Too Many Requests).
+func AssertRateLimited(t testing.TB, rr *httptest.ResponseRecorder) {
+	t.Helper()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status code %v; got %v", http.StatusTooManyRequests, rr.Code)
+	}
+}
+
+// AssertNotRateLimited reports an error through t if rr is the response to a request denied by
+// the default denied handler of the middleware.
+func AssertNotRateLimited(t testing.TB, rr *httptest.ResponseRecorder) {
+	t.Helper()
+	if rr.Code == http.StatusTooManyRequests {
+		t.Errorf("expected a status code other than %v", http.StatusTooManyRequests)
+	}
+}
+
+// AssertRateLimitHeaders reports an error through t if rr does not report the given limit and
+// remaining budget, in the headers of either [cerberus.HeaderStyleLegacy] or
+// [cerberus.HeaderStyleIETF].
+func AssertRateLimitHeaders(t testing.TB, rr *httptest.ResponseRecorder, limit, remaining int) {
+	t.Helper()
+	prefix := "X-RateLimit-"
+	if rr.Header().Get("RateLimit-Limit") != "" {
+		prefix = "RateLimit-"
+	}
+	if got := rr.Header().Get(prefix + "Limit"); got != strconv.Itoa(limit) {
+		t.Errorf("expected %vLimit %v; got %q", prefix, limit, got)
+	}
+	if got := rr.Header().Get(prefix + "Remaining"); got != strconv.Itoa(remaining) {
+		t.Errorf("expected %vRemaining %v; got %q", prefix, remaining, got)
+	}
+}
+
+// AssertRetryAfter reports an error through t if rr does not ask the client to retry after d, in
+// the Retry-After header, in seconds rounded up, or the X-RateLimit-Retry-After header of
+// [cerberus.HeaderStyleLegacy], in milliseconds.
+func AssertRetryAfter(t testing.TB, rr *httptest.ResponseRecorder, d time.Duration) {
+	t.Helper()
+	if got := rr.Header().Get("Retry-After"); got != "" {
+		if want := strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10); got != want {
+			t.Errorf("expected Retry-After %v; got %q", want, got)
+		}
+		return
+	}
+	if got := rr.Header().Get("X-RateLimit-Retry-After"); got != strconv.FormatInt(d.Milliseconds(), 10) {
+		t.Errorf("expected X-RateLimit-Retry-After %v; got %q", d.Milliseconds(), got)
+	}
+}
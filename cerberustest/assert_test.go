@@ -0,0 +1,49 @@
+package cerberustest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordingT is a testing.TB recording the errors reported through it.
+type recordingT struct {
+	testing.TB
+	errors int
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors++
+}
+
+// Test the assertions report mismatched responses
+func TestAssertionsReportMismatches(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("RateLimit-Limit", "10")
+	rr.Header().Set("RateLimit-Remaining", "5")
+	rr.Header().Set("Retry-After", "2")
+	rr.WriteHeader(http.StatusOK)
+
+	tests := []struct {
+		name   string
+		assert func(testing.TB)
+		errors int
+	}{
+		{"rate limited", func(t testing.TB) { AssertRateLimited(t, rr) }, 1},
+		{"not rate limited", func(t testing.TB) { AssertNotRateLimited(t, rr) }, 0},
+		{"matching headers", func(t testing.TB) { AssertRateLimitHeaders(t, rr, 10, 5) }, 0},
+		{"mismatched headers", func(t testing.TB) { AssertRateLimitHeaders(t, rr, 20, 0) }, 2},
+		{"matching retry after", func(t testing.TB) { AssertRetryAfter(t, rr, 1500*time.Millisecond) }, 0},
+		{"mismatched retry after", func(t testing.TB) { AssertRetryAfter(t, rr, 5*time.Second) }, 1},
+	}
+	for _, test := range tests {
+		recorder := &recordingT{TB: t}
+		test.assert(recorder)
+		if recorder.errors != test.errors {
+			t.Errorf("%s: expected %v errors; got %v", test.name, test.errors, recorder.errors)
+		}
+	}
+}
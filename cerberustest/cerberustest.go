@@ -0,0 +1,119 @@
+// Package cerberustest provides utilities for testing services that use cerberus, so that their
+// throttling behavior can be tested without a real rate limiter or real time:
+//   - [FakeLimiter] is a rate limiter following a script of decisions and recording the requests it
+//     checks.
+//   - [Clock] is a clock that only moves when told to, for the built-in limiters.
+//   - [AssertRateLimited], [AssertNotRateLimited], and [AssertRateLimitHeaders] check the responses
+//     recorded with [httptest.ResponseRecorder].
+//
+// For example, to check that a handler denies the third request of a client:
+//
+//	limiter := cerberustest.NewFakeLimiter(cerberustest.Allow, cerberustest.Allow, cerberustest.Deny)
+//	handler := cerberus.New(limiter)(myHandler)
+//	responses := cerberustest.ServeN(handler, httptest.NewRequest(http.MethodGet, "/", nil), 3)
+//	cerberustest.AssertNotRateLimited(t, responses[1])
+//	cerberustest.AssertRateLimited(t, responses[2])
+package cerberustest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Decision is the outcome of a rate limiting check scripted for a [FakeLimiter].
+type Decision struct {
+	// Allowed reports whether the request is allowed.
+	Allowed bool
+	// Err is the error the check fails with, if any.
+	Err error
+}
+
+// Decisions commonly scripted for a [FakeLimiter].
+var (
+	// Allow allows the request.
+	Allow = Decision{Allowed: true}
+	// Deny denies the request.
+	Deny = Decision{}
+)
+
+// Fail returns a [Decision] failing the check with err, as a rate limiter whose store is
+// unavailable would.
+func Fail(err error) Decision {
+	return Decision{Err: err}
+}
+
+// Repeat returns a script of n times the decision d, for example to allow a number of requests
+// before denying the others: append(Repeat(Allow, 10), Deny).
+func Repeat(d Decision, n int) []Decision {
+	script := make([]Decision, n)
+	for i := range script {
+		script[i] = d
+	}
+	return script
+}
+
+// FakeLimiter is a [cerberus.RateLimiter] and [cerberus.AdvancedRateLimiter] that decides on the
+// requests it checks by following a script, and records them for inspection.
+//
+// Behavior:
+//   - The n-th request checked gets the n-th decision of the script.
+//   - Once the script runs out, the last decision is repeated. A FakeLimiter with an empty script
+//     allows all requests.
+//   - GetRateLimitData returns the data set with SetData, the zero RateLimitData by default, which
+//     makes the middleware omit rate limit headers.
+//
+// A FakeLimiter is safe for concurrent use by multiple goroutines.
+type FakeLimiter struct {
+	mu     sync.Mutex
+	script []Decision
+	calls  []*http.Request
+	data   cerberus.RateLimitData
+}
+
+// NewFakeLimiter creates a new [FakeLimiter] following script.
+func NewFakeLimiter(script ...Decision) *FakeLimiter {
+	return &FakeLimiter{script: append([]Decision(nil), script...)}
+}
+
+// IsAllowed records the request and returns the next decision of the script.
+func (l *FakeLimiter) IsAllowed(r *http.Request) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decision := Allow
+	if n := len(l.script); n > 0 {
+		decision = l.script[min(len(l.calls), n-1)]
+	}
+	l.calls = append(l.calls, r)
+	return decision.Allowed, decision.Err
+}
+
+// GetRateLimitData returns the data set with SetData.
+func (l *FakeLimiter) GetRateLimitData(*http.Request) cerberus.RateLimitData {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data
+}
+
+// SetData sets the data returned by GetRateLimitData, which the middleware reports in rate limit
+// headers.
+func (l *FakeLimiter) SetData(data cerberus.RateLimitData) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data = data
+}
+
+// Calls returns the requests checked so far, in order.
+func (l *FakeLimiter) Calls() []*http.Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*http.Request(nil), l.calls...)
+}
+
+// Reset forgets the requests checked so far, and starts the script over.
+func (l *FakeLimiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
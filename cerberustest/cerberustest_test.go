@@ -0,0 +1,60 @@
+package cerberustest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Test the fake limiter follows its script and repeats the last decision
+func TestFakeLimiterFollowsScript(t *testing.T) {
+	errStore := errors.New("store unavailable")
+	limiter := NewFakeLimiter(append(Repeat(Allow, 2), Fail(errStore), Deny)...)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	want := []Decision{Allow, Allow, Fail(errStore), Deny, Deny}
+	for i, decision := range want {
+		isAllowed, err := limiter.IsAllowed(req)
+		if isAllowed != decision.Allowed || err != decision.Err {
+			t.Errorf("request %d: expected %v, %v; got %v, %v", i+1, decision.Allowed, decision.Err, isAllowed, err)
+		}
+	}
+	if calls := limiter.Calls(); len(calls) != 5 || calls[0] != req {
+		t.Errorf("expected 5 recorded calls; got %v", len(calls))
+	}
+	limiter.Reset()
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed || len(limiter.Calls()) != 1 {
+		t.Errorf("expected the script to start over after a reset")
+	}
+}
+
+// Test a fake limiter without a script allows all requests
+func TestFakeLimiterWithoutScript(t *testing.T) {
+	limiter := NewFakeLimiter()
+
+	for range 3 {
+		if isAllowed, err := limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/", nil)); !isAllowed || err != nil {
+			t.Errorf("expected the request to be allowed; got %v, %v", isAllowed, err)
+		}
+	}
+}
+
+// Test the fake limiter drives the middleware, including its headers
+func TestFakeLimiterWithMiddleware(t *testing.T) {
+	limiter := NewFakeLimiter(Allow, Deny)
+	limiter.SetData(cerberus.RateLimitData{Limit: 10, Remaining: 0, RetryAfter: 1500 * time.Millisecond})
+	handler := cerberus.New(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	responses := ServeN(handler, httptest.NewRequest(http.MethodGet, "/", nil), 2)
+
+	AssertNotRateLimited(t, responses[0])
+	AssertRateLimitHeaders(t, responses[0], 10, 0)
+	AssertRateLimited(t, responses[1])
+	AssertRetryAfter(t, responses[1], 1500*time.Millisecond)
+}
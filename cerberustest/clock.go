@@ -0,0 +1,139 @@
+package cerberustest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Clock is a [cerberus.Clock] that only moves when told to, so that tests of time-based limiters
+// are deterministic and need not sleep. Pass it to the built-in limiters with [cerberus.WithClock].
+//
+// Behavior:
+//   - Now returns the time the clock was created with, moved by Advance, Set, and Sleep.
+//   - Sleep advances the clock by the given duration and returns immediately, as if the calling
+//     goroutine had slept.
+//   - Timers created with NewTimer fire when the clock is moved past their deadline. Tests of code
+//     waiting on a timer in another goroutine can call WaitForTimers before moving the clock.
+//
+// A Clock must be created with [NewClock]. It is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	clock := cerberustest.NewClock(time.Time{})
+//	limiter := cerberus.NewFixedWindowLimiter(10, time.Minute, cerberus.WithClock(clock))
+//	// Use up the budget of a client, then:
+//	clock.Advance(time.Minute)
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  map[*timer]struct{}
+}
+
+// timer is a [cerberus.Timer] created by a [Clock].
+type timer struct {
+	clock *Clock
+	c     chan time.Time
+	at    time.Time
+}
+
+// NewClock creates a new [Clock] set to start. If start is the zero time, the clock is set to an
+// arbitrary fixed time instead, in the middle of a one minute window.
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = time.Date(2024, time.January, 1, 0, 0, 30, 0, time.UTC)
+	}
+	c := &Clock{now: start, timers: make(map[*timer]struct{})}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time the clock is set to.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d, firing the timers due by then.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set sets the clock to t, which may be in the past, firing the timers due by then.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+// Sleep advances the clock by d.
+func (c *Clock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// NewTimer creates a new timer firing once the clock has moved by d.
+func (c *Clock) NewTimer(d time.Duration) cerberus.Timer {
+	t := &timer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// WaitForTimers blocks until at least n timers are waiting to fire, for example until code under
+// test running in another goroutine is waiting for the clock to move.
+func (c *Clock) WaitForTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// set sets the clock to now and fires the timers due by then. The caller must hold c.mu.
+func (c *Clock) set(now time.Time) {
+	c.now = now
+	for t := range c.timers {
+		if !t.at.After(now) {
+			c.fire(t)
+		}
+	}
+}
+
+// fire sends the current time on the channel of t and deactivates it. The caller must hold c.mu.
+func (c *Clock) fire(t *timer) {
+	delete(c.timers, t)
+	select {
+	case t.c <- c.now:
+	default:
+	}
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, active := c.timers[t]
+	t.at = c.now.Add(d)
+	c.timers[t] = struct{}{}
+	if d <= 0 {
+		c.fire(t)
+	}
+	c.changed.Broadcast()
+	return active
+}
@@ -0,0 +1,96 @@
+package cerberustest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Test the clock only moves when told to
+func TestClock(t *testing.T) {
+	start := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected %v; got %v", start, now)
+	}
+	clock.Advance(time.Minute)
+	if now := clock.Now(); !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected %v; got %v", start.Add(time.Minute), now)
+	}
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected %v; got %v", start, now)
+	}
+}
+
+// Test built-in limiters tell the time with the clock
+func TestClockWithLimiter(t *testing.T) {
+	clock := NewClock(time.Time{})
+	limiter := cerberus.NewFixedWindowLimiter(1, time.Minute, cerberus.WithClock(clock))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	limiter.IsAllowed(req)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected the second request in the window to be denied")
+	}
+	if data := limiter.GetRateLimitData(req); data.RetryAfter != 30*time.Second {
+		t.Errorf("expected a retry after 30s; got %v", data.RetryAfter)
+	}
+	clock.Advance(30 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected a request in the next window to be allowed")
+	}
+}
+
+// Test timers fire once the clock moves past their deadline
+func TestClockTimers(t *testing.T) {
+	clock := NewClock(time.Time{})
+	timer := clock.NewTimer(time.Second)
+	stopped := clock.NewTimer(time.Second)
+	stopped.Stop()
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Errorf("expected the timer not to fire before its deadline")
+	default:
+	}
+	clock.Sleep(time.Millisecond)
+	select {
+	case fired := <-timer.C():
+		if !fired.Equal(clock.Now()) {
+			t.Errorf("expected the timer to fire at %v; got %v", clock.Now(), fired)
+		}
+	default:
+		t.Errorf("expected the timer to fire at its deadline")
+	}
+	select {
+	case <-stopped.C():
+		t.Errorf("expected a stopped timer not to fire")
+	default:
+	}
+}
+
+// Test wait mode waits by the clock of the rate limiter
+func TestClockWithWaitMode(t *testing.T) {
+	clock := NewClock(time.Time{})
+	limiter := cerberus.NewTokenBucketLimiter(1, 1, cerberus.WithClock(clock))
+	handler := cerberus.New(limiter, cerberus.WithWaitMode(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ServeN(handler, req, 1)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- ServeN(handler, req, 1)[0]
+	}()
+	clock.WaitForTimers(1)
+	clock.Advance(time.Second)
+
+	AssertNotRateLimited(t, <-done)
+}
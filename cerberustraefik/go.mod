@@ -0,0 +1,9 @@
+module github.com/mxmlkzdh/cerberus/cerberustraefik
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require github.com/mxmlkzdh/cerberus v0.0.0
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
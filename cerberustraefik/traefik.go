@@ -0,0 +1,103 @@
+// Package cerberustraefik is a Traefik middleware plugin applying rate limiting with cerberus rate
+// limiters, so that services behind Traefik can be rate limited without custom glue.
+//
+// The plugin follows the conventions of Traefik plugins: [CreateConfig] returns the default
+// configuration, which Traefik fills from the dynamic configuration, and [New] creates the
+// middleware. The configuration mirrors [cerberus.MiddlewareConfig], with windows written as
+// duration strings:
+//
+//	http:
+//	  middlewares:
+//	    limits:
+//	      plugin:
+//	        cerberus:
+//	          headerStyle: ietf
+//	          default: {key: forwarded_for, algorithm: token_bucket, limit: 100, window: 1m}
+//	          routes:
+//	            - {pattern: "POST /login", algorithm: sliding_window, limit: 5, window: 1m}
+//
+// Limits can also be kept in a JSON or YAML file of their own, read by [cerberus.LoadConfig], with
+// configFile. Requests reach Traefik's middlewares from the entry point, so limits keyed by client
+// address need the "forwarded_for" key when Traefik itself runs behind a proxy.
+package cerberustraefik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Config is the configuration of the plugin. Exactly one of ConfigFile and the limits, Default
+// and Routes, must be set.
+type Config struct {
+	// ConfigFile is the path of a JSON or YAML file describing the limits. See
+	// [cerberus.LoadConfig].
+	ConfigFile string `json:"-"`
+
+	// HeaderStyle is the set of rate limit headers added to responses: "legacy" (the default),
+	// "ietf", or "none".
+	HeaderStyle string `json:"header_style,omitempty"`
+
+	// Default is the limit of requests that match no route, if any.
+	Default *Route `json:"default,omitempty"`
+
+	// Routes are the limits of specific routes, in order of precedence.
+	Routes []Route `json:"routes,omitempty"`
+}
+
+// Route describes the limit of a route, as a [cerberus.RouteConfig] does.
+type Route struct {
+	Pattern   string           `json:"pattern,omitempty"`
+	Key       string           `json:"key,omitempty"`
+	Algorithm string           `json:"algorithm,omitempty"`
+	Limit     int              `json:"limit,omitempty"`
+	Window    string           `json:"window,omitempty"`
+	Methods   map[string]Route `json:"methods,omitempty"`
+}
+
+// CreateConfig returns the default configuration of the plugin, which has no limits.
+func CreateConfig() *Config {
+	return &Config{}
+}
+
+// New creates the middleware named name, applying the limits of config to the requests passed to
+// next. Denied requests are answered with an HTTP 429 (Too Many Requests). An error wrapping
+// [cerberus.ErrInvalidConfig] is returned if config does not describe valid limits.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	handle, err := config.middleware()
+	if err != nil {
+		return nil, fmt.Errorf("cerberus middleware %q: %w", name, err)
+	}
+	return handle.Wrap(next), nil
+}
+
+// middleware creates the middleware enforcing the limits of the config.
+func (c *Config) middleware() (*cerberus.Handle, error) {
+	hasLimits := c.Default != nil || len(c.Routes) > 0 || c.HeaderStyle != ""
+	if c.ConfigFile != "" {
+		if hasLimits {
+			return nil, fmt.Errorf("%w: configFile cannot be set along with limits", cerberus.ErrInvalidConfig)
+		}
+		return cerberus.LoadConfig(c.ConfigFile)
+	}
+	if !hasLimits {
+		return nil, fmt.Errorf("%w: either configFile or limits must be set", cerberus.ErrInvalidConfig)
+	}
+	// The limits are converted through JSON, where windows are duration strings.
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var config cerberus.MiddlewareConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		if errors.Is(err, cerberus.ErrInvalidConfig) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", cerberus.ErrInvalidConfig, err)
+	}
+	return config.Middleware()
+}
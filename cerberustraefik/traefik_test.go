@@ -0,0 +1,89 @@
+package cerberustraefik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// newTestMiddleware creates the middleware of config in front of a handler counting the requests
+// reaching it.
+func newTestMiddleware(t *testing.T, config *Config, reached *int) http.Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler, err := New(context.Background(), next, config, "limits")
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	return handler
+}
+
+// serve sends a request for method and target to handler, and returns the status code.
+func serve(handler http.Handler, method, target string) int {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	handler.ServeHTTP(rr, r)
+	return rr.Code
+}
+
+// Test the limits of the configuration are applied to the routes they match
+func TestNew(t *testing.T) {
+	config := CreateConfig()
+	config.Default = &Route{Algorithm: "fixed_window", Limit: 2, Window: "1m"}
+	config.Routes = []Route{{Pattern: "/login", Methods: map[string]Route{
+		"POST": {Algorithm: "fixed_window", Limit: 1, Window: "1m"},
+	}}}
+	reached := 0
+	handler := newTestMiddleware(t, config, &reached)
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve(handler, http.MethodPost, "/login"); code != expected {
+			t.Errorf("expected status %d for login %d; got %d", expected, i+1, code)
+		}
+	}
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve(handler, http.MethodGet, "/"); code != expected {
+			t.Errorf("expected status %d for request %d; got %d", expected, i+1, code)
+		}
+	}
+	if reached != 3 {
+		t.Errorf("expected 3 requests to reach the next handler; got %d", reached)
+	}
+}
+
+// Test the limits are loaded from configFile
+func TestNewConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	os.WriteFile(path, []byte("default: {algorithm: fixed_window, limit: 1, window: 1m}\n"), 0o600)
+	reached := 0
+	handler := newTestMiddleware(t, &Config{ConfigFile: path}, &reached)
+
+	serve(handler, http.MethodGet, "/")
+	if code := serve(handler, http.MethodGet, "/"); code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d; got %d", http.StatusTooManyRequests, code)
+	}
+}
+
+// Test invalid configurations are rejected
+func TestNewInvalid(t *testing.T) {
+	next := http.NotFoundHandler()
+	for name, config := range map[string]*Config{
+		"empty":  CreateConfig(),
+		"both":   {ConfigFile: "limits.yaml", Default: &Route{Limit: 1, Window: "1m"}},
+		"window": {Default: &Route{Limit: 1, Window: "soon"}},
+		"limit":  {Default: &Route{Limit: -1, Window: "1m"}},
+	} {
+		if _, err := New(context.Background(), next, config, "limits"); !errors.Is(err, cerberus.ErrInvalidConfig) {
+			t.Errorf("expected %s config to be rejected with %v; got %v", name, cerberus.ErrInvalidConfig, err)
+		}
+	}
+}
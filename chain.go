@@ -0,0 +1,137 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+)
+
+// RefundableRateLimiter is an extended version of the [RateLimiter] interface for rate limiters
+// that can give back the budget consumed by a request they allowed, for example because a
+// [ChainLimiter] denied the request after all. All built-in limiters implement it.
+type RefundableRateLimiter interface {
+	RateLimiter
+	// RefundRequest gives back the budget consumed by the request, which was allowed earlier. The
+	// refund must not raise the budget above its maximum.
+	RefundRequest(ctx context.Context, r *http.Request) error
+}
+
+// ChainLimiter is a [RateLimiter] and [AdvancedRateLimiter] composing several rate limiters with
+// AND semantics: a request is allowed only if every one of them allows it. This expresses limits
+// at several time scales and levels at once, such as 10 requests per second and 1000 per hour for
+// each client, and 100000 per minute for the whole service.
+//
+// Behavior:
+//   - The rate limiters are consulted in order, and consulting stops at the first one that denies
+//     the request or fails.
+//   - The budget consumed by the rate limiters that allowed the request before that is refunded,
+//     if they implement [RefundableRateLimiter], so that denied requests do not count against any
+//     limit. Refunds are best effort: failed refunds are ignored.
+//
+// Each rate limiter consumes and refunds budget atomically, but the chain as a whole is not a
+// transaction: concurrent requests may briefly observe budget that is about to be refunded. The
+// cheapest and most restrictive rate limiters should come first, so that most denied requests
+// touch as few stores as possible.
+//
+// A ChainLimiter is safe for concurrent use by multiple goroutines if its rate limiters are.
+//
+// Example usage:
+//
+//	perClient := NewChainLimiter(
+//		NewTokenBucketLimiter(10, 10),
+//		NewFixedWindowLimiter(1000, time.Hour),
+//		NewFixedWindowLimiter(100000, time.Minute, WithKeyFunc(func(*http.Request) (string, error) { return "global", nil })),
+//	)
+type ChainLimiter struct {
+	rateLimiters []RateLimiter
+}
+
+// NewChainLimiter creates a new [ChainLimiter] that allows a request only if all of rateLimiters
+// allow it.
+func NewChainLimiter(rateLimiters ...RateLimiter) *ChainLimiter {
+	return &ChainLimiter{rateLimiters: append([]RateLimiter(nil), rateLimiters...)}
+}
+
+// IsAllowed checks the request against each rate limiter in turn. It returns true if all of them
+// allowed it, false otherwise. An error is returned if a rate limiter fails, in which case the
+// budget consumed by the preceding rate limiters is refunded as well.
+func (l *ChainLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiters.
+func (l *ChainLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	for i, rateLimiter := range l.rateLimiters {
+		isAllowed, err := isAllowed(ctx, rateLimiter, r)
+		if err != nil || !isAllowed {
+			refund(ctx, l.rateLimiters[:i], r)
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// RefundRequest gives back the budget consumed by the request from every rate limiter that
+// implements [RefundableRateLimiter]. The first error encountered is returned, after attempting
+// all refunds.
+func (l *ChainLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return refund(ctx, l.rateLimiters, r)
+}
+
+// GetRateLimitData returns the rate limit data of the most restrictive rate limiter, the one with
+// the fewest remaining requests. RetryAfter is the longest time any rate limiter asks the client to
+// wait, and ResetAt the latest time any rate limiter restores the budget of the client. Rate
+// limiters that do not implement [AdvancedRateLimiter] are ignored.
+func (l *ChainLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	var data RateLimitData
+	for _, rateLimiter := range l.rateLimiters {
+		advanced, ok := rateLimiter.(AdvancedRateLimiter)
+		if !ok {
+			continue
+		}
+		d := advanced.GetRateLimitData(r)
+		if d.Limit == 0 {
+			continue
+		}
+		retryAfter := max(data.RetryAfter, d.RetryAfter)
+		resetAt := laterOf(data.ResetAt, d.ResetAt)
+		if data.Limit == 0 || d.Remaining < data.Remaining || d.Remaining == data.Remaining && d.Limit < data.Limit {
+			data = d
+		}
+		data.RetryAfter = retryAfter
+		data.ResetAt = resetAt
+	}
+	return data
+}
+
+// Close closes the rate limiters of the chain implementing [Closer], in order. It implements
+// Closer.
+func (l *ChainLimiter) Close(ctx context.Context) error {
+	return closeAll(ctx, l.rateLimiters...)
+}
+
+// key returns the key identifying the client making the request, as reported by the first rate
+// limiter that reports one.
+func (l *ChainLimiter) key(r *http.Request) (string, error) {
+	for _, rateLimiter := range l.rateLimiters {
+		if k, ok := rateLimiter.(keyer); ok {
+			return k.key(r)
+		}
+	}
+	return "", ErrNoKey
+}
+
+// refund gives back the budget consumed by r from each of rateLimiters that implements
+// [RefundableRateLimiter], and returns the first error encountered.
+func refund(ctx context.Context, rateLimiters []RateLimiter, r *http.Request) error {
+	var firstErr error
+	for _, rateLimiter := range rateLimiters {
+		refundable, ok := rateLimiter.(RefundableRateLimiter)
+		if !ok {
+			continue
+		}
+		if err := refundable.RefundRequest(ctx, r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,106 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test the chain allows a request only if every rate limiter allows it
+func TestChainLimiterRequiresAll(t *testing.T) {
+	limiter := NewChainLimiter(
+		NewFixedWindowLimiter(3, time.Minute),
+		NewFixedWindowLimiter(2, time.Hour),
+	)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the second limit to be denied")
+	}
+}
+
+// Test budget consumed by earlier rate limiters is refunded when a later one denies
+func TestChainLimiterRefundsOnDenial(t *testing.T) {
+	clock := newFakeClock()
+	builtins := map[string]AdvancedRateLimiter{}
+	tokenBucket := NewTokenBucketLimiter(2, 1)
+	tokenBucket.now = clock.Now
+	builtins["token bucket"] = tokenBucket
+	slidingWindow := NewSlidingWindowLimiter(2, time.Minute)
+	slidingWindow.now = clock.Now
+	builtins["sliding window"] = slidingWindow
+	fixedWindow := NewFixedWindowLimiter(2, time.Minute)
+	fixedWindow.now = clock.Now
+	builtins["fixed window"] = fixedWindow
+	leakyBucket := NewLeakyBucketLimiter(2, 1)
+	leakyBucket.now = clock.Now
+	builtins["leaky bucket"] = leakyBucket
+	gcra := NewGCRALimiter(time.Second, time.Second)
+	gcra.now = clock.Now
+	builtins["GCRA"] = gcra
+
+	deny := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	for name, builtin := range builtins {
+		req := newRequestFrom("192.0.2.1:1234")
+		limiter := NewChainLimiter(builtin, deny)
+
+		for range 3 {
+			limiter.IsAllowed(req)
+		}
+
+		if data := builtin.GetRateLimitData(req); data.Remaining != 2 {
+			t.Errorf("%s: expected denied requests to be refunded; got %+v", name, data)
+		}
+	}
+}
+
+// Test errors stop the chain and are returned
+func TestChainLimiterError(t *testing.T) {
+	storeErr := errors.New("store unavailable")
+	first := NewFixedWindowLimiter(5, time.Minute)
+	limiter := NewChainLimiter(first, &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, storeErr
+		},
+	})
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if _, err := limiter.IsAllowed(req); !errors.Is(err, storeErr) {
+		t.Errorf("expected store error; got %v", err)
+	}
+	if data := first.GetRateLimitData(req); data.Remaining != 5 {
+		t.Errorf("expected budget to be refunded; got %+v", data)
+	}
+}
+
+// Test the chain reports the data of the most restrictive rate limiter
+func TestChainLimiterGetRateLimitData(t *testing.T) {
+	limiter := NewChainLimiter(
+		&MockAdvancedRateLimiter{
+			GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+				return RateLimitData{Limit: 10, Remaining: 0, RetryAfter: time.Second}
+			},
+		},
+		&MockAdvancedRateLimiter{
+			GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+				return RateLimitData{Limit: 1000, Remaining: 0, RetryAfter: time.Hour}
+			},
+		},
+	)
+
+	data := limiter.GetRateLimitData(newRequestFrom("192.0.2.1:1234"))
+
+	if data.Limit != 10 || data.Remaining != 0 || data.RetryAfter != time.Hour {
+		t.Errorf("expected {10 0 1h}; got %+v", data)
+	}
+}
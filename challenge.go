@@ -0,0 +1,87 @@
+package cerberus
+
+import "net/http"
+
+// ChallengeHandler lets clients exceeding the rate limit prove they are legitimate, such as by
+// solving a CAPTCHA or a proof-of-work puzzle, instead of waiting for their budget to recover. It
+// is installed with [WithChallengeHandler].
+type ChallengeHandler struct {
+	// Challenge responds to requests exceeding the rate limit in place of the denied handler, for
+	// example by redirecting to a CAPTCHA page, or by responding with a proof-of-work puzzle. The
+	// handler is responsible for writing the status code. If Challenge is nil, the denied handler
+	// responds as usual.
+	Challenge http.Handler
+
+	// Verify reports whether r carries a valid solution to a challenge, such as a CAPTCHA token
+	// validated with its provider, or a proof-of-work nonce. It is called for every request before
+	// it is checked against the rate limiter, so it must return false quickly for requests without
+	// a solution, and must reject solutions that were already used, otherwise a single solution
+	// could restore the budget of a client indefinitely. An error is handled like a failed
+	// verification, and logged with [WithLogger].
+	Verify func(r *http.Request) (bool, error)
+
+	// OnPass is called with the key of a client that passed a challenge, once its budget was
+	// restored, so that its limit can also be raised, for example by moving it to a higher plan of
+	// a [TieredLimiter]. It is optional.
+	OnPass func(r *http.Request, key string)
+}
+
+// WithChallengeHandler challenges the clients exceeding the rate limit, and restores the full
+// budget of those passing the challenge. It requires a rate limiter implementing [ManagedLimiter],
+// such as the built-in limiters, which restores the budget with its Reset method.
+//
+// Behavior:
+//   - A request exceeding the rate limit is passed to the Challenge handler of handler, in place
+//     of the denied handler. Rate limit headers are set on the response as for other denied
+//     requests.
+//   - A request passing the Verify function of handler restores the full budget of the client
+//     making it, identified by the key reported by [KeyOf], and lifts any block, including bans of
+//     [WithPenaltyPolicy], whose denials start over, before the request is checked against the
+//     rate limiter. OnPass is then called.
+//
+// It panics if the Verify function of handler is nil. [New] panics if the rate limiter does not
+// implement [ManagedLimiter].
+//
+// Example usage:
+//
+//	challenge := ChallengeHandler{
+//		Challenge: http.RedirectHandler("/captcha", http.StatusSeeOther),
+//		Verify:    verifyCaptchaToken,
+//	}
+//	http.Handle("/", New(myRateLimiter, WithChallengeHandler(challenge))(myHandler))
+func WithChallengeHandler(handler ChallengeHandler) Option {
+	if handler.Verify == nil {
+		panic("cerberus: challenge verify function must not be nil")
+	}
+	return func(o *options) {
+		o.challenge = &handler
+	}
+}
+
+// verifyChallenge restores the budget of the client making r with rateLimiter if r passes the
+// challenge.
+func (o *options) verifyChallenge(r *http.Request, rateLimiter ManagedLimiter) {
+	passed, err := o.challenge.Verify(r)
+	if err != nil {
+		o.logError(r, rateLimiter, err)
+		return
+	}
+	if !passed {
+		return
+	}
+	key, err := KeyOf(rateLimiter, r)
+	if err != nil {
+		return
+	}
+	err = rateLimiter.Reset(r.Context(), key)
+	if err == nil && o.penalty != nil {
+		err = o.penalty.pardon(r, key)
+	}
+	if err != nil {
+		o.logError(r, rateLimiter, err)
+		return
+	}
+	if o.challenge.OnPass != nil {
+		o.challenge.OnPass(r, key)
+	}
+}
@@ -0,0 +1,119 @@
+package cerberus
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newChallengeRequest returns a request from 192.0.2.1, carrying solution as the
+// solution to a challenge if it is not empty.
+func newChallengeRequest(solution string) *http.Request {
+	req := newRequestFrom("192.0.2.1:1234")
+	if solution != "" {
+		req.Header.Set("X-Challenge", solution)
+	}
+	return req
+}
+
+// Test denied requests are challenged, and passing the challenge restores the budget
+func TestWithChallengeHandler(t *testing.T) {
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	var passed []string
+	challenge := ChallengeHandler{
+		Challenge: http.RedirectHandler("/captcha", http.StatusSeeOther),
+		Verify: func(r *http.Request) (bool, error) {
+			return r.Header.Get("X-Challenge") == "solved", nil
+		},
+		OnPass: func(r *http.Request, key string) {
+			passed = append(passed, key)
+		},
+	}
+	handler := New(limiter, WithChallengeHandler(challenge))(noContent)
+
+	codes := make([]int, 0, 4)
+	for _, solution := range []string{"", "", "wrong", "solved"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newChallengeRequest(solution))
+		codes = append(codes, rr.Code)
+	}
+
+	expected := []int{http.StatusNoContent, http.StatusSeeOther, http.StatusSeeOther, http.StatusNoContent}
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("expected status codes %v; got %v", expected, codes)
+			break
+		}
+	}
+	if len(passed) != 1 || passed[0] != "192.0.2.1" {
+		t.Errorf("expected OnPass to be called for 192.0.2.1; got %v", passed)
+	}
+}
+
+// Test passing a challenge lifts a ban of the penalty policy
+func TestChallengeHandlerLiftsBan(t *testing.T) {
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	challenge := ChallengeHandler{
+		Verify: func(r *http.Request) (bool, error) {
+			return r.Header.Get("X-Challenge") == "solved", nil
+		},
+	}
+	policy := PenaltyPolicy{Threshold: 1, Window: time.Minute, Ban: time.Hour}
+	handler := New(limiter, WithPenaltyPolicy(policy), WithChallengeHandler(challenge))(noContent)
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), newChallengeRequest(""))
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newChallengeRequest("solved"))
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected the banned client to be allowed after passing the challenge; got %v", rr.Code)
+	}
+}
+
+// Test verification errors are logged and handled like failed verifications
+func TestChallengeHandlerVerifyError(t *testing.T) {
+	var buf bytes.Buffer
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	challenge := ChallengeHandler{
+		Verify: func(r *http.Request) (bool, error) {
+			return false, errors.New("captcha provider unavailable")
+		},
+	}
+	handler := New(limiter, WithChallengeHandler(challenge), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))(noContent)
+
+	handler.ServeHTTP(httptest.NewRecorder(), newChallengeRequest("solved"))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newChallengeRequest("solved"))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", rr.Code)
+	}
+	if !strings.Contains(buf.String(), "captcha provider unavailable") {
+		t.Errorf("expected the error to be logged; got %s", buf.String())
+	}
+}
+
+// Test challenge handlers without a verify function, and rate limiters that cannot reset clients, panic
+func TestChallengeHandlerPanics(t *testing.T) {
+	verify := func(r *http.Request) (bool, error) { return false, nil }
+	for name, fn := range map[string]func(){
+		"nil verify":        func() { WithChallengeHandler(ChallengeHandler{}) },
+		"unmanaged limiter": func() { New(&MockRateLimiter{}, WithChallengeHandler(ChallengeHandler{Verify: verify})) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
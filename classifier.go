@@ -0,0 +1,257 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crawlerVerificationTTL is how long a [CrawlerVerifier] caches the result of verifying an
+// address.
+const crawlerVerificationTTL = time.Hour
+
+// Class is the kind of client making a request, as determined by a [ClassifierFunc].
+type Class string
+
+const (
+	// ClassHuman is the class of requests that look like they come from a person using a browser
+	// or an app.
+	ClassHuman Class = "human"
+
+	// ClassSuspectedBot is the class of requests that look automated, such as those of scrapers,
+	// command line tools, or clients impersonating search engine crawlers.
+	ClassSuspectedBot Class = "bot"
+
+	// ClassVerifiedCrawler is the class of requests from search engine crawlers whose identity was
+	// verified, such as Googlebot.
+	ClassVerifiedCrawler Class = "crawler"
+)
+
+// ClassifierFunc determines the class of the client making a request, so that requests can be
+// checked against a rate limiter per class with [NewClassLimiter]. Custom classes may be defined
+// alongside the built-in ones, for example from the score of a bot management service.
+type ClassifierFunc func(*http.Request) Class
+
+// NewClassLimiter creates a new [TieredLimiter] that determines the class of each request with
+// classify and checks it against the rate limiter of that class in classes, so that suspected bots
+// get a stricter limit than humans, and verified crawlers a generous one.
+//
+// Requests of a class without a rate limiter are checked against the rate limiter of
+// [ClassHuman]. An error wrapping [ErrUnknownTier] is returned if there is none either.
+//
+// Example usage:
+//
+//	limiter := NewClassLimiter(HeuristicClassifier(NewCrawlerVerifier(nil)), map[Class]RateLimiter{
+//		ClassHuman:           NewTokenBucketLimiter(10, 20),
+//		ClassSuspectedBot:    NewTokenBucketLimiter(1, 5),
+//		ClassVerifiedCrawler: NewTokenBucketLimiter(50, 100),
+//	})
+func NewClassLimiter(classify ClassifierFunc, classes map[Class]RateLimiter) *TieredLimiter {
+	tiers := make(map[string]RateLimiter, len(classes))
+	for class, rateLimiter := range classes {
+		tiers[string(class)] = rateLimiter
+	}
+	return NewTieredLimiter(func(r *http.Request) (string, error) {
+		class := classify(r)
+		if _, ok := classes[class]; !ok {
+			class = ClassHuman
+		}
+		return string(class), nil
+	}, tiers)
+}
+
+// crawler is a search engine crawler, identified by a token of its User-Agent header, whose
+// addresses resolve to host names in one of its domains.
+type crawler struct {
+	token   string
+	domains []string
+}
+
+// knownCrawlers are the crawlers that [CrawlerVerifier] can verify, with the domains their
+// operators document for reverse DNS verification.
+var knownCrawlers = []crawler{
+	{"googlebot", []string{"googlebot.com", "google.com"}},
+	{"google-inspectiontool", []string{"googlebot.com", "google.com"}},
+	{"bingbot", []string{"search.msn.com"}},
+	{"applebot", []string{"applebot.apple.com"}},
+	{"yandexbot", []string{"yandex.ru", "yandex.net", "yandex.com"}},
+	{"baiduspider", []string{"baidu.com", "baidu.jp"}},
+}
+
+// botUserAgentTokens are lowercase tokens of the User-Agent headers of automated clients, such as
+// crawlers, HTTP libraries, command line tools, and headless browsers.
+var botUserAgentTokens = []string{
+	"bot", "crawl", "spider", "scrape", "slurp", "curl/", "wget/", "httpie/", "python-requests",
+	"python-urllib", "aiohttp", "go-http-client", "java/", "okhttp", "apache-httpclient",
+	"libwww-perl", "node-fetch", "axios/", "headlesschrome", "phantomjs", "puppeteer",
+	"playwright", "selenium",
+}
+
+// claimedCrawler returns the crawler whose token is in userAgent, in lowercase, if any.
+func claimedCrawler(userAgent string) (crawler, bool) {
+	for _, c := range knownCrawlers {
+		if strings.Contains(userAgent, c.token) {
+			return c, true
+		}
+	}
+	return crawler{}, false
+}
+
+// HeuristicClassifier returns a [ClassifierFunc] that tells humans and bots apart with the headers
+// of requests. It suits websites visited with browsers: APIs, whose clients are legitimately
+// automated, should classify requests by their credentials instead.
+//
+// Behavior:
+//   - Requests whose User-Agent header claims to be a known search engine crawler, such as
+//     Googlebot or Bingbot, are of class [ClassVerifiedCrawler] if verifier confirms the claim,
+//     and of class [ClassSuspectedBot] otherwise, since the User-Agent header is easily forged.
+//     With a nil verifier, no crawler is verified.
+//   - Requests without a User-Agent header, or whose User-Agent header is the one of an automated
+//     client, such as curl, an HTTP library, or a headless browser, are of class
+//     [ClassSuspectedBot].
+//   - Requests missing an Accept or Accept-Language header, which browsers always send, are of
+//     class [ClassSuspectedBot].
+//   - Other requests are of class [ClassHuman].
+//
+// Example usage: NewClassLimiter(HeuristicClassifier(NewCrawlerVerifier(nil)), limiters)
+func HeuristicClassifier(verifier *CrawlerVerifier) ClassifierFunc {
+	return func(r *http.Request) Class {
+		userAgent := strings.ToLower(r.UserAgent())
+		if _, ok := claimedCrawler(userAgent); ok {
+			if verifier != nil && verifier.Verify(r) {
+				return ClassVerifiedCrawler
+			}
+			return ClassSuspectedBot
+		}
+		if userAgent == "" || slices.ContainsFunc(botUserAgentTokens, func(token string) bool {
+			return strings.Contains(userAgent, token)
+		}) {
+			return ClassSuspectedBot
+		}
+		if r.Header.Get("Accept") == "" || r.Header.Get("Accept-Language") == "" {
+			return ClassSuspectedBot
+		}
+		return ClassHuman
+	}
+}
+
+// CrawlerVerifier verifies that requests claiming to come from a search engine crawler in their
+// User-Agent header, such as Googlebot, Bingbot, Applebot, YandexBot, or Baiduspider, actually
+// come from it, with the forward-confirmed reverse DNS lookup their operators document: the
+// address of the client must resolve to a host name in a domain of the crawler, which must resolve
+// back to the address.
+//
+// Results are cached per address for an hour, so that DNS is only queried for the first request
+// of each client. A CrawlerVerifier is safe for concurrent use by multiple goroutines.
+type CrawlerVerifier struct {
+	clientIP   *ClientIPResolver
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+
+	mu       sync.Mutex
+	verified map[string]cachedVerification
+	sweptAt  time.Time
+}
+
+// cachedVerification is the result of verifying an address as a crawler, cached until expiresAt.
+type cachedVerification struct {
+	crawler   string
+	ok        bool
+	expiresAt time.Time
+}
+
+// NewCrawlerVerifier creates a new [CrawlerVerifier] verifying the IP address of clients resolved
+// with clientIP, or the one of their connection if clientIP is nil.
+func NewCrawlerVerifier(clientIP *ClientIPResolver) *CrawlerVerifier {
+	if clientIP == nil {
+		clientIP = connectionIPResolver
+	}
+	return &CrawlerVerifier{
+		clientIP:   clientIP,
+		lookupAddr: net.DefaultResolver.LookupAddr,
+		lookupHost: net.DefaultResolver.LookupHost,
+		verified:   make(map[string]cachedVerification),
+	}
+}
+
+// Verify reports whether r comes from the search engine crawler its User-Agent header claims. It
+// returns false if r does not claim to come from a known crawler, or if DNS cannot be queried.
+func (v *CrawlerVerifier) Verify(r *http.Request) bool {
+	c, ok := claimedCrawler(strings.ToLower(r.UserAgent()))
+	if !ok {
+		return false
+	}
+	addr, err := netip.ParseAddr(v.clientIP.ClientIP(r))
+	if err != nil {
+		return false
+	}
+	ip := addr.Unmap().String()
+	now := time.Now()
+	v.mu.Lock()
+	cached, ok := v.verified[ip]
+	v.mu.Unlock()
+	if ok && cached.crawler == c.token && now.Before(cached.expiresAt) {
+		return cached.ok
+	}
+	verified, err := v.verify(r.Context(), ip, c)
+	if err != nil {
+		return false
+	}
+	v.mu.Lock()
+	v.sweep(now)
+	v.verified[ip] = cachedVerification{crawler: c.token, ok: verified, expiresAt: now.Add(crawlerVerificationTTL)}
+	v.mu.Unlock()
+	return verified
+}
+
+// verify performs the forward-confirmed reverse DNS lookup of ip for c. An error is returned if
+// DNS cannot be queried, rather than if ip is not verified, so that failures are not cached.
+func (v *CrawlerVerifier) verify(ctx context.Context, ip string, c crawler) (bool, error) {
+	names, err := v.lookupAddr(ctx, ip)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if !slices.ContainsFunc(c.domains, func(domain string) bool {
+			return strings.HasSuffix(name, "."+domain)
+		}) {
+			continue
+		}
+		addrs, err := v.lookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		if slices.ContainsFunc(addrs, func(addr string) bool {
+			resolved, err := netip.ParseAddr(addr)
+			return err == nil && resolved.Unmap().String() == ip
+		}) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sweep removes the expired results, if their TTL has elapsed since the last sweep, so that the
+// results of clients that went away do not accumulate. v.mu must be held.
+func (v *CrawlerVerifier) sweep(now time.Time) {
+	if now.Sub(v.sweptAt) < crawlerVerificationTTL {
+		return
+	}
+	v.sweptAt = now
+	for ip, cached := range v.verified {
+		if !now.Before(cached.expiresAt) {
+			delete(v.verified, ip)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newBrowserRequest returns a request from addr with the headers of a browser and userAgent.
+func newBrowserRequest(addr, userAgent string) *http.Request {
+	r := newRequestFrom(addr)
+	r.Header.Set("User-Agent", userAgent)
+	r.Header.Set("Accept", "text/html")
+	r.Header.Set("Accept-Language", "en-US")
+	return r
+}
+
+// newRequestWithUserAgent returns a request from addr with only a User-Agent header.
+func newRequestWithUserAgent(addr, userAgent string) *http.Request {
+	r := newRequestFrom(addr)
+	r.Header.Set("User-Agent", userAgent)
+	return r
+}
+
+// newTestCrawlerVerifier returns a CrawlerVerifier resolving 66.249.66.1 to a Googlebot host name,
+// counting the reverse lookups in lookups.
+func newTestCrawlerVerifier(lookups *int) *CrawlerVerifier {
+	verifier := NewCrawlerVerifier(nil)
+	verifier.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		*lookups++
+		if addr == "66.249.66.1" {
+			return []string{"crawl-66-249-66-1.googlebot.com."}, nil
+		}
+		return []string{"attacker.example.com."}, nil
+	}
+	verifier.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "crawl-66-249-66-1.googlebot.com" {
+			return []string{"66.249.66.1"}, nil
+		}
+		return nil, nil
+	}
+	return verifier
+}
+
+// Test requests are classified by their headers
+func TestHeuristicClassifier(t *testing.T) {
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	const googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	var lookups int
+	classify := HeuristicClassifier(newTestCrawlerVerifier(&lookups))
+
+	missingLanguage := newBrowserRequest("192.0.2.1:1234", chrome)
+	missingLanguage.Header.Del("Accept-Language")
+	tests := []struct {
+		name string
+		r    *http.Request
+		want Class
+	}{
+		{"browser", newBrowserRequest("192.0.2.1:1234", chrome), ClassHuman},
+		{"curl", newBrowserRequest("192.0.2.1:1234", "curl/8.4.0"), ClassSuspectedBot},
+		{"headless", newBrowserRequest("192.0.2.1:1234", "Mozilla/5.0 HeadlessChrome/120.0"), ClassSuspectedBot},
+		{"no user agent", newBrowserRequest("192.0.2.1:1234", ""), ClassSuspectedBot},
+		{"missing header", missingLanguage, ClassSuspectedBot},
+		{"verified crawler", newRequestWithUserAgent("66.249.66.1:1234", googlebot), ClassVerifiedCrawler},
+		{"spoofed crawler", newRequestWithUserAgent("192.0.2.1:1234", googlebot), ClassSuspectedBot},
+	}
+	for _, test := range tests {
+		if class := classify(test.r); class != test.want {
+			t.Errorf("%s: expected class %q; got %q", test.name, test.want, class)
+		}
+	}
+
+	if class := HeuristicClassifier(nil)(newRequestWithUserAgent("66.249.66.1:1234", googlebot)); class != ClassSuspectedBot {
+		t.Errorf("expected crawlers to be suspected without a verifier; got %q", class)
+	}
+}
+
+// Test verification results are cached per address
+func TestCrawlerVerifierCache(t *testing.T) {
+	var lookups int
+	verifier := newTestCrawlerVerifier(&lookups)
+	r := newRequestWithUserAgent("66.249.66.1:1234", "Googlebot/2.1")
+
+	for range 3 {
+		if !verifier.Verify(r) {
+			t.Fatalf("expected the crawler to be verified")
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup; got %d", lookups)
+	}
+	if verifier.Verify(newRequestWithUserAgent("66.249.66.1:1234", "Mozilla/5.0")) {
+		t.Errorf("expected requests not claiming a crawler not to be verified")
+	}
+}
+
+// Test each class is checked against its own limiter, and unknown classes against the human one
+func TestClassLimiter(t *testing.T) {
+	limiter := NewClassLimiter(func(r *http.Request) Class {
+		return Class(r.Header.Get("X-Class"))
+	}, map[Class]RateLimiter{
+		ClassHuman:        NewFixedWindowLimiter(2, time.Hour),
+		ClassSuspectedBot: NewFixedWindowLimiter(1, time.Hour),
+	})
+	request := func(class Class) *http.Request {
+		r := newRequestFrom("192.0.2.1:1234")
+		r.Header.Set("X-Class", string(class))
+		return r
+	}
+
+	limiter.IsAllowed(request(ClassSuspectedBot))
+	if isAllowed, _ := limiter.IsAllowed(request(ClassSuspectedBot)); isAllowed {
+		t.Errorf("expected the second bot request to be denied")
+	}
+	limiter.IsAllowed(request("unknown"))
+	if isAllowed, _ := limiter.IsAllowed(request(ClassHuman)); !isAllowed {
+		t.Errorf("expected the second human request to be allowed")
+	}
+	if isAllowed, _ := limiter.IsAllowed(request(ClassHuman)); isAllowed {
+		t.Errorf("expected the third human request to be denied")
+	}
+}
@@ -0,0 +1,184 @@
+package cerberus
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver determines the IP address of the client making a request, for services running
+// behind reverse proxies and load balancers. Such services see the address of the last proxy as
+// the address of the connection, and the address of the client in headers added by the proxies,
+// such as X-Forwarded-For.
+//
+// These headers can be set by the client too, so trusting them blindly allows clients to evade
+// IP-based limits by spoofing them. A ClientIPResolver only trusts headers that were added by
+// proxies in a configured list of trusted networks.
+//
+// Behavior:
+//   - If the connection does not come from a trusted proxy, its address is the client's.
+//   - Otherwise, the headers are consulted in order of precedence. Headers listing one address
+//     per hop, X-Forwarded-For and Forwarded, are read from the last hop backwards, and the first
+//     address that is not a trusted proxy is the client's. If every hop is a trusted proxy, the
+//     first address listed is the client's. Other headers, such as X-Real-IP and CF-Connecting-IP,
+//     hold the address of the client alone.
+//   - If no header holds a valid address, the address of the connection is the client's.
+//
+// Resolvers are used through [ClientIPResolver.Key] as a [KeyFunc], and through
+// [ClientIPResolver.MatchIPs] as a [RequestMatcher]. [KeyByIP], [KeyByForwardedFor], and
+// [MatchIPs] are backed by resolvers as well.
+//
+// Example usage:
+//
+//	resolver := NewClientIPResolver([]string{"10.0.0.0/8"}, "X-Forwarded-For")
+//	limiter := NewTokenBucketLimiter(10, 1, WithKeyFunc(resolver.Key))
+//	http.Handle("/resource", New(limiter, WithAllowlist(resolver.MatchIPs("10.1.2.3")))(myHandler))
+type ClientIPResolver struct {
+	trustedProxies []netip.Prefix
+	headers        []string
+}
+
+// defaultClientIPHeaders are the headers consulted by a [ClientIPResolver] created without any.
+var defaultClientIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+var (
+	// connectionIPResolver resolves the address of the connection, trusting no proxy.
+	connectionIPResolver = &ClientIPResolver{}
+
+	// forwardedForResolver resolves the first address listed in X-Forwarded-For, trusting every
+	// proxy.
+	forwardedForResolver = NewClientIPResolver([]string{"0.0.0.0/0", "::/0"}, "X-Forwarded-For")
+)
+
+// NewClientIPResolver creates a new [ClientIPResolver] that trusts the headers added by proxies in
+// trustedProxies, which are IP addresses and CIDR ranges such as "10.0.0.0/8". headers lists the
+// headers holding client addresses in order of precedence. Only the headers actually set by the
+// trusted proxies should be listed. The default is Forwarded, X-Forwarded-For, then X-Real-IP;
+// CF-Connecting-IP must be listed explicitly by services behind Cloudflare.
+//
+// It panics if an entry of trustedProxies is neither a valid IP address nor a valid CIDR range.
+func NewClientIPResolver(trustedProxies []string, headers ...string) *ClientIPResolver {
+	resolver := &ClientIPResolver{
+		trustedProxies: make([]netip.Prefix, 0, len(trustedProxies)),
+		headers:        headers,
+	}
+	if len(resolver.headers) == 0 {
+		resolver.headers = defaultClientIPHeaders
+	}
+	for _, entry := range trustedProxies {
+		resolver.trustedProxies = append(resolver.trustedProxies, parsePrefix(entry))
+	}
+	return resolver
+}
+
+// ClientIP returns the IP address of the client making the request.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if addr, err := netip.ParseAddr(remote); err != nil || !c.trusted(addr.Unmap()) {
+		return remote
+	}
+	for _, header := range c.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		var addr netip.Addr
+		switch http.CanonicalHeaderKey(header) {
+		case "X-Forwarded-For":
+			addr = c.lastUntrusted(strings.Split(strings.Join(values, ","), ","), parseForwardedFor)
+		case "Forwarded":
+			addr = c.lastUntrusted(strings.Split(strings.Join(values, ","), ","), parseForwardedElement)
+		default:
+			addr, _ = parseForwardedFor(values[0])
+		}
+		if addr.IsValid() {
+			return addr.String()
+		}
+	}
+	return remote
+}
+
+// Key is a [KeyFunc] that keys requests by the IP address of the client making them.
+func (c *ClientIPResolver) Key(r *http.Request) (string, error) {
+	return c.ClientIP(r), nil
+}
+
+// MatchIPs returns a [RequestMatcher] that matches requests from clients with the given IP
+// addresses and CIDR ranges, such as "192.0.2.1", "2001:db8::1", or "10.0.0.0/8".
+//
+// It panics if an entry is neither a valid IP address nor a valid CIDR range.
+func (c *ClientIPResolver) MatchIPs(entries ...string) RequestMatcher {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		prefixes = append(prefixes, parsePrefix(entry))
+	}
+	return func(r *http.Request) bool {
+		addr, err := netip.ParseAddr(c.ClientIP(r))
+		if err != nil {
+			return false
+		}
+		return containsAddr(prefixes, addr.Unmap())
+	}
+}
+
+// lastUntrusted returns the address of the client among the hops, listed from the first to the
+// last: the last address that is not a trusted proxy, or the first address if all of them are.
+// Hops are parsed with parse, and the search stops at the first hop that cannot be parsed, since
+// the hops before it cannot be trusted either. The zero Addr is returned if the last hop cannot
+// be parsed.
+func (c *ClientIPResolver) lastUntrusted(hops []string, parse func(string) (netip.Addr, bool)) netip.Addr {
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parse(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !c.trusted(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// trusted reports whether addr belongs to a trusted proxy.
+func (c *ClientIPResolver) trusted(addr netip.Addr) bool {
+	return containsAddr(c.trustedProxies, addr)
+}
+
+// containsAddr reports whether any of prefixes contains addr.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseForwardedFor parses an IP address as listed in X-Forwarded-For and similar headers.
+func parseForwardedFor(value string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(value))
+	return addr.Unmap(), err == nil
+}
+
+// parseForwardedElement parses the address in the "for" parameter of an element of the Forwarded
+// header defined by RFC 7239, such as `for=192.0.2.60;proto=http` or `for="[2001:db8::17]:4711"`.
+// Obfuscated identifiers and "unknown" are not valid addresses.
+func parseForwardedElement(element string) (netip.Addr, bool) {
+	for _, pair := range strings.Split(element, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if !strings.EqualFold(name, "for") {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		addr, err := netip.ParseAddr(value)
+		return addr.Unmap(), err == nil
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,82 @@
+package cerberus
+
+import (
+	"testing"
+)
+
+// Test the client IP is resolved from trusted proxies only, following header precedence
+func TestClientIPResolver(t *testing.T) {
+	resolver := NewClientIPResolver([]string{"10.0.0.0/8", "2001:db8::/32"}, "Forwarded", "X-Forwarded-For", "X-Real-IP", "CF-Connecting-IP")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"untrusted connection", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "192.0.2.1"},
+		{"no header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"forwarded for", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"spoofed forwarded for", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.9, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"all hops trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"invalid hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "not-an-ip, 10.0.0.2"}, "10.0.0.2"},
+		{"invalid last hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7, not-an-ip"}, "10.0.0.1"},
+		{"forwarded", "10.0.0.1:1234", map[string]string{"Forwarded": `for=203.0.113.7;proto=https, for="[2001:db8::1]:4711"`}, "203.0.113.7"},
+		{"forwarded ipv6", "10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db9::1]:4711"`}, "2001:db9::1"},
+		{"forwarded precedence", "10.0.0.1:1234", map[string]string{"Forwarded": "for=203.0.113.7", "X-Forwarded-For": "198.51.100.9"}, "203.0.113.7"},
+		{"forwarded unknown", "10.0.0.1:1234", map[string]string{"Forwarded": "for=unknown", "X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"real ip", "10.0.0.1:1234", map[string]string{"X-Real-IP": "203.0.113.7"}, "203.0.113.7"},
+		{"cloudflare", "10.0.0.1:1234", map[string]string{"CF-Connecting-IP": "203.0.113.7"}, "203.0.113.7"},
+		{"mapped ipv4", "[::ffff:10.0.0.1]:1234", map[string]string{"X-Forwarded-For": "::ffff:203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, test := range tests {
+		req := newRequestFrom(test.remoteAddr)
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+		if ip := resolver.ClientIP(req); ip != test.want {
+			t.Errorf("%s: expected %q; got %q", test.name, test.want, ip)
+		}
+	}
+}
+
+// Test only the headers the resolver is configured with are consulted
+func TestClientIPResolverHeaders(t *testing.T) {
+	req := newRequestFrom("10.0.0.1:1234")
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	req.Header.Set("CF-Connecting-IP", "203.0.113.7")
+
+	if ip := NewClientIPResolver([]string{"10.0.0.0/8"}).ClientIP(req); ip != "198.51.100.9" {
+		t.Errorf("expected 198.51.100.9 with the default headers; got %q", ip)
+	}
+	if ip := NewClientIPResolver([]string{"10.0.0.0/8"}, "CF-Connecting-IP").ClientIP(req); ip != "203.0.113.7" {
+		t.Errorf("expected 203.0.113.7; got %q", ip)
+	}
+}
+
+// Test the resolver backs keys and IP matchers
+func TestClientIPResolverKeyAndMatchIPs(t *testing.T) {
+	resolver := NewClientIPResolver([]string{"10.0.0.0/8"}, "X-Forwarded-For")
+	req := newRequestFrom("10.0.0.1:1234")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	if key, err := resolver.Key(req); err != nil || key != "203.0.113.7" {
+		t.Errorf("expected key 203.0.113.7; got %q, %v", key, err)
+	}
+	if !resolver.MatchIPs("203.0.113.0/24")(req) {
+		t.Errorf("expected the client address to match")
+	}
+	if resolver.MatchIPs("10.0.0.0/8")(req) {
+		t.Errorf("expected the proxy address not to match")
+	}
+}
+
+// Test invalid trusted proxies panic
+func TestNewClientIPResolverPanicsOnInvalidProxy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	NewClientIPResolver([]string{"10.0.0.0/33"})
+}
@@ -0,0 +1,86 @@
+package cerberus
+
+import "time"
+
+// Clock is the source of time of the built-in limiters, and of the waits of [WithWaitMode] and
+// [Transport] in front of them, so that tests of time-based algorithms can be deterministic, and
+// limiters can follow a time source other than the system clock. [SystemClock] is the default;
+// the cerberustest package provides a Clock that only moves when told to.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses the current goroutine for at least d.
+	Sleep(d time.Duration)
+	// NewTimer creates a new Timer that sends the current time on its channel after at least d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event created by [Clock.NewTimer], like a [time.Timer].
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer already fired or was
+	// stopped.
+	Stop() bool
+	// Reset changes the timer to fire after d. It returns true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the [Clock] reading the system clock, with the functions of package time.
+var SystemClock Clock = systemClock{}
+
+// systemClock is the type of [SystemClock].
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// systemTimer is a [Timer] backed by a [time.Timer].
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// WithClock sets the [Clock] the limiter tells the time with. The default [MemoryStore] of the
+// limiter, if it has no store set with [WithStore], expires entries by the same clock, and the
+// middleware waits by it in [WithWaitMode]. The default is [SystemClock].
+//
+// Example usage: NewTokenBucketLimiter(10, 1, WithClock(cerberustest.NewClock(time.Time{})))
+func WithClock(clock Clock) LimiterOption {
+	return func(o *limiterOptions) {
+		o.clock = clock
+	}
+}
+
+// clocked is implemented by the rate limiters that follow a [Clock].
+type clocked interface {
+	timeSource() Clock
+}
+
+// clockOf returns the clock rateLimiter follows, or [SystemClock] if it does not report one.
+func clockOf(rateLimiter RateLimiter) Clock {
+	if c, ok := rateLimiter.(clocked); ok {
+		return c.timeSource()
+	}
+	return SystemClock
+}
+
+// laterOf returns the later of a and b.
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
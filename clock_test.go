@@ -0,0 +1,59 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the system clock tells the time and fires timers
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	if now := SystemClock.Now(); now.Before(before) {
+		t.Errorf("expected the current time; got %v", now)
+	}
+	timer := SystemClock.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(time.Second):
+		t.Errorf("expected the timer to fire")
+	}
+	if timer.Stop() {
+		t.Errorf("expected a fired timer not to be active")
+	}
+	if timer.Reset(time.Hour) {
+		t.Errorf("expected a fired timer not to be active")
+	}
+	if !timer.Stop() {
+		t.Errorf("expected a reset timer to be active")
+	}
+}
+
+// offsetClock is a Clock running ahead of the system clock.
+type offsetClock struct {
+	systemClock
+	offset time.Duration
+}
+
+func (c offsetClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// Test the built-in limiters report the clock they follow
+func TestClockOf(t *testing.T) {
+	clock := offsetClock{offset: time.Hour}
+	for _, rateLimiter := range []RateLimiter{
+		NewFixedWindowLimiter(1, time.Minute, WithClock(clock)),
+		NewSlidingWindowLimiter(1, time.Minute, WithClock(clock)),
+		NewTokenBucketLimiter(1, 1, WithClock(clock)),
+		NewLeakyBucketLimiter(1, 1, WithClock(clock)),
+		NewGCRALimiter(time.Second, 0, WithClock(clock)),
+		NewQuotaLimiter(1, Monthly(time.UTC), WithClock(clock)),
+	} {
+		if c := clockOf(rateLimiter); c != clock {
+			t.Errorf("%T: expected the configured clock; got %v", rateLimiter, c)
+		}
+	}
+	if c := clockOf(&MockRateLimiter{}); c != SystemClock {
+		t.Errorf("expected the system clock; got %v", c)
+	}
+}
@@ -0,0 +1,106 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Closer is implemented by the rate limiters and stores that own resources outliving the requests
+// they handle, such as the janitor goroutine of a [MemoryStore], the increments a [BatchingStore]
+// has not flushed yet, the connection pool of a Redis store, or the node of a gossip cluster.
+//
+// Close releases the resources and flushes any pending state, waiting for that to complete until
+// ctx is done, in which case it returns the error of ctx. It must be called once the rate limiter
+// or store is no longer used, typically on shutdown. Calling it more than once has no further
+// effect. The built-in limiters close the stores they created, but not those set with [WithStore],
+// which may be shared, and composite limiters such as [ChainLimiter] close the limiters they are
+// made of.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// closeAll closes the values implementing [Closer], in order, and returns their errors joined.
+func closeAll[T any](ctx context.Context, values ...T) error {
+	var errs []error
+	for _, v := range values {
+		if c, ok := any(v).(Closer); ok {
+			errs = append(errs, c.Close(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeOwnedStore closes store if it is the default store of a limiter, created because no store
+// was set with [WithStore]. Stores set with WithStore are left open, since they may be shared.
+func closeOwnedStore(ctx context.Context, store Store) error {
+	if s, ok := store.(*MemoryStore); ok && s.owned {
+		return s.Close(ctx)
+	}
+	return nil
+}
+
+// Handle is a rate limiting middleware along with the resources it depends on, so that they can be
+// released, and their pending state flushed, on shutdown. It is created with [NewHandle].
+type Handle struct {
+	middleware func(http.Handler) http.Handler
+	closers    []Closer
+}
+
+// NewHandle is like [New], but returns a [Handle] closing rateLimiter, if it implements [Closer],
+// and the resources created by the options, such as the default store of [WithPenaltyPolicy], when
+// it is closed. Stores and limiters created separately, such as stores set with [WithStore], can
+// be closed along with them with [WithClosers].
+//
+// Example usage:
+//
+//	store := NewCachingStore(myRedisStore, 100*time.Millisecond, nil)
+//	limiter := NewHandle(NewFixedWindowLimiter(1000, time.Minute, WithStore(store)), WithClosers(store))
+//	server := &http.Server{Handler: limiter.Wrap(myHandler)}
+//	...
+//	server.Shutdown(ctx)
+//	limiter.Close(ctx)
+func NewHandle(rateLimiter RateLimiter, opts ...Option) *Handle {
+	options := newOptions(opts)
+	var closers []Closer
+	if c, ok := rateLimiter.(Closer); ok {
+		closers = append(closers, c)
+	}
+	if options.penalty != nil {
+		store := options.penalty.Store
+		closers = append(closers, closerFunc(func(ctx context.Context) error {
+			return closeOwnedStore(ctx, store)
+		}))
+	}
+	closers = append(closers, options.closers...)
+	return &Handle{middleware: newMiddleware(rateLimiter, options), closers: closers}
+}
+
+// closerFunc is a function implementing [Closer].
+type closerFunc func(ctx context.Context) error
+
+func (f closerFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Wrap returns next wrapped with the middleware.
+func (h *Handle) Wrap(next http.Handler) http.Handler {
+	return h.middleware(next)
+}
+
+// Close closes the rate limiter of the middleware and the resources it depends on, in order. It
+// should be called once the server stopped serving requests, such as after [http.Server.Shutdown]
+// returned, so that no request updates the state being flushed. The errors of all resources are
+// returned joined.
+func (h *Handle) Close(ctx context.Context) error {
+	return closeAll(ctx, h.closers...)
+}
+
+// WithClosers adds resources closed by [Handle.Close] after the rate limiter, in order, such as the
+// stores set with [WithStore], which the rate limiter does not close. It has no effect on
+// middlewares created with [New].
+func WithClosers(closers ...Closer) Option {
+	return func(o *options) {
+		o.closers = append(o.closers, closers...)
+	}
+}
@@ -0,0 +1,122 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// closerRecorder is a Closer recording the order it is closed in.
+type closerRecorder struct {
+	name   string
+	closed *[]string
+	err    error
+}
+
+func (c closerRecorder) Close(context.Context) error {
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}
+
+// closerLimiter is a RateLimiter implementing Closer.
+type closerLimiter struct {
+	MockRateLimiter
+	closerRecorder
+}
+
+// isClosed reports whether the janitor of store was stopped.
+func isClosed(store *MemoryStore) bool {
+	select {
+	case <-store.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// TestLimiterCloseOwnedStore tests that the built-in limiters close their default store, but not
+// the stores set with WithStore.
+func TestLimiterCloseOwnedStore(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTokenBucketLimiter(1, 1)
+	if err := limiter.Close(ctx); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if !isClosed(limiter.store.(*MemoryStore)) {
+		t.Errorf("expected the default store to be closed")
+	}
+
+	store := NewMemoryStore()
+	defer store.Close(ctx)
+	for _, limiter := range []Closer{
+		NewTokenBucketLimiter(1, 1, WithStore(store)),
+		NewLeakyBucketLimiter(1, 1, WithStore(store)),
+		NewFixedWindowLimiter(1, time.Minute, WithStore(store)),
+		NewSlidingWindowLimiter(1, time.Minute, WithStore(store)),
+		NewGCRALimiter(time.Second, 0, WithStore(store)),
+		NewQuotaLimiter(1, Monthly(time.UTC), WithStore(store)),
+		NewConcurrencyLimiter(1, WithStore(store)),
+	} {
+		if err := limiter.Close(ctx); err != nil {
+			t.Errorf("expected no error; got %v", err)
+		}
+	}
+	if isClosed(store) {
+		t.Errorf("expected a store set with WithStore to be left open")
+	}
+}
+
+// TestCompositeLimiterClose tests that composite limiters close the limiters they are made of.
+func TestCompositeLimiterClose(t *testing.T) {
+	ctx := context.Background()
+	var closed []string
+	a := &closerLimiter{closerRecorder: closerRecorder{name: "a", closed: &closed}}
+	b := &closerLimiter{closerRecorder: closerRecorder{name: "b", closed: &closed, err: errors.New("b failed")}}
+
+	if err := NewChainLimiter(a, &MockRateLimiter{}, b).Close(ctx); !errors.Is(err, b.err) {
+		t.Errorf("expected %v; got %v", b.err, err)
+	}
+	if len(closed) != 2 || closed[0] != "a" || closed[1] != "b" {
+		t.Errorf("expected the limiters of the chain to be closed in order; got %v", closed)
+	}
+
+	closed = nil
+	NewFallbackLimiter(a, b, time.Second).Close(ctx)
+	if len(closed) != 2 {
+		t.Errorf("expected the primary and fallback limiters to be closed; got %v", closed)
+	}
+
+	closed = nil
+	NewTieredLimiter(nil, map[string]RateLimiter{"free": a, "pro": b}).Close(ctx)
+	if len(closed) != 2 {
+		t.Errorf("expected the limiters of the tiers to be closed; got %v", closed)
+	}
+}
+
+// TestHandle tests that a Handle serves requests like the middleware of New, and closes the rate
+// limiter and the resources it depends on in order.
+func TestHandle(t *testing.T) {
+	var closed []string
+	rateLimiter := &closerLimiter{
+		MockRateLimiter: MockRateLimiter{IsAllowedFunc: func(*http.Request) (bool, error) { return false, nil }},
+		closerRecorder:  closerRecorder{name: "limiter", closed: &closed},
+	}
+	store := closerRecorder{name: "store", closed: &closed}
+	handle := NewHandle(rateLimiter, WithClosers(store))
+
+	rr := httptest.NewRecorder()
+	handle.Wrap(noContent).ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d; got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if err := handle.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if len(closed) != 2 || closed[0] != "limiter" || closed[1] != "store" {
+		t.Errorf("expected the rate limiter and the store to be closed in order; got %v", closed)
+	}
+}
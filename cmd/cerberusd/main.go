@@ -0,0 +1,191 @@
+// Command cerberusd runs cerberus rate limiting as a standalone process, so that services written
+// in any language can be rate limited with the limiters of the library. It loads a declarative
+// [cerberus.MiddlewareConfig] from a JSON or YAML file, and runs in one of two modes:
+//
+//   - As a reverse proxy, with -upstream: allowed requests are forwarded to the upstream service,
+//     and denied requests are answered with an HTTP 429 (Too Many Requests) without reaching it.
+//   - As an authorization sidecar, without -upstream, for proxies that ask an external service
+//     whether to let a request through, such as the auth_request module of NGINX, the forwardAuth
+//     middleware of Traefik, or the HTTP ext_authz filter of Envoy. Allowed requests are answered
+//     with an HTTP 200 (OK), and denied ones with an HTTP 429, with the rate limit headers in both
+//     cases. The original method, URI and host of the request are read from the
+//     X-Forwarded-Method, X-Forwarded-Uri (or X-Original-URI) and X-Forwarded-Host headers when
+//     the proxy sets them, so that routes of the config match the original request.
+//
+// In both modes, cerberusd sits behind a proxy or in front of a service, so limits keyed by client
+// address usually need the "forwarded_for" key of the config.
+//
+// Usage:
+//
+//	cerberusd -config /etc/cerberusd/limits.yaml -listen :8080 -upstream http://localhost:3000
+//
+// Decisions are logged as JSON to standard error. On SIGINT or SIGTERM, cerberusd stops accepting
+// connections and waits for the requests in flight, up to -shutdown-timeout, before exiting.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// config holds the command-line settings of cerberusd.
+type config struct {
+	configPath      string
+	listen          string
+	upstream        *url.URL
+	shutdownTimeout time.Duration
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "cerberusd: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses args, then serves requests until ctx is done. Logs and usage are written to stderr.
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	c, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewJSONHandler(stderr, nil))
+	handler, limit, err := newHandler(c, logger)
+	if err != nil {
+		return err
+	}
+	defer limit.Close(context.Background())
+	server := &http.Server{
+		Addr:              c.listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	logger.Info("cerberusd started", slog.String("listen", c.listen), slog.String("mode", c.mode()))
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := limit.Close(shutdownCtx); err != nil {
+		return err
+	}
+	logger.Info("cerberusd stopped")
+	return nil
+}
+
+// parseFlags parses the command-line settings in args. Usage and parse errors are written to
+// output.
+func parseFlags(args []string, output io.Writer) (config, error) {
+	var c config
+	var upstream string
+	flags := flag.NewFlagSet("cerberusd", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&c.configPath, "config", "", "path of the JSON or YAML rate limit config (required)")
+	flags.StringVar(&c.listen, "listen", ":8080", "address to listen on")
+	flags.StringVar(&upstream, "upstream", "", "URL of the service to forward allowed requests to; without it, cerberusd runs as an authorization sidecar")
+	flags.DurationVar(&c.shutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to wait for requests in flight on shutdown")
+	if err := flags.Parse(args); err != nil {
+		return c, err
+	}
+	if flags.NArg() > 0 {
+		return c, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+	if c.configPath == "" {
+		return c, errors.New("-config is required")
+	}
+	if upstream != "" {
+		u, err := url.Parse(upstream)
+		if err != nil {
+			return c, fmt.Errorf("-upstream: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return c, fmt.Errorf("-upstream: %q is not an absolute HTTP URL", upstream)
+		}
+		c.upstream = u
+	}
+	return c, nil
+}
+
+// mode returns the name of the mode cerberusd runs in, for logs.
+func (c config) mode() string {
+	if c.upstream != nil {
+		return "proxy"
+	}
+	return "auth"
+}
+
+// newHandler creates the handler serving requests in the mode set by c, applying the limits of
+// the config file of c and logging decisions to logger. The returned handle must be closed once
+// the handler no longer serves requests.
+func newHandler(c config, logger *slog.Logger) (http.Handler, *cerberus.Handle, error) {
+	limit, err := cerberus.LoadConfig(c.configPath, cerberus.WithLogger(logger))
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.upstream != nil {
+		proxy := httputil.NewSingleHostReverseProxy(c.upstream)
+		proxy.ErrorLog = slog.NewLogLogger(logger.Handler(), slog.LevelError)
+		return limit.Wrap(proxy), limit, nil
+	}
+	allowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return originalRequest(limit.Wrap(allowed)), limit, nil
+}
+
+// originalRequest restores the method, URI and host of the request a proxy is asking about from
+// the headers the proxy sets, before passing it to next.
+func originalRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("X-Forwarded-Method")
+		uri := r.Header.Get("X-Forwarded-Uri")
+		if uri == "" {
+			uri = r.Header.Get("X-Original-URI")
+		}
+		host := r.Header.Get("X-Forwarded-Host")
+		if method == "" && uri == "" && host == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.Clone(r.Context())
+		if method != "" {
+			r.Method = method
+		}
+		if uri != "" {
+			if u, err := url.ParseRequestURI(uri); err == nil {
+				r.URL, r.RequestURI = u, uri
+			}
+		}
+		if host != "" {
+			r.Host = host
+		}
+		next.ServeHTTP(w, r)
+	})
+}
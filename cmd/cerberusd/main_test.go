@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a rate limit config allowing 1 request per minute per client address to
+// /limited, and returns its path.
+func writeConfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	data := "routes:\n  - {pattern: \"/limited\", key: forwarded_for, algorithm: fixed_window, limit: 1, window: 1m}\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	return path
+}
+
+// newTestHandler creates the handler of cerberusd for args.
+func newTestHandler(t *testing.T, args ...string) http.Handler {
+	c, err := parseFlags(append([]string{"-config", writeConfig(t)}, args...), io.Discard)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	handler, limit, err := newHandler(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	t.Cleanup(func() { limit.Close(context.Background()) })
+	return handler
+}
+
+// serve sends a request for target with headers to handler, and returns the status code.
+func serve(handler http.Handler, target string, headers map[string]string) int {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("X-Forwarded-For", "192.0.2.1")
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	return rr.Code
+}
+
+// Test allowed requests are forwarded to the upstream in proxy mode, and denied ones are not
+func TestProxyMode(t *testing.T) {
+	received := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+	handler := newTestHandler(t, "-upstream", upstream.URL)
+
+	for i, expected := range []int{http.StatusNoContent, http.StatusTooManyRequests} {
+		if code := serve(handler, "/limited", nil); code != expected {
+			t.Errorf("expected status %d for request %d; got %d", expected, i+1, code)
+		}
+	}
+	if code := serve(handler, "/other", nil); code != http.StatusNoContent {
+		t.Errorf("expected requests matching no route to be forwarded; got %d", code)
+	}
+	if received != 2 {
+		t.Errorf("expected 2 requests to be forwarded; got %d", received)
+	}
+}
+
+// Test requests are answered with their decision in auth mode, matched by their original URI
+func TestAuthMode(t *testing.T) {
+	handler := newTestHandler(t)
+	original := map[string]string{"X-Forwarded-Method": "GET", "X-Forwarded-Uri": "/limited?page=2"}
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve(handler, "/auth", original); code != expected {
+			t.Errorf("expected status %d for request %d; got %d", expected, i+1, code)
+		}
+	}
+	if code := serve(handler, "/auth", map[string]string{"X-Original-URI": "/limited"}); code != http.StatusTooManyRequests {
+		t.Errorf("expected X-Original-URI to be used as the original URI; got %d", code)
+	}
+	if code := serve(handler, "/auth", map[string]string{"X-Forwarded-Uri": "/other"}); code != http.StatusOK {
+		t.Errorf("expected requests matching no route to be allowed; got %d", code)
+	}
+}
+
+// Test invalid command lines are rejected
+func TestParseFlagsInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-config", "limits.yaml", "extra"},
+		{"-config", "limits.yaml", "-upstream", "localhost:3000"},
+	} {
+		if _, err := parseFlags(args, io.Discard); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+// Test run serves until its context is done
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := run(ctx, []string{"-config", writeConfig(t), "-listen", "127.0.0.1:0"}, io.Discard); err != nil {
+		t.Errorf("expected no error; got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cerberus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CoalescingStore is a [Store] that coalesces the concurrent reads of the same key into a single
+// read of a remote store, whose result is shared by all the callers waiting for it. When hundreds
+// of concurrent requests from a hot client check the same key, such as a popular API key or the
+// shared key of a global limit, the store serves one read per round trip instead of one per
+// request.
+//
+// Behavior:
+//   - A Get or TTL of a key for which the same operation is in flight waits for it and returns its
+//     result, instead of querying the store again. The first read of a key queries the store.
+//   - All other operations, including CompareAndSwap, are passed through to the store, so the
+//     atomicity of updates is unchanged: a limiter that read a value which changed before it could
+//     swap it retries, as it would without the cache.
+//   - A caller whose context is done stops waiting and returns the error of its context, without
+//     failing the other callers waiting for the same read.
+//
+// A coalesced read may have started before the call that joins it, and so may miss writes made in
+// between by other callers, which is no different from a read that arrived at the store a little
+// earlier. Increments are not coalesced: use a [BatchingStore] beneath the CoalescingStore for
+// counter-based limiters.
+//
+// A CoalescingStore is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	store := NewCoalescingStore(myRedisStore)
+//	limiter := NewTokenBucketLimiter(1000, 100, WithStore(store), WithKeyFunc(KeyByHeader("X-API-Key")))
+type CoalescingStore struct {
+	Store
+
+	mu   sync.Mutex
+	gets map[string]*storeRead[[]byte]
+	ttls map[string]*storeRead[time.Duration]
+}
+
+// storeRead is a read of a key shared by the callers of a [CoalescingStore]. done is closed once
+// value and err are set.
+type storeRead[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// NewCoalescingStore creates a new [CoalescingStore] coalescing the concurrent reads of store.
+func NewCoalescingStore(store Store) *CoalescingStore {
+	return &CoalescingStore{
+		Store: store,
+		gets:  make(map[string]*storeRead[[]byte]),
+		ttls:  make(map[string]*storeRead[time.Duration]),
+	}
+}
+
+// Get returns the value stored under key, sharing the result of a read of key already in flight,
+// if any.
+func (s *CoalescingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return coalesce(ctx, s, s.gets, key, s.Store.Get)
+}
+
+// TTL returns the remaining time to live of key, sharing the result of a read of the TTL of key
+// already in flight, if any.
+func (s *CoalescingStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return coalesce(ctx, s, s.ttls, key, s.Store.TTL)
+}
+
+// ScanKeys returns the keys of the store starting with prefix. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (s *CoalescingStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	return scanKeys(ctx, s.Store, prefix)
+}
+
+// coalesce returns the result of read(ctx, key), joining the read of key in inflight, if any. The
+// read is made with a context that is not canceled with ctx, since other callers may be waiting
+// for it.
+func coalesce[T any](ctx context.Context, s *CoalescingStore, inflight map[string]*storeRead[T], key string, read func(context.Context, string) (T, error)) (T, error) {
+	s.mu.Lock()
+	call, ok := inflight[key]
+	if !ok {
+		call = &storeRead[T]{done: make(chan struct{})}
+		inflight[key] = call
+		go func() {
+			call.value, call.err = read(context.WithoutCancel(ctx), key)
+			s.mu.Lock()
+			delete(inflight, key)
+			s.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	s.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,117 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowReadStore is a Store whose reads block until release is closed, counting them.
+type slowReadStore struct {
+	Store
+	release chan struct{}
+	gets    atomic.Int64
+	ttls    atomic.Int64
+}
+
+func (s *slowReadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.gets.Add(1)
+	<-s.release
+	return s.Store.Get(ctx, key)
+}
+
+func (s *slowReadStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.ttls.Add(1)
+	<-s.release
+	return s.Store.TTL(ctx, key)
+}
+
+// Test concurrent reads of the same key share a single read of the store
+func TestCoalescingStoreCoalescesReads(t *testing.T) {
+	ctx := context.Background()
+	slow := &slowReadStore{Store: NewMemoryStore(), release: make(chan struct{})}
+	slow.Set(ctx, "hot", []byte("value"), time.Minute)
+	store := NewCoalescingStore(slow)
+
+	var wg sync.WaitGroup
+	values := make([]string, 100)
+	for i := range values {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.Get(ctx, "hot")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			values[i] = string(value)
+		}()
+	}
+	for slow.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		store.TTL(ctx, "hot")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+
+	for i, value := range values {
+		if value != "value" {
+			t.Fatalf("expected reader %d to get the value; got %q", i, value)
+		}
+	}
+	if gets := slow.gets.Load(); gets >= 100 {
+		t.Errorf("expected the reads to be coalesced; got %d reads", gets)
+	}
+	if ttls := slow.ttls.Load(); ttls != 1 {
+		t.Errorf("expected TTL reads to be made separately; got %d", ttls)
+	}
+	if value, _ := store.Get(ctx, "hot"); string(value) != "value" {
+		t.Errorf("expected later reads to query the store again; got %q", value)
+	}
+	if gets := slow.gets.Load(); gets < 2 {
+		t.Errorf("expected a new read after the first completed; got %d reads", gets)
+	}
+}
+
+// Test a caller whose context is done stops waiting without failing the others
+func TestCoalescingStoreContext(t *testing.T) {
+	slow := &slowReadStore{Store: NewMemoryStore(), release: make(chan struct{})}
+	store := NewCoalescingStore(slow)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error)
+	go func() {
+		_, err := store.Get(context.Background(), "hot")
+		done <- err
+	}()
+	for slow.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := store.Get(canceled, "hot"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v; got %v", context.Canceled, err)
+	}
+	close(slow.release)
+	if err := <-done; err != nil {
+		t.Errorf("expected the other caller to succeed; got %v", err)
+	}
+}
+
+// Test limiters work through the store
+func TestCoalescingStoreWithLimiter(t *testing.T) {
+	limiter := NewTokenBucketLimiter(2, 1, WithStore(NewCoalescingStore(NewMemoryStore())))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i, want := range []bool{true, true, false} {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || isAllowed != want {
+			t.Errorf("request %d: expected %v; got %v, %v", i+1, want, isAllowed, err)
+		}
+	}
+}
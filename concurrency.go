@@ -0,0 +1,213 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// concurrencyTTL is how long the number of connections of a client is kept without any connection
+// being opened or closed, which bounds how long connections of instances that exited without
+// closing them are counted.
+const concurrencyTTL = 24 * time.Hour
+
+// concurrencyIdleTTL is how long the number of connections of a client is kept once it is back to
+// zero.
+const concurrencyIdleTTL = time.Minute
+
+// ConcurrencyLimiter limits the number of connections each client keeps open at once, rather than
+// the rate at which it makes requests. It suits long-lived connections, such as Server-Sent Events,
+// long polling, or WebSockets, which may last for hours: a rate limiter would count such a
+// connection once, at the start, however long it is held, whereas a concurrency limiter frees the
+// slot of the client when it is closed. It is installed in a middleware with [WithLongLived].
+//
+// Connections are counted in the limiter's [Store], so that sharing the store between instances of
+// a service limits the connections of a client across all of them. The count of a client is
+// forgotten 24 hours after a connection of the client was last opened or closed, so connections of
+// instances that exited without closing them are not counted forever. It can also be cleared with
+// [ConcurrencyLimiter.Reset].
+//
+// A ConcurrencyLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	streams := NewConcurrencyLimiter(5, WithKeyFunc(KeyByHeader("X-API-Key")))
+//	http.Handle("/", New(myRateLimiter, WithLongLived(streams))(myHandler))
+type ConcurrencyLimiter struct {
+	max     int
+	keyFunc KeyFunc
+	store   Store
+}
+
+// NewConcurrencyLimiter creates a new [ConcurrencyLimiter] allowing each client at most max open
+// connections.
+//
+// It panics if max is not positive.
+func NewConcurrencyLimiter(max int, opts ...LimiterOption) *ConcurrencyLimiter {
+	if max <= 0 {
+		panic("cerberus: maximum number of connections must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &ConcurrencyLimiter{
+		max:     max,
+		keyFunc: options.keyFunc,
+		store:   options.store,
+	}
+}
+
+// Acquire takes a connection slot for the client making r. If the client already has the maximum
+// number of connections open, it returns false and takes nothing. Otherwise, the release function
+// returned must be called once the connection is closed. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, r *http.Request) (release func(), ok bool, err error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err = l.add(ctx, key, 1)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func() {
+		l.add(context.WithoutCancel(ctx), key, -1)
+	}, true, nil
+}
+
+// InUse returns the number of connections the client identified by key has open. An error is
+// returned if the store fails.
+func (l *ConcurrencyLimiter) InUse(ctx context.Context, key string) (int, error) {
+	value, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := l.count(key, value)
+	return int(n), err
+}
+
+// Reset forgets the connections of the client identified by key, for example those of an instance
+// that exited without closing them. An error is returned if the store fails.
+func (l *ConcurrencyLimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, key)
+}
+
+// add adds delta to the number of connections of the client identified by key. It reports false
+// without storing anything if that exceeds the maximum.
+func (l *ConcurrencyLimiter) add(ctx context.Context, key string, delta int64) (bool, error) {
+	ok := true
+	err := modify(ctx, l.store, key, func(value []byte) ([]byte, time.Duration, error) {
+		n, err := l.count(key, value)
+		if err != nil {
+			return nil, 0, err
+		}
+		n = max(n+delta, 0)
+		if ok = n <= int64(l.max) || delta < 0; !ok {
+			return nil, 0, nil
+		}
+		if n == 0 {
+			return encodeUint64s(0), concurrencyIdleTTL, nil
+		}
+		return encodeUint64s(uint64(n)), concurrencyTTL, nil
+	})
+	return ok, err
+}
+
+// count decodes the number of connections stored under key. A nil value yields zero.
+func (l *ConcurrencyLimiter) count(key string, value []byte) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	fields, ok := decodeUint64s(value)
+	if !ok || len(fields) != 1 {
+		return 0, fmt.Errorf("%w: %q is not a connection count", ErrMalformedValue, key)
+	}
+	return int64(fields[0]), nil
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *ConcurrencyLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// key returns the key identifying the client making the request.
+func (l *ConcurrencyLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// longLived is the configuration set with [WithLongLived].
+type longLived struct {
+	limiter  *ConcurrencyLimiter
+	matchers []RequestMatcher
+}
+
+// WithLongLived counts the long-lived connections matching any of matchers against limiter
+// instead of the rate limiter of the middleware, so that a client holding a stream open for hours
+// is limited in how many streams it holds, rather than consuming its request budget once per
+// stream. Without matchers, the requests matched by [MatchStreaming] are long-lived.
+//
+// Behavior:
+//   - A long-lived request takes a slot of the client making it from limiter, and is forwarded to
+//     the next handler without being checked against the rate limiter. The slot is freed when the
+//     next handler returns, which is when the connection is closed.
+//   - A long-lived request of a client with no free slot is passed to the denied handler. It is
+//     still forwarded in shadow mode. See [WithShadowMode].
+//   - Errors of limiter are handled according to the failure policy, like errors of the rate
+//     limiter.
+//   - Decisions are logged with [WithLogger] and reported to the hooks set with [WithHooks], with
+//     no rate limit data, and no rate limit headers are added to the response.
+//
+// Example usage:
+//
+//	streams := NewConcurrencyLimiter(5)
+//	http.Handle("/", New(NewTokenBucketLimiter(10, 20), WithLongLived(streams))(myHandler))
+func WithLongLived(limiter *ConcurrencyLimiter, matchers ...RequestMatcher) Option {
+	if len(matchers) == 0 {
+		matchers = []RequestMatcher{MatchStreaming}
+	}
+	return func(o *options) {
+		o.longLived = &longLived{limiter: limiter, matchers: matchers}
+	}
+}
+
+// MatchStreaming is a [RequestMatcher] matching requests for long-lived connections: requests for
+// Server-Sent Events, which accept the text/event-stream media type, and WebSocket handshakes.
+func MatchStreaming(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(strings.ToLower(accept), "text/event-stream") {
+			return true
+		}
+	}
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// serveLongLived serves the long-lived request r, holding a slot of the concurrency limiter while
+// next handles it. rateLimiter is the rate limiter of the middleware, whose key identifies r in log
+// events.
+func (o *options) serveLongLived(w http.ResponseWriter, r *http.Request, next http.Handler, rateLimiter RateLimiter) {
+	release, ok, err := o.longLived.limiter.Acquire(r.Context(), r)
+	if err != nil {
+		o.logError(r, rateLimiter, err)
+		o.failed(r, err)
+		if o.shadow {
+			next.ServeHTTP(w, r)
+			return
+		}
+		o.handleError(w, r, next, err)
+		return
+	}
+	if !ok {
+		o.logDenied(r, rateLimiter, RateLimitData{})
+		o.denied(r, RateLimitData{})
+		o.publishDenied(r, rateLimiter, RateLimitData{})
+		if !o.shadow {
+			o.deniedHandler.ServeHTTP(w, r)
+			return
+		}
+	} else {
+		defer release()
+		o.allowed(r, RateLimitData{})
+	}
+	next.ServeHTTP(w, r)
+}
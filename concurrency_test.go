@@ -0,0 +1,158 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newStreamRequest returns a request for Server-Sent Events from remoteAddr.
+func newStreamRequest(remoteAddr string) *http.Request {
+	req := newRequestFrom(remoteAddr)
+	req.Header.Set("Accept", "text/event-stream")
+	return req
+}
+
+// TestConcurrencyLimiterAcquire tests that a client may hold at most the maximum number of
+// connections, and that releasing one frees a slot.
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewConcurrencyLimiter(2)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	release, ok, err := limiter.Acquire(ctx, req)
+	if !ok || err != nil {
+		t.Fatalf("expected the first connection to be allowed; got %v, %v", ok, err)
+	}
+	if _, ok, _ := limiter.Acquire(ctx, req); !ok {
+		t.Errorf("expected the second connection to be allowed")
+	}
+	if _, ok, _ := limiter.Acquire(ctx, req); ok {
+		t.Errorf("expected the third connection to be denied")
+	}
+	if _, ok, _ := limiter.Acquire(ctx, newRequestFrom("192.0.2.2:1234")); !ok {
+		t.Errorf("expected a connection of another client to be allowed")
+	}
+	if n, _ := limiter.InUse(ctx, "192.0.2.1"); n != 2 {
+		t.Errorf("expected 2 connections in use; got %d", n)
+	}
+
+	release()
+	if n, _ := limiter.InUse(ctx, "192.0.2.1"); n != 1 {
+		t.Errorf("expected 1 connection in use after a release; got %d", n)
+	}
+	if _, ok, _ := limiter.Acquire(ctx, req); !ok {
+		t.Errorf("expected a connection to be allowed after a release")
+	}
+
+	limiter.Reset(ctx, "192.0.2.1")
+	if n, _ := limiter.InUse(ctx, "192.0.2.1"); n != 0 {
+		t.Errorf("expected no connection in use after a reset; got %d", n)
+	}
+}
+
+// TestConcurrencyLimiterErrors tests that key and store errors are returned.
+func TestConcurrencyLimiterErrors(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewConcurrencyLimiter(1, WithKeyFunc(KeyByHeader("X-API-Key")))
+	if _, _, err := limiter.Acquire(ctx, newRequestFrom("192.0.2.1:1234")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected %v; got %v", ErrNoKey, err)
+	}
+
+	store := NewMemoryStore()
+	store.Set(ctx, "192.0.2.1", []byte("malformed"), 0)
+	limiter = NewConcurrencyLimiter(1, WithStore(store))
+	if _, _, err := limiter.Acquire(ctx, newRequestFrom("192.0.2.1:1234")); !errors.Is(err, ErrMalformedValue) {
+		t.Errorf("expected %v; got %v", ErrMalformedValue, err)
+	}
+}
+
+// TestWithLongLived tests that long-lived requests are limited by number rather than by the rate
+// limiter, and hold their slot while they are served.
+func TestWithLongLived(t *testing.T) {
+	streams := NewConcurrencyLimiter(1)
+	rateLimiter := &MockRateLimiter{IsAllowedFunc: func(*http.Request) (bool, error) { return false, nil }}
+	var denied int
+	hooks := Hooks{OnDenied: func(*http.Request, RateLimitData) { denied++ }}
+	var inner *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = New(rateLimiter, WithLongLived(streams), WithHooks(hooks))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// While the first stream is open, another stream of the same client is denied.
+		if inner == nil {
+			inner = httptest.NewRecorder()
+			handler.ServeHTTP(inner, newStreamRequest("192.0.2.1:1234"))
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newStreamRequest("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the stream to bypass the rate limiter; got status %d", rr.Code)
+	}
+	if inner.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a concurrent stream to be denied; got status %d", inner.Code)
+	}
+	if denied != 1 {
+		t.Errorf("expected 1 denial to be reported; got %d", denied)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newStreamRequest("192.0.2.1:1234"))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected a stream to be allowed once the first one closed; got status %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected other requests to be checked against the rate limiter; got status %d", rr.Code)
+	}
+}
+
+// TestWithLongLivedShadowAndErrors tests that long-lived requests denied in shadow mode are still
+// served, and that errors of the concurrency limiter follow the failure policy.
+func TestWithLongLivedShadowAndErrors(t *testing.T) {
+	rateLimiter := &MockRateLimiter{IsAllowedFunc: func(*http.Request) (bool, error) { return true, nil }}
+	streams := NewConcurrencyLimiter(1)
+	streams.Acquire(context.Background(), newRequestFrom("192.0.2.1:1234"))
+	handler := New(rateLimiter, WithLongLived(streams), WithShadowMode(true))(noContent)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newStreamRequest("192.0.2.1:1234"))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected the stream to be served in shadow mode; got status %d", rr.Code)
+	}
+
+	streams = NewConcurrencyLimiter(1, WithKeyFunc(KeyByHeader("X-API-Key")))
+	handler = New(rateLimiter, WithLongLived(streams), WithFailurePolicy(FailOpen))(noContent)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newStreamRequest("192.0.2.1:1234"))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected the stream to be served with FailOpen; got status %d", rr.Code)
+	}
+}
+
+// TestMatchStreaming tests that Server-Sent Events and WebSocket handshakes are matched.
+func TestMatchStreaming(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"event stream", http.Header{"Accept": {"text/event-stream"}}, true},
+		{"event stream among others", http.Header{"Accept": {"text/html, Text/Event-Stream;q=0.9"}}, true},
+		{"websocket", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"WebSocket"}}, true},
+		{"regular", http.Header{"Accept": {"application/json"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequestFrom("192.0.2.1:1234")
+			req.Header = tt.header
+			if got := MatchStreaming(req); got != tt.want {
+				t.Errorf("expected %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
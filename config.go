@@ -0,0 +1,378 @@
+package cerberus
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MiddlewareConfig is a declarative description of a rate limiting middleware, so that limits can
+// be managed as configuration rather than code. It is usually loaded from a JSON or YAML file with
+// [LoadConfig]:
+//
+//	store:
+//	  type: memory
+//	  options: {max_entries: "100000"}
+//	header_style: ietf
+//	default: {key: ip, algorithm: token_bucket, limit: 100, window: 1m}
+//	routes:
+//	  - {pattern: "POST /login", algorithm: sliding_window, limit: 5, window: 1m}
+//	  - pattern: "/api/items/*"
+//	    methods:
+//	      POST: {algorithm: sliding_window, limit: 60, window: 1m}
+//	      DELETE: {algorithm: sliding_window, limit: 10, window: 1m}
+//	  - {pattern: "/api/*", key: "header:X-API-Key", algorithm: gcra, limit: 1000, window: 1m}
+//
+// The middleware checks each request against the limit of the first matching route, or against
+// the default limit if no route matches, with the semantics of a [PolicyRouter]. Requests that
+// match no route are allowed if there is no default limit.
+type MiddlewareConfig struct {
+	// Store is the backend keeping the state of all limits. The default is a [MemoryStore].
+	Store StoreConfig `json:"store" yaml:"store"`
+
+	// HeaderStyle is the set of rate limit headers added to responses: "legacy" (the default) for
+	// [HeaderStyleLegacy], "ietf" for [HeaderStyleIETF], or "none" to omit them.
+	HeaderStyle string `json:"header_style" yaml:"header_style"`
+
+	// Default is the limit of requests that match no route, if any.
+	Default *RouteConfig `json:"default" yaml:"default"`
+
+	// Routes are the limits of specific routes, in order of precedence.
+	Routes []RouteConfig `json:"routes" yaml:"routes"`
+}
+
+// RouteConfig describes the limit of a route of a [MiddlewareConfig].
+type RouteConfig struct {
+	// Pattern selects the requests of the route, with the syntax of [PolicyRouter.Handle]. It is
+	// ignored for the default limit.
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// Key selects how requests are mapped to rate limiting keys: "ip" (the default) for
+	// [KeyByIP], "forwarded_for" for [KeyByForwardedFor], "path" for [KeyByPath], "cert_cn",
+	// "cert_dns", and "spiffe_id" for [KeyByCertCommonName], [KeyByCertDNSName], and
+	// [KeyBySPIFFEID], or one of "header:NAME", "cookie:NAME", and "query:NAME" for
+	// [KeyByHeader], [KeyByCookie], and [KeyByQueryParam]. A key containing braces is a template
+	// of [KeyByTemplate], such as "{ip}:{path}".
+	Key string `json:"key" yaml:"key"`
+
+	// Algorithm, Limit and Window describe the limit, as in a [Config].
+	Algorithm Algorithm     `json:"algorithm" yaml:"algorithm"`
+	Limit     int           `json:"limit" yaml:"limit"`
+	Window    time.Duration `json:"window" yaml:"window"`
+
+	// Costs are the costs of the requests of specific routes, weighed against the limit as with a
+	// [CostMap], in order of precedence. Other requests cost one. Methods default to the costs of
+	// their route.
+	Costs []CostConfig `json:"costs" yaml:"costs"`
+
+	// Methods are the limits of specific methods of the route, such as "POST" or "DELETE", with the
+	// semantics of [PolicyRouter.HandleMethods]. Their Key, Algorithm, and Window default to those
+	// of the route, and they cannot have a pattern or methods of their own. The limit of the route,
+	// if it has one, applies to the other methods; otherwise requests with other methods are
+	// checked against the next matching route. Methods are not supported for the default limit.
+	Methods map[string]RouteConfig `json:"methods" yaml:"methods"`
+}
+
+// UnmarshalJSON decodes the route config, parsing its window as a duration string. Unknown fields
+// are rejected.
+func (c *RouteConfig) UnmarshalJSON(data []byte) error {
+	type routeConfig RouteConfig
+	var raw struct {
+		routeConfig
+		Window string `json:"window"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	*c = RouteConfig(raw.routeConfig)
+	c.Window = 0
+	if raw.Window == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(raw.Window)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	c.Window = window
+	return nil
+}
+
+// CostConfig describes the cost of the requests of a route in a [RouteConfig], such as
+// {pattern: "GET /search", cost: 5}.
+type CostConfig struct {
+	// Pattern selects the requests of the route, with the syntax of [PolicyRouter.Handle].
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// Cost is how much of the budget of a client each request consumes. A cost of zero exempts
+	// the requests from rate limiting.
+	Cost int `json:"cost" yaml:"cost"`
+}
+
+// StoreConfig describes the [Store] of a [MiddlewareConfig].
+type StoreConfig struct {
+	// Type is the name the store backend was registered under with [RegisterStore]. The default
+	// is "memory", for a [MemoryStore] accepting the options "max_entries" and "cleanup_interval".
+	Type string `json:"type" yaml:"type"`
+
+	// Options are passed to the [StoreFactory] of the backend.
+	Options map[string]string `json:"options" yaml:"options"`
+}
+
+// StoreFactory creates a [Store] from the options of a [StoreConfig]. It returns an error if the
+// options are invalid or the backend cannot be reached.
+type StoreFactory func(options map[string]string) (Store, error)
+
+var (
+	storeFactoriesMu sync.RWMutex
+	storeFactories   = map[string]StoreFactory{"memory": newMemoryStoreFromOptions}
+)
+
+// RegisterStore makes a store backend available to [MiddlewareConfig] under name, typically from
+// the init function of the package implementing it. It panics if factory is nil or if a backend is
+// already registered under name.
+//
+// Example usage: RegisterStore("redis", func(options map[string]string) (Store, error) { return newRedisStore(options["addr"]) })
+func RegisterStore(name string, factory StoreFactory) {
+	storeFactoriesMu.Lock()
+	defer storeFactoriesMu.Unlock()
+	if factory == nil {
+		panic("cerberus: store factory must not be nil")
+	}
+	if _, ok := storeFactories[name]; ok {
+		panic(fmt.Sprintf("cerberus: store %q registered twice", name))
+	}
+	storeFactories[name] = factory
+}
+
+// LoadConfig loads the [MiddlewareConfig] in the JSON or YAML file at path and creates the
+// middleware it describes, as a [Handle] closing its store. The format is chosen by the extension
+// of the file: ".json", ".yaml", or ".yml". opts are applied after the settings of the file. An
+// error wrapping [ErrInvalidConfig] is returned if the file does not describe a valid middleware.
+//
+// Example usage:
+//
+//	limit, err := LoadConfig("/etc/myservice/limits.yaml", WithLogger(slog.Default()))
+//	...
+//	server := &http.Server{Addr: ":8080", Handler: limit.Wrap(mux)}
+//	...
+//	server.Shutdown(ctx)
+//	limit.Close(ctx)
+func LoadConfig(path string, opts ...Option) (*Handle, error) {
+	var config MiddlewareConfig
+	if err := readConfigFile(path, &config); err != nil {
+		return nil, err
+	}
+	return config.Middleware(opts...)
+}
+
+// Middleware creates the middleware described by the config, as a [Handle] closing the store it
+// created. opts are applied after the settings of the config. An error wrapping
+// [ErrInvalidConfig] is returned if the config is invalid, and the error of the [StoreFactory] if
+// the store cannot be created, in which case no store is left open.
+func (c MiddlewareConfig) Middleware(opts ...Option) (*Handle, error) {
+	var configOpts []Option
+	switch c.HeaderStyle {
+	case "", "legacy":
+	case "ietf":
+		configOpts = append(configOpts, WithHeaderStyle(HeaderStyleIETF))
+	case "none":
+		configOpts = append(configOpts, WithHeaders(false))
+	default:
+		return nil, fmt.Errorf("%w: unknown header style %q", ErrInvalidConfig, c.HeaderStyle)
+	}
+	store, err := c.Store.newStore()
+	if err != nil {
+		return nil, err
+	}
+	router, err := c.newRouter(store)
+	if err != nil {
+		closeAll(context.Background(), store)
+		return nil, err
+	}
+	if closer, ok := store.(Closer); ok {
+		configOpts = append(configOpts, WithClosers(closer))
+	}
+	return NewHandle(router, append(configOpts, opts...)...), nil
+}
+
+// newRouter creates the router enforcing the routes of the config, keeping the state of their
+// limits in store.
+func (c MiddlewareConfig) newRouter(store Store) (*PolicyRouter, error) {
+	router := NewPolicyRouter()
+	for _, routeConfig := range c.Routes {
+		route, err := routeConfig.newRoute(store)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", routeConfig.Pattern, err)
+		}
+		router.routes = append(router.routes, route)
+	}
+	if c.Default != nil {
+		if c.Default.Methods != nil {
+			return nil, fmt.Errorf("%w: default route: methods are not supported", ErrInvalidConfig)
+		}
+		rateLimiter, err := c.Default.newLimiter(store, "")
+		if err != nil {
+			return nil, fmt.Errorf("default route: %w", err)
+		}
+		router.HandleDefault(rateLimiter)
+	}
+	return router, nil
+}
+
+// newRoute creates the route described by the config, keeping the state of its limits in store.
+func (c RouteConfig) newRoute(store Store) (route, error) {
+	route, err := parseRoute(c.Pattern, nil)
+	if err != nil {
+		return route, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if c.Methods == nil || c.Limit != 0 || c.Algorithm != "" {
+		route.limiter, err = c.newLimiter(store, c.Pattern)
+		if err != nil {
+			return route, err
+		}
+	}
+	if c.Methods == nil {
+		return route, nil
+	}
+	route.byMethod = make(map[string]RateLimiter, len(c.Methods)+1)
+	if route.limiter != nil {
+		route.byMethod["*"] = route.limiter
+	}
+	for method, methodConfig := range c.Methods {
+		if methodConfig.Pattern != "" || methodConfig.Methods != nil {
+			return route, fmt.Errorf("%w: method %q cannot have a pattern or methods", ErrInvalidConfig, method)
+		}
+		methodConfig.Key = cmp.Or(methodConfig.Key, c.Key)
+		methodConfig.Algorithm = cmp.Or(methodConfig.Algorithm, c.Algorithm)
+		methodConfig.Window = cmp.Or(methodConfig.Window, c.Window)
+		if methodConfig.Costs == nil {
+			methodConfig.Costs = c.Costs
+		}
+		method = strings.ToUpper(strings.TrimSpace(method))
+		rateLimiter, err := methodConfig.newLimiter(store, method+" "+c.Pattern)
+		if err != nil {
+			return route, fmt.Errorf("method %q: %w", method, err)
+		}
+		route.byMethod[method] = rateLimiter
+	}
+	return route, nil
+}
+
+// newLimiter creates the built-in limiter enforcing the route config, keeping its state in store
+// under keys prefixed with prefix.
+func (c RouteConfig) newLimiter(store Store, prefix string) (RateLimiter, error) {
+	config := Config{Algorithm: c.Algorithm, Limit: c.Limit, Window: c.Window}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	keyFunc, err := parseKeyFunc(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	opts := []LimiterOption{WithKeyFunc(keyFunc), WithStore(prefixedStore{Store: store, prefix: prefix + ":"})}
+	if c.Costs != nil {
+		costs, err := newCostMap(c.Costs)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithCostFunc(costs.Cost))
+	}
+	return newBuiltinLimiter(config, opts...), nil
+}
+
+// newCostMap creates the [CostMap] of the costs of a [RouteConfig], in which other requests cost
+// one. An error wrapping [ErrInvalidConfig] is returned if a cost is invalid.
+func newCostMap(costs []CostConfig) (*CostMap, error) {
+	costMap := NewCostMap(1)
+	for _, cost := range costs {
+		if cost.Cost < 0 {
+			return nil, fmt.Errorf("%w: cost of %q must not be negative", ErrInvalidConfig, cost.Pattern)
+		}
+		route, err := parseRoute(cost.Pattern, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+		costMap.routes = append(costMap.routes, costRoute{route: route, cost: cost.Cost})
+	}
+	return costMap, nil
+}
+
+// parseKeyFunc returns the [KeyFunc] selected by the key setting of a [RouteConfig].
+func parseKeyFunc(spec string) (KeyFunc, error) {
+	switch spec {
+	case "", "ip":
+		return KeyByIP, nil
+	case "forwarded_for":
+		return KeyByForwardedFor, nil
+	case "path":
+		return KeyByPath, nil
+	case "cert_cn":
+		return KeyByCertCommonName, nil
+	case "cert_dns":
+		return KeyByCertDNSName, nil
+	case "spiffe_id":
+		return KeyBySPIFFEID, nil
+	}
+	if strings.ContainsAny(spec, "{}") {
+		return parseKeyTemplate(spec)
+	}
+	kind, name, ok := strings.Cut(spec, ":")
+	if ok && name != "" {
+		switch kind {
+		case "header":
+			return KeyByHeader(name), nil
+		case "cookie":
+			return KeyByCookie(name), nil
+		case "query":
+			return KeyByQueryParam(name), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidConfig, spec)
+}
+
+// newStore creates the store described by the config.
+func (c StoreConfig) newStore() (Store, error) {
+	name := c.Type
+	if name == "" {
+		name = "memory"
+	}
+	storeFactoriesMu.RLock()
+	factory, ok := storeFactories[name]
+	storeFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown store %q", ErrInvalidConfig, name)
+	}
+	return factory(c.Options)
+}
+
+// newMemoryStoreFromOptions is the [StoreFactory] of [MemoryStore].
+func newMemoryStoreFromOptions(options map[string]string) (Store, error) {
+	var opts []MemoryStoreOption
+	for name, value := range options {
+		switch name {
+		case "max_entries":
+			maxEntries, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: max_entries: %w", ErrInvalidConfig, err)
+			}
+			opts = append(opts, WithMaxEntries(maxEntries))
+		case "cleanup_interval":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: cleanup_interval: %w", ErrInvalidConfig, err)
+			}
+			opts = append(opts, WithCleanupInterval(interval))
+		default:
+			return nil, fmt.Errorf("%w: unknown memory store option %q", ErrInvalidConfig, name)
+		}
+	}
+	return NewMemoryStore(opts...), nil
+}
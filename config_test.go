@@ -0,0 +1,258 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes content to a file named name in a temporary directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serve sends a request with the given method, path, and API key through the middleware and
+// returns the response.
+func serve(middleware func(http.Handler) http.Handler, method, path, apiKey string) *httptest.ResponseRecorder {
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(method, path, nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// Test a YAML config materializes routes, keys, and the default limit
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfigFile(t, "limits.yaml", `
+store:
+  type: memory
+  options: {max_entries: 1000}
+header_style: ietf
+default: {algorithm: fixed_window, limit: 3, window: 1m}
+routes:
+  - {pattern: "POST /login", algorithm: sliding_window, limit: 1, window: 1m}
+  - {pattern: "/api/*", key: "header:X-API-Key", algorithm: token_bucket, limit: 2, window: 1h}
+`)
+
+	middleware, err := LoadConfig(path)
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if rr := serve(middleware.Wrap, "POST", "/login", ""); rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("expected first login to be allowed with IETF headers; got %v %v", rr.Code, rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "POST", "/login", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected second login to be denied; got %v", rr.Code)
+	}
+	for range 2 {
+		serve(middleware.Wrap, "GET", "/api/users", "alice")
+	}
+	if rr := serve(middleware.Wrap, "GET", "/api/users", "alice"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected third API request of alice to be denied; got %v", rr.Code)
+	}
+	if rr := serve(middleware.Wrap, "GET", "/api/users", "bob"); rr.Code != http.StatusOK {
+		t.Errorf("expected API request of bob to be allowed; got %v", rr.Code)
+	}
+	if rr := serve(middleware.Wrap, "GET", "/", ""); rr.Header().Get("RateLimit-Limit") != "3" {
+		t.Errorf("expected default limit of 3; got %v", rr.Header())
+	}
+}
+
+// Test a JSON config is loaded and options are applied after its settings
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfigFile(t, "limits.json", `{
+		"header_style": "none",
+		"routes": [{"pattern": "GET /search", "algorithm": "gcra", "limit": 1, "window": "1m"}]
+	}`)
+
+	middleware, err := LoadConfig(path, WithHeaders(true))
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if rr := serve(middleware.Wrap, "GET", "/search", ""); rr.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("expected headers to be turned back on; got %v", rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "GET", "/search", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected second search to be denied; got %v", rr.Code)
+	}
+	if rr := serve(middleware.Wrap, "GET", "/other", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected unmatched request to be allowed; got %v", rr.Code)
+	}
+}
+
+// Test routes can carry different limits per method, inheriting the settings of the route
+func TestLoadConfigMethods(t *testing.T) {
+	path := writeConfigFile(t, "limits.yaml", `
+routes:
+  - pattern: "/items/*"
+    algorithm: fixed_window
+    limit: 3
+    window: 1m
+    methods:
+      POST: {limit: 2}
+      delete: {algorithm: sliding_window, limit: 1}
+  - pattern: "/admin/*"
+    methods:
+      DELETE: {algorithm: gcra, limit: 1, window: 1m}
+  - {pattern: "/admin/*", algorithm: fixed_window, limit: 5, window: 1m}
+`)
+
+	middleware, err := LoadConfig(path)
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	for method, limit := range map[string]string{"GET": "3", "POST": "2", "DELETE": "1"} {
+		if rr := serve(middleware.Wrap, method, "/items/42", ""); rr.Header().Get("X-RateLimit-Limit") != limit {
+			t.Errorf("%s: expected a limit of %s; got %v", method, limit, rr.Header())
+		}
+	}
+	if rr := serve(middleware.Wrap, "DELETE", "/admin/users", ""); rr.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("expected a limit of 1 for DELETE; got %v", rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "GET", "/admin/users", ""); rr.Header().Get("X-RateLimit-Limit") != "5" {
+		t.Errorf("expected GET to fall through to the next route; got %v", rr.Header())
+	}
+}
+
+// Test the costs of a route weigh its requests, and default to the costs of the route for methods
+func TestLoadConfigCosts(t *testing.T) {
+	path := writeConfigFile(t, "limits.yaml", `
+routes:
+  - pattern: "/api/*"
+    algorithm: fixed_window
+    limit: 10
+    window: 1m
+    costs:
+      - {pattern: "GET /api/search", cost: 4}
+      - {pattern: "/api/health", cost: 0}
+    methods:
+      POST: {limit: 5}
+`)
+
+	middleware, err := LoadConfig(path)
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if rr := serve(middleware.Wrap, "GET", "/api/search", ""); rr.Header().Get("X-RateLimit-Remaining") != "6" {
+		t.Errorf("expected a search to cost 4; got %v", rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "GET", "/api/items", ""); rr.Header().Get("X-RateLimit-Remaining") != "5" {
+		t.Errorf("expected other requests to cost 1; got %v", rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "GET", "/api/health", ""); rr.Header().Get("X-RateLimit-Remaining") != "5" {
+		t.Errorf("expected health checks to cost nothing; got %v", rr.Header())
+	}
+	if rr := serve(middleware.Wrap, "POST", "/api/search", ""); rr.Header().Get("X-RateLimit-Remaining") != "4" {
+		t.Errorf("expected a search with another method to cost 1; got %v", rr.Header())
+	}
+}
+
+// Test invalid configs are rejected with ErrInvalidConfig
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown field":     `routes: [{pattern: /a, algorithm: gcra, limit: 1, window: 1s, burst: 2}]`,
+		"unknown algorithm": `routes: [{pattern: /a, algorithm: magic, limit: 1, window: 1s}]`,
+		"missing limit":     `default: {algorithm: gcra, window: 1s}`,
+		"malformed pattern": `routes: [{pattern: "GET a", algorithm: gcra, limit: 1, window: 1s}]`,
+		"unknown key":       `routes: [{pattern: /a, key: "jwt:sub", algorithm: gcra, limit: 1, window: 1s}]`,
+		"unknown template":  `routes: [{pattern: /a, key: "{ip}:{jwt:sub}", algorithm: gcra, limit: 1, window: 1s}]`,
+		"unknown store":     `store: {type: carrier-pigeon}`,
+		"bad store option":  `store: {options: {max_entries: many}}`,
+		"header style":      `header_style: loud`,
+		"method pattern":    `routes: [{pattern: /a, methods: {GET: {pattern: /b, algorithm: gcra, limit: 1, window: 1s}}}]`,
+		"method limit":      `routes: [{pattern: /a, methods: {GET: {algorithm: gcra, window: 1s}}}]`,
+		"default methods":   `default: {algorithm: gcra, limit: 1, window: 1s, methods: {GET: {limit: 2}}}`,
+		"negative cost":     `default: {algorithm: gcra, limit: 1, window: 1s, costs: [{pattern: /a, cost: -1}]}`,
+		"cost pattern":      `default: {algorithm: gcra, limit: 1, window: 1s, costs: [{pattern: a, cost: 1}]}`,
+	} {
+		_, err := LoadConfig(writeConfigFile(t, "limits.yaml", content))
+
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig; got %v", name, err)
+		}
+	}
+}
+
+// Test registered store backends are used by configs
+func TestRegisterStore(t *testing.T) {
+	var options map[string]string
+	// The registry is global, so the backend is unregistered for the test to run more than once.
+	t.Cleanup(func() {
+		storeFactoriesMu.Lock()
+		defer storeFactoriesMu.Unlock()
+		delete(storeFactories, "test")
+	})
+	RegisterStore("test", func(o map[string]string) (Store, error) {
+		options = o
+		return NewMemoryStore(), nil
+	})
+
+	_, err := LoadConfig(writeConfigFile(t, "limits.yaml", `store: {type: test, options: {addr: "localhost:6379"}}`))
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if options["addr"] != "localhost:6379" {
+		t.Errorf("expected options to be passed to the factory; got %v", options)
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "registered twice") {
+			t.Errorf("expected panic for duplicate registration; got %v", r)
+		}
+	}()
+	RegisterStore("test", func(map[string]string) (Store, error) { return nil, nil })
+}
+
+// closeCountingStore is a Store counting the calls to Close.
+type closeCountingStore struct {
+	Store
+	closes int
+}
+
+func (s *closeCountingStore) Close(context.Context) error {
+	s.closes++
+	return nil
+}
+
+// Test the store of a config is closed when the config is invalid, and with the handle otherwise
+func TestMiddlewareConfigClosesStore(t *testing.T) {
+	store := &closeCountingStore{Store: NewMemoryStore(WithCleanupInterval(0))}
+	t.Cleanup(func() {
+		storeFactoriesMu.Lock()
+		defer storeFactoriesMu.Unlock()
+		delete(storeFactories, "closing")
+	})
+	RegisterStore("closing", func(map[string]string) (Store, error) { return store, nil })
+
+	_, err := LoadConfig(writeConfigFile(t, "limits.yaml", "store: {type: closing}\nroutes: [{pattern: /a, algorithm: magic, limit: 1, window: 1s}]"))
+	if !errors.Is(err, ErrInvalidConfig) || store.closes != 1 {
+		t.Errorf("expected ErrInvalidConfig and the store to be closed; got %v and %d closes", err, store.closes)
+	}
+
+	handle, err := LoadConfig(writeConfigFile(t, "limits.yaml", "store: {type: closing}\ndefault: {algorithm: gcra, limit: 1, window: 1s}"))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if err := handle.Close(context.Background()); err != nil || store.closes != 2 {
+		t.Errorf("expected the handle to close the store; got %v and %d closes", err, store.closes)
+	}
+}
@@ -0,0 +1,110 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WatchConfigFile loads the [Config] of limiter from the JSON or YAML file at path, then checks
+// the file for changes every interval and reloads it whenever it changed, until ctx is done. The
+// format is chosen by the extension of the file: ".json", ".yaml", or ".yml".
+//
+// An error is returned if the file cannot be loaded initially, in which case the file is not
+// watched. Later failures, such as a syntax error introduced by an edit, keep the current config
+// in place until the file is fixed.
+//
+// Behavior:
+//   - Reloads are logged at level Info with the message "rate limit config reloaded" if logger is
+//     not nil.
+//   - Failed reloads are logged at level Error with the message "rate limit config reload failed"
+//     if logger is not nil.
+//
+// Example usage: err := WatchConfigFile(ctx, "/etc/myservice/limits.yaml", 10*time.Second, myDynamicLimiter, slog.Default())
+func WatchConfigFile(ctx context.Context, path string, interval time.Duration, limiter *DynamicLimiter, logger *slog.Logger) error {
+	if interval <= 0 {
+		panic("cerberus: config watch interval must be positive")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := reloadConfigFile(path, limiter); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			latest, err := os.Stat(path)
+			if err == nil && latest.ModTime().Equal(info.ModTime()) && latest.Size() == info.Size() {
+				continue
+			}
+			if err == nil {
+				info = latest
+				err = reloadConfigFile(path, limiter)
+			}
+			if logger == nil {
+				continue
+			}
+			if err != nil {
+				logger.LogAttrs(ctx, slog.LevelError, "rate limit config reload failed",
+					slog.String("path", path), slog.Any("error", err))
+				continue
+			}
+			config := limiter.Config()
+			logger.LogAttrs(ctx, slog.LevelInfo, "rate limit config reloaded",
+				slog.String("path", path),
+				slog.String("algorithm", string(config.Algorithm)),
+				slog.Int("limit", config.Limit),
+				slog.Duration("window", config.Window))
+		}
+	}()
+	return nil
+}
+
+// reloadConfigFile replaces the config of limiter with the one in the file at path.
+func reloadConfigFile(path string, limiter *DynamicLimiter) error {
+	var config Config
+	if err := readConfigFile(path, &config); err != nil {
+		return err
+	}
+	return limiter.UpdateConfig(config)
+}
+
+// readConfigFile decodes the JSON or YAML file at path into v, depending on its extension.
+// Unknown fields are rejected.
+func readConfigFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(v)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(v)
+	default:
+		return fmt.Errorf("%w: unsupported file extension %q", ErrInvalidConfig, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, for loggers written by the watcher.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Test the config is loaded from YAML and reloaded when the file changes
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	os.WriteFile(path, []byte("algorithm: fixed_window\nlimit: 10\nwindow: 1m\n"), 0o600)
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Second})
+	var logs syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := WatchConfigFile(ctx, path, 5*time.Millisecond, limiter, slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if expected := (Config{Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Minute}); limiter.Config() != expected {
+		t.Errorf("expected %+v; got %+v", expected, limiter.Config())
+	}
+
+	os.WriteFile(path, []byte("algorithm: fixed_window\nlimit: 20\nwindow: 1m\n"), 0o600)
+	deadline := time.Now().Add(time.Second)
+	for limiter.Config().Limit != 20 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if limiter.Config().Limit != 20 {
+		t.Errorf("expected config to be reloaded; got %+v", limiter.Config())
+	}
+	for !strings.Contains(logs.String(), "rate limit config reloaded") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(logs.String(), `"limit":20`) {
+		t.Errorf("expected reload to be logged; got %s", logs.String())
+	}
+}
+
+// Test an invalid edit is logged and the current config is kept
+func TestWatchConfigFileInvalidEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.json")
+	os.WriteFile(path, []byte(`{"algorithm": "gcra", "limit": 10, "window": "1s"}`), 0o600)
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Second})
+	var logs syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchConfigFile(ctx, path, 5*time.Millisecond, limiter, slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	os.WriteFile(path, []byte(`{"algorithm": "gcra", "limit": -1, "window": "1s"}`), 0o600)
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "rate limit config reload failed") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if !strings.Contains(logs.String(), "rate limit config reload failed") {
+		t.Errorf("expected failed reload to be logged; got %s", logs.String())
+	}
+	if limiter.Config().Limit != 10 {
+		t.Errorf("expected config to be kept; got %+v", limiter.Config())
+	}
+}
+
+// Test the initial load fails for missing, malformed, and unsupported files
+func TestWatchConfigFileInitialError(t *testing.T) {
+	dir := t.TempDir()
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Second})
+	malformed := filepath.Join(dir, "limits.yaml")
+	os.WriteFile(malformed, []byte("algorithm: gcra\nlimt: 10\n"), 0o600)
+	unsupported := filepath.Join(dir, "limits.toml")
+	os.WriteFile(unsupported, []byte(""), 0o600)
+
+	if err := WatchConfigFile(context.Background(), filepath.Join(dir, "missing.json"), time.Second, limiter, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist; got %v", err)
+	}
+	for _, path := range []string{malformed, unsupported} {
+		if err := WatchConfigFile(context.Background(), path, time.Second, limiter, nil); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %s; got %v", path, err)
+		}
+	}
+}
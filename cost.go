@@ -0,0 +1,92 @@
+package cerberus
+
+import "net/http"
+
+// CostFunc returns the cost of a request, that is how much of a client's budget it consumes.
+// For example, a bulk export may cost 50 while a simple lookup costs 1. A cost of zero exempts
+// the request from rate limiting without consuming any budget, and negative costs count as zero.
+type CostFunc func(*http.Request) int
+
+// WithCostFunc sets the [CostFunc] used to weigh requests. The default gives every request a cost
+// of one. All built-in limiters support costs: a request is allowed only if the client has at
+// least its cost left in its budget, and consumes that much of it. The Remaining field of
+// [RateLimitData] is measured in the same units, and RetryAfter is the time until the budget
+// covers the cost of the request it was reported for. A request costing more than the whole
+// budget of a limiter is always denied.
+//
+// Example usage: NewTokenBucketLimiter(100, 10, WithCostFunc(func(r *http.Request) int { return len(r.URL.Query()["id"]) }))
+func WithCostFunc(costFunc CostFunc) LimiterOption {
+	return func(o *limiterOptions) {
+		o.costFunc = costFunc
+	}
+}
+
+// requestCost returns the cost of r according to costFunc, or one if costFunc is nil.
+func requestCost(costFunc CostFunc, r *http.Request) int {
+	if costFunc == nil {
+		return 1
+	}
+	return max(costFunc(r), 0)
+}
+
+// CostMap is a declarative table of the costs of routes, for use as a [CostFunc] with
+// [WithCostFunc], so that expensive endpoints such as searches or report generation weigh more on
+// the budget of clients without writing a CostFunc by hand.
+//
+// Routes are registered with Handle using the patterns of [PolicyRouter.Handle], such as
+// "GET /search" or "/reports/*". The cost of a request is the one of the first matching route, in
+// the order in which routes were registered, or the default cost if no route matches. Routes
+// must be registered before the cost map is used.
+//
+// Example usage:
+//
+//	costs := NewCostMap(1).
+//		Handle("GET /search", 5).
+//		Handle("POST /reports/*", 50).
+//		Handle("/health", 0)
+//	limiter := NewTokenBucketLimiter(100, 10, WithCostFunc(costs.Cost))
+type CostMap struct {
+	routes      []costRoute
+	defaultCost int
+}
+
+// costRoute is a route of a [CostMap] and the cost of its requests.
+type costRoute struct {
+	route
+	cost int
+}
+
+// NewCostMap creates a new [CostMap] without any routes, in which requests cost defaultCost. It
+// panics if defaultCost is negative.
+func NewCostMap(defaultCost int) *CostMap {
+	if defaultCost < 0 {
+		panic("cerberus: cost must not be negative")
+	}
+	return &CostMap{defaultCost: defaultCost}
+}
+
+// Handle sets the cost of requests matching pattern, and returns the cost map so that calls can
+// be chained. A cost of zero exempts the requests from rate limiting. It panics if pattern is
+// malformed or if cost is negative.
+func (m *CostMap) Handle(pattern string, cost int) *CostMap {
+	if cost < 0 {
+		panic("cerberus: cost must not be negative")
+	}
+	route, err := parseRoute(pattern, nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	m.routes = append(m.routes, costRoute{route: route, cost: cost})
+	return m
+}
+
+// Cost returns the cost of r: the cost of the first route it matches, or the default cost. It is
+// a [CostFunc].
+func (m *CostMap) Cost(r *http.Request) int {
+	for _, route := range m.routes {
+		if route.matches(r) {
+			return route.cost
+		}
+	}
+	return m.defaultCost
+}
@@ -0,0 +1,170 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// costByHeader costs requests according to their X-Cost header, defaulting to one.
+func costByHeader(r *http.Request) int {
+	if cost, err := strconv.Atoi(r.Header.Get("X-Cost")); err == nil {
+		return cost
+	}
+	return 1
+}
+
+// newCostRequest returns a request from a fixed client with the given cost.
+func newCostRequest(cost int) *http.Request {
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-Cost", strconv.Itoa(cost))
+	return req
+}
+
+// Test all built-in limiters charge the cost of requests against the budget
+func TestWithCostFuncChargesCost(t *testing.T) {
+	clock := newFakeClock()
+	withCost := WithCostFunc(costByHeader)
+	tokenBucket := NewTokenBucketLimiter(10, 1, withCost)
+	tokenBucket.now = clock.Now
+	slidingWindow := NewSlidingWindowLimiter(10, time.Minute, withCost)
+	slidingWindow.now = clock.Now
+	fixedWindow := NewFixedWindowLimiter(10, time.Minute, withCost)
+	fixedWindow.now = clock.Now
+	leakyBucket := NewLeakyBucketLimiter(10, 1, withCost)
+	leakyBucket.now = clock.Now
+	gcra := NewGCRALimiter(time.Second, 9*time.Second, withCost)
+	gcra.now = clock.Now
+	adaptive := NewAdaptiveLimiter(10, 10, time.Minute, func() float64 { return 0 }, 1, withCost)
+	adaptive.counter.now = clock.Now
+
+	limiters := map[string]AdvancedRateLimiter{
+		"token bucket":   tokenBucket,
+		"sliding window": slidingWindow,
+		"fixed window":   fixedWindow,
+		"leaky bucket":   leakyBucket,
+		"GCRA":           gcra,
+		"adaptive":       adaptive,
+	}
+	for name, limiter := range limiters {
+		if isAllowed, err := limiter.IsAllowed(newCostRequest(7)); err != nil || !isAllowed {
+			t.Errorf("%s: expected request costing 7 to be allowed; got %v, %v", name, isAllowed, err)
+		}
+		data := limiter.GetRateLimitData(newCostRequest(5))
+		if data.Remaining != 3 || data.RetryAfter <= 0 {
+			t.Errorf("%s: expected 3 remaining and a retry delay for a request costing 5; got %+v", name, data)
+		}
+		if isAllowed, _ := limiter.IsAllowed(newCostRequest(5)); isAllowed {
+			t.Errorf("%s: expected request costing 5 to be denied", name)
+		}
+		if isAllowed, _ := limiter.IsAllowed(newCostRequest(3)); !isAllowed {
+			t.Errorf("%s: expected request costing 3 to be allowed after a denied costly request", name)
+		}
+	}
+}
+
+// Test requests costing zero are always allowed
+func TestWithCostFuncZeroCost(t *testing.T) {
+	limiter := NewGCRALimiter(time.Second, 0, WithCostFunc(costByHeader))
+	limiter.IsAllowed(newCostRequest(1))
+
+	if isAllowed, _ := limiter.IsAllowed(newCostRequest(0)); !isAllowed {
+		t.Errorf("expected request costing 0 to be allowed")
+	}
+}
+
+// Test requests costing zero leave no state behind in the store
+func TestWithCostFuncZeroCostStoresNothing(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close(context.Background())
+	limiters := map[string]RateLimiter{
+		"token bucket": NewTokenBucketLimiter(10, 1, WithCostFunc(costByHeader), WithStore(prefixedStore{store, "tb:"})),
+		"leaky bucket": NewLeakyBucketLimiter(10, 1, WithCostFunc(costByHeader), WithStore(prefixedStore{store, "lb:"})),
+	}
+	for name, limiter := range limiters {
+		if isAllowed, err := limiter.IsAllowed(newCostRequest(0)); !isAllowed || err != nil {
+			t.Errorf("%s: expected request costing 0 to be allowed; got %v, %v", name, isAllowed, err)
+		}
+	}
+	if keys, _ := store.ScanKeys(context.Background(), ""); len(keys) != 0 {
+		t.Errorf("expected no entries for requests costing 0; got %v", keys)
+	}
+}
+
+// Test requests costing more than the whole budget are denied
+func TestWithCostFuncCostExceedingBudget(t *testing.T) {
+	limiter := NewTokenBucketLimiter(10, 1, WithCostFunc(costByHeader))
+	req := newCostRequest(11)
+
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request costing more than the capacity to be denied")
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 10 || data.RetryAfter != 0 {
+		t.Errorf("expected {10 10 0}; got %+v", data)
+	}
+}
+
+// Test the cost map costs requests by the first route they match
+func TestCostMap(t *testing.T) {
+	costs := NewCostMap(1).
+		Handle("GET /search", 5).
+		Handle("/reports/*", 50).
+		Handle("/reports/summary", 2).
+		Handle("/health", 0)
+
+	for target, want := range map[string]int{
+		"GET /search":               5,
+		"POST /search":              1,
+		"POST /reports/2024/q1":     50,
+		"GET /reports/summary":      50,
+		"GET /health":               0,
+		"GET /items/42":             1,
+		"DELETE /reports":           1,
+		"GET /search/suggestions":   1,
+		"HEAD /reports/2024/q1.pdf": 50,
+	} {
+		method, path, _ := strings.Cut(target, " ")
+		if cost := costs.Cost(httptest.NewRequest(method, path, nil)); cost != want {
+			t.Errorf("%s: expected a cost of %d; got %d", target, want, cost)
+		}
+	}
+}
+
+// Test a cost map weighs requests against the budget of a limiter
+func TestCostMapWithLimiter(t *testing.T) {
+	costs := NewCostMap(1).Handle("/export", 8)
+	limiter := NewFixedWindowLimiter(10, time.Minute, WithCostFunc(costs.Cost))
+
+	export := httptest.NewRequest(http.MethodGet, "/export", nil)
+	if isAllowed, _ := limiter.IsAllowed(export); !isAllowed {
+		t.Fatalf("expected the export to be allowed")
+	}
+	if isAllowed, _ := limiter.IsAllowed(export); isAllowed {
+		t.Errorf("expected a second export to be denied")
+	}
+	if data := limiter.GetRateLimitData(httptest.NewRequest(http.MethodGet, "/items", nil)); data.Remaining != 2 {
+		t.Errorf("expected 2 remaining; got %d", data.Remaining)
+	}
+}
+
+// Test the cost map rejects negative costs and malformed patterns
+func TestCostMapPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"negative default":  func() { NewCostMap(-1) },
+		"negative cost":     func() { NewCostMap(1).Handle("/a", -1) },
+		"malformed pattern": func() { NewCostMap(1).Handle("a", 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
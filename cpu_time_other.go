@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cerberus
+
+import "time"
+
+// processCPUTime returns zero, since the CPU time of the process is not available on this platform.
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,175 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lateCheckTimeout bounds the time a [DeadlineLimiter] lets a check that missed its deadline run
+// in the background, so that checks against an unreachable store do not accumulate.
+const lateCheckTimeout = 10 * time.Second
+
+// DeadlineLimiter is a [RateLimiter] and [AdvancedRateLimiter] that bounds the time a rate limiter
+// may take to decide, typically one backed by a remote [Store] such as Redis. When the decision
+// is late, it decides locally instead, so that a slow store never adds more than the deadline to
+// the latency of requests.
+//
+// Behavior:
+//   - If the rate limiter decides within the deadline, its decision is returned.
+//   - Otherwise, the request is allowed with [FailOpen], or denied with [FailClosed], and the
+//     check is left to complete in the background, for up to 10 seconds.
+//   - Late checks are reconciled with the local decision once they complete: a request allowed
+//     locally but denied by the rate limiter is counted with Commit, if it implements
+//     [DeferredRateLimiter], and a request denied locally but allowed by the rate limiter is
+//     refunded, if it implements [RefundableRateLimiter]. The store thus keeps an accurate count
+//     of the requests served, and clients are limited normally once it is fast again.
+//   - Errors of the rate limiter within the deadline are returned, and so is the error of the
+//     context of the request if it is done before the deadline.
+//
+// Unlike a [FallbackLimiter], which checks late requests against another rate limiter, a
+// DeadlineLimiter makes the same decision for all of them, and needs no local state.
+//
+// A DeadlineLimiter is safe for concurrent use by multiple goroutines if its rate limiter is.
+//
+// Example usage:
+//
+//	limiter := NewDeadlineLimiter(
+//		NewTokenBucketLimiter(100, 10, WithStore(myRedisStore)),
+//		5*time.Millisecond,
+//		FailOpen,
+//	)
+type DeadlineLimiter struct {
+	rateLimiter RateLimiter
+	deadline    time.Duration
+	allow       bool
+
+	late sync.WaitGroup
+}
+
+// NewDeadlineLimiter creates a new [DeadlineLimiter] that waits up to deadline for the decision of
+// rateLimiter, and decides with policy once it is exceeded.
+//
+// It panics if deadline is not positive, or if policy is neither [FailOpen] nor [FailClosed].
+func NewDeadlineLimiter(rateLimiter RateLimiter, deadline time.Duration, policy FailurePolicy) *DeadlineLimiter {
+	if deadline <= 0 {
+		panic("cerberus: deadline must be positive")
+	}
+	if policy != FailOpen && policy != FailClosed {
+		panic("cerberus: deadline policy must be FailOpen or FailClosed")
+	}
+	return &DeadlineLimiter{
+		rateLimiter: rateLimiter,
+		deadline:    deadline,
+		allow:       policy == FailOpen,
+	}
+}
+
+// IsAllowed checks the request against the rate limiter, or decides with the policy of the
+// limiter if the rate limiter exceeds the deadline.
+func (l *DeadlineLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiter. The check is not
+// cancelled with ctx, so that late checks can complete in the background.
+func (l *DeadlineLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	type result struct {
+		isAllowed bool
+		err       error
+	}
+	// The channel is buffered so that a late check does not block once nobody waits for it.
+	done := make(chan result, 1)
+	// decided receives the decision the request was served with if the check is late, or is
+	// closed if it is not.
+	decided := make(chan bool, 1)
+	l.late.Add(1)
+	go func() {
+		defer l.late.Done()
+		checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lateCheckTimeout)
+		defer cancel()
+		isAllowed, err := isAllowed(checkCtx, l.rateLimiter, r)
+		done <- result{isAllowed, err}
+		if served, late := <-decided; late && err == nil && isAllowed != served {
+			l.reconcile(checkCtx, r, served)
+		}
+	}()
+	timer := time.NewTimer(l.deadline)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		close(decided)
+		return res.isAllowed, res.err
+	case <-timer.C:
+		decided <- l.allow
+		return l.allow, nil
+	case <-ctx.Done():
+		decided <- false
+		return false, ctx.Err()
+	}
+}
+
+// GetRateLimitData returns the rate limit data reported by the rate limiter, or the zero
+// RateLimitData if it exceeds the deadline or does not implement [AdvancedRateLimiter].
+func (l *DeadlineLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	advanced, ok := l.rateLimiter.(AdvancedRateLimiter)
+	if !ok {
+		return RateLimitData{}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), l.deadline)
+	defer cancel()
+	// The channel is buffered so that a late rate limiter does not block forever.
+	done := make(chan RateLimitData, 1)
+	go func() {
+		done <- advanced.GetRateLimitData(r.WithContext(ctx))
+	}()
+	select {
+	case data := <-done:
+		return data
+	case <-ctx.Done():
+		return RateLimitData{}
+	}
+}
+
+// RefundRequest gives back the budget consumed by the request, if the rate limiter implements
+// [RefundableRateLimiter]. It implements RefundableRateLimiter.
+func (l *DeadlineLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return refund(ctx, []RateLimiter{l.rateLimiter}, r)
+}
+
+// Close waits for the late checks to complete and be reconciled, until ctx is done, and closes the
+// rate limiter, if it implements [Closer]. It implements Closer.
+func (l *DeadlineLimiter) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.late.Wait()
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return closeAll(ctx, l.rateLimiter)
+}
+
+// key returns the key identifying the client making the request, as reported by the rate limiter.
+func (l *DeadlineLimiter) key(r *http.Request) (string, error) {
+	if k, ok := l.rateLimiter.(keyer); ok {
+		return k.key(r)
+	}
+	return "", ErrNoKey
+}
+
+// reconcile brings the count of the rate limiter in line with served, the decision a request was
+// served with after its check, which decided otherwise, exceeded the deadline.
+func (l *DeadlineLimiter) reconcile(ctx context.Context, r *http.Request, served bool) {
+	if !served {
+		refund(ctx, []RateLimiter{l.rateLimiter}, r)
+		return
+	}
+	if deferred, ok := l.rateLimiter.(DeferredRateLimiter); ok {
+		deferred.Commit(ctx, r)
+	}
+}
@@ -0,0 +1,175 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowLimiter decides isAllowed once release is closed, and counts the commits and refunds made
+// afterwards.
+type slowLimiter struct {
+	isAllowed bool
+	release   chan struct{}
+	commits   atomic.Int64
+	refunds   atomic.Int64
+}
+
+func (l *slowLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+func (l *slowLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	select {
+	case <-l.release:
+		return l.isAllowed, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func (l *slowLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	return l.IsAllowedContext(ctx, r)
+}
+
+func (l *slowLimiter) Commit(ctx context.Context, r *http.Request) error {
+	l.commits.Add(1)
+	return nil
+}
+
+func (l *slowLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	l.refunds.Add(1)
+	return nil
+}
+
+// Test decisions made within the deadline are returned
+func TestDeadlineLimiterWithinDeadline(t *testing.T) {
+	limiter := NewDeadlineLimiter(NewTokenBucketLimiter(1, 1), time.Second, FailOpen)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if isAllowed, err := limiter.IsAllowed(req); !isAllowed || err != nil {
+		t.Errorf("expected first request to be allowed; got %v, %v", isAllowed, err)
+	}
+	if isAllowed, err := limiter.IsAllowed(req); isAllowed || err != nil {
+		t.Errorf("expected second request to be denied; got %v, %v", isAllowed, err)
+	}
+	if data := limiter.GetRateLimitData(req); data.Limit != 1 {
+		t.Errorf("expected the rate limit data of the rate limiter; got %+v", data)
+	}
+	if key, err := KeyOf(limiter, req); key != "192.0.2.1" || err != nil {
+		t.Errorf("expected key 192.0.2.1; got %q, %v", key, err)
+	}
+}
+
+// Test late decisions are made with the policy and reconciled once the check completes
+func TestDeadlineLimiterLate(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    FailurePolicy
+		isAllowed bool
+		want      bool
+		commits   int64
+		refunds   int64
+	}{
+		{"fail open, denied late", FailOpen, false, true, 1, 0},
+		{"fail open, allowed late", FailOpen, true, true, 0, 0},
+		{"fail closed, allowed late", FailClosed, true, false, 0, 1},
+		{"fail closed, denied late", FailClosed, false, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slow := &slowLimiter{isAllowed: tt.isAllowed, release: make(chan struct{})}
+			limiter := NewDeadlineLimiter(slow, 5*time.Millisecond, tt.policy)
+
+			start := time.Now()
+			isAllowed, err := limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/", nil))
+			if isAllowed != tt.want || err != nil {
+				t.Errorf("expected %v; got %v, %v", tt.want, isAllowed, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected the decision not to wait for the rate limiter; took %v", elapsed)
+			}
+			close(slow.release)
+			if err := limiter.Close(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if commits, refunds := slow.commits.Load(), slow.refunds.Load(); commits != tt.commits || refunds != tt.refunds {
+				t.Errorf("expected %d commits and %d refunds; got %d and %d", tt.commits, tt.refunds, commits, refunds)
+			}
+		})
+	}
+}
+
+// Test the late check is not cancelled with the context of the request
+func TestDeadlineLimiterContext(t *testing.T) {
+	slow := &slowLimiter{isAllowed: true, release: make(chan struct{})}
+	limiter := NewDeadlineLimiter(slow, time.Second, FailOpen)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	isAllowed, err := limiter.IsAllowedContext(ctx, httptest.NewRequest(http.MethodGet, "/", nil))
+	if isAllowed || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v; got %v, %v", context.Canceled, isAllowed, err)
+	}
+	close(slow.release)
+	limiter.Close(context.Background())
+	if refunds := slow.refunds.Load(); refunds != 1 {
+		t.Errorf("expected the request allowed late to be refunded; got %d refunds", refunds)
+	}
+}
+
+// Test rate limit data is not waited for beyond the deadline
+func TestDeadlineLimiterRateLimitData(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	limiter := NewDeadlineLimiter(&MockAdvancedRateLimiter{
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			<-release
+			return RateLimitData{Limit: 10}
+		},
+	}, 5*time.Millisecond, FailOpen)
+
+	if data := limiter.GetRateLimitData(httptest.NewRequest(http.MethodGet, "/", nil)); data != (RateLimitData{}) {
+		t.Errorf("expected no rate limit data; got %+v", data)
+	}
+}
+
+// Test Close gives up waiting for late checks once its context is done
+func TestDeadlineLimiterCloseContext(t *testing.T) {
+	slow := &slowLimiter{release: make(chan struct{})}
+	defer close(slow.release)
+	limiter := NewDeadlineLimiter(slow, time.Millisecond, FailOpen)
+	limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/", nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.Close(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v; got %v", context.Canceled, err)
+	}
+}
+
+// Test NewDeadlineLimiter panics with invalid arguments
+func TestDeadlineLimiterPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		policy   FailurePolicy
+	}{
+		{"zero deadline", 0, FailOpen},
+		{"fail with error", time.Millisecond, FailWithError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			NewDeadlineLimiter(NewTokenBucketLimiter(1, 1), tt.deadline, tt.policy)
+		})
+	}
+}
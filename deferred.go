@@ -0,0 +1,93 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+)
+
+// DeferredRateLimiter is an extended version of the [RateLimiter] interface for rate limiters that
+// can decide whether a request is allowed and count it against the limit in two separate steps.
+// This lets the middlewares count a request only once its response is known, as configured with
+// [WithCountIf]. All built-in limiters implement it.
+type DeferredRateLimiter interface {
+	RateLimiter
+	// Peek reports whether the request would be allowed, without counting it.
+	Peek(ctx context.Context, r *http.Request) (bool, error)
+	// Commit counts the request against the limit. It must count the request even if that exceeds
+	// the limit, since concurrent requests may all have been allowed by Peek before any of them
+	// was counted.
+	Commit(ctx context.Context, r *http.Request) error
+}
+
+// WithCountIf makes the middlewares count a request against the limit only after the next handler
+// has responded, and only if predicate returns true for the status code of the response. For
+// example, counting only HTTP 401 (Unauthorized) responses to a login endpoint limits failed login
+// attempts, and thus brute-force attacks, without ever locking out users who log in successfully.
+//
+// Behavior:
+//   - A request is denied if the client has exhausted its limit, as reported by Peek.
+//   - Otherwise, the request is forwarded to the next handler, and counted with Commit if the
+//     status code of the response satisfies predicate. Errors returned by Commit cannot affect
+//     the response anymore, and are only reported to the logger set with [WithLogger].
+//
+// The rate limiter must implement [DeferredRateLimiter]; the middleware constructors panic
+// otherwise. [WithWaitMode] has no effect in this mode.
+//
+// Example usage:
+//
+//	failedLogins := NewSlidingWindowLimiter(5, 15*time.Minute)
+//	countFailures := WithCountIf(func(status int) bool { return status == http.StatusUnauthorized })
+//	http.Handle("POST /login", New(failedLogins, countFailures)(loginHandler))
+func WithCountIf(predicate func(statusCode int) bool) Option {
+	return func(o *options) {
+		o.countIf = predicate
+	}
+}
+
+// serveDeferred forwards r to next, recording the status code of the response, and commits r to
+// rateLimiter if the status code satisfies the predicate set with [WithCountIf].
+func (o *options) serveDeferred(w http.ResponseWriter, r *http.Request, next http.Handler, rateLimiter DeferredRateLimiter) {
+	recorder := &statusRecorder{ResponseWriter: w}
+	next.ServeHTTP(recorder, r)
+	if !o.countIf(recorder.status()) {
+		return
+	}
+	if err := rateLimiter.Commit(context.WithoutCancel(r.Context()), r); err != nil {
+		o.logError(r, rateLimiter, err)
+	}
+}
+
+// statusRecorder is an [http.ResponseWriter] recording the status code of the response written to
+// the [http.ResponseWriter] it wraps.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped [http.ResponseWriter], for use by [http.ResponseController].
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status returns the status code of the response, which is HTTP 200 (OK) if the handler wrote
+// nothing.
+func (w *statusRecorder) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,101 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// loginHandler responds with HTTP 401 (Unauthorized) unless the password is correct.
+var loginHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Password") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Write([]byte("welcome"))
+})
+
+// newLoginRequest returns a login request from a fixed client with the given password.
+func newLoginRequest(password string) *http.Request {
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-Password", password)
+	return req
+}
+
+// Test WithCountIf counts only responses matching the predicate
+func TestWithCountIfCountsMatchingResponses(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(2, time.Minute)
+	countFailures := WithCountIf(func(status int) bool { return status == http.StatusUnauthorized })
+	middleware := New(limiter, countFailures)(loginHandler)
+
+	for range 3 {
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, newLoginRequest("secret"))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected successful logins not to be limited; got %v", rr.Code)
+		}
+	}
+	for _, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, newLoginRequest("wrong"))
+		if rr.Code != want {
+			t.Errorf("expected status %v; got %v", want, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, newLoginRequest("secret"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected client to be locked out; got %v", rr.Code)
+	}
+}
+
+// Test Commit counts requests beyond the limit
+func TestDeferredRateLimitersCommitBeyondLimit(t *testing.T) {
+	clock := newFakeClock()
+	tokenBucket := NewTokenBucketLimiter(1, 1)
+	tokenBucket.now = clock.Now
+	slidingWindow := NewSlidingWindowLimiter(1, time.Minute)
+	slidingWindow.now = clock.Now
+	fixedWindow := NewFixedWindowLimiter(1, time.Minute)
+	fixedWindow.now = clock.Now
+	leakyBucket := NewLeakyBucketLimiter(1, 1)
+	leakyBucket.now = clock.Now
+	gcra := NewGCRALimiter(time.Second, 0)
+	gcra.now = clock.Now
+	adaptive := NewAdaptiveLimiter(1, 1, time.Minute, func() float64 { return 0 }, 1)
+	adaptive.counter.now = clock.Now
+
+	limiters := map[string]DeferredRateLimiter{
+		"token bucket":   tokenBucket,
+		"sliding window": slidingWindow,
+		"fixed window":   fixedWindow,
+		"leaky bucket":   leakyBucket,
+		"GCRA":           gcra,
+		"adaptive":       adaptive,
+	}
+	for name, limiter := range limiters {
+		req := newRequestFrom("192.0.2.1:1234")
+		if isAllowed, err := limiter.Peek(req.Context(), req); err != nil || !isAllowed {
+			t.Errorf("%s: expected fresh client to be allowed; got %v, %v", name, isAllowed, err)
+		}
+		for range 2 {
+			if err := limiter.Commit(req.Context(), req); err != nil {
+				t.Errorf("%s: expected no error; got %v", name, err)
+			}
+		}
+		if isAllowed, _ := limiter.Peek(req.Context(), req); isAllowed {
+			t.Errorf("%s: expected client to be denied after exceeding the limit", name)
+		}
+	}
+}
+
+// Test WithCountIf panics for rate limiters that cannot defer counting
+func TestWithCountIfRequiresDeferredRateLimiter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected New to panic")
+		}
+	}()
+	New(&MockRateLimiter{}, WithCountIf(func(int) bool { return true }))
+}
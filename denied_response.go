@@ -0,0 +1,195 @@
+package cerberus
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// rateLimitDataKey is the context key of the [RateLimitData] of a denied request.
+type rateLimitDataKey struct{}
+
+// RateLimitDataFromContext returns the [RateLimitData] of the denied request whose context is ctx,
+// for use by the handler set with [WithDeniedHandler], such as to describe the limit in the body of
+// the response. It returns false if there is none, which is the case of requests that were not
+// denied, or denied by a rate limiter that does not implement [AdvancedRateLimiter].
+func RateLimitDataFromContext(ctx context.Context) (RateLimitData, bool) {
+	data, ok := ctx.Value(rateLimitDataKey{}).(RateLimitData)
+	return data, ok
+}
+
+// ResponseFormat is a format of the bodies of the responses written by [DeniedResponder].
+type ResponseFormat int
+
+const (
+	// FormatProblemJSON writes a problem details object of RFC 9457 (formerly RFC 7807), with the
+	// media type application/problem+json, as in:
+	//
+	//	{"type":"about:blank","title":"Too Many Requests","status":429,
+	//	 "detail":"Rate limit exceeded, retry in 30 seconds.","limit":100,"remaining":0,"retry_after":30}
+	FormatProblemJSON ResponseFormat = iota + 1
+
+	// FormatJSON writes a plain JSON object, with the media type application/json, as in:
+	//
+	//	{"error":"rate limit exceeded","limit":100,"remaining":0,"retry_after":30}
+	FormatJSON
+
+	// FormatXML writes a problem details document of RFC 9457 in XML, with the media type
+	// application/problem+xml, as in:
+	//
+	//	<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Too Many Requests</title>
+	//	<status>429</status><detail>...</detail><limit>100</limit><remaining>0</remaining>
+	//	<retry_after>30</retry_after></problem>
+	FormatXML
+)
+
+// problem is the problem details object written by [DeniedResponder].
+type problem struct {
+	XMLName    xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type       string   `json:"type" xml:"type"`
+	Title      string   `json:"title" xml:"title"`
+	Status     int      `json:"status" xml:"status"`
+	Detail     string   `json:"detail" xml:"detail"`
+	Limit      *int     `json:"limit,omitempty" xml:"limit,omitempty"`
+	Remaining  *int     `json:"remaining,omitempty" xml:"remaining,omitempty"`
+	RetryAfter *int64   `json:"retry_after,omitempty" xml:"retry_after,omitempty"`
+}
+
+// plainError is the plain JSON object written by [DeniedResponder].
+type plainError struct {
+	Error      string `json:"error"`
+	Limit      *int   `json:"limit,omitempty"`
+	Remaining  *int   `json:"remaining,omitempty"`
+	RetryAfter *int64 `json:"retry_after,omitempty"`
+}
+
+// mediaTypes returns the media types of the responses in format, the first of which is written in
+// the Content-Type header, followed by the other media types accepted by clients that understand
+// them.
+func (f ResponseFormat) mediaTypes() []string {
+	switch f {
+	case FormatProblemJSON:
+		return []string{"application/problem+json", "application/json"}
+	case FormatXML:
+		return []string{"application/problem+xml", "application/xml", "text/xml"}
+	default:
+		return []string{"application/json"}
+	}
+}
+
+// DeniedResponder returns a handler, to be set with [WithDeniedHandler], that responds to denied
+// requests with an HTTP 429 (Too Many Requests) whose body describes the limit and when to retry,
+// from the [RateLimitData] of the request, instead of the empty body of the default handler.
+//
+// Behavior:
+//   - The body is written in the first of formats that the client accepts, as negotiated with the
+//     Accept header of the request and the quality values it lists, or in the first of formats if
+//     the client accepts none of them. The default format is [FormatProblemJSON].
+//   - The limit, the remaining budget, and the time to wait before retrying, in seconds, are
+//     included if the rate limiter implements [AdvancedRateLimiter], and omitted otherwise.
+//
+// It panics if a format is unknown.
+//
+// Example usage: http.Handle("/", New(myAdvancedRateLimiter, WithDeniedHandler(DeniedResponder(FormatProblemJSON, FormatXML)))(myHandler))
+func DeniedResponder(formats ...ResponseFormat) http.Handler {
+	if len(formats) == 0 {
+		formats = []ResponseFormat{FormatProblemJSON}
+	}
+	for _, format := range formats {
+		if format < FormatProblemJSON || format > FormatXML {
+			panic("cerberus: unknown response format")
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := negotiateFormat(r.Header.Values("Accept"), formats)
+		data, ok := RateLimitDataFromContext(r.Context())
+		var limit, remaining *int
+		var retryAfter *int64
+		detail := "Rate limit exceeded."
+		if ok && data.Limit > 0 {
+			limit, remaining = &data.Limit, &data.Remaining
+		}
+		if ok && data.RetryAfter > 0 {
+			seconds := ceilSeconds(data.RetryAfter)
+			retryAfter = &seconds
+			detail = "Rate limit exceeded, retry in " + strconv.FormatInt(seconds, 10) + " seconds."
+		}
+		var body []byte
+		switch format {
+		case FormatJSON:
+			body, _ = json.Marshal(plainError{Error: "rate limit exceeded", Limit: limit, Remaining: remaining, RetryAfter: retryAfter})
+		default:
+			p := problem{
+				Type:       "about:blank",
+				Title:      http.StatusText(http.StatusTooManyRequests),
+				Status:     http.StatusTooManyRequests,
+				Detail:     detail,
+				Limit:      limit,
+				Remaining:  remaining,
+				RetryAfter: retryAfter,
+			}
+			if format == FormatXML {
+				body, _ = xml.Marshal(p)
+				body = append([]byte(xml.Header), body...)
+			} else {
+				body, _ = json.Marshal(p)
+			}
+		}
+		w.Header().Set("Content-Type", format.mediaTypes()[0])
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(body)
+	})
+}
+
+// negotiateFormat returns the format of formats the client accepts best according to the Accept
+// header values accept, or the first of formats if it accepts none of them or sent no Accept
+// header.
+func negotiateFormat(accept []string, formats []ResponseFormat) ResponseFormat {
+	best, bestQuality := formats[0], 0.0
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaRange, params, _ := strings.Cut(mediaRange, ";")
+			mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+			quality := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "q") {
+					if q, err := strconv.ParseFloat(value, 64); err == nil {
+						quality = q
+					}
+				}
+			}
+			if quality <= bestQuality {
+				continue
+			}
+			for _, format := range formats {
+				if acceptsFormat(mediaRange, format) {
+					best, bestQuality = format, quality
+					break
+				}
+			}
+		}
+	}
+	return best
+}
+
+// acceptsFormat reports whether mediaRange, such as "application/json" or "application/*",
+// includes a media type of format.
+func acceptsFormat(mediaRange string, format ResponseFormat) bool {
+	if mediaRange == "*/*" {
+		return true
+	}
+	for _, mediaType := range format.mediaTypes() {
+		if mediaRange == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package cerberus
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newDeniedMiddleware returns a middleware denying every request with data, responding with a
+// DeniedResponder of formats.
+func newDeniedMiddleware(data RateLimitData, formats ...ResponseFormat) http.Handler {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc:        func(r *http.Request) (bool, error) { return false, nil },
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData { return data },
+	}
+	return New(mockLimiter, WithHeaders(false), WithDeniedHandler(DeniedResponder(formats...)))(noContent)
+}
+
+// Test the default format is problem+json, describing the limit
+func TestDeniedResponderProblemJSON(t *testing.T) {
+	middleware := newDeniedMiddleware(RateLimitData{Limit: 100, RetryAfter: 29500 * time.Millisecond})
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a problem+json 429; got %v %v", rr.Code, rr.Header())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != 429.0 || body["limit"] != 100.0 || body["remaining"] != 0.0 || body["retry_after"] != 30.0 {
+		t.Errorf("expected status 429, limit 100, remaining 0, and retry_after 30; got %v", body)
+	}
+}
+
+// Test the format is negotiated with the Accept header
+func TestDeniedResponderNegotiation(t *testing.T) {
+	middleware := newDeniedMiddleware(RateLimitData{Limit: 10}, FormatJSON, FormatXML)
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"text/html", "application/json"},
+		{"application/xml", "application/problem+xml"},
+		{"application/json;q=0.5, text/xml", "application/problem+xml"},
+		{"application/*;q=0.9, application/xml;q=0.1", "application/json"},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r := newRequestFrom("192.0.2.1:1234")
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		middleware.ServeHTTP(rr, r)
+		if contentType := rr.Header().Get("Content-Type"); contentType != test.want {
+			t.Errorf("%q: expected %s; got %s", test.accept, test.want, contentType)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r := newRequestFrom("192.0.2.1:1234")
+	r.Header.Set("Accept", "application/xml")
+	middleware.ServeHTTP(rr, r)
+	var body problem
+	if err := xml.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Status != 429 || body.Limit == nil || *body.Limit != 10 {
+		t.Errorf("expected an XML problem with limit 10; got %+v, %v", body, err)
+	}
+}
+
+// Test details are omitted without rate limit data
+func TestDeniedResponderWithoutData(t *testing.T) {
+	mockLimiter := &MockRateLimiter{IsAllowedFunc: func(r *http.Request) (bool, error) { return false, nil }}
+	rr := httptest.NewRecorder()
+	New(mockLimiter, WithDeniedHandler(DeniedResponder(FormatJSON)))(noContent).ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if body := rr.Body.String(); body != `{"error":"rate limit exceeded"}` {
+		t.Errorf("expected a bare error; got %s", body)
+	}
+}
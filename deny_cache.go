@@ -0,0 +1,162 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DenyCacheLimiter is a [RateLimiter] and [AdvancedRateLimiter] that remembers, for a short time,
+// the clients a rate limiter denied, and denies their next requests without consulting it. A
+// flood of requests from a single abusive client then costs the remote [Store] of the rate
+// limiter one round trip per period rather than one per request, although all of them would be
+// denied anyway.
+//
+// Behavior:
+//   - Requests from a client that was denied less than ttl ago are denied from the cache, along
+//     with the [RateLimitData] of the denial, with RetryAfter reduced by the time elapsed since.
+//   - Denials are remembered for ttl, or until the client may retry if that is sooner, so that
+//     clients are never denied for longer than the rate limiter asks them to wait, if it reports
+//     when.
+//   - Requests are passed through to the rate limiter if they are allowed, if it does not report
+//     the keys of clients, or if their key cannot be derived.
+//
+// Denials are remembered per key, whatever the cost of requests: with a [CostFunc], cheap requests
+// of a client denied for an expensive one are denied as well until the cache expires. The cache
+// is local to the instance, and holds the clients denied within the last ttl.
+//
+// A DenyCacheLimiter is safe for concurrent use by multiple goroutines if its rate limiter is.
+//
+// Example usage: limiter := NewDenyCacheLimiter(NewTokenBucketLimiter(100, 10, WithStore(myRedisStore)), 100*time.Millisecond)
+type DenyCacheLimiter struct {
+	rateLimiter RateLimiter
+	ttl         time.Duration
+
+	mu      sync.Mutex
+	denied  map[string]cachedDenial
+	sweptAt time.Time
+
+	now func() time.Time
+}
+
+// cachedDenial is a denial of a client remembered by a [DenyCacheLimiter] until expiresAt.
+type cachedDenial struct {
+	data      RateLimitData
+	deniedAt  time.Time
+	expiresAt time.Time
+}
+
+// NewDenyCacheLimiter creates a new [DenyCacheLimiter] that remembers the denials of rateLimiter
+// for up to ttl.
+//
+// It panics if ttl is not positive.
+func NewDenyCacheLimiter(rateLimiter RateLimiter, ttl time.Duration) *DenyCacheLimiter {
+	if ttl <= 0 {
+		panic("cerberus: deny cache TTL must be positive")
+	}
+	return &DenyCacheLimiter{
+		rateLimiter: rateLimiter,
+		ttl:         ttl,
+		denied:      make(map[string]cachedDenial),
+		now:         time.Now,
+	}
+}
+
+// IsAllowed denies the request if its client was denied recently, and checks it against the rate
+// limiter otherwise.
+func (l *DenyCacheLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiter.
+func (l *DenyCacheLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.key(r)
+	if err != nil {
+		return isAllowed(ctx, l.rateLimiter, r)
+	}
+	if _, ok := l.cached(key); ok {
+		return false, nil
+	}
+	allowed, err := isAllowed(ctx, l.rateLimiter, r)
+	if err != nil || allowed {
+		return allowed, err
+	}
+	var data RateLimitData
+	if advanced, ok := l.rateLimiter.(AdvancedRateLimiter); ok {
+		data = advanced.GetRateLimitData(r)
+	}
+	now := l.now()
+	expiresAt := now.Add(l.ttl)
+	if data.RetryAfter > 0 && data.RetryAfter < l.ttl {
+		expiresAt = now.Add(data.RetryAfter)
+	}
+	l.mu.Lock()
+	l.sweep(now)
+	l.denied[key] = cachedDenial{data: data, deniedAt: now, expiresAt: expiresAt}
+	l.mu.Unlock()
+	return false, nil
+}
+
+// GetRateLimitData returns the rate limit data of the denial of the client, if it was denied
+// recently, and the data reported by the rate limiter otherwise. The zero RateLimitData is returned
+// if the rate limiter does not implement [AdvancedRateLimiter].
+func (l *DenyCacheLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	if key, err := l.key(r); err == nil {
+		if data, ok := l.cached(key); ok {
+			return data
+		}
+	}
+	if advanced, ok := l.rateLimiter.(AdvancedRateLimiter); ok {
+		return advanced.GetRateLimitData(r)
+	}
+	return RateLimitData{}
+}
+
+// RefundRequest gives back the budget consumed by the request, if the rate limiter implements
+// [RefundableRateLimiter]. It implements RefundableRateLimiter.
+func (l *DenyCacheLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return refund(ctx, []RateLimiter{l.rateLimiter}, r)
+}
+
+// Close closes the rate limiter, if it implements [Closer]. It implements Closer.
+func (l *DenyCacheLimiter) Close(ctx context.Context) error {
+	return closeAll(ctx, l.rateLimiter)
+}
+
+// key returns the key identifying the client making the request, as reported by the rate limiter.
+func (l *DenyCacheLimiter) key(r *http.Request) (string, error) {
+	if k, ok := l.rateLimiter.(keyer); ok {
+		return k.key(r)
+	}
+	return "", ErrNoKey
+}
+
+// cached returns the rate limit data of the denial of the client identified by key, and whether
+// it was denied less than ttl ago.
+func (l *DenyCacheLimiter) cached(key string) (RateLimitData, bool) {
+	now := l.now()
+	l.mu.Lock()
+	denial, ok := l.denied[key]
+	l.mu.Unlock()
+	if !ok || !now.Before(denial.expiresAt) {
+		return RateLimitData{}, false
+	}
+	data := denial.data
+	data.RetryAfter = max(data.RetryAfter-now.Sub(denial.deniedAt), 0)
+	return data, true
+}
+
+// sweep removes the expired denials, if the TTL has elapsed since the last sweep, so that the
+// denials of clients that went away do not accumulate. l.mu must be held.
+func (l *DenyCacheLimiter) sweep(now time.Time) {
+	if now.Sub(l.sweptAt) < l.ttl {
+		return
+	}
+	l.sweptAt = now
+	for key, denial := range l.denied {
+		if !now.Before(denial.expiresAt) {
+			delete(l.denied, key)
+		}
+	}
+}
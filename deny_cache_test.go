@@ -0,0 +1,95 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// countingLimiter is a token bucket limiter counting the checks it receives.
+type countingLimiter struct {
+	*TokenBucketLimiter
+	checks int
+}
+
+func (l *countingLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	l.checks++
+	return l.TokenBucketLimiter.IsAllowedContext(ctx, r)
+}
+
+// Test a denied client is denied from the cache without consulting the rate limiter
+func TestDenyCacheLimiterCachesDenials(t *testing.T) {
+	clock := newFakeClock()
+	inner := &countingLimiter{TokenBucketLimiter: NewTokenBucketLimiter(2, 1)}
+	inner.now = clock.Now
+	limiter := NewDenyCacheLimiter(inner, 100*time.Millisecond)
+	limiter.now = clock.Now
+	abusive := newRequestFrom("192.0.2.1:1234")
+
+	for range 2 {
+		limiter.IsAllowed(abusive)
+	}
+	for range 10 {
+		if isAllowed, _ := limiter.IsAllowed(abusive); isAllowed {
+			t.Fatalf("expected the abusive client to be denied")
+		}
+	}
+	if inner.checks != 3 {
+		t.Errorf("expected 3 checks to reach the rate limiter; got %d", inner.checks)
+	}
+	if isAllowed, _ := limiter.IsAllowed(newRequestFrom("192.0.2.2:1234")); !isAllowed {
+		t.Errorf("expected other clients to be allowed")
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	limiter.IsAllowed(abusive)
+	if inner.checks != 5 {
+		t.Errorf("expected the rate limiter to be consulted once the cache expired; got %d checks", inner.checks)
+	}
+}
+
+// Test cached denials report the data of the denial and expire when the client may retry
+func TestDenyCacheLimiterRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	inner := &countingLimiter{TokenBucketLimiter: NewTokenBucketLimiter(1, 20)}
+	inner.now = clock.Now
+	limiter := NewDenyCacheLimiter(inner, time.Second)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Fatalf("expected the second request to be denied")
+	}
+	clock.Advance(20 * time.Millisecond)
+	data := limiter.GetRateLimitData(req)
+	if data.Limit != 1 || data.RetryAfter != 30*time.Millisecond {
+		t.Errorf("expected the cached data with 30ms to wait; got %+v", data)
+	}
+
+	clock.Advance(30 * time.Millisecond)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected the client to be allowed once it may retry")
+	}
+}
+
+// Test requests without a key are passed through to the rate limiter
+func TestDenyCacheLimiterWithoutKey(t *testing.T) {
+	keyErr := errors.New("no key")
+	inner := NewTokenBucketLimiter(1, 1, WithKeyFunc(func(*http.Request) (string, error) { return "", keyErr }))
+	limiter := NewDenyCacheLimiter(inner, time.Second)
+
+	if _, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234")); !errors.Is(err, keyErr) {
+		t.Errorf("expected the error of the key function; got %v", err)
+	}
+	mock := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	if isAllowed, err := NewDenyCacheLimiter(mock, time.Second).IsAllowed(newRequestFrom("192.0.2.1:1234")); isAllowed || err != nil {
+		t.Errorf("expected the decision of a rate limiter without keys; got %v, %v", isAllowed, err)
+	}
+}
@@ -0,0 +1,415 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidConfig is returned when a [Config] does not describe a valid limit.
+var ErrInvalidConfig = errors.New("cerberus: invalid config")
+
+// Algorithm names a built-in rate limiting algorithm in a [Config].
+type Algorithm string
+
+const (
+	// AlgorithmTokenBucket selects a [TokenBucketLimiter].
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmLeakyBucket selects a [LeakyBucketLimiter].
+	AlgorithmLeakyBucket Algorithm = "leaky_bucket"
+	// AlgorithmFixedWindow selects a [FixedWindowLimiter].
+	AlgorithmFixedWindow Algorithm = "fixed_window"
+	// AlgorithmSlidingWindow selects a [SlidingWindowLimiter].
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmSlidingWindowCounter selects a [SlidingWindowCounterLimiter].
+	AlgorithmSlidingWindowCounter Algorithm = "sliding_window_counter"
+	// AlgorithmGCRA selects a [GCRALimiter].
+	AlgorithmGCRA Algorithm = "gcra"
+)
+
+// Config describes a limit of Limit requests per Window, enforced with a built-in algorithm. It
+// is the configuration of a [DynamicLimiter], and can be decoded from JSON or YAML, with the
+// window written as a duration string such as "1m" or "1h30m":
+//
+//	{"algorithm": "token_bucket", "limit": 100, "window": "1m"}
+//
+// The algorithms interpret the limit as follows:
+//   - [AlgorithmTokenBucket]: buckets of Limit tokens, refilled at Limit tokens per Window.
+//   - [AlgorithmLeakyBucket]: buckets of capacity Limit, leaking at Limit requests per Window.
+//   - [AlgorithmFixedWindow], [AlgorithmSlidingWindow], and [AlgorithmSlidingWindowCounter]: Limit
+//     requests per Window.
+//   - [AlgorithmGCRA]: one request per Window/Limit on average, with bursts of up to Limit
+//     requests.
+type Config struct {
+	Algorithm Algorithm     `json:"algorithm" yaml:"algorithm"`
+	Limit     int           `json:"limit" yaml:"limit"`
+	Window    time.Duration `json:"window" yaml:"window"`
+}
+
+// Validate returns an error wrapping [ErrInvalidConfig] if the algorithm is unknown, or if the
+// limit or the window is not positive.
+func (c Config) Validate() error {
+	switch c.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmLeakyBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow,
+		AlgorithmSlidingWindowCounter, AlgorithmGCRA:
+	default:
+		return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidConfig, c.Algorithm)
+	}
+	if c.Limit <= 0 {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalidConfig)
+	}
+	if c.Window <= 0 {
+		return fmt.Errorf("%w: window must be positive", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the config with its window as a duration string.
+func (c Config) MarshalJSON() ([]byte, error) {
+	type config Config
+	return json.Marshal(struct {
+		config
+		Window string `json:"window"`
+	}{config(c), c.Window.String()})
+}
+
+// UnmarshalJSON decodes the config, parsing its window as a duration string. Unknown fields are
+// rejected, so that misspelled settings do not go unnoticed.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+	var raw struct {
+		config
+		Window string `json:"window"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	*c = Config(raw.config)
+	if raw.Window == "" {
+		c.Window = 0
+		return nil
+	}
+	window, err := time.ParseDuration(raw.Window)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	c.Window = window
+	return nil
+}
+
+// DynamicLimiter is a [RateLimiter] and [AdvancedRateLimiter] whose limit can be changed at
+// runtime, for example to tighten limits during an incident without restarting the service. It
+// enforces the limit described by its current [Config] with the corresponding built-in limiter,
+// and replaces that limiter atomically when [DynamicLimiter.UpdateConfig] is called.
+// [WatchConfigFile] keeps the config in sync with a file.
+//
+// All configs share the limiter's [Store], so the usage of each client carries over when the limit
+// changes but the algorithm does not. State kept with different algorithms is stored under
+// different keys, so switching algorithms starts every client afresh.
+//
+// The limits of specific clients, such as customers with their own contracts, can be looked up at
+// request time with [WithLimitResolver].
+//
+// A DynamicLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter, err := NewDynamicLimiter(Config{Algorithm: AlgorithmTokenBucket, Limit: 100, Window: time.Minute})
+//	...
+//	limiter.UpdateConfig(Config{Algorithm: AlgorithmTokenBucket, Limit: 10, Window: time.Minute})
+type DynamicLimiter struct {
+	opts      []LimiterOption
+	store     Store
+	topDenied *TopKeys
+	limits    *limitCache
+	state     atomic.Pointer[dynamicState]
+}
+
+// dynamicState is the current config of a [DynamicLimiter] and the limiter enforcing it, along
+// with the limiters enforcing the limits resolved for specific clients with [WithLimitResolver],
+// by config.
+type dynamicState struct {
+	config      Config
+	rateLimiter builtinLimiter
+	opts        []LimiterOption
+	limiters    sync.Map
+}
+
+// builtinLimiter is the set of interfaces implemented by all built-in limiters.
+type builtinLimiter interface {
+	AdvancedRateLimiter
+	RateLimiterContext
+	DeferredRateLimiter
+	RefundableRateLimiter
+	InspectableLimiter
+	ManagedLimiter
+	TopDeniedLimiter
+	keyer
+}
+
+// NewDynamicLimiter creates a new [DynamicLimiter] enforcing config. The options are applied to
+// the built-in limiter of every config. An error wrapping [ErrInvalidConfig] is returned if config
+// is invalid.
+func NewDynamicLimiter(config Config, opts ...LimiterOption) (*DynamicLimiter, error) {
+	options := newLimiterOptions(opts)
+	l := &DynamicLimiter{
+		opts:      opts,
+		store:     options.store,
+		topDenied: options.topDenied,
+		limits:    newLimitCache(options),
+	}
+	if err := l.UpdateConfig(config); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// UpdateConfig replaces the config of the limiter. Requests checked afterwards are checked
+// against the new config. An error wrapping [ErrInvalidConfig] is returned if config is invalid,
+// in which case the current config is kept.
+func (l *DynamicLimiter) UpdateConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	opts := append(append([]LimiterOption(nil), l.opts...),
+		WithStore(prefixedStore{Store: l.store, prefix: string(config.Algorithm) + ":"}),
+		withTopKeys(l.topDenied))
+	rateLimiter := newBuiltinLimiter(config, opts...)
+	l.state.Store(&dynamicState{config: config, rateLimiter: rateLimiter, opts: opts})
+	return nil
+}
+
+// newBuiltinLimiter creates the built-in limiter enforcing config, which must be valid.
+func newBuiltinLimiter(config Config, opts ...LimiterOption) builtinLimiter {
+	switch config.Algorithm {
+	case AlgorithmTokenBucket:
+		return NewTokenBucketLimiter(config.Limit, float64(config.Limit)/config.Window.Seconds(), opts...)
+	case AlgorithmLeakyBucket:
+		return NewLeakyBucketLimiter(config.Limit, float64(config.Limit)/config.Window.Seconds(), opts...)
+	case AlgorithmFixedWindow:
+		return NewFixedWindowLimiter(config.Limit, config.Window, opts...)
+	case AlgorithmSlidingWindow:
+		return NewSlidingWindowLimiter(config.Limit, config.Window, opts...)
+	case AlgorithmSlidingWindowCounter:
+		return NewSlidingWindowCounterLimiter(config.Limit, config.Window, opts...)
+	default:
+		emissionInterval := max(config.Window/time.Duration(config.Limit), 1)
+		return NewGCRALimiter(emissionInterval, emissionInterval*time.Duration(config.Limit-1), opts...)
+	}
+}
+
+// Config returns the current config of the limiter.
+func (l *DynamicLimiter) Config() Config {
+	return l.state.Load().config
+}
+
+// IsAllowed checks the request against the current config.
+func (l *DynamicLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *DynamicLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter, err := l.limiterOf(r)
+	if err != nil {
+		return false, err
+	}
+	return rateLimiter.IsAllowedContext(ctx, r)
+}
+
+// Peek reports whether the request would be allowed under the current config, without counting
+// it.
+func (l *DynamicLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter, err := l.limiterOf(r)
+	if err != nil {
+		return false, err
+	}
+	return rateLimiter.Peek(ctx, r)
+}
+
+// Commit counts the request under the current config, even if that exceeds the limit.
+func (l *DynamicLimiter) Commit(ctx context.Context, r *http.Request) error {
+	rateLimiter, err := l.limiterOf(r)
+	if err != nil {
+		return err
+	}
+	return rateLimiter.Commit(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed under the current
+// config.
+func (l *DynamicLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	rateLimiter, err := l.limiterOf(r)
+	if err != nil {
+		return err
+	}
+	return rateLimiter.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the current state of the limit of the client making the request under
+// the current config. The zero RateLimitData is returned if the limit of the client cannot be
+// resolved.
+func (l *DynamicLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	rateLimiter, err := l.limiterOf(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	return rateLimiter.GetRateLimitData(r)
+}
+
+// Keys returns the keys of the clients tracked under the current config.
+func (l *DynamicLimiter) Keys(ctx context.Context) ([]string, error) {
+	return l.state.Load().rateLimiter.Keys(ctx)
+}
+
+// Usage returns the rate limit data of the client identified by key under the current config.
+func (l *DynamicLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	state := l.state.Load()
+	rateLimiter, err := l.limiterFor(state, key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	return rateLimiter.Usage(ctx, key)
+}
+
+// Reset restores the full budget of the client identified by key under the current config.
+func (l *DynamicLimiter) Reset(ctx context.Context, key string) error {
+	return l.state.Load().rateLimiter.Reset(ctx, key)
+}
+
+// Block denies all requests of the client identified by key for d under the current config. The
+// block is lifted if the algorithm is changed.
+func (l *DynamicLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	return l.state.Load().rateLimiter.Block(ctx, key, d)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+// Denials are tracked across configs.
+func (l *DynamicLimiter) TopDenied() ([]KeyCount, error) {
+	return l.state.Load().rateLimiter.TopDenied()
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *DynamicLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// key returns the key identifying the client making the request.
+func (l *DynamicLimiter) key(r *http.Request) (string, error) {
+	return l.state.Load().rateLimiter.key(r)
+}
+
+// limiterOf returns the built-in limiter enforcing the limit of the client making r under the
+// current config. Requests without a key get the limiter of the config, which reports the error
+// of the key function.
+func (l *DynamicLimiter) limiterOf(r *http.Request) (builtinLimiter, error) {
+	state := l.state.Load()
+	if l.limits == nil {
+		return state.rateLimiter, nil
+	}
+	key, err := state.rateLimiter.key(r)
+	if err != nil {
+		return state.rateLimiter, nil
+	}
+	return l.limiterFor(state, key)
+}
+
+// limiterFor returns the built-in limiter enforcing the limit of the client identified by key in
+// state: the limiter of the limit resolved for the client with [WithLimitResolver], if any, and
+// the limiter of the config otherwise.
+func (l *DynamicLimiter) limiterFor(state *dynamicState, key string) (builtinLimiter, error) {
+	if l.limits == nil {
+		return state.rateLimiter, nil
+	}
+	limit, err := l.limits.get(key)
+	if err != nil {
+		return nil, err
+	}
+	config := Config{Algorithm: state.config.Algorithm, Limit: limit.Limit, Window: limit.Window}
+	if limit == (Limit{}) || config == state.config {
+		return state.rateLimiter, nil
+	}
+	if rateLimiter, ok := state.limiters.Load(config); ok {
+		return rateLimiter.(builtinLimiter), nil
+	}
+	rateLimiter, _ := state.limiters.LoadOrStore(config, newBuiltinLimiter(config, state.opts...))
+	return rateLimiter.(builtinLimiter), nil
+}
+
+// prefixedStore is a [Store] that prefixes every key before passing it to the underlying store, so
+// that several users of the same store do not clash.
+type prefixedStore struct {
+	Store
+	prefix string
+}
+
+func (s prefixedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.Store.Get(ctx, s.prefix+key)
+}
+
+func (s prefixedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.Store.Set(ctx, s.prefix+key, value, ttl)
+}
+
+func (s prefixedStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.Store.Increment(ctx, s.prefix+key, delta, ttl)
+}
+
+func (s prefixedStore) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return s.Store.CompareAndSwap(ctx, s.prefix+key, old, new, ttl)
+}
+
+func (s prefixedStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return s.Store.TTL(ctx, s.prefix+key)
+}
+
+func (s prefixedStore) Delete(ctx context.Context, key string) error {
+	return s.Store.Delete(ctx, s.prefix+key)
+}
+
+func (s prefixedStore) getByteKey(ctx context.Context, key []byte) ([]byte, error) {
+	buf := s.prefixed(key)
+	defer keyBufferPool.Put(buf)
+	return getByteKey(ctx, s.Store, *buf)
+}
+
+func (s prefixedStore) incrementByteKey(ctx context.Context, key []byte, delta int64, ttl time.Duration) (int64, error) {
+	buf := s.prefixed(key)
+	defer keyBufferPool.Put(buf)
+	return incrementByteKey(ctx, s.Store, *buf, delta, ttl)
+}
+
+func (s prefixedStore) ttlByteKey(ctx context.Context, key []byte) (time.Duration, error) {
+	buf := s.prefixed(key)
+	defer keyBufferPool.Put(buf)
+	return ttlByteKey(ctx, s.Store, *buf)
+}
+
+// prefixed returns a buffer from keyBufferPool holding key with the prefix of the store. The caller
+// must put the buffer back into the pool.
+func (s prefixedStore) prefixed(key []byte) *[]byte {
+	buf := keyBufferPool.Get().(*[]byte)
+	*buf = append(append((*buf)[:0], s.prefix...), key...)
+	return buf
+}
+
+// ScanKeys returns the keys starting with prefix, without the prefix of the store. An error
+// wrapping [errors.ErrUnsupported] is returned if the underlying store does not implement
+// [KeyScanner].
+func (s prefixedStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := scanKeys(ctx, s.Store, s.prefix+prefix)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.prefix)
+	}
+	return keys, err
+}
@@ -0,0 +1,92 @@
+package cerberus
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test updating the config changes the limit and keeps the usage of clients
+func TestDynamicLimiterUpdateConfig(t *testing.T) {
+	limiter, err := NewDynamicLimiter(Config{Algorithm: AlgorithmFixedWindow, Limit: 5, Window: time.Hour})
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	req := newRequestFrom("192.0.2.1:1234")
+	for range 3 {
+		limiter.IsAllowed(req)
+	}
+
+	if err := limiter.UpdateConfig(Config{Algorithm: AlgorithmFixedWindow, Limit: 3, Window: time.Hour}); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request to be denied under the new limit")
+	}
+	if data := limiter.GetRateLimitData(req); data.Limit != 3 {
+		t.Errorf("expected limit to be 3; got %v", data.Limit)
+	}
+}
+
+// Test switching algorithms starts clients afresh instead of failing on the old state
+func TestDynamicLimiterSwitchAlgorithm(t *testing.T) {
+	store := NewMemoryStore()
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Hour}, WithStore(store))
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+
+	for _, algorithm := range []Algorithm{AlgorithmLeakyBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter, AlgorithmGCRA} {
+		limiter.UpdateConfig(Config{Algorithm: algorithm, Limit: 1, Window: time.Hour})
+
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Errorf("%s: expected first request to be allowed; got %v, %v", algorithm, isAllowed, err)
+		}
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || isAllowed {
+			t.Errorf("%s: expected second request to be denied; got %v, %v", algorithm, isAllowed, err)
+		}
+	}
+}
+
+// Test invalid configs are rejected and the current config is kept
+func TestDynamicLimiterInvalidConfig(t *testing.T) {
+	config := Config{Algorithm: AlgorithmGCRA, Limit: 10, Window: time.Second}
+	limiter, _ := NewDynamicLimiter(config)
+
+	for _, invalid := range []Config{
+		{Algorithm: "unknown", Limit: 10, Window: time.Second},
+		{Algorithm: AlgorithmGCRA, Limit: 0, Window: time.Second},
+		{Algorithm: AlgorithmGCRA, Limit: 10},
+	} {
+		if err := limiter.UpdateConfig(invalid); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %+v; got %v", invalid, err)
+		}
+	}
+	if limiter.Config() != config {
+		t.Errorf("expected config to be kept; got %+v", limiter.Config())
+	}
+	if _, err := NewDynamicLimiter(Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig; got %v", err)
+	}
+}
+
+// Test configs round-trip through JSON with duration strings
+func TestConfigJSON(t *testing.T) {
+	config := Config{Algorithm: AlgorithmSlidingWindow, Limit: 100, Window: 90 * time.Second}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if string(data) != `{"algorithm":"sliding_window","limit":100,"window":"1m30s"}` {
+		t.Errorf("expected window as a duration string; got %s", data)
+	}
+	var decoded Config
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != config {
+		t.Errorf("expected %+v; got %+v, %v", config, decoded, err)
+	}
+	if err := json.Unmarshal([]byte(`{"algorithm":"gcra","limt":1,"window":"1s"}`), &decoded); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
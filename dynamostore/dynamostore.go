@@ -0,0 +1,340 @@
+// Package dynamostore provides a [cerberus.Store] backed by Amazon DynamoDB, so that serverless
+// deployments, such as Lambda functions behind API Gateway, enforce common limits without running
+// Redis.
+//
+// Every key of the store is an item of a DynamoDB table, and every operation is a single request
+// with a condition expression, so that concurrent updates never overwrite each other. Integers,
+// such as counters, are stored as numbers and incremented in place with update expressions. The
+// table must have a partition key named "pk" of type string, and should have DynamoDB's Time to
+// Live enabled on the "ttl" attribute, so that expired items are eventually removed:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	store := dynamostore.New(dynamodb.NewFromConfig(cfg), "rate-limits")
+//	limiter := cerberus.NewFixedWindowLimiter(100, time.Minute, cerberus.WithStore(store))
+//
+// DynamoDB removes expired items up to a few days late, so the store keeps the precise expiration
+// time of items in the "expires_at" attribute, and ignores expired items it reads. Reads are
+// strongly consistent.
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Attribute names of the items of the table.
+const (
+	attrKey       = "pk"
+	attrValue     = "value"
+	attrCount     = "count"
+	attrExpiresAt = "expires_at"
+	attrTTL       = "ttl"
+)
+
+// Condition and update expressions of the operations of the store. Expressions refer to the
+// attributes through the placeholders of attributeNames, since some names are reserved words.
+const (
+	condLive         = "attribute_not_exists(#e) OR #e > :now"
+	condMissing      = "attribute_not_exists(#k) OR #e <= :now"
+	condCounterLive  = "attribute_exists(#c) AND (" + condLive + ")"
+	condValueLive    = "#v = :old AND (" + condLive + ")"
+	condCountLive    = "#c = :old AND (" + condLive + ")"
+	updateAdd        = "ADD #c :delta"
+	updateReset      = "SET #c = :delta, #e = :exp, #t = :ttl REMOVE #v"
+	updateResetNoTTL = "SET #c = :delta REMOVE #v, #e, #t"
+	filterScan       = "begins_with(#k, :prefix) AND (" + condLive + ")"
+)
+
+// attributeNames maps the placeholders of the expressions to attribute names. Requests must only
+// carry the placeholders their expressions use, as selected with [names].
+var attributeNames = map[string]string{
+	"#k": attrKey,
+	"#v": attrValue,
+	"#c": attrCount,
+	"#e": attrExpiresAt,
+	"#t": attrTTL,
+}
+
+// API is the subset of the DynamoDB API used by a [Store]. It is implemented by *dynamodb.Client.
+type API interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Store is a [cerberus.Store] and [cerberus.KeyScanner] keeping its entries in a DynamoDB table.
+// Scanning reads the whole table, and is only meant for operational tooling.
+//
+// A Store is safe for concurrent use by multiple goroutines.
+type Store struct {
+	client API
+	table  string
+	now    func() time.Time
+}
+
+// New creates a new [Store] keeping its entries in the named table, using client.
+func New(client API, table string) *Store {
+	return &Store{
+		client: client,
+		table:  table,
+		now:    time.Now,
+	}
+}
+
+// Get returns the value stored under key, or nil if the key does not exist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	item, err := s.item(ctx, key)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if count, ok := item[attrCount].(*types.AttributeValueMemberN); ok {
+		return []byte(count.Value), nil
+	}
+	if value, ok := item[attrValue].(*types.AttributeValueMemberB); ok && value.Value != nil {
+		return value.Value, nil
+	}
+	return []byte{}, nil
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      s.newItem(key, value, ttl),
+	})
+	return err
+}
+
+// Increment adds delta to the integer stored under key and returns the result. A missing key is
+// created with the given TTL, and the TTL of an existing key is left unchanged.
+func (s *Store) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	for {
+		// Counters are incremented in place, as long as they have not expired.
+		out, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.table),
+			Key:                       itemKey(key),
+			UpdateExpression:          aws.String(updateAdd),
+			ConditionExpression:       aws.String(condCounterLive),
+			ExpressionAttributeNames:  names("#c", "#e"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":delta": number(delta), ":now": number(s.now().UnixMilli())},
+			ReturnValues:              types.ReturnValueUpdatedNew,
+		})
+		if err == nil {
+			count, ok := out.Attributes[attrCount].(*types.AttributeValueMemberN)
+			if !ok {
+				return 0, fmt.Errorf("dynamostore: update of %q returned no count", key)
+			}
+			return strconv.ParseInt(count.Value, 10, 64)
+		}
+		if !isConditionFailed(err) {
+			return 0, err
+		}
+
+		// Missing and expired counters are created afresh.
+		update, values := updateResetNoTTL, map[string]types.AttributeValue{":delta": number(delta), ":now": number(s.now().UnixMilli())}
+		if ttl > 0 {
+			update = updateReset
+			expiresAt := s.now().Add(ttl)
+			values[":exp"] = number(expiresAt.UnixMilli())
+			values[":ttl"] = number(expiresAt.Add(time.Second - 1).Unix())
+		}
+		_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.table),
+			Key:                       itemKey(key),
+			UpdateExpression:          aws.String(update),
+			ConditionExpression:       aws.String(condMissing),
+			ExpressionAttributeNames:  names("#k", "#v", "#c", "#e", "#t"),
+			ExpressionAttributeValues: values,
+		})
+		if err == nil {
+			return delta, nil
+		}
+		if !isConditionFailed(err) {
+			return 0, err
+		}
+
+		// The key exists, either because it was created concurrently or because it holds a value
+		// that is not a counter.
+		item, err := s.item(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := item[attrValue]; ok {
+			return 0, fmt.Errorf("%w: %q is not a counter", cerberus.ErrMalformedValue, key)
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// CompareAndSwap stores new under key only if the current value equals old, where a nil old value
+// matches a missing key. It reports whether the value was stored.
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	values := map[string]types.AttributeValue{":now": number(s.now().UnixMilli())}
+	condition, attributes := condMissing, names("#k", "#e")
+	if old != nil {
+		condition, attributes = condValueLive, names("#v", "#e")
+		values[":old"] = &types.AttributeValueMemberB{Value: old}
+		if count, ok := parseCount(old); ok {
+			condition, attributes = condCountLive, names("#c", "#e")
+			values[":old"] = number(count)
+		}
+	}
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(s.table),
+		Item:                      s.newItem(key, new, ttl),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  attributes,
+		ExpressionAttributeValues: values,
+	})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// TTL returns the remaining time to live of key. It returns zero if the key does not exist or does
+// not expire.
+func (s *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
+	item, err := s.item(ctx, key)
+	if err != nil || item == nil {
+		return 0, err
+	}
+	expiresAt, ok := expiration(item)
+	if !ok {
+		return 0, nil
+	}
+	return max(time.UnixMilli(expiresAt).Sub(s.now()), 0), nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       itemKey(key),
+	})
+	return err
+}
+
+// ScanKeys returns the keys starting with prefix that have not expired, in no particular order.
+func (s *Store) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(s.table),
+		ProjectionExpression:     aws.String("#k"),
+		FilterExpression:         aws.String(filterScan),
+		ExpressionAttributeNames: names("#k", "#e"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+			":now":    number(s.now().UnixMilli()),
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+	var keys []string
+	for {
+		out, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			if key, ok := item[attrKey].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, key.Value)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// item returns the item of key, or nil if it does not exist or has expired.
+func (s *Store) item(ctx context.Context, key string) (map[string]types.AttributeValue, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return nil, err
+	}
+	if expiresAt, ok := expiration(out.Item); ok && expiresAt <= s.now().UnixMilli() {
+		return nil, nil
+	}
+	return out.Item, nil
+}
+
+// newItem returns the item storing value under key for ttl. Integers are stored as numbers, so
+// that they can be incremented in place.
+func (s *Store) newItem(key string, value []byte, ttl time.Duration) map[string]types.AttributeValue {
+	item := itemKey(key)
+	if count, ok := parseCount(value); ok {
+		item[attrCount] = number(count)
+	} else {
+		item[attrValue] = &types.AttributeValueMemberB{Value: value}
+	}
+	if ttl > 0 {
+		expiresAt := s.now().Add(ttl)
+		item[attrExpiresAt] = number(expiresAt.UnixMilli())
+		// DynamoDB's Time to Live works with seconds, and must not remove items early.
+		item[attrTTL] = number(expiresAt.Add(time.Second - 1).Unix())
+	}
+	return item
+}
+
+// itemKey returns the primary key of the item of key.
+func itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{attrKey: &types.AttributeValueMemberS{Value: key}}
+}
+
+// expiration returns the expiration time of item in Unix milliseconds, and false if it does not
+// expire.
+func expiration(item map[string]types.AttributeValue) (int64, bool) {
+	attr, ok := item[attrExpiresAt].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, false
+	}
+	expiresAt, err := strconv.ParseInt(attr.Value, 10, 64)
+	return expiresAt, err == nil
+}
+
+// parseCount parses value as an integer in the canonical form written by Increment, so that it
+// can be stored as a number and read back unchanged.
+func parseCount(value []byte) (int64, bool) {
+	count, err := strconv.ParseInt(string(value), 10, 64)
+	return count, err == nil && strconv.FormatInt(count, 10) == string(value)
+}
+
+// names returns the attribute names of placeholders. DynamoDB rejects requests carrying names that
+// their expressions do not use.
+func names(placeholders ...string) map[string]string {
+	names := make(map[string]string, len(placeholders))
+	for _, placeholder := range placeholders {
+		names[placeholder] = attributeNames[placeholder]
+	}
+	return names
+}
+
+// number returns the DynamoDB number n.
+func number(n int64) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+// isConditionFailed reports whether err is the failure of a condition expression.
+func isConditionFailed(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionFailed)
+}
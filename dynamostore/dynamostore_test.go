@@ -0,0 +1,334 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// fakeDynamo is an in-memory model of the DynamoDB API, evaluating the expressions used by the
+// store. Like DynamoDB, it rejects requests whose placeholders do not match their expressions,
+// and it never removes expired items by itself.
+type fakeDynamo struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+var placeholder = regexp.MustCompile(`[#:]\w+`)
+
+// checkPlaceholders returns an error unless names and values are exactly the placeholders used by
+// expressions.
+func checkPlaceholders(names map[string]string, values map[string]types.AttributeValue, expressions ...*string) error {
+	used := map[string]bool{}
+	for _, expression := range expressions {
+		for _, p := range placeholder.FindAllString(aws.ToString(expression), -1) {
+			used[p] = true
+		}
+	}
+	var given []string
+	given = slices.AppendSeq(given, maps.Keys(names))
+	given = slices.AppendSeq(given, maps.Keys(values))
+	if len(given) != len(used) {
+		return fmt.Errorf("placeholders %v do not match expressions %v", given, used)
+	}
+	for _, p := range given {
+		if !used[p] {
+			return fmt.Errorf("unused placeholder %s", p)
+		}
+	}
+	return nil
+}
+
+func numberValue(attr types.AttributeValue) (int64, bool) {
+	n, ok := attr.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(n.Value, 10, 64)
+	return value, err == nil
+}
+
+func (f *fakeDynamo) condition(condition string, item map[string]types.AttributeValue, values map[string]types.AttributeValue) bool {
+	now, _ := numberValue(values[":now"])
+	expiresAt, expires := numberValue(item[attrExpiresAt])
+	live := item != nil && (!expires || expiresAt > now)
+	switch condition {
+	case "":
+		return true
+	case condMissing:
+		return item == nil || expires && expiresAt <= now
+	case condCounterLive:
+		_, ok := item[attrCount]
+		return ok && live
+	case condValueLive:
+		value, ok := item[attrValue].(*types.AttributeValueMemberB)
+		return ok && string(value.Value) == string(values[":old"].(*types.AttributeValueMemberB).Value) && live
+	case condCountLive:
+		count, ok := numberValue(item[attrCount])
+		old, _ := numberValue(values[":old"])
+		return ok && count == old && live
+	}
+	panic("unexpected condition " + condition)
+}
+
+func (f *fakeDynamo) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item := f.items[params.Key[attrKey].(*types.AttributeValueMemberS).Value]
+	return &dynamodb.GetItemOutput{Item: maps.Clone(item)}, nil
+}
+
+func (f *fakeDynamo) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := checkPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := params.Item[attrKey].(*types.AttributeValueMemberS).Value
+	if !f.condition(aws.ToString(params.ConditionExpression), f.items[key], params.ExpressionAttributeValues) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	f.items[key] = maps.Clone(params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamo) UpdateItem(_ context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := checkPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression, params.UpdateExpression); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := params.Key[attrKey].(*types.AttributeValueMemberS).Value
+	values := params.ExpressionAttributeValues
+	if !f.condition(aws.ToString(params.ConditionExpression), f.items[key], values) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	item := maps.Clone(f.items[key])
+	if item == nil {
+		item = maps.Clone(params.Key)
+	}
+	switch aws.ToString(params.UpdateExpression) {
+	case updateAdd:
+		count, _ := numberValue(item[attrCount])
+		delta, _ := numberValue(values[":delta"])
+		item[attrCount] = number(count + delta)
+	case updateReset:
+		item[attrCount], item[attrExpiresAt], item[attrTTL] = values[":delta"], values[":exp"], values[":ttl"]
+		delete(item, attrValue)
+	case updateResetNoTTL:
+		item[attrCount] = values[":delta"]
+		delete(item, attrValue)
+		delete(item, attrExpiresAt)
+		delete(item, attrTTL)
+	default:
+		panic("unexpected update " + aws.ToString(params.UpdateExpression))
+	}
+	f.items[key] = item
+	out := &dynamodb.UpdateItemOutput{}
+	if params.ReturnValues == types.ReturnValueUpdatedNew {
+		out.Attributes = map[string]types.AttributeValue{attrCount: item[attrCount]}
+	}
+	return out, nil
+}
+
+func (f *fakeDynamo) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, params.Key[attrKey].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// Scan returns one item per page, to exercise pagination.
+func (f *fakeDynamo) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := checkPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.FilterExpression, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := slices.Sorted(maps.Keys(f.items))
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		start = sort.SearchStrings(keys, params.ExclusiveStartKey[attrKey].(*types.AttributeValueMemberS).Value) + 1
+	}
+	out := &dynamodb.ScanOutput{}
+	if start >= len(keys) {
+		return out, nil
+	}
+	key := keys[start]
+	prefix := params.ExpressionAttributeValues[":prefix"].(*types.AttributeValueMemberS).Value
+	now, _ := numberValue(params.ExpressionAttributeValues[":now"])
+	expiresAt, expires := numberValue(f.items[key][attrExpiresAt])
+	if strings.HasPrefix(key, prefix) && (!expires || expiresAt > now) {
+		out.Items = append(out.Items, itemKey(key))
+	}
+	out.LastEvaluatedKey = itemKey(key)
+	return out, nil
+}
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newStore() (*Store, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	store := New(newFakeDynamo(), "rate-limits")
+	store.now = clock.Now
+	return store, clock
+}
+
+// Test the store implements the semantics of cerberus.Store
+func TestStore(t *testing.T) {
+	store, clock := newStore()
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "missing"); err != nil || value != nil {
+		t.Errorf("expected nil for a missing key; got %q, %v", value, err)
+	}
+	store.Set(ctx, "a", []byte("value"), time.Minute)
+	if value, _ := store.Get(ctx, "a"); string(value) != "value" {
+		t.Errorf("expected value; got %q", value)
+	}
+	if ttl, _ := store.TTL(ctx, "a"); ttl != time.Minute {
+		t.Errorf("expected a TTL of a minute; got %v", ttl)
+	}
+	store.Set(ctx, "empty", []byte{}, 0)
+	if value, _ := store.Get(ctx, "empty"); value == nil || len(value) != 0 {
+		t.Errorf("expected an empty value; got %v", value)
+	}
+
+	if swapped, err := store.CompareAndSwap(ctx, "b", nil, []byte("x"), 0); err != nil || !swapped {
+		t.Errorf("expected swap of a missing key; got %v, %v", swapped, err)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", nil, []byte("y"), 0); swapped {
+		t.Errorf("expected no swap of an existing key against nil")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("x"), []byte("7"), 0); !swapped {
+		t.Errorf("expected swap of a matching value")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("7"), []byte("8"), 0); !swapped {
+		t.Errorf("expected swap of a matching integer")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("7"), []byte("9"), 0); swapped {
+		t.Errorf("expected no swap of a stale value")
+	}
+	if value, _ := store.Get(ctx, "b"); string(value) != "8" {
+		t.Errorf("expected 8; got %q", value)
+	}
+	if keys, err := store.ScanKeys(ctx, ""); err != nil || !slices.Equal(keys, []string{"a", "b", "empty"}) {
+		t.Errorf("expected keys [a b empty]; got %v, %v", keys, err)
+	}
+
+	clock.Advance(time.Minute)
+	if value, _ := store.Get(ctx, "a"); value != nil {
+		t.Errorf("expected an expired key to be missing; got %q", value)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "a", nil, []byte("new"), 0); !swapped {
+		t.Errorf("expected swap of an expired key against nil")
+	}
+	store.Delete(ctx, "b")
+	if keys, _ := store.ScanKeys(ctx, "e"); !slices.Equal(keys, []string{"empty"}) {
+		t.Errorf("expected keys [empty]; got %v", keys)
+	}
+}
+
+// Test increments create, update, and reject keys
+func TestStoreIncrement(t *testing.T) {
+	store, clock := newStore()
+	ctx := context.Background()
+
+	if n, err := store.Increment(ctx, "counter", 2, time.Minute); err != nil || n != 2 {
+		t.Errorf("expected 2; got %d, %v", n, err)
+	}
+	if n, err := store.Increment(ctx, "counter", 3, time.Hour); err != nil || n != 5 {
+		t.Errorf("expected 5; got %d, %v", n, err)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl != time.Minute {
+		t.Errorf("expected the TTL of the counter to be kept; got %v", ttl)
+	}
+	if value, _ := store.Get(ctx, "counter"); string(value) != "5" {
+		t.Errorf("expected 5; got %q", value)
+	}
+	clock.Advance(time.Minute)
+	if n, _ := store.Increment(ctx, "counter", 1, 0); n != 1 {
+		t.Errorf("expected an expired counter to restart; got %d", n)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl != 0 {
+		t.Errorf("expected no TTL; got %v", ttl)
+	}
+	store.Set(ctx, "text", []byte("text"), 0)
+	if _, err := store.Increment(ctx, "text", 1, 0); !errors.Is(err, cerberus.ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", err)
+	}
+}
+
+// Test concurrent increments are not lost
+func TestStoreConcurrentIncrements(t *testing.T) {
+	store, _ := newStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Increment(context.Background(), "counter", 1, time.Minute)
+		}()
+	}
+	wg.Wait()
+	if value, _ := store.Get(context.Background(), "counter"); string(value) != "20" {
+		t.Errorf("expected 20; got %q", value)
+	}
+}
+
+// Test built-in limiters work on top of the store
+func TestStoreWithLimiter(t *testing.T) {
+	store, _ := newStore()
+	limiter := cerberus.NewFixedWindowLimiter(2, time.Minute, cerberus.WithStore(store))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if err := limiter.RefundRequest(context.Background(), req); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+		t.Errorf("expected request to be allowed after a refund; got %v, %v", isAllowed, err)
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+}
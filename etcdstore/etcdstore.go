@@ -0,0 +1,208 @@
+// Package etcdstore provides a [cerberus.Store] backed by etcd, so that the instances of a service
+// enforce common limits without running Redis, for example on Kubernetes clusters where etcd is
+// already used for coordination.
+//
+// Every key of the store is an etcd key under a prefix, holding the value as is. Atomic updates
+// are etcd transactions comparing the revision or the value of the key, and keys expire with etcd
+// leases:
+//
+//	client, err := clientv3.New(clientv3.Config{Endpoints: []string{"etcd:2379"}})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	store := etcdstore.New(client, etcdstore.WithPrefix("/ratelimit/"))
+//	limiter := cerberus.NewTokenBucketLimiter(100, 10, cerberus.WithStore(store))
+//
+// Leases have a granularity of one second, so times to live are rounded up to whole seconds, and
+// every write with a time to live grants a lease. etcd is optimized for consistency rather than
+// throughput, so the store suits limits on moderately busy services, such as limits per tenant,
+// rather than per-request limits of high traffic APIs.
+package etcdstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Store is a [cerberus.Store] and [cerberus.KeyScanner] keeping its entries in etcd.
+//
+// A Store is safe for concurrent use by multiple goroutines.
+type Store struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// Option configures a [Store].
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the etcd keys of the store, so that several stores, or other
+// applications, can share an etcd cluster. The default is "cerberus/".
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// New creates a new [Store] using client. The client is not closed by the store.
+func New(client *clientv3.Client, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		prefix: "cerberus/",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get returns the value stored under key, or nil if the key does not exist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.Get(ctx, s.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return nonNil(resp.Kvs[0].Value), nil
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	opts, err := s.leaseOptions(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(ctx, s.prefix+key, string(value), opts...)
+	return err
+}
+
+// Increment adds delta to the integer stored under key and returns the result. A missing key is
+// created with the given TTL, and the lease of an existing key is kept.
+func (s *Store) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	etcdKey := s.prefix + key
+	for {
+		resp, err := s.client.Get(ctx, etcdKey)
+		if err != nil {
+			return 0, err
+		}
+		var txn clientv3.Txn
+		var n int64
+		if len(resp.Kvs) == 0 {
+			opts, err := s.leaseOptions(ctx, ttl)
+			if err != nil {
+				return 0, err
+			}
+			n = delta
+			txn = s.client.Txn(ctx).
+				If(clientv3.Compare(clientv3.CreateRevision(etcdKey), "=", 0)).
+				Then(clientv3.OpPut(etcdKey, strconv.FormatInt(n, 10), opts...))
+		} else {
+			kv := resp.Kvs[0]
+			current, err := strconv.ParseInt(string(kv.Value), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %q is not an integer", cerberus.ErrMalformedValue, key)
+			}
+			n = current + delta
+			txn = s.client.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(etcdKey), "=", kv.ModRevision)).
+				Then(clientv3.OpPut(etcdKey, strconv.FormatInt(n, 10), clientv3.WithIgnoreLease()))
+		}
+		txnResp, err := txn.Commit()
+		if err != nil {
+			return 0, err
+		}
+		if txnResp.Succeeded {
+			return n, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// CompareAndSwap stores new under key only if the current value equals old, where a nil old value
+// matches a missing key. It reports whether the value was stored.
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	etcdKey := s.prefix + key
+	cmp := clientv3.Compare(clientv3.Value(etcdKey), "=", string(old))
+	if old == nil {
+		cmp = clientv3.Compare(clientv3.CreateRevision(etcdKey), "=", 0)
+	}
+	opts, err := s.leaseOptions(ctx, ttl)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.client.Txn(ctx).If(cmp).Then(clientv3.OpPut(etcdKey, string(new), opts...)).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// TTL returns the remaining time to live of key, in whole seconds. It returns zero if the key does
+// not exist or does not expire.
+func (s *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
+	resp, err := s.client.Get(ctx, s.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 || resp.Kvs[0].Lease == 0 {
+		return 0, nil
+	}
+	lease, err := s.client.TimeToLive(ctx, clientv3.LeaseID(resp.Kvs[0].Lease))
+	if err != nil {
+		return 0, err
+	}
+	if lease.TTL < 0 {
+		return 0, nil
+	}
+	// Leases report whole seconds, so a live lease with under a second left reports zero.
+	return time.Duration(max(lease.TTL, 1)) * time.Second, nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.Delete(ctx, s.prefix+key)
+	return err
+}
+
+// ScanKeys returns the keys starting with prefix, in no particular order.
+func (s *Store) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	resp, err := s.client.Get(ctx, s.prefix+prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, strings.TrimPrefix(string(kv.Key), s.prefix))
+	}
+	return keys, nil
+}
+
+// leaseOptions grants a lease expiring after ttl, rounded up to whole seconds, and returns the
+// options attaching it to a put. No lease is granted if ttl is not positive.
+func (s *Store) leaseOptions(ctx context.Context, ttl time.Duration) ([]clientv3.OpOption, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+	lease, err := s.client.Grant(ctx, int64((ttl+time.Second-1)/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return []clientv3.OpOption{clientv3.WithLease(lease.ID)}, nil
+}
+
+// nonNil returns b, or an empty slice if b is nil, since nil values denote missing keys.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
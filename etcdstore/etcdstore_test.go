@@ -0,0 +1,176 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// freeURL returns the URL of a local port that is free to listen on.
+func freeURL(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	defer listener.Close()
+	return url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+// newStore starts an embedded etcd server and returns a store connected to it.
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	config := embed.NewConfig()
+	config.Dir = t.TempDir()
+	config.LogLevel = "error"
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	config.ListenClientUrls, config.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	config.ListenPeerUrls, config.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	config.InitialCluster = config.InitialClusterFromName(config.Name)
+	server, err := embed.StartEtcd(config)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	t.Cleanup(server.Close)
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatalf("expected etcd to start")
+	}
+
+	client, err := clientv3.New(clientv3.Config{Endpoints: []string{clientURL.String()}, DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return New(client, WithPrefix("test/"))
+}
+
+// Test the store implements the semantics of cerberus.Store
+func TestStore(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "missing"); err != nil || value != nil {
+		t.Errorf("expected nil for a missing key; got %q, %v", value, err)
+	}
+	if err := store.Set(ctx, "a", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if value, _ := store.Get(ctx, "a"); string(value) != "value" {
+		t.Errorf("expected value; got %q", value)
+	}
+	if ttl, err := store.TTL(ctx, "a"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected a TTL of up to a minute; got %v, %v", ttl, err)
+	}
+	if err := store.Set(ctx, "empty", []byte{}, 0); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if value, _ := store.Get(ctx, "empty"); value == nil || len(value) != 0 {
+		t.Errorf("expected an empty value; got %v", value)
+	}
+	if ttl, _ := store.TTL(ctx, "empty"); ttl != 0 {
+		t.Errorf("expected no TTL; got %v", ttl)
+	}
+
+	if swapped, err := store.CompareAndSwap(ctx, "b", nil, []byte("1"), 0); err != nil || !swapped {
+		t.Errorf("expected swap of a missing key; got %v, %v", swapped, err)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", nil, []byte("2"), 0); swapped {
+		t.Errorf("expected no swap of an existing key against nil")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("1"), []byte("2"), 0); !swapped {
+		t.Errorf("expected swap of a matching value")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("1"), []byte("3"), 0); swapped {
+		t.Errorf("expected no swap of a stale value")
+	}
+
+	if err := store.Delete(ctx, "b"); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if value, _ := store.Get(ctx, "b"); value != nil {
+		t.Errorf("expected a deleted key to be missing; got %q", value)
+	}
+	if keys, err := store.ScanKeys(ctx, ""); err != nil || !slices.Equal(sorted(keys), []string{"a", "empty"}) {
+		t.Errorf("expected keys [a empty]; got %v, %v", keys, err)
+	}
+}
+
+// Test increments create, update, and reject keys
+func TestStoreIncrement(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+
+	if n, err := store.Increment(ctx, "counter", 2, time.Minute); err != nil || n != 2 {
+		t.Errorf("expected 2; got %d, %v", n, err)
+	}
+	if n, err := store.Increment(ctx, "counter", 3, time.Hour); err != nil || n != 5 {
+		t.Errorf("expected 5; got %d, %v", n, err)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl > time.Minute {
+		t.Errorf("expected the TTL of the counter to be kept; got %v", ttl)
+	}
+	store.Set(ctx, "text", []byte("text"), 0)
+	if _, err := store.Increment(ctx, "text", 1, 0); !errors.Is(err, cerberus.ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", err)
+	}
+}
+
+// Test keys expire with their lease, and report a positive TTL until then
+func TestStoreExpires(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+
+	store.Set(ctx, "a", []byte("value"), time.Millisecond)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ttl, err := store.TTL(ctx, "a")
+		if err != nil {
+			t.Fatalf("expected no error; got %v", err)
+		}
+		value, err := store.Get(ctx, "a")
+		if err != nil {
+			t.Fatalf("expected no error; got %v", err)
+		}
+		if value == nil {
+			break
+		}
+		if ttl <= 0 {
+			t.Fatalf("expected a positive TTL while the key exists; got %v", ttl)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the key to expire")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Test built-in limiters work on top of the store
+func TestStoreWithLimiter(t *testing.T) {
+	limiter := cerberus.NewFixedWindowLimiter(2, time.Minute, cerberus.WithStore(newStore(t)))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+}
+
+func sorted(keys []string) []string {
+	slices.Sort(keys)
+	return keys
+}
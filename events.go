@@ -0,0 +1,317 @@
+package cerberus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the kind of an [Event].
+type EventType string
+
+const (
+	// EventDenied is the type of the events of requests denied by a rate limiter.
+	EventDenied EventType = "denied"
+
+	// EventBanned is the type of the events of clients banned by the penalty policy set with
+	// [WithPenaltyPolicy].
+	EventBanned EventType = "banned"
+)
+
+// Event is a structured record of a rate limiting decision worth the attention of a security team,
+// such as the denial of a request or the ban of a client, published by the middlewares to an
+// [EventPublisher]. Events are encoded as JSON objects with snake_case fields and durations
+// formatted as strings such as "1.5s", and fields that do not apply are omitted.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Key identifies the client, if the rate limiter reports the keys of clients.
+	Key string
+	// RemoteAddr is the network address of the connection of the request, which is the address of
+	// the last proxy in front of the server, if any.
+	RemoteAddr string
+	Method     string
+	// Route is the pattern of the matching [http.ServeMux] route, or the path if there is none.
+	Route     string
+	UserAgent string
+
+	// Limit and RetryAfter are reported for the denials of an [AdvancedRateLimiter].
+	Limit      int
+	RetryAfter time.Duration
+	// Ban is the duration of the ban of a client.
+	Ban time.Duration
+	// Shadow is set on the events of middlewares in shadow mode, whose denials are not enforced.
+	// See [WithShadowMode].
+	Shadow bool
+}
+
+// MarshalJSON encodes the event as a JSON object with its durations as duration strings.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event struct {
+		Type       EventType `json:"type"`
+		Time       time.Time `json:"time"`
+		Key        string    `json:"key,omitempty"`
+		RemoteAddr string    `json:"remote_addr,omitempty"`
+		Method     string    `json:"method"`
+		Route      string    `json:"route"`
+		UserAgent  string    `json:"user_agent,omitempty"`
+		Limit      int       `json:"limit,omitempty"`
+		RetryAfter string    `json:"retry_after,omitempty"`
+		Ban        string    `json:"ban,omitempty"`
+		Shadow     bool      `json:"shadow,omitempty"`
+	}
+	encoded := event{
+		Type:       e.Type,
+		Time:       e.Time,
+		Key:        e.Key,
+		RemoteAddr: e.RemoteAddr,
+		Method:     e.Method,
+		Route:      e.Route,
+		UserAgent:  e.UserAgent,
+		Limit:      e.Limit,
+		Shadow:     e.Shadow,
+	}
+	if e.RetryAfter > 0 {
+		encoded.RetryAfter = e.RetryAfter.String()
+	}
+	if e.Ban > 0 {
+		encoded.Ban = e.Ban.String()
+	}
+	return json.Marshal(encoded)
+}
+
+// EventExporter sends batches of events to an external system, such as a Kafka topic or a NATS
+// subject feeding a SIEM or an abuse detection pipeline. Exporters implementing [Closer] are closed
+// by [EventPublisher.Close].
+type EventExporter interface {
+	// ExportEvents sends events, in order, and returns an error if any of them could not be sent.
+	ExportEvents(ctx context.Context, events []Event) error
+}
+
+// EventPublisher publishes the events of the middlewares it is set on with [WithEventPublisher] to
+// an [EventExporter], in the background, so that a slow or unavailable external system never
+// delays requests.
+//
+// Behavior:
+//   - Events are queued in a bounded buffer, and exported in batches of up to 1000 events, once
+//     per flush interval or as soon as a batch is full.
+//   - Events published while the buffer is full are dropped, and counted by
+//     [EventPublisher.Dropped], so that a flood of denials cannot exhaust the memory of the
+//     service.
+//   - Errors of the exporter are reported to the error handler, and the events of the failed
+//     batches are lost.
+//
+// An EventPublisher is safe for concurrent use by multiple goroutines, and may be shared by
+// several middlewares. It must be closed with [EventPublisher.Close] on shutdown, which exports
+// the events still queued.
+//
+// Example usage:
+//
+//	events := NewEventPublisher(myExporter, func(err error) { log.Print(err) })
+//	defer events.Close(context.Background())
+//	http.Handle("/", New(myRateLimiter, WithEventPublisher(events))(myHandler))
+type EventPublisher struct {
+	exporter      EventExporter
+	onError       func(error)
+	flushInterval time.Duration
+
+	events  chan Event
+	dropped atomic.Uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+	closeErr  error
+}
+
+// maxEventBatch is the number of events that makes an [EventPublisher] export its batch before
+// the flush interval has elapsed.
+const maxEventBatch = 1000
+
+// EventPublisherOption configures an [EventPublisher].
+type EventPublisherOption func(*EventPublisher)
+
+// WithEventBufferSize sets the number of events an [EventPublisher] queues before dropping new
+// ones. The default is 10000.
+func WithEventBufferSize(size int) EventPublisherOption {
+	return func(p *EventPublisher) {
+		p.events = make(chan Event, size)
+	}
+}
+
+// WithEventFlushInterval sets how long an [EventPublisher] waits for more events after the first
+// one of a batch, which is the delay of events during quiet periods. The default is 1s.
+func WithEventFlushInterval(interval time.Duration) EventPublisherOption {
+	return func(p *EventPublisher) {
+		p.flushInterval = interval
+	}
+}
+
+// NewEventPublisher creates a new [EventPublisher] exporting events with exporter, and reporting
+// its errors to onError, which may be nil to ignore them.
+//
+// It panics if the buffer size or the flush interval is not positive.
+func NewEventPublisher(exporter EventExporter, onError func(error), opts ...EventPublisherOption) *EventPublisher {
+	p := &EventPublisher{
+		exporter:      exporter,
+		onError:       onError,
+		flushInterval: time.Second,
+		events:        make(chan Event, 10000),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if cap(p.events) <= 0 {
+		panic("cerberus: event buffer size must be positive")
+	}
+	if p.flushInterval <= 0 {
+		panic("cerberus: event flush interval must be positive")
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues event for export, or drops it if the buffer is full or the publisher is closed.
+func (p *EventPublisher) Publish(event Event) {
+	select {
+	case <-p.done:
+		p.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case p.events <- event:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full or the publisher was
+// closed.
+func (p *EventPublisher) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Close stops accepting events and exports those still queued, waiting for that to complete until
+// ctx is done, in which case it returns the error of ctx and the export completes in the
+// background. The exporter is closed afterwards if it implements [Closer]. Events published
+// concurrently with Close may be lost. Calling it more than once has no further effect. It
+// implements Closer.
+func (p *EventPublisher) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	select {
+	case <-p.stopped:
+		return p.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run exports the published events in batches until the publisher is closed, and closes the
+// exporter then.
+func (p *EventPublisher) run() {
+	defer close(p.stopped)
+	var batch []Event
+	var timer <-chan time.Time
+	for {
+		select {
+		case event := <-p.events:
+			batch = append(batch, event)
+			if len(batch) >= maxEventBatch {
+				batch, timer = p.export(batch), nil
+			} else if timer == nil {
+				timer = time.After(p.flushInterval)
+			}
+		case <-timer:
+			batch, timer = p.export(batch), nil
+		case <-p.done:
+			for len(p.events) > 0 {
+				if batch = append(batch, <-p.events); len(batch) >= maxEventBatch {
+					batch = p.export(batch)
+				}
+			}
+			p.export(batch)
+			p.closeErr = closeAll(context.Background(), p.exporter)
+			return
+		}
+	}
+}
+
+// export exports batch, and returns a new empty batch.
+func (p *EventPublisher) export(batch []Event) []Event {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := p.exporter.ExportEvents(context.Background(), batch); err != nil && p.onError != nil {
+		p.onError(err)
+	}
+	return nil
+}
+
+// WithEventPublisher publishes an [Event] to publisher for each request denied by the middleware,
+// and for each client banned by the penalty policy set with [WithPenaltyPolicy], so that security
+// teams can feed throttling data into their SIEM and abuse detection pipelines. Requests exempted
+// with [WithSkipFunc] or [WithAllowlist], and requests rejected with [WithDenylist], do not
+// publish any event.
+//
+// Example usage: http.Handle("/", New(myRateLimiter, WithEventPublisher(events))(myHandler))
+func WithEventPublisher(publisher *EventPublisher) Option {
+	return func(o *options) {
+		o.events = publisher
+	}
+}
+
+// publishDenied publishes the denial of r by rateLimiter. data is the rate limit data reported for
+// the request, if any.
+func (o *options) publishDenied(r *http.Request, rateLimiter RateLimiter, data RateLimitData) {
+	if o.events == nil {
+		return
+	}
+	event := o.newEvent(EventDenied, r, rateLimiter)
+	event.Limit = data.Limit
+	event.RetryAfter = data.RetryAfter
+	o.events.Publish(event)
+}
+
+// publishBanned publishes the ban for d of the client identified by key, following the denial of
+// r.
+func (o *options) publishBanned(r *http.Request, rateLimiter RateLimiter, key string, d time.Duration) {
+	if o.events == nil {
+		return
+	}
+	event := o.newEvent(EventBanned, r, rateLimiter)
+	event.Key = key
+	event.Ban = d
+	o.events.Publish(event)
+}
+
+// newEvent returns an event of the given type describing r.
+func (o *options) newEvent(eventType EventType, r *http.Request, rateLimiter RateLimiter) Event {
+	route := r.Pattern
+	if route == "" {
+		route = r.URL.Path
+	}
+	event := Event{
+		Type:       eventType,
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Route:      route,
+		UserAgent:  r.UserAgent(),
+		Shadow:     o.shadow,
+	}
+	if k, ok := rateLimiter.(keyer); ok {
+		if key, err := k.key(r); err == nil {
+			event.Key = key
+		}
+	}
+	return event
+}
@@ -0,0 +1,186 @@
+package cerberus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingExporter records the events it exports, and whether it was closed.
+type recordingExporter struct {
+	mu      sync.Mutex
+	batches [][]Event
+	err     error
+	closed  bool
+}
+
+func (e *recordingExporter) ExportEvents(ctx context.Context, events []Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, events)
+	return e.err
+}
+
+func (e *recordingExporter) Close(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+func (e *recordingExporter) events() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []Event
+	for _, batch := range e.batches {
+		events = append(events, batch...)
+	}
+	return events
+}
+
+// Test the middleware publishes the denials of requests and the bans of clients
+func TestWithEventPublisher(t *testing.T) {
+	exporter := &recordingExporter{}
+	publisher := NewEventPublisher(exporter, nil)
+	limiter := NewTokenBucketLimiter(1, 0.001)
+	handler := New(limiter,
+		WithEventPublisher(publisher),
+		WithPenaltyPolicy(PenaltyPolicy{Threshold: 1, Window: time.Minute, Ban: time.Minute}),
+	)(noContent)
+
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("User-Agent", "scraper/1.0")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := exporter.events()
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if len(events) != 3 || types[0] != EventDenied || types[1] != EventDenied || types[2] != EventBanned {
+		t.Fatalf("expected two denials and a ban; got %v", types)
+	}
+	denied := events[0]
+	if denied.Key != "192.0.2.1" || denied.Route != "/api" || denied.Method != http.MethodGet ||
+		denied.UserAgent != "scraper/1.0" || denied.Limit != 1 || denied.RetryAfter <= 0 {
+		t.Errorf("expected the denial to describe the request; got %+v", denied)
+	}
+	if banned := events[2]; banned.Key != "192.0.2.1" || banned.Ban != time.Minute {
+		t.Errorf("expected the ban of the client for 1m; got %+v", banned)
+	}
+	if !exporter.closed {
+		t.Errorf("expected the exporter to be closed")
+	}
+}
+
+// Test events are encoded with snake_case fields and duration strings
+func TestEventMarshalJSON(t *testing.T) {
+	event := Event{
+		Type:       EventDenied,
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Key:        "192.0.2.1",
+		Method:     http.MethodGet,
+		Route:      "/api",
+		Limit:      10,
+		RetryAfter: 1500 * time.Millisecond,
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"type":"denied","time":"2024-01-02T03:04:05Z","key":"192.0.2.1","method":"GET","route":"/api","limit":10,"retry_after":"1.5s"}`
+	if string(data) != want {
+		t.Errorf("expected %s; got %s", want, data)
+	}
+}
+
+// Test events are exported once the flush interval has elapsed
+func TestEventPublisherFlushInterval(t *testing.T) {
+	exporter := &recordingExporter{}
+	publisher := NewEventPublisher(exporter, nil, WithEventFlushInterval(5*time.Millisecond))
+	defer publisher.Close(context.Background())
+
+	publisher.Publish(Event{Type: EventDenied})
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.events()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the event to be exported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Test events are dropped when the buffer is full or the publisher is closed
+func TestEventPublisherDrops(t *testing.T) {
+	release := make(chan struct{})
+	exporter := &blockingExporter{release: release, started: make(chan struct{})}
+	publisher := NewEventPublisher(exporter, nil, WithEventBufferSize(1), WithEventFlushInterval(time.Millisecond))
+
+	publisher.Publish(Event{Type: EventDenied})
+	<-exporter.started
+	publisher.Publish(Event{Type: EventDenied})
+	publisher.Publish(Event{Type: EventDenied})
+	if dropped := publisher.Dropped(); dropped != 1 {
+		t.Errorf("expected 1 event dropped while the buffer is full; got %d", dropped)
+	}
+	close(release)
+	publisher.Close(context.Background())
+	publisher.Publish(Event{Type: EventDenied})
+	if dropped := publisher.Dropped(); dropped != 2 {
+		t.Errorf("expected events published after Close to be dropped; got %d dropped", dropped)
+	}
+}
+
+// blockingExporter signals started on its first export, and blocks until release is closed.
+type blockingExporter struct {
+	release chan struct{}
+	started chan struct{}
+	once    sync.Once
+}
+
+func (e *blockingExporter) ExportEvents(ctx context.Context, events []Event) error {
+	e.once.Do(func() { close(e.started) })
+	<-e.release
+	return nil
+}
+
+// Test the errors of the exporter are reported
+func TestEventPublisherErrors(t *testing.T) {
+	errExport := errors.New("broker down")
+	var reported []error
+	publisher := NewEventPublisher(&recordingExporter{err: errExport}, func(err error) { reported = append(reported, err) })
+
+	publisher.Publish(Event{Type: EventDenied})
+	publisher.Close(context.Background())
+	if len(reported) != 1 || !errors.Is(reported[0], errExport) {
+		t.Errorf("expected %v to be reported; got %v", errExport, reported)
+	}
+}
+
+// Test NewEventPublisher panics with invalid options
+func TestEventPublisherPanics(t *testing.T) {
+	for name, opt := range map[string]EventPublisherOption{
+		"zero buffer size":    WithEventBufferSize(0),
+		"zero flush interval": WithEventFlushInterval(0),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			NewEventPublisher(&recordingExporter{}, nil, opt)
+		}()
+	}
+}
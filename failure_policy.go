@@ -0,0 +1,35 @@
+package cerberus
+
+import "net/http"
+
+// FailurePolicy determines how the middlewares handle requests when the rate limiter returns an
+// error, such as when a distributed limiter cannot reach its backing store.
+type FailurePolicy int
+
+const (
+	// FailWithError passes the error to the error handler set with [WithErrorHandler], which
+	// responds with an HTTP 500 (Internal Server Error) by default.
+	FailWithError FailurePolicy = iota
+
+	// FailOpen forwards the request to the next handler as if it had been allowed. This keeps the
+	// service available while the rate limiter is failing, at the cost of not enforcing limits.
+	FailOpen
+
+	// FailClosed responds to the request as if it had been denied, using the handler set with
+	// [WithDeniedHandler]. This protects the service from overload while the rate limiter is
+	// failing, at the cost of rejecting all traffic.
+	FailClosed
+)
+
+// handleError responds to a request for which the rate limiter returned err according to the
+// configured failure policy.
+func (o *options) handleError(w http.ResponseWriter, r *http.Request, next http.Handler, err error) {
+	switch o.failurePolicy {
+	case FailOpen:
+		next.ServeHTTP(w, r)
+	case FailClosed:
+		o.deniedHandler.ServeHTTP(w, r)
+	default:
+		o.errorHandler(w, r, err)
+	}
+}
@@ -0,0 +1,127 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// FallbackLimiter is a [RateLimiter] and [AdvancedRateLimiter] that consults a primary rate
+// limiter, typically one backed by a shared [Store] such as Redis, and transparently falls back to
+// a secondary rate limiter, typically one backed by process memory, when the primary fails or is
+// too slow. An outage of the shared store then degrades limits from global to per-instance,
+// instead of failing every request open or closed.
+//
+// Behavior:
+//   - If the primary rate limiter decides within the latency budget, its decision is returned.
+//   - If it returns an error or takes longer than the latency budget, the request is checked
+//     against the fallback rate limiter instead, and its decision is returned.
+//   - If the context of the request is done, its error is returned without consulting the fallback
+//     rate limiter.
+//
+// A primary rate limiter that exceeds the latency budget is not waited for: it is left to finish
+// in the background with a cancelled context, so it should implement [RateLimiterContext] to
+// abort its work promptly.
+//
+// A FallbackLimiter is safe for concurrent use by multiple goroutines if its rate limiters are.
+//
+// Example usage:
+//
+//	limiter := NewFallbackLimiter(
+//		NewTokenBucketLimiter(100, 10, WithStore(myRedisStore)),
+//		NewTokenBucketLimiter(100, 10),
+//		50*time.Millisecond,
+//	)
+type FallbackLimiter struct {
+	primary       RateLimiter
+	fallback      RateLimiter
+	latencyBudget time.Duration
+}
+
+// NewFallbackLimiter creates a new [FallbackLimiter] that checks requests against primary, and
+// against fallback when primary fails or takes longer than latencyBudget. A non-positive
+// latencyBudget means primary is waited for until the context of the request is done.
+func NewFallbackLimiter(primary, fallback RateLimiter, latencyBudget time.Duration) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:       primary,
+		fallback:      fallback,
+		latencyBudget: latencyBudget,
+	}
+}
+
+// IsAllowed checks the request against the primary rate limiter, or against the fallback rate
+// limiter if the primary fails or exceeds the latency budget. An error is returned if the fallback
+// rate limiter fails as well.
+func (l *FallbackLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiters.
+func (l *FallbackLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	if isAllowed, err := l.checkPrimary(ctx, r); err == nil {
+		return isAllowed, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return isAllowed(ctx, l.fallback, r)
+}
+
+// GetRateLimitData returns the rate limit data reported by the primary rate limiter, or by the
+// fallback rate limiter if the primary reports none, for example because its store is
+// unreachable. Rate limiters that do not implement [AdvancedRateLimiter] report no data.
+func (l *FallbackLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	if advanced, ok := l.primary.(AdvancedRateLimiter); ok {
+		if data := advanced.GetRateLimitData(r); data.Limit != 0 {
+			return data
+		}
+	}
+	if advanced, ok := l.fallback.(AdvancedRateLimiter); ok {
+		return advanced.GetRateLimitData(r)
+	}
+	return RateLimitData{}
+}
+
+// Close closes the primary and fallback rate limiters, if they implement [Closer]. It implements
+// Closer.
+func (l *FallbackLimiter) Close(ctx context.Context) error {
+	return closeAll(ctx, l.primary, l.fallback)
+}
+
+// key returns the key identifying the client making the request, as reported by the primary rate
+// limiter, or by the fallback rate limiter if the primary does not report keys.
+func (l *FallbackLimiter) key(r *http.Request) (string, error) {
+	for _, rateLimiter := range []RateLimiter{l.primary, l.fallback} {
+		if k, ok := rateLimiter.(keyer); ok {
+			return k.key(r)
+		}
+	}
+	return "", ErrNoKey
+}
+
+// checkPrimary checks the request against the primary rate limiter, giving up with
+// [context.DeadlineExceeded] once the latency budget is exhausted.
+func (l *FallbackLimiter) checkPrimary(ctx context.Context, r *http.Request) (bool, error) {
+	if l.latencyBudget <= 0 {
+		return isAllowed(ctx, l.primary, r)
+	}
+	ctx, cancel := context.WithTimeout(ctx, l.latencyBudget)
+	defer cancel()
+	type result struct {
+		isAllowed bool
+		err       error
+	}
+	// The channel is buffered so that a primary rate limiter finishing after the budget does not
+	// block forever.
+	done := make(chan result, 1)
+	go func() {
+		isAllowed, err := isAllowed(ctx, l.primary, r)
+		done <- result{isAllowed, err}
+	}()
+	select {
+	case res := <-done:
+		return res.isAllowed, res.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,129 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test the primary decision is returned when the primary works
+func TestFallbackLimiterUsesPrimary(t *testing.T) {
+	fallbackCalls := 0
+	limiter := NewFallbackLimiter(
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return false, nil
+			},
+		},
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				fallbackCalls++
+				return true, nil
+			},
+		},
+		time.Second,
+	)
+
+	isAllowed, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+
+	if err != nil || isAllowed {
+		t.Errorf("expected primary denial; got %v, %v", isAllowed, err)
+	}
+	if fallbackCalls != 0 {
+		t.Errorf("expected fallback not to be consulted; got %v calls", fallbackCalls)
+	}
+}
+
+// Test the fallback decides when the primary fails
+func TestFallbackLimiterPrimaryError(t *testing.T) {
+	limiter := NewFallbackLimiter(
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return false, errors.New("store unavailable")
+			},
+		},
+		NewFixedWindowLimiter(1, time.Minute),
+		0,
+	)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+		t.Errorf("expected fallback to allow the first request; got %v, %v", isAllowed, err)
+	}
+	if isAllowed, err := limiter.IsAllowed(req); err != nil || isAllowed {
+		t.Errorf("expected fallback to deny the second request; got %v, %v", isAllowed, err)
+	}
+}
+
+// Test the fallback decides when the primary exceeds the latency budget
+func TestFallbackLimiterLatencyBudget(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	limiter := NewFallbackLimiter(
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				<-release
+				return false, nil
+			},
+		},
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return true, nil
+			},
+		},
+		10*time.Millisecond,
+	)
+
+	start := time.Now()
+	isAllowed, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+
+	if err != nil || !isAllowed {
+		t.Errorf("expected fallback to allow the request; got %v, %v", isAllowed, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected decision within the latency budget; got %v", elapsed)
+	}
+}
+
+// Test a done request context is reported instead of consulting the fallback
+func TestFallbackLimiterContextDone(t *testing.T) {
+	limiter := NewFallbackLimiter(
+		NewFixedWindowLimiter(1, time.Minute),
+		&MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return true, nil
+			},
+		},
+		time.Second,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := limiter.IsAllowedContext(ctx, newRequestFrom("192.0.2.1:1234")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled; got %v", err)
+	}
+}
+
+// Test rate limit data comes from the primary, or from the fallback if the primary has none
+func TestFallbackLimiterGetRateLimitData(t *testing.T) {
+	primary := &MockAdvancedRateLimiter{
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{}
+		},
+	}
+	limiter := NewFallbackLimiter(primary, NewFixedWindowLimiter(5, time.Minute), 0)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if data := limiter.GetRateLimitData(req); data.Limit != 5 {
+		t.Errorf("expected fallback data; got %+v", data)
+	}
+
+	primary.GetRateLimitDataFunc = func(r *http.Request) RateLimitData {
+		return RateLimitData{Limit: 100, Remaining: 99}
+	}
+	if data := limiter.GetRateLimitData(req); data.Limit != 100 {
+		t.Errorf("expected primary data; got %+v", data)
+	}
+}
@@ -0,0 +1,116 @@
+// Package fasthttpadapter applies rate limiting with cerberus rate limiters to fasthttp servers,
+// which cannot use net/http middlewares.
+//
+// The adapters run the same middleware as [cerberus.New], so every [cerberus.Option] is
+// supported, including custom denied and error handlers written against net/http. Each request
+// is converted to an [http.Request] for the rate limiter, and the rate limit headers and any
+// response written by the middleware are converted back to the fasthttp response:
+//
+//	limiter := cerberus.NewTokenBucketLimiter(100, 10)
+//	fasthttp.ListenAndServe(":8080", fasthttpadapter.New(limiter)(myRequestHandler))
+package fasthttpadapter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// New returns a middleware constructor that applies rate limiting with the provided
+// [cerberus.RateLimiter] to the request handlers it wraps. It is the fasthttp equivalent of
+// [cerberus.New].
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, it is forwarded to the next request handler,
+//     with any rate limit headers already set on the response.
+//   - If the request exceeds the rate limit or the rate limiter encounters an error, the response
+//     written by the denied or error handler is sent instead.
+//
+// Example usage: fasthttp.ListenAndServe(":8080", New(myRateLimiter, cerberus.WithHeaderStyle(cerberus.HeaderStyleIETF))(myRequestHandler))
+func New(rateLimiter cerberus.RateLimiter, opts ...cerberus.Option) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	middleware := cerberus.New(rateLimiter, opts...)(http.HandlerFunc(markAllowed))
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			var r http.Request
+			if err := fasthttpadaptor.ConvertRequest(ctx, &r, true); err != nil {
+				ctx.Error(http.StatusText(http.StatusInternalServerError), fasthttp.StatusInternalServerError)
+				return
+			}
+			allowed := false
+			w := &responseWriter{ctx: ctx, header: make(http.Header)}
+			middleware.ServeHTTP(w, r.WithContext(context.WithValue(ctx, allowedKey{}, &allowed)))
+			w.writeHeader()
+			if allowed {
+				next(ctx)
+			}
+		}
+	}
+}
+
+// Middleware is the fasthttp equivalent of [cerberus.Middleware]: it applies rate limiting with
+// rate limit headers turned off.
+//
+// Example usage: fasthttp.ListenAndServe(":8080", Middleware(myRateLimiter, myRequestHandler))
+func Middleware(rateLimiter cerberus.RateLimiter, next fasthttp.RequestHandler, opts ...cerberus.Option) fasthttp.RequestHandler {
+	return New(rateLimiter, append([]cerberus.Option{cerberus.WithHeaders(false)}, opts...)...)(next)
+}
+
+// AdvancedMiddleware is the fasthttp equivalent of [cerberus.AdvancedMiddleware]: it applies rate
+// limiting and adds rate limit headers to responses.
+//
+// Example usage: fasthttp.ListenAndServe(":8080", AdvancedMiddleware(myAdvancedRateLimiter, myRequestHandler))
+func AdvancedMiddleware(rateLimiter cerberus.AdvancedRateLimiter, next fasthttp.RequestHandler, opts ...cerberus.Option) fasthttp.RequestHandler {
+	return New(rateLimiter, opts...)(next)
+}
+
+// allowedKey is the context key of the flag recording whether the middleware forwarded the
+// request.
+type allowedKey struct{}
+
+// markAllowed is the handler wrapped by the middleware. It records that the request was
+// forwarded to it.
+func markAllowed(_ http.ResponseWriter, r *http.Request) {
+	*r.Context().Value(allowedKey{}).(*bool) = true
+}
+
+// responseWriter is an [http.ResponseWriter] writing to the response of a fasthttp request.
+type responseWriter struct {
+	ctx         *fasthttp.RequestCtx
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader copies the headers to the fasthttp response and sets its status code.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.writeHeader()
+	w.ctx.SetStatusCode(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.ctx.Write(b)
+}
+
+// writeHeader copies the headers to the fasthttp response if they have not been written yet,
+// leaving its status code unchanged.
+func (w *responseWriter) writeHeader() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	for name, values := range w.header {
+		for _, value := range values {
+			w.ctx.Response.Header.Add(name, value)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fasthttpadapter
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/valyala/fasthttp"
+)
+
+type stubLimiter struct {
+	isAllowed bool
+	err       error
+}
+
+func (l *stubLimiter) IsAllowed(*http.Request) (bool, error) {
+	return l.isAllowed, l.err
+}
+
+// newRequestCtx returns the context of a GET request to /api from 192.0.2.1.
+func newRequestCtx() *fasthttp.RequestCtx {
+	var ctx fasthttp.RequestCtx
+	var req fasthttp.Request
+	req.SetRequestURI("http://example.com/api")
+	ctx.Init(&req, &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234}, nil)
+	return &ctx
+}
+
+func okHandler(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.WriteString("ok")
+}
+
+// Test allowed requests reach the handler with rate limit headers
+func TestAdvancedMiddlewareAllowed(t *testing.T) {
+	handler := AdvancedMiddleware(cerberus.NewFixedWindowLimiter(10, time.Minute), okHandler)
+	ctx := newRequestCtx()
+
+	handler(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != "ok" {
+		t.Errorf("expected handler response; got %v %q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if limit := string(ctx.Response.Header.Peek("X-RateLimit-Limit")); limit != "10" {
+		t.Errorf("expected X-RateLimit-Limit to be 10; got %v", limit)
+	}
+	if remaining := string(ctx.Response.Header.Peek("X-RateLimit-Remaining")); remaining != "9" {
+		t.Errorf("expected X-RateLimit-Remaining to be 9; got %v", remaining)
+	}
+}
+
+// Test denied requests get a 429 with the retry header
+func TestAdvancedMiddlewareDenied(t *testing.T) {
+	handler := AdvancedMiddleware(cerberus.NewFixedWindowLimiter(1, time.Minute), okHandler, cerberus.WithHeaderStyle(cerberus.HeaderStyleIETF))
+	handler(newRequestCtx())
+	ctx := newRequestCtx()
+
+	handler(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", ctx.Response.StatusCode())
+	}
+	if len(ctx.Response.Body()) != 0 {
+		t.Errorf("expected empty body; got %q", ctx.Response.Body())
+	}
+	if retryAfter := string(ctx.Response.Header.Peek("Retry-After")); retryAfter == "" {
+		t.Errorf("expected Retry-After header")
+	}
+}
+
+// Test Middleware omits headers and honors the error handler
+func TestMiddlewareError(t *testing.T) {
+	handler := Middleware(&stubLimiter{err: errors.New("store unavailable")}, okHandler,
+		cerberus.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}))
+	ctx := newRequestCtx()
+
+	handler(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Errorf("expected status 503; got %v", ctx.Response.StatusCode())
+	}
+	if body := string(ctx.Response.Body()); body != "store unavailable\n" {
+		t.Errorf("expected error body; got %q", body)
+	}
+}
+
+// Benchmark serving allowed requests through the middleware
+func BenchmarkAdvancedMiddleware(b *testing.B) {
+	handler := AdvancedMiddleware(cerberus.NewGCRALimiter(time.Nanosecond, time.Hour), okHandler)
+	ctx := newRequestCtx()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler(ctx)
+		ctx.Response.Reset()
+	}
+}
@@ -0,0 +1,447 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fixedWindowPrefix prefixes the store keys of the counters and blocks of a [FixedWindowLimiter].
+const fixedWindowPrefix = "fixed_window:"
+
+// FixedWindowLimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the fixed
+// window counter algorithm. Time is divided into consecutive windows of equal length, and each
+// client, identified by its key (its IP address by default), may make up to limit requests per
+// window. Counters are reset when a new window begins.
+//
+// Each request is a single atomic increment of a counter in the limiter's [Store], and counters
+// expire at the end of their window. This makes FixedWindowLimiter the cheapest of the built-in
+// limiters, at the cost of allowing up to twice the limit in bursts that straddle a window
+// boundary.
+//
+// A FixedWindowLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", Middleware(NewFixedWindowLimiter(100, time.Minute), myHandler))
+type FixedWindowLimiter struct {
+	limit  int64
+	period Period
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+}
+
+// NewFixedWindowLimiter creates a new [FixedWindowLimiter] that allows up to limit requests per
+// client within each window. Windows are aligned to the zero time, so for example one minute
+// windows always start at the beginning of a minute.
+//
+// It panics if limit or window is not positive.
+func NewFixedWindowLimiter(limit int, window time.Duration, opts ...LimiterOption) *FixedWindowLimiter {
+	if limit <= 0 {
+		panic("cerberus: fixed window limit must be positive")
+	}
+	if window <= 0 {
+		panic("cerberus: fixed window duration must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &FixedWindowLimiter{
+		limit:     int64(limit),
+		period:    truncatingPeriod(window),
+		keyFunc:   options.keyFunc,
+		store:     options.store,
+		costFunc:  options.costFunc,
+		policy:    options.policy,
+		now:       options.clock.Now,
+		clock:     options.clock,
+		topDenied: options.topDenied,
+	}
+}
+
+// IsAllowed adds the cost of the request to the counter of the client making it for the current
+// window. It returns true if the counter did not exceed the limit, false otherwise. An error is
+// returned if no key can be derived from the request or the store fails.
+func (l *FixedWindowLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *FixedWindowLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	isAllowed, err := l.increment(ctx, key, int64(requestCost(l.costFunc, r)), l.limit)
+	if !isAllowed && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *FixedWindowLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	return l.peek(ctx, key, int64(requestCost(l.costFunc, r)), l.limit)
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *FixedWindowLimiter) Commit(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	_, err = l.increment(ctx, key, int64(requestCost(l.costFunc, r)), math.MaxInt64)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. An error is returned
+// if no key can be derived from the request or the store fails.
+func (l *FixedWindowLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	now := l.now()
+	start, end := l.period(now)
+	return refundWindowCounter(ctx, l.store, fixedWindowPrefix, key, start, int64(requestCost(l.costFunc, r)), end.Sub(now))
+}
+
+// GetRateLimitData returns the current state of the counter of the client making the request.
+// Remaining is the budget left in the current window, and RetryAfter is the time until the next
+// window begins if it does not cover the cost of the request. The zero RateLimitData is returned if
+// no key can be derived from the request or the store fails.
+func (l *FixedWindowLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, l.limit, int64(requestCost(l.costFunc, r)))
+	return data
+}
+
+// Usage returns the current state of the counter of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *FixedWindowLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, l.limit, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *FixedWindowLimiter) Reset(ctx context.Context, key string) error {
+	start, _ := l.period(l.now())
+	if err := l.store.Delete(ctx, windowCounterKey(fixedWindowPrefix, key, start)); err != nil {
+		return err
+	}
+	return l.store.Delete(ctx, windowBlockKey(fixedWindowPrefix, key))
+}
+
+// Block denies all requests of the client identified by key for d. Since counters do not outlive
+// their window, the block is kept in a separate entry of the store, which the limiter checks when
+// the counter of the client is over the limit or has just been created. An error is returned if
+// the store fails.
+func (l *FixedWindowLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	now := l.now()
+	start, end := l.period(now)
+	return blockWindowClient(ctx, l.store, fixedWindowPrefix, key, d, start, end.Sub(now))
+}
+
+// Keys returns the keys of the clients with a counter in the current window, in no particular
+// order. An error wrapping [errors.ErrUnsupported] is returned if the store does not implement
+// [KeyScanner].
+func (l *FixedWindowLimiter) Keys(ctx context.Context) ([]string, error) {
+	start, _ := l.period(l.now())
+	return windowKeys(ctx, l.store, fixedWindowPrefix, start)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *FixedWindowLimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *FixedWindowLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// increment adds cost to the counter of key for the current window, and reports whether the
+// counter stayed within limit. Denied costs greater than one are taken back off the counter, so
+// that they do not use up the budget left for cheaper requests.
+func (l *FixedWindowLimiter) increment(ctx context.Context, key string, cost, limit int64) (bool, error) {
+	if cost == 0 {
+		blocked, err := windowBlockedFor(ctx, l.store, fixedWindowPrefix, key)
+		return blocked == 0 && err == nil, err
+	}
+	now := l.now()
+	start, end := l.period(now)
+	buf := keyBufferPool.Get().(*[]byte)
+	defer keyBufferPool.Put(buf)
+	*buf = appendWindowCounterKey((*buf)[:0], fixedWindowPrefix, key, start)
+	ttl := end.Sub(now)
+	count, err := incrementByteKey(ctx, l.store, *buf, cost, ttl)
+	if err != nil {
+		return false, err
+	}
+	if count == cost || count > limit || count >= windowBlockMark {
+		blocked, unmarked, err := settleWindowBlock(ctx, l.store, string(*buf), fixedWindowPrefix, key, cost, ttl)
+		if err != nil || blocked > 0 {
+			return false, err
+		}
+		count = unmarked
+	}
+	if count <= limit {
+		return true, nil
+	}
+	if cost > 1 {
+		if _, err := incrementByteKey(ctx, l.store, *buf, -cost, ttl); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// count returns the counter of key for the current window, and the time until the next window
+// begins. The counter may carry windowBlockMark.
+func (l *FixedWindowLimiter) count(ctx context.Context, key string) (int64, time.Duration, error) {
+	now := l.now()
+	start, end := l.period(now)
+	buf := keyBufferPool.Get().(*[]byte)
+	defer keyBufferPool.Put(buf)
+	*buf = appendWindowCounterKey((*buf)[:0], fixedWindowPrefix, key, start)
+	value, err := getByteKey(ctx, l.store, *buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := parseWindowCounter(value, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, end.Sub(now), nil
+}
+
+// peek reports whether a request of the given cost by the client identified by key would keep its
+// counter for the current window within limit.
+func (l *FixedWindowLimiter) peek(ctx context.Context, key string, cost, limit int64) (bool, error) {
+	count, _, err := l.count(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if windowMayBeBlocked(count) {
+		if blocked, err := windowBlockedFor(ctx, l.store, fixedWindowPrefix, key); err != nil || blocked > 0 {
+			return false, err
+		}
+	}
+	return count&^windowBlockMark+cost <= limit, nil
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost, measured against limit.
+func (l *FixedWindowLimiter) rateLimitData(ctx context.Context, key string, limit, cost int64) (RateLimitData, error) {
+	count, reset, err := l.count(ctx, key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	data := windowRateLimitData(limit, count&^windowBlockMark, cost, reset)
+	start, end := l.period(l.now())
+	data.Window = end.Sub(start)
+	data.Policy = l.policy
+	data.Rate = float64(limit) / data.Window.Seconds()
+	data.Burst = int(limit)
+	data.ResetAt = end
+	if !windowMayBeBlocked(count) {
+		return data, nil
+	}
+	blocked, err := windowBlockedFor(ctx, l.store, fixedWindowPrefix, key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	if blocked > 0 {
+		data.Remaining = 0
+		if cost <= limit {
+			data.RetryAfter = blocked
+		}
+		data.ResetAt = laterOf(data.ResetAt, l.now().Add(blocked))
+	}
+	return data, nil
+}
+
+// windowRateLimitData returns the rate limit data of a window counter at count out of limit for a
+// request of the given cost, with reset until the next window begins.
+func windowRateLimitData(limit, count, cost int64, reset time.Duration) RateLimitData {
+	data := RateLimitData{
+		Limit:     int(limit),
+		Remaining: int(max(limit-count, 0)),
+	}
+	if int64(data.Remaining) < cost && cost <= limit {
+		data.RetryAfter = reset
+	}
+	return data
+}
+
+// windowKeys returns the keys of the clients with a counter under prefix for any of the windows
+// starting at starts, in no particular order. An error wrapping [errors.ErrUnsupported] is returned
+// if the store does not implement [KeyScanner].
+func windowKeys(ctx context.Context, store Store, prefix string, starts ...time.Time) ([]string, error) {
+	clientKeys, err := scanClientKeys(ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	suffixes := make([]string, len(starts))
+	for i, start := range starts {
+		suffixes[i] = windowCounterKey("", "", start)
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, clientKey := range clientKeys {
+		for _, suffix := range suffixes {
+			if key, ok := strings.CutSuffix(clientKey, suffix); ok && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// refundWindowCounter takes cost back off the counter of key under prefix for the window starting
+// at start, keeping the counter for ttl and any windowBlockMark it carries. Nothing is stored if
+// there is no counter.
+func refundWindowCounter(ctx context.Context, store Store, prefix, key string, start time.Time, cost int64, ttl time.Duration) error {
+	return modify(ctx, store, windowCounterKey(prefix, key, start), func(value []byte) ([]byte, time.Duration, error) {
+		if value == nil {
+			return nil, 0, nil
+		}
+		count, err := parseWindowCounter(value, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		count = count&windowBlockMark | max(count&^windowBlockMark-cost, 0)
+		return []byte(strconv.FormatInt(count, 10)), ttl, nil
+	})
+}
+
+// windowBlockMark is added to the counter of a blocked client for the current window, so that the
+// counter exceeds any limit. The window limiters then only need to check for a block when a counter
+// is over the limit, or has just been created in a new window, rather than on every request.
+const windowBlockMark = 1 << 62
+
+// windowMayBeBlocked reports whether a client with the given counter for the current window may be
+// blocked, that is whether the counter carries windowBlockMark or does not exist yet.
+func windowMayBeBlocked(count int64) bool {
+	return count == 0 || count >= windowBlockMark
+}
+
+// blockWindowClient blocks the client identified by key under prefix for d. Since counters do not
+// outlive their window, the block is kept in a separate entry of the store, and the counter of the
+// client for the window starting at start is marked with windowBlockMark, keeping it for ttl.
+func blockWindowClient(ctx context.Context, store Store, prefix, key string, d time.Duration, start time.Time, ttl time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if err := store.Set(ctx, windowBlockKey(prefix, key), []byte{1}, d); err != nil {
+		return err
+	}
+	return modify(ctx, store, windowCounterKey(prefix, key, start), func(value []byte) ([]byte, time.Duration, error) {
+		count, err := parseWindowCounter(value, key)
+		if err != nil || count >= windowBlockMark {
+			return nil, 0, err
+		}
+		return []byte(strconv.FormatInt(count+windowBlockMark, 10)), ttl, nil
+	})
+}
+
+// settleWindowBlock checks for a block of the client identified by key under prefix, after cost was
+// added to its counter stored under storeKey. The counter of a blocked client gets cost taken back off and is
+// marked with windowBlockMark, and the mark is removed once the block is lifted, keeping the counter
+// for ttl. It returns how long the client remains blocked, and the counter without the mark.
+func settleWindowBlock(ctx context.Context, store Store, storeKey, prefix, key string, cost int64, ttl time.Duration) (time.Duration, int64, error) {
+	blocked, err := windowBlockedFor(ctx, store, prefix, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	var count int64
+	err = modify(ctx, store, storeKey, func(value []byte) ([]byte, time.Duration, error) {
+		if count, err = parseWindowCounter(value, key); err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case blocked > 0:
+			if count = max(count-cost, 0); count < windowBlockMark {
+				count += windowBlockMark
+			}
+		case count >= windowBlockMark:
+			count -= windowBlockMark
+		default:
+			return nil, 0, nil
+		}
+		return []byte(strconv.FormatInt(count, 10)), ttl, nil
+	})
+	return blocked, count &^ windowBlockMark, err
+}
+
+// parseWindowCounter parses the counter of key stored as value, or returns zero if there is none.
+func parseWindowCounter(value []byte, key string) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	count, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a counter", ErrMalformedValue, key)
+	}
+	return count, nil
+}
+
+// windowBlockedFor returns how long the client identified by key under prefix remains blocked, or
+// zero if it is not blocked.
+func windowBlockedFor(ctx context.Context, store Store, prefix, key string) (time.Duration, error) {
+	buf := keyBufferPool.Get().(*[]byte)
+	defer keyBufferPool.Put(buf)
+	*buf = appendWindowBlockKey((*buf)[:0], prefix, key)
+	return ttlByteKey(ctx, store, *buf)
+}
+
+// windowCounterKey returns the store key of the counter of key under prefix for the window
+// starting at start.
+func windowCounterKey(prefix, key string, start time.Time) string {
+	return string(appendWindowCounterKey(nil, prefix, key, start))
+}
+
+// appendWindowCounterKey appends the store key of the counter of key under prefix for the window
+// starting at start to dst, and returns the extended buffer.
+func appendWindowCounterKey(dst []byte, prefix, key string, start time.Time) []byte {
+	dst = append(append(append(dst, prefix...), key...), ':')
+	return strconv.AppendInt(dst, start.UnixNano(), 10)
+}
+
+// windowBlockKey returns the store key of the block of key under prefix.
+func windowBlockKey(prefix, key string) string {
+	return string(appendWindowBlockKey(nil, prefix, key))
+}
+
+// appendWindowBlockKey appends the store key of the block of key under prefix to dst, and returns
+// the extended buffer.
+func appendWindowBlockKey(dst []byte, prefix, key string) []byte {
+	return append(append(append(dst, prefix...), key...), ":blocked"...)
+}
+
+// key returns the key identifying the client making the request.
+func (l *FixedWindowLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *FixedWindowLimiter) timeSource() Clock {
+	return l.clock
+}
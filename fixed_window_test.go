@@ -0,0 +1,174 @@
+package cerberus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test the limiter allows up to the limit within a window
+func TestFixedWindowLimiterAllowsUpToLimit(t *testing.T) {
+	limiter := NewFixedWindowLimiter(2, time.Minute)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding limit to be denied")
+	}
+}
+
+// Test the counter resets when a new window begins
+func TestFixedWindowLimiterResetsAtWindowBoundary(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	data := limiter.GetRateLimitData(req)
+	if data.Remaining != 0 || data.RetryAfter != 40*time.Second {
+		t.Errorf("expected {1 0 40s}; got %+v", data)
+	}
+	if data.Rate != 1.0/60 || data.Burst != 1 {
+		t.Errorf("expected rate 1/60 and burst 1; got %+v", data)
+	}
+	clock.Advance(40 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request in a new window to be allowed")
+	}
+}
+
+// Test concurrent requests never exceed the limit
+func TestFixedWindowLimiterConcurrentRequests(t *testing.T) {
+	limiter := NewFixedWindowLimiter(50, time.Hour)
+	req := newRequestFrom("192.0.2.1:1234")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed > 50 {
+		t.Errorf("expected at most 50 allowed requests; got %d", allowed)
+	}
+}
+
+// Test keys and usage are reported for the current window only
+func TestFixedWindowLimiterKeysAndUsage(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewFixedWindowLimiter(3, time.Minute)
+	limiter.now = clock.Now
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	clock.Advance(40 * time.Second)
+	limiter.IsAllowed(newRequestFrom("192.0.2.2:1234"))
+	limiter.IsAllowed(newRequestFrom("192.0.2.2:1234"))
+
+	keys, err := limiter.Keys(context.Background())
+	if err != nil || len(keys) != 1 || keys[0] != "192.0.2.2" {
+		t.Errorf("expected [192.0.2.2]; got %v, %v", keys, err)
+	}
+	data, err := limiter.Usage(context.Background(), "192.0.2.2")
+	if err != nil || data.Limit != 3 || data.Remaining != 1 {
+		t.Errorf("expected {3 1 0s}; got %+v, %v", data, err)
+	}
+}
+
+// Test window limiters of different kinds sharing a store do not count against each other
+func TestFixedWindowLimiterSharedStore(t *testing.T) {
+	store := NewMemoryStore()
+	fixed := NewFixedWindowLimiter(5, time.Minute, WithStore(store))
+	counter := NewSlidingWindowCounterLimiter(5, time.Minute, WithStore(store))
+	for range 5 {
+		counter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	}
+	counter.IsAllowed(newRequestFrom("192.0.2.2:1234"))
+
+	if isAllowed, err := fixed.IsAllowed(newRequestFrom("192.0.2.1:1234")); !isAllowed || err != nil {
+		t.Errorf("expected request to be allowed by the fixed window limiter; got %v, %v", isAllowed, err)
+	}
+	keys, err := fixed.Keys(context.Background())
+	if err != nil || len(keys) != 1 || keys[0] != "192.0.2.1" {
+		t.Errorf("expected [192.0.2.1]; got %v, %v", keys, err)
+	}
+}
+
+// Test the budget is reported to reset at the end of the window, or of a longer block
+func TestFixedWindowLimiterResetAt(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewFixedWindowLimiter(3, time.Minute)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+
+	if data := limiter.GetRateLimitData(req); !data.ResetAt.Equal(clock.Now().Add(40 * time.Second)) {
+		t.Errorf("expected the budget to reset in 40s; got %v", data.ResetAt)
+	}
+	limiter.Block(context.Background(), "192.0.2.1", time.Hour)
+	if data := limiter.GetRateLimitData(req); data.ResetAt.Before(clock.Now().Add(59 * time.Minute)) {
+		t.Errorf("expected the budget to reset at the end of the block; got %v", data.ResetAt)
+	}
+}
+
+// ttlCountingStore is a Store counting the calls to TTL.
+type ttlCountingStore struct {
+	Store
+	ttls int
+}
+
+func (s *ttlCountingStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.ttls++
+	return s.Store.TTL(ctx, key)
+}
+
+// Test blocks are checked only for new or exceeded counters, and lifted in the middle of a window
+func TestFixedWindowLimiterBlockChecks(t *testing.T) {
+	clock := newFakeClock()
+	memoryStore := NewMemoryStore(WithCleanupInterval(0))
+	memoryStore.now = clock.Now
+	store := &ttlCountingStore{Store: memoryStore}
+	limiter := NewFixedWindowLimiter(5, time.Minute, WithStore(store))
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	limiter.IsAllowed(req)
+	if store.ttls != 1 {
+		t.Errorf("expected the block to be checked once for a new counter; got %d checks", store.ttls)
+	}
+	limiter.Block(context.Background(), "192.0.2.1", 10*time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected blocked client to be denied")
+	}
+	clock.Advance(11 * time.Second)
+	if isAllowed, err := limiter.IsAllowed(req); !isAllowed || err != nil {
+		t.Errorf("expected client to be allowed once the block ends; got %v, %v", isAllowed, err)
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 2 {
+		t.Errorf("expected 2 remaining requests, as before the block; got %+v", data)
+	}
+}
+
+// Benchmark a client making requests at the rate of the limit
+func BenchmarkFixedWindowLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewFixedWindowLimiter(100, time.Second)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
@@ -0,0 +1,300 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// gcraPrefix prefixes the store keys of the theoretical arrival times of a [GCRALimiter].
+const gcraPrefix = "gcra:"
+
+// GCRALimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the generic cell
+// rate algorithm (GCRA). Instead of counting requests, it keeps a single timestamp per client,
+// identified by its key (its IP address by default): the theoretical arrival time (TAT) of the
+// client's next request if requests arrived exactly once per emission interval.
+//
+// A request is allowed if it does not arrive earlier than its theoretical arrival time minus the
+// burst tolerance. Each allowed request pushes the theoretical arrival time forward by one emission
+// interval. As a result, clients are limited to one request per emission interval on average, and
+// may send up to burstTolerance/emissionInterval + 1 requests back to back.
+//
+// GCRA is as precise as a token bucket while storing only a single timestamp per client. Timestamps
+// are kept in the limiter's [Store] and expire once they lie in the past.
+//
+// A GCRALimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", AdvancedMiddleware(NewGCRALimiter(100*time.Millisecond, time.Second), myHandler))
+type GCRALimiter struct {
+	emissionInterval time.Duration
+	burstTolerance   time.Duration
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+}
+
+// NewGCRALimiter creates a new [GCRALimiter] that allows one request per emissionInterval on
+// average, tolerating requests that arrive up to burstTolerance ahead of schedule.
+//
+// It panics if emissionInterval is not positive or burstTolerance is negative.
+func NewGCRALimiter(emissionInterval, burstTolerance time.Duration, opts ...LimiterOption) *GCRALimiter {
+	if emissionInterval <= 0 {
+		panic("cerberus: GCRA emission interval must be positive")
+	}
+	if burstTolerance < 0 {
+		panic("cerberus: GCRA burst tolerance must not be negative")
+	}
+	options := newLimiterOptions(opts)
+	return &GCRALimiter{
+		emissionInterval: emissionInterval,
+		burstTolerance:   burstTolerance,
+		keyFunc:          options.keyFunc,
+		store:            options.store,
+		costFunc:         options.costFunc,
+		policy:           options.policy,
+		now:              options.clock.Now,
+		clock:            options.clock,
+		topDenied:        options.topDenied,
+	}
+}
+
+// NewGCRALimiterFromRate creates a new [GCRALimiter] that allows rate requests per second on
+// average, and up to burst requests back to back, like a token bucket of capacity burst refilled at
+// rate tokens per second.
+//
+// It panics if rate or burst is not positive.
+func NewGCRALimiterFromRate(rate float64, burst int, opts ...LimiterOption) *GCRALimiter {
+	if !(rate > 0) {
+		panic("cerberus: GCRA rate must be positive")
+	}
+	if burst <= 0 {
+		panic("cerberus: GCRA burst must be positive")
+	}
+	emissionInterval := time.Duration(float64(time.Second) / rate)
+	return NewGCRALimiter(emissionInterval, time.Duration(burst-1)*emissionInterval, opts...)
+}
+
+// IsAllowed checks the request against the theoretical arrival time of the client making it, and
+// advances the theoretical arrival time by one emission interval per unit of the request's cost if
+// the request is allowed. It returns true if the request conforms to the configured rate, false
+// otherwise. An error is returned if no key can be derived from the request or the store fails.
+func (l *GCRALimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *GCRALimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.take(ctx, r, false)
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *GCRALimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	value, err := l.store.Get(ctx, gcraPrefix+key)
+	if err != nil {
+		return false, err
+	}
+	now := l.now()
+	tat, err := l.tat(key, value, now)
+	if err != nil {
+		return false, err
+	}
+	return l.conforms(tat, now, time.Duration(requestCost(l.costFunc, r))), nil
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *GCRALimiter) Commit(ctx context.Context, r *http.Request) error {
+	_, err := l.take(ctx, r, true)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. A theoretical arrival
+// time moved back to the present by the refund is deleted, since it is the state of a new client.
+// An error is returned if no key can be derived from the request or the store fails.
+func (l *GCRALimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	cost := time.Duration(requestCost(l.costFunc, r))
+	return modifyOrDelete(ctx, l.store, gcraPrefix+key, func(value []byte) ([]byte, time.Duration, bool, error) {
+		if value == nil {
+			return nil, 0, false, nil
+		}
+		now := l.now()
+		tat, err := l.tat(key, value, now)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		tat = tat.Add(-cost * l.emissionInterval)
+		return encodeUint64s(uint64(tat.UnixNano())), tat.Sub(now), !tat.After(now), nil
+	})
+}
+
+// take counts the request if it is allowed, or regardless if force is true, and reports whether
+// it was allowed.
+func (l *GCRALimiter) take(ctx context.Context, r *http.Request, force bool) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	cost := time.Duration(requestCost(l.costFunc, r))
+	if cost == 0 {
+		return true, nil
+	}
+	var isAllowed bool
+	err = modify(ctx, l.store, gcraPrefix+key, func(value []byte) ([]byte, time.Duration, error) {
+		now := l.now()
+		tat, err := l.tat(key, value, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if isAllowed = l.conforms(tat, now, cost); !isAllowed && !force {
+			return nil, 0, nil
+		}
+		tat = tat.Add(cost * l.emissionInterval)
+		return encodeUint64s(uint64(tat.UnixNano())), tat.Sub(now), nil
+	})
+	if !isAllowed && !force && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// GetRateLimitData derives the current rate limit state of the client making the request from its
+// theoretical arrival time. Limit is the maximum burst size, Remaining is the number of units of
+// cost the client could spend right now, and RetryAfter is the time until the request would conform
+// if it does not. The zero RateLimitData is returned if no key can be derived from the request or
+// the store fails.
+func (l *GCRALimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, requestCost(l.costFunc, r))
+	return data
+}
+
+// Rate returns the sustained rate of the limiter, in requests per second.
+func (l *GCRALimiter) Rate() float64 {
+	return float64(time.Second) / float64(l.emissionInterval)
+}
+
+// Burst returns the burst of the limiter, the number of requests a client may make back to back.
+func (l *GCRALimiter) Burst() int {
+	return int(l.burstTolerance/l.emissionInterval) + 1
+}
+
+// Usage returns the current state of the limit of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *GCRALimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *GCRALimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, gcraPrefix+key)
+}
+
+// Block pushes the theoretical arrival time of the client identified by key so far ahead that its
+// requests are denied for d. An error is returned if the store fails.
+func (l *GCRALimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	now := l.now()
+	tat := now.Add(d + l.burstTolerance)
+	return l.store.Set(ctx, gcraPrefix+key, encodeUint64s(uint64(tat.UnixNano())), tat.Sub(now))
+}
+
+// Keys returns the keys of the clients the limiter is tracking, in no particular order. Clients
+// whose state has expired are back to a full budget and are not listed. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (l *GCRALimiter) Keys(ctx context.Context) ([]string, error) {
+	return scanClientKeys(ctx, l.store, gcraPrefix)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *GCRALimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *GCRALimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost.
+func (l *GCRALimiter) rateLimitData(ctx context.Context, key string, cost int) (RateLimitData, error) {
+	value, err := l.store.Get(ctx, gcraPrefix+key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	now := l.now()
+	tat, err := l.tat(key, value, now)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	ahead := tat.Sub(now)
+	data := RateLimitData{
+		Limit:   l.Burst(),
+		Policy:  l.policy,
+		Rate:    l.Rate(),
+		Burst:   l.Burst(),
+		ResetAt: tat,
+	}
+	data.Window = time.Duration(data.Limit) * l.emissionInterval
+	if ahead <= l.burstTolerance {
+		data.Remaining = int((l.burstTolerance-ahead)/l.emissionInterval) + 1
+	}
+	if late := ahead + time.Duration(cost-1)*l.emissionInterval - l.burstTolerance; late > 0 && cost <= data.Limit {
+		data.RetryAfter = late
+	}
+	return data, nil
+}
+
+// conforms reports whether a request of the given cost arriving at now conforms, given the
+// theoretical arrival time tat.
+func (l *GCRALimiter) conforms(tat, now time.Time, cost time.Duration) bool {
+	return cost == 0 || tat.Add((cost-1)*l.emissionInterval).Sub(now) <= l.burstTolerance
+}
+
+// tat decodes the theoretical arrival time stored under key. The result is never earlier than
+// now; a nil value yields now.
+func (l *GCRALimiter) tat(key string, value []byte, now time.Time) (time.Time, error) {
+	if value == nil {
+		return now, nil
+	}
+	fields, ok := decodeUint64s(value)
+	if !ok || len(fields) != 1 {
+		return time.Time{}, fmt.Errorf("%w: %q is not a GCRA timestamp", ErrMalformedValue, key)
+	}
+	if tat := time.Unix(0, int64(fields[0])); tat.After(now) {
+		return tat, nil
+	}
+	return now, nil
+}
+
+// key returns the key identifying the client making the request.
+func (l *GCRALimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *GCRALimiter) timeSource() Clock {
+	return l.clock
+}
@@ -0,0 +1,93 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the limiter allows a burst within the burst tolerance
+func TestGCRALimiterAllowsBurst(t *testing.T) {
+	limiter := NewGCRALimiter(100*time.Millisecond, 200*time.Millisecond)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 3; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding burst to be denied")
+	}
+}
+
+// Test the limiter allows requests at the emission interval
+func TestGCRALimiterAllowsSustainedRate(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewGCRALimiter(100*time.Millisecond, 0)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 5; i++ {
+		if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+		clock.Advance(100 * time.Millisecond)
+	}
+}
+
+// Test rate limit data is derived from the theoretical arrival time
+func TestGCRALimiterGetRateLimitData(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewGCRALimiter(100*time.Millisecond, 200*time.Millisecond)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	data := limiter.GetRateLimitData(req)
+	if data.Limit != 3 || data.Remaining != 3 || data.RetryAfter != 0 {
+		t.Errorf("expected {3 3 0}; got %+v", data)
+	}
+	limiter.IsAllowed(req)
+	limiter.IsAllowed(req)
+	limiter.IsAllowed(req)
+	data = limiter.GetRateLimitData(req)
+	if data.Remaining != 0 || data.RetryAfter != 100*time.Millisecond {
+		t.Errorf("expected {3 0 100ms}; got %+v", data)
+	}
+	clock.Advance(30 * time.Millisecond)
+	data = limiter.GetRateLimitData(req)
+	if data.RetryAfter != 70*time.Millisecond {
+		t.Errorf("expected RetryAfter to be 70ms; got %v", data.RetryAfter)
+	}
+}
+
+// Test a limiter created from a rate and a burst reports them
+func TestNewGCRALimiterFromRate(t *testing.T) {
+	limiter := NewGCRALimiterFromRate(10, 3)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if rate, burst := limiter.Rate(), limiter.Burst(); rate != 10 || burst != 3 {
+		t.Errorf("expected rate 10 and burst 3; got %v and %v", rate, burst)
+	}
+	allowed := 0
+	for range 5 {
+		if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 requests to be allowed; got %v", allowed)
+	}
+	if data := limiter.GetRateLimitData(req); data.Rate != 10 || data.Burst != 3 {
+		t.Errorf("expected rate 10 and burst 3; got %+v", data)
+	}
+}
+
+// Benchmark a client making requests at the emission rate
+func BenchmarkGCRALimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewGCRALimiter(10*time.Millisecond, time.Second)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
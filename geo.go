@@ -0,0 +1,144 @@
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GeoInfo describes where an IP address is located and which network it belongs to, as resolved
+// by a [GeoProvider]. Fields are left empty when they are unknown.
+type GeoInfo struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country of the address, such as "DE".
+	Country string
+
+	// ASN is the number of the autonomous system announcing the address, such as 13335.
+	ASN uint32
+
+	// Organization is the name of the organization operating the autonomous system.
+	Organization string
+}
+
+// GeoProvider resolves IP addresses to their country and autonomous system, typically from a
+// local database such as those of MaxMind or IPinfo. Lookups happen on every request, so they
+// should not perform network calls.
+type GeoProvider interface {
+	// Lookup returns the location and network of addr. It returns an error if the database cannot
+	// be read, and a GeoInfo with empty fields if addr is not in the database.
+	Lookup(addr netip.Addr) (GeoInfo, error)
+}
+
+// GeoResolver maps requests to the country and autonomous system of their client with a
+// [GeoProvider], so that clients can be limited per country or per network, and traffic from
+// networks that often front scrapers, such as hosting providers, can be limited more strictly or
+// denied.
+//
+// Example usage:
+//
+//	geo := NewGeoResolver(provider, nil)
+//	limiter := NewTokenBucketLimiter(1000, 100, WithKeyFunc(geo.KeyByCountry))
+//	http.Handle("/", New(limiter, WithDenylist(geo.MatchASNs(HostingASNs()...)))(myHandler))
+type GeoResolver struct {
+	provider GeoProvider
+	clientIP *ClientIPResolver
+}
+
+// NewGeoResolver creates a new [GeoResolver] resolving the clients of requests with provider. The
+// IP address of a client is resolved with clientIP, or is the one of its connection if clientIP is
+// nil.
+func NewGeoResolver(provider GeoProvider, clientIP *ClientIPResolver) *GeoResolver {
+	if clientIP == nil {
+		clientIP = connectionIPResolver
+	}
+	return &GeoResolver{provider: provider, clientIP: clientIP}
+}
+
+// Lookup returns the location and network of the client making r. It returns an error if the IP
+// address of the client is invalid or the provider fails.
+func (g *GeoResolver) Lookup(r *http.Request) (GeoInfo, error) {
+	ip := g.clientIP.ClientIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return GeoInfo{}, fmt.Errorf("%w: invalid client IP %q", ErrNoKey, ip)
+	}
+	return g.provider.Lookup(addr.Unmap())
+}
+
+// KeyByCountry is a [KeyFunc] that keys requests by the country of their client, such as "DE",
+// so that all the clients of a country share a single limit. Requests from addresses of unknown
+// country are rejected with an error wrapping [ErrNoKey].
+func (g *GeoResolver) KeyByCountry(r *http.Request) (string, error) {
+	info, err := g.Lookup(r)
+	if err != nil {
+		return "", err
+	}
+	if info.Country == "" {
+		return "", fmt.Errorf("%w: unknown country", ErrNoKey)
+	}
+	return info.Country, nil
+}
+
+// KeyByASN is a [KeyFunc] that keys requests by the autonomous system of their client, such as
+// "AS13335", so that all the clients of a network share a single limit. Requests from addresses of
+// unknown network are rejected with an error wrapping [ErrNoKey].
+func (g *GeoResolver) KeyByASN(r *http.Request) (string, error) {
+	info, err := g.Lookup(r)
+	if err != nil {
+		return "", err
+	}
+	if info.ASN == 0 {
+		return "", fmt.Errorf("%w: unknown autonomous system", ErrNoKey)
+	}
+	return "AS" + strconv.FormatUint(uint64(info.ASN), 10), nil
+}
+
+// MatchCountries returns a [RequestMatcher] that matches requests from clients in the countries
+// with the given ISO 3166-1 alpha-2 codes, such as "DE", in any case. Requests whose client cannot
+// be resolved do not match.
+func (g *GeoResolver) MatchCountries(codes ...string) RequestMatcher {
+	codes = slices.Clone(codes)
+	for i, code := range codes {
+		codes[i] = strings.ToUpper(code)
+	}
+	return func(r *http.Request) bool {
+		info, err := g.Lookup(r)
+		return err == nil && info.Country != "" && slices.Contains(codes, info.Country)
+	}
+}
+
+// MatchASNs returns a [RequestMatcher] that matches requests from clients in the autonomous
+// systems with the given numbers. Requests whose client cannot be resolved do not match.
+func (g *GeoResolver) MatchASNs(asns ...uint32) RequestMatcher {
+	asns = slices.Clone(asns)
+	return func(r *http.Request) bool {
+		info, err := g.Lookup(r)
+		return err == nil && info.ASN != 0 && slices.Contains(asns, info.ASN)
+	}
+}
+
+// HostingASNs returns the numbers of the autonomous systems of large cloud and hosting providers,
+// such as AWS, Azure, DigitalOcean, Hetzner, and OVH, whose addresses front most scrapers and bots
+// but few human visitors. It is a starting point for [GeoResolver.MatchASNs], to be adjusted to
+// the traffic of a service: APIs called from servers should not deny these networks. Search engine
+// crawlers, such as Googlebot, use networks that are not listed.
+func HostingASNs() []uint32 {
+	return []uint32{
+		16509,  // Amazon (AWS)
+		14618,  // Amazon (AWS)
+		8075,   // Microsoft (Azure)
+		396982, // Google Cloud
+		31898,  // Oracle Cloud
+		45102,  // Alibaba Cloud
+		132203, // Tencent Cloud
+		14061,  // DigitalOcean
+		63949,  // Akamai (Linode)
+		20473,  // Vultr
+		24940,  // Hetzner
+		16276,  // OVH
+		12876,  // Scaleway
+		51167,  // Contabo
+	}
+}
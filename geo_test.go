@@ -0,0 +1,79 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+// mapGeoProvider is a GeoProvider looking addresses up in a map.
+type mapGeoProvider map[netip.Addr]GeoInfo
+
+func (p mapGeoProvider) Lookup(addr netip.Addr) (GeoInfo, error) {
+	return p[addr], nil
+}
+
+var testGeoProvider = mapGeoProvider{
+	netip.MustParseAddr("192.0.2.1"):    {Country: "DE", ASN: 3320, Organization: "Deutsche Telekom AG"},
+	netip.MustParseAddr("192.0.2.2"):    {Country: "DE", ASN: 24940, Organization: "Hetzner Online GmbH"},
+	netip.MustParseAddr("198.51.100.1"): {Country: "FR"},
+}
+
+// Test requests are keyed by the country and ASN of their client
+func TestGeoResolverKeys(t *testing.T) {
+	geo := NewGeoResolver(testGeoProvider, nil)
+	tests := []struct {
+		addr    string
+		country string
+		asn     string
+	}{
+		{"192.0.2.1:1234", "DE", "AS3320"},
+		{"[::ffff:192.0.2.2]:1234", "DE", "AS24940"},
+		{"198.51.100.1:1234", "FR", ""},
+		{"203.0.113.1:1234", "", ""},
+	}
+	for _, test := range tests {
+		r := newRequestFrom(test.addr)
+		country, err := geo.KeyByCountry(r)
+		if country != test.country || (test.country == "") != errors.Is(err, ErrNoKey) {
+			t.Errorf("%s: expected country %q; got %q, %v", test.addr, test.country, country, err)
+		}
+		asn, err := geo.KeyByASN(r)
+		if asn != test.asn || (test.asn == "") != errors.Is(err, ErrNoKey) {
+			t.Errorf("%s: expected ASN %q; got %q, %v", test.addr, test.asn, asn, err)
+		}
+	}
+}
+
+// Test the client IP is resolved with the ClientIPResolver of the GeoResolver
+func TestGeoResolverClientIP(t *testing.T) {
+	geo := NewGeoResolver(testGeoProvider, NewClientIPResolver([]string{"10.0.0.0/8"}))
+	r := newRequestFrom("10.0.0.1:1234")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if country, _ := geo.KeyByCountry(r); country != "FR" {
+		t.Errorf("expected the country of the forwarded client; got %q", country)
+	}
+}
+
+// Test requests are denied by country and by ASN
+func TestGeoResolverMatchers(t *testing.T) {
+	geo := NewGeoResolver(testGeoProvider, nil)
+	handler := New(&MockRateLimiter{IsAllowedFunc: func(*http.Request) (bool, error) { return true, nil }},
+		WithDenylist(geo.MatchCountries("fr"), geo.MatchASNs(HostingASNs()...)))(noContent)
+
+	for addr, expected := range map[string]int{
+		"192.0.2.1:1234":    http.StatusNoContent,
+		"192.0.2.2:1234":    http.StatusForbidden,
+		"198.51.100.1:1234": http.StatusForbidden,
+		"203.0.113.1:1234":  http.StatusNoContent,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequestFrom(addr))
+		if rr.Code != expected {
+			t.Errorf("%s: expected status %d; got %d", addr, expected, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+)
+
+// globalKey is the key under which a [GlobalAndPerKeyLimiter] counts all requests.
+const globalKey = "global"
+
+// GlobalAndPerKeyLimiter is a [RateLimiter] and [AdvancedRateLimiter] enforcing a limit on each
+// client, such as 10 requests per second per IP address, along with a total limit on the whole
+// service, such as 1000 requests per second, so that many clients staying within their own limit
+// cannot overload the backends together.
+//
+// Behavior:
+//   - Requests are checked against the global limit first, then against the limit of their
+//     client. A request is allowed only if both allow it.
+//   - Requests denied by the limit of their client, or whose client cannot be identified, give
+//     back the global budget they consumed, so that a client hammering the service past its own
+//     limit does not eat into the budget of the others.
+//   - Requests denied by the global limit do not consume the budget of their client.
+//   - [GlobalAndPerKeyLimiter.GetRateLimitData] reports whichever limit is closest to being
+//     exhausted.
+//
+// The global budget is consumed and refunded atomically, but concurrent requests may briefly
+// observe global budget that is about to be refunded.
+//
+// A GlobalAndPerKeyLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter, err := NewGlobalAndPerKeyLimiter(
+//		Config{Algorithm: AlgorithmTokenBucket, Limit: 1000, Window: time.Second},
+//		Config{Algorithm: AlgorithmTokenBucket, Limit: 10, Window: time.Second},
+//		WithKeyFunc(KeyByHeader("X-API-Key")), WithStore(sharedStore))
+type GlobalAndPerKeyLimiter struct {
+	global builtinLimiter
+	perKey builtinLimiter
+	chain  *ChainLimiter
+	store  Store
+}
+
+// NewGlobalAndPerKeyLimiter creates a new [GlobalAndPerKeyLimiter] enforcing global on all
+// requests together and perKey on the requests of each client. The options are applied to both
+// limits, except for the [KeyFunc] set with [WithKeyFunc], which only identifies the clients of
+// perKey. Both limits keep their state in the same store, under distinct prefixes. An error
+// wrapping [ErrInvalidConfig] is returned if a config is invalid.
+func NewGlobalAndPerKeyLimiter(global, perKey Config, opts ...LimiterOption) (*GlobalAndPerKeyLimiter, error) {
+	if err := global.Validate(); err != nil {
+		return nil, err
+	}
+	if err := perKey.Validate(); err != nil {
+		return nil, err
+	}
+	store := newLimiterOptions(opts).store
+	globalLimiter := newBuiltinLimiter(global, append(append([]LimiterOption(nil), opts...),
+		WithStore(prefixedStore{Store: store, prefix: "global:"}),
+		WithKeyFunc(func(*http.Request) (string, error) { return globalKey, nil }),
+		withTopKeys(nil))...)
+	perKeyLimiter := newBuiltinLimiter(perKey, append(append([]LimiterOption(nil), opts...),
+		WithStore(prefixedStore{Store: store, prefix: "key:"}))...)
+	return &GlobalAndPerKeyLimiter{
+		global: globalLimiter,
+		perKey: perKeyLimiter,
+		chain:  NewChainLimiter(globalLimiter, perKeyLimiter),
+		store:  store,
+	}, nil
+}
+
+// IsAllowed checks the request against the global limit and the limit of its client. It returns
+// true if both allowed it, false otherwise. An error is returned if a limit fails, or if the
+// client cannot be identified.
+func (l *GlobalAndPerKeyLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the limits.
+func (l *GlobalAndPerKeyLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.chain.IsAllowedContext(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by the request from both limits. It implements
+// [RefundableRateLimiter].
+func (l *GlobalAndPerKeyLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return l.chain.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the rate limit data of the limit with the fewest remaining requests,
+// which is the global limit when the service is close to its total capacity.
+func (l *GlobalAndPerKeyLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	return l.chain.GetRateLimitData(r)
+}
+
+// GlobalUsage returns the current usage of the global limit, without consuming any budget, for
+// example to export how close the service is to its total capacity as a metric.
+func (l *GlobalAndPerKeyLimiter) GlobalUsage(ctx context.Context) (RateLimitData, error) {
+	return l.global.Usage(ctx, globalKey)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *GlobalAndPerKeyLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// key returns the key identifying the client making the request.
+func (l *GlobalAndPerKeyLimiter) key(r *http.Request) (string, error) {
+	return l.perKey.key(r)
+}
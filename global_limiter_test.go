@@ -0,0 +1,97 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test the global limit caps the requests of all clients together
+func TestGlobalAndPerKeyLimiterGlobalCap(t *testing.T) {
+	limiter, err := NewGlobalAndPerKeyLimiter(
+		Config{Algorithm: AlgorithmFixedWindow, Limit: 3, Window: time.Minute},
+		Config{Algorithm: AlgorithmFixedWindow, Limit: 2, Window: time.Minute},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close(context.Background())
+
+	for i, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.3:1234"} {
+		if isAllowed, err := limiter.IsAllowed(newRequestFrom(addr)); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(newRequestFrom("192.0.2.4:1234")); isAllowed {
+		t.Errorf("expected request exceeding the global limit to be denied")
+	}
+	data, err := limiter.GlobalUsage(context.Background())
+	if err != nil || data.Limit != 3 || data.Remaining != 0 {
+		t.Errorf("expected global usage of 3 out of 3; got %+v, %v", data, err)
+	}
+}
+
+// Test requests denied by the limit of their client give back the global budget
+func TestGlobalAndPerKeyLimiterRefundsGlobal(t *testing.T) {
+	limiter, err := NewGlobalAndPerKeyLimiter(
+		Config{Algorithm: AlgorithmTokenBucket, Limit: 3, Window: time.Hour},
+		Config{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Hour},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hammering := newRequestFrom("192.0.2.1:1234")
+
+	if isAllowed, _ := limiter.IsAllowed(hammering); !isAllowed {
+		t.Fatalf("expected first request to be allowed")
+	}
+	for range 5 {
+		if isAllowed, _ := limiter.IsAllowed(hammering); isAllowed {
+			t.Fatalf("expected request exceeding the limit of the client to be denied")
+		}
+	}
+	for i, addr := range []string{"192.0.2.2:1234", "192.0.2.3:1234"} {
+		if isAllowed, err := limiter.IsAllowed(newRequestFrom(addr)); err != nil || !isAllowed {
+			t.Errorf("expected request of client %d to be allowed; got %v, %v", i+2, isAllowed, err)
+		}
+	}
+	if data := limiter.GetRateLimitData(newRequestFrom("192.0.2.4:1234")); data.Limit != 3 || data.Remaining != 0 {
+		t.Errorf("expected the exhausted global limit to be reported; got %+v", data)
+	}
+}
+
+// Test requests whose client cannot be identified are rejected without consuming global budget
+func TestGlobalAndPerKeyLimiterKeyError(t *testing.T) {
+	limiter, err := NewGlobalAndPerKeyLimiter(
+		Config{Algorithm: AlgorithmFixedWindow, Limit: 1, Window: time.Minute},
+		Config{Algorithm: AlgorithmFixedWindow, Limit: 1, Window: time.Minute},
+		WithKeyFunc(KeyByHeader("X-API-Key")),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey; got %v", err)
+	}
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-API-Key", "key")
+	if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+		t.Errorf("expected request with a key to be allowed; got %v, %v", isAllowed, err)
+	}
+	if key, err := limiter.key(req); err != nil || key != "key" {
+		t.Errorf("expected key to be key; got %v, %v", key, err)
+	}
+}
+
+// Test invalid configs are rejected
+func TestGlobalAndPerKeyLimiterInvalidConfig(t *testing.T) {
+	valid := Config{Algorithm: AlgorithmGCRA, Limit: 1, Window: time.Second}
+	if _, err := NewGlobalAndPerKeyLimiter(Config{}, valid); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for the global config; got %v", err)
+	}
+	if _, err := NewGlobalAndPerKeyLimiter(valid, Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for the per-key config; got %v", err)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/mxmlkzdh/cerberus
 
 go 1.23.1
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,364 @@
+// Package gossiplimiter provides an approximate distributed rate limiter that needs no central
+// store. Each node of a cluster counts the requests it allows, and periodically sends its counts
+// to its peers over UDP. A node allows a request if the counts of the whole cluster, as last heard
+// from its peers, leave room for it.
+//
+// This trades precision for throughput and availability: checks never leave the node, and the
+// loss of a peer only loses its counts. The cluster may exceed the limit by the requests the other
+// nodes allowed since they last sent their counts, that is by up to the rate of the cluster times
+// the sync interval, so the sync interval should be small compared to the window:
+//
+//	conn, err := net.ListenPacket("udp", ":7946")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	limiter := gossiplimiter.New(1000, time.Minute, conn)
+//	defer limiter.Close(context.Background())
+//	if err := limiter.SetPeers("10.0.0.2:7946", "10.0.0.3:7946"); err != nil {
+//		log.Fatal(err)
+//	}
+//	http.Handle("/api/", cerberus.New(limiter)(apiHandler))
+//
+// Limits are enforced over fixed windows aligned to the zero time, so the clocks of the nodes must
+// be synchronized. Messages are neither authenticated nor encrypted, so the port must only be
+// reachable from the cluster's private network.
+package gossiplimiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// maxPayload is the size above which counts are split across several messages, so that messages
+// fit in the MTU of most networks. Entries larger than that are sent alone.
+const maxPayload = 1200
+
+// message is the payload of a UDP datagram, carrying the counts of a node for a window.
+type message struct {
+	Node   uint64           `json:"node"`
+	Window int64            `json:"window"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// Limiter is a [cerberus.RateLimiter] and [cerberus.AdvancedRateLimiter] enforcing an approximate
+// limit across a cluster of nodes, each running a Limiter with the same limit and window. Each
+// client, identified by its key (its IP address by default), may make up to limit requests per
+// window across the cluster.
+//
+// A Limiter is safe for concurrent use by multiple goroutines.
+type Limiter struct {
+	limit    int64
+	window   time.Duration
+	conn     net.PacketConn
+	node     uint64
+	keyFunc  cerberus.KeyFunc
+	costFunc cerberus.CostFunc
+	interval time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	peers       []net.Addr
+	windowStart time.Time
+	local       map[string]int64
+	dirty       map[string]bool
+	remote      map[uint64]map[string]int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	synced    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Option configures a [Limiter].
+type Option func(*Limiter)
+
+// WithKeyFunc sets the [cerberus.KeyFunc] used to map requests to rate limiting keys. The default
+// is [cerberus.KeyByIP].
+func WithKeyFunc(keyFunc cerberus.KeyFunc) Option {
+	return func(l *Limiter) {
+		l.keyFunc = keyFunc
+	}
+}
+
+// WithCostFunc sets the [cerberus.CostFunc] used to weigh requests. The default gives every
+// request a cost of one.
+func WithCostFunc(costFunc cerberus.CostFunc) Option {
+	return func(l *Limiter) {
+		l.costFunc = costFunc
+	}
+}
+
+// WithSyncInterval sets how often the limiter sends its counts to its peers. The default is a
+// tenth of the window.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(l *Limiter) {
+		l.interval = interval
+	}
+}
+
+// New creates a new [Limiter] that allows up to limit requests per client within each window
+// across the cluster, exchanging counts with its peers through conn. The limiter takes ownership
+// of conn, and closes it when it is closed. It has no peers until [Limiter.SetPeers] is called.
+//
+// It panics if limit, window, or the sync interval is not positive.
+func New(limit int, window time.Duration, conn net.PacketConn, opts ...Option) *Limiter {
+	if limit <= 0 {
+		panic("gossiplimiter: limit must be positive")
+	}
+	if window <= 0 {
+		panic("gossiplimiter: window must be positive")
+	}
+	l := &Limiter{
+		limit:    int64(limit),
+		window:   window,
+		conn:     conn,
+		node:     rand.Uint64(),
+		keyFunc:  cerberus.KeyByIP,
+		interval: window / 10,
+		now:      time.Now,
+		local:    make(map[string]int64),
+		dirty:    make(map[string]bool),
+		remote:   make(map[uint64]map[string]int64),
+		done:     make(chan struct{}),
+		synced:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.interval <= 0 {
+		panic("gossiplimiter: sync interval must be positive")
+	}
+	l.wg.Add(2)
+	go l.receive()
+	go l.sync()
+	return l
+}
+
+// SetPeers replaces the peers the limiter sends its counts to, given as UDP addresses such as
+// "10.0.0.2:7946". It is meant to be called whenever the membership of the cluster changes. The
+// counts already received from removed peers are kept until the end of the window. An error is
+// returned if an address cannot be resolved, in which case the peers are left unchanged.
+func (l *Limiter) SetPeers(peers ...string) error {
+	addrs := make([]net.Addr, 0, len(peers))
+	for _, peer := range peers {
+		addr, err := net.ResolveUDPAddr("udp", peer)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.peers = addrs
+	return nil
+}
+
+// Addr returns the local address the limiter receives counts on.
+func (l *Limiter) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// IsAllowed adds the cost of the request to the local count of the client making it, if the counts
+// of the cluster leave room for it. It returns true if the request was counted, false otherwise.
+// An error is returned if no key can be derived from the request.
+func (l *Limiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed. The limiter does not use ctx, since checks never leave the
+// node.
+func (l *Limiter) IsAllowedContext(_ context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	cost := l.cost(r)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance()
+	if l.count(key)+cost > l.limit {
+		return false, nil
+	}
+	if cost > 0 {
+		l.local[key] += cost
+		l.dirty[key] = true
+	}
+	return true, nil
+}
+
+// GetRateLimitData returns the state of the limit of the client making the request, as known by
+// this node. Remaining is the budget left in the current window across the cluster, and RetryAfter
+// is the time until the next window begins if it does not cover the cost of the request. The zero
+// RateLimitData is returned if no key can be derived from the request.
+func (l *Limiter) GetRateLimitData(r *http.Request) cerberus.RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return cerberus.RateLimitData{}
+	}
+	cost := l.cost(r)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance()
+	data := cerberus.RateLimitData{
+		Limit:     int(l.limit),
+		Remaining: int(max(l.limit-l.count(key), 0)),
+		Window:    l.window,
+	}
+	if int64(data.Remaining) < cost && cost <= l.limit {
+		data.RetryAfter = l.windowStart.Add(l.window).Sub(l.now())
+	}
+	return data
+}
+
+// Close sends the counts that changed since the last sync to the peers, stops exchanging counts
+// with them, and closes the connection of the limiter. If ctx is done before the counts are sent,
+// the connection is closed anyway and the error of ctx is returned. Requests checked afterwards
+// are only limited by the counts known so far. It implements [cerberus.Closer].
+func (l *Limiter) Close(ctx context.Context) error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		select {
+		case <-l.synced:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		err = errors.Join(err, l.conn.Close())
+		l.wg.Wait()
+	})
+	return err
+}
+
+// cost returns the cost of r.
+func (l *Limiter) cost(r *http.Request) int64 {
+	if l.costFunc == nil {
+		return 1
+	}
+	return int64(max(l.costFunc(r), 0))
+}
+
+// count returns the count of key across the cluster in the current window. l.mu must be held.
+func (l *Limiter) count(key string) int64 {
+	count := l.local[key]
+	for _, counts := range l.remote {
+		count += counts[key]
+	}
+	return count
+}
+
+// advance resets the counts if a new window has begun. l.mu must be held.
+func (l *Limiter) advance() {
+	start := l.now().Truncate(l.window)
+	if start.Equal(l.windowStart) {
+		return
+	}
+	l.windowStart = start
+	l.local = make(map[string]int64)
+	l.dirty = make(map[string]bool)
+	l.remote = make(map[uint64]map[string]int64)
+}
+
+// sync sends the counts that changed since the last sync to the peers, every sync interval, until
+// the limiter is closed, and a last time then.
+func (l *Limiter) sync() {
+	defer l.wg.Done()
+	defer close(l.synced)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			l.broadcast()
+			return
+		case <-ticker.C:
+			l.broadcast()
+		}
+	}
+}
+
+// broadcast sends the counts that changed since the last call to the peers.
+func (l *Limiter) broadcast() {
+	for _, payload := range l.payloads() {
+		l.mu.Lock()
+		peers := l.peers
+		l.mu.Unlock()
+		for _, peer := range peers {
+			// Messages carry the full counts of their keys rather than increments, so a lost
+			// message is made up for when its keys are next counted.
+			l.conn.WriteTo(payload, peer)
+		}
+	}
+}
+
+// payloads returns the messages carrying the counts that changed since the last call, split so
+// that each fits in maxPayload if possible.
+func (l *Limiter) payloads() [][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance()
+	var payloads [][]byte
+	msg := message{Node: l.node, Window: l.windowStart.UnixNano(), Counts: make(map[string]int64)}
+	size := 0
+	for key := range l.dirty {
+		entry, _ := json.Marshal(map[string]int64{key: l.local[key]})
+		if size > 0 && size+len(entry) > maxPayload {
+			payload, _ := json.Marshal(msg)
+			payloads = append(payloads, payload)
+			msg.Counts, size = make(map[string]int64), 0
+		}
+		msg.Counts[key] = l.local[key]
+		size += len(entry)
+	}
+	if size > 0 {
+		payload, _ := json.Marshal(msg)
+		payloads = append(payloads, payload)
+	}
+	l.dirty = make(map[string]bool)
+	return payloads
+}
+
+// receive merges the counts received from the peers, until the limiter is closed. Counts of other
+// windows, or sent by the limiter itself, are ignored.
+func (l *Limiter) receive() {
+	defer l.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+				continue
+			}
+		}
+		var msg message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil || msg.Node == l.node {
+			continue
+		}
+		l.mu.Lock()
+		l.advance()
+		if msg.Window == l.windowStart.UnixNano() {
+			counts := l.remote[msg.Node]
+			if counts == nil {
+				counts = make(map[string]int64)
+				l.remote[msg.Node] = counts
+			}
+			for key, count := range msg.Counts {
+				counts[key] = max(counts[key], count)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,132 @@
+package gossiplimiter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLimiter(t *testing.T, limit int, opts ...Option) *Limiter {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	opts = append([]Option{WithSyncInterval(10 * time.Millisecond)}, opts...)
+	limiter := New(limit, time.Hour, conn, opts...)
+	now := time.Unix(1700000000, 0)
+	limiter.mu.Lock()
+	limiter.now = func() time.Time { return now }
+	limiter.mu.Unlock()
+	t.Cleanup(func() { limiter.Close(context.Background()) })
+	return limiter
+}
+
+func newRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	return req
+}
+
+// waitForRemaining waits until the limiter reports remaining requests for the client.
+func waitForRemaining(t *testing.T, limiter *Limiter, remaining int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for limiter.GetRateLimitData(newRequest()).Remaining != remaining {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d remaining requests; got %+v", remaining, limiter.GetRateLimitData(newRequest()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Test a node limits requests locally
+func TestLimiterAllowsUpToLimit(t *testing.T) {
+	limiter := newLimiter(t, 2)
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(newRequest()); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(newRequest()); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+	data := limiter.GetRateLimitData(newRequest())
+	if data.Limit != 2 || data.Remaining != 0 || data.RetryAfter <= 0 || data.Window != time.Hour {
+		t.Errorf("expected an exhausted limit; got %+v", data)
+	}
+}
+
+// Test nodes share their counts and enforce a common limit
+func TestLimiterSharesCounts(t *testing.T) {
+	a, b := newLimiter(t, 10), newLimiter(t, 10)
+	if err := a.SetPeers(b.Addr().String()); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	b.SetPeers(a.Addr().String())
+
+	for i := 0; i < 6; i++ {
+		a.IsAllowed(newRequest())
+	}
+	waitForRemaining(t, b, 4)
+	for i := 0; i < 4; i++ {
+		if isAllowed, _ := b.IsAllowed(newRequest()); !isAllowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+	if isAllowed, _ := b.IsAllowed(newRequest()); isAllowed {
+		t.Errorf("expected request exceeding the cluster limit to be denied")
+	}
+	waitForRemaining(t, a, 0)
+}
+
+// Test counts are split across messages that fit in a datagram
+func TestLimiterSplitsPayloads(t *testing.T) {
+	limiter := newLimiter(t, 10, WithSyncInterval(time.Hour), WithKeyFunc(func(r *http.Request) (string, error) {
+		return r.URL.Path, nil
+	}))
+	for i := 0; i < 100; i++ {
+		limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("x", 50)+string(rune('a'+i%26))+string(rune('a'+i/26)), nil))
+	}
+	payloads := limiter.payloads()
+	if len(payloads) < 2 {
+		t.Fatalf("expected several payloads; got %d", len(payloads))
+	}
+	for _, payload := range payloads {
+		if len(payload) > maxPayload+100 {
+			t.Errorf("expected payloads of about %d bytes; got %d", maxPayload, len(payload))
+		}
+	}
+	if payloads := limiter.payloads(); len(payloads) != 0 {
+		t.Errorf("expected no payload without changes; got %d", len(payloads))
+	}
+}
+
+// Test invalid peers are rejected
+func TestLimiterSetPeersInvalid(t *testing.T) {
+	if err := newLimiter(t, 1).SetPeers("not an address"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+// Test closing a node sends the counts it has not synced yet
+func TestLimiterCloseSendsPendingCounts(t *testing.T) {
+	a, b := newLimiter(t, 10, WithSyncInterval(time.Hour)), newLimiter(t, 10)
+	a.SetPeers(b.Addr().String())
+
+	for i := 0; i < 3; i++ {
+		a.IsAllowed(newRequest())
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	waitForRemaining(t, b, 7)
+	if err := a.Close(context.Background()); err != nil {
+		t.Errorf("expected closing again to have no effect; got %v", err)
+	}
+}
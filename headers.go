@@ -0,0 +1,325 @@
+package cerberus
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HeaderStyle selects the set of headers used to report rate limit information to clients.
+type HeaderStyle int
+
+const (
+	// HeaderStyleLegacy reports rate limit information using the widespread, non-standard
+	// X-RateLimit-* headers:
+	//   - X-RateLimit-Limit and X-RateLimit-Remaining on allowed requests, along with
+	//     X-RateLimit-Reset, the time at which the budget of the client is fully restored in Unix
+	//     seconds, if the rate limiter reports it.
+	//   - X-RateLimit-Retry-After, in milliseconds, on denied requests.
+	HeaderStyleLegacy HeaderStyle = iota
+
+	// HeaderStyleIETF reports rate limit information using the headers defined by the IETF draft
+	// "RateLimit header fields for HTTP" and the standard Retry-After header:
+	//   - RateLimit-Limit and RateLimit-Remaining on allowed requests, along with RateLimit-Reset,
+	//     the number of seconds until the budget of the client is fully restored, if the rate
+	//     limiter reports it.
+	//   - RateLimit-Policy on allowed requests, describing the limit and its window in seconds, as
+	//     in "100;w=60", if the rate limiter reports a window or a policy.
+	//   - Retry-After, in seconds, on denied requests.
+	HeaderStyleIETF
+)
+
+// RetryAfterFormat selects the format of the standard Retry-After header on responses to denied
+// requests. See [WithRetryAfter].
+type RetryAfterFormat int
+
+const (
+	// RetryAfterSeconds writes the time until the client may retry in whole seconds, rounded up,
+	// as in "Retry-After: 120".
+	RetryAfterSeconds RetryAfterFormat = iota + 1
+
+	// RetryAfterHTTPDate writes the time at which the client may retry as an HTTP date, rounded up
+	// to the next second, as in "Retry-After: Wed, 21 Oct 2015 07:28:00 GMT".
+	RetryAfterHTTPDate
+)
+
+// WithRetryAfter makes the middlewares add the standard Retry-After header to responses to
+// requests denied by an [AdvancedRateLimiter], in the given format, whatever the header style.
+// Many HTTP clients honor Retry-After but none of the other rate limit headers. By default,
+// Retry-After is only added, in seconds, with [HeaderStyleIETF].
+//
+// Example usage: http.Handle("/resource", New(myAdvancedRateLimiter, WithRetryAfter(RetryAfterHTTPDate))(myHandler))
+func WithRetryAfter(format RetryAfterFormat) Option {
+	return func(o *options) {
+		o.retryAfter = format
+	}
+}
+
+// WithDeniedHeaders sets whether the middlewares also add the rate limit headers of allowed
+// requests to the responses to requests denied by an [AdvancedRateLimiter], since clients need them
+// the most when they are throttled. It is disabled by default.
+//
+// Behavior:
+//   - With [HeaderStyleLegacy], denied responses carry X-RateLimit-Limit, X-RateLimit-Remaining,
+//     and X-RateLimit-Reset, alongside X-RateLimit-Retry-After.
+//   - With [HeaderStyleIETF], denied responses carry RateLimit-Limit, RateLimit-Remaining,
+//     RateLimit-Reset, which is the time until the client may retry, and RateLimit-Policy,
+//     alongside Retry-After.
+//   - The remaining budget is always reported as 0 on denied responses, even if the request was
+//     denied only because its cost exceeds the budget left.
+//
+// Example usage: http.Handle("/resource", New(myAdvancedRateLimiter, WithDeniedHeaders(true))(myHandler))
+func WithDeniedHeaders(enabled bool) Option {
+	return func(o *options) {
+		o.deniedHeaders = enabled
+	}
+}
+
+// HeaderNames renames the rate limit headers added to responses, for example to follow the naming
+// scheme of an API gateway, or suppresses some of them, such as those disclosing the size of the
+// budget of clients. Each field is the name of a header: an empty field keeps the name of the
+// header style, and "-" suppresses the header. See [WithHeaderNames].
+type HeaderNames struct {
+	// Limit renames X-RateLimit-Limit, or RateLimit-Limit with [HeaderStyleIETF].
+	Limit string
+
+	// Remaining renames X-RateLimit-Remaining, or RateLimit-Remaining with [HeaderStyleIETF].
+	Remaining string
+
+	// Reset renames X-RateLimit-Reset, or RateLimit-Reset with [HeaderStyleIETF].
+	Reset string
+
+	// Policy renames RateLimit-Policy, which is only written with [HeaderStyleIETF].
+	Policy string
+
+	// RetryAfter renames X-RateLimit-Retry-After, or Retry-After with [HeaderStyleIETF]. The
+	// Retry-After header added with [WithRetryAfter] is not renamed.
+	RetryAfter string
+
+	// Backoff renames X-RateLimit-Backoff, which is written with [WithBackoffHints].
+	Backoff string
+
+	// Jitter renames X-RateLimit-Jitter, which is written with [WithBackoffHints].
+	Jitter string
+}
+
+// WithHeaderNames sets the names of the rate limit headers added to responses, on top of those of
+// the header style, so that headers can be renamed or suppressed one by one. All rate limit
+// headers are suppressed with [WithHeaders] instead.
+//
+// Example usage: http.Handle("/resource", New(myAdvancedRateLimiter, WithHeaderNames(HeaderNames{Limit: "-", Remaining: "X-Quota-Remaining"}))(myHandler))
+func WithHeaderNames(names HeaderNames) Option {
+	return func(o *options) {
+		o.headerNames = names
+	}
+}
+
+// Canonical forms of the header names, which are assigned to header maps directly rather than
+// through [http.Header.Set], so that they do not need to be canonicalized on every request.
+var (
+	headerRateLimit           = http.CanonicalHeaderKey("RateLimit")
+	headerRateLimitLimit      = http.CanonicalHeaderKey("RateLimit-Limit")
+	headerRateLimitRemaining  = http.CanonicalHeaderKey("RateLimit-Remaining")
+	headerRateLimitReset      = http.CanonicalHeaderKey("RateLimit-Reset")
+	headerRateLimitPolicy     = http.CanonicalHeaderKey("RateLimit-Policy")
+	headerXRateLimitLimit     = http.CanonicalHeaderKey("X-RateLimit-Limit")
+	headerXRateLimitRemaining = http.CanonicalHeaderKey("X-RateLimit-Remaining")
+	headerXRateLimitReset     = http.CanonicalHeaderKey("X-RateLimit-Reset")
+	headerXRateLimitRetry     = http.CanonicalHeaderKey("X-RateLimit-Retry-After")
+	headerXRateLimitBackoff   = http.CanonicalHeaderKey("X-RateLimit-Backoff")
+	headerXRateLimitJitter    = http.CanonicalHeaderKey("X-RateLimit-Jitter")
+	headerRetryAfter          = http.CanonicalHeaderKey("Retry-After")
+)
+
+// headerKeys are the canonical names of the rate limit headers written by the middlewares,
+// resolved once from the header style and the [HeaderNames] set with [WithHeaderNames]. The names
+// of suppressed headers are empty.
+type headerKeys struct {
+	style      HeaderStyle
+	limit      string
+	remaining  string
+	reset      string
+	policy     string
+	retryAfter string
+	backoff    string
+	jitter     string
+}
+
+// newHeaderKeys returns the names of the headers of style, renamed with names.
+func newHeaderKeys(style HeaderStyle, names HeaderNames) headerKeys {
+	keys := headerKeys{
+		style:      style,
+		limit:      headerXRateLimitLimit,
+		remaining:  headerXRateLimitRemaining,
+		reset:      headerXRateLimitReset,
+		retryAfter: headerXRateLimitRetry,
+		backoff:    headerXRateLimitBackoff,
+		jitter:     headerXRateLimitJitter,
+	}
+	if style == HeaderStyleIETF {
+		keys.limit = headerRateLimitLimit
+		keys.remaining = headerRateLimitRemaining
+		keys.reset = headerRateLimitReset
+		keys.policy = headerRateLimitPolicy
+		keys.retryAfter = headerRetryAfter
+	}
+	rename := func(key *string, name string) {
+		switch name {
+		case "":
+		case "-":
+			*key = ""
+		default:
+			*key = http.CanonicalHeaderKey(name)
+		}
+	}
+	rename(&keys.limit, names.Limit)
+	rename(&keys.remaining, names.Remaining)
+	rename(&keys.reset, names.Reset)
+	if style == HeaderStyleIETF {
+		rename(&keys.policy, names.Policy)
+	}
+	rename(&keys.retryAfter, names.RetryAfter)
+	rename(&keys.backoff, names.Backoff)
+	rename(&keys.jitter, names.Jitter)
+	return keys
+}
+
+// writeAllowedHeaders sets the headers reporting data on the response to an allowed request, at
+// now by the clock of the rate limiter. Nothing is written if data has no limit, which means the
+// request was not subject to one.
+func (k *headerKeys) writeAllowedHeaders(h http.Header, data RateLimitData, now time.Time) {
+	if data.Limit == 0 {
+		return
+	}
+	var buf [64]byte
+	values := headerValues{text: buf[:0]}
+	switch k.style {
+	case HeaderStyleIETF:
+		values = values.appendInt(int64(data.Limit))
+		values = values.appendInt(int64(data.Remaining))
+		// The value of an unknown reset time is written without a key, so that it is omitted.
+		resetKey := k.reset
+		if data.ResetAt.IsZero() {
+			resetKey = ""
+		}
+		values = values.appendInt(max(ceilSeconds(data.ResetAt.Sub(now)), 0))
+		if data.Policy != "" {
+			values = values.appendString(data.Policy)
+		} else if data.Window > 0 {
+			values.text = append(strconv.AppendInt(values.text, int64(data.Limit), 10), ";w="...)
+			values = values.appendInt(ceilSeconds(data.Window))
+		}
+		values.writeTo(h, k.limit, k.remaining, resetKey, k.policy)
+	default:
+		values = values.appendInt(int64(data.Limit))
+		values = values.appendInt(int64(data.Remaining))
+		if !data.ResetAt.IsZero() {
+			values = values.appendInt(ceilUnix(data.ResetAt))
+		}
+		values.writeTo(h, k.limit, k.remaining, k.reset)
+	}
+}
+
+// writeDeniedHeaders sets the headers reporting data on the response to a denied request, at now
+// by the clock of the rate limiter, along with the headers of allowed requests if limitHeaders is
+// true, whose reset time is then the time until the client may retry.
+func (k *headerKeys) writeDeniedHeaders(h http.Header, data RateLimitData, limitHeaders bool, now time.Time) {
+	if limitHeaders {
+		limitData := data
+		limitData.Remaining = 0
+		if k.style == HeaderStyleIETF {
+			limitData.ResetAt = now.Add(data.RetryAfter)
+		}
+		k.writeAllowedHeaders(h, limitData, now)
+	}
+	var buf [32]byte
+	values := headerValues{text: buf[:0]}
+	switch k.style {
+	case HeaderStyleIETF:
+		values.appendInt(ceilSeconds(data.RetryAfter)).writeTo(h, k.retryAfter)
+	default:
+		values.appendInt(data.RetryAfter.Milliseconds()).writeTo(h, k.retryAfter)
+	}
+}
+
+// headerValues holds the values of up to four headers, formatted one after the other into a
+// buffer provided by the caller, usually on its stack. Setting them with writeTo costs two
+// allocations, one for the text of all values and one for the slices holding them, where setting
+// each header with [http.Header.Set] and [strconv.Itoa] would cost up to three allocations per
+// header. headerValues is passed by value, so that the buffer does not escape to the heap.
+type headerValues struct {
+	text []byte
+	ends [4]int
+	n    int
+}
+
+// appendInt returns the values with a value added, made of the text appended since the previous
+// value followed by v in decimal.
+func (v headerValues) appendInt(i int64) headerValues {
+	v.text = strconv.AppendInt(v.text, i, 10)
+	return v.end()
+}
+
+// appendString is like appendInt, but with a string.
+func (v headerValues) appendString(s string) headerValues {
+	v.text = append(v.text, s...)
+	return v.end()
+}
+
+// end returns the values with the end of the last value marked.
+func (v headerValues) end() headerValues {
+	v.ends[v.n] = len(v.text)
+	v.n++
+	return v
+}
+
+// writeTo sets the headers named keys to the values in order, replacing any existing values.
+// Keys without a value are ignored, and so are values whose key is empty, which are those of
+// suppressed headers. The keys must be in canonical form.
+func (v headerValues) writeTo(h http.Header, keys ...string) {
+	if v.n == 0 {
+		return
+	}
+	text := string(v.text)
+	values := make([]string, v.n)
+	start := 0
+	for i := 0; i < v.n; i++ {
+		values[i] = text[start:v.ends[i]]
+		if keys[i] != "" {
+			h[keys[i]] = values[i : i+1 : i+1]
+		}
+		start = v.ends[i]
+	}
+}
+
+// writeRetryAfter sets the Retry-After header reporting data on the response to a denied request
+// in the format selected with [WithRetryAfter], if any. An HTTP-date is computed from now, the
+// time of the clock of the rate limiter.
+func (o *options) writeRetryAfter(h http.Header, data RateLimitData, now time.Time) {
+	switch o.retryAfter {
+	case RetryAfterSeconds:
+		var buf [32]byte
+		headerValues{text: buf[:0]}.appendInt(ceilSeconds(data.RetryAfter)).writeTo(h, headerRetryAfter)
+	case RetryAfterHTTPDate:
+		retryAt := now.Add(data.RetryAfter)
+		if rounded := retryAt.Truncate(time.Second); rounded.Before(retryAt) {
+			retryAt = rounded.Add(time.Second)
+		}
+		h[headerRetryAfter] = []string{retryAt.UTC().Format(http.TimeFormat)}
+	}
+}
+
+// ceilSeconds returns d in whole seconds, rounded up so that clients never retry too early.
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// ceilUnix returns t in Unix seconds, rounded up so that clients never resume too early.
+func ceilUnix(t time.Time) int64 {
+	seconds := t.Unix()
+	if t.Nanosecond() > 0 {
+		seconds++
+	}
+	return seconds
+}
@@ -0,0 +1,100 @@
+package cerberus
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Hooks are callbacks invoked by the middlewares on their rate limiting decisions, so that
+// applications can attach behavior such as audit logging, business metrics, or notifying an abuse
+// detection system, without wrapping the rate limiter. Nil callbacks are ignored.
+//
+// Hooks run synchronously on the goroutine serving the request, before the response is written,
+// and must therefore be fast. Slow work, such as calling a remote service, should be handed off to
+// another goroutine.
+type Hooks struct {
+	// OnAllowed is called for each request allowed by the rate limiter, before it is forwarded to
+	// the next handler. data is the rate limit data reported for the request if the rate limiter
+	// implements [AdvancedRateLimiter], and the zero RateLimitData otherwise.
+	OnAllowed func(r *http.Request, data RateLimitData)
+
+	// OnDenied is called for each request denied by the rate limiter, before it is passed to the
+	// denied handler. data is as for OnAllowed.
+	OnDenied func(r *http.Request, data RateLimitData)
+
+	// OnError is called for each request for which the rate limiter returned err, before the
+	// request is handled according to the failure policy.
+	OnError func(r *http.Request, err error)
+
+	// OnBanned is called when the denial of r gets the client identified by key banned for d by
+	// the penalty policy set with [WithPenaltyPolicy], after OnDenied.
+	OnBanned func(r *http.Request, key string, d time.Duration)
+}
+
+// WithHooks adds callbacks invoked on the decisions of the middleware. It can be passed several
+// times, for example by independent integrations, in which case all hooks are invoked in the order
+// they were added. Requests exempted with [WithSkipFunc] or [WithAllowlist], and requests rejected
+// with [WithDenylist], are not subject to rate limiting and do not trigger any hook.
+//
+// Example usage:
+//
+//	hooks := Hooks{
+//		OnDenied: func(r *http.Request, data RateLimitData) {
+//			deniedRequests.WithLabelValues(r.Pattern).Inc()
+//		},
+//	}
+//	http.Handle("/resource", New(myRateLimiter, WithHooks(hooks))(myHandler))
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		if hooks.OnAllowed != nil {
+			o.onAllowed = append(o.onAllowed, hooks.OnAllowed)
+		}
+		if hooks.OnDenied != nil {
+			o.onDenied = append(o.onDenied, hooks.OnDenied)
+		}
+		if hooks.OnError != nil {
+			o.onError = append(o.onError, hooks.OnError)
+		}
+		if hooks.OnBanned != nil {
+			o.onBanned = append(o.onBanned, hooks.OnBanned)
+		}
+	}
+}
+
+// wantsData reports whether the rate limit data of a request is needed for the hooks or the logs
+// of a decision, whether or not headers are emitted.
+func (o *options) wantsData(r *http.Request, isAllowed bool) bool {
+	if isAllowed {
+		return len(o.onAllowed) > 0
+	}
+	return len(o.onDenied) > 0 || o.logEnabled(r.Context(), slog.LevelInfo)
+}
+
+// allowed invokes the OnAllowed hooks.
+func (o *options) allowed(r *http.Request, data RateLimitData) {
+	for _, hook := range o.onAllowed {
+		hook(r, data)
+	}
+}
+
+// denied invokes the OnDenied hooks.
+func (o *options) denied(r *http.Request, data RateLimitData) {
+	for _, hook := range o.onDenied {
+		hook(r, data)
+	}
+}
+
+// failed invokes the OnError hooks.
+func (o *options) failed(r *http.Request, err error) {
+	for _, hook := range o.onError {
+		hook(r, err)
+	}
+}
+
+// banned invokes the OnBanned hooks.
+func (o *options) banned(r *http.Request, key string, d time.Duration) {
+	for _, hook := range o.onBanned {
+		hook(r, key, d)
+	}
+}
@@ -0,0 +1,78 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test hooks are invoked on allowed and denied requests with their rate limit data
+func TestWithHooksAllowedAndDenied(t *testing.T) {
+	var allowed, denied []RateLimitData
+	hooks := Hooks{
+		OnAllowed: func(r *http.Request, data RateLimitData) { allowed = append(allowed, data) },
+		OnDenied:  func(r *http.Request, data RateLimitData) { denied = append(denied, data) },
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(NewFixedWindowLimiter(1, time.Minute), WithHooks(hooks), WithHeaders(false))(handler)
+
+	for range 2 {
+		middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+
+	if len(allowed) != 1 || allowed[0].Limit != 1 || allowed[0].Remaining != 0 {
+		t.Errorf("expected one allowed request with no remaining budget; got %+v", allowed)
+	}
+	if len(denied) != 1 || denied[0].RetryAfter <= 0 {
+		t.Errorf("expected one denied request with a retry-after; got %+v", denied)
+	}
+}
+
+// Test error hooks are invoked whatever the failure policy
+func TestWithHooksError(t *testing.T) {
+	errStore := errors.New("store unavailable")
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, errStore
+		},
+	}
+	var errs []error
+	hooks := Hooks{OnError: func(r *http.Request, err error) { errs = append(errs, err) }}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(mockLimiter, WithHooks(hooks), WithFailurePolicy(FailOpen))(handler)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the request to fail open; got %v", rr.Code)
+	}
+	if len(errs) != 1 || errs[0] != errStore {
+		t.Errorf("expected the store error; got %v", errs)
+	}
+}
+
+// Test several hooks are invoked in order, and exempted requests trigger none
+func TestWithHooksOrderAndExemptions(t *testing.T) {
+	var calls []string
+	first := Hooks{OnAllowed: func(*http.Request, RateLimitData) { calls = append(calls, "first") }}
+	second := Hooks{OnAllowed: func(*http.Request, RateLimitData) { calls = append(calls, "second") }}
+	skip := func(r *http.Request) bool { return r.URL.Path == "/health" }
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(NewFixedWindowLimiter(10, time.Minute), WithHooks(first), WithHooks(second), WithSkipFunc(skip))(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("expected first then second; got %v", calls)
+	}
+}
@@ -0,0 +1,115 @@
+package cerberus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrNoKey is returned by a [KeyFunc] when the request does not carry the attribute the
+// rate limiting key is derived from, such as a missing header or cookie.
+var ErrNoKey = errors.New("cerberus: no rate limit key in request")
+
+// KeyFunc extracts the key that identifies the client of a request for rate limiting purposes.
+// Requests with the same key share the same rate limit, so the choice of KeyFunc determines
+// whether clients are limited per IP address, per user, per API key, per endpoint, and so on.
+//
+// A KeyFunc returns an error if no key can be derived from the request. Errors are returned
+// to the caller of IsAllowed, which means the request is handled like any other rate limiter
+// error. Implementations should wrap [ErrNoKey] when the relevant attribute is missing.
+type KeyFunc func(*http.Request) (string, error)
+
+// KeyByIP is a [KeyFunc] that keys requests by the IP address of the client's connection,
+// as reported by RemoteAddr. It is the default KeyFunc of all built-in limiters.
+//
+// Behind reverse proxies, the connection comes from the last proxy. Use the Key method of a
+// [ClientIPResolver] trusting the proxies instead.
+func KeyByIP(r *http.Request) (string, error) {
+	return connectionIPResolver.Key(r)
+}
+
+// KeyByForwardedFor is a [KeyFunc] that keys requests by the first IP address listed in the
+// X-Forwarded-For header, falling back to the IP address of the connection if the header is
+// absent or does not end with a valid IP address.
+//
+// The X-Forwarded-For header is controlled by the client. Only use KeyByForwardedFor behind a
+// reverse proxy that overwrites the header, otherwise clients can evade the limit by spoofing it.
+// A [ClientIPResolver] trusting only known proxies is safer.
+func KeyByForwardedFor(r *http.Request) (string, error) {
+	return forwardedForResolver.Key(r)
+}
+
+// KeyByPath is a [KeyFunc] that keys requests by their URL path, so that all clients share
+// a single limit per endpoint.
+func KeyByPath(r *http.Request) (string, error) {
+	return r.URL.Path, nil
+}
+
+// KeyByHost is a [KeyFunc] that keys requests by the host they are sent to, as in the URL of
+// outgoing requests or the Host header of incoming ones, so that all requests to a host share a
+// single limit. It suits rate limiting outgoing requests with [Transport].
+func KeyByHost(r *http.Request) (string, error) {
+	if r.URL.Host != "" {
+		return r.URL.Host, nil
+	}
+	return r.Host, nil
+}
+
+// KeyByHeader returns a [KeyFunc] that keys requests by the value of the named header, such as
+// an API key or a user ID set by an authentication proxy. Requests without the header are
+// rejected with an error wrapping [ErrNoKey].
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return "", fmt.Errorf("%w: missing header %q", ErrNoKey, name)
+		}
+		return value, nil
+	}
+}
+
+// KeyByCookie returns a [KeyFunc] that keys requests by the value of the named cookie, such as a
+// session ID. Requests without the cookie are rejected with an error wrapping [ErrNoKey].
+func KeyByCookie(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", fmt.Errorf("%w: missing cookie %q", ErrNoKey, name)
+		}
+		return cookie.Value, nil
+	}
+}
+
+// KeyByQueryParam returns a [KeyFunc] that keys requests by the value of the named URL query
+// parameter. Requests without the parameter are rejected with an error wrapping [ErrNoKey].
+func KeyByQueryParam(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			return "", fmt.Errorf("%w: missing query parameter %q", ErrNoKey, name)
+		}
+		return value, nil
+	}
+}
+
+// KeyOf returns the key rateLimiter identifies the client making r by, such as the IP address
+// returned by [KeyByIP], for tooling that reports on clients, like dashboards of the most limited
+// keys. It returns an error wrapping [ErrNoKey] if rateLimiter does not report its keys, which is
+// the case of rate limiters other than the built-in ones.
+func KeyOf(rateLimiter RateLimiter, r *http.Request) (string, error) {
+	if k, ok := rateLimiter.(keyer); ok {
+		return k.key(r)
+	}
+	return "", fmt.Errorf("%w: %T does not report its keys", ErrNoKey, rateLimiter)
+}
+
+// remoteIP returns the IP address portion of the request's RemoteAddr. If RemoteAddr
+// cannot be split into a host and a port, it is returned unchanged.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,191 @@
+package cerberus
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccountResolver resolves an API key to the ID of the account owning it, usually by querying an
+// account service or a database. It should return an error wrapping [ErrNoKey] if the API key is
+// unknown or revoked.
+type AccountResolver func(ctx context.Context, apiKey string) (string, error)
+
+// APIKeyResolver keys requests by the account owning the API key they carry, so that all the API
+// keys of an account share its limit. Resolving API keys to accounts typically takes a round trip
+// to an account service, which must not be made on every request.
+//
+// Behavior:
+//   - Resolved accounts are kept in a cache of bounded size, evicting the least recently used API
+//     keys first, for a fixed time to live.
+//   - Unknown API keys, for which the resolver returned an error wrapping [ErrNoKey], are cached
+//     as well, so that clients sending random API keys cannot flood the account service. Other
+//     errors are not cached.
+//   - Concurrent requests carrying the same uncached API key share a single call to the resolver.
+//
+// Resolvers are used through [APIKeyResolver.Key] as a [KeyFunc].
+//
+// An APIKeyResolver is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	accounts := NewAPIKeyResolver(KeyByHeader("X-API-Key"), lookupAccount, 10000, 5*time.Minute)
+//	limiter := NewTokenBucketLimiter(100, 10, WithKeyFunc(accounts.Key))
+type APIKeyResolver struct {
+	source    KeyFunc
+	resolve   AccountResolver
+	cacheSize int
+	ttl       time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      list.List
+	inflight map[string]*accountCall
+
+	now func() time.Time
+}
+
+// accountEntry is a cached resolution of an API key. err is nil, or an error wrapping [ErrNoKey].
+type accountEntry struct {
+	apiKey    string
+	account   string
+	err       error
+	expiresAt time.Time
+}
+
+// accountCall is a call to the resolver shared by concurrent requests. done is closed once account
+// and err are set.
+type accountCall struct {
+	done    chan struct{}
+	account string
+	err     error
+}
+
+// NewAPIKeyResolver creates a new [APIKeyResolver] that takes API keys from requests with source,
+// such as [KeyByHeader] or [KeyByQueryParam], and resolves them to account IDs with resolve. Up to
+// cacheSize resolutions are cached, each for ttl.
+//
+// It panics if source or resolve is nil, or if cacheSize or ttl is not positive.
+func NewAPIKeyResolver(source KeyFunc, resolve AccountResolver, cacheSize int, ttl time.Duration) *APIKeyResolver {
+	if source == nil || resolve == nil {
+		panic("cerberus: API key source and resolver must not be nil")
+	}
+	if cacheSize <= 0 {
+		panic("cerberus: API key cache size must be positive")
+	}
+	if ttl <= 0 {
+		panic("cerberus: API key cache TTL must be positive")
+	}
+	return &APIKeyResolver{
+		source:    source,
+		resolve:   resolve,
+		cacheSize: cacheSize,
+		ttl:       ttl,
+		entries:   make(map[string]*list.Element),
+		inflight:  make(map[string]*accountCall),
+		now:       time.Now,
+	}
+}
+
+// Key is a [KeyFunc] that keys requests by the account owning the API key they carry. An error
+// wrapping [ErrNoKey] is returned if the request carries no API key or an unknown one, and the
+// error of the resolver is returned if it fails.
+func (a *APIKeyResolver) Key(r *http.Request) (string, error) {
+	apiKey, err := a.source(r)
+	if err != nil {
+		return "", err
+	}
+	return a.Account(r.Context(), apiKey)
+}
+
+// Account returns the ID of the account owning apiKey, from the cache if possible.
+func (a *APIKeyResolver) Account(ctx context.Context, apiKey string) (string, error) {
+	a.mu.Lock()
+	if entry, ok := a.lookup(apiKey); ok {
+		a.mu.Unlock()
+		return entry.account, entry.err
+	}
+	call, ok := a.inflight[apiKey]
+	if !ok {
+		call = &accountCall{done: make(chan struct{})}
+		a.inflight[apiKey] = call
+		// The call is shared, so it must not be canceled with the request that started it.
+		go a.call(context.WithoutCancel(ctx), apiKey, call)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.account, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate removes apiKey from the cache, for example after it has been revoked or moved to
+// another account.
+func (a *APIKeyResolver) Invalidate(apiKey string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if element, ok := a.entries[apiKey]; ok {
+		a.remove(element)
+	}
+}
+
+// call resolves apiKey, caches the result if it is cacheable, and completes call.
+func (a *APIKeyResolver) call(ctx context.Context, apiKey string, call *accountCall) {
+	call.account, call.err = a.resolve(ctx, apiKey)
+	if call.err != nil && !errors.Is(call.err, ErrNoKey) {
+		call.err = fmt.Errorf("cerberus: resolving API key: %w", call.err)
+	}
+	a.mu.Lock()
+	delete(a.inflight, apiKey)
+	if call.err == nil || errors.Is(call.err, ErrNoKey) {
+		a.store(&accountEntry{
+			apiKey:    apiKey,
+			account:   call.account,
+			err:       call.err,
+			expiresAt: a.now().Add(a.ttl),
+		})
+	}
+	a.mu.Unlock()
+	close(call.done)
+}
+
+// lookup returns the unexpired cache entry of apiKey, marking it as most recently used. a.mu must
+// be held.
+func (a *APIKeyResolver) lookup(apiKey string) (*accountEntry, bool) {
+	element, ok := a.entries[apiKey]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*accountEntry)
+	if !a.now().Before(entry.expiresAt) {
+		a.remove(element)
+		return nil, false
+	}
+	a.lru.MoveToFront(element)
+	return entry, true
+}
+
+// store adds entry to the cache, evicting the least recently used entry if the cache is full. a.mu
+// must be held.
+func (a *APIKeyResolver) store(entry *accountEntry) {
+	if element, ok := a.entries[entry.apiKey]; ok {
+		a.remove(element)
+	}
+	if a.lru.Len() >= a.cacheSize {
+		a.remove(a.lru.Back())
+	}
+	a.entries[entry.apiKey] = a.lru.PushFront(entry)
+}
+
+// remove removes element from the cache. a.mu must be held.
+func (a *APIKeyResolver) remove(element *list.Element) {
+	a.lru.Remove(element)
+	delete(a.entries, element.Value.(*accountEntry).apiKey)
+}
@@ -0,0 +1,139 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver resolves API keys "key-N" to accounts "account-N", and counts its calls.
+type countingResolver struct {
+	calls atomic.Int64
+}
+
+func (c *countingResolver) resolve(_ context.Context, apiKey string) (string, error) {
+	c.calls.Add(1)
+	switch apiKey {
+	case "unknown":
+		return "", fmt.Errorf("%w: unknown API key", ErrNoKey)
+	case "failing":
+		return "", errors.New("account service unavailable")
+	}
+	return "account-" + apiKey[len("key-"):], nil
+}
+
+func newRequestWithAPIKey(apiKey string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("X-API-Key", apiKey)
+	return req
+}
+
+// Test API keys are resolved to accounts and cached
+func TestAPIKeyResolverCaches(t *testing.T) {
+	resolver := &countingResolver{}
+	accounts := NewAPIKeyResolver(KeyByHeader("X-API-Key"), resolver.resolve, 10, time.Minute)
+	clock := newFakeClock()
+	accounts.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		if key, err := accounts.Key(newRequestWithAPIKey("key-1")); err != nil || key != "account-1" {
+			t.Fatalf("expected key account-1; got %q, %v", key, err)
+		}
+	}
+	if calls := resolver.calls.Load(); calls != 1 {
+		t.Errorf("expected 1 call to the resolver; got %d", calls)
+	}
+	clock.Advance(time.Minute)
+	accounts.Key(newRequestWithAPIKey("key-1"))
+	if calls := resolver.calls.Load(); calls != 2 {
+		t.Errorf("expected an expired entry to be resolved again; got %d calls", calls)
+	}
+	accounts.Invalidate("key-1")
+	accounts.Key(newRequestWithAPIKey("key-1"))
+	if calls := resolver.calls.Load(); calls != 3 {
+		t.Errorf("expected an invalidated entry to be resolved again; got %d calls", calls)
+	}
+}
+
+// Test unknown API keys are cached, and failures are not
+func TestAPIKeyResolverErrors(t *testing.T) {
+	resolver := &countingResolver{}
+	accounts := NewAPIKeyResolver(KeyByHeader("X-API-Key"), resolver.resolve, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := accounts.Key(newRequestWithAPIKey("unknown")); !errors.Is(err, ErrNoKey) {
+			t.Errorf("expected ErrNoKey; got %v", err)
+		}
+	}
+	if calls := resolver.calls.Load(); calls != 1 {
+		t.Errorf("expected unknown API keys to be cached; got %d calls", calls)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := accounts.Key(newRequestWithAPIKey("failing")); err == nil || errors.Is(err, ErrNoKey) {
+			t.Errorf("expected the resolver error; got %v", err)
+		}
+	}
+	if calls := resolver.calls.Load(); calls != 3 {
+		t.Errorf("expected failures not to be cached; got %d calls", calls)
+	}
+	if _, err := accounts.Key(httptest.NewRequest(http.MethodGet, "/api", nil)); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey without an API key; got %v", err)
+	}
+}
+
+// Test the least recently used API keys are evicted from a full cache
+func TestAPIKeyResolverEvictsLeastRecentlyUsed(t *testing.T) {
+	resolver := &countingResolver{}
+	accounts := NewAPIKeyResolver(KeyByHeader("X-API-Key"), resolver.resolve, 2, time.Minute)
+
+	accounts.Key(newRequestWithAPIKey("key-1"))
+	accounts.Key(newRequestWithAPIKey("key-2"))
+	accounts.Key(newRequestWithAPIKey("key-1"))
+	accounts.Key(newRequestWithAPIKey("key-3"))
+	accounts.Key(newRequestWithAPIKey("key-1"))
+	if calls := resolver.calls.Load(); calls != 3 {
+		t.Errorf("expected key-1 to stay cached; got %d calls", calls)
+	}
+	accounts.Key(newRequestWithAPIKey("key-2"))
+	if calls := resolver.calls.Load(); calls != 4 {
+		t.Errorf("expected key-2 to be evicted; got %d calls", calls)
+	}
+}
+
+// Test concurrent requests share a single call to the resolver
+func TestAPIKeyResolverSharesCalls(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	resolve := func(_ context.Context, apiKey string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "account-1", nil
+	}
+	accounts := NewAPIKeyResolver(KeyByHeader("X-API-Key"), resolve, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key, err := accounts.Key(newRequestWithAPIKey("key-1")); err != nil || key != "account-1" {
+				t.Errorf("expected key account-1; got %q, %v", key, err)
+			}
+		}()
+	}
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 call to the resolver; got %d", n)
+	}
+}
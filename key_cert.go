@@ -0,0 +1,73 @@
+package cerberus
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// verifiedClientCert returns the leaf of the first verified chain of the TLS client certificate
+// of r. An error wrapping [ErrNoKey] is returned if the request was not made over TLS, or if its
+// client did not present a certificate that the server verified.
+func verifiedClientCert(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, fmt.Errorf("%w: no verified client certificate", ErrNoKey)
+	}
+	return r.TLS.VerifiedChains[0][0], nil
+}
+
+// KeyByCertCommonName is a [KeyFunc] that keys requests by the common name of the subject of the
+// verified TLS client certificate of their client, such as "billing-service", so that each
+// workload of a mutual TLS deployment has its own limit wherever it connects from.
+//
+// Only certificates verified by the server count, which requires the ClientAuth setting of its
+// [crypto/tls.Config] to be [crypto/tls.VerifyClientCertIfGiven] or
+// [crypto/tls.RequireAndVerifyClientCert]. Requests without one, or whose certificate has no
+// common name, are rejected with an error wrapping [ErrNoKey]. Behind a proxy terminating TLS,
+// key requests by the identity it forwards instead, such as with [KeyByHeader].
+func KeyByCertCommonName(r *http.Request) (string, error) {
+	cert, err := verifiedClientCert(r)
+	if err != nil {
+		return "", err
+	}
+	if cert.Subject.CommonName == "" {
+		return "", fmt.Errorf("%w: client certificate without common name", ErrNoKey)
+	}
+	return cert.Subject.CommonName, nil
+}
+
+// KeyByCertDNSName is a [KeyFunc] that keys requests by the first DNS name in the subject
+// alternative names of the verified TLS client certificate of their client, such as
+// "billing.internal.example.com". Requests without a verified client certificate, or whose
+// certificate has no DNS name, are rejected with an error wrapping [ErrNoKey]. See
+// [KeyByCertCommonName] for the configuration of the server.
+func KeyByCertDNSName(r *http.Request) (string, error) {
+	cert, err := verifiedClientCert(r)
+	if err != nil {
+		return "", err
+	}
+	if len(cert.DNSNames) == 0 {
+		return "", fmt.Errorf("%w: client certificate without DNS name", ErrNoKey)
+	}
+	return cert.DNSNames[0], nil
+}
+
+// KeyBySPIFFEID is a [KeyFunc] that keys requests by the SPIFFE ID of their client, such as
+// "spiffe://example.org/ns/billing/sa/api", found in the URI subject alternative name of the
+// X.509 SVID it presented as its verified TLS client certificate. It suits service meshes, such as
+// those of Istio or SPIRE, in which workloads are identified by SPIFFE IDs rather than by
+// addresses. Requests without a verified client certificate, or whose certificate has no SPIFFE
+// ID, are rejected with an error wrapping [ErrNoKey]. See [KeyByCertCommonName] for the
+// configuration of the server.
+func KeyBySPIFFEID(r *http.Request) (string, error) {
+	cert, err := verifiedClientCert(r)
+	if err != nil {
+		return "", err
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%w: client certificate without SPIFFE ID", ErrNoKey)
+}
@@ -0,0 +1,81 @@
+package cerberus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newRequestWithCert returns a request whose client presented cert, verified by the server.
+func newRequestWithCert(cert *x509.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
+// Test the certificate key functions extract the identity of the workload
+func TestKeyByClientCert(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/billing/sa/api")
+	website, _ := url.Parse("https://billing.example.org")
+	req := newRequestWithCert(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "billing-service"},
+		DNSNames: []string{"billing.internal.example.com", "billing"},
+		URIs:     []*url.URL{website, spiffeID},
+	})
+
+	for name, test := range map[string]struct {
+		keyFunc KeyFunc
+		want    string
+	}{
+		"common name": {KeyByCertCommonName, "billing-service"},
+		"DNS name":    {KeyByCertDNSName, "billing.internal.example.com"},
+		"SPIFFE ID":   {KeyBySPIFFEID, "spiffe://example.org/ns/billing/sa/api"},
+	} {
+		if key, err := test.keyFunc(req); err != nil || key != test.want {
+			t.Errorf("%s: expected key %q; got %q, %v", name, test.want, key, err)
+		}
+	}
+}
+
+// Test requests without a verified certificate, or without the identity, have no key
+func TestKeyByClientCertMissing(t *testing.T) {
+	unverified := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	unverified.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "forged"}}}}
+	anonymous := newRequestWithCert(&x509.Certificate{})
+
+	for name, req := range map[string]*http.Request{
+		"plain HTTP": httptest.NewRequest(http.MethodGet, "/", nil),
+		"unverified": unverified,
+		"anonymous":  anonymous,
+	} {
+		for _, keyFunc := range []KeyFunc{KeyByCertCommonName, KeyByCertDNSName, KeyBySPIFFEID} {
+			if _, err := keyFunc(req); !errors.Is(err, ErrNoKey) {
+				t.Errorf("%s: expected ErrNoKey; got %v", name, err)
+			}
+		}
+	}
+}
+
+// Test certificate identities can be selected by the key setting of a config
+func TestParseKeyFuncClientCert(t *testing.T) {
+	req := newRequestWithCert(&x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}})
+
+	keyFunc, err := parseKeyFunc("{cert_cn}:{path}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key, err := keyFunc(req); err != nil || key != "billing-service:/" {
+		t.Errorf("expected key billing-service:/; got %q, %v", key, err)
+	}
+	if _, err := parseKeyFunc("spiffe_id"); err != nil {
+		t.Errorf("expected spiffe_id to be a valid key; got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package cerberus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KeyComposite returns a [KeyFunc] that keys requests by the keys of all of keyFuncs, joined with
+// "|", so that each combination of their values has its own limit, such as each endpoint of each
+// tenant with KeyComposite(KeyByHeader("X-Tenant-ID"), KeyByPath). Requests for which any of
+// keyFuncs returns an error are rejected with that error.
+func KeyComposite(keyFuncs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		keys := make([]string, len(keyFuncs))
+		for i, keyFunc := range keyFuncs {
+			key, err := keyFunc(r)
+			if err != nil {
+				return "", err
+			}
+			keys[i] = key
+		}
+		return strings.Join(keys, "|"), nil
+	}
+}
+
+// KeyByTemplate returns a [KeyFunc] that keys requests by template, in which each placeholder in
+// braces is replaced by an attribute of the request, such as "{ip}:{path}:{hash:header:User-Agent}"
+// to limit each client per endpoint and user agent.
+//
+// Behavior:
+//   - Placeholders are the key settings of a [RouteConfig]: "ip", "forwarded_for", "path",
+//     "cert_cn", "cert_dns", "spiffe_id", "header:NAME", "cookie:NAME", and "query:NAME", as well
+//     as "method" and "host" for the method and the host of the request.
+//   - A placeholder prefixed with "hash:" is replaced by a short hash of its value, which keeps
+//     keys short and free of separators when the value is long or controlled by the client, such
+//     as a User-Agent header.
+//   - A placeholder suffixed with "?" is replaced by an empty string if the request lacks the
+//     attribute. Otherwise, requests lacking it are rejected with an error wrapping [ErrNoKey].
+//   - Text outside braces is kept as is.
+//
+// It panics if template has an unknown placeholder or unbalanced braces.
+//
+// Example usage: NewTokenBucketLimiter(10, 20, WithKeyFunc(KeyByTemplate("{header:X-Tenant-ID}:{method}:{path}")))
+func KeyByTemplate(template string) KeyFunc {
+	keyFunc, err := parseKeyTemplate(template)
+	if err != nil {
+		panic(err.Error())
+	}
+	return keyFunc
+}
+
+// parseKeyTemplate returns the [KeyFunc] of [KeyByTemplate] for template. An error wrapping
+// [ErrInvalidConfig] is returned if template is malformed.
+func parseKeyTemplate(template string) (KeyFunc, error) {
+	var parts []KeyFunc
+	for rest := template; rest != ""; {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			parts = append(parts, literalKey(rest))
+			break
+		}
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("%w: unbalanced braces in key template %q", ErrInvalidConfig, template)
+		}
+		if start > 0 {
+			parts = append(parts, literalKey(rest[:start]))
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unbalanced braces in key template %q", ErrInvalidConfig, template)
+		}
+		part, err := parsePlaceholder(rest[start+1 : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("%w in key template %q", err, template)
+		}
+		parts = append(parts, part)
+		rest = rest[start+end+1:]
+	}
+	return func(r *http.Request) (string, error) {
+		var key strings.Builder
+		for _, part := range parts {
+			value, err := part(r)
+			if err != nil {
+				return "", err
+			}
+			key.WriteString(value)
+		}
+		return key.String(), nil
+	}, nil
+}
+
+// parsePlaceholder returns the [KeyFunc] replacing a placeholder of a key template.
+func parsePlaceholder(placeholder string) (KeyFunc, error) {
+	spec, optional := strings.CutSuffix(placeholder, "?")
+	spec, hashed := strings.CutPrefix(spec, "hash:")
+	var keyFunc KeyFunc
+	switch spec {
+	case "":
+		return nil, fmt.Errorf("%w: empty placeholder", ErrInvalidConfig)
+	case "method":
+		keyFunc = func(r *http.Request) (string, error) { return r.Method, nil }
+	case "host":
+		keyFunc = KeyByHost
+	default:
+		var err error
+		if keyFunc, err = parseKeyFunc(spec); err != nil || strings.Contains(spec, "{") {
+			return nil, fmt.Errorf("%w: unknown placeholder %q", ErrInvalidConfig, placeholder)
+		}
+	}
+	return func(r *http.Request) (string, error) {
+		value, err := keyFunc(r)
+		if err != nil {
+			if optional && errors.Is(err, ErrNoKey) {
+				return "", nil
+			}
+			return "", err
+		}
+		if hashed {
+			sum := sha256.Sum256([]byte(value))
+			return hex.EncodeToString(sum[:8]), nil
+		}
+		return value, nil
+	}, nil
+}
+
+// literalKey returns a [KeyFunc] returning s, the text of a key template outside placeholders.
+func literalKey(s string) KeyFunc {
+	return func(*http.Request) (string, error) {
+		return s, nil
+	}
+}
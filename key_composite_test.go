@@ -0,0 +1,67 @@
+package cerberus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test composite keys join the keys of their key functions and fail with any of them
+func TestKeyComposite(t *testing.T) {
+	keyFunc := KeyComposite(KeyByHeader("X-Tenant-ID"), KeyByPath)
+	r := httptest.NewRequest("GET", "/orders", nil)
+
+	if _, err := keyFunc(r); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey; got %v", err)
+	}
+	r.Header.Set("X-Tenant-ID", "acme")
+	if key, err := keyFunc(r); key != "acme|/orders" || err != nil {
+		t.Errorf("expected acme|/orders; got %q, %v", key, err)
+	}
+}
+
+// Test templates replace placeholders by the attributes of requests
+func TestKeyByTemplate(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://api.example.com/orders?region=eu", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "curl/8.4.0")
+	userAgentHash := sha256.Sum256([]byte("curl/8.4.0"))
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{ip}:{path}", "192.0.2.1:/orders"},
+		{"{method} {host}{path}", "POST api.example.com/orders"},
+		{"{query:region}/{header:X-Tenant-ID?}", "eu/"},
+		{"ua:{hash:header:User-Agent}", "ua:" + hex.EncodeToString(userAgentHash[:8])},
+		{"static", "static"},
+	}
+	for _, test := range tests {
+		key, err := KeyByTemplate(test.template)(r)
+		if key != test.want || err != nil {
+			t.Errorf("%s: expected %q; got %q, %v", test.template, test.want, key, err)
+		}
+	}
+
+	if _, err := KeyByTemplate("{ip}:{header:X-Tenant-ID}")(r); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey for a missing attribute; got %v", err)
+	}
+}
+
+// Test malformed templates are rejected
+func TestKeyByTemplateInvalid(t *testing.T) {
+	for _, template := range []string{"{ip", "ip}", "{}", "{jwt:sub}", "{header:{ip}}"} {
+		if _, err := parseKeyTemplate(template); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig; got %v", template, err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected KeyByTemplate to panic")
+		}
+	}()
+	KeyByTemplate("{ip")
+}
@@ -0,0 +1,155 @@
+package cerberus
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BrowserIDResolver keys the requests of anonymous browsers by an ID it issues to each of them in
+// a signed cookie, so that browsers keep their own limit when their IP address changes, and users
+// sharing an IP address, such as behind carrier-grade NAT or a corporate proxy, do not share a
+// limit.
+//
+// Behavior:
+//   - [BrowserIDResolver.Middleware] issues a cookie holding a random ID, the time it was issued
+//     at, and an HMAC-SHA256 of both, to every request without a valid cookie.
+//   - [BrowserIDResolver.Key] keys requests with a valid cookie by its ID, and other requests, such
+//     as the first request of a browser or those of clients that do not keep cookies, with the
+//     fallback [KeyFunc].
+//   - Cookies whose signature does not match, or that were issued more than maxAge ago, are
+//     ignored, and replaced by the middleware.
+//
+// Clients can collect many cookies, one per request keyed with the fallback, and spread their
+// requests over them. Chain the limit of browsers with a looser limit per IP address with a
+// [ChainLimiter] to bound the total of such clients.
+//
+// A BrowserIDResolver is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	browsers := NewBrowserIDResolver("__rl_id", nil, 30*24*time.Hour, secret)
+//	limiter := NewChainLimiter(
+//		NewTokenBucketLimiter(20, 2, WithKeyFunc(browsers.Key)),
+//		NewTokenBucketLimiter(500, 50),
+//	)
+//	http.Handle("/", browsers.Middleware(New(limiter)(myHandler)))
+type BrowserIDResolver struct {
+	name     string
+	fallback KeyFunc
+	maxAge   time.Duration
+	secrets  [][]byte
+
+	now func() time.Time
+}
+
+// NewBrowserIDResolver creates a new [BrowserIDResolver] issuing cookies with the given name,
+// valid for maxAge, and signed with the first of secrets. Cookies signed with any of secrets are
+// valid, so that secrets can be rotated. Requests without a valid cookie are keyed with fallback,
+// or with [KeyByIP] if fallback is nil.
+//
+// It panics if name is empty, if maxAge is not positive, if no secret is given, or if a secret is
+// shorter than 16 bytes.
+func NewBrowserIDResolver(name string, fallback KeyFunc, maxAge time.Duration, secrets ...[]byte) *BrowserIDResolver {
+	if name == "" {
+		panic("cerberus: browser ID cookie name must not be empty")
+	}
+	if maxAge <= 0 {
+		panic("cerberus: browser ID max age must be positive")
+	}
+	if len(secrets) == 0 {
+		panic("cerberus: at least one browser ID secret is required")
+	}
+	for _, secret := range secrets {
+		if len(secret) < minSecretSize {
+			panic("cerberus: browser ID secret must be at least 16 bytes")
+		}
+	}
+	if fallback == nil {
+		fallback = KeyByIP
+	}
+	return &BrowserIDResolver{name: name, fallback: fallback, maxAge: maxAge, secrets: secrets, now: time.Now}
+}
+
+// Key is a [KeyFunc] that keys requests by the ID in their cookie, if it is valid, and with the
+// fallback KeyFunc otherwise.
+func (b *BrowserIDResolver) Key(r *http.Request) (string, error) {
+	if id, ok := b.browserID(r); ok {
+		return id, nil
+	}
+	return b.fallback(r)
+}
+
+// Middleware returns a handler issuing a cookie with a new ID to requests without a valid cookie,
+// before calling next. It must wrap the rate limiting middleware, so that the cookie is issued
+// even to requests that are denied. The cookie is HttpOnly, is sent with top-level navigations
+// from other sites, and is Secure on requests made over TLS.
+func (b *BrowserIDResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := b.browserID(r); !ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:     b.name,
+				Value:    b.issue(),
+				Path:     "/",
+				MaxAge:   int(b.maxAge / time.Second),
+				Secure:   r.TLS != nil,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// browserID returns the ID in the cookie of r, and whether the cookie is valid.
+func (b *BrowserIDResolver) browserID(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(b.name)
+	if err != nil {
+		return "", false
+	}
+	payload, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return "", false
+	}
+	id, timestamp, ok := strings.Cut(payload, "-")
+	if !ok || id == "" {
+		return "", false
+	}
+	issuedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	if age := b.now().Sub(time.Unix(issuedAt, 0)); age > b.maxAge || age < -time.Minute {
+		return "", false
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", false
+	}
+	for _, secret := range b.secrets {
+		if hmac.Equal(mac, browserIDMAC(secret, payload)) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// issue returns the value of a cookie holding a new ID, issued now.
+func (b *BrowserIDResolver) issue() string {
+	var id [16]byte
+	rand.Read(id[:])
+	payload := hex.EncodeToString(id[:]) + "-" + strconv.FormatInt(b.now().Unix(), 10)
+	return payload + "." + hex.EncodeToString(browserIDMAC(b.secrets[0], payload))
+}
+
+// browserIDMAC returns the HMAC-SHA256, keyed with secret, of the payload of a browser ID cookie.
+func browserIDMAC(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
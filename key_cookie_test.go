@@ -0,0 +1,114 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// browserIDSecret is a secret signing browser ID cookies in tests.
+var browserIDSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// issueBrowserID returns the cookie issued by the middleware of browsers to a request without one.
+func issueBrowserID(t *testing.T, browsers *BrowserIDResolver) *http.Cookie {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	browsers.Middleware(noContent).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a cookie to be issued; got %v", cookies)
+	}
+	return cookies[0]
+}
+
+// Test browsers are keyed by their cookie across IP addresses, and by IP address without one
+func TestBrowserIDResolverKey(t *testing.T) {
+	browsers := NewBrowserIDResolver("__rl_id", nil, time.Hour, browserIDSecret)
+	cookie := issueBrowserID(t, browsers)
+	if !cookie.HttpOnly || cookie.MaxAge != 3600 || cookie.Path != "/" {
+		t.Errorf("expected an HttpOnly cookie for an hour; got %+v", cookie)
+	}
+
+	var keys []string
+	for _, addr := range []string{"192.0.2.1:1234", "198.51.100.7:4321"} {
+		req := newRequestFrom(addr)
+		req.AddCookie(cookie)
+		key, err := browsers.Key(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if keys[0] != keys[1] || keys[0] == "192.0.2.1" || !strings.HasPrefix(cookie.Value, keys[0]) {
+		t.Errorf("expected the key to be the ID of the cookie; got %v", keys)
+	}
+	if key, _ := browsers.Key(newRequestFrom("192.0.2.1:1234")); key != "192.0.2.1" {
+		t.Errorf("expected requests without a cookie to be keyed by IP address; got %v", key)
+	}
+	if other := issueBrowserID(t, browsers); other.Value == cookie.Value {
+		t.Errorf("expected each browser to get its own ID")
+	}
+}
+
+// Test the middleware does not replace valid cookies
+func TestBrowserIDResolverKeepsValidCookie(t *testing.T) {
+	browsers := NewBrowserIDResolver("__rl_id", nil, time.Hour, browserIDSecret)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(issueBrowserID(t, browsers))
+
+	rr := httptest.NewRecorder()
+	browsers.Middleware(noContent).ServeHTTP(rr, req)
+	if cookies := rr.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no new cookie; got %v", cookies)
+	}
+}
+
+// Test forged, expired, and malformed cookies fall back to the IP address
+func TestBrowserIDResolverRejectsInvalidCookies(t *testing.T) {
+	clock := newFakeClock()
+	browsers := NewBrowserIDResolver("__rl_id", nil, time.Hour, browserIDSecret)
+	browsers.now = clock.Now
+	forger := NewBrowserIDResolver("__rl_id", nil, time.Hour, []byte("fedcba9876543210fedcba9876543210"))
+	valid := browsers.issue()
+	id, rest, _ := strings.Cut(valid, "-")
+	tampered := strings.Repeat("0", len(id)) + "-" + rest
+
+	clock.Advance(time.Minute)
+	for name, value := range map[string]string{
+		"forged":    forger.issue(),
+		"tampered":  tampered,
+		"malformed": "not-a-cookie",
+		"bad hex":   strings.SplitN(valid, ".", 2)[0] + ".zz",
+	} {
+		req := newRequestFrom("192.0.2.1:1234")
+		req.AddCookie(&http.Cookie{Name: "__rl_id", Value: value})
+		if key, _ := browsers.Key(req); key != "192.0.2.1" {
+			t.Errorf("%s: expected the cookie to be ignored; got key %v", name, key)
+		}
+	}
+
+	req := newRequestFrom("192.0.2.1:1234")
+	req.AddCookie(&http.Cookie{Name: "__rl_id", Value: valid})
+	if key, _ := browsers.Key(req); key != id {
+		t.Errorf("expected the valid cookie to be accepted; got key %v", key)
+	}
+	clock.Advance(time.Hour)
+	if key, _ := browsers.Key(req); key != "192.0.2.1" {
+		t.Errorf("expected the expired cookie to be ignored; got key %v", key)
+	}
+}
+
+// Test cookies signed with a previous secret stay valid during a rotation
+func TestBrowserIDResolverRotation(t *testing.T) {
+	previous := NewBrowserIDResolver("__rl_id", nil, time.Hour, browserIDSecret)
+	newSecret := []byte("fedcba9876543210fedcba9876543210")
+	browsers := NewBrowserIDResolver("__rl_id", nil, time.Hour, newSecret, browserIDSecret)
+
+	req := newRequestFrom("192.0.2.1:1234")
+	req.AddCookie(issueBrowserID(t, previous))
+	if key, _ := browsers.Key(req); key == "192.0.2.1" {
+		t.Errorf("expected a cookie signed with the previous secret to be accepted")
+	}
+}
@@ -0,0 +1,78 @@
+package cerberus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TokenVerifier verifies the signature and validity of a JSON Web Token, such as its expiration
+// time, and returns an error if the token must not be trusted. It receives the token in its compact
+// serialization, as sent in the Authorization header. Verifiers are usually thin wrappers around a
+// JWT library holding the keys of the issuer.
+type TokenVerifier func(token string) error
+
+// KeyByJWTClaim returns a [KeyFunc] that keys requests by a claim of the JSON Web Token they carry
+// as a bearer token in the Authorization header, such as "sub" or "tenant_id", so that
+// authenticated APIs are limited per identity rather than per IP address.
+//
+// Behavior:
+//   - The token is verified with verify before its claims are read. If verify is nil, the token is
+//     not verified, which is only safe behind a gateway that rejects invalid tokens: clients could
+//     otherwise forge tokens to pick their own key.
+//   - String claims are used as is, and numeric claims are formatted as in the token. Claims of
+//     other types are ignored.
+//   - Requests without a bearer token, with a malformed or rejected token, or without the claim
+//     are rejected with an error wrapping [ErrNoKey].
+//
+// Example usage: NewTokenBucketLimiter(10, 1, WithKeyFunc(KeyByJWTClaim("sub", myVerifier)))
+func KeyByJWTClaim(claim string, verify TokenVerifier) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", fmt.Errorf("%w: missing bearer token", ErrNoKey)
+		}
+		if verify != nil {
+			if err := verify(token); err != nil {
+				return "", fmt.Errorf("%w: invalid bearer token: %w", ErrNoKey, err)
+			}
+		}
+		claims, err := jwtClaims(token)
+		if err != nil {
+			return "", fmt.Errorf("%w: malformed bearer token: %w", ErrNoKey, err)
+		}
+		switch value := claims[claim].(type) {
+		case string:
+			if value != "" {
+				return value, nil
+			}
+		case json.Number:
+			return value.String(), nil
+		}
+		return "", fmt.Errorf("%w: missing claim %q", ErrNoKey, claim)
+	}
+}
+
+// jwtClaims decodes the claims of a JSON Web Token in compact serialization, without verifying it.
+// Numbers are decoded as [json.Number], so that large identifiers keep their precision.
+func jwtClaims(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("expected 3 parts; got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]any
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
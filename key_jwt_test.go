@@ -0,0 +1,73 @@
+package cerberus
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newJWT returns an unsigned token carrying the given claims.
+func newJWT(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + ".sig"
+}
+
+func newRequestWithAuthorization(authorization string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Authorization", authorization)
+	return req
+}
+
+// Test string and numeric claims are used as keys
+func TestKeyByJWTClaim(t *testing.T) {
+	req := newRequestWithAuthorization("Bearer " + newJWT(`{"sub":"user-1","tenant_id":12345678901234567890}`))
+
+	if key, err := KeyByJWTClaim("sub", nil)(req); err != nil || key != "user-1" {
+		t.Errorf("expected key user-1; got %q, %v", key, err)
+	}
+	if key, err := KeyByJWTClaim("tenant_id", nil)(req); err != nil || key != "12345678901234567890" {
+		t.Errorf("expected key 12345678901234567890; got %q, %v", key, err)
+	}
+}
+
+// Test requests without a usable claim are rejected with ErrNoKey
+func TestKeyByJWTClaimMissing(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"no header", ""},
+		{"basic auth", "Basic dXNlcjpwYXNz"},
+		{"malformed token", "Bearer not-a-token"},
+		{"malformed payload", "Bearer a.!!!.c"},
+		{"missing claim", "Bearer " + newJWT(`{"iss":"issuer"}`)},
+		{"object claim", "Bearer " + newJWT(`{"sub":{"id":1}}`)},
+	}
+	for _, test := range tests {
+		_, err := KeyByJWTClaim("sub", nil)(newRequestWithAuthorization(test.authorization))
+		if !errors.Is(err, ErrNoKey) {
+			t.Errorf("%s: expected ErrNoKey; got %v", test.name, err)
+		}
+	}
+}
+
+// Test tokens are verified before their claims are used
+func TestKeyByJWTClaimVerifies(t *testing.T) {
+	errExpired := errors.New("token expired")
+	var verified string
+	verify := func(token string) error {
+		verified = token
+		return errExpired
+	}
+	token := newJWT(`{"sub":"user-1"}`)
+
+	_, err := KeyByJWTClaim("sub", verify)(newRequestWithAuthorization("bearer " + token))
+	if !errors.Is(err, ErrNoKey) || !errors.Is(err, errExpired) {
+		t.Errorf("expected ErrNoKey wrapping the verifier error; got %v", err)
+	}
+	if verified != token {
+		t.Errorf("expected the verifier to receive the token; got %q", verified)
+	}
+}
@@ -0,0 +1,98 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test the built-in key functions extract the expected attributes
+func TestKeyFuncs(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/items?token=abc", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.1")
+	req.Header.Set("X-API-Key", "key-1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s-1"})
+
+	tests := []struct {
+		name    string
+		keyFunc KeyFunc
+		want    string
+	}{
+		{"ip", KeyByIP, "192.0.2.1"},
+		{"forwarded for", KeyByForwardedFor, "203.0.113.7"},
+		{"path", KeyByPath, "/api/items"},
+		{"host", KeyByHost, "example.com"},
+		{"header", KeyByHeader("X-API-Key"), "key-1"},
+		{"cookie", KeyByCookie("session"), "s-1"},
+		{"query param", KeyByQueryParam("token"), "abc"},
+	}
+	for _, test := range tests {
+		key, err := test.keyFunc(req)
+		if err != nil || key != test.want {
+			t.Errorf("%s: expected key %q; got %q, %v", test.name, test.want, key, err)
+		}
+	}
+}
+
+// Test KeyByForwardedFor falls back to the connection address
+func TestKeyByForwardedForFallsBackToRemoteAddr(t *testing.T) {
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-Forwarded-For", "not-an-ip")
+
+	if key, _ := KeyByForwardedFor(req); key != "192.0.2.1" {
+		t.Errorf("expected key 192.0.2.1; got %q", key)
+	}
+}
+
+// Test key functions report missing attributes with ErrNoKey
+func TestKeyFuncsMissingAttribute(t *testing.T) {
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for _, keyFunc := range []KeyFunc{KeyByHeader("X-API-Key"), KeyByCookie("session"), KeyByQueryParam("token")} {
+		if _, err := keyFunc(req); !errors.Is(err, ErrNoKey) {
+			t.Errorf("expected ErrNoKey; got %v", err)
+		}
+	}
+}
+
+// Test built-in limiters use the configured key function
+func TestLimitersUseKeyFunc(t *testing.T) {
+	keyFunc := WithKeyFunc(KeyByHeader("X-API-Key"))
+	limiters := map[string]AdvancedRateLimiter{
+		"token bucket":   NewTokenBucketLimiter(1, 1, keyFunc),
+		"sliding window": NewSlidingWindowLimiter(1, 1, keyFunc),
+		"fixed window":   NewFixedWindowLimiter(1, 1, keyFunc),
+		"leaky bucket":   NewLeakyBucketLimiter(1, 1, keyFunc),
+		"gcra":           NewGCRALimiter(1, 0, keyFunc),
+	}
+	for name, limiter := range limiters {
+		first := newRequestFrom("192.0.2.1:1234")
+		first.Header.Set("X-API-Key", "key-1")
+		second := newRequestFrom("192.0.2.1:1234")
+		second.Header.Set("X-API-Key", "key-2")
+
+		limiter.IsAllowed(first)
+		if isAllowed, _ := limiter.IsAllowed(second); !isAllowed {
+			t.Errorf("%s: expected request with another key to be allowed", name)
+		}
+		if _, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234")); !errors.Is(err, ErrNoKey) {
+			t.Errorf("%s: expected ErrNoKey; got %v", name, err)
+		}
+	}
+}
+
+// Test KeyOf reports the key of built-in limiters and ErrNoKey for other rate limiters
+func TestKeyOf(t *testing.T) {
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-API-Key", "key-1")
+
+	key, err := KeyOf(NewTokenBucketLimiter(1, 1, WithKeyFunc(KeyByHeader("X-API-Key"))), req)
+	if err != nil || key != "key-1" {
+		t.Errorf("expected key-1; got %q, %v", key, err)
+	}
+	if _, err := KeyOf(&MockRateLimiter{}, req); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey; got %v", err)
+	}
+}
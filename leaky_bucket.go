@@ -0,0 +1,292 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// leakyBucketPrefix prefixes the store keys of the buckets of a [LeakyBucketLimiter].
+const leakyBucketPrefix = "leaky_bucket:"
+
+// LeakyBucketLimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the leaky
+// bucket algorithm. Every client, identified by its key (its IP address by default), is assigned
+// a bucket that fills up by one unit per allowed request and leaks at a constant rate. A request
+// is allowed only if it fits into the bucket without overflowing it.
+//
+// Because the bucket drains at a constant rate, the sustained throughput of each client is capped
+// at the leak rate, and the capacity bounds how many requests may be admitted back to back. With
+// a capacity of one, requests are admitted at most once per drain interval, which smooths traffic
+// forwarded to downstream services rather than passing bursts through.
+//
+// Buckets are kept in the limiter's [Store] and expire once they have drained completely.
+//
+// A LeakyBucketLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", AdvancedMiddleware(NewLeakyBucketLimiter(5, 10), myHandler))
+type LeakyBucketLimiter struct {
+	capacity float64
+	leakRate float64
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+}
+
+// leakyBucket holds the state of a single client's bucket.
+type leakyBucket struct {
+	level float64
+	last  time.Time
+}
+
+// NewLeakyBucketLimiter creates a new [LeakyBucketLimiter] where each bucket holds up to capacity
+// requests and leaks at leakRate requests per second. Buckets start empty.
+//
+// It panics if capacity or leakRate is not positive.
+func NewLeakyBucketLimiter(capacity int, leakRate float64, opts ...LimiterOption) *LeakyBucketLimiter {
+	if capacity <= 0 {
+		panic("cerberus: leaky bucket capacity must be positive")
+	}
+	if leakRate <= 0 {
+		panic("cerberus: leaky bucket leak rate must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &LeakyBucketLimiter{
+		capacity:  float64(capacity),
+		leakRate:  leakRate,
+		keyFunc:   options.keyFunc,
+		store:     options.store,
+		costFunc:  options.costFunc,
+		policy:    options.policy,
+		now:       options.clock.Now,
+		clock:     options.clock,
+		topDenied: options.topDenied,
+	}
+}
+
+// IsAllowed adds the request to the bucket of the client making it, filling it by the cost of the
+// request. It returns true if the request fit into the bucket, false otherwise. An error is
+// returned if no key can be derived from the request or the store fails.
+func (l *LeakyBucketLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *LeakyBucketLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.take(ctx, r, false)
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *LeakyBucketLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	value, err := l.store.Get(ctx, leakyBucketPrefix+key)
+	if err != nil {
+		return false, err
+	}
+	bucket, err := l.leak(key, value)
+	if err != nil {
+		return false, err
+	}
+	return bucket.level+float64(requestCost(l.costFunc, r)) <= l.capacity, nil
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *LeakyBucketLimiter) Commit(ctx context.Context, r *http.Request) error {
+	_, err := l.take(ctx, r, true)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. A bucket emptied by
+// the refund is deleted, since it is the bucket of a new client. An error is returned if no key can
+// be derived from the request or the store fails.
+func (l *LeakyBucketLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	cost := float64(requestCost(l.costFunc, r))
+	return modifyOrDelete(ctx, l.store, leakyBucketPrefix+key, func(value []byte) ([]byte, time.Duration, bool, error) {
+		if value == nil {
+			return nil, 0, false, nil
+		}
+		bucket, err := l.leak(key, value)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		bucket.level = math.Max(0, bucket.level-cost)
+		return bucket.encode(), l.ttl(bucket), bucket.level == 0, nil
+	})
+}
+
+// take counts the request if it is allowed, or regardless if force is true, and reports whether
+// it was allowed.
+func (l *LeakyBucketLimiter) take(ctx context.Context, r *http.Request, force bool) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	cost := float64(requestCost(l.costFunc, r))
+	var isAllowed bool
+	err = modify(ctx, l.store, leakyBucketPrefix+key, func(value []byte) ([]byte, time.Duration, error) {
+		bucket, err := l.leak(key, value)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Requests costing nothing are not written, so that they do not keep empty buckets alive.
+		if isAllowed = bucket.level+cost <= l.capacity; cost == 0 || !isAllowed && !force {
+			return nil, 0, nil
+		}
+		bucket.level += cost
+		return bucket.encode(), l.ttl(bucket), nil
+	})
+	if !isAllowed && !force && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// GetRateLimitData returns the current state of the bucket of the client making the request. Limit
+// is the capacity of the bucket, Remaining is the number of requests that still fit into it, and
+// RetryAfter is the time until enough has drained to make room for the request if the bucket is too
+// full. The zero RateLimitData is returned if no key can be derived from the request or the store
+// fails.
+func (l *LeakyBucketLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, requestCost(l.costFunc, r))
+	return data
+}
+
+// Rate returns the sustained rate of the limiter, the number of requests leaking out of each
+// bucket per second.
+func (l *LeakyBucketLimiter) Rate() float64 {
+	return l.leakRate
+}
+
+// Burst returns the burst of the limiter, the number of requests each bucket holds when full.
+func (l *LeakyBucketLimiter) Burst() int {
+	return int(l.capacity)
+}
+
+// Usage returns the current state of the bucket of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *LeakyBucketLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *LeakyBucketLimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, leakyBucketPrefix+key)
+}
+
+// Block fills the bucket of the client identified by key beyond its capacity with d worth of
+// leakage, so that its requests are denied for d. An error is returned if the store fails.
+func (l *LeakyBucketLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	bucket := leakyBucket{level: l.capacity + d.Seconds()*l.leakRate, last: l.now()}
+	return l.store.Set(ctx, leakyBucketPrefix+key, bucket.encode(), l.ttl(bucket))
+}
+
+// Keys returns the keys of the clients the limiter is tracking, in no particular order. Clients
+// whose state has expired are back to a full budget and are not listed. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (l *LeakyBucketLimiter) Keys(ctx context.Context) ([]string, error) {
+	return scanClientKeys(ctx, l.store, leakyBucketPrefix)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *LeakyBucketLimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *LeakyBucketLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost.
+func (l *LeakyBucketLimiter) rateLimitData(ctx context.Context, key string, cost int) (RateLimitData, error) {
+	value, err := l.store.Get(ctx, leakyBucketPrefix+key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	bucket, err := l.leak(key, value)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	data := RateLimitData{
+		Limit:     int(l.capacity),
+		Remaining: int(math.Floor(math.Max(l.capacity-bucket.level, 0))),
+		Window:    time.Duration(l.capacity / l.leakRate * float64(time.Second)),
+		Policy:    l.policy,
+		Rate:      l.leakRate,
+		Burst:     int(l.capacity),
+		ResetAt:   bucket.last.Add(time.Duration(bucket.level / l.leakRate * float64(time.Second))),
+	}
+	if cost := float64(cost); bucket.level+cost-l.capacity > 0 && cost <= l.capacity {
+		data.RetryAfter = time.Duration((bucket.level + cost - l.capacity) / l.leakRate * float64(time.Second))
+	}
+	return data, nil
+}
+
+// leak decodes the bucket stored under key and drains what leaked since it was last drained.
+// A nil value yields an empty bucket.
+func (l *LeakyBucketLimiter) leak(key string, value []byte) (leakyBucket, error) {
+	now := l.now()
+	if value == nil {
+		return leakyBucket{last: now}, nil
+	}
+	fields, ok := decodeUint64s(value)
+	if !ok || len(fields) != 2 {
+		return leakyBucket{}, fmt.Errorf("%w: %q is not a leaky bucket", ErrMalformedValue, key)
+	}
+	bucket := leakyBucket{
+		level: math.Float64frombits(fields[0]),
+		last:  time.Unix(0, int64(fields[1])),
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.level = math.Max(0, bucket.level-elapsed.Seconds()*l.leakRate)
+		bucket.last = now
+	}
+	return bucket, nil
+}
+
+// ttl returns the time until bucket has drained completely, after which keeping it in the store
+// is pointless. It is at least the time a request takes to leak, since a TTL of zero would keep the
+// bucket forever.
+func (l *LeakyBucketLimiter) ttl(bucket leakyBucket) time.Duration {
+	return max(time.Duration(bucket.level/l.leakRate*float64(time.Second)), time.Duration(float64(time.Second)/l.leakRate))
+}
+
+// encode serializes the bucket for storage.
+func (b leakyBucket) encode() []byte {
+	return encodeUint64s(math.Float64bits(b.level), uint64(b.last.UnixNano()))
+}
+
+// key returns the key identifying the client making the request.
+func (l *LeakyBucketLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *LeakyBucketLimiter) timeSource() Clock {
+	return l.clock
+}
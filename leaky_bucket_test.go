@@ -0,0 +1,52 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the bucket admits requests up to its capacity
+func TestLeakyBucketLimiterAllowsUpToCapacity(t *testing.T) {
+	limiter := NewLeakyBucketLimiter(2, 1)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request overflowing the bucket to be denied")
+	}
+}
+
+// Test a bucket with a capacity of one spaces requests evenly
+func TestLeakyBucketLimiterSmoothsRequests(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewLeakyBucketLimiter(1, 10)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	clock.Advance(50 * time.Millisecond)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Fatalf("expected request before the next drain slot to be denied")
+	}
+	data := limiter.GetRateLimitData(req)
+	if data.Remaining != 0 || data.RetryAfter != 50*time.Millisecond {
+		t.Errorf("expected {1 0 50ms}; got %+v", data)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request at the next drain slot to be allowed")
+	}
+}
+
+// Benchmark a client filling the bucket as fast as it leaks
+func BenchmarkLeakyBucketLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewLeakyBucketLimiter(100, 100)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
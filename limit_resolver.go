@@ -0,0 +1,131 @@
+package cerberus
+
+import (
+	"cmp"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultLimitCacheTTL is how long the limits resolved by the resolver set with
+// [WithLimitResolver] are cached by default.
+const defaultLimitCacheTTL = time.Minute
+
+// Limit is the limit of a client resolved at request time by the resolver set with
+// [WithLimitResolver]: Limit requests per Window, with the algorithm of the limiter. The zero Limit
+// stands for the default limit of the limiter.
+type Limit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// WithLimitResolver makes the limiter look up the limit of each client with resolve, called with
+// the key of the client, instead of applying the same limit to all of them. It suits limits that
+// live in a database or a feature flag system, such as the limits of customer-specific contracts,
+// which cannot be hardcoded.
+//
+// Behavior:
+//   - Resolved limits are cached per key for a minute, or as set with [WithLimitCacheTTL], so that
+//     resolve is not called on every request. Errors are not cached.
+//   - A zero [Limit] applies the default limit of the limiter to the client.
+//   - If resolve returns an error, or a limit that is not positive, checking the request fails with
+//     that error, and the request is handled according to the failure policy of the middleware.
+//   - The usage of a client is kept when its limit changes, as with [DynamicLimiter.UpdateConfig].
+//
+// Limit resolvers are supported by [DynamicLimiter], whose config provides the algorithm and the
+// default limit. Other limiters ignore this option.
+//
+// Example usage:
+//
+//	limiter, err := NewDynamicLimiter(Config{Algorithm: AlgorithmGCRA, Limit: 100, Window: time.Minute},
+//		WithKeyFunc(KeyByHeader("X-API-Key")),
+//		WithLimitResolver(func(apiKey string) (Limit, error) {
+//			return contracts.RateLimit(apiKey) // e.g. Limit{Limit: 5000, Window: time.Minute}
+//		}))
+func WithLimitResolver(resolve func(key string) (Limit, error)) LimiterOption {
+	return func(o *limiterOptions) {
+		o.limitResolver = resolve
+	}
+}
+
+// WithLimitCacheTTL sets how long the limits resolved by the resolver set with
+// [WithLimitResolver] are cached. Shorter durations apply changes of limits faster, at the cost of
+// more calls to the resolver. The default is one minute.
+//
+// It panics if ttl is not positive.
+func WithLimitCacheTTL(ttl time.Duration) LimiterOption {
+	if ttl <= 0 {
+		panic("cerberus: limit cache TTL must be positive")
+	}
+	return func(o *limiterOptions) {
+		o.limitCacheTTL = ttl
+	}
+}
+
+// limitCache caches the limits resolved by the resolver set with [WithLimitResolver].
+type limitCache struct {
+	resolve func(key string) (Limit, error)
+	ttl     time.Duration
+	clock   Clock
+
+	mu      sync.Mutex
+	limits  map[string]cachedLimit
+	sweptAt time.Time
+}
+
+// cachedLimit is a limit cached by a [limitCache] until expiresAt.
+type cachedLimit struct {
+	limit     Limit
+	expiresAt time.Time
+}
+
+// newLimitCache returns the cache of the limit resolver of options, or nil if there is none.
+func newLimitCache(options limiterOptions) *limitCache {
+	if options.limitResolver == nil {
+		return nil
+	}
+	return &limitCache{
+		resolve: options.limitResolver,
+		ttl:     cmp.Or(options.limitCacheTTL, defaultLimitCacheTTL),
+		clock:   options.clock,
+		limits:  make(map[string]cachedLimit),
+	}
+}
+
+// get returns the limit of the client identified by key, resolving it if it is not cached. An
+// error wrapping [ErrInvalidConfig] is returned if the limit resolved is not valid.
+func (c *limitCache) get(key string) (Limit, error) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	cached, ok := c.limits[key]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.limit, nil
+	}
+	limit, err := c.resolve(key)
+	if err != nil {
+		return Limit{}, err
+	}
+	if limit != (Limit{}) && (limit.Limit <= 0 || limit.Window <= 0) {
+		return Limit{}, fmt.Errorf("%w: limit of %q must be positive", ErrInvalidConfig, key)
+	}
+	c.mu.Lock()
+	c.sweep(now)
+	c.limits[key] = cachedLimit{limit: limit, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return limit, nil
+}
+
+// sweep removes the expired limits, if the TTL of the cache has elapsed since the last sweep, so
+// that the limits of clients that went away do not accumulate. c.mu must be held.
+func (c *limitCache) sweep(now time.Time) {
+	if now.Sub(c.sweptAt) < c.ttl {
+		return
+	}
+	c.sweptAt = now
+	for key, cached := range c.limits {
+		if !now.Before(cached.expiresAt) {
+			delete(c.limits, key)
+		}
+	}
+}
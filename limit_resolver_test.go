@@ -0,0 +1,111 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newAPIKeyRequest returns a request carrying apiKey in the X-API-Key header.
+func newAPIKeyRequest(apiKey string) *http.Request {
+	r := newRequestFrom("192.0.2.1:1234")
+	r.Header.Set("X-API-Key", apiKey)
+	return r
+}
+
+// Test clients get the limits resolved for them, and the default limit otherwise
+func TestWithLimitResolver(t *testing.T) {
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmFixedWindow, Limit: 2, Window: time.Hour},
+		WithKeyFunc(KeyByHeader("X-API-Key")),
+		WithLimitResolver(func(key string) (Limit, error) {
+			if key == "contract" {
+				return Limit{Limit: 4, Window: time.Hour}, nil
+			}
+			return Limit{}, nil
+		}))
+
+	for apiKey, expected := range map[string]int{"contract": 4, "other": 2} {
+		req := newAPIKeyRequest(apiKey)
+		allowed := 0
+		for range 6 {
+			if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+				allowed++
+			}
+		}
+		if allowed != expected {
+			t.Errorf("expected %d requests of %q to be allowed; got %d", expected, apiKey, allowed)
+		}
+		if data := limiter.GetRateLimitData(req); data.Limit != expected {
+			t.Errorf("expected a limit of %d for %q; got %d", expected, apiKey, data.Limit)
+		}
+		if data, _ := limiter.Usage(context.Background(), apiKey); data.Limit != expected {
+			t.Errorf("expected a usage limit of %d for %q; got %d", expected, apiKey, data.Limit)
+		}
+	}
+}
+
+// Test resolved limits are cached for the TTL, and usage is kept when they change
+func TestWithLimitResolverCache(t *testing.T) {
+	clock := newWaitRecordingClock()
+	calls := 0
+	limit := Limit{Limit: 2, Window: time.Hour}
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Hour},
+		WithClock(clock),
+		WithLimitCacheTTL(time.Minute),
+		WithLimitResolver(func(string) (Limit, error) {
+			calls++
+			return limit, nil
+		}))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for range 2 {
+		limiter.IsAllowed(req)
+	}
+	if calls != 1 {
+		t.Errorf("expected the limit to be resolved once; got %d calls", calls)
+	}
+	// The request denied below counts in a fixed window, so 3 requests are used when the limit changes.
+	limit = Limit{Limit: 4, Window: time.Hour}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected the cached limit to apply")
+	}
+
+	clock.Sleep(time.Minute)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected the new limit to apply after the TTL")
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected the usage of the client to be kept under the new limit")
+	}
+	if calls != 2 {
+		t.Errorf("expected the limit to be resolved again after the TTL; got %d calls", calls)
+	}
+}
+
+// Test resolver errors and invalid limits fail the check, and are not cached
+func TestWithLimitResolverError(t *testing.T) {
+	errResolver := errors.New("contracts unavailable")
+	results := []struct {
+		limit Limit
+		err   error
+	}{{err: errResolver}, {limit: Limit{Limit: -1, Window: time.Hour}}, {limit: Limit{Limit: 1, Window: time.Hour}}}
+	limiter, _ := NewDynamicLimiter(Config{Algorithm: AlgorithmGCRA, Limit: 10, Window: time.Hour},
+		WithLimitResolver(func(string) (Limit, error) {
+			result := results[0]
+			results = results[1:]
+			return result.limit, result.err
+		}))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if _, err := limiter.IsAllowed(req); !errors.Is(err, errResolver) {
+		t.Errorf("expected %v; got %v", errResolver, err)
+	}
+	if _, err := limiter.IsAllowed(req); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected %v; got %v", ErrInvalidConfig, err)
+	}
+	if isAllowed, err := limiter.IsAllowed(req); !isAllowed || err != nil {
+		t.Errorf("expected the request to be allowed once the limit resolves; got %v, %v", isAllowed, err)
+	}
+}
@@ -0,0 +1,69 @@
+package cerberus
+
+import "time"
+
+// LimiterOption configures the behavior shared by all built-in limiters, such as how requests
+// are mapped to rate limiting keys. Options are passed to the limiter constructors, for example
+// NewTokenBucketLimiter(10, 1, WithKeyFunc(KeyByHeader("X-API-Key"))).
+type LimiterOption func(*limiterOptions)
+
+// limiterOptions holds the configuration assembled from a list of [LimiterOption] values.
+type limiterOptions struct {
+	keyFunc  KeyFunc
+	store    Store
+	costFunc CostFunc
+	policy   string
+	clock    Clock
+
+	topDenied *TopKeys
+
+	warmUp     time.Duration
+	warmUpFrom float64
+
+	limitResolver func(key string) (Limit, error)
+	limitCacheTTL time.Duration
+}
+
+// newLimiterOptions applies opts on top of the default configuration.
+func newLimiterOptions(opts []LimiterOption) limiterOptions {
+	options := limiterOptions{
+		keyFunc: KeyByIP,
+		clock:   SystemClock,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.store == nil {
+		store := NewMemoryStore(withMemoryStoreClock(options.clock.Now))
+		store.owned = true
+		options.store = store
+	}
+	return options
+}
+
+// WithKeyFunc sets the [KeyFunc] used to map requests to rate limiting keys. Requests that map
+// to the same key share the same limit. The default is [KeyByIP].
+func WithKeyFunc(keyFunc KeyFunc) LimiterOption {
+	return func(o *limiterOptions) {
+		o.keyFunc = keyFunc
+	}
+}
+
+// WithStore sets the [Store] the limiter keeps its state in. Sharing a store between instances
+// of a service makes them enforce a common limit. Limiters sharing a store must not use the same
+// keys. The default is a new [MemoryStore] per limiter.
+func WithStore(store Store) LimiterOption {
+	return func(o *limiterOptions) {
+		o.store = store
+	}
+}
+
+// WithPolicy sets the policy the limiter reports in the RateLimit-Policy header, overriding the
+// default policy derived from its limit and window, such as "100;w=60". It allows adding
+// parameters such as a comment: WithPolicy(`100;w=60;comment="sliding window"`). The header is
+// only emitted with [HeaderStyleIETF].
+func WithPolicy(policy string) LimiterOption {
+	return func(o *limiterOptions) {
+		o.policy = policy
+	}
+}
@@ -0,0 +1,271 @@
+package cerberus
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PriorityFunc classifies requests by priority, such as [PriorityCritical] for health checks or
+// [PriorityHigh] for paying customers. Larger values are more important.
+type PriorityFunc func(r *http.Request) int
+
+// Priority classes of requests, from the least to the most important. See [PriorityFunc].
+const (
+	// PriorityLow is for requests that can be dropped first, such as prefetches, crawlers, or
+	// analytics beacons.
+	PriorityLow = iota
+	// PriorityNormal is for regular traffic.
+	PriorityNormal
+	// PriorityHigh is for requests that should be dropped last, such as those of paying customers
+	// or checkout flows.
+	PriorityHigh
+	// PriorityCritical is for requests that must never be dropped, such as health checks.
+	PriorityCritical
+)
+
+// LoadShedder is a [RateLimiter] rejecting a fraction of all requests while the process is
+// overloaded, as measured by process-level signals such as the number of goroutines, the size of
+// the heap, the CPU utilization, or the number of requests in flight. Unlike the other rate
+// limiters, it protects the service as a whole rather than from individual clients, and is usually
+// installed in front of them.
+//
+// Behavior:
+//   - Each signal has a threshold and a maximum. The load of the process grows linearly from 0,
+//     when the signal closest to its maximum is at its threshold, to 1, when it reaches its maximum.
+//   - Requests are rejected by priority class, as reported by the function set with
+//     [WithPriority], with a probability growing with the load: low-priority requests are all
+//     rejected before any normal-priority request is, and normal-priority requests before
+//     high-priority ones, so that all requests but critical ones are rejected at full load.
+//     Critical requests are never rejected.
+//   - Signals are sampled at most once per sample interval, when a request is checked, so that
+//     checking a request costs a random number and a few atomic operations.
+//
+// The number of requests in flight is only known to the middleware returned by
+// [LoadShedder.Middleware], which rejects requests with an HTTP 503 (Service Unavailable) by
+// default.
+//
+// A LoadShedder is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	shedder := NewLoadShedder(
+//		WithCPUThreshold(0.8, 0.95),
+//		WithInFlightThreshold(500, 1000),
+//		WithPriority(func(r *http.Request) int {
+//			if r.URL.Path == "/healthz" {
+//				return PriorityCritical
+//			}
+//			return PriorityNormal
+//		}),
+//	)
+//	http.Handle("/", shedder.Middleware()(New(perClientLimiter)(myHandler)))
+type LoadShedder struct {
+	signals        []loadSignal
+	priority       PriorityFunc
+	sampleInterval time.Duration
+
+	inFlight atomic.Int64
+	shed     atomic.Uint64
+
+	mu        sync.Mutex
+	sampledAt time.Time
+
+	now func() time.Time
+}
+
+// loadSignal is a signal of a [LoadShedder] together with its threshold and its maximum.
+type loadSignal struct {
+	signal    SignalFunc
+	threshold float64
+	max       float64
+}
+
+// LoadShedderOption configures a [LoadShedder].
+type LoadShedderOption func(*LoadShedder)
+
+// WithLoadSignal adds a signal to the load shedder, such as the length of a work queue or the p99
+// latency of a downstream dependency. Requests start being rejected when the signal exceeds
+// threshold, and are all rejected when it reaches max.
+//
+// It panics if signal is nil or max is not greater than threshold.
+func WithLoadSignal(signal SignalFunc, threshold, max float64) LoadShedderOption {
+	if signal == nil {
+		panic("cerberus: load signal function must not be nil")
+	}
+	if !(max > threshold) {
+		panic("cerberus: load signal maximum must be greater than its threshold")
+	}
+	return func(s *LoadShedder) {
+		s.signals = append(s.signals, loadSignal{signal: signal, threshold: threshold, max: max})
+	}
+}
+
+// WithGoroutineThreshold adds the number of goroutines as a signal to the load shedder. Each
+// request served by net/http runs on its own goroutine, so this tracks the concurrency of the
+// process as a whole.
+func WithGoroutineThreshold(threshold, max int) LoadShedderOption {
+	return WithLoadSignal(func() float64 {
+		return float64(runtime.NumGoroutine())
+	}, float64(threshold), float64(max))
+}
+
+// WithHeapThreshold adds the size of the heap, in bytes, as a signal to the load shedder. It
+// counts the memory occupied by live and not yet collected objects.
+func WithHeapThreshold(threshold, max uint64) LoadShedderOption {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	return WithLoadSignal(func() float64 {
+		metrics.Read(sample)
+		return float64(sample[0].Value.Uint64())
+	}, float64(threshold), float64(max))
+}
+
+// WithCPUThreshold adds the CPU utilization of the process as a signal to the load shedder, as a
+// fraction of the CPUs it may use, given by GOMAXPROCS. The utilization is measured over the sample
+// interval. It is only available on Linux, macOS, and the BSDs, and is zero elsewhere.
+func WithCPUThreshold(threshold, max float64) LoadShedderOption {
+	var lastCPU time.Duration
+	var lastWall time.Time
+	return WithLoadSignal(func() float64 {
+		cpu, wall := processCPUTime(), time.Now()
+		defer func() { lastCPU, lastWall = cpu, wall }()
+		if lastWall.IsZero() || !wall.After(lastWall) {
+			return 0
+		}
+		return (cpu - lastCPU).Seconds() / (wall.Sub(lastWall).Seconds() * float64(runtime.GOMAXPROCS(0)))
+	}, threshold, max)
+}
+
+// WithInFlightThreshold adds the number of requests in flight in the middleware returned by
+// [LoadShedder.Middleware] as a signal to the load shedder.
+//
+// It panics if max is not greater than threshold.
+func WithInFlightThreshold(threshold, max int) LoadShedderOption {
+	if max <= threshold {
+		panic("cerberus: load signal maximum must be greater than its threshold")
+	}
+	return func(s *LoadShedder) {
+		WithLoadSignal(func() float64 {
+			return float64(s.inFlight.Load())
+		}, float64(threshold), float64(max))(s)
+	}
+}
+
+// WithPriority sets the function classifying requests by priority. The default classifies all
+// requests as [PriorityNormal]. Priorities below [PriorityLow] are treated as PriorityLow, and
+// priorities above [PriorityCritical] as PriorityCritical.
+func WithPriority(priority PriorityFunc) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.priority = priority
+	}
+}
+
+// WithSampleInterval sets how often the signals of the load shedder are sampled. The default is
+// 100 milliseconds.
+func WithSampleInterval(interval time.Duration) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.sampleInterval = interval
+	}
+}
+
+// WithLoadShedderClock sets the [Clock] the load shedder tells the time with, which decides when
+// the sample interval has elapsed. The default is [SystemClock].
+func WithLoadShedderClock(clock Clock) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.now = clock.Now
+	}
+}
+
+// NewLoadShedder creates a new [LoadShedder] with the signals set by opts. A LoadShedder without
+// signals never rejects requests.
+func NewLoadShedder(opts ...LoadShedderOption) *LoadShedder {
+	s := &LoadShedder{
+		priority:       func(*http.Request) int { return PriorityNormal },
+		sampleInterval: 100 * time.Millisecond,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// IsAllowed samples the signals if the sample interval has elapsed, and reports whether the request
+// is admitted given the current load and its priority. It never returns an error.
+func (s *LoadShedder) IsAllowed(r *http.Request) (bool, error) {
+	s.sample()
+	shed := s.Load()
+	if shed == 0 {
+		return true, nil
+	}
+	priority := min(max(s.priority(r), PriorityLow), PriorityCritical)
+	if priority == PriorityCritical {
+		return true, nil
+	}
+	// Each priority class below PriorityCritical is shed within its own band of load, so that
+	// lower classes are shed entirely before higher ones start being shed.
+	probability := shed*PriorityCritical - float64(priority-PriorityLow)
+	return rand.Float64() >= probability, nil
+}
+
+// Load returns the load of the process as of the last sample, between 0 when no request is rejected
+// and 1 when all requests but critical ones are.
+func (s *LoadShedder) Load() float64 {
+	return math.Float64frombits(s.shed.Load())
+}
+
+// InFlight returns the number of requests in flight in the middleware returned by
+// [LoadShedder.Middleware].
+func (s *LoadShedder) InFlight() int {
+	return int(s.inFlight.Load())
+}
+
+// Middleware returns a middleware constructor shedding load with s, which also keeps track of the
+// requests in flight. It is equivalent to [New] with s as the rate limiter, except that rejected
+// requests get an HTTP 503 (Service Unavailable) by default. opts are passed to New, and can set a
+// different denied handler.
+//
+// Example usage: http.Handle("/", shedder.Middleware(WithLogger(slog.Default()))(myHandler))
+func (s *LoadShedder) Middleware(opts ...Option) func(http.Handler) http.Handler {
+	opts = append([]Option{WithDeniedHandler(http.HandlerFunc(serviceUnavailable))}, opts...)
+	middleware := New(s, opts...)
+	return func(next http.Handler) http.Handler {
+		handler := middleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.inFlight.Add(1)
+			defer s.inFlight.Add(-1)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sample updates the fraction of requests shed from the signals, if the sample interval has
+// elapsed since they were last sampled. Requests checked while another goroutine is sampling use
+// the previous fraction rather than wait.
+func (s *LoadShedder) sample() {
+	if len(s.signals) == 0 || !s.mu.TryLock() {
+		return
+	}
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.sampledAt) < s.sampleInterval {
+		return
+	}
+	s.sampledAt = now
+	var shed float64
+	for _, signal := range s.signals {
+		value := signal.signal()
+		shed = max(shed, (value-signal.threshold)/(signal.max-signal.threshold))
+	}
+	s.shed.Store(math.Float64bits(min(max(shed, 0), 1)))
+}
+
+// serviceUnavailable responds with an empty HTTP 503 (Service Unavailable).
+func serviceUnavailable(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
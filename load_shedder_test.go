@@ -0,0 +1,153 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestLoadShedder returns a load shedder driven by the value pointed to by signal, with a
+// threshold of 0 and a maximum of 1, and a fake clock.
+func newTestLoadShedder(signal *float64, opts ...LoadShedderOption) (*LoadShedder, *fakeClock) {
+	clock := newFakeClock()
+	opts = append([]LoadShedderOption{WithLoadSignal(func() float64 { return *signal }, 0, 1)}, opts...)
+	shedder := NewLoadShedder(opts...)
+	shedder.now = clock.Now
+	return shedder, clock
+}
+
+// allowedCount returns how many of n requests of the given priority are allowed by shedder.
+func allowedCount(shedder *LoadShedder, priority, n int) int {
+	shedder.priority = func(*http.Request) int { return priority }
+	allowed := 0
+	for range n {
+		if isAllowed, _ := shedder.IsAllowed(newRequestFrom("192.0.2.1:1234")); isAllowed {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// Test no request is rejected below the threshold
+func TestLoadShedderAllowsBelowThreshold(t *testing.T) {
+	signal := -1.0
+	shedder, _ := newTestLoadShedder(&signal)
+
+	if allowed := allowedCount(shedder, PriorityLow, 100); allowed != 100 {
+		t.Errorf("expected 100 requests to be allowed; got %v", allowed)
+	}
+	if load := shedder.Load(); load != 0 {
+		t.Errorf("expected a load of 0; got %v", load)
+	}
+}
+
+// Test all requests but critical ones are rejected at the maximum
+func TestLoadShedderRejectsAtMaximum(t *testing.T) {
+	signal := 2.0
+	shedder, _ := newTestLoadShedder(&signal)
+
+	for _, priority := range []int{PriorityLow, PriorityNormal, PriorityHigh} {
+		if allowed := allowedCount(shedder, priority, 100); allowed != 0 {
+			t.Errorf("priority %v: expected no request to be allowed; got %v", priority, allowed)
+		}
+	}
+	if allowed := allowedCount(shedder, PriorityCritical, 100); allowed != 100 {
+		t.Errorf("expected 100 critical requests to be allowed; got %v", allowed)
+	}
+	if load := shedder.Load(); load != 1 {
+		t.Errorf("expected a load of 1; got %v", load)
+	}
+}
+
+// Test low-priority requests are rejected before higher-priority ones
+func TestLoadShedderRejectsByPriority(t *testing.T) {
+	signal := 0.5
+	shedder, _ := newTestLoadShedder(&signal)
+
+	if allowed := allowedCount(shedder, PriorityLow, 100); allowed != 0 {
+		t.Errorf("expected no low-priority request to be allowed; got %v", allowed)
+	}
+	if allowed := allowedCount(shedder, PriorityNormal, 1000); allowed < 400 || allowed > 600 {
+		t.Errorf("expected about half of normal-priority requests to be allowed; got %v out of 1000", allowed)
+	}
+	if allowed := allowedCount(shedder, PriorityHigh, 100); allowed != 100 {
+		t.Errorf("expected 100 high-priority requests to be allowed; got %v", allowed)
+	}
+}
+
+// Test signals are sampled at most once per sample interval
+func TestLoadShedderSamplesOncePerInterval(t *testing.T) {
+	signal := 0.0
+	shedder, clock := newTestLoadShedder(&signal, WithSampleInterval(time.Second))
+	allowedCount(shedder, PriorityNormal, 1)
+
+	signal = 1
+	if allowed := allowedCount(shedder, PriorityNormal, 10); allowed != 10 {
+		t.Errorf("expected 10 requests to be allowed before the next sample; got %v", allowed)
+	}
+	clock.Advance(time.Second)
+	if allowed := allowedCount(shedder, PriorityNormal, 10); allowed != 0 {
+		t.Errorf("expected no request to be allowed after the next sample; got %v", allowed)
+	}
+}
+
+// Test the middleware rejects requests with a 503 and tracks the requests in flight
+func TestLoadShedderMiddleware(t *testing.T) {
+	shedder := NewLoadShedder(WithInFlightThreshold(1, 2), WithSampleInterval(0))
+	var middleware http.Handler
+	var inFlight, nestedCode int
+	middleware = shedder.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inFlight = shedder.InFlight(); inFlight == 1 {
+			rr := httptest.NewRecorder()
+			middleware.ServeHTTP(rr, r)
+			nestedCode = rr.Code
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %v; got %v", http.StatusOK, rr.Code)
+	}
+	if inFlight != 1 {
+		t.Errorf("expected 1 request in flight; got %v", inFlight)
+	}
+	if nestedCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %v for the concurrent request; got %v", http.StatusServiceUnavailable, nestedCode)
+	}
+	if n := shedder.InFlight(); n != 0 {
+		t.Errorf("expected no request in flight; got %v", n)
+	}
+}
+
+// Test a load shedder without signals never rejects requests
+func TestLoadShedderWithoutSignals(t *testing.T) {
+	shedder := NewLoadShedder()
+
+	if allowed := allowedCount(shedder, PriorityLow, 100); allowed != 100 {
+		t.Errorf("expected 100 requests to be allowed; got %v", allowed)
+	}
+}
+
+// Test invalid signals are rejected
+func TestWithLoadSignalPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"nil signal":        func() { WithLoadSignal(nil, 0, 1) },
+		"max below":         func() { WithLoadSignal(func() float64 { return 0 }, 1, 0) },
+		"max equal":         func() { WithLoadSignal(func() float64 { return 0 }, 1, 1) },
+		"goroutine max":     func() { WithGoroutineThreshold(10, 10) },
+		"in-flight reverse": func() { WithInFlightThreshold(10, 5) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
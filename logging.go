@@ -0,0 +1,82 @@
+package cerberus
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// WithLogger sets the logger that receives structured events about rate limiting decisions, so
+// operators can audit which clients are throttled and why. No events are logged by default.
+//
+// Behavior:
+//   - Denied requests are logged at level Info with the message "rate limit exceeded".
+//   - Rate limiter errors are logged at level Error with the message "rate limiter error", whatever
+//     the failure policy.
+//
+// Events carry the request method, its route (the pattern of the matching [http.ServeMux] route,
+// or the path if there is none), the key identifying the client if the rate limiter is a built-in
+// one, and, for denials by an [AdvancedRateLimiter], the time until the client may retry. Events
+// of middlewares in shadow mode carry shadow=true. See [WithShadowMode].
+//
+// Example usage: http.Handle("/resource", New(myRateLimiter, WithLogger(slog.Default()))(myHandler))
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// keyer is implemented by rate limiters that can report the key identifying the client making a
+// request, for logging purposes.
+type keyer interface {
+	key(r *http.Request) (string, error)
+}
+
+// logDenied logs that rateLimiter denied r. data is the rate limit data reported for the request,
+// if any.
+func (o *options) logDenied(r *http.Request, rateLimiter RateLimiter, data RateLimitData) {
+	if !o.logEnabled(r.Context(), slog.LevelInfo) {
+		return
+	}
+	attrs := o.requestAttrs(r, rateLimiter)
+	if data.Limit > 0 {
+		attrs = append(attrs, slog.Duration("retry_after", data.RetryAfter))
+	}
+	o.logger.LogAttrs(r.Context(), slog.LevelInfo, "rate limit exceeded", attrs...)
+}
+
+// logError logs that rateLimiter returned err for r.
+func (o *options) logError(r *http.Request, rateLimiter RateLimiter, err error) {
+	if !o.logEnabled(r.Context(), slog.LevelError) {
+		return
+	}
+	attrs := append(o.requestAttrs(r, rateLimiter), slog.Any("error", err))
+	o.logger.LogAttrs(r.Context(), slog.LevelError, "rate limiter error", attrs...)
+}
+
+// requestAttrs returns the attributes identifying r in log events. Events of middlewares in shadow
+// mode are marked with a shadow attribute.
+func (o *options) requestAttrs(r *http.Request, rateLimiter RateLimiter) []slog.Attr {
+	route := r.Pattern
+	if route == "" {
+		route = r.URL.Path
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("route", route),
+	}
+	if k, ok := rateLimiter.(keyer); ok {
+		if key, err := k.key(r); err == nil {
+			attrs = append(attrs, slog.String("key", key))
+		}
+	}
+	if o.shadow {
+		attrs = append(attrs, slog.Bool("shadow", true))
+	}
+	return attrs
+}
+
+// logEnabled reports whether events at level would be logged.
+func (o *options) logEnabled(ctx context.Context, level slog.Level) bool {
+	return o.logger != nil && o.logger.Enabled(ctx, level)
+}
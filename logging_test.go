@@ -0,0 +1,101 @@
+package cerberus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// decodeLogEvents decodes the JSON log events written to buf.
+func decodeLogEvents(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var events []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var event map[string]any
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("expected valid JSON log event; got %v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// Test WithLogger logs denials with the key, route, and retry-after
+func TestWithLoggerLogsDenials(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(limiter, WithLogger(logger), WithHeaders(false))(handler)
+
+	for range 2 {
+		middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+
+	events := decodeLogEvents(t, &buf)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 log event; got %v", len(events))
+	}
+	event := events[0]
+	if event["msg"] != "rate limit exceeded" || event["level"] != "INFO" {
+		t.Errorf("expected info rate limit exceeded event; got %v", event)
+	}
+	if event["key"] != "192.0.2.1" {
+		t.Errorf("expected key 192.0.2.1; got %v", event["key"])
+	}
+	if event["route"] != "/api" || event["method"] != http.MethodGet {
+		t.Errorf("expected route /api and method GET; got %v and %v", event["route"], event["method"])
+	}
+	if _, ok := event["retry_after"]; !ok {
+		t.Errorf("expected retry_after attribute; got %v", event)
+	}
+}
+
+// Test WithLogger logs rate limiter errors
+func TestWithLoggerLogsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, errors.New("store unavailable")
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(mockLimiter, WithLogger(logger), WithFailurePolicy(FailOpen))(handler)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status OK; got %v", rr.Code)
+	}
+	events := decodeLogEvents(t, &buf)
+	if len(events) != 1 || events[0]["level"] != "ERROR" || events[0]["error"] != "store unavailable" {
+		t.Errorf("expected 1 error event; got %v", events)
+	}
+}
+
+// Test WithLogger logs nothing for allowed requests
+func TestWithLoggerIgnoresAllowedRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(NewTokenBucketLimiter(10, 1), WithLogger(logger))(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no log events; got %v", buf.String())
+	}
+}
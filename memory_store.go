@@ -0,0 +1,427 @@
+package cerberus
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memoryStoreShards is the number of shards the entries of a [MemoryStore] are spread across.
+// Operations on keys in different shards never contend for the same lock.
+const memoryStoreShards = 64
+
+// MemoryStore is a [Store] that keeps its entries in process memory. It is the default store of
+// all built-in limiters, and is suitable for services running as a single instance.
+//
+// Entries are spread across shards by the hash of their key to reduce lock contention. Expired
+// entries are removed lazily when they are accessed, and periodically by a background janitor
+// goroutine so that keys which are never accessed again do not accumulate. The number of entries
+// can additionally be bounded, in which case the least recently used entries are evicted first.
+// Entries can be carried over process restarts with [MemoryStore.Snapshot] and
+// [MemoryStore.Restore].
+//
+// A MemoryStore is safe for concurrent use by multiple goroutines. The janitor is stopped by
+// calling Close, or when the store is garbage collected.
+type MemoryStore struct {
+	shards             *[memoryStoreShards]memoryStoreShard
+	maxEntriesPerShard int
+
+	closeOnce sync.Once
+	done      chan struct{}
+	janitor   *janitorCounters
+	// owned is set on the default store of a limiter, which the limiter closes.
+	owned bool
+
+	now func() time.Time
+}
+
+// MemoryStoreStats is a snapshot of the state of a [MemoryStore], as returned by
+// [MemoryStore.Stats].
+type MemoryStoreStats struct {
+	// Entries is the number of entries in the store, including expired entries that have not been
+	// removed yet.
+	Entries int `json:"entries"`
+	// JanitorRuns is the number of times the janitor has swept the store.
+	JanitorRuns int64 `json:"janitor_runs"`
+	// JanitorRemoved is the number of expired entries removed by the janitor.
+	JanitorRemoved int64 `json:"janitor_removed"`
+}
+
+// janitorCounters counts the work of the janitor of a [MemoryStore]. It is allocated separately
+// from the store so that the janitor can update it without referencing the store.
+type janitorCounters struct {
+	runs    atomic.Int64
+	removed atomic.Int64
+}
+
+// memoryStoreShard is a lock-protected subset of the entries of a [MemoryStore]. Entries are kept
+// in a list ordered from most to least recently used, indexed by key.
+type memoryStoreShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+}
+
+// memoryEntry is a value stored in a [MemoryStore] together with its expiration time. A zero
+// expiration time means the entry never expires.
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStoreOption configures a [MemoryStore].
+type MemoryStoreOption func(*memoryStoreOptions)
+
+// memoryStoreOptions holds the configuration assembled from a list of [MemoryStoreOption] values.
+type memoryStoreOptions struct {
+	cleanupInterval time.Duration
+	maxEntries      int
+	now             func() time.Time
+}
+
+// defaultCleanupInterval is how often the janitor of a [MemoryStore] runs unless set with
+// [WithCleanupInterval].
+var defaultCleanupInterval = time.Minute
+
+// WithCleanupInterval sets how often the janitor removes expired entries from a [MemoryStore]. A
+// non-positive interval disables the janitor, leaving only lazy removal on access. The default is
+// one minute.
+func WithCleanupInterval(interval time.Duration) MemoryStoreOption {
+	return func(o *memoryStoreOptions) {
+		o.cleanupInterval = interval
+	}
+}
+
+// WithMaxEntries bounds the number of entries in a [MemoryStore]. When the bound is reached, the
+// least recently used entries are evicted to make room for new ones. Since every shard is bounded
+// separately, the store may start evicting slightly before maxEntries is reached overall. A
+// non-positive value means no bound, which is the default.
+func WithMaxEntries(maxEntries int) MemoryStoreOption {
+	return func(o *memoryStoreOptions) {
+		o.maxEntries = maxEntries
+	}
+}
+
+// withMemoryStoreClock sets the function a [MemoryStore] tells the time with, which must be set
+// before the janitor starts since the janitor keeps its own copy. The default is [time.Now].
+func withMemoryStoreClock(now func() time.Time) MemoryStoreOption {
+	return func(o *memoryStoreOptions) {
+		o.now = now
+	}
+}
+
+// NewMemoryStore creates a new, empty [MemoryStore].
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	options := memoryStoreOptions{
+		cleanupInterval: defaultCleanupInterval,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s := &MemoryStore{
+		shards:  new([memoryStoreShards]memoryStoreShard),
+		done:    make(chan struct{}),
+		janitor: new(janitorCounters),
+		now:     options.now,
+	}
+	if options.maxEntries > 0 {
+		s.maxEntriesPerShard = (options.maxEntries + memoryStoreShards - 1) / memoryStoreShards
+	}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*list.Element)
+	}
+	if options.cleanupInterval > 0 {
+		// The janitor must not reference s, otherwise s would never become unreachable
+		// and the finalizer stopping the janitor would never run.
+		go runJanitor(s.shards, s.janitor, s.now, options.cleanupInterval, s.done)
+		runtime.SetFinalizer(s, func(s *MemoryStore) { s.Close(context.Background()) })
+	}
+	return s
+}
+
+// Get returns the value stored under key, or nil if the key does not exist.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if entry := s.lookup(shard, key); entry != nil {
+		return entry.value, nil
+	}
+	return nil, nil
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	s.store(shard, key, value, s.expiresAt(ttl))
+	return nil
+}
+
+// Increment adds delta to the integer stored under key and returns the result. A missing key is
+// treated as zero and created with the given TTL. It returns an error if the existing value is not
+// an integer.
+func (s *MemoryStore) Increment(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry := s.lookup(shard, key)
+	if entry == nil {
+		s.store(shard, key, strconv.AppendInt(nil, delta, 10), s.expiresAt(ttl))
+		return delta, nil
+	}
+	return entry.increment(delta)
+}
+
+// CompareAndSwap stores new under key only if the current value equals old, where a nil old value
+// matches a missing key. It reports whether the value was stored.
+func (s *MemoryStore) CompareAndSwap(_ context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry := s.lookup(shard, key)
+	if (entry != nil) != (old != nil) || (entry != nil && !bytes.Equal(entry.value, old)) {
+		return false, nil
+	}
+	s.store(shard, key, new, s.expiresAt(ttl))
+	return true, nil
+}
+
+// TTL returns the remaining time to live of key. It returns zero if the key does not exist or
+// does not expire.
+func (s *MemoryStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return s.ttl(s.lookup(shard, key)), nil
+}
+
+// Delete removes key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if element, ok := shard.entries[key]; ok {
+		shard.remove(element)
+	}
+	return nil
+}
+
+// Len returns the number of entries in the store, including expired entries that have not been
+// removed yet.
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns a snapshot of the number of entries in the store and of the work of its janitor,
+// for monitoring and debugging.
+func (s *MemoryStore) Stats() MemoryStoreStats {
+	return MemoryStoreStats{
+		Entries:        s.Len(),
+		JanitorRuns:    s.janitor.runs.Load(),
+		JanitorRemoved: s.janitor.removed.Load(),
+	}
+}
+
+// ScanKeys returns the keys starting with prefix that have not expired, in no particular order.
+// It implements [KeyScanner], and always returns a nil error.
+func (s *MemoryStore) ScanKeys(_ context.Context, prefix string) ([]string, error) {
+	now := s.now()
+	var keys []string
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, element := range shard.entries {
+			if strings.HasPrefix(key, prefix) && !element.Value.(*memoryEntry).expired(now) {
+				keys = append(keys, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return keys, nil
+}
+
+// Close stops the janitor. The store remains usable afterwards, relying on lazy removal of
+// expired entries only. Close always returns nil. It implements [Closer].
+func (s *MemoryStore) Close(context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// getByteKey is like Get, with a key that is not retained. It implements [byteKeyStore].
+func (s *MemoryStore) getByteKey(_ context.Context, key []byte) ([]byte, error) {
+	shard := &s.shards[shardIndex(key, memoryStoreShards)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if entry := s.live(shard, shard.entries[string(key)]); entry != nil {
+		return entry.value, nil
+	}
+	return nil, nil
+}
+
+// incrementByteKey is like Increment, with a key that is only copied if it does not exist. It
+// implements [byteKeyStore].
+func (s *MemoryStore) incrementByteKey(_ context.Context, key []byte, delta int64, ttl time.Duration) (int64, error) {
+	shard := &s.shards[shardIndex(key, memoryStoreShards)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry := s.live(shard, shard.entries[string(key)])
+	if entry == nil {
+		s.store(shard, string(key), strconv.AppendInt(nil, delta, 10), s.expiresAt(ttl))
+		return delta, nil
+	}
+	return entry.increment(delta)
+}
+
+// ttlByteKey is like TTL, with a key that is not retained. It implements [byteKeyStore].
+func (s *MemoryStore) ttlByteKey(_ context.Context, key []byte) (time.Duration, error) {
+	shard := &s.shards[shardIndex(key, memoryStoreShards)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return s.ttl(s.live(shard, shard.entries[string(key)])), nil
+}
+
+// shard returns the shard responsible for key.
+func (s *MemoryStore) shard(key string) *memoryStoreShard {
+	return &s.shards[shardIndex(key, memoryStoreShards)]
+}
+
+// lookup returns the entry stored under key in shard and marks it as most recently used. Expired
+// entries are removed, and nil is returned for them. The caller must hold shard.mu.
+func (s *MemoryStore) lookup(shard *memoryStoreShard, key string) *memoryEntry {
+	return s.live(shard, shard.entries[key])
+}
+
+// live returns the entry held by element, which may be nil, and marks it as most recently used.
+// Expired entries are removed, and nil is returned for them. The caller must hold shard.mu.
+func (s *MemoryStore) live(shard *memoryStoreShard, element *list.Element) *memoryEntry {
+	if element == nil {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(s.now()) {
+		shard.remove(element)
+		return nil
+	}
+	shard.lru.MoveToFront(element)
+	return entry
+}
+
+// store writes an entry to shard, evicting the least recently used entry if the shard is full.
+// The caller must hold shard.mu.
+func (s *MemoryStore) store(shard *memoryStoreShard, key string, value []byte, expiresAt time.Time) {
+	if element, ok := shard.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		shard.lru.MoveToFront(element)
+		return
+	}
+	if s.maxEntriesPerShard > 0 && len(shard.entries) >= s.maxEntriesPerShard {
+		shard.remove(shard.lru.Back())
+	}
+	shard.entries[key] = shard.lru.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+}
+
+// ttl returns the remaining time to live of entry, which may be nil.
+func (s *MemoryStore) ttl(entry *memoryEntry) time.Duration {
+	if entry == nil || entry.expiresAt.IsZero() {
+		return 0
+	}
+	return entry.expiresAt.Sub(s.now())
+}
+
+// expiresAt returns the expiration time of an entry written now with the given TTL.
+func (s *MemoryStore) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(ttl)
+}
+
+// remove deletes the entry held by element from the shard. The caller must hold shard.mu.
+func (shard *memoryStoreShard) remove(element *list.Element) {
+	entry := shard.lru.Remove(element).(*memoryEntry)
+	delete(shard.entries, entry.key)
+}
+
+// deleteExpired removes all entries that have expired at now and returns how many were removed.
+// The caller must hold shard.mu.
+func (shard *memoryStoreShard) deleteExpired(now time.Time) int {
+	removed := 0
+	for _, element := range shard.entries {
+		if element.Value.(*memoryEntry).expired(now) {
+			shard.remove(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+// increment adds delta to the integer value of the entry and returns the result.
+func (e *memoryEntry) increment(delta int64) (int64, error) {
+	n, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", ErrMalformedValue, e.key)
+	}
+	n += delta
+	e.value = strconv.AppendInt(nil, n, 10)
+	return n, nil
+}
+
+// expired reports whether the entry has expired at now.
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// runJanitor removes expired entries from shards every interval until done is closed, recording
+// its work in counters. Expiration is judged by now, the clock of the store, rather than by the
+// time of the tick. Shards are swept one at a time so that requests are never blocked on more
+// than a single shard.
+func runJanitor(shards *[memoryStoreShards]memoryStoreShard, counters *janitorCounters, now func() time.Time, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for i := range shards {
+				shard := &shards[i]
+				shard.mu.Lock()
+				counters.removed.Add(int64(shard.deleteExpired(now())))
+				shard.mu.Unlock()
+			}
+			counters.runs.Add(1)
+		}
+	}
+}
+
+// shardIndex maps key to one of n shards using the FNV-1a hash function.
+func shardIndex[K string | []byte](key K, n int) int {
+	const offset32, prime32 = 2166136261, 16777619
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return int(h % uint32(n))
+}
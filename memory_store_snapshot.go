@@ -0,0 +1,141 @@
+package cerberus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSnapshot is returned by [MemoryStore.Restore] when the data to restore is not a
+// snapshot taken with [MemoryStore.Snapshot], or is corrupted.
+var ErrInvalidSnapshot = errors.New("cerberus: invalid store snapshot")
+
+// snapshotMagic starts every snapshot of a [MemoryStore], followed by the version of the format.
+const snapshotMagic = "CRBS"
+
+// snapshotVersion is the version of the format of the snapshots of a [MemoryStore]. Snapshots of
+// other versions are rejected.
+const snapshotVersion = 1
+
+// Snapshot returns the entries of the store that have not expired, encoded in a compact binary
+// format, so that they can be persisted, for example to disk or to an object store, and restored
+// with [MemoryStore.Restore] by the next process, such that limits are not reset by restarts and
+// deploys. Clients whose budget was exhausted before a deploy would otherwise all be allowed again
+// at once after it.
+//
+// Entries are copied one shard at a time, so the snapshot is consistent for each key but not
+// across keys written while it is taken. Expiration times are recorded as absolute times of the
+// clock of the store, so the clocks of the processes taking and restoring a snapshot must agree.
+// Snapshot always returns a nil error.
+//
+// Example usage:
+//
+//	snapshot, _ := store.Snapshot()
+//	os.WriteFile("/var/lib/myservice/limits.bin", snapshot, 0o600)
+func (s *MemoryStore) Snapshot() ([]byte, error) {
+	now := s.now()
+	b := append([]byte(snapshotMagic), snapshotVersion)
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		// Entries are written from the least to the most recently used, so that restoring them
+		// in order preserves which are evicted first by a bounded store.
+		for element := shard.lru.Back(); element != nil; element = element.Prev() {
+			entry := element.Value.(*memoryEntry)
+			if entry.expired(now) {
+				continue
+			}
+			b = binary.AppendUvarint(b, uint64(len(entry.key)))
+			b = append(b, entry.key...)
+			b = binary.AppendUvarint(b, uint64(len(entry.value)))
+			b = append(b, entry.value...)
+			var expiresAt int64
+			if !entry.expiresAt.IsZero() {
+				expiresAt = entry.expiresAt.UnixNano()
+			}
+			b = binary.AppendVarint(b, expiresAt)
+		}
+		shard.mu.Unlock()
+	}
+	return b, nil
+}
+
+// Restore writes the entries of a snapshot taken with [MemoryStore.Snapshot] to the store, with
+// the expiration times they had when the snapshot was taken. Entries that have expired since are
+// skipped, and entries of the store with the same keys are replaced. Other entries of the store
+// are kept.
+//
+// An error wrapping [ErrInvalidSnapshot] is returned if snapshot is not a valid snapshot, in which
+// case the store is left unchanged.
+//
+// Example usage:
+//
+//	store := NewMemoryStore()
+//	if snapshot, err := os.ReadFile("/var/lib/myservice/limits.bin"); err == nil {
+//		if err := store.Restore(snapshot); err != nil {
+//			log.Printf("limits not restored: %v", err)
+//		}
+//	}
+func (s *MemoryStore) Restore(snapshot []byte) error {
+	entries, err := decodeSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, entry := range entries {
+		if entry.expired(now) {
+			continue
+		}
+		shard := s.shard(entry.key)
+		shard.mu.Lock()
+		s.store(shard, entry.key, entry.value, entry.expiresAt)
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+// decodeSnapshot decodes the entries of a snapshot taken with [MemoryStore.Snapshot].
+func decodeSnapshot(b []byte) ([]memoryEntry, error) {
+	if len(b) < len(snapshotMagic)+1 || string(b[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidSnapshot)
+	}
+	if version := b[len(snapshotMagic)]; version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, version)
+	}
+	b = b[len(snapshotMagic)+1:]
+	var entries []memoryEntry
+	for len(b) > 0 {
+		key, rest, ok := readSnapshotBytes(b)
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated key", ErrInvalidSnapshot)
+		}
+		value, rest, ok := readSnapshotBytes(rest)
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated value of %q", ErrInvalidSnapshot, key)
+		}
+		expiresAt, n := binary.Varint(rest)
+		if n <= 0 {
+			return nil, fmt.Errorf("%w: truncated expiration time of %q", ErrInvalidSnapshot, key)
+		}
+		entry := memoryEntry{key: string(key), value: value}
+		if expiresAt != 0 {
+			entry.expiresAt = time.Unix(0, expiresAt)
+		}
+		entries = append(entries, entry)
+		b = rest[n:]
+	}
+	return entries, nil
+}
+
+// readSnapshotBytes reads a byte slice prefixed with its length from b, and returns it along with
+// the rest of b. It returns false if b is too short. The slice returned is a copy, so that b can
+// be reused by the caller.
+func readSnapshotBytes(b []byte) ([]byte, []byte, bool) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < length {
+		return nil, nil, false
+	}
+	b = b[n:]
+	return append([]byte{}, b[:length]...), b[length:], true
+}
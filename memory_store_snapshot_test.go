@@ -0,0 +1,112 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreSnapshotRestore tests that the entries of a store, with their values and
+// expiration times, are restored from a snapshot, and that expired entries are skipped.
+func TestMemoryStoreSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	store := NewMemoryStore(WithCleanupInterval(0))
+	store.now = clock.Now
+	store.Set(ctx, "kept", []byte("value"), 0)
+	store.Set(ctx, "empty", []byte{}, time.Minute)
+	store.Increment(ctx, "counter", 5, time.Hour)
+	store.Set(ctx, "short", []byte("value"), time.Second)
+	store.Set(ctx, "expired", []byte("value"), time.Millisecond)
+	clock.Advance(time.Millisecond)
+
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	clock.Advance(time.Second)
+	restored := NewMemoryStore(WithCleanupInterval(0))
+	restored.now = clock.Now
+	restored.Set(ctx, "kept", []byte("replaced"), 0)
+	restored.Set(ctx, "other", []byte("value"), 0)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if value, _ := restored.Get(ctx, "kept"); string(value) != "value" {
+		t.Errorf("expected the entry of the snapshot to replace the existing one; got %q", value)
+	}
+	if value, _ := restored.Get(ctx, "empty"); value == nil || len(value) != 0 {
+		t.Errorf("expected an empty value; got %q", value)
+	}
+	if n, _ := restored.Increment(ctx, "counter", 1, time.Hour); n != 6 {
+		t.Errorf("expected the counter to be restored; got %d", n)
+	}
+	if ttl, _ := restored.TTL(ctx, "counter"); ttl != time.Hour-time.Second-time.Millisecond {
+		t.Errorf("expected the expiration time to be restored; got a TTL of %v", ttl)
+	}
+	for _, key := range []string{"short", "expired"} {
+		if value, _ := restored.Get(ctx, key); value != nil {
+			t.Errorf("expected %q to be skipped since it expired; got %q", key, value)
+		}
+	}
+	if value, _ := restored.Get(ctx, "other"); string(value) != "value" {
+		t.Errorf("expected the other entries of the store to be kept; got %q", value)
+	}
+}
+
+// TestMemoryStoreSnapshotLRU tests that restoring a snapshot into a bounded store evicts the least
+// recently used entries of the snapshot first.
+func TestMemoryStoreSnapshotLRU(t *testing.T) {
+	ctx := context.Background()
+	// b is a key in the same shard as a, so that a store bounded to a single entry per shard keeps
+	// only one of them.
+	a, b := "a", "b"
+	for i := 0; shardIndex(b, memoryStoreShards) != shardIndex(a, memoryStoreShards); i++ {
+		b = "b" + strconv.Itoa(i)
+	}
+	store := NewMemoryStore(WithCleanupInterval(0))
+	store.Set(ctx, a, []byte("1"), 0)
+	store.Set(ctx, b, []byte("2"), 0)
+	store.Get(ctx, a)
+	snapshot, _ := store.Snapshot()
+
+	bounded := NewMemoryStore(WithCleanupInterval(0), WithMaxEntries(memoryStoreShards))
+	bounded.Restore(snapshot)
+	if value, _ := bounded.Get(ctx, a); string(value) != "1" {
+		t.Errorf("expected the most recently used entry to be kept; got %q", value)
+	}
+	if value, _ := bounded.Get(ctx, b); value != nil {
+		t.Errorf("expected the least recently used entry to be evicted; got %q", value)
+	}
+}
+
+// TestMemoryStoreRestoreInvalid tests that invalid snapshots are rejected, leaving the store
+// unchanged.
+func TestMemoryStoreRestoreInvalid(t *testing.T) {
+	ctx := context.Background()
+	source := NewMemoryStore(WithCleanupInterval(0))
+	source.Set(ctx, "a", []byte("value"), time.Minute)
+	source.Set(ctx, "b", []byte("value"), time.Minute)
+	snapshot, _ := source.Snapshot()
+
+	tests := map[string][]byte{
+		"empty":       nil,
+		"wrong magic": []byte("XXXX\x01"),
+		"version":     append([]byte(snapshotMagic), snapshotVersion+1),
+		"truncated":   snapshot[:len(snapshot)-3],
+	}
+	for name, snapshot := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := NewMemoryStore(WithCleanupInterval(0))
+			if err := store.Restore(snapshot); !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("expected %v; got %v", ErrInvalidSnapshot, err)
+			}
+			if n := store.Len(); n != 0 {
+				t.Errorf("expected the store to be left unchanged; got %d entries", n)
+			}
+		})
+	}
+}
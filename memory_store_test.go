@@ -0,0 +1,254 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Test values can be stored, read, and deleted
+func TestMemoryStoreSetGetDelete(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "key"); err != nil || value != nil {
+		t.Fatalf("expected missing key to yield nil; got %q, %v", value, err)
+	}
+	store.Set(ctx, "key", []byte("value"), 0)
+	if value, _ := store.Get(ctx, "key"); string(value) != "value" {
+		t.Errorf("expected value; got %q", value)
+	}
+	store.Delete(ctx, "key")
+	if value, _ := store.Get(ctx, "key"); value != nil {
+		t.Errorf("expected deleted key to yield nil; got %q", value)
+	}
+}
+
+// Test entries expire after their TTL
+func TestMemoryStoreExpiresEntries(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	store.now = clock.Now
+	ctx := context.Background()
+
+	store.Set(ctx, "key", []byte("value"), time.Minute)
+	clock.Advance(20 * time.Second)
+	if ttl, _ := store.TTL(ctx, "key"); ttl != 40*time.Second {
+		t.Errorf("expected TTL of 40s; got %v", ttl)
+	}
+	clock.Advance(40 * time.Second)
+	if value, _ := store.Get(ctx, "key"); value != nil {
+		t.Errorf("expected expired key to yield nil; got %q", value)
+	}
+}
+
+// Test Increment creates counters and keeps their TTL
+func TestMemoryStoreIncrement(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	store.now = clock.Now
+	ctx := context.Background()
+
+	if n, err := store.Increment(ctx, "counter", 2, time.Minute); err != nil || n != 2 {
+		t.Fatalf("expected 2; got %d, %v", n, err)
+	}
+	clock.Advance(30 * time.Second)
+	if n, _ := store.Increment(ctx, "counter", 3, time.Minute); n != 5 {
+		t.Errorf("expected 5; got %d", n)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl != 30*time.Second {
+		t.Errorf("expected TTL of 30s; got %v", ttl)
+	}
+	store.Set(ctx, "text", []byte("abc"), 0)
+	if _, err := store.Increment(ctx, "text", 1, 0); !errors.Is(err, ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", err)
+	}
+}
+
+// Test CompareAndSwap only stores values over the expected value
+func TestMemoryStoreCompareAndSwap(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if swapped, _ := store.CompareAndSwap(ctx, "key", nil, []byte("a"), 0); !swapped {
+		t.Fatalf("expected swap over missing key to succeed")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "key", nil, []byte("b"), 0); swapped {
+		t.Errorf("expected swap over missing key to fail when key exists")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "key", []byte("x"), []byte("b"), 0); swapped {
+		t.Errorf("expected swap over wrong value to fail")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "key", []byte("a"), []byte("b"), 0); !swapped {
+		t.Errorf("expected swap over current value to succeed")
+	}
+	if value, _ := store.Get(ctx, "key"); string(value) != "b" {
+		t.Errorf("expected b; got %q", value)
+	}
+}
+
+// Test limiters sharing a store enforce a common limit
+func TestLimitersShareStore(t *testing.T) {
+	store := NewMemoryStore()
+	first := NewTokenBucketLimiter(1, 1, WithStore(store))
+	second := NewTokenBucketLimiter(1, 1, WithStore(store))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	first.IsAllowed(req)
+	if isAllowed, _ := second.IsAllowed(req); isAllowed {
+		t.Errorf("expected request to be denied by a limiter sharing the store")
+	}
+}
+
+// Test the janitor removes expired entries that are never accessed again
+func TestMemoryStoreJanitorRemovesExpiredEntries(t *testing.T) {
+	store := NewMemoryStore(WithCleanupInterval(5 * time.Millisecond))
+	defer store.Close(context.Background())
+	ctx := context.Background()
+
+	store.Set(ctx, "expiring", []byte("value"), time.Millisecond)
+	store.Set(ctx, "permanent", []byte("value"), 0)
+	deadline := time.Now().Add(time.Second)
+	for store.Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := store.Len(); n != 1 {
+		t.Errorf("expected 1 entry after cleanup; got %d", n)
+	}
+}
+
+// Test the janitor of the default store of a limiter judges expiration by the clock of the limiter
+func TestMemoryStoreJanitorUsesLimiterClock(t *testing.T) {
+	defaultCleanupInterval = 5 * time.Millisecond
+	t.Cleanup(func() { defaultCleanupInterval = time.Minute })
+	// The clock is years behind the wall clock, so judging by the wall clock removes everything.
+	limiter := NewTokenBucketLimiter(2, 1, WithClock(newWaitRecordingClock()))
+	defer limiter.Close(context.Background())
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+
+	store := limiter.store.(*MemoryStore)
+	deadline := time.Now().Add(time.Second)
+	for store.Stats().JanitorRuns < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := store.Stats(); stats.JanitorRuns < 2 || stats.Entries != 1 {
+		t.Errorf("expected the janitor to keep the live bucket; got %+v", stats)
+	}
+}
+
+// Test the least recently used entries are evicted when the store is full
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(WithMaxEntries(2*memoryStoreShards), WithCleanupInterval(0))
+	ctx := context.Background()
+	// Collect three keys that fall into the same shard, which holds two entries.
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		if key := fmt.Sprintf("key-%d", i); shardIndex(key, memoryStoreShards) == 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	store.Set(ctx, keys[0], []byte("0"), 0)
+	store.Set(ctx, keys[1], []byte("1"), 0)
+	store.Get(ctx, keys[0])
+	store.Set(ctx, keys[2], []byte("2"), 0)
+
+	if value, _ := store.Get(ctx, keys[1]); value != nil {
+		t.Errorf("expected least recently used key to be evicted; got %q", value)
+	}
+	for _, key := range []string{keys[0], keys[2]} {
+		if value, _ := store.Get(ctx, key); value == nil {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+}
+
+// Test keys are scanned by prefix, skipping expired entries
+func TestMemoryStoreScanKeys(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(WithCleanupInterval(0))
+	store.now = clock.Now
+	ctx := context.Background()
+	store.Set(ctx, "a:1", []byte("1"), 0)
+	store.Set(ctx, "a:2", []byte("2"), time.Second)
+	store.Set(ctx, "b:1", []byte("3"), 0)
+	clock.Advance(2 * time.Second)
+
+	keys, err := store.ScanKeys(ctx, "a:")
+
+	if err != nil || len(keys) != 1 || keys[0] != "a:1" {
+		t.Errorf("expected [a:1]; got %v, %v", keys, err)
+	}
+}
+
+// Benchmark concurrent increments of counters spread across shards
+func BenchmarkMemoryStoreIncrement(b *testing.B) {
+	store := NewMemoryStore()
+	defer store.Close(context.Background())
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("client-%d", i)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			store.Increment(ctx, keys[i%len(keys)], 1, time.Minute)
+			i++
+		}
+	})
+}
+
+// Test keys given as byte slices are not retained by the store
+func TestMemoryStoreByteKeys(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	key := []byte("counter")
+
+	incrementByteKey(ctx, store, key, 2, time.Minute)
+	copy(key, "COUNTER")
+	if value, _ := store.Get(ctx, "counter"); string(value) != "2" {
+		t.Errorf("expected 2; got %q", value)
+	}
+	if n, _ := incrementByteKey(ctx, store, []byte("counter"), 1, time.Minute); n != 3 {
+		t.Errorf("expected 3; got %d", n)
+	}
+	if value, _ := getByteKey(ctx, store, []byte("counter")); string(value) != "3" {
+		t.Errorf("expected 3; got %q", value)
+	}
+	if ttl, _ := ttlByteKey(ctx, store, []byte("counter")); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected a TTL of up to a minute; got %v", ttl)
+	}
+	if value, _ := getByteKey(ctx, store, key); value != nil {
+		t.Errorf("expected a missing key; got %q", value)
+	}
+}
+
+// Test the stats of a store report its entries and the work of its janitor
+func TestMemoryStoreStats(t *testing.T) {
+	store := NewMemoryStore(WithCleanupInterval(5 * time.Millisecond))
+	defer store.Close(context.Background())
+	ctx := context.Background()
+
+	store.Set(ctx, "expiring", []byte("value"), time.Millisecond)
+	store.Set(ctx, "permanent", []byte("value"), 0)
+	deadline := time.Now().Add(time.Second)
+	for store.Stats().JanitorRemoved < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stats := store.Stats()
+
+	if stats.Entries != 1 {
+		t.Errorf("expected 1 entry; got %d", stats.Entries)
+	}
+	if stats.JanitorRuns < 1 {
+		t.Errorf("expected the janitor to have run; got %d runs", stats.JanitorRuns)
+	}
+	if stats.JanitorRemoved != 1 {
+		t.Errorf("expected the janitor to have removed 1 entry; got %d", stats.JanitorRemoved)
+	}
+}
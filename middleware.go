@@ -13,18 +13,13 @@ import "net/http"
 //   - If the request exceeds the rate limit, an HTTP 429 (Too Many Requests) response is returned.
 //   - If the rate limiter encounters an error, an HTTP 500 (Internal Server Error) response is returned.
 //
+// The responses to denied requests and to rate limiter errors can be customized with
+// [WithDeniedHandler] and [WithErrorHandler] respectively, and [WithFailurePolicy] selects
+// whether rate limiter errors fail open, fail closed, or are reported to the error handler.
+//
+// Middleware is equivalent to [New] with rate limit headers turned off.
+//
 // Example usage: http.Handle("/resource", Middleware(myRateLimiter, myHandler))
-func Middleware(rateLimiter RateLimiter, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		isAllowed, err := rateLimiter.IsAllowed(r)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if !isAllowed {
-			w.WriteHeader(http.StatusTooManyRequests)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+func Middleware(rateLimiter RateLimiter, next http.Handler, opts ...Option) http.Handler {
+	return New(rateLimiter, append([]Option{WithHeaders(false)}, opts...)...)(next)
 }
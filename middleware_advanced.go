@@ -1,9 +1,6 @@
 package cerberus
 
-import (
-	"fmt"
-	"net/http"
-)
+import "net/http"
 
 // AdvancedMiddleware applies advanced rate limiting to incoming HTTP requests using an [AdvancedRateLimiter].
 // In addition to enforcing rate limits, this middleware also sets custom headers in the response to provide
@@ -27,22 +24,15 @@ import (
 //
 // If an error occurs during the rate limit check, responds with an HTTP 500 (Internal Server Error).
 //
+// The headers above are those of the default [HeaderStyleLegacy]. Pass [WithHeaderStyle] to emit
+// a different set of headers, such as the standards-track headers of [HeaderStyleIETF]. The
+// responses to denied requests and to rate limiter errors can be customized with
+// [WithDeniedHandler] and [WithErrorHandler] respectively, and [WithFailurePolicy] selects
+// whether rate limiter errors fail open, fail closed, or are reported to the error handler.
+//
+// AdvancedMiddleware is equivalent to [New] with an [AdvancedRateLimiter].
+//
 // Example usage:	http.Handle("/resource", AdvancedMiddleware(myAdvancedRateLimiter, myHandler))
-func AdvancedMiddleware(rateLimiter AdvancedRateLimiter, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		isAllowed, err := rateLimiter.IsAllowed(r)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		data := rateLimiter.GetRateLimitData(r)
-		if !isAllowed {
-			w.Header().Set("X-RateLimit-Retry-After", fmt.Sprintf("%d", data.RetryAfter.Milliseconds()))
-			w.WriteHeader(http.StatusTooManyRequests)
-			return
-		}
-		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", data.Limit))
-		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", data.Remaining))
-		next.ServeHTTP(w, r)
-	})
+func AdvancedMiddleware(rateLimiter AdvancedRateLimiter, next http.Handler, opts ...Option) http.Handler {
+	return New(rateLimiter, opts...)(next)
 }
@@ -135,3 +135,348 @@ func TestAdvancedMiddlewareAddsRateLimitHeaders(t *testing.T) {
 		t.Errorf("expected X-RateLimit-Remaining to be 99; got %v", remaining)
 	}
 }
+
+// Test emitting IETF draft headers for allowed requests
+func TestAdvancedMiddlewareIETFHeadersOnAllowed(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{
+				Limit:      100,
+				Remaining:  99,
+				RetryAfter: 0,
+			}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := AdvancedMiddleware(mockLimiter, handler, WithHeaderStyle(HeaderStyleIETF))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if limit := rr.Header().Get("RateLimit-Limit"); limit != "100" {
+		t.Errorf("expected RateLimit-Limit to be 100; got %v", limit)
+	}
+	if remaining := rr.Header().Get("RateLimit-Remaining"); remaining != "99" {
+		t.Errorf("expected RateLimit-Remaining to be 99; got %v", remaining)
+	}
+	if reset, ok := rr.Header()["Ratelimit-Reset"]; ok {
+		t.Errorf("expected no RateLimit-Reset header without a reset time; got %v", reset)
+	}
+	if legacy := rr.Header().Get("X-RateLimit-Limit"); legacy != "" {
+		t.Errorf("expected no X-RateLimit-Limit header; got %v", legacy)
+	}
+}
+
+// Test emitting the RateLimit-Policy header derived from the window of built-in limiters
+func TestAdvancedMiddlewareIETFPolicyHeader(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for expected, limiter := range map[string]AdvancedRateLimiter{
+		"100;w=60":                        NewSlidingWindowLimiter(100, time.Minute),
+		"10;w=5":                          NewTokenBucketLimiter(10, 2),
+		"10;w=1":                          NewGCRALimiter(100*time.Millisecond, 900*time.Millisecond),
+		`5;w=60;comment="login attempts"`: NewFixedWindowLimiter(5, time.Minute, WithPolicy(`5;w=60;comment="login attempts"`)),
+	} {
+		middleware := AdvancedMiddleware(limiter, handler, WithHeaderStyle(HeaderStyleIETF))
+		rr := httptest.NewRecorder()
+
+		middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+		if policy := rr.Header().Get("RateLimit-Policy"); policy != expected {
+			t.Errorf("expected RateLimit-Policy to be %v; got %v", expected, policy)
+		}
+	}
+}
+
+// Test emitting a seconds-based Retry-After header for denied requests
+func TestAdvancedMiddlewareIETFHeadersOnDenied(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{
+				Limit:      100,
+				Remaining:  0,
+				RetryAfter: 1500 * time.Millisecond,
+			}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := AdvancedMiddleware(mockLimiter, handler, WithHeaderStyle(HeaderStyleIETF))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status Too Many Requests; got %v", rr.Code)
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("expected Retry-After header to be 2; got %v", retryAfter)
+	}
+}
+
+// Test custom denied handlers receive the rate limit headers
+func TestAdvancedMiddlewareCustomDeniedHandler(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{
+				Limit:      100,
+				Remaining:  0,
+				RetryAfter: time.Second,
+			}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	deniedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/captcha", http.StatusSeeOther)
+	})
+	middleware := AdvancedMiddleware(mockLimiter, handler, WithDeniedHandler(deniedHandler))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected status See Other; got %v", rr.Code)
+	}
+	if retryAfter := rr.Header().Get("X-RateLimit-Retry-After"); retryAfter != "1000" {
+		t.Errorf("expected X-RateLimit-Retry-After header to be 1000; got %v", retryAfter)
+	}
+}
+
+// Test emitting the standard Retry-After header in the selected format with the legacy style
+func TestAdvancedMiddlewareWithRetryAfter(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{Limit: 10, Remaining: 0, RetryAfter: 1500 * time.Millisecond}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithRetryAfter(RetryAfterSeconds)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("expected Retry-After to be 2; got %v", retryAfter)
+	}
+	if legacy := rr.Header().Get("X-RateLimit-Retry-After"); legacy != "1500" {
+		t.Errorf("expected X-RateLimit-Retry-After to be 1500; got %v", legacy)
+	}
+
+	before := time.Now()
+	rr = httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithRetryAfter(RetryAfterHTTPDate)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	retryAt, err := http.ParseTime(rr.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("expected an HTTP date; got %v", err)
+	}
+	if retryAt.Before(before.Add(1500*time.Millisecond)) || retryAt.After(before.Add(3*time.Second)) {
+		t.Errorf("expected Retry-After about 2s after %v; got %v", before, retryAt)
+	}
+}
+
+// Test rate limit headers cost two allocations, whatever their number
+func TestAdvancedMiddlewareHeaderAllocations(t *testing.T) {
+	for _, style := range []HeaderStyle{HeaderStyleLegacy, HeaderStyleIETF} {
+		mockLimiter := &MockAdvancedRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return true, nil
+			},
+			GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+				return RateLimitData{Limit: 1000, Remaining: 999, RetryAfter: time.Minute, Window: time.Minute}
+			},
+		}
+		handler := AdvancedMiddleware(mockLimiter, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), WithHeaderStyle(style))
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		w := discardResponseWriter{header: make(http.Header)}
+
+		if allocs := testing.AllocsPerRun(100, func() { handler.ServeHTTP(w, req) }); allocs > 2 {
+			t.Errorf("style %v: expected at most 2 allocations; got %v", style, allocs)
+		}
+	}
+}
+
+// Benchmark the overhead of the middleware, including rate limit headers
+func BenchmarkAdvancedMiddleware(b *testing.B) {
+	for _, bm := range []struct {
+		name      string
+		isAllowed bool
+		style     HeaderStyle
+	}{
+		{"Legacy", true, HeaderStyleLegacy},
+		{"IETF", true, HeaderStyleIETF},
+		{"LegacyDenied", false, HeaderStyleLegacy},
+		{"IETFDenied", false, HeaderStyleIETF},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			mockLimiter := &MockAdvancedRateLimiter{
+				IsAllowedFunc: func(r *http.Request) (bool, error) {
+					return bm.isAllowed, nil
+				},
+				GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+					return RateLimitData{Limit: 1000, Remaining: 999, RetryAfter: time.Minute, Window: time.Minute}
+				},
+			}
+			handler := AdvancedMiddleware(mockLimiter, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				WithHeaderStyle(bm.style), WithDeniedHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+			benchmarkHandler(b, handler)
+		})
+	}
+}
+
+// Test the rate limit headers of allowed responses are added to denied responses when enabled
+func TestAdvancedMiddlewareWithDeniedHeaders(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{Limit: 10, Remaining: 1, RetryAfter: 1500 * time.Millisecond, Window: time.Minute}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "" {
+		t.Errorf("expected no X-RateLimit-Limit header by default; got %v", limit)
+	}
+
+	rr = httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithDeniedHeaders(true)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	for name, want := range map[string]string{"X-RateLimit-Limit": "10", "X-RateLimit-Remaining": "0", "X-RateLimit-Retry-After": "1500"} {
+		if value := rr.Header().Get(name); value != want {
+			t.Errorf("expected %s to be %v; got %v", name, want, value)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithDeniedHeaders(true), WithHeaderStyle(HeaderStyleIETF)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	for name, want := range map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "RateLimit-Policy": "10;w=60", "Retry-After": "2"} {
+		if value := rr.Header().Get(name); value != want {
+			t.Errorf("expected %s to be %v; got %v", name, want, value)
+		}
+	}
+}
+
+// Test the reset time is written in Unix seconds, rounded up, with the legacy style
+func TestAdvancedMiddlewareResetHeader(t *testing.T) {
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{Limit: 10, Remaining: 9, ResetAt: time.Unix(1700000000, 500)}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if reset := rr.Header().Get("X-RateLimit-Reset"); reset != "1700000001" {
+		t.Errorf("expected X-RateLimit-Reset to be 1700000001; got %v", reset)
+	}
+}
+
+// Test the reset time is written in seconds from now by the limiter clock, rounded up, with the IETF
+// style
+func TestAdvancedMiddlewareIETFResetHeader(t *testing.T) {
+	// The clock is 20 seconds into a minute.
+	limiter := NewFixedWindowLimiter(10, time.Minute, WithClock(newWaitRecordingClock()))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	New(limiter, WithHeaderStyle(HeaderStyleIETF))(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if reset := rr.Header().Get("RateLimit-Reset"); reset != "40" {
+		t.Errorf("expected RateLimit-Reset to be 40; got %v", reset)
+	}
+}
+
+// Test the Retry-After HTTP date is computed from the clock of the rate limiter
+func TestAdvancedMiddlewareRetryAfterDateClock(t *testing.T) {
+	limiter := NewFixedWindowLimiter(1, time.Minute, WithClock(newWaitRecordingClock()))
+	handler := New(limiter, WithRetryAfter(RetryAfterHTTPDate))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	expected := time.Unix(1700000040, 0).UTC().Format(http.TimeFormat)
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != expected {
+		t.Errorf("expected Retry-After to be %v; got %v", expected, retryAfter)
+	}
+}
+
+// Test headers are renamed or suppressed with WithHeaderNames
+func TestAdvancedMiddlewareWithHeaderNames(t *testing.T) {
+	isAllowed := true
+	mockLimiter := &MockAdvancedRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return isAllowed, nil
+		},
+		GetRateLimitDataFunc: func(r *http.Request) RateLimitData {
+			return RateLimitData{Limit: 10, Remaining: 4, RetryAfter: 1500 * time.Millisecond, Window: time.Minute, ResetAt: time.Now().Add(1500 * time.Millisecond)}
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	names := HeaderNames{Limit: "-", Remaining: "x-quota-remaining", Policy: "-", RetryAfter: "X-Quota-Retry-After"}
+
+	rr := httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithHeaderNames(names)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "" {
+		t.Errorf("expected no X-RateLimit-Limit header; got %v", limit)
+	}
+	if remaining := rr.Header().Get("X-Quota-Remaining"); remaining != "4" {
+		t.Errorf("expected X-Quota-Remaining to be 4; got %v", remaining)
+	}
+	if remaining := rr.Header().Get("X-RateLimit-Remaining"); remaining != "" {
+		t.Errorf("expected no X-RateLimit-Remaining header; got %v", remaining)
+	}
+
+	rr = httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithHeaderNames(names), WithHeaderStyle(HeaderStyleIETF)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	for name, want := range map[string]string{"RateLimit-Limit": "", "X-Quota-Remaining": "4", "RateLimit-Reset": "2", "RateLimit-Policy": ""} {
+		if value := rr.Header().Get(name); value != want {
+			t.Errorf("expected %s to be %q; got %q", name, want, value)
+		}
+	}
+
+	isAllowed = false
+	rr = httptest.NewRecorder()
+	AdvancedMiddleware(mockLimiter, handler, WithHeaderNames(names), WithHeaderStyle(HeaderStyleIETF)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if retryAfter := rr.Header().Get("X-Quota-Retry-After"); retryAfter != "2" {
+		t.Errorf("expected X-Quota-Retry-After to be 2; got %v", retryAfter)
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "" {
+		t.Errorf("expected no Retry-After header; got %v", retryAfter)
+	}
+}
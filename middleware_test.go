@@ -79,3 +79,144 @@ func TestMiddlewareHandlesError(t *testing.T) {
 		t.Errorf("expected status Internal Server Error; got %v", rr.Code)
 	}
 }
+
+// Test middleware responds to denied requests with a custom handler
+func TestMiddlewareCustomDeniedHandler(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	deniedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"slow down"}`))
+	})
+	middleware := Middleware(mockLimiter, handler, WithDeniedHandler(deniedHandler))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status Too Many Requests; got %v", rr.Code)
+	}
+	if body := rr.Body.String(); body != `{"error":"slow down"}` {
+		t.Errorf("expected custom body; got %v", body)
+	}
+}
+
+// Test middleware passes rate limiter errors to a custom error handler
+func TestMiddlewareCustomErrorHandler(t *testing.T) {
+	limiterErr := fmt.Errorf("rate limiter error")
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, limiterErr
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var handledErr error
+	errorHandler := func(w http.ResponseWriter, r *http.Request, err error) {
+		handledErr = err
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	middleware := Middleware(mockLimiter, handler, WithErrorHandler(errorHandler))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status Service Unavailable; got %v", rr.Code)
+	}
+	if handledErr != limiterErr {
+		t.Errorf("expected error handler to receive %v; got %v", limiterErr, handledErr)
+	}
+}
+
+// Test middleware applies the configured failure policy to rate limiter errors
+func TestMiddlewareFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy FailurePolicy
+		want   int
+	}{
+		{FailWithError, http.StatusInternalServerError},
+		{FailOpen, http.StatusOK},
+		{FailClosed, http.StatusTooManyRequests},
+	}
+	for _, test := range tests {
+		mockLimiter := &MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return false, fmt.Errorf("rate limiter error")
+			},
+		}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		middleware := Middleware(mockLimiter, handler, WithFailurePolicy(test.policy))
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		rr := httptest.NewRecorder()
+
+		middleware.ServeHTTP(rr, req)
+
+		if rr.Code != test.want {
+			t.Errorf("policy %v: expected status %v; got %v", test.policy, test.want, rr.Code)
+		}
+	}
+}
+
+// Test the middleware does not allocate on allowed requests
+func TestMiddlewareDoesNotAllocate(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+	}
+	handler := Middleware(mockLimiter, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	w := discardResponseWriter{header: make(http.Header)}
+
+	if allocs := testing.AllocsPerRun(100, func() { handler.ServeHTTP(w, req) }); allocs != 0 {
+		t.Errorf("expected no allocations; got %v", allocs)
+	}
+}
+
+// Benchmark the overhead of the middleware on allowed requests
+func BenchmarkMiddleware(b *testing.B) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return true, nil
+		},
+	}
+	benchmarkHandler(b, Middleware(mockLimiter, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+}
+
+// ResponseWriter discarding the response, so that benchmarks measure the middleware only
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardResponseWriter) WriteHeader(int) {}
+
+func benchmarkHandler(b *testing.B, handler http.Handler) {
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	w := discardResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,117 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// MultiRegionLimiter is a [RateLimiter] and [AdvancedRateLimiter] splitting a global limit between
+// the regions of a service sharing a [MultiRegionStore], such as 1000 requests per minute per API
+// key across all regions, of which each region may admit at most half on its own.
+//
+// Behavior:
+//   - Requests are checked against the regional limit first, a share of the global limit counted
+//     in the store of the local region only, then against the global limit, counted in every
+//     region by the replication of the store. A request is allowed only if both allow it.
+//   - Both checks are made against the store of the local region, so that no request waits for a
+//     cross-region round trip.
+//   - [MultiRegionLimiter.GetRateLimitData] reports whichever limit is closest to being exhausted.
+//
+// The share sets the trade-off between availability and accuracy. The global limit is enforced
+// with a lag of one sync interval plus the latency between the regions, so each region may admit
+// the requests the others admitted within that lag on top of it. A share of 1 lets a region use
+// the whole limit when the others are idle, at the cost of the largest overshoot, while a share of
+// 1/n for n regions splits the limit statically, so that it is never exceeded but idle regions
+// leave their share unused. Shares in between bound the overshoot to the regional limit of the
+// regions admitting requests concurrently.
+//
+// A MultiRegionLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter, err := NewMultiRegionLimiter(
+//		Config{Algorithm: AlgorithmSlidingWindowCounter, Limit: 1000, Window: time.Minute},
+//		0.5, multiRegionStore, WithKeyFunc(KeyByHeader("X-API-Key")))
+type MultiRegionLimiter struct {
+	regional builtinLimiter
+	global   builtinLimiter
+	chain    *ChainLimiter
+}
+
+// NewMultiRegionLimiter creates a new [MultiRegionLimiter] enforcing config across the regions of
+// store, of which the local region may admit up to share, rounded up. The options are applied to
+// both limits, except for the store set with [WithStore], which is replaced by store. The
+// algorithm of config must keep counters, so that the counts of the regions can be merged:
+// [AlgorithmFixedWindow] or [AlgorithmSlidingWindowCounter]. An error wrapping [ErrInvalidConfig]
+// is returned if config is invalid or uses another algorithm, or if share is not between 0,
+// excluded, and 1.
+func NewMultiRegionLimiter(config Config, share float64, store *MultiRegionStore, opts ...LimiterOption) (*MultiRegionLimiter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Algorithm != AlgorithmFixedWindow && config.Algorithm != AlgorithmSlidingWindowCounter {
+		return nil, fmt.Errorf("%w: algorithm %q cannot be replicated across regions", ErrInvalidConfig, config.Algorithm)
+	}
+	if !(share > 0 && share <= 1) {
+		return nil, fmt.Errorf("%w: regional share must be between 0 and 1", ErrInvalidConfig)
+	}
+	regionalConfig := config
+	regionalConfig.Limit = int(math.Ceil(share * float64(config.Limit)))
+	// The regional limit is kept in the store of the local region, so that it is not replicated.
+	regional := newBuiltinLimiter(regionalConfig, append(append([]LimiterOption(nil), opts...),
+		WithStore(prefixedStore{Store: store.Store, prefix: "regional:"}),
+		withTopKeys(nil))...)
+	global := newBuiltinLimiter(config, append(append([]LimiterOption(nil), opts...),
+		WithStore(prefixedStore{Store: store, prefix: "global:"}))...)
+	return &MultiRegionLimiter{
+		regional: regional,
+		global:   global,
+		chain:    NewChainLimiter(regional, global),
+	}, nil
+}
+
+// IsAllowed checks the request against the regional and the global limits. It returns true if both
+// allowed it, false otherwise. An error is returned if a limit fails, or if the client cannot be
+// identified.
+func (l *MultiRegionLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the limits.
+func (l *MultiRegionLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.chain.IsAllowedContext(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by the request from both limits. It implements
+// [RefundableRateLimiter].
+func (l *MultiRegionLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return l.chain.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the rate limit data of the limit with the fewest remaining requests.
+func (l *MultiRegionLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	return l.chain.GetRateLimitData(r)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. Its global
+// usage is reset in every region, and its regional usage in the local region only.
+func (l *MultiRegionLimiter) Reset(ctx context.Context, key string) error {
+	if err := l.global.Reset(ctx, key); err != nil {
+		return err
+	}
+	return l.regional.Reset(ctx, key)
+}
+
+// Block denies all requests of the client identified by key for d, starting now, in every region.
+// Together with Reset, it implements [ManagedLimiter].
+func (l *MultiRegionLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	return l.global.Block(ctx, key, d)
+}
+
+// key returns the key identifying the client making the request.
+func (l *MultiRegionLimiter) key(r *http.Request) (string, error) {
+	return l.global.key(r)
+}
@@ -0,0 +1,244 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MultiRegionStore is a [Store] for services deployed in several regions, each with its own
+// store, such as a Redis deployment per region. Limits are enforced against the store of the
+// local region, without any cross-region round trip, and the writes of each region are replicated
+// asynchronously to the stores of the others, so that every region converges to the counts of all
+// of them.
+//
+// Behavior:
+//   - All operations are applied to the store of the local region, and return its results.
+//   - Increments, Set, and Delete are also queued for the stores of the other regions, and
+//     replicated once per sync interval. Increments of the same key are summed into one, so that
+//     each region receives at most one increment per key and interval, and a Set or Delete
+//     replaces the writes to its key queued before it.
+//   - Replication errors are reported to the error handler, and the writes of failed
+//     replications are lost, so that an unreachable region does not hold back the others.
+//   - CompareAndSwap is not replicated, since concurrent swaps in different regions cannot be
+//     merged.
+//
+// Counters converge because increments commute: the counter of a key in each region is the sum of
+// the increments of every region, each seen after at most one sync interval plus the latency
+// between the regions. Counter-based limiters, such as [FixedWindowLimiter],
+// [SlidingWindowCounterLimiter], and [QuotaLimiter], thus enforce a global limit, exceeded by at
+// most the requests the other regions admitted within that lag. Limiters keeping other state, such
+// as [TokenBucketLimiter], update it with CompareAndSwap, and so enforce their limit per region.
+// [MultiRegionLimiter] bounds the share of a global limit each region may admit on its own.
+//
+// A MultiRegionStore is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	store := NewMultiRegionStore("eu-west-1", map[string]Store{
+//		"eu-west-1": euStore,
+//		"us-east-1": usStore,
+//	}, 100*time.Millisecond, func(err error) { log.Print(err) })
+//	defer store.Close(context.Background())
+//	limiter := NewFixedWindowLimiter(1000, time.Minute, WithStore(store))
+type MultiRegionStore struct {
+	Store
+	region       string
+	remotes      map[string]Store
+	syncInterval time.Duration
+	onError      func(error)
+
+	mu      sync.Mutex
+	pending map[string]*replicatedWrite
+	timer   *time.Timer
+}
+
+// replicatedWrite holds the writes to a key queued for replication: a Set of value, or a Delete
+// if deleted is true, if any, followed by an increment of delta.
+type replicatedWrite struct {
+	set     bool
+	deleted bool
+	value   []byte
+	ttl     time.Duration
+
+	delta    int64
+	deltaTTL time.Duration
+}
+
+// NewMultiRegionStore creates a new [MultiRegionStore] for the given region, enforcing limits
+// against stores[region] and replicating its writes to the other stores every syncInterval. The
+// errors of the replications are reported to onError, which may be nil to ignore them.
+//
+// It panics if stores has no store for region, or if syncInterval is not positive.
+func NewMultiRegionStore(region string, stores map[string]Store, syncInterval time.Duration, onError func(error)) *MultiRegionStore {
+	local, ok := stores[region]
+	if !ok {
+		panic("cerberus: no store for the local region")
+	}
+	if syncInterval <= 0 {
+		panic("cerberus: sync interval must be positive")
+	}
+	remotes := make(map[string]Store, len(stores)-1)
+	for name, store := range stores {
+		if name != region {
+			remotes[name] = store
+		}
+	}
+	return &MultiRegionStore{
+		Store:        local,
+		region:       region,
+		remotes:      remotes,
+		syncInterval: syncInterval,
+		onError:      onError,
+		pending:      make(map[string]*replicatedWrite),
+	}
+}
+
+// Region returns the name of the local region.
+func (s *MultiRegionStore) Region() string {
+	return s.region
+}
+
+// Set stores value under key in the store of the local region, and queues the write for the other
+// regions.
+func (s *MultiRegionStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.Store.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	s.queue(key, func(write *replicatedWrite) {
+		*write = replicatedWrite{set: true, value: bytes.Clone(value), ttl: ttl}
+	})
+	return nil
+}
+
+// Increment adds delta to the counter of key in the store of the local region, and queues the
+// increment for the other regions. It returns the value of the counter in the local region, which
+// includes the increments replicated from the other regions so far.
+func (s *MultiRegionStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	value, err := s.Store.Increment(ctx, key, delta, ttl)
+	if err != nil {
+		return 0, err
+	}
+	s.queue(key, func(write *replicatedWrite) {
+		write.delta += delta
+		write.deltaTTL = ttl
+	})
+	return value, nil
+}
+
+// Delete removes key from the store of the local region, and queues the deletion for the other
+// regions.
+func (s *MultiRegionStore) Delete(ctx context.Context, key string) error {
+	if err := s.Store.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.queue(key, func(write *replicatedWrite) {
+		*write = replicatedWrite{deleted: true}
+	})
+	return nil
+}
+
+// ScanKeys returns the keys of the store of the local region starting with prefix. An error
+// wrapping [errors.ErrUnsupported] is returned if it does not implement [KeyScanner].
+func (s *MultiRegionStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	return scanKeys(ctx, s.Store, prefix)
+}
+
+// Flush replicates the queued writes to the other regions immediately, and waits for them to be
+// applied. It should be called before shutting down, so that the writes of the last interval are
+// not lost.
+func (s *MultiRegionStore) Flush() {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	pending := s.pending
+	s.pending = make(map[string]*replicatedWrite)
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for region, store := range s.remotes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := replicate(context.Background(), store, pending); err != nil && s.onError != nil {
+				s.onError(fmt.Errorf("region %s: %w", region, err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Close replicates the queued writes, like Flush, waiting for them to be applied until ctx is done,
+// in which case it returns the error of ctx and the replication completes in the background. The
+// store remains usable afterwards, and the stores of the regions are not closed. It implements
+// [Closer].
+func (s *MultiRegionStore) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Flush()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queue applies update to the writes to key queued for replication, and schedules the next sync if
+// none is.
+func (s *MultiRegionStore) queue(key string, update func(*replicatedWrite)) {
+	if len(s.remotes) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	write, ok := s.pending[key]
+	if !ok {
+		write = &replicatedWrite{}
+		s.pending[key] = write
+	}
+	update(write)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.syncInterval, s.Flush)
+	}
+}
+
+// replicate applies the writes of pending to store: the Set and Delete operations first, then the
+// increments, in a single call if store implements [BatchIncrementer]. The first error
+// encountered is returned.
+func replicate(ctx context.Context, store Store, pending map[string]*replicatedWrite) error {
+	var firstErr error
+	var ops []IncrementOp
+	for key, write := range pending {
+		var err error
+		switch {
+		case write.deleted:
+			err = store.Delete(ctx, key)
+		case write.set:
+			err = store.Set(ctx, key, write.value, write.ttl)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if write.delta != 0 {
+			ops = append(ops, IncrementOp{Key: key, Delta: write.delta, TTL: write.deltaTTL})
+		}
+	}
+	for _, result := range incrementBatch(ctx, store, ops) {
+		if result.Err != nil && firstErr == nil {
+			firstErr = result.Err
+		}
+	}
+	return firstErr
+}
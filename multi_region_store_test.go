@@ -0,0 +1,136 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRegions returns a memory store for each of the regions eu and us.
+func newTestRegions() map[string]Store {
+	return map[string]Store{
+		"eu": NewMemoryStore(WithCleanupInterval(0)),
+		"us": NewMemoryStore(WithCleanupInterval(0)),
+	}
+}
+
+// Test writes are applied locally and replicated to the other regions on flush
+func TestMultiRegionStoreReplicates(t *testing.T) {
+	ctx := context.Background()
+	regions := newTestRegions()
+	eu := NewMultiRegionStore("eu", regions, time.Hour, nil)
+	us := NewMultiRegionStore("us", regions, time.Hour, nil)
+
+	for i := range 3 {
+		if value, err := eu.Increment(ctx, "counter", 1, time.Minute); value != int64(i+1) || err != nil {
+			t.Fatalf("expected local value %d; got %d, %v", i+1, value, err)
+		}
+	}
+	us.Increment(ctx, "counter", 2, time.Minute)
+	eu.Set(ctx, "value", []byte("eu"), time.Minute)
+	if value, _ := regions["us"].Get(ctx, "value"); value != nil {
+		t.Errorf("expected writes not to be replicated before the sync; got %q", value)
+	}
+
+	eu.Flush()
+	us.Flush()
+	for name, store := range regions {
+		if value, _ := store.Get(ctx, "counter"); string(value) != "5" {
+			t.Errorf("expected the counter of %s to converge to 5; got %q", name, value)
+		}
+		if value, _ := store.Get(ctx, "value"); string(value) != "eu" {
+			t.Errorf("expected the value to be replicated to %s; got %q", name, value)
+		}
+		if ttl, _ := store.TTL(ctx, "counter"); ttl <= 0 {
+			t.Errorf("expected the counter of %s to expire; got TTL %v", name, ttl)
+		}
+	}
+	if region := eu.Region(); region != "eu" {
+		t.Errorf("expected region eu; got %q", region)
+	}
+}
+
+// Test Set and Delete replace the writes to their key queued before them
+func TestMultiRegionStoreOverwrites(t *testing.T) {
+	ctx := context.Background()
+	regions := newTestRegions()
+	regions["us"].Increment(ctx, "deleted", 10, 0)
+	eu := NewMultiRegionStore("eu", regions, time.Hour, nil)
+
+	eu.Increment(ctx, "deleted", 1, 0)
+	eu.Delete(ctx, "deleted")
+	eu.Increment(ctx, "set", 1, 0)
+	eu.Set(ctx, "set", []byte("7"), 0)
+	eu.Increment(ctx, "set", 1, 0)
+	eu.Flush()
+
+	if value, _ := regions["us"].Get(ctx, "deleted"); value != nil {
+		t.Errorf("expected the deletion to be replicated; got %q", value)
+	}
+	if value, _ := regions["us"].Get(ctx, "set"); string(value) != "8" {
+		t.Errorf("expected the set and the later increment to be replicated; got %q", value)
+	}
+}
+
+// Test writes are replicated after the sync interval
+func TestMultiRegionStoreSync(t *testing.T) {
+	ctx := context.Background()
+	regions := newTestRegions()
+	eu := NewMultiRegionStore("eu", regions, 5*time.Millisecond, nil)
+
+	eu.Increment(ctx, "counter", 1, 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if value, _ := regions["us"].Get(ctx, "counter"); string(value) == "1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the increment to be replicated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := eu.Close(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Test replication errors are reported with their region without failing local writes
+func TestMultiRegionStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	regions := newTestRegions()
+	regions["us"] = &failingStore{Store: regions["us"], failing: true}
+	var errs []error
+	eu := NewMultiRegionStore("eu", regions, time.Hour, func(err error) { errs = append(errs, err) })
+
+	if err := eu.Set(ctx, "value", []byte("eu"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eu.Flush()
+	if len(errs) != 1 || !errors.Is(errs[0], errStoreDown) || !strings.Contains(errs[0].Error(), "us") {
+		t.Errorf("expected an error of region us; got %v", errs)
+	}
+}
+
+// Test NewMultiRegionStore panics with invalid arguments
+func TestMultiRegionStorePanics(t *testing.T) {
+	tests := []struct {
+		name         string
+		region       string
+		syncInterval time.Duration
+	}{
+		{"unknown region", "ap", time.Second},
+		{"zero sync interval", "eu", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			NewMultiRegionStore(tt.region, newTestRegions(), tt.syncInterval, nil)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test each region admits its share of the limit, and the regions together the whole limit
+func TestMultiRegionLimiter(t *testing.T) {
+	regions := newTestRegions()
+	clock := newWaitRecordingClock()
+	config := Config{Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Minute}
+	euStore := NewMultiRegionStore("eu", regions, time.Hour, nil)
+	usStore := NewMultiRegionStore("us", regions, time.Hour, nil)
+	eu, err := NewMultiRegionLimiter(config, 0.6, euStore, WithClock(clock))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	us, err := NewMultiRegionLimiter(config, 0.6, usStore, WithClock(clock))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := newRequestFrom("192.0.2.1:1234")
+
+	admit := func(limiter *MultiRegionLimiter) int {
+		admitted := 0
+		for range 10 {
+			if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+				admitted++
+			}
+		}
+		return admitted
+	}
+	if admitted := admit(eu); admitted != 6 {
+		t.Errorf("expected eu to admit its share of 6 requests; got %d", admitted)
+	}
+	euStore.Flush()
+	if admitted := admit(us); admitted != 4 {
+		t.Errorf("expected us to admit the 4 requests left globally; got %d", admitted)
+	}
+	if data := us.GetRateLimitData(req); data.Limit != 10 || data.Remaining != 0 {
+		t.Errorf("expected the global limit to be exhausted; got %+v", data)
+	}
+
+	if err := eu.Reset(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	euStore.Flush()
+	// The global usage is reset in us as well, but the regional usage of us is kept.
+	if admitted := admit(us); admitted != 2 {
+		t.Errorf("expected us to admit the 2 requests left of its share; got %d", admitted)
+	}
+}
+
+// Test blocks are replicated to every region
+func TestMultiRegionLimiterBlock(t *testing.T) {
+	regions := newTestRegions()
+	config := Config{Algorithm: AlgorithmSlidingWindowCounter, Limit: 10, Window: time.Minute}
+	euStore := NewMultiRegionStore("eu", regions, time.Hour, nil)
+	eu, _ := NewMultiRegionLimiter(config, 1, euStore)
+	us, _ := NewMultiRegionLimiter(config, 1, NewMultiRegionStore("us", regions, time.Hour, nil))
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if err := eu.Block(context.Background(), "192.0.2.1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	euStore.Flush()
+	if isAllowed, err := us.IsAllowed(req); isAllowed || err != nil {
+		t.Errorf("expected the client to be blocked in us; got %v, %v", isAllowed, err)
+	}
+}
+
+// Test NewMultiRegionLimiter rejects invalid configs and shares
+func TestMultiRegionLimiterInvalid(t *testing.T) {
+	store := NewMultiRegionStore("eu", newTestRegions(), time.Hour, nil)
+	tests := []struct {
+		name   string
+		config Config
+		share  float64
+	}{
+		{"invalid config", Config{Algorithm: AlgorithmFixedWindow}, 1},
+		{"token bucket", Config{Algorithm: AlgorithmTokenBucket, Limit: 10, Window: time.Minute}, 1},
+		{"zero share", Config{Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Minute}, 0},
+		{"share above 1", Config{Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Minute}, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMultiRegionLimiter(tt.config, tt.share, store); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected %v; got %v", ErrInvalidConfig, err)
+			}
+		})
+	}
+}
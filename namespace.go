@@ -0,0 +1,196 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// NamespaceConfigFunc returns the config of the limit of a namespace, such as a tenant, overriding
+// the default config of a [NamespacedLimiter]. It returns false to use the default config, and an
+// error if the config cannot be determined. It is called for every request, so configs held in a
+// database should be cached.
+type NamespaceConfigFunc func(ctx context.Context, namespace string) (Config, bool, error)
+
+// NamespacedLimiter is a [RateLimiter] and [AdvancedRateLimiter] serving many namespaces, such as
+// the tenants of a multi-tenant deployment, with a single instance. Each namespace has its own
+// keyspace and its own limit, so that the traffic of one tenant cannot skew the counters of
+// another, even if they share a [Store] and their clients have the same keys.
+//
+// Behavior:
+//   - The namespace of a request is derived with a [KeyFunc], such as [KeyByHeader] or
+//     [KeyByJWTClaim]. Requests without a namespace are rejected with the error of the KeyFunc.
+//   - The client is identified within its namespace by the KeyFunc set with [WithKeyFunc], its IP
+//     address by default.
+//   - Each namespace enforces the config returned by the [NamespaceConfigFunc], or the default
+//     config. Like with a [DynamicLimiter], the usage of each client carries over when the config
+//     of its namespace changes but the algorithm does not.
+//
+// A limiter is kept in memory for every namespace seen, so namespaces should not be controlled by
+// unauthenticated clients.
+//
+// A NamespacedLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	limiter, err := NewNamespacedLimiter(KeyByJWTClaim("tenant_id", verify),
+//		Config{Algorithm: AlgorithmTokenBucket, Limit: 100, Window: time.Minute}, tenantConfig,
+//		WithKeyFunc(KeyByJWTClaim("sub", verify)), WithStore(sharedStore))
+type NamespacedLimiter struct {
+	namespaceFunc KeyFunc
+	config        Config
+	configFunc    NamespaceConfigFunc
+	opts          []LimiterOption
+	store         Store
+
+	// namespaces maps namespaces to their *DynamicLimiter.
+	namespaces sync.Map
+}
+
+// NewNamespacedLimiter creates a new [NamespacedLimiter] deriving the namespace of requests with
+// namespaceFunc and enforcing config in namespaces for which configFunc returns no override. A nil
+// configFunc enforces config in every namespace. The options are applied to the limiter of every
+// namespace. An error wrapping [ErrInvalidConfig] is returned if config is invalid.
+//
+// It panics if namespaceFunc is nil.
+func NewNamespacedLimiter(namespaceFunc KeyFunc, config Config, configFunc NamespaceConfigFunc, opts ...LimiterOption) (*NamespacedLimiter, error) {
+	if namespaceFunc == nil {
+		panic("cerberus: namespace function must not be nil")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &NamespacedLimiter{
+		namespaceFunc: namespaceFunc,
+		config:        config,
+		configFunc:    configFunc,
+		opts:          opts,
+		store:         newLimiterOptions(opts).store,
+	}, nil
+}
+
+// IsAllowed checks the request against the limit of its namespace.
+func (l *NamespacedLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store and to resolve
+// the config of the namespace.
+func (l *NamespacedLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter, err := l.limiter(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	return rateLimiter.IsAllowedContext(ctx, r)
+}
+
+// Peek reports whether the request would be allowed by the limit of its namespace, without
+// counting it.
+func (l *NamespacedLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter, err := l.limiter(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	return rateLimiter.Peek(ctx, r)
+}
+
+// Commit counts the request against the limit of its namespace, even if that exceeds the limit.
+func (l *NamespacedLimiter) Commit(ctx context.Context, r *http.Request) error {
+	rateLimiter, err := l.limiter(ctx, r)
+	if err != nil {
+		return err
+	}
+	return rateLimiter.Commit(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed by the limit of its
+// namespace.
+func (l *NamespacedLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	rateLimiter, err := l.limiter(ctx, r)
+	if err != nil {
+		return err
+	}
+	return rateLimiter.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the current state of the limit of the client making the request within
+// its namespace. The zero RateLimitData is returned if the namespace or its config cannot be
+// determined.
+func (l *NamespacedLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	rateLimiter, err := l.limiter(r.Context(), r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	return rateLimiter.GetRateLimitData(r)
+}
+
+// Namespace returns the limiter of namespace, creating it with the default config if no request
+// of the namespace has been seen yet. It gives access to the clients of the namespace, for example
+// to reset them with [DynamicLimiter.Reset].
+func (l *NamespacedLimiter) Namespace(namespace string) *DynamicLimiter {
+	if rateLimiter, ok := l.namespaces.Load(namespace); ok {
+		return rateLimiter.(*DynamicLimiter)
+	}
+	opts := append(append([]LimiterOption(nil), l.opts...),
+		WithStore(prefixedStore{Store: l.store, prefix: url.PathEscape(namespace) + "/"}))
+	// The default config has been validated, so no error can occur.
+	rateLimiter, _ := NewDynamicLimiter(l.config, opts...)
+	actual, _ := l.namespaces.LoadOrStore(namespace, rateLimiter)
+	return actual.(*DynamicLimiter)
+}
+
+// Namespaces returns the namespaces seen so far, in no particular order.
+func (l *NamespacedLimiter) Namespaces() []string {
+	var namespaces []string
+	l.namespaces.Range(func(namespace, _ any) bool {
+		namespaces = append(namespaces, namespace.(string))
+		return true
+	})
+	return namespaces
+}
+
+// limiter returns the limiter of the namespace of the request, updated to the current config of
+// the namespace.
+func (l *NamespacedLimiter) limiter(ctx context.Context, r *http.Request) (*DynamicLimiter, error) {
+	namespace, err := l.namespaceFunc(r)
+	if err != nil {
+		return nil, err
+	}
+	config := l.config
+	if l.configFunc != nil {
+		override, ok, err := l.configFunc(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			config = override
+		}
+	}
+	rateLimiter := l.Namespace(namespace)
+	if rateLimiter.Config() != config {
+		if err := rateLimiter.UpdateConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return rateLimiter, nil
+}
+
+// Close stops the janitor of the default store of the namespaces, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *NamespacedLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// key returns the key identifying the client making the request, qualified by its namespace.
+func (l *NamespacedLimiter) key(r *http.Request) (string, error) {
+	namespace, err := l.namespaceFunc(r)
+	if err != nil {
+		return "", err
+	}
+	key, err := l.Namespace(namespace).key(r)
+	if err != nil {
+		return "", err
+	}
+	return url.PathEscape(namespace) + "/" + key, nil
+}
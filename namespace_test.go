@@ -0,0 +1,97 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRequestInNamespace(namespace string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Tenant", namespace)
+	return req
+}
+
+// Test namespaces have isolated keyspaces in a shared store
+func TestNamespacedLimiterIsolatesNamespaces(t *testing.T) {
+	config := Config{Algorithm: AlgorithmFixedWindow, Limit: 1, Window: time.Hour}
+	limiter, err := NewNamespacedLimiter(KeyByHeader("X-Tenant"), config, nil, WithStore(NewMemoryStore()))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if isAllowed, err := limiter.IsAllowed(newRequestInNamespace("a")); err != nil || !isAllowed {
+		t.Fatalf("expected the first request to be allowed; got %v, %v", isAllowed, err)
+	}
+	if isAllowed, _ := limiter.IsAllowed(newRequestInNamespace("a")); isAllowed {
+		t.Errorf("expected the second request in the namespace to be denied")
+	}
+	if isAllowed, _ := limiter.IsAllowed(newRequestInNamespace("b")); !isAllowed {
+		t.Errorf("expected the same client in another namespace to be allowed")
+	}
+	if key, _ := limiter.key(newRequestInNamespace("a/b")); key != "a%2Fb/192.0.2.1" {
+		t.Errorf("expected key a%%2Fb/192.0.2.1; got %q", key)
+	}
+}
+
+// Test namespaces enforce the config overrides resolved for them
+func TestNamespacedLimiterOverrides(t *testing.T) {
+	config := Config{Algorithm: AlgorithmFixedWindow, Limit: 1, Window: time.Hour}
+	premium := Config{Algorithm: AlgorithmFixedWindow, Limit: 3, Window: time.Hour}
+	errLookup := errors.New("lookup failed")
+	configFunc := func(_ context.Context, namespace string) (Config, bool, error) {
+		switch namespace {
+		case "premium":
+			return premium, true, nil
+		case "broken":
+			return Config{}, false, errLookup
+		}
+		return Config{}, false, nil
+	}
+	limiter, _ := NewNamespacedLimiter(KeyByHeader("X-Tenant"), config, configFunc)
+
+	if data := limiter.GetRateLimitData(newRequestInNamespace("premium")); data.Limit != 3 {
+		t.Errorf("expected limit 3 in the premium namespace; got %d", data.Limit)
+	}
+	if data := limiter.GetRateLimitData(newRequestInNamespace("basic")); data.Limit != 1 {
+		t.Errorf("expected limit 1 in the basic namespace; got %d", data.Limit)
+	}
+	if _, err := limiter.IsAllowed(newRequestInNamespace("broken")); !errors.Is(err, errLookup) {
+		t.Errorf("expected the lookup error; got %v", err)
+	}
+	if _, err := limiter.IsAllowed(httptest.NewRequest(http.MethodGet, "/api", nil)); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey without a namespace; got %v", err)
+	}
+	if got := limiter.Namespace("premium").Config(); got != premium {
+		t.Errorf("expected the premium config; got %+v", got)
+	}
+}
+
+// Test clients can be managed within their namespace
+func TestNamespacedLimiterNamespace(t *testing.T) {
+	config := Config{Algorithm: AlgorithmFixedWindow, Limit: 1, Window: time.Hour}
+	limiter, _ := NewNamespacedLimiter(KeyByHeader("X-Tenant"), config, nil)
+	req := newRequestInNamespace("a")
+
+	limiter.IsAllowed(req)
+	if err := limiter.Namespace("a").Reset(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected the request to be allowed after a reset")
+	}
+	if namespaces := limiter.Namespaces(); len(namespaces) != 1 || namespaces[0] != "a" {
+		t.Errorf("expected namespaces [a]; got %v", namespaces)
+	}
+}
+
+// Test an invalid default config is rejected
+func TestNamespacedLimiterInvalidConfig(t *testing.T) {
+	if _, err := NewNamespacedLimiter(KeyByHeader("X-Tenant"), Config{}, nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig; got %v", err)
+	}
+}
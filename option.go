@@ -0,0 +1,117 @@
+package cerberus
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures the behavior of the rate limiting middlewares, such as which headers are
+// emitted. Options are passed to the middleware constructors, for example
+// AdvancedMiddleware(myAdvancedRateLimiter, myHandler, WithHeaderStyle(HeaderStyleIETF)).
+type Option func(*options)
+
+// options holds the configuration assembled from a list of [Option] values.
+type options struct {
+	headers       bool
+	headerStyle   HeaderStyle
+	headerNames   HeaderNames
+	headerKeys    headerKeys
+	deniedHandler http.Handler
+	errorHandler  func(http.ResponseWriter, *http.Request, error)
+	failurePolicy FailurePolicy
+	allowlist     []RequestMatcher
+	denylist      []RequestMatcher
+	logger        *slog.Logger
+	events        *EventPublisher
+	maxWait       time.Duration
+	countIf       func(statusCode int) bool
+	refundIf      func(statusCode int, header http.Header) bool
+	retryAfter    RetryAfterFormat
+	deniedHeaders bool
+	backoffHints  bool
+	onAllowed     []func(*http.Request, RateLimitData)
+	onDenied      []func(*http.Request, RateLimitData)
+	onError       []func(*http.Request, error)
+	onBanned      []func(*http.Request, string, time.Duration)
+	shadow        bool
+	rollout       int
+	priority      PriorityFunc
+	reserve       float64
+	penalty       *PenaltyPolicy
+	challenge     *ChallengeHandler
+	longLived     *longLived
+	closers       []Closer
+}
+
+// newOptions applies opts on top of the default configuration.
+func newOptions(opts []Option) options {
+	options := options{
+		headers:       true,
+		headerStyle:   HeaderStyleLegacy,
+		deniedHandler: http.HandlerFunc(tooManyRequests),
+		errorHandler:  internalServerError,
+		rollout:       rolloutBuckets,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.headerKeys = newHeaderKeys(options.headerStyle, options.headerNames)
+	return options
+}
+
+// WithHeaders sets whether rate limit headers are added to responses when the rate limiter
+// implements [AdvancedRateLimiter]. The default is true, except for [Middleware].
+func WithHeaders(enabled bool) Option {
+	return func(o *options) {
+		o.headers = enabled
+	}
+}
+
+// WithHeaderStyle sets the set of headers used to report rate limit information to clients.
+// The default is [HeaderStyleLegacy].
+func WithHeaderStyle(style HeaderStyle) Option {
+	return func(o *options) {
+		o.headerStyle = style
+	}
+}
+
+// WithDeniedHandler sets the handler that responds to requests denied by the rate limiter, for
+// example to return a JSON error body, a localized message, or a redirect to a captcha page. The
+// handler is responsible for writing the status code. Any rate limit headers are set on the
+// response before the handler is called, and the [RateLimitData] of the request is available to
+// the handler with [RateLimitDataFromContext]. The default handler responds with an empty HTTP 429
+// (Too Many Requests); [DeniedResponder] responds with a body describing the limit.
+func WithDeniedHandler(handler http.Handler) Option {
+	return func(o *options) {
+		o.deniedHandler = handler
+	}
+}
+
+// WithErrorHandler sets the function that responds to requests for which the rate limiter
+// returned an error, for example to log the error, emit a metric, or respond with a different
+// status code. The function is responsible for writing the response. The default responds with
+// an empty HTTP 500 (Internal Server Error).
+func WithErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// WithFailurePolicy sets how requests are handled when the rate limiter returns an error, for
+// example because its backing store is unreachable. The default is [FailWithError].
+func WithFailurePolicy(policy FailurePolicy) Option {
+	return func(o *options) {
+		o.failurePolicy = policy
+	}
+}
+
+// tooManyRequests responds with an empty HTTP 429 (Too Many Requests).
+func tooManyRequests(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// internalServerError responds with an empty HTTP 500 (Internal Server Error).
+func internalServerError(w http.ResponseWriter, _ *http.Request, _ error) {
+	w.WriteHeader(http.StatusInternalServerError)
+}
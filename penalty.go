@@ -0,0 +1,154 @@
+package cerberus
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PenaltyPolicy describes how the middleware escalates the repeated denials of a client to
+// temporary bans, with [WithPenaltyPolicy].
+type PenaltyPolicy struct {
+	// Threshold is the number of denials of a client within Window that is tolerated. The client
+	// is banned on the next denial.
+	Threshold int
+	// Window is the period over which denials are counted.
+	Window time.Duration
+	// Ban is the duration of the first ban of a client.
+	Ban time.Duration
+	// Factor multiplies the duration of the ban on each repeat offense, so that with a Ban of 10
+	// minutes and a Factor of 2, a client is banned for 10 minutes, then 20, then 40. The default,
+	// used if Factor is less than 1, is 2.
+	Factor float64
+	// MaxBan caps the duration of bans. Zero means no cap.
+	MaxBan time.Duration
+	// Memory is how long the offenses of a client are remembered after its last ban ends. A client
+	// offending again within Memory is banned for longer. The default, used if Memory is zero or
+	// less, is 24 hours.
+	Memory time.Duration
+	// Store is where denials, offenses, and bans are counted. Sharing a store between instances of
+	// a service makes them escalate together. The default is a new [MemoryStore], which is closed
+	// with the middleware by [Handle.Close].
+	Store Store
+}
+
+// WithPenaltyPolicy bans clients that keep exceeding the rate limit, with bans growing longer on
+// each repeat offense, so that abusive clients are shut out for longer than a window without
+// penalizing occasional bursts. It requires a rate limiter implementing [ManagedLimiter], such as
+// the built-in limiters, which enforces the bans with its Block method.
+//
+// Behavior:
+//   - The denials of each client, identified by the key reported by [KeyOf], are counted over
+//     the Window of the policy. The denial exceeding the Threshold bans the client for the Ban
+//     duration, multiplied by Factor for each earlier ban within Memory, up to MaxBan.
+//   - Bans are reported to the OnBanned hooks set with [WithHooks], so that they can be relayed
+//     to external blocking systems such as firewalls, and logged with [WithLogger].
+//   - Requests denied during a ban do not count toward another one.
+//   - Middlewares in shadow mode do not ban clients. See [WithShadowMode].
+//
+// It panics if Threshold, Window, or Ban is not positive. [New] panics if the rate limiter does
+// not implement [ManagedLimiter].
+//
+// Example usage:
+//
+//	policy := PenaltyPolicy{Threshold: 100, Window: time.Minute, Ban: 10 * time.Minute, MaxBan: 24 * time.Hour}
+//	http.Handle("/", New(myRateLimiter, WithPenaltyPolicy(policy))(myHandler))
+func WithPenaltyPolicy(policy PenaltyPolicy) Option {
+	if policy.Threshold <= 0 || policy.Window <= 0 || policy.Ban <= 0 {
+		panic("cerberus: penalty threshold, window, and ban must be positive")
+	}
+	if policy.Factor < 1 {
+		policy.Factor = 2
+	}
+	if policy.Memory <= 0 {
+		policy.Memory = 24 * time.Hour
+	}
+	return func(o *options) {
+		p := policy
+		if p.Store == nil {
+			store := NewMemoryStore()
+			store.owned = true
+			p.Store = store
+		}
+		o.penalty = &p
+	}
+}
+
+// penalize counts the denial of r by rateLimiter, and bans the client making it if that exceeds
+// the threshold of the policy.
+func (o *options) penalize(r *http.Request, rateLimiter ManagedLimiter) {
+	key, err := KeyOf(rateLimiter, r)
+	if err != nil {
+		return
+	}
+	d, err := o.penalty.offend(r, rateLimiter, key)
+	if err != nil {
+		o.logError(r, rateLimiter, err)
+		return
+	}
+	if d == 0 {
+		return
+	}
+	if o.logEnabled(r.Context(), slog.LevelWarn) {
+		attrs := append(o.requestAttrs(r, rateLimiter), slog.Duration("ban", d))
+		o.logger.LogAttrs(r.Context(), slog.LevelWarn, "client banned", attrs...)
+	}
+	o.banned(r, key, d)
+	o.publishBanned(r, rateLimiter, key, d)
+}
+
+// offend counts a denial of the client identified by key, and bans it with rateLimiter if that
+// exceeds the threshold. It returns the duration of the ban, or zero if the client was not banned.
+func (p *PenaltyPolicy) offend(r *http.Request, rateLimiter ManagedLimiter, key string) (time.Duration, error) {
+	ctx := r.Context()
+	if banned, err := p.Store.TTL(ctx, "penalty:ban:"+key); err != nil || banned > 0 {
+		return 0, err
+	}
+	denials, err := p.Store.Increment(ctx, "penalty:denials:"+key, 1, p.Window)
+	if err != nil || denials != int64(p.Threshold)+1 {
+		return 0, err
+	}
+	offenses, err := p.Store.Increment(ctx, "penalty:offenses:"+key, 1, p.Memory)
+	if err != nil {
+		return 0, err
+	}
+	d := p.banDuration(offenses)
+	// Offenses are remembered from the end of the last ban rather than from the first offense.
+	memory := d + p.Memory
+	if memory < d {
+		memory = math.MaxInt64
+	}
+	if err := p.Store.Set(ctx, "penalty:offenses:"+key, strconv.AppendInt(nil, offenses, 10), memory); err != nil {
+		return 0, err
+	}
+	if err := rateLimiter.Block(ctx, key, d); err != nil {
+		return 0, err
+	}
+	if err := p.Store.Set(ctx, "penalty:ban:"+key, []byte{1}, d); err != nil {
+		return 0, err
+	}
+	return d, p.Store.Delete(ctx, "penalty:denials:"+key)
+}
+
+// pardon lifts the ban of the client identified by key, if any, and forgets its denials since. Its
+// offenses are still remembered.
+func (p *PenaltyPolicy) pardon(r *http.Request, key string) error {
+	if err := p.Store.Delete(r.Context(), "penalty:ban:"+key); err != nil {
+		return err
+	}
+	return p.Store.Delete(r.Context(), "penalty:denials:"+key)
+}
+
+// banDuration returns the duration of the ban for the given offense, counting from 1.
+func (p *PenaltyPolicy) banDuration(offense int64) time.Duration {
+	d := float64(p.Ban) * math.Pow(p.Factor, float64(offense-1))
+	if p.MaxBan > 0 && d > float64(p.MaxBan) {
+		return p.MaxBan
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
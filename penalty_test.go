@@ -0,0 +1,143 @@
+package cerberus
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// blockingLimiter is a managed rate limiter denying every request and recording blocks.
+type blockingLimiter struct {
+	MockRateLimiter
+	blocks []time.Duration
+}
+
+func newBlockingLimiter() *blockingLimiter {
+	return &blockingLimiter{
+		MockRateLimiter: MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				return false, nil
+			},
+		},
+	}
+}
+
+// noContent is a handler responding with an HTTP 204 (No Content).
+var noContent = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func (l *blockingLimiter) Reset(context.Context, string) error {
+	return nil
+}
+
+func (l *blockingLimiter) Block(_ context.Context, _ string, d time.Duration) error {
+	l.blocks = append(l.blocks, d)
+	return nil
+}
+
+func (l *blockingLimiter) key(r *http.Request) (string, error) {
+	return KeyByIP(r)
+}
+
+// Test clients exceeding the threshold of denials are banned and reported to the hooks
+func TestWithPenaltyPolicy(t *testing.T) {
+	limiter := NewFixedWindowLimiter(1, time.Minute)
+	var bans []time.Duration
+	hooks := Hooks{
+		OnBanned: func(r *http.Request, key string, d time.Duration) {
+			if key != "192.0.2.1" {
+				t.Errorf("expected key 192.0.2.1; got %q", key)
+			}
+			bans = append(bans, d)
+		},
+	}
+	policy := PenaltyPolicy{Threshold: 2, Window: time.Minute, Ban: 10 * time.Minute}
+	handler := New(limiter, WithPenaltyPolicy(policy), WithHooks(hooks))(noContent)
+
+	for range 6 {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+
+	if !slices.Equal(bans, []time.Duration{10 * time.Minute}) {
+		t.Errorf("expected a single ban of 10m; got %v", bans)
+	}
+	if data := limiter.GetRateLimitData(newRequestFrom("192.0.2.1:1234")); data.RetryAfter < 9*time.Minute {
+		t.Errorf("expected the client to be blocked for about 10m; got %v", data.RetryAfter)
+	}
+}
+
+// Test bans grow on repeat offenses, and are back to the first duration once offenses are forgotten
+func TestPenaltyPolicyEscalates(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(WithCleanupInterval(0))
+	store.now = clock.Now
+	policy := PenaltyPolicy{Threshold: 1, Window: time.Minute, Ban: 10 * time.Minute, Factor: 3, Memory: time.Hour, Store: store}
+	limiter := newBlockingLimiter()
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for _, wait := range []time.Duration{11 * time.Minute, 31 * time.Minute, 3 * time.Hour, 0} {
+		for range 2 {
+			policy.offend(req, limiter, "192.0.2.1")
+		}
+		clock.Advance(wait)
+	}
+
+	want := []time.Duration{10 * time.Minute, 30 * time.Minute, 90 * time.Minute, 10 * time.Minute}
+	if !slices.Equal(limiter.blocks, want) {
+		t.Errorf("expected bans of %v; got %v", want, limiter.blocks)
+	}
+}
+
+// Test ban durations are capped
+func TestPenaltyPolicyBanDuration(t *testing.T) {
+	policy := PenaltyPolicy{Ban: time.Minute, Factor: 2, MaxBan: time.Hour}
+	if d := policy.banDuration(3); d != 4*time.Minute {
+		t.Errorf("expected 4m; got %v", d)
+	}
+	if d := policy.banDuration(10); d != time.Hour {
+		t.Errorf("expected the ban to be capped to 1h; got %v", d)
+	}
+	policy.MaxBan = 0
+	if d := policy.banDuration(100); d != math.MaxInt64 {
+		t.Errorf("expected the longest duration; got %v", d)
+	}
+}
+
+// Test clients are not banned in shadow mode
+func TestPenaltyPolicyShadowMode(t *testing.T) {
+	limiter := newBlockingLimiter()
+	policy := PenaltyPolicy{Threshold: 1, Window: time.Minute, Ban: time.Minute}
+	handler := New(limiter, WithPenaltyPolicy(policy), WithShadowMode(true))(noContent)
+
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+
+	if len(limiter.blocks) != 0 {
+		t.Errorf("expected no ban; got %v", limiter.blocks)
+	}
+}
+
+// Test invalid policies and rate limiters that cannot ban clients panic
+func TestPenaltyPolicyPanics(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"invalid policy": func() { WithPenaltyPolicy(PenaltyPolicy{Threshold: 1, Window: time.Minute}) },
+		"unmanaged limiter": func() {
+			New(&MockRateLimiter{}, WithPenaltyPolicy(PenaltyPolicy{Threshold: 1, Window: time.Minute, Ban: time.Minute}))
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
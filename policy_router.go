@@ -0,0 +1,206 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PolicyRouter is a [RateLimiter] that applies different rate limiters to different routes, so
+// that a single middleware installed at the root of a server can, for example, limit /login to
+// 5 requests per minute while allowing 1000 requests per minute on /api/*.
+//
+// Routes are registered with Handle using patterns of the form "[METHOD ]PATH". The optional
+// method restricts the route to requests with that method, and may list several methods separated
+// by commas, as in "GET,HEAD /files/*". PATH is matched against the request's URL path using the
+// syntax of [path.Match], where * matches any sequence of characters within a path segment. As an
+// exception, a pattern ending in "/*" also matches everything below it, so "/api/*" matches
+// "/api/users" as well as "/api/users/42", and "/*" matches every path.
+//
+// A single path can carry different limits per method with HandleMethods, as writes usually need
+// tighter limits than reads.
+//
+// Requests are checked against the rate limiter of the first matching route, in the order in
+// which routes were registered. Requests that match no route are checked against the default rate
+// limiter, if one is set, and are allowed otherwise.
+//
+// PolicyRouter implements [AdvancedRateLimiter] and [RateLimiterContext] by delegating to the
+// matching rate limiter when it implements these interfaces. Routes must be registered before
+// the router is used; registering routes concurrently with requests is not safe.
+//
+// Example usage:
+//
+//	router := NewPolicyRouter()
+//	router.Handle("POST /login", NewSlidingWindowLimiter(5, time.Minute))
+//	router.Handle("/api/*", NewTokenBucketLimiter(1000, 1000.0/60))
+//	http.ListenAndServe(":8080", New(router)(mux))
+type PolicyRouter struct {
+	routes   []route
+	fallback RateLimiter
+}
+
+// route is a pattern registered with a [PolicyRouter], parsed into its components. Routes
+// registered with HandleMethods have a rate limiter per method instead of a single one.
+type route struct {
+	methods     []string
+	pathPattern string
+	limiter     RateLimiter
+	byMethod    map[string]RateLimiter
+}
+
+// NewPolicyRouter creates a new [PolicyRouter] without any routes.
+func NewPolicyRouter() *PolicyRouter {
+	return &PolicyRouter{}
+}
+
+// Handle registers rateLimiter for requests matching pattern. It panics if pattern is malformed.
+func (p *PolicyRouter) Handle(pattern string, rateLimiter RateLimiter) {
+	route, err := parseRoute(pattern, rateLimiter)
+	if err != nil {
+		panic(err.Error())
+	}
+	p.routes = append(p.routes, route)
+}
+
+// HandleMethods registers a rate limiter per method for requests matching pattern, such as
+// "/api/items/*", with the syntax of Handle. limiters maps methods, such as "GET" or "POST", to
+// their rate limiter, and the rate limiter under "*", if any, applies to the other methods.
+// Requests with a method without a rate limiter do not match the route, and are checked against
+// the next matching route. It panics if pattern is malformed.
+//
+// Example usage:
+//
+//	router.HandleMethods("/api/items/*", map[string]RateLimiter{
+//		"GET":    NewTokenBucketLimiter(1000, 1000.0/60),
+//		"POST":   NewSlidingWindowLimiter(60, time.Minute),
+//		"DELETE": NewSlidingWindowLimiter(10, time.Minute),
+//	})
+func (p *PolicyRouter) HandleMethods(pattern string, limiters map[string]RateLimiter) {
+	route, err := parseRoute(pattern, nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	route.byMethod = make(map[string]RateLimiter, len(limiters))
+	for method, rateLimiter := range limiters {
+		route.byMethod[strings.ToUpper(strings.TrimSpace(method))] = rateLimiter
+	}
+	p.routes = append(p.routes, route)
+}
+
+// HandleDefault sets the rate limiter for requests that match no route. Without a default rate
+// limiter, such requests are allowed.
+func (p *PolicyRouter) HandleDefault(rateLimiter RateLimiter) {
+	p.fallback = rateLimiter
+}
+
+// IsAllowed checks the request against the rate limiter of the route it matches.
+func (p *PolicyRouter) IsAllowed(r *http.Request) (bool, error) {
+	return p.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiter of the route.
+func (p *PolicyRouter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter := p.Match(r)
+	if rateLimiter == nil {
+		return true, nil
+	}
+	return isAllowed(ctx, rateLimiter, r)
+}
+
+// GetRateLimitData returns the rate limit data reported by the rate limiter of the route the
+// request matches. The zero RateLimitData is returned if that rate limiter does not implement
+// [AdvancedRateLimiter], or if the request matches no route.
+func (p *PolicyRouter) GetRateLimitData(r *http.Request) RateLimitData {
+	if advanced, ok := p.Match(r).(AdvancedRateLimiter); ok {
+		return advanced.GetRateLimitData(r)
+	}
+	return RateLimitData{}
+}
+
+// Match returns the rate limiter responsible for the request: the one of the first matching
+// route, or the default rate limiter. It returns nil if the request is not rate limited.
+func (p *PolicyRouter) Match(r *http.Request) RateLimiter {
+	for _, route := range p.routes {
+		if !route.matches(r) {
+			continue
+		}
+		if rateLimiter, ok := route.limiterFor(r); ok {
+			return rateLimiter
+		}
+	}
+	return p.fallback
+}
+
+// key returns the key identifying the client making the request, as reported by the rate
+// limiter of the route the request matches.
+func (p *PolicyRouter) key(r *http.Request) (string, error) {
+	if k, ok := p.Match(r).(keyer); ok {
+		return k.key(r)
+	}
+	return "", ErrNoKey
+}
+
+// parseRoute parses pattern into a route to rateLimiter. It returns an error if pattern is
+// malformed.
+func parseRoute(pattern string, rateLimiter RateLimiter) (route, error) {
+	route := route{limiter: rateLimiter}
+	methods, pathPattern, hasMethods := strings.Cut(pattern, " ")
+	if hasMethods {
+		route.methods = strings.Split(methods, ",")
+	} else {
+		pathPattern = methods
+	}
+	route.pathPattern = strings.TrimSpace(pathPattern)
+	if !strings.HasPrefix(route.pathPattern, "/") {
+		return route, fmt.Errorf("cerberus: route pattern %q must contain a path starting with /", pattern)
+	}
+	if _, err := path.Match(route.pathPattern, ""); err != nil {
+		return route, fmt.Errorf("cerberus: malformed route pattern %q: %v", pattern, err)
+	}
+	return route, nil
+}
+
+// matches reports whether the request matches the route.
+func (rt route) matches(r *http.Request) bool {
+	if len(rt.methods) > 0 && !containsFold(rt.methods, r.Method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(rt.pathPattern, "/*"); ok {
+		if prefix == "" {
+			return true
+		}
+		for dir := r.URL.Path; dir != "/" && dir != "."; dir = path.Dir(dir) {
+			if matched, _ := path.Match(prefix, path.Dir(dir)); matched {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(rt.pathPattern, r.URL.Path)
+	return matched
+}
+
+// limiterFor returns the rate limiter of the route for the method of the request, and whether the
+// route has one.
+func (rt route) limiterFor(r *http.Request) (RateLimiter, bool) {
+	if rt.byMethod == nil {
+		return rt.limiter, true
+	}
+	if rateLimiter, ok := rt.byMethod[strings.ToUpper(r.Method)]; ok {
+		return rateLimiter, true
+	}
+	rateLimiter, ok := rt.byMethod["*"]
+	return rateLimiter, ok
+}
+
+// containsFold reports whether values contains s, ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimSpace(value), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test requests are matched against routes in registration order
+func TestPolicyRouterMatch(t *testing.T) {
+	login := &MockRateLimiter{}
+	api := &MockRateLimiter{}
+	user := &MockRateLimiter{}
+	fallback := &MockRateLimiter{}
+	router := NewPolicyRouter()
+	router.Handle("POST /login", login)
+	router.Handle("GET,HEAD /users/*/profile", user)
+	router.Handle("/api/*", api)
+	router.HandleDefault(fallback)
+
+	tests := []struct {
+		method string
+		path   string
+		want   RateLimiter
+	}{
+		{http.MethodPost, "/login", login},
+		{http.MethodGet, "/login", fallback},
+		{http.MethodHead, "/users/42/profile", user},
+		{http.MethodGet, "/users/42/settings", fallback},
+		{http.MethodGet, "/api/", api},
+		{http.MethodDelete, "/api/items/42", api},
+		{http.MethodGet, "/apis", fallback},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if got := router.Match(req); got != test.want {
+			t.Errorf("%s %s: matched the wrong rate limiter", test.method, test.path)
+		}
+	}
+}
+
+// Test the catch-all pattern "/*" matches every path
+func TestPolicyRouterCatchAll(t *testing.T) {
+	all := &MockRateLimiter{}
+	router := NewPolicyRouter()
+	router.Handle("/*", all)
+
+	for _, path := range []string{"/", "/x", "/x/y", "/x/y/"} {
+		if got := router.Match(httptest.NewRequest(http.MethodGet, path, nil)); got != all {
+			t.Errorf("%s: expected the catch-all route to match", path)
+		}
+	}
+}
+
+// Test routes registered with HandleMethods apply the rate limiter of the method of the request
+func TestPolicyRouterHandleMethods(t *testing.T) {
+	read := &MockRateLimiter{}
+	write := &MockRateLimiter{}
+	remove := &MockRateLimiter{}
+	api := &MockRateLimiter{}
+	router := NewPolicyRouter()
+	router.HandleMethods("/items/*", map[string]RateLimiter{"*": read, "post": write, "PUT": write})
+	router.HandleMethods("/api/*", map[string]RateLimiter{"DELETE": remove})
+	router.Handle("/api/*", api)
+
+	tests := []struct {
+		method string
+		path   string
+		want   RateLimiter
+	}{
+		{http.MethodGet, "/items/42", read},
+		{http.MethodPost, "/items/42", write},
+		{http.MethodPut, "/items/42", write},
+		{http.MethodDelete, "/api/users/42", remove},
+		{http.MethodGet, "/api/users/42", api},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if got := router.Match(req); got != test.want {
+			t.Errorf("%s %s: matched the wrong rate limiter", test.method, test.path)
+		}
+	}
+}
+
+// Test requests matching no route are allowed without a default rate limiter
+func TestPolicyRouterAllowsUnmatchedRequests(t *testing.T) {
+	router := NewPolicyRouter()
+	router.Handle("/login", NewFixedWindowLimiter(1, 1))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(router)(handler)
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/home", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status OK; got %v", rr.Code)
+		}
+		if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "" {
+			t.Errorf("expected no X-RateLimit-Limit header; got %v", limit)
+		}
+	}
+}
+
+// Test each route enforces its own limit
+func TestPolicyRouterEnforcesRouteLimits(t *testing.T) {
+	router := NewPolicyRouter()
+	router.Handle("/login", NewFixedWindowLimiter(1, 1e12))
+	router.Handle("/api/*", NewFixedWindowLimiter(2, 1e12))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(router)(handler)
+	codes := func(path string, n int) []int {
+		var codes []int
+		for i := 0; i < n; i++ {
+			rr := httptest.NewRecorder()
+			middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			codes = append(codes, rr.Code)
+		}
+		return codes
+	}
+
+	if got := codes("/login", 2); got[1] != http.StatusTooManyRequests {
+		t.Errorf("expected second login request to be denied; got %v", got)
+	}
+	if got := codes("/api/items", 3); got[1] != http.StatusOK || got[2] != http.StatusTooManyRequests {
+		t.Errorf("expected third api request to be denied; got %v", got)
+	}
+}
+
+// Test malformed patterns are rejected
+func TestPolicyRouterRejectsMalformedPatterns(t *testing.T) {
+	for _, pattern := range []string{"GET login", "/files/[a-"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected pattern %q to be rejected", pattern)
+				}
+			}()
+			NewPolicyRouter().Handle(pattern, &MockRateLimiter{})
+		}()
+	}
+}
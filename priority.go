@@ -0,0 +1,55 @@
+package cerberus
+
+import "net/http"
+
+// WithPriorityReserve keeps a reserve of the budget of each client for its most important
+// requests, as classified by priority, such as checkout flows over browsing traffic. The reserve is
+// a fraction of the limit, between 0 and 1, and it requires an [AdvancedRateLimiter] reporting the
+// budget left. There is no reserve by default.
+//
+// Behavior:
+//   - Once the budget left falls below the reserve, requests of [PriorityLow] are denied.
+//   - Requests of higher priorities are denied at lower levels of budget, in equal steps: with a
+//     reserve of 0.3, requests of [PriorityNormal] are denied below 20% of the limit, and requests
+//     of [PriorityHigh] below 10%. Requests of [PriorityCritical] are only denied by the limit.
+//   - Requests denied this way are handled like any other denied request, and the budget they
+//     consumed is given back if the rate limiter implements [RefundableRateLimiter].
+//
+// Priorities below PriorityLow are treated as PriorityLow, and priorities above PriorityCritical
+// as PriorityCritical.
+//
+// It panics if reserve is not between 0 and 1, or if priority is nil and reserve is positive. [New]
+// panics if the rate limiter does not implement [AdvancedRateLimiter].
+//
+// Example usage:
+//
+//	priority := func(r *http.Request) int {
+//		if strings.HasPrefix(r.URL.Path, "/checkout") {
+//			return PriorityHigh
+//		}
+//		return PriorityLow
+//	}
+//	http.Handle("/", New(myAdvancedRateLimiter, WithPriorityReserve(priority, 0.2))(myHandler))
+func WithPriorityReserve(priority PriorityFunc, reserve float64) Option {
+	if !(reserve >= 0 && reserve <= 1) {
+		panic("cerberus: priority reserve must be between 0 and 1")
+	}
+	if priority == nil && reserve > 0 {
+		panic("cerberus: priority func must not be nil")
+	}
+	return func(o *options) {
+		o.priority = priority
+		o.reserve = reserve
+	}
+}
+
+// reserved reports whether the request must be denied to keep the reserve of the budget left, as
+// reported by data once the request was counted, for requests of higher priority.
+func (o *options) reserved(r *http.Request, data RateLimitData) bool {
+	if o.reserve == 0 || data.Limit == 0 {
+		return false
+	}
+	priority := min(max(o.priority(r), PriorityLow), PriorityCritical)
+	reserve := o.reserve * float64(PriorityCritical-priority) / float64(PriorityCritical-PriorityLow)
+	return float64(data.Remaining) < reserve*float64(data.Limit)
+}
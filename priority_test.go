@@ -0,0 +1,94 @@
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test low-priority requests are denied once the budget left falls below the reserve
+func TestWithPriorityReserve(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	priority := func(r *http.Request) int {
+		if r.URL.Path == "/checkout" {
+			return PriorityHigh
+		}
+		return PriorityLow
+	}
+	limiter := NewFixedWindowLimiter(10, time.Minute)
+	middleware := New(limiter, WithPriorityReserve(priority, 0.3))(handler)
+	serve := func(path string) int {
+		req := newRequestFrom("192.0.2.1:1234")
+		req.URL.Path = path
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	browsing := 0
+	for range 10 {
+		if serve("/browse") == http.StatusOK {
+			browsing++
+		}
+	}
+	if browsing != 7 {
+		t.Errorf("expected 7 low-priority requests to be allowed; got %v", browsing)
+	}
+	checkout := 0
+	for range 10 {
+		if serve("/checkout") == http.StatusOK {
+			checkout++
+		}
+	}
+	if checkout != 2 {
+		t.Errorf("expected 2 high-priority requests to be allowed; got %v", checkout)
+	}
+	if data := limiter.GetRateLimitData(newRequestFrom("192.0.2.1:1234")); data.Remaining != 1 {
+		t.Errorf("expected denied requests to be refunded, leaving 1 remaining; got %v", data.Remaining)
+	}
+}
+
+// Test critical requests are only denied by the limit
+func TestWithPriorityReserveCritical(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	critical := func(*http.Request) int { return PriorityCritical }
+	middleware := New(NewFixedWindowLimiter(5, time.Minute), WithPriorityReserve(critical, 1))(handler)
+
+	allowed := 0
+	for range 10 {
+		rr := httptest.NewRecorder()
+		middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+		if rr.Code == http.StatusOK {
+			allowed++
+		}
+	}
+
+	if allowed != 5 {
+		t.Errorf("expected 5 critical requests to be allowed; got %v", allowed)
+	}
+}
+
+// Test invalid reserves and rate limiters are rejected
+func TestWithPriorityReservePanics(t *testing.T) {
+	priority := func(*http.Request) int { return PriorityNormal }
+	for name, f := range map[string]func(){
+		"negative reserve": func() { WithPriorityReserve(priority, -0.1) },
+		"reserve above 1":  func() { WithPriorityReserve(priority, 1.1) },
+		"nil priority":     func() { WithPriorityReserve(nil, 0.5) },
+		"basic limiter":    func() { New(&MockRateLimiter{}, WithPriorityReserve(priority, 0.5)) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
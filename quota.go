@@ -0,0 +1,213 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Period divides time into consecutive periods, such as calendar days or billing cycles. It
+// returns the start and the end of the period containing t. The start must not be after t, and
+// the end must be after it.
+type Period func(t time.Time) (start, end time.Time)
+
+// Daily returns a [Period] of calendar days in loc, from midnight to midnight.
+func Daily(loc *time.Location) Period {
+	return func(t time.Time) (time.Time, time.Time) {
+		t = t.In(loc)
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// Monthly returns a [Period] of calendar months in loc, from midnight of the first day of a month
+// to midnight of the first day of the next.
+func Monthly(loc *time.Location) Period {
+	return func(t time.Time) (time.Time, time.Time) {
+		t = t.In(loc)
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// BillingCycle returns a [Period] of monthly cycles starting at anchor, for example the time a
+// customer subscribed. Each cycle starts on the same day of the month and at the same time of day
+// as anchor, in the location of anchor. In months that are too short, cycles start on the last day
+// of the month instead, so a cycle anchored on January 31 renews on February 28 or 29, then on
+// March 31.
+func BillingCycle(anchor time.Time) Period {
+	cycleStart := func(n int) time.Time {
+		year, month := anchor.Year(), anchor.Month()+time.Month(n)
+		lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, anchor.Location()).Day()
+		return time.Date(year, month, min(anchor.Day(), lastDay),
+			anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+	}
+	return func(t time.Time) (time.Time, time.Time) {
+		t = t.In(anchor.Location())
+		n := (t.Year()-anchor.Year())*12 + int(t.Month()-anchor.Month())
+		if cycleStart(n).After(t) {
+			n--
+		}
+		return cycleStart(n), cycleStart(n + 1)
+	}
+}
+
+// truncatingPeriod returns a [Period] of consecutive windows of length window, aligned to the zero
+// time.
+func truncatingPeriod(window time.Duration) Period {
+	return func(t time.Time) (time.Time, time.Time) {
+		start := t.Truncate(window)
+		return start, start.Add(window)
+	}
+}
+
+// Quota describes the usage of a client's quota in the current period.
+type Quota struct {
+	// Limit is the total budget of the client in each period.
+	Limit int64
+
+	// Used is the budget the client has consumed in the current period.
+	Used int64
+
+	// Remaining is the budget the client has left in the current period.
+	Remaining int64
+
+	// ResetAt is the end of the current period, when the budget is restored.
+	ResetAt time.Time
+}
+
+// QuotaLimiter is a [RateLimiter] and [AdvancedRateLimiter] enforcing quotas over long periods,
+// such as a number of API calls per day, per calendar month, or per billing cycle. Each client,
+// identified by its key (its IP address by default, although quotas are usually keyed by user or
+// API key), may make up to limit requests per period. Counters are reset when a new period begins.
+//
+// Unlike the rate limiters meant to absorb bursts, a QuotaLimiter keeps counters for as long as a
+// period lasts, and exposes them through [QuotaLimiter.Quota] so that usage can be shown to
+// customers or billed. Counters of monthly quotas should not be lost on a restart, so a QuotaLimiter
+// is best used with a persistent [Store] set with [WithStore].
+//
+// A QuotaLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	quota := NewQuotaLimiter(10000, Monthly(time.UTC), WithKeyFunc(KeyByHeader("X-API-Key")), WithStore(myPersistentStore))
+//	http.Handle("/api/", New(quota, WithHeaderStyle(HeaderStyleIETF))(apiHandler))
+type QuotaLimiter struct {
+	counter *FixedWindowLimiter
+}
+
+// NewQuotaLimiter creates a new [QuotaLimiter] that allows up to limit requests per client within
+// each period.
+//
+// It panics if limit is not positive or period is nil.
+func NewQuotaLimiter(limit int, period Period, opts ...LimiterOption) *QuotaLimiter {
+	if period == nil {
+		panic("cerberus: quota period must not be nil")
+	}
+	if limit <= 0 {
+		panic("cerberus: quota limit must be positive")
+	}
+	counter := NewFixedWindowLimiter(limit, time.Hour, opts...)
+	counter.period = period
+	return &QuotaLimiter{counter: counter}
+}
+
+// IsAllowed adds the cost of the request to the counter of the client making it for the current
+// period. It returns true if the counter did not exceed the limit, false otherwise. An error is
+// returned if no key can be derived from the request or the store fails.
+func (l *QuotaLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.counter.IsAllowed(r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *QuotaLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.counter.IsAllowedContext(ctx, r)
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *QuotaLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	return l.counter.Peek(ctx, r)
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *QuotaLimiter) Commit(ctx context.Context, r *http.Request) error {
+	return l.counter.Commit(ctx, r)
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. An error is returned
+// if no key can be derived from the request or the store fails.
+func (l *QuotaLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	return l.counter.RefundRequest(ctx, r)
+}
+
+// GetRateLimitData returns the current state of the quota of the client making the request.
+// Remaining is the budget left in the current period, and RetryAfter is the time until the next
+// period begins if it does not cover the cost of the request. The zero RateLimitData is returned
+// if no key can be derived from the request or the store fails.
+func (l *QuotaLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	return l.counter.GetRateLimitData(r)
+}
+
+// Usage returns the current state of the quota of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *QuotaLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.counter.Usage(ctx, key)
+}
+
+// Reset restores the full quota of the client identified by key for the current period, lifting
+// any block. An error is returned if the store fails.
+func (l *QuotaLimiter) Reset(ctx context.Context, key string) error {
+	return l.counter.Reset(ctx, key)
+}
+
+// Block denies all requests of the client identified by key for d, without consuming its quota.
+// An error is returned if the store fails.
+func (l *QuotaLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	return l.counter.Block(ctx, key, d)
+}
+
+// Keys returns the keys of the clients that used their quota in the current period, in no
+// particular order. An error wrapping [errors.ErrUnsupported] is returned if the store does not
+// implement [KeyScanner].
+func (l *QuotaLimiter) Keys(ctx context.Context) ([]string, error) {
+	return l.counter.Keys(ctx)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *QuotaLimiter) TopDenied() ([]KeyCount, error) {
+	return l.counter.TopDenied()
+}
+
+// Close closes the counter of the limiter, stopping the janitor of its default store, if no store
+// was set with [WithStore]. It implements [Closer].
+func (l *QuotaLimiter) Close(ctx context.Context) error {
+	return l.counter.Close(ctx)
+}
+
+// Quota returns the usage of the quota of the client identified by key in the current period. An
+// error is returned if the store fails.
+func (l *QuotaLimiter) Quota(ctx context.Context, key string) (Quota, error) {
+	used, _, err := l.counter.count(ctx, key)
+	if err != nil {
+		return Quota{}, err
+	}
+	_, end := l.counter.period(l.counter.now())
+	return Quota{
+		Limit:     l.counter.limit,
+		Used:      used,
+		Remaining: max(l.counter.limit-used, 0),
+		ResetAt:   end,
+	}, nil
+}
+
+// key returns the key identifying the client making the request.
+func (l *QuotaLimiter) key(r *http.Request) (string, error) {
+	return l.counter.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *QuotaLimiter) timeSource() Clock {
+	return l.counter.clock
+}
@@ -0,0 +1,91 @@
+package cerberus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Test Daily and Monthly align periods to the calendar
+func TestCalendarPeriods(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2024, time.February, 10, 23, 30, 0, 0, time.UTC)
+
+	start, end := Daily(loc)(now)
+	if want := time.Date(2024, time.February, 11, 0, 0, 0, 0, loc); !start.Equal(want) || !end.Equal(want.AddDate(0, 0, 1)) {
+		t.Errorf("expected day starting %v; got %v to %v", want, start, end)
+	}
+	start, end = Monthly(time.UTC)(now)
+	if want := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.AddDate(0, 1, 0)) {
+		t.Errorf("expected month starting %v; got %v to %v", want, start, end)
+	}
+}
+
+// Test BillingCycle renews on the anchor day, clamped to short months
+func TestBillingCycle(t *testing.T) {
+	period := BillingCycle(time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		now        time.Time
+		start, end time.Time
+	}{
+		{
+			time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			time.Date(2024, time.March, 31, 11, 59, 0, 0, time.UTC),
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		start, end := period(test.now)
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("%v: expected %v to %v; got %v to %v", test.now, test.start, test.end, start, end)
+		}
+	}
+}
+
+// Test the limiter enforces the quota and reports usage
+func TestQuotaLimiter(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewQuotaLimiter(2, Daily(time.UTC))
+	limiter.counter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	quota, err := limiter.Quota(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	_, midnight := Daily(time.UTC)(clock.Now())
+	if quota.Limit != 2 || quota.Used != 1 || quota.Remaining != 1 || !quota.ResetAt.Equal(midnight) {
+		t.Errorf("expected {2 1 1 %v}; got %+v", midnight, quota)
+	}
+	limiter.IsAllowed(req)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the quota to be denied")
+	}
+	if data := limiter.GetRateLimitData(req); data.RetryAfter != midnight.Sub(clock.Now()) {
+		t.Errorf("expected retry at midnight; got %+v", data)
+	}
+	clock.Advance(midnight.Sub(clock.Now()))
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request in the next period to be allowed")
+	}
+}
+
+// Benchmark a client using its daily quota at a steady rate
+func BenchmarkQuotaLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewQuotaLimiter(86400, Daily(time.UTC))
+	limiter.counter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, time.Second)
+}
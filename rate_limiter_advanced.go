@@ -49,4 +49,39 @@ type RateLimitData struct {
 	// making another request. It is typically used to set the X-RateLimit-Retry-After
 	// header in the HTTP response when rate limiting is enforced.
 	RetryAfter time.Duration
+
+	// Window is the time window the Limit applies to, such as one minute
+	// for a limit of 100 requests per minute. It is used to describe the
+	// policy in the RateLimit-Policy header. Zero means the window is unknown.
+	Window time.Duration
+
+	// Policy describes the rate limit policy in the syntax of the
+	// RateLimit-Policy header, such as "100;w=60". If empty, the policy is
+	// derived from Limit and Window.
+	Policy string
+
+	// Rate is the sustained rate, in requests per second, the client may keep up over time, such
+	// as 10 for a token bucket refilled with 10 tokens per second. Zero means the rate is unknown.
+	Rate float64
+
+	// Burst is the number of requests the client may make back to back, on top of the sustained
+	// Rate, when its budget is full, such as the capacity of a token bucket. Zero means the burst
+	// is unknown.
+	Burst int
+
+	// ResetAt is the time at which the budget of the client will be fully restored if it makes no
+	// more requests, such as the end of the current window of a fixed window counter. It is
+	// reported in the X-RateLimit-Reset header of [HeaderStyleLegacy], and as the time until then in
+	// the RateLimit-Reset header of [HeaderStyleIETF]. The zero time means the time is unknown.
+	ResetAt time.Time
+
+	// Backoff is the base delay of the exponential backoff a client should follow when its retries
+	// keep being denied, doubling the delay after each denial, such as the time its budget takes to
+	// recover one request. Zero means the rate limiter gives no hint. See [WithBackoffHints].
+	Backoff time.Duration
+
+	// Jitter is the upper bound of the random delay a client should add to each retry, so that
+	// clients denied at the same time do not all retry at the same time. Zero means the rate
+	// limiter gives no hint. See [WithBackoffHints].
+	Jitter time.Duration
 }
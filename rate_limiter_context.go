@@ -0,0 +1,32 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+)
+
+// RateLimiterContext is an extended version of the [RateLimiter] interface for rate limiters that
+// perform I/O, such as distributed limiters backed by Redis or another remote store. Such limiters
+// should respect the cancellation and deadline of the context passed to them, and use it to
+// propagate tracing information to their backend.
+//
+// The middlewares call IsAllowedContext instead of IsAllowed when a rate limiter implements this
+// interface. All built-in limiters implement it.
+type RateLimiterContext interface {
+	RateLimiter
+	// IsAllowedContext is like IsAllowed, but uses ctx instead of the request's context for any
+	// work it performs. It should return ctx.Err() if ctx is done before the decision is made.
+	IsAllowedContext(ctx context.Context, r *http.Request) (bool, error)
+}
+
+// isAllowed checks r against rateLimiter using ctx, calling IsAllowedContext if the rate limiter
+// implements [RateLimiterContext], and IsAllowed with a copy of r carrying ctx otherwise.
+func isAllowed(ctx context.Context, rateLimiter RateLimiter, r *http.Request) (bool, error) {
+	if rateLimiterContext, ok := rateLimiter.(RateLimiterContext); ok {
+		return rateLimiterContext.IsAllowedContext(ctx, r)
+	}
+	if ctx != r.Context() {
+		r = r.WithContext(ctx)
+	}
+	return rateLimiter.IsAllowed(r)
+}
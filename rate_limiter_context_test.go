@@ -0,0 +1,71 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Mock implementation of RateLimiterContext
+type MockRateLimiterContext struct {
+	MockRateLimiter
+	IsAllowedContextFunc func(context.Context, *http.Request) (bool, error)
+}
+
+func (rl *MockRateLimiterContext) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return rl.IsAllowedContextFunc(ctx, r)
+}
+
+type contextKey struct{}
+
+// Test middleware calls IsAllowedContext with the request's context
+func TestMiddlewarePrefersIsAllowedContext(t *testing.T) {
+	var gotValue any
+	mockLimiter := &MockRateLimiterContext{
+		MockRateLimiter: MockRateLimiter{
+			IsAllowedFunc: func(r *http.Request) (bool, error) {
+				t.Errorf("expected IsAllowed not to be called")
+				return false, nil
+			},
+		},
+		IsAllowedContextFunc: func(ctx context.Context, r *http.Request) (bool, error) {
+			gotValue = ctx.Value(contextKey{})
+			return true, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := Middleware(mockLimiter, handler)
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextKey{}, "traced"))
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status OK; got %v", rr.Code)
+	}
+	if gotValue != "traced" {
+		t.Errorf("expected the request's context to be passed; got value %v", gotValue)
+	}
+}
+
+// Test plain rate limiters receive the context through the request
+func TestIsAllowedPassesContextToRateLimiter(t *testing.T) {
+	var gotValue any
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			gotValue = r.Context().Value(contextKey{})
+			return true, nil
+		},
+	}
+	ctx := context.WithValue(context.Background(), contextKey{}, "traced")
+
+	isAllowed(ctx, mockLimiter, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if gotValue != "traced" {
+		t.Errorf("expected the context to be passed; got value %v", gotValue)
+	}
+}
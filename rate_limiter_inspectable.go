@@ -0,0 +1,20 @@
+package cerberus
+
+import "context"
+
+// InspectableLimiter is an extended version of the [RateLimiter] interface for rate limiters that
+// can report the state of their clients by key rather than by request, for operational tooling
+// such as an admin API. All built-in limiters implement it.
+//
+// The built-in limiters can only list keys if their [Store] implements [KeyScanner]. Keys are
+// listed as they are kept in the store, so a store shared with other limiters lists their keys as
+// well.
+type InspectableLimiter interface {
+	RateLimiter
+	// Keys returns the keys of the clients the rate limiter is currently tracking, in no
+	// particular order.
+	Keys(ctx context.Context) ([]string, error)
+	// Usage returns the rate limit data of the client identified by key, as reported for a
+	// request of cost 1.
+	Usage(ctx context.Context, key string) (RateLimitData, error)
+}
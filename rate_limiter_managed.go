@@ -0,0 +1,18 @@
+package cerberus
+
+import (
+	"context"
+	"time"
+)
+
+// ManagedLimiter is an extended version of the [RateLimiter] interface for rate limiters whose
+// clients can be managed by key, so that applications can clear the usage of a user after a plan
+// upgrade, or ban an abusive client. All built-in limiters implement it.
+type ManagedLimiter interface {
+	RateLimiter
+	// Reset restores the full budget of the client identified by key, lifting any block.
+	Reset(ctx context.Context, key string) error
+	// Block denies all requests of the client identified by key for d, starting now. Blocking a
+	// client that is already blocked replaces the previous block. A non-positive d does nothing.
+	Block(ctx context.Context, key string, d time.Duration) error
+}
@@ -0,0 +1,99 @@
+package cerberus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newManagedLimiters returns one of each built-in limiter allowing 2 requests per minute, all
+// driven by clock.
+func newManagedLimiters(clock *fakeClock) map[string]ManagedLimiter {
+	store := func() Store {
+		s := NewMemoryStore(WithCleanupInterval(0))
+		s.now = clock.Now
+		return s
+	}
+	tokenBucket := NewTokenBucketLimiter(2, 2.0/60, WithStore(store()))
+	tokenBucket.now = clock.Now
+	leakyBucket := NewLeakyBucketLimiter(2, 2.0/60, WithStore(store()))
+	leakyBucket.now = clock.Now
+	fixedWindow := NewFixedWindowLimiter(2, time.Minute, WithStore(store()))
+	fixedWindow.now = clock.Now
+	slidingWindow := NewSlidingWindowLimiter(2, time.Minute, WithStore(store()))
+	slidingWindow.now = clock.Now
+	gcra := NewGCRALimiter(30*time.Second, 30*time.Second, WithStore(store()))
+	gcra.now = clock.Now
+	quota := NewQuotaLimiter(2, Daily(time.UTC), WithStore(store()))
+	quota.counter.now = clock.Now
+	return map[string]ManagedLimiter{
+		"token bucket":   tokenBucket,
+		"leaky bucket":   leakyBucket,
+		"fixed window":   fixedWindow,
+		"sliding window": slidingWindow,
+		"GCRA":           gcra,
+		"quota":          quota,
+	}
+}
+
+// Test blocked clients are denied until the block ends, and others are not affected
+func TestManagedLimiterBlock(t *testing.T) {
+	clock := newFakeClock()
+	ctx := context.Background()
+	for name, limiter := range newManagedLimiters(clock) {
+		if err := limiter.Block(ctx, "192.0.2.1", time.Hour); err != nil {
+			t.Fatalf("%s: expected no error; got %v", name, err)
+		}
+
+		if isAllowed, _ := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234")); isAllowed {
+			t.Errorf("%s: expected blocked client to be denied", name)
+		}
+		if isAllowed, _ := limiter.IsAllowed(newRequestFrom("192.0.2.2:1234")); !isAllowed {
+			t.Errorf("%s: expected other client to be allowed", name)
+		}
+		data := limiter.(AdvancedRateLimiter).GetRateLimitData(newRequestFrom("192.0.2.1:1234"))
+		if data.Remaining != 0 || data.RetryAfter < time.Hour-time.Minute {
+			t.Errorf("%s: expected no remaining requests for about an hour; got %+v", name, data)
+		}
+	}
+}
+
+// Test blocks expire
+func TestManagedLimiterBlockExpires(t *testing.T) {
+	clock := newFakeClock()
+	ctx := context.Background()
+	limiters := newManagedLimiters(clock)
+	for _, limiter := range limiters {
+		limiter.Block(ctx, "192.0.2.1", time.Hour)
+	}
+
+	clock.Advance(time.Hour + time.Minute)
+
+	for name, limiter := range limiters {
+		if isAllowed, err := limiter.IsAllowed(newRequestFrom("192.0.2.1:1234")); err != nil || !isAllowed {
+			t.Errorf("%s: expected client to be allowed after the block; got %v, %v", name, isAllowed, err)
+		}
+	}
+}
+
+// Test resetting restores the full budget and lifts blocks
+func TestManagedLimiterReset(t *testing.T) {
+	clock := newFakeClock()
+	ctx := context.Background()
+	for name, limiter := range newManagedLimiters(clock) {
+		req := newRequestFrom("192.0.2.1:1234")
+		limiter.IsAllowed(req)
+		limiter.IsAllowed(req)
+		limiter.Block(ctx, "192.0.2.1", time.Hour)
+
+		if err := limiter.Reset(ctx, "192.0.2.1"); err != nil {
+			t.Fatalf("%s: expected no error; got %v", name, err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+				t.Errorf("%s: expected request %d after reset to be allowed; got %v, %v", name, i+1, isAllowed, err)
+			}
+		}
+	}
+}
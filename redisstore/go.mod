@@ -0,0 +1,18 @@
+module github.com/mxmlkzdh/cerberus/redisstore
+
+go 1.23.1
+
+replace github.com/mxmlkzdh/cerberus => ../
+
+require (
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/mxmlkzdh/cerberus v0.0.0
+	github.com/redis/go-redis/v9 v9.12.1
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,247 @@
+// Package redisstore provides a [cerberus.Store] backed by Redis, the most common backend for
+// enforcing common limits across the instances of a service.
+//
+// The store works with any [redis.UniversalClient]: a single server, a Redis Cluster, or a
+// primary monitored by Redis Sentinel. [redis.NewUniversalClient] picks the right client from its
+// options:
+//
+//	// A single server.
+//	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{"redis:6379"}})
+//	// A Redis Cluster, reached through any of its nodes.
+//	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{"node-1:6379", "node-2:6379"}})
+//	// A primary monitored by Sentinel.
+//	client := redis.NewUniversalClient(&redis.UniversalOptions{MasterName: "mymaster", Addrs: []string{"sentinel:26379"}})
+//
+//	store := redisstore.New(client)
+//	limiter := cerberus.NewGCRALimiter(100*time.Millisecond, time.Second, cerberus.WithStore(store))
+//
+// Atomic operations run as Lua scripts. Every script touches a single key, so they run on
+// clusters without hash tags, and the keys of the store spread evenly across slots. The prefix of
+// the store must not contain a hash tag, such as "{limits}", which would put all keys in one slot.
+// Scanning keys visits every primary of a cluster.
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/redis/go-redis/v9"
+)
+
+// incrementScript adds ARGV[1] to KEYS[1], setting the TTL of ARGV[2] milliseconds if the key was
+// created.
+var incrementScript = redis.NewScript(`
+local n = redis.call("INCRBY", KEYS[1], ARGV[1])
+if n == tonumber(ARGV[1]) and tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// compareAndSwapScript sets KEYS[1] to ARGV[3] with a TTL of ARGV[4] milliseconds if its value is
+// ARGV[2], where ARGV[1] is "1" to match a missing key instead.
+var compareAndSwapScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if ARGV[1] == "1" then
+	if current then return 0 end
+elseif current ~= ARGV[2] then
+	return 0
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[3], "PX", ARGV[4])
+else
+	redis.call("SET", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// Store is a [cerberus.Store] and [cerberus.KeyScanner] keeping its entries in Redis.
+//
+// A Store is safe for concurrent use by multiple goroutines.
+type Store struct {
+	client     redis.UniversalClient
+	prefix     string
+	ownsClient bool
+}
+
+// Option configures a [Store].
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the Redis keys of the store, so that several stores, or other
+// applications, can share a Redis deployment. The default is "cerberus:".
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithClientOwnership makes [Store.Close] close the client of the store, releasing its connection
+// pool, for stores whose client is used by nothing else. By default, the client is not closed by
+// the store.
+func WithClientOwnership() Option {
+	return func(s *Store) {
+		s.ownsClient = true
+	}
+}
+
+// New creates a new [Store] using client. The client is not closed by the store, unless the store
+// is created with [WithClientOwnership].
+func New(client redis.UniversalClient, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		prefix: "cerberus:",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Close closes the client of the store if it was created with [WithClientOwnership], and does
+// nothing otherwise. It implements [cerberus.Closer].
+func (s *Store) Close(context.Context) error {
+	if !s.ownsClient {
+		return nil
+	}
+	err := s.client.Close()
+	if errors.Is(err, redis.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Get returns the value stored under key, or nil if the key does not exist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(value), nil
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, nonNil(value), max(ttl, 0)).Err()
+}
+
+// Increment adds delta to the integer stored under key and returns the result. A missing key is
+// created with the given TTL, and the TTL of an existing key is left unchanged.
+func (s *Store) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	n, err := incrementScript.Run(ctx, s.client, []string{s.prefix + key}, delta, milliseconds(ttl)).Int64()
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, fmt.Errorf("%w: %q is not an integer", cerberus.ErrMalformedValue, key)
+	}
+	return n, err
+}
+
+// IncrementBatch applies each of ops as if by Increment, in a single pipeline, so that a
+// [cerberus.BatchingStore] in front of the store makes one round trip per batch. On a Redis
+// Cluster, the pipeline is split by node.
+func (s *Store) IncrementBatch(ctx context.Context, ops []cerberus.IncrementOp) []cerberus.IncrementResult {
+	results := make([]cerberus.IncrementResult, len(ops))
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.Cmd, len(ops))
+	for i, op := range ops {
+		cmds[i] = incrementScript.Eval(ctx, pipe, []string{s.prefix + op.Key}, op.Delta, milliseconds(op.TTL))
+	}
+	pipe.Exec(ctx)
+	for i, cmd := range cmds {
+		results[i].Value, results[i].Err = cmd.Int64()
+		if results[i].Err != nil && strings.Contains(results[i].Err.Error(), "not an integer") {
+			results[i].Err = fmt.Errorf("%w: %q is not an integer", cerberus.ErrMalformedValue, ops[i].Key)
+		}
+	}
+	return results
+}
+
+// CompareAndSwap stores new under key only if the current value equals old, where a nil old value
+// matches a missing key. It reports whether the value was stored.
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	missing := "0"
+	if old == nil {
+		missing = "1"
+	}
+	swapped, err := compareAndSwapScript.Run(ctx, s.client, []string{s.prefix + key}, missing, nonNil(old), nonNil(new), milliseconds(ttl)).Int()
+	return swapped == 1, err
+}
+
+// TTL returns the remaining time to live of key. It returns zero if the key does not exist or does
+// not expire.
+func (s *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, s.prefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL reports missing keys and keys without a TTL with negative values.
+	return max(ttl, 0), nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// ScanKeys returns the keys starting with prefix, in no particular order. On a Redis Cluster, the
+// keys of every primary are scanned.
+func (s *Store) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	pattern := s.prefix + escapePattern(prefix) + "*"
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		keys := make(chan []string)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+				nodeKeys, err := s.scan(ctx, node, pattern)
+				if err == nil {
+					keys <- nodeKeys
+				}
+				return err
+			})
+			close(keys)
+		}()
+		var all []string
+		for nodeKeys := range keys {
+			all = append(all, nodeKeys...)
+		}
+		return all, <-errs
+	}
+	return s.scan(ctx, s.client, pattern)
+}
+
+// scan returns the keys of client matching pattern, without the prefix of the store.
+func (s *Store) scan(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), s.prefix))
+	}
+	return keys, iter.Err()
+}
+
+// escapePattern escapes the special characters of a Redis glob-style pattern in s.
+func escapePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// milliseconds returns ttl in whole milliseconds, as the scripts take it. A positive TTL is rounded
+// up, like go-redis does for Set, since zero means that the key does not expire.
+func milliseconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
+
+// nonNil returns b, or an empty slice if b is nil, since nil values denote missing keys.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
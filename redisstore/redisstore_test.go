@@ -0,0 +1,184 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mxmlkzdh/cerberus"
+	"github.com/redis/go-redis/v9"
+)
+
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{server.Addr()}})
+	t.Cleanup(func() { client.Close() })
+	return New(client, WithPrefix("test:")), server
+}
+
+// Test the store implements the semantics of cerberus.Store
+func TestStore(t *testing.T) {
+	store, server := newStore(t)
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "missing"); err != nil || value != nil {
+		t.Errorf("expected nil for a missing key; got %q, %v", value, err)
+	}
+	store.Set(ctx, "a", []byte("value"), time.Minute)
+	if value, _ := store.Get(ctx, "a"); string(value) != "value" {
+		t.Errorf("expected value; got %q", value)
+	}
+	if ttl, _ := store.TTL(ctx, "a"); ttl != time.Minute {
+		t.Errorf("expected a TTL of a minute; got %v", ttl)
+	}
+	store.Set(ctx, "empty", nil, 0)
+	if value, _ := store.Get(ctx, "empty"); value == nil || len(value) != 0 {
+		t.Errorf("expected an empty value; got %v", value)
+	}
+	if ttl, _ := store.TTL(ctx, "empty"); ttl != 0 {
+		t.Errorf("expected no TTL; got %v", ttl)
+	}
+
+	if swapped, err := store.CompareAndSwap(ctx, "b", nil, []byte("x"), 0); err != nil || !swapped {
+		t.Errorf("expected swap of a missing key; got %v, %v", swapped, err)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", nil, []byte("y"), 0); swapped {
+		t.Errorf("expected no swap of an existing key against nil")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("x"), []byte("y"), time.Second); !swapped {
+		t.Errorf("expected swap of a matching value")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("x"), []byte("z"), 0); swapped {
+		t.Errorf("expected no swap of a stale value")
+	}
+	if ttl, _ := store.TTL(ctx, "b"); ttl != time.Second {
+		t.Errorf("expected a TTL of a second; got %v", ttl)
+	}
+
+	server.FastForward(time.Minute)
+	if value, _ := store.Get(ctx, "a"); value != nil {
+		t.Errorf("expected an expired key to be missing; got %q", value)
+	}
+	store.Delete(ctx, "empty")
+	if value, _ := store.Get(ctx, "empty"); value != nil {
+		t.Errorf("expected a deleted key to be missing; got %q", value)
+	}
+}
+
+// Test increments create, update, and reject keys
+func TestStoreIncrement(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+
+	if n, err := store.Increment(ctx, "counter", 2, time.Minute); err != nil || n != 2 {
+		t.Errorf("expected 2; got %d, %v", n, err)
+	}
+	if n, err := store.Increment(ctx, "counter", 3, time.Hour); err != nil || n != 5 {
+		t.Errorf("expected 5; got %d, %v", n, err)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl != time.Minute {
+		t.Errorf("expected the TTL of the counter to be kept; got %v", ttl)
+	}
+	store.Increment(ctx, "short", 1, time.Microsecond)
+	store.CompareAndSwap(ctx, "swapped", nil, []byte("value"), time.Microsecond)
+	for _, key := range []string{"short", "swapped"} {
+		if ttl, _ := store.TTL(ctx, key); ttl != time.Millisecond {
+			t.Errorf("expected a TTL below 1ms to be rounded up for %s; got %v", key, ttl)
+		}
+	}
+	store.Set(ctx, "text", []byte("text"), 0)
+	if _, err := store.Increment(ctx, "text", 1, 0); !errors.Is(err, cerberus.ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", err)
+	}
+}
+
+// Test batches of increments are applied in a pipeline
+func TestStoreIncrementBatch(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+	store.Set(ctx, "text", []byte("text"), 0)
+
+	results := store.IncrementBatch(ctx, []cerberus.IncrementOp{
+		{Key: "a", Delta: 2, TTL: time.Minute},
+		{Key: "b", Delta: 3},
+		{Key: "text", Delta: 1},
+	})
+	if results[0].Value != 2 || results[1].Value != 3 || results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("expected values 2 and 3; got %+v", results)
+	}
+	if !errors.Is(results[2].Err, cerberus.ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", results[2].Err)
+	}
+	if ttl, _ := store.TTL(ctx, "a"); ttl != time.Minute {
+		t.Errorf("expected a TTL of a minute; got %v", ttl)
+	}
+}
+
+// Test keys are scanned literally, on single servers and clusters
+func TestStoreScanKeys(t *testing.T) {
+	store, server := newStore(t)
+	ctx := context.Background()
+
+	store.Set(ctx, "a*1", []byte("1"), 0)
+	store.Set(ctx, "ab1", []byte("1"), 0)
+	server.Set("other", "1")
+	if keys, err := store.ScanKeys(ctx, "a*"); err != nil || !slices.Equal(keys, []string{"a*1"}) {
+		t.Errorf("expected keys [a*1]; got %v, %v", keys, err)
+	}
+
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.Addr()}})
+	defer cluster.Close()
+	keys, err := New(cluster, WithPrefix("test:")).ScanKeys(ctx, "")
+	slices.Sort(keys)
+	if err != nil || !slices.Equal(keys, []string{"a*1", "ab1"}) {
+		t.Errorf("expected keys [a*1 ab1]; got %v, %v", keys, err)
+	}
+}
+
+// Test built-in limiters work on top of the store
+func TestStoreWithLimiter(t *testing.T) {
+	store, _ := newStore(t)
+	limiter := cerberus.NewGCRALimiter(time.Second, time.Second, cerberus.WithStore(store))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+}
+
+// Test the client is only closed by stores owning it
+func TestStoreClose(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{server.Addr()}})
+	t.Cleanup(func() { client.Close() })
+
+	if err := New(client).Close(ctx); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Errorf("expected the client to be left open; got %v", err)
+	}
+
+	store := New(client, WithClientOwnership())
+	if err := store.Close(ctx); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	if err := client.Ping(ctx).Err(); !errors.Is(err, redis.ErrClosed) {
+		t.Errorf("expected the client to be closed; got %v", err)
+	}
+	if err := store.Close(ctx); err != nil {
+		t.Errorf("expected closing again to have no effect; got %v", err)
+	}
+}
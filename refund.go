@@ -0,0 +1,48 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+)
+
+// WithRefundIf makes the middlewares give back the budget consumed by an allowed request once the
+// next handler has responded, if predicate returns true for the status code and the headers of the
+// response. It suits responses that were cheap to serve, such as HTTP 304 (Not Modified) responses
+// or responses served from a cache, which should not use up the budget of clients.
+//
+// Behavior:
+//   - Requests are checked and counted as usual before they are forwarded to the next handler.
+//   - Once the next handler has responded, the budget consumed by the request is refunded with
+//     RefundRequest if the response satisfies predicate. Errors returned by RefundRequest cannot
+//     affect the response anymore, and are only reported to the logger set with [WithLogger].
+//   - Requests that were not counted, such as the requests exceeding the limit let through by
+//     [WithShadowMode], are never refunded.
+//
+// The rate limiter must implement [RefundableRateLimiter]; the middleware constructors panic
+// otherwise. This option has no effect with [WithCountIf], whose predicate selects the responses
+// that are counted in the first place.
+//
+// Example usage:
+//
+//	refundCacheHits := WithRefundIf(func(status int, header http.Header) bool {
+//		return status == http.StatusNotModified || header.Get("X-Cache") == "HIT"
+//	})
+//	http.Handle("/", New(NewTokenBucketLimiter(100, 10), refundCacheHits)(myHandler))
+func WithRefundIf(predicate func(statusCode int, header http.Header) bool) Option {
+	return func(o *options) {
+		o.refundIf = predicate
+	}
+}
+
+// serveRefundable forwards r to next, recording the status code of the response, and refunds r to
+// rateLimiter if the response satisfies the predicate set with [WithRefundIf].
+func (o *options) serveRefundable(w http.ResponseWriter, r *http.Request, next http.Handler, rateLimiter RefundableRateLimiter) {
+	recorder := &statusRecorder{ResponseWriter: w}
+	next.ServeHTTP(recorder, r)
+	if !o.refundIf(recorder.status(), w.Header()) {
+		return
+	}
+	if err := rateLimiter.RefundRequest(context.WithoutCancel(r.Context()), r); err != nil {
+		o.logError(r, rateLimiter, err)
+	}
+}
@@ -0,0 +1,100 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test WithRefundIf refunds only responses matching the predicate
+func TestWithRefundIfRefundsMatchingResponses(t *testing.T) {
+	limiter := NewFixedWindowLimiter(2, time.Minute)
+	refundCacheHits := WithRefundIf(func(status int, header http.Header) bool {
+		return status == http.StatusNotModified || header.Get("X-Cache") == "HIT"
+	})
+	middleware := New(limiter, refundCacheHits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cache") {
+		case "hit":
+			w.Header().Set("X-Cache", "HIT")
+		case "etag":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	serve := func(cache string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/?cache="+cache, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		middleware.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for range 3 {
+		serve("hit")
+		serve("etag")
+	}
+	for _, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve("miss"); code != want {
+			t.Errorf("expected status %v; got %v", want, code)
+		}
+	}
+}
+
+// Test Refund gives tokens back up to the capacity of the bucket
+func TestTokenBucketLimiterRefund(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(3, 1)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	for range 3 {
+		limiter.IsAllowed(req)
+	}
+
+	if err := limiter.Refund(context.Background(), "192.0.2.1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 2 {
+		t.Errorf("expected 2 remaining tokens; got %+v", data)
+	}
+	limiter.Refund(context.Background(), "192.0.2.1", 10)
+	if data := limiter.GetRateLimitData(req); data.Remaining != 3 {
+		t.Errorf("expected the bucket to be full; got %+v", data)
+	}
+}
+
+// Test refunds restoring the full budget delete the state of the client, and others keep it
+// expiring
+func TestRefundRequestStoreEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	defer store.Close(ctx)
+	limiters := map[string]RefundableRateLimiter{
+		tokenBucketPrefix: NewTokenBucketLimiter(10, 1, WithStore(store)),
+		leakyBucketPrefix: NewLeakyBucketLimiter(10, 1, WithStore(store)),
+		gcraPrefix:        NewGCRALimiter(time.Second, 9*time.Second, WithStore(store)),
+	}
+	req := newRequestFrom("192.0.2.1:1234")
+	for name, limiter := range limiters {
+		limiter.IsAllowed(req)
+		limiter.IsAllowed(req)
+		limiter.RefundRequest(ctx, req)
+		if ttl, _ := store.TTL(ctx, name+"192.0.2.1"); ttl <= 0 {
+			t.Errorf("%s: expected a positive TTL after a partial refund; got %v", name, ttl)
+		}
+		limiter.RefundRequest(ctx, req)
+		if keys, _ := store.ScanKeys(ctx, ""); len(keys) != 0 {
+			t.Errorf("%s: expected no entry after refunding every request; got %v", name, keys)
+		}
+	}
+}
+
+// Test WithRefundIf requires a RefundableRateLimiter
+func TestWithRefundIfRequiresRefundableRateLimiter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	New(&MockRateLimiter{}, WithRefundIf(func(int, http.Header) bool { return true }))
+}
@@ -0,0 +1,58 @@
+package cerberus
+
+import (
+	"math"
+	"net/http"
+)
+
+// rolloutBuckets is the number of buckets keys are hashed into to decide whether they are part of
+// a rollout, which makes rollouts precise to a hundredth of a percent.
+const rolloutBuckets = 10000
+
+// WithRollout enforces the rate limit for the given percentage of clients only, so that a new limit
+// can be tried out on a few clients, say 5%, before it is enforced for all of them. Requests of
+// the other clients are exempted from rate limiting, as with [WithSkipFunc]. The default is 100.
+//
+// Clients are identified by their key, as reported by the rate limiter if it is a built-in one,
+// and by their IP address otherwise. Whether a client is part of the rollout is decided by hashing
+// its key, so the decision is the same for every request of the client, on every instance of the
+// service. Raising the percentage only ever adds clients to the rollout: a client enforced at 5% is
+// still enforced at 20%. Requests whose key cannot be derived are enforced.
+//
+// It panics if percent is not between 0 and 100.
+//
+// Example usage: http.Handle("/resource", New(newLimiter, WithRollout(5))(myHandler))
+func WithRollout(percent float64) Option {
+	if !(percent >= 0 && percent <= 100) {
+		panic("cerberus: rollout percentage must be between 0 and 100")
+	}
+	return func(o *options) {
+		o.rollout = int(math.Round(percent * rolloutBuckets / 100))
+	}
+}
+
+// rolloutMatcher returns a [RequestMatcher] matching the requests of the clients that are not part
+// of a rollout to the given number of buckets out of rolloutBuckets, with clients identified by the
+// keys of rateLimiter.
+func rolloutMatcher(rateLimiter RateLimiter, buckets int) RequestMatcher {
+	keyFunc := KeyByIP
+	if k, ok := rateLimiter.(keyer); ok {
+		keyFunc = k.key
+	}
+	return func(r *http.Request) bool {
+		key, err := keyFunc(r)
+		return err == nil && rolloutBucket(key) >= buckets
+	}
+}
+
+// rolloutBucket hashes key into one of rolloutBuckets buckets using the 64-bit FNV-1a hash
+// function, which is independent of the 32-bit hash used to shard a [MemoryStore].
+func rolloutBucket(key string) int {
+	const offset64, prime64 = 14695981039346656037, 1099511628211
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return int(h % rolloutBuckets)
+}
@@ -0,0 +1,68 @@
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rolloutDenials returns the addresses of the clients, out of n, denied a second request by a limit
+// of one request per minute rolled out to percent of the clients.
+func rolloutDenials(percent float64, n int) map[string]bool {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(NewFixedWindowLimiter(1, time.Minute), WithRollout(percent))(handler)
+	denied := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		addr := fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)
+		for range 2 {
+			rr := httptest.NewRecorder()
+			middleware.ServeHTTP(rr, newRequestFrom(addr))
+			if rr.Code == http.StatusTooManyRequests {
+				denied[addr] = true
+			}
+		}
+	}
+	return denied
+}
+
+// Test the limit is enforced for the given percentage of clients
+func TestWithRolloutPercentage(t *testing.T) {
+	for _, test := range []struct {
+		percent  float64
+		min, max int
+	}{
+		{0, 0, 0},
+		{10, 70, 130},
+		{50, 440, 560},
+		{100, 1000, 1000},
+	} {
+		if denied := len(rolloutDenials(test.percent, 1000)); denied < test.min || denied > test.max {
+			t.Errorf("rollout %v%%: expected between %v and %v clients to be limited; got %v", test.percent, test.min, test.max, denied)
+		}
+	}
+}
+
+// Test raising the percentage keeps the clients already part of the rollout
+func TestWithRolloutIsStable(t *testing.T) {
+	small, large := rolloutDenials(5, 1000), rolloutDenials(20, 1000)
+
+	for addr := range small {
+		if !large[addr] {
+			t.Errorf("expected %v to remain part of the rollout", addr)
+		}
+	}
+}
+
+// Test invalid percentages are rejected
+func TestWithRolloutPanicsOnInvalidPercentage(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	WithRollout(101)
+}
@@ -0,0 +1,132 @@
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithServerRateLimits sets whether the transport created with [Transport] honors the rate limit
+// headers of the responses it receives, so that callers of rate limited APIs back off as asked by
+// the server without handling the headers themselves. It is enabled by default.
+//
+// Behavior:
+//   - A response tells the client to slow down with a Retry-After header, in seconds or as an HTTP
+//     date, or the X-RateLimit-Retry-After header of [HeaderStyleLegacy], in milliseconds, on an
+//     HTTP 429 (Too Many Requests) or 503 (Service Unavailable). It also does so with a RateLimit-
+//     Remaining header of 0 and a RateLimit-Reset header, in seconds, or with the r=0 and t
+//     parameters of the RateLimit header of the latest IETF drafts, on any response.
+//   - Later requests to the same host are then held until the time the server asked for has
+//     elapsed, if that fits within the wait set with [WithTransportWait], and fail with an error
+//     wrapping [ErrRateLimited] otherwise, without being checked against the rate limiter.
+//
+// Hosts are throttled by each transport separately, in memory.
+func WithServerRateLimits(enabled bool) TransportOption {
+	return func(t *transport) {
+		t.serverLimits = enabled
+	}
+}
+
+// waitServer holds r until the time the server it is sent to asked for has elapsed, if any. It
+// returns an error wrapping [ErrRateLimited] if that is beyond the wait budget of the transport,
+// or the error of the context of r if it is done while waiting.
+func (t *transport) waitServer(r *http.Request) error {
+	if !t.serverLimits {
+		return nil
+	}
+	t.mu.Lock()
+	until, ok := t.blockedUntil[r.URL.Host]
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	delay := until.Sub(t.clock.Now())
+	if delay <= 0 {
+		t.mu.Lock()
+		if t.blockedUntil[r.URL.Host] == until {
+			delete(t.blockedUntil, r.URL.Host)
+		}
+		t.mu.Unlock()
+		return nil
+	}
+	if delay > t.maxWait {
+		return fmt.Errorf("%w: retry after %v", ErrRateLimited, delay)
+	}
+	timer := t.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+		return r.Context().Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// observe throttles the host r was sent to if resp asks the client to slow down.
+func (t *transport) observe(r *http.Request, resp *http.Response) {
+	now := t.clock.Now()
+	delay := serverRetryAfter(resp, now)
+	if delay <= 0 {
+		return
+	}
+	until := now.Add(delay)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.blockedUntil[r.URL.Host]) {
+		t.blockedUntil[r.URL.Host] = until
+	}
+}
+
+// serverRetryAfter returns how long resp asks the client to wait before sending another request,
+// or zero if it does not. The longest delay of all the headers it carries is returned.
+func serverRetryAfter(resp *http.Response, now time.Time) time.Duration {
+	h := resp.Header
+	var delay time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if value := h.Get(headerRetryAfter); value != "" {
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				delay = max(delay, time.Duration(seconds)*time.Second)
+			} else if date, err := http.ParseTime(value); err == nil {
+				delay = max(delay, date.Sub(now))
+			}
+		}
+		if millis, err := strconv.ParseInt(h.Get(headerXRateLimitRetry), 10, 64); err == nil {
+			delay = max(delay, time.Duration(millis)*time.Millisecond)
+		}
+	}
+	if h.Get(headerRateLimitRemaining) == "0" {
+		if seconds, err := strconv.ParseInt(h.Get(headerRateLimitReset), 10, 64); err == nil {
+			delay = max(delay, time.Duration(seconds)*time.Second)
+		}
+	}
+	for _, value := range h.Values(headerRateLimit) {
+		delay = max(delay, rateLimitFieldDelay(value))
+	}
+	return delay
+}
+
+// rateLimitFieldDelay returns the time until the reset of the first exhausted limit listed in the
+// value of a RateLimit header, such as `"default";r=0;t=30`, or zero if none is exhausted.
+func rateLimitFieldDelay(value string) time.Duration {
+	for _, item := range strings.Split(value, ",") {
+		var remaining, reset string
+		for _, param := range strings.Split(item, ";")[1:] {
+			name, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch name {
+			case "r":
+				remaining = v
+			case "t":
+				reset = v
+			}
+		}
+		if remaining != "0" {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test requests to a host are throttled after it responds with a Retry-After header
+func TestTransportHonorsRetryAfter(t *testing.T) {
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(nil, nil)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the first request to be sent; got %v", err)
+	}
+	resp.Body.Close()
+	_, err = client.Get(server.URL)
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited; got %v", err)
+	}
+	if received != 1 {
+		t.Errorf("expected 1 request to be received; got %v", received)
+	}
+}
+
+// Test requests wait for the time asked by the server in wait mode
+func TestTransportWaitsForServer(t *testing.T) {
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			w.Header().Set("X-RateLimit-Retry-After", "100")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(nil, nil, WithTransportWait(time.Second))}
+
+	for i := range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to be sent; got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+
+	if elapsed := times[1].Sub(times[0]); elapsed < 90*time.Millisecond {
+		t.Errorf("expected the second request to be delayed by about 100ms; got %v", elapsed)
+	}
+}
+
+// Test server rate limits can be ignored
+func TestWithServerRateLimitsDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(nil, nil, WithServerRateLimits(false))}
+
+	for i := range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to be sent; got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// Test the delay asked by a response is derived from its headers
+func TestServerRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{"retry-after seconds", http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"retry-after on success", http.StatusOK, http.Header{"Retry-After": {"30"}}, 0},
+		{"legacy milliseconds", http.StatusServiceUnavailable, http.Header{"X-Ratelimit-Retry-After": {"1500"}}, 1500 * time.Millisecond},
+		{"exhausted", http.StatusOK, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"10"}}, 10 * time.Second},
+		{"not exhausted", http.StatusOK, http.Header{"Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"10"}}, 0},
+		{"structured field", http.StatusOK, http.Header{"Ratelimit": {`"burst";r=3;t=1, "daily";r=0;t=20`}}, 20 * time.Second},
+		{"longest", http.StatusTooManyRequests, http.Header{"Retry-After": {"5"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"8"}}, 8 * time.Second},
+		{"malformed", http.StatusTooManyRequests, http.Header{"Retry-After": {"soon"}}, 0},
+	}
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: test.header}
+		if got := serverRetryAfter(resp, time.Now()); got != test.want {
+			t.Errorf("%s: expected %v; got %v", test.name, test.want, got)
+		}
+	}
+}
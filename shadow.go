@@ -0,0 +1,30 @@
+package cerberus
+
+// WithShadowMode sets whether the middleware runs in shadow mode, also known as dry run. In shadow
+// mode, requests are checked against the rate limiter as usual, but always forwarded to the next
+// handler, so that the effect of a new limit can be observed in production before it is enforced.
+// Shadow mode is off by default.
+//
+// Behavior:
+//   - Requests the rate limiter denies are logged with [WithLogger] and passed to the OnDenied
+//     hooks set with [WithHooks] as usual, then forwarded instead of being passed to the denied
+//     handler. Log events are marked with a shadow attribute.
+//   - Rate limiter errors are logged and passed to the OnError hooks, then forwarded whatever the
+//     failure policy.
+//   - No rate limit headers are added to responses, and requests are never delayed by
+//     [WithWaitMode], so that clients cannot tell the limit is there.
+//   - Access lists set with [WithAllowlist] and [WithDenylist] are still enforced.
+//
+// The rate limiter still counts the requests it checks, so it must not share keys of a [Store] with
+// an enforced rate limiter. A rate limiter instrumented with cerberusmetrics or cerberusotel reports
+// the requests it would have denied as denied.
+//
+// Example usage:
+//
+//	shadow := New(NewFixedWindowLimiter(100, time.Minute), WithShadowMode(true), WithLogger(slog.Default()))
+//	http.Handle("/resource", shadow(New(currentLimiter)(myHandler)))
+func WithShadowMode(enabled bool) Option {
+	return func(o *options) {
+		o.shadow = enabled
+	}
+}
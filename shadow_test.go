@@ -0,0 +1,67 @@
+package cerberus
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test shadow mode forwards denied requests and reports them
+func TestWithShadowModeForwardsDenied(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	var denied int
+	hooks := Hooks{OnDenied: func(*http.Request, RateLimitData) { denied++ }}
+	var reached int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(NewFixedWindowLimiter(1, time.Minute), WithShadowMode(true), WithLogger(logger), WithHooks(hooks))(handler)
+
+	var rr *httptest.ResponseRecorder
+	for range 2 {
+		rr = httptest.NewRecorder()
+		middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+	}
+
+	if rr.Code != http.StatusOK || reached != 2 {
+		t.Errorf("expected both requests to be forwarded; got %v after %v calls", rr.Code, reached)
+	}
+	if denied != 1 {
+		t.Errorf("expected 1 shadow denial; got %v", denied)
+	}
+	if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "" {
+		t.Errorf("expected no rate limit headers; got %v", limit)
+	}
+	events := decodeLogEvents(t, &buf)
+	if len(events) != 1 || events[0]["shadow"] != true {
+		t.Errorf("expected 1 log event marked as shadow; got %v", events)
+	}
+}
+
+// Test shadow mode forwards requests on rate limiter errors whatever the failure policy
+func TestWithShadowModeForwardsErrors(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, errors.New("store unavailable")
+		},
+	}
+	var errs int
+	hooks := Hooks{OnError: func(*http.Request, error) { errs++ }}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(mockLimiter, WithShadowMode(true), WithFailurePolicy(FailClosed), WithHooks(hooks))(handler)
+	rr := httptest.NewRecorder()
+
+	middleware.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rr.Code != http.StatusOK || errs != 1 {
+		t.Errorf("expected the request to be forwarded after 1 error; got %v after %v errors", rr.Code, errs)
+	}
+}
@@ -0,0 +1,261 @@
+package cerberus
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedStore is a [Store] spreading keys across several stores, such as Redis or Memcached
+// instances, with consistent hashing, so that the state of the limiters scales horizontally beyond
+// a single node.
+//
+// Behavior:
+//   - Each shard is placed at many points of a hash ring, derived from its name, and each key is
+//     kept by the shard owning the first point following the hash of the key. Adding or removing a
+//     shard only moves the keys of about one shard's share, and every process configured with the
+//     same shard names maps keys the same way, whatever the order they are listed in.
+//   - All operations on a key go to its shard, so they are as atomic as the operations of the
+//     shard. Keys are scanned on every shard implementing [KeyScanner].
+//   - With failover, enabled with [WithFailover], a shard returning an error is taken out of the
+//     ring for a cooldown, and its keys go to the next shard on the ring meanwhile, which acts as
+//     its replica. Keys start over on the replica, and again on the shard when it is back, which is
+//     usually preferable to failing every request while a node is down.
+//
+// A ShardedStore is safe for concurrent use by multiple goroutines.
+//
+// Example usage:
+//
+//	store := NewShardedStore(map[string]Store{
+//		"redis-1": redisstore.New(client1),
+//		"redis-2": redisstore.New(client2),
+//		"redis-3": redisstore.New(client3),
+//	}, WithFailover(10*time.Second))
+//	limiter := NewGCRALimiter(100*time.Millisecond, time.Second, WithStore(store))
+type ShardedStore struct {
+	shards       []*storeShard
+	ring         []ringPoint
+	virtualNodes int
+	cooldown     time.Duration
+
+	now func() time.Time
+}
+
+// storeShard is a shard of a [ShardedStore]. downUntil is the time, in Unix nanoseconds, until
+// which the shard is out of the ring after failing.
+type storeShard struct {
+	name      string
+	store     Store
+	downUntil atomic.Int64
+}
+
+// ringPoint is a point of the hash ring of a [ShardedStore], owned by the shard at index shard.
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// ShardedStoreOption configures a [ShardedStore].
+type ShardedStoreOption func(*ShardedStore)
+
+// WithVirtualNodes sets the number of points of the hash ring per shard. More points spread keys
+// more evenly across shards, at the cost of memory. The default is 160.
+//
+// It panics if n is not positive.
+func WithVirtualNodes(n int) ShardedStoreOption {
+	if n <= 0 {
+		panic("cerberus: the number of virtual nodes must be positive")
+	}
+	return func(s *ShardedStore) {
+		s.virtualNodes = n
+	}
+}
+
+// WithFailover moves the keys of a shard returning an error to the next shard on the ring for
+// cooldown, after which the shard is tried again. Errors of the context of the operation do not
+// count as failures. By default, there is no failover, and errors are returned to the caller.
+//
+// It panics if cooldown is not positive.
+func WithFailover(cooldown time.Duration) ShardedStoreOption {
+	if cooldown <= 0 {
+		panic("cerberus: failover cooldown must be positive")
+	}
+	return func(s *ShardedStore) {
+		s.cooldown = cooldown
+	}
+}
+
+// NewShardedStore creates a new [ShardedStore] spreading keys across shards, identified by their
+// names. The names determine where keys go, so they must be the same on every instance of a
+// service, and must be kept when the address of a shard changes.
+//
+// It panics if shards is empty.
+func NewShardedStore(shards map[string]Store, opts ...ShardedStoreOption) *ShardedStore {
+	if len(shards) == 0 {
+		panic("cerberus: sharded store must have at least one shard")
+	}
+	s := &ShardedStore{virtualNodes: 160, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, name := range slices.Sorted(maps.Keys(shards)) {
+		s.shards = append(s.shards, &storeShard{name: name, store: shards[name]})
+	}
+	s.ring = make([]ringPoint, 0, len(s.shards)*s.virtualNodes)
+	for i, shard := range s.shards {
+		for v := range s.virtualNodes {
+			s.ring = append(s.ring, ringPoint{hash: ringHash(shard.name + "#" + strconv.Itoa(v)), shard: i})
+		}
+	}
+	slices.SortFunc(s.ring, func(a, b ringPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.shard, b.shard))
+	})
+	return s
+}
+
+// Get returns the value stored under key by its shard, or nil if the key does not exist.
+func (s *ShardedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return onShard(ctx, s, key, func(store Store) ([]byte, error) {
+		return store.Get(ctx, key)
+	})
+}
+
+// Set stores value under key in its shard, replacing any existing value.
+func (s *ShardedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := onShard(ctx, s, key, func(store Store) (struct{}, error) {
+		return struct{}{}, store.Set(ctx, key, value, ttl)
+	})
+	return err
+}
+
+// Increment adds delta to the integer stored under key by its shard and returns the result.
+func (s *ShardedStore) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return onShard(ctx, s, key, func(store Store) (int64, error) {
+		return store.Increment(ctx, key, delta, ttl)
+	})
+}
+
+// CompareAndSwap stores new under key in its shard only if the current value equals old. It
+// reports whether the value was stored.
+func (s *ShardedStore) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return onShard(ctx, s, key, func(store Store) (bool, error) {
+		return store.CompareAndSwap(ctx, key, old, new, ttl)
+	})
+}
+
+// TTL returns the remaining time to live of key in its shard.
+func (s *ShardedStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return onShard(ctx, s, key, func(store Store) (time.Duration, error) {
+		return store.TTL(ctx, key)
+	})
+}
+
+// Delete removes key from its shard.
+func (s *ShardedStore) Delete(ctx context.Context, key string) error {
+	_, err := onShard(ctx, s, key, func(store Store) (struct{}, error) {
+		return struct{}{}, store.Delete(ctx, key)
+	})
+	return err
+}
+
+// ScanKeys returns the keys starting with prefix of all the shards, in no particular order. Keys
+// left on a replica by a failover may be listed twice. An error wrapping [errors.ErrUnsupported]
+// is returned if a shard does not implement [KeyScanner].
+func (s *ShardedStore) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for _, shard := range s.shards {
+		shardKeys, err := scanKeys(ctx, shard.store, prefix)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, shardKeys...)
+	}
+	return keys, nil
+}
+
+// Shard returns the name of the shard key is currently kept by, taking failed shards out of the
+// ring, for debugging.
+func (s *ShardedStore) Shard(key string) string {
+	return s.shards[s.route(key)[0]].name
+}
+
+// Close closes the shards implementing [Closer], in the order of their names, since the store is
+// usually their only user. It implements Closer.
+func (s *ShardedStore) Close(ctx context.Context) error {
+	stores := make([]Store, len(s.shards))
+	for i, shard := range s.shards {
+		stores[i] = shard.store
+	}
+	return closeAll(ctx, stores...)
+}
+
+// route returns the indexes of the shards to try for key, in order: the shard owning key and,
+// with failover, the following shards on the ring. Shards out of the ring are skipped, unless all
+// of them are, in which case the shard owning key is tried anyway.
+func (s *ShardedStore) route(key string) []int {
+	h := ringHash(key)
+	start, _ := slices.BinarySearchFunc(s.ring, h, func(p ringPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	owner := s.ring[start%len(s.ring)].shard
+	if s.cooldown == 0 {
+		return []int{owner}
+	}
+	now := s.now().UnixNano()
+	seen := make([]bool, len(s.shards))
+	var route []int
+	for i := range s.ring {
+		shard := s.ring[(start+i)%len(s.ring)].shard
+		if seen[shard] {
+			continue
+		}
+		seen[shard] = true
+		if s.shards[shard].downUntil.Load() <= now {
+			route = append(route, shard)
+		}
+		if len(route) == len(s.shards) {
+			break
+		}
+	}
+	if len(route) == 0 {
+		return []int{owner}
+	}
+	return route
+}
+
+// onShard runs op on the store of the shard of key. With failover, a shard failing is taken out
+// of the ring, and op is retried on the next shard.
+func onShard[T any](ctx context.Context, s *ShardedStore, key string, op func(Store) (T, error)) (T, error) {
+	var result T
+	var err error
+	for _, i := range s.route(key) {
+		shard := s.shards[i]
+		result, err = op(shard.store)
+		if err == nil || s.cooldown == 0 || ctx.Err() != nil || errors.Is(err, ErrMalformedValue) {
+			return result, err
+		}
+		shard.downUntil.Store(s.now().Add(s.cooldown).UnixNano())
+	}
+	return result, err
+}
+
+// ringHash returns the position of key on the hash ring of a [ShardedStore]: its 64-bit FNV-1a
+// hash, with the bits mixed by the finalizer of SplitMix64 so that similar keys, such as the names
+// of the virtual nodes of a shard, spread evenly.
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
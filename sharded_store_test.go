@@ -0,0 +1,159 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// failingStore is a Store failing every operation while failing is set.
+type failingStore struct {
+	Store
+	failing bool
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s *failingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.failing {
+		return nil, errStoreDown
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (s *failingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if s.failing {
+		return errStoreDown
+	}
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+// newTestShards returns n memory stores named shard-0 to shard-n-1.
+func newTestShards(n int) map[string]Store {
+	shards := make(map[string]Store, n)
+	for i := range n {
+		shards[fmt.Sprintf("shard-%d", i)] = NewMemoryStore(WithCleanupInterval(0))
+	}
+	return shards
+}
+
+// TestShardedStoreDistribution tests that keys are spread across all shards, and that adding a
+// shard only moves keys to the new shard.
+func TestShardedStoreDistribution(t *testing.T) {
+	ctx := context.Background()
+	shards := newTestShards(3)
+	store := NewShardedStore(shards)
+	counts := map[string]int{}
+	before := map[string]string{}
+	for i := range 3000 {
+		key := fmt.Sprintf("key-%d", i)
+		store.Set(ctx, key, []byte("value"), 0)
+		before[key] = store.Shard(key)
+		counts[before[key]]++
+	}
+	for name, shard := range shards {
+		if n := shard.(*MemoryStore).Len(); n != counts[name] {
+			t.Errorf("expected %s to hold %d keys; got %d", name, counts[name], n)
+		}
+		if counts[name] < 600 {
+			t.Errorf("expected keys to be spread evenly; got %v", counts)
+		}
+	}
+
+	shards["shard-3"] = NewMemoryStore(WithCleanupInterval(0))
+	grown := NewShardedStore(shards)
+	moved := 0
+	for key, name := range before {
+		if after := grown.Shard(key); after != name {
+			moved++
+			if after != "shard-3" {
+				t.Fatalf("expected %q to stay on %s or move to the new shard; got %s", key, name, after)
+			}
+		}
+	}
+	if moved == 0 || moved > 1200 {
+		t.Errorf("expected about a quarter of the keys to move; got %d", moved)
+	}
+}
+
+// TestShardedStoreFailover tests that the keys of a failing shard go to the next shard for the
+// cooldown, and back to the shard after it.
+func TestShardedStoreFailover(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	shards := newTestShards(3)
+	failing := &failingStore{Store: shards["shard-0"]}
+	shards["shard-0"] = failing
+	store := NewShardedStore(shards, WithFailover(time.Minute))
+	store.now = clock.Now
+	key := "key"
+	for i := 0; store.Shard(key) != "shard-0"; i++ {
+		key = fmt.Sprintf("key-%d", i)
+	}
+
+	failing.failing = true
+	if err := store.Set(ctx, key, []byte("value"), 0); err != nil {
+		t.Fatalf("expected the write to fail over; got %v", err)
+	}
+	replica := store.Shard(key)
+	if replica == "shard-0" {
+		t.Fatalf("expected the failing shard to be out of the ring")
+	}
+	if value, _ := shards[replica].Get(ctx, key); string(value) != "value" {
+		t.Errorf("expected the value to be written to the replica; got %q", value)
+	}
+
+	failing.failing = false
+	clock.Advance(time.Minute)
+	if name := store.Shard(key); name != "shard-0" {
+		t.Errorf("expected the shard to be back after the cooldown; got %s", name)
+	}
+	if value, _ := store.Get(ctx, key); value != nil {
+		t.Errorf("expected the key to start over on the shard; got %q", value)
+	}
+}
+
+// TestShardedStoreNoFailover tests that errors of a shard are returned without failover, as well
+// as when all shards fail.
+func TestShardedStoreNoFailover(t *testing.T) {
+	ctx := context.Background()
+	failing := &failingStore{Store: NewMemoryStore(WithCleanupInterval(0)), failing: true}
+	for _, store := range []*ShardedStore{
+		NewShardedStore(map[string]Store{"a": failing, "b": NewMemoryStore(WithCleanupInterval(0))}),
+		NewShardedStore(map[string]Store{"a": failing}, WithFailover(time.Minute)),
+	} {
+		key := "key"
+		for i := 0; store.Shard(key) != "a"; i++ {
+			key = fmt.Sprintf("key-%d", i)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, errStoreDown) {
+			t.Errorf("expected %v; got %v", errStoreDown, err)
+		}
+	}
+}
+
+// TestShardedStoreScanKeys tests that keys are scanned on all shards.
+func TestShardedStoreScanKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewShardedStore(newTestShards(3))
+	var want []string
+	for i := range 20 {
+		key := fmt.Sprintf("a:%d", i)
+		want = append(want, key)
+		store.Set(ctx, key, []byte("value"), 0)
+	}
+	store.Set(ctx, "b:1", []byte("value"), 0)
+
+	keys, err := store.ScanKeys(ctx, "a:")
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	slices.Sort(keys)
+	slices.Sort(want)
+	if !slices.Equal(keys, want) {
+		t.Errorf("expected %v; got %v", want, keys)
+	}
+}
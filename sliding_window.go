@@ -0,0 +1,289 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// slidingWindowPrefix prefixes the store keys of the logs of a [SlidingWindowLimiter].
+const slidingWindowPrefix = "sliding_window:"
+
+// SlidingWindowLimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the
+// sliding window log algorithm. It records the time of every allowed request per client,
+// identified by its key (its IP address by default), and allows a new request only if fewer
+// than limit requests were recorded within the preceding window.
+//
+// Unlike a fixed window counter, the window slides with every request, so a client cannot
+// send twice the limit by clustering requests around a window boundary. The price for this
+// accuracy is memory: up to limit timestamps are kept for each client.
+//
+// Logs are kept in the limiter's [Store] and expire one window after the last recorded request.
+//
+// A SlidingWindowLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", AdvancedMiddleware(NewSlidingWindowLimiter(100, time.Minute), myHandler))
+type SlidingWindowLimiter struct {
+	limit  int
+	window time.Duration
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+}
+
+// NewSlidingWindowLimiter creates a new [SlidingWindowLimiter] that allows up to limit
+// requests per client within any period of length window.
+//
+// It panics if limit or window is not positive.
+func NewSlidingWindowLimiter(limit int, window time.Duration, opts ...LimiterOption) *SlidingWindowLimiter {
+	if limit <= 0 {
+		panic("cerberus: sliding window limit must be positive")
+	}
+	if window <= 0 {
+		panic("cerberus: sliding window duration must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &SlidingWindowLimiter{
+		limit:     limit,
+		window:    window,
+		keyFunc:   options.keyFunc,
+		store:     options.store,
+		costFunc:  options.costFunc,
+		policy:    options.policy,
+		now:       options.clock.Now,
+		clock:     options.clock,
+		topDenied: options.topDenied,
+	}
+}
+
+// IsAllowed records the request in the log of the client making it, once per unit of its cost, if
+// the log has room for it within the current window. It returns true if the request was recorded,
+// false otherwise. An error is returned if no key can be derived from the request or the store
+// fails.
+func (l *SlidingWindowLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *SlidingWindowLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.take(ctx, r, false)
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *SlidingWindowLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	value, err := l.store.Get(ctx, slidingWindowPrefix+key)
+	if err != nil {
+		return false, err
+	}
+	log, err := l.prune(key, value, l.now())
+	if err != nil {
+		return false, err
+	}
+	return log.len()+requestCost(l.costFunc, r) <= l.limit, nil
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *SlidingWindowLimiter) Commit(ctx context.Context, r *http.Request) error {
+	_, err := l.take(ctx, r, true)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed, by removing as many
+// of the most recent entries from the log as the request costs. An error is returned if no key can
+// be derived from the request or the store fails.
+func (l *SlidingWindowLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	cost := requestCost(l.costFunc, r)
+	return modify(ctx, l.store, slidingWindowPrefix+key, func(value []byte) ([]byte, time.Duration, error) {
+		if value == nil {
+			return nil, 0, nil
+		}
+		log, err := l.prune(key, value, l.now())
+		if err != nil {
+			return nil, 0, err
+		}
+		return bytes.Clone(log[:8*max(log.len()-cost, 0)]), l.window, nil
+	})
+}
+
+// take counts the request if it is allowed, or regardless if force is true, and reports whether
+// it was allowed.
+func (l *SlidingWindowLimiter) take(ctx context.Context, r *http.Request, force bool) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	cost := requestCost(l.costFunc, r)
+	var isAllowed bool
+	err = modify(ctx, l.store, slidingWindowPrefix+key, func(value []byte) ([]byte, time.Duration, error) {
+		now := l.now()
+		log, err := l.prune(key, value, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if isAllowed = log.len()+cost <= l.limit; !isAllowed && !force {
+			return nil, 0, nil
+		}
+		return log.append(uint64(now.UnixNano()), cost), l.window, nil
+	})
+	if !isAllowed && !force && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// GetRateLimitData returns the current state of the log of the client making the request. Remaining
+// is the number of requests that can still be recorded within the current window, and RetryAfter is
+// the time until enough recorded requests leave the window to make room for the request if there is
+// too little. The zero RateLimitData is returned if no key can be derived from the request or the
+// store fails.
+func (l *SlidingWindowLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, requestCost(l.costFunc, r))
+	return data
+}
+
+// Usage returns the current state of the log of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *SlidingWindowLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *SlidingWindowLimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, slidingWindowPrefix+key)
+}
+
+// Block fills the log of the client identified by key with requests that leave the window only
+// after d, so that its requests are denied for d. An error is returned if the store fails.
+func (l *SlidingWindowLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	log := make([]uint64, l.limit)
+	for i := range log {
+		log[i] = uint64(l.now().Add(d - l.window).UnixNano())
+	}
+	return l.store.Set(ctx, slidingWindowPrefix+key, encodeUint64s(log...), d)
+}
+
+// Keys returns the keys of the clients the limiter is tracking, in no particular order. Clients
+// whose state has expired are back to a full budget and are not listed. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (l *SlidingWindowLimiter) Keys(ctx context.Context) ([]string, error) {
+	return scanClientKeys(ctx, l.store, slidingWindowPrefix)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *SlidingWindowLimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *SlidingWindowLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost.
+func (l *SlidingWindowLimiter) rateLimitData(ctx context.Context, key string, cost int) (RateLimitData, error) {
+	value, err := l.store.Get(ctx, slidingWindowPrefix+key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	now := l.now()
+	log, err := l.prune(key, value, now)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	data := RateLimitData{
+		Limit:     l.limit,
+		Remaining: l.limit - log.len(),
+		Window:    l.window,
+		Policy:    l.policy,
+		Rate:      float64(l.limit) / l.window.Seconds(),
+		Burst:     l.limit,
+		ResetAt:   now,
+	}
+	if log.len() > 0 {
+		data.ResetAt = time.Unix(0, int64(log.at(log.len()-1))).Add(l.window)
+	}
+	if data.Remaining < cost && cost <= l.limit {
+		expiring := log.at(log.len() - (l.limit - cost) - 1)
+		data.RetryAfter = time.Unix(0, int64(expiring)).Add(l.window).Sub(now)
+	}
+	data.Remaining = max(data.Remaining, 0)
+	return data, nil
+}
+
+// prune drops the timestamps that have left the window ending at now from the log stored under
+// key. The log is not decoded, and the result shares the memory of value, so that checking a log
+// does not allocate.
+func (l *SlidingWindowLimiter) prune(key string, value []byte, now time.Time) (slidingLog, error) {
+	log := slidingLog(value)
+	if len(log)%8 != 0 {
+		return nil, fmt.Errorf("%w: %q is not a sliding window log", ErrMalformedValue, key)
+	}
+	start := uint64(now.Add(-l.window).UnixNano())
+	i := 0
+	for i < log.len() && log.at(i) <= start {
+		i++
+	}
+	return log[8*i:], nil
+}
+
+// slidingLog is the log of a [SlidingWindowLimiter] as stored: timestamps in Unix nanoseconds,
+// oldest first, encoded with [encodeUint64s].
+type slidingLog []byte
+
+// len returns the number of timestamps in the log.
+func (log slidingLog) len() int {
+	return len(log) / 8
+}
+
+// at returns the i-th timestamp of the log.
+func (log slidingLog) at(i int) uint64 {
+	return binary.BigEndian.Uint64(log[8*i:])
+}
+
+// append returns a copy of the log with n more timestamps t.
+func (log slidingLog) append(t uint64, n int) []byte {
+	b := make([]byte, len(log), len(log)+8*n)
+	copy(b, log)
+	for range n {
+		b = binary.BigEndian.AppendUint64(b, t)
+	}
+	return b
+}
+
+// key returns the key identifying the client making the request.
+func (l *SlidingWindowLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *SlidingWindowLimiter) timeSource() Clock {
+	return l.clock
+}
@@ -0,0 +1,366 @@
+package cerberus
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+)
+
+// slidingWindowCounterPrefix prefixes the store keys of a [SlidingWindowCounterLimiter].
+const slidingWindowCounterPrefix = "sliding_window_counter:"
+
+// SlidingWindowCounterLimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the
+// sliding window counter algorithm. Like [FixedWindowLimiter], it counts the requests of each
+// client, identified by its key (its IP address by default), per fixed window. It then estimates
+// the number of requests within the window ending now by weighting the counter of the previous
+// window by the share of it that the sliding window still overlaps, and allows a request only if
+// that estimate stays within limit.
+//
+// It is a middle ground between the other window limiters: it keeps two counters per client,
+// rather than up to limit timestamps like [SlidingWindowLimiter], and prevents the bursts of up to
+// twice the limit that [FixedWindowLimiter] allows around window boundaries. The estimate assumes
+// requests were spread evenly over the previous window, which is accurate enough for most
+// traffic.
+//
+// Counters are kept in the limiter's [Store] and expire once they no longer overlap the sliding
+// window. Denied requests are not counted.
+//
+// A SlidingWindowCounterLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", AdvancedMiddleware(NewSlidingWindowCounterLimiter(100, time.Minute), myHandler))
+type SlidingWindowCounterLimiter struct {
+	limit  int64
+	window time.Duration
+	period Period
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+}
+
+// NewSlidingWindowCounterLimiter creates a new [SlidingWindowCounterLimiter] that allows up to
+// limit requests per client within any period of length window, as estimated from the counters
+// of the current and previous windows. Windows are aligned to the zero time.
+//
+// It panics if limit or window is not positive.
+func NewSlidingWindowCounterLimiter(limit int, window time.Duration, opts ...LimiterOption) *SlidingWindowCounterLimiter {
+	if limit <= 0 {
+		panic("cerberus: sliding window counter limit must be positive")
+	}
+	if window <= 0 {
+		panic("cerberus: sliding window counter duration must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &SlidingWindowCounterLimiter{
+		limit:     int64(limit),
+		window:    window,
+		period:    truncatingPeriod(window),
+		keyFunc:   options.keyFunc,
+		store:     options.store,
+		costFunc:  options.costFunc,
+		policy:    options.policy,
+		now:       options.clock.Now,
+		clock:     options.clock,
+		topDenied: options.topDenied,
+	}
+}
+
+// IsAllowed adds the cost of the request to the counter of the client making it for the current
+// window. It returns true if the estimated number of requests within the sliding window did not
+// exceed the limit, false otherwise, in which case the cost is taken back off the counter. An error
+// is returned if no key can be derived from the request or the store fails.
+func (l *SlidingWindowCounterLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *SlidingWindowCounterLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	isAllowed, err := l.increment(ctx, key, int64(requestCost(l.costFunc, r)), l.limit)
+	if !isAllowed && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *SlidingWindowCounterLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	counts, err := l.counts(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if counts.mayBeBlocked {
+		if blocked, err := windowBlockedFor(ctx, l.store, slidingWindowCounterPrefix, key); err != nil || blocked > 0 {
+			return false, err
+		}
+	}
+	return counts.estimate()+float64(requestCost(l.costFunc, r)) <= float64(l.limit), nil
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *SlidingWindowCounterLimiter) Commit(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	_, err = l.increment(ctx, key, int64(requestCost(l.costFunc, r)), math.MaxInt64)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. An error is returned
+// if no key can be derived from the request or the store fails.
+func (l *SlidingWindowCounterLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	now := l.now()
+	start, end := l.period(now)
+	return refundWindowCounter(ctx, l.store, slidingWindowCounterPrefix, key, start, int64(requestCost(l.costFunc, r)), end.Sub(now)+l.window)
+}
+
+// GetRateLimitData returns the current state of the counters of the client making the request.
+// Remaining is the budget left within the sliding window, and RetryAfter is the time until the
+// estimate drops enough to cover the cost of the request, if it does not already. The zero
+// RateLimitData is returned if no key can be derived from the request or the store fails.
+func (l *SlidingWindowCounterLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, int64(requestCost(l.costFunc, r)))
+	return data
+}
+
+// Usage returns the current state of the counters of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *SlidingWindowCounterLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *SlidingWindowCounterLimiter) Reset(ctx context.Context, key string) error {
+	start, _ := l.period(l.now())
+	for _, storeKey := range []string{windowCounterKey(slidingWindowCounterPrefix, key, start), windowCounterKey(slidingWindowCounterPrefix, key, start.Add(-l.window)), windowBlockKey(slidingWindowCounterPrefix, key)} {
+		if err := l.store.Delete(ctx, storeKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Block denies all requests of the client identified by key for d. Since counters do not outlive
+// the sliding window, the block is kept in a separate entry of the store, which the limiter checks
+// when the estimate for the client is over the limit or its counter has just been created. An error
+// is returned if the store fails.
+func (l *SlidingWindowCounterLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	now := l.now()
+	start, end := l.period(now)
+	return blockWindowClient(ctx, l.store, slidingWindowCounterPrefix, key, d, start, end.Sub(now)+l.window)
+}
+
+// Keys returns the keys of the clients with a counter in the current or the previous window, in no
+// particular order. An error wrapping [errors.ErrUnsupported] is returned if the store does not
+// implement [KeyScanner].
+func (l *SlidingWindowCounterLimiter) Keys(ctx context.Context) ([]string, error) {
+	start, _ := l.period(l.now())
+	return windowKeys(ctx, l.store, slidingWindowCounterPrefix, start, start.Add(-l.window))
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *SlidingWindowCounterLimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *SlidingWindowCounterLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// windowCounts are the counters of a client for the current and previous windows, at a point of
+// the current window.
+type windowCounts struct {
+	previous, current int64
+
+	// weight is the share of the previous window still overlapped by the sliding window.
+	weight float64
+
+	// elapsed and remaining are the time since the current window began and until it ends.
+	elapsed, remaining time.Duration
+
+	// mayBeBlocked reports whether the counter of the current window carries windowBlockMark, which
+	// is not included in current, or does not exist.
+	mayBeBlocked bool
+}
+
+// estimate returns the estimated number of requests within the sliding window.
+func (c windowCounts) estimate() float64 {
+	return float64(c.previous)*c.weight + float64(c.current)
+}
+
+// increment adds cost to the counter of key for the current window, and reports whether the
+// estimated number of requests within the sliding window stayed within limit. Denied costs are
+// taken back off the counter, so that the previous window of the next one only counts allowed
+// requests.
+func (l *SlidingWindowCounterLimiter) increment(ctx context.Context, key string, cost, limit int64) (bool, error) {
+	if cost == 0 {
+		blocked, err := windowBlockedFor(ctx, l.store, slidingWindowCounterPrefix, key)
+		return blocked == 0 && err == nil, err
+	}
+	now := l.now()
+	start, end := l.period(now)
+	buf := keyBufferPool.Get().(*[]byte)
+	defer keyBufferPool.Put(buf)
+	*buf = appendWindowCounterKey((*buf)[:0], slidingWindowCounterPrefix, key, start)
+	ttl := end.Sub(now) + l.window
+	count, err := incrementByteKey(ctx, l.store, *buf, cost, ttl)
+	if err != nil {
+		return false, err
+	}
+	previous, err := l.readCounter(ctx, appendWindowCounterKey((*buf)[:0], slidingWindowCounterPrefix, key, start.Add(-l.window)), key)
+	if err != nil {
+		return false, err
+	}
+	counts := windowCounts{previous: previous &^ windowBlockMark, current: count, weight: l.weight(now, start)}
+	*buf = appendWindowCounterKey((*buf)[:0], slidingWindowCounterPrefix, key, start)
+	if count == cost || count >= windowBlockMark || limit != math.MaxInt64 && counts.estimate() > float64(limit) {
+		blocked, unmarked, err := settleWindowBlock(ctx, l.store, string(*buf), slidingWindowCounterPrefix, key, cost, ttl)
+		if err != nil || blocked > 0 {
+			return false, err
+		}
+		counts.current = unmarked
+	}
+	if limit == math.MaxInt64 || counts.estimate() <= float64(limit) {
+		return true, nil
+	}
+	if _, err := incrementByteKey(ctx, l.store, *buf, -cost, ttl); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// counts returns the counters of key for the current and previous windows.
+func (l *SlidingWindowCounterLimiter) counts(ctx context.Context, key string) (windowCounts, error) {
+	now := l.now()
+	start, end := l.period(now)
+	buf := keyBufferPool.Get().(*[]byte)
+	defer keyBufferPool.Put(buf)
+	current, err := l.readCounter(ctx, appendWindowCounterKey((*buf)[:0], slidingWindowCounterPrefix, key, start), key)
+	if err != nil {
+		return windowCounts{}, err
+	}
+	previous, err := l.readCounter(ctx, appendWindowCounterKey((*buf)[:0], slidingWindowCounterPrefix, key, start.Add(-l.window)), key)
+	if err != nil {
+		return windowCounts{}, err
+	}
+	return windowCounts{
+		previous:     previous &^ windowBlockMark,
+		current:      current &^ windowBlockMark,
+		weight:       l.weight(now, start),
+		elapsed:      now.Sub(start),
+		remaining:    end.Sub(now),
+		mayBeBlocked: windowMayBeBlocked(current),
+	}, nil
+}
+
+// readCounter returns the counter of key stored under storeKey, or zero if there is none. The
+// counter may carry windowBlockMark.
+func (l *SlidingWindowCounterLimiter) readCounter(ctx context.Context, storeKey []byte, key string) (int64, error) {
+	value, err := getByteKey(ctx, l.store, storeKey)
+	if err != nil {
+		return 0, err
+	}
+	return parseWindowCounter(value, key)
+}
+
+// weight returns the share of the window before the one starting at start that the sliding window
+// ending at now still overlaps.
+func (l *SlidingWindowCounterLimiter) weight(now, start time.Time) float64 {
+	return 1 - float64(now.Sub(start))/float64(l.window)
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost.
+func (l *SlidingWindowCounterLimiter) rateLimitData(ctx context.Context, key string, cost int64) (RateLimitData, error) {
+	counts, err := l.counts(ctx, key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	data := RateLimitData{
+		Limit:     int(l.limit),
+		Remaining: int(max(l.limit-int64(math.Ceil(counts.estimate())), 0)),
+		Window:    l.window,
+		Policy:    l.policy,
+		Rate:      float64(l.limit) / l.window.Seconds(),
+		Burst:     int(l.limit),
+	}
+	if counts.estimate()+float64(cost) > float64(l.limit) && cost <= l.limit {
+		data.RetryAfter = l.retryAfter(counts, cost)
+	}
+	now := l.now()
+	switch {
+	case counts.current > 0:
+		data.ResetAt = now.Add(counts.remaining + l.window)
+	case counts.previous > 0:
+		data.ResetAt = now.Add(counts.remaining)
+	default:
+		data.ResetAt = now
+	}
+	if !counts.mayBeBlocked {
+		return data, nil
+	}
+	blocked, err := windowBlockedFor(ctx, l.store, slidingWindowCounterPrefix, key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	if blocked > 0 {
+		data.Remaining = 0
+		if cost <= l.limit {
+			data.RetryAfter = max(data.RetryAfter, blocked)
+		}
+		data.ResetAt = laterOf(data.ResetAt, now.Add(blocked))
+	}
+	return data, nil
+}
+
+// retryAfter returns the time until the estimate of counts drops enough for a request of the given
+// cost, which must not exceed the limit, to be allowed. The estimate drops as the previous window
+// slides out: within the current window if its counter leaves room for the request, or within the
+// next one otherwise, when the counter of the current window becomes the previous one.
+func (l *SlidingWindowCounterLimiter) retryAfter(counts windowCounts, cost int64) time.Duration {
+	room := l.limit - cost
+	if counts.current <= room {
+		// previous * (1 - x) + current <= room, for the share x of the current window elapsed.
+		share := 1 - float64(room-counts.current)/float64(counts.previous)
+		return time.Duration(math.Ceil(share*float64(l.window))) - counts.elapsed
+	}
+	// current * (1 - x) <= room, for the share x of the next window elapsed.
+	share := 1 - float64(room)/float64(counts.current)
+	return counts.remaining + time.Duration(math.Ceil(share*float64(l.window)))
+}
+
+// key returns the key identifying the client making the request.
+func (l *SlidingWindowCounterLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *SlidingWindowCounterLimiter) timeSource() Clock {
+	return l.clock
+}
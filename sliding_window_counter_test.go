@@ -0,0 +1,123 @@
+package cerberus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Test the limiter allows up to the limit within a window
+func TestSlidingWindowCounterLimiterAllowsUpToLimit(t *testing.T) {
+	limiter := NewSlidingWindowCounterLimiter(2, time.Minute)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding limit to be denied")
+	}
+}
+
+// Test the previous window is weighted by the share the sliding window still overlaps
+func TestSlidingWindowCounterLimiterWeightsPreviousWindow(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowCounterLimiter(10, time.Minute)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	for i := 0; i < 10; i++ {
+		limiter.IsAllowed(req)
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 0 || data.RetryAfter != 46*time.Second {
+		t.Errorf("expected {10 0 46s}; got %+v", data)
+	}
+
+	clock.Advance(40 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request at the window boundary to be denied")
+	}
+
+	clock.Advance(30 * time.Second)
+	for i := 0; i < 5; i++ {
+		if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+			t.Fatalf("expected request %d halfway through the window to be allowed", i+1)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the estimate to be denied")
+	}
+	if data := limiter.GetRateLimitData(req); data.Remaining != 0 || data.RetryAfter != 6*time.Second {
+		t.Errorf("expected {10 0 6s}; got %+v", data)
+	}
+	clock.Advance(6 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request after RetryAfter to be allowed")
+	}
+}
+
+// Test keys and usage cover the current and previous windows
+func TestSlidingWindowCounterLimiterKeysAndUsage(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowCounterLimiter(4, time.Minute)
+	limiter.now = clock.Now
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	clock.Advance(70 * time.Second)
+	limiter.IsAllowed(newRequestFrom("192.0.2.2:1234"))
+
+	keys, err := limiter.Keys(context.Background())
+	if err != nil || len(keys) != 2 {
+		t.Errorf("expected 2 keys; got %v, %v", keys, err)
+	}
+	data, err := limiter.Usage(context.Background(), "192.0.2.1")
+	if err != nil || data.Limit != 4 || data.Remaining != 3 {
+		t.Errorf("expected {4 3 0s}; got %+v, %v", data, err)
+	}
+	if err := limiter.Reset(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := limiter.Usage(context.Background(), "192.0.2.1"); data.Remaining != 4 {
+		t.Errorf("expected the full budget after a reset; got %+v", data)
+	}
+}
+
+// Test blocks are lifted in the middle of a window, and spanning windows
+func TestSlidingWindowCounterLimiterBlock(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(WithCleanupInterval(0))
+	store.now = clock.Now
+	limiter := NewSlidingWindowCounterLimiter(4, time.Minute, WithStore(store))
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	limiter.Block(context.Background(), "192.0.2.1", 10*time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected blocked client to be denied")
+	}
+	clock.Advance(11 * time.Second)
+	if data := limiter.GetRateLimitData(req); data.Remaining != 3 {
+		t.Errorf("expected 3 remaining requests, as before the block; got %+v", data)
+	}
+
+	limiter.Block(context.Background(), "192.0.2.1", 2*time.Minute)
+	clock.Advance(time.Minute)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected blocked client to be denied in the next window")
+	}
+	clock.Advance(2 * time.Minute)
+	if isAllowed, err := limiter.IsAllowed(req); !isAllowed || err != nil {
+		t.Errorf("expected client to be allowed once the block ends; got %v, %v", isAllowed, err)
+	}
+}
+
+// Benchmark a client making requests at the rate of the limit
+func BenchmarkSlidingWindowCounterLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowCounterLimiter(100, time.Second)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
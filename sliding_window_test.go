@@ -0,0 +1,73 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the limiter allows up to the limit within a window
+func TestSlidingWindowLimiterAllowsUpToLimit(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(2, time.Minute)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding limit to be denied")
+	}
+}
+
+// Test the window slides with time instead of resetting at boundaries
+func TestSlidingWindowLimiterSlides(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowLimiter(2, time.Minute)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	clock.Advance(30 * time.Second)
+	limiter.IsAllowed(req)
+	clock.Advance(20 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Fatalf("expected request within window to be denied")
+	}
+	clock.Advance(10 * time.Second)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request to be allowed once the oldest request left the window")
+	}
+}
+
+// Test rate limit data reflects the log state
+func TestSlidingWindowLimiterGetRateLimitData(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowLimiter(2, time.Minute)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	data := limiter.GetRateLimitData(req)
+	if data.Limit != 2 || data.Remaining != 1 || data.RetryAfter != 0 {
+		t.Errorf("expected {2 1 0}; got %+v", data)
+	}
+	clock.Advance(15 * time.Second)
+	limiter.IsAllowed(req)
+	data = limiter.GetRateLimitData(req)
+	if data.Remaining != 0 || data.RetryAfter != 45*time.Second {
+		t.Errorf("expected {2 0 45s}; got %+v", data)
+	}
+	if data.Rate != 2.0/60 || data.Burst != 2 {
+		t.Errorf("expected rate 2/60 and burst 2; got %+v", data)
+	}
+}
+
+// Benchmark a client making requests at the rate of the limit, with a full log
+func BenchmarkSlidingWindowLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewSlidingWindowLimiter(100, time.Second)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
@@ -0,0 +1,324 @@
+// Package sqlstore provides a [cerberus.Store] backed by a SQL database through database/sql, so
+// that low-traffic applications get durable counters shared by all their instances without running
+// additional infrastructure.
+//
+// Entries are rows of a single table, created with [Store.CreateTable]. Writes are upserts, and
+// read-modify-write operations lock the row they update for the duration of a transaction, so that
+// concurrent updates never overwrite each other:
+//
+//	db, err := sql.Open("pgx", "postgres://localhost/app")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	store := sqlstore.New(db, sqlstore.Postgres, "rate_limits")
+//	if err := store.CreateTable(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//	limiter := cerberus.NewFixedWindowLimiter(100, time.Minute, cerberus.WithStore(store))
+//
+// Expired rows are ignored, and removed when they are overwritten or by [Store.DeleteExpired],
+// which should be called periodically. Every check of a limiter takes one or more round trips to
+// the database, so the store is not meant for high traffic services.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+)
+
+// Dialect describes the SQL dialect of a database.
+type Dialect struct {
+	name string
+
+	// placeholder returns the placeholder of the nth parameter of a query, starting at 1.
+	placeholder func(n int) string
+
+	// createTable is the statement creating the table, with %s standing for its name.
+	createTable string
+
+	// upsert is the clause of an INSERT statement replacing the value and expiration time of an
+	// existing row.
+	upsert string
+
+	// insertIgnore is the INSERT statement, with %s standing for the table, that inserts nothing
+	// if the row exists.
+	insertIgnore string
+
+	// forUpdate is the clause of a SELECT statement locking the selected rows.
+	forUpdate string
+}
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	return d.name
+}
+
+var (
+	// Postgres is the dialect of PostgreSQL.
+	Postgres = Dialect{
+		name:         "postgres",
+		placeholder:  func(n int) string { return "$" + strconv.Itoa(n) },
+		createTable:  "CREATE TABLE IF NOT EXISTS %s (store_key TEXT PRIMARY KEY, store_value BYTEA NOT NULL, expires_at BIGINT)",
+		upsert:       "ON CONFLICT (store_key) DO UPDATE SET store_value = EXCLUDED.store_value, expires_at = EXCLUDED.expires_at",
+		insertIgnore: "INSERT INTO %s (store_key, store_value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (store_key) DO NOTHING",
+		forUpdate:    " FOR UPDATE",
+	}
+
+	// MySQL is the dialect of MySQL and MariaDB. Keys are binary strings of up to 255 bytes, so
+	// that they are compared case-sensitively.
+	MySQL = Dialect{
+		name:         "mysql",
+		placeholder:  func(int) string { return "?" },
+		createTable:  "CREATE TABLE IF NOT EXISTS %s (store_key VARBINARY(255) PRIMARY KEY, store_value BLOB NOT NULL, expires_at BIGINT)",
+		upsert:       "ON DUPLICATE KEY UPDATE store_value = VALUES(store_value), expires_at = VALUES(expires_at)",
+		insertIgnore: "INSERT IGNORE INTO %s (store_key, store_value, expires_at) VALUES (?, ?, ?)",
+		forUpdate:    " FOR UPDATE",
+	}
+
+	// SQLite is the dialect of SQLite. SQLite locks the whole database rather than rows, so
+	// concurrent transactions may fail with a busy error unless the database is used through a
+	// single connection, as set with db.SetMaxOpenConns(1).
+	SQLite = Dialect{
+		name:         "sqlite",
+		placeholder:  func(int) string { return "?" },
+		createTable:  "CREATE TABLE IF NOT EXISTS %s (store_key TEXT PRIMARY KEY, store_value BLOB NOT NULL, expires_at INTEGER)",
+		upsert:       "ON CONFLICT (store_key) DO UPDATE SET store_value = excluded.store_value, expires_at = excluded.expires_at",
+		insertIgnore: "INSERT INTO %s (store_key, store_value, expires_at) VALUES (?, ?, ?) ON CONFLICT (store_key) DO NOTHING",
+	}
+)
+
+// errConflict reports that a row was inserted concurrently, and that the transaction must be
+// retried.
+var errConflict = errors.New("sqlstore: concurrent insert")
+
+// Store is a [cerberus.Store] and [cerberus.KeyScanner] keeping its entries in a table of a SQL
+// database. Expiration times are stored in Unix milliseconds, in the expires_at column.
+//
+// A Store is safe for concurrent use by multiple goroutines.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	now     func() time.Time
+
+	getQuery        string
+	selectForUpdate string
+	setQuery        string
+	insertIgnore    string
+	updateQuery     string
+	deleteQuery     string
+	scanQuery       string
+	expireQuery     string
+}
+
+// New creates a new [Store] keeping its entries in the named table of db, which uses dialect. The
+// table name is used as is in queries, and must not come from untrusted input. The database is
+// not closed by the store.
+func New(db *sql.DB, dialect Dialect, table string) *Store {
+	p := dialect.placeholder
+	return &Store{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+		now:     time.Now,
+
+		getQuery:        fmt.Sprintf("SELECT store_value, expires_at FROM %s WHERE store_key = %s", table, p(1)),
+		selectForUpdate: fmt.Sprintf("SELECT store_value, expires_at FROM %s WHERE store_key = %s%s", table, p(1), dialect.forUpdate),
+		setQuery:        fmt.Sprintf("INSERT INTO %s (store_key, store_value, expires_at) VALUES (%s, %s, %s) %s", table, p(1), p(2), p(3), dialect.upsert),
+		insertIgnore:    fmt.Sprintf(dialect.insertIgnore, table),
+		updateQuery:     fmt.Sprintf("UPDATE %s SET store_value = %s, expires_at = %s WHERE store_key = %s", table, p(1), p(2), p(3)),
+		deleteQuery:     fmt.Sprintf("DELETE FROM %s WHERE store_key = %s", table, p(1)),
+		scanQuery:       fmt.Sprintf("SELECT store_key FROM %s WHERE store_key LIKE %s ESCAPE '!' AND (expires_at IS NULL OR expires_at > %s)", table, p(1), p(2)),
+		expireQuery:     fmt.Sprintf("DELETE FROM %s WHERE expires_at <= %s", table, p(1)),
+	}
+}
+
+// CreateTable creates the table of the store if it does not exist.
+func (s *Store) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(s.dialect.createTable, s.table))
+	return err
+}
+
+// Get returns the value stored under key, or nil if the key does not exist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, _, _, err := s.get(s.db.QueryRowContext(ctx, s.getQuery, key))
+	return value, err
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.db.ExecContext(ctx, s.setQuery, key, nonNil(value), s.expiresAt(ttl))
+	return err
+}
+
+// Increment adds delta to the integer stored under key and returns the result. A missing key is
+// created with the given TTL, and the TTL of an existing key is left unchanged.
+func (s *Store) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	var n int64
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		value, expiresAt, found, err := s.get(tx.QueryRowContext(ctx, s.selectForUpdate, key))
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			n = delta
+			return s.put(ctx, tx, found, key, []byte(strconv.FormatInt(n, 10)), s.expiresAt(ttl))
+		}
+		current, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an integer", cerberus.ErrMalformedValue, key)
+		}
+		n = current + delta
+		_, err = tx.ExecContext(ctx, s.updateQuery, []byte(strconv.FormatInt(n, 10)), expiresAt, key)
+		return err
+	})
+	return n, err
+}
+
+// CompareAndSwap stores new under key only if the current value equals old, where a nil old value
+// matches a missing key. It reports whether the value was stored.
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	var swapped bool
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		value, _, found, err := s.get(tx.QueryRowContext(ctx, s.selectForUpdate, key))
+		if err != nil {
+			return err
+		}
+		swapped = value == nil && old == nil || value != nil && old != nil && string(value) == string(old)
+		if !swapped {
+			return nil
+		}
+		return s.put(ctx, tx, found, key, nonNil(new), s.expiresAt(ttl))
+	})
+	return swapped, err
+}
+
+// TTL returns the remaining time to live of key. It returns zero if the key does not exist or does
+// not expire.
+func (s *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
+	value, expiresAt, _, err := s.get(s.db.QueryRowContext(ctx, s.getQuery, key))
+	if err != nil || value == nil || !expiresAt.Valid {
+		return 0, err
+	}
+	return time.UnixMilli(expiresAt.Int64).Sub(s.now()), nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.deleteQuery, key)
+	return err
+}
+
+// ScanKeys returns the keys starting with prefix that have not expired, in no particular order.
+func (s *Store) ScanKeys(ctx context.Context, prefix string) ([]string, error) {
+	pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+	rows, err := s.db.QueryContext(ctx, s.scanQuery, pattern, s.now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteExpired removes the rows of expired keys, and returns how many were removed.
+func (s *Store) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, s.expireQuery, s.now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// get scans the value and expiration time of a row, and reports whether the row exists. The value
+// is nil if the row does not exist or has expired.
+func (s *Store) get(row *sql.Row) ([]byte, sql.NullInt64, bool, error) {
+	var value []byte
+	var expiresAt sql.NullInt64
+	err := row.Scan(&value, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, expiresAt, false, nil
+	}
+	if err != nil {
+		return nil, expiresAt, false, err
+	}
+	if expiresAt.Valid && expiresAt.Int64 <= s.now().UnixMilli() {
+		return nil, expiresAt, true, nil
+	}
+	return nonNil(value), expiresAt, true, nil
+}
+
+// put stores value under key within tx, updating the row of key if found, which must have been
+// selected for update, and inserting it otherwise. errConflict is returned if the row was inserted
+// concurrently since it was found missing.
+func (s *Store) put(ctx context.Context, tx *sql.Tx, found bool, key string, value []byte, expiresAt sql.NullInt64) error {
+	if found {
+		_, err := tx.ExecContext(ctx, s.updateQuery, value, expiresAt, key)
+		return err
+	}
+	result, err := tx.ExecContext(ctx, s.insertIgnore, key, value, expiresAt)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return errConflict
+	}
+	return nil
+}
+
+// transact runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+// The transaction is retried if fn returns errConflict.
+func (s *Store) transact(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	for {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		err = fn(tx)
+		if err == nil {
+			return tx.Commit()
+		}
+		tx.Rollback()
+		if !errors.Is(err, errConflict) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// expiresAt returns the expiration time of a key written now with ttl, in Unix milliseconds, or
+// NULL if ttl is not positive.
+func (s *Store) expiresAt(ttl time.Duration) sql.NullInt64 {
+	if ttl <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: s.now().Add(ttl).UnixMilli(), Valid: true}
+}
+
+// nonNil returns b, or an empty slice if b is nil, since the value column is not nullable.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
@@ -0,0 +1,180 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/cerberus"
+	_ "modernc.org/sqlite"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newStore returns a store backed by a new SQLite database.
+func newStore(t *testing.T) (*Store, *fakeClock) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	store := New(db, SQLite, "rate_limits")
+	store.now = clock.Now
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+	return store, clock
+}
+
+// Test the store implements the semantics of cerberus.Store
+func TestStore(t *testing.T) {
+	store, clock := newStore(t)
+	ctx := context.Background()
+
+	if value, err := store.Get(ctx, "missing"); err != nil || value != nil {
+		t.Errorf("expected nil for a missing key; got %q, %v", value, err)
+	}
+	store.Set(ctx, "a", []byte("value"), time.Minute)
+	if value, _ := store.Get(ctx, "a"); string(value) != "value" {
+		t.Errorf("expected value; got %q", value)
+	}
+	if ttl, _ := store.TTL(ctx, "a"); ttl != time.Minute {
+		t.Errorf("expected a TTL of a minute; got %v", ttl)
+	}
+	store.Set(ctx, "empty", nil, 0)
+	if value, _ := store.Get(ctx, "empty"); value == nil || len(value) != 0 {
+		t.Errorf("expected an empty value; got %v", value)
+	}
+
+	if swapped, err := store.CompareAndSwap(ctx, "b", nil, []byte("x"), 0); err != nil || !swapped {
+		t.Errorf("expected swap of a missing key; got %v, %v", swapped, err)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", nil, []byte("y"), 0); swapped {
+		t.Errorf("expected no swap of an existing key against nil")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("x"), []byte("y"), 0); !swapped {
+		t.Errorf("expected swap of a matching value")
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "b", []byte("x"), []byte("z"), 0); swapped {
+		t.Errorf("expected no swap of a stale value")
+	}
+	if keys, err := store.ScanKeys(ctx, ""); err != nil || !slices.Equal(sorted(keys), []string{"a", "b", "empty"}) {
+		t.Errorf("expected keys [a b empty]; got %v, %v", keys, err)
+	}
+
+	clock.Advance(time.Minute)
+	if value, _ := store.Get(ctx, "a"); value != nil {
+		t.Errorf("expected an expired key to be missing; got %q", value)
+	}
+	if swapped, _ := store.CompareAndSwap(ctx, "a", nil, []byte("new"), 0); !swapped {
+		t.Errorf("expected swap of an expired key against nil")
+	}
+	store.Delete(ctx, "b")
+	if value, _ := store.Get(ctx, "b"); value != nil {
+		t.Errorf("expected a deleted key to be missing; got %q", value)
+	}
+}
+
+// Test increments create, update, and reject keys
+func TestStoreIncrement(t *testing.T) {
+	store, clock := newStore(t)
+	ctx := context.Background()
+
+	if n, err := store.Increment(ctx, "counter", 2, time.Minute); err != nil || n != 2 {
+		t.Errorf("expected 2; got %d, %v", n, err)
+	}
+	if n, err := store.Increment(ctx, "counter", 3, time.Hour); err != nil || n != 5 {
+		t.Errorf("expected 5; got %d, %v", n, err)
+	}
+	if ttl, _ := store.TTL(ctx, "counter"); ttl != time.Minute {
+		t.Errorf("expected the TTL of the counter to be kept; got %v", ttl)
+	}
+	clock.Advance(time.Minute)
+	if n, _ := store.Increment(ctx, "counter", 1, 0); n != 1 {
+		t.Errorf("expected an expired counter to restart; got %d", n)
+	}
+	store.Set(ctx, "text", []byte("text"), 0)
+	if _, err := store.Increment(ctx, "text", 1, 0); !errors.Is(err, cerberus.ErrMalformedValue) {
+		t.Errorf("expected ErrMalformedValue; got %v", err)
+	}
+}
+
+// Test keys are scanned literally and expired rows are deleted
+func TestStoreScanKeysAndDeleteExpired(t *testing.T) {
+	store, clock := newStore(t)
+	ctx := context.Background()
+
+	store.Set(ctx, "a_1", []byte("1"), time.Minute)
+	store.Set(ctx, "ab1", []byte("1"), 0)
+	store.Set(ctx, "a%", []byte("1"), 0)
+	if keys, _ := store.ScanKeys(ctx, "a_"); !slices.Equal(keys, []string{"a_1"}) {
+		t.Errorf("expected keys [a_1]; got %v", keys)
+	}
+	if keys, _ := store.ScanKeys(ctx, "a%"); !slices.Equal(keys, []string{"a%"}) {
+		t.Errorf("expected keys [a%%]; got %v", keys)
+	}
+	clock.Advance(time.Minute)
+	if n, err := store.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Errorf("expected 1 deleted row; got %d, %v", n, err)
+	}
+}
+
+// Test the queries of each dialect use its placeholders
+func TestDialects(t *testing.T) {
+	postgres := New(nil, Postgres, "limits")
+	if !strings.Contains(postgres.updateQuery, "store_key = $3") || !strings.HasSuffix(postgres.selectForUpdate, "FOR UPDATE") {
+		t.Errorf("expected numbered placeholders and row locks; got %q, %q", postgres.updateQuery, postgres.selectForUpdate)
+	}
+	mysql := New(nil, MySQL, "limits")
+	if !strings.Contains(mysql.setQuery, "VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE") {
+		t.Errorf("expected an upsert on duplicate key; got %q", mysql.setQuery)
+	}
+}
+
+// Test built-in limiters work on top of the store
+func TestStoreWithLimiter(t *testing.T) {
+	store, _ := newStore(t)
+	limiter := cerberus.NewFixedWindowLimiter(2, time.Minute, cerberus.WithStore(store))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 2; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding the limit to be denied")
+	}
+}
+
+func sorted(keys []string) []string {
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package cerberus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMalformedValue is returned by the built-in limiters when a value read from a [Store] cannot
+// be decoded, for example because the same key is used by two different kinds of limiter.
+var ErrMalformedValue = errors.New("cerberus: malformed value in store")
+
+// Store is the storage backend of the built-in limiters. It separates the rate limiting
+// algorithms from where their state is kept, so the same algorithm can run against process
+// memory, or against a shared backend such as Redis, Memcached, or DynamoDB to enforce limits
+// across multiple instances of a service.
+//
+// Values are opaque byte slices, except for values written by Increment, which are stored as
+// base 10 integers. Keys expire after the time to live (TTL) given when they were written; a
+// non-positive TTL means the key never expires.
+//
+// Implementations must be safe for concurrent use by multiple goroutines, and every method must
+// be atomic with respect to the others. Byte slices passed to and returned from a Store must not
+// be modified by the caller.
+type Store interface {
+	// Get returns the value stored under key, or nil if the key does not exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores value under key, replacing any existing value.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Increment adds delta to the integer stored under key and returns the result. A missing
+	// key is treated as zero and created with the given TTL; the TTL of an existing key is left
+	// unchanged.
+	Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// CompareAndSwap stores new under key only if the current value equals old, where a nil old
+	// value matches a missing key. It reports whether the value was stored.
+	CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error)
+
+	// TTL returns the remaining time to live of key. It returns zero if the key does not exist
+	// or does not expire.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// KeyScanner is implemented by stores that can enumerate their keys, such as [MemoryStore]. It
+// allows the built-in limiters to list the clients they are tracking. Scanning may be slow on
+// large stores, and is meant for operational tooling rather than for the request path.
+type KeyScanner interface {
+	// ScanKeys returns the keys starting with prefix that have not expired, in no particular
+	// order.
+	ScanKeys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// scanKeys returns the keys of store starting with prefix. An error wrapping
+// [errors.ErrUnsupported] is returned if store does not implement [KeyScanner].
+func scanKeys(ctx context.Context, store Store, prefix string) ([]string, error) {
+	scanner, ok := store.(KeyScanner)
+	if !ok {
+		return nil, fmt.Errorf("cerberus: store %T cannot list keys: %w", store, errors.ErrUnsupported)
+	}
+	return scanner.ScanKeys(ctx, prefix)
+}
+
+// scanClientKeys returns the keys of the clients whose state a limiter keeps in store under
+// prefix. Each built-in limiter prefixes its store keys with the name of its algorithm, so that
+// limiters of different kinds sharing a store do not count against each other, and listing the
+// clients of one skips the entries of the others. An error wrapping [errors.ErrUnsupported] is
+// returned if store does not implement [KeyScanner].
+func scanClientKeys(ctx context.Context, store Store, prefix string) ([]string, error) {
+	storeKeys, err := scanKeys(ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(storeKeys))
+	for _, storeKey := range storeKeys {
+		keys = append(keys, strings.TrimPrefix(storeKey, prefix))
+	}
+	return keys, nil
+}
+
+// modify atomically replaces the value stored under key with the result of fn, retrying with the
+// latest value whenever it was changed concurrently. fn receives the current value, or nil if the
+// key does not exist, and returns the value to store and its TTL. If fn returns a nil value or an
+// error, nothing is stored.
+func modify(ctx context.Context, store Store, key string, fn func(value []byte) ([]byte, time.Duration, error)) error {
+	for {
+		old, err := store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		value, ttl, err := fn(old)
+		if err != nil || value == nil {
+			return err
+		}
+		swapped, err := store.CompareAndSwap(ctx, key, old, value, ttl)
+		if err != nil || swapped {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// modifyOrDelete is like modify, but fn also reports whether the new value is the state of a new
+// client, in which case key is deleted rather than written, since a missing key stands for that
+// state. A request counted concurrently between the last swap and the deletion is forgotten, which
+// at worst gives back the budget it consumed.
+func modifyOrDelete(ctx context.Context, store Store, key string, fn func(value []byte) ([]byte, time.Duration, bool, error)) error {
+	var fresh bool
+	err := modify(ctx, store, key, func(value []byte) ([]byte, time.Duration, error) {
+		value, ttl, isFresh, err := fn(value)
+		if fresh = isFresh && err == nil; fresh {
+			return nil, 0, nil
+		}
+		return value, ttl, err
+	})
+	if err != nil || !fresh {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
+
+// byteKeyStore is implemented by stores that can look keys up from byte slices without retaining
+// them, such as [MemoryStore]. Limiters deriving store keys from the keys of clients build them in
+// buffers from keyBufferPool, and pass the buffers to these methods when the store implements them,
+// so that checking a request does not allocate a string per store key.
+type byteKeyStore interface {
+	getByteKey(ctx context.Context, key []byte) ([]byte, error)
+	incrementByteKey(ctx context.Context, key []byte, delta int64, ttl time.Duration) (int64, error)
+	ttlByteKey(ctx context.Context, key []byte) (time.Duration, error)
+}
+
+// keyBufferPool holds the buffers store keys are built in. See [byteKeyStore].
+var keyBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 64)
+		return &b
+	},
+}
+
+// getByteKey is like store.Get, with a key built in a buffer from keyBufferPool.
+func getByteKey(ctx context.Context, store Store, key []byte) ([]byte, error) {
+	if s, ok := store.(byteKeyStore); ok {
+		return s.getByteKey(ctx, key)
+	}
+	return store.Get(ctx, string(key))
+}
+
+// incrementByteKey is like store.Increment, with a key built in a buffer from keyBufferPool.
+func incrementByteKey(ctx context.Context, store Store, key []byte, delta int64, ttl time.Duration) (int64, error) {
+	if s, ok := store.(byteKeyStore); ok {
+		return s.incrementByteKey(ctx, key, delta, ttl)
+	}
+	return store.Increment(ctx, string(key), delta, ttl)
+}
+
+// ttlByteKey is like store.TTL, with a key built in a buffer from keyBufferPool.
+func ttlByteKey(ctx context.Context, store Store, key []byte) (time.Duration, error) {
+	if s, ok := store.(byteKeyStore); ok {
+		return s.ttlByteKey(ctx, key)
+	}
+	return store.TTL(ctx, string(key))
+}
+
+// encodeUint64s encodes values as consecutive big-endian 64-bit integers. It is used by the
+// built-in limiters to serialize their per-key state.
+func encodeUint64s(values ...uint64) []byte {
+	b := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint64(b, v)
+	}
+	return b
+}
+
+// decodeUint64s decodes a value produced by [encodeUint64s]. It returns false if b is not a
+// sequence of 64-bit integers.
+func decodeUint64s(b []byte) ([]uint64, bool) {
+	if len(b)%8 != 0 {
+		return nil, false
+	}
+	values := make([]uint64, len(b)/8)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	return values, true
+}
@@ -0,0 +1,147 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ThrottledResponseWriter is an [http.ResponseWriter] pacing the bytes written to the wrapped
+// writer at a budget of bytes per second per key kept in a [Store], so that downloads sharing a key
+// share its bandwidth fairly, even across instances of a service sharing the store. It suits file
+// download endpoints, where a client may otherwise saturate the network with a few requests.
+//
+// Behavior:
+//   - The budget of a key is tracked like a [GCRALimiter] tracks requests: a single timestamp per
+//     key, the time at which all the bytes written so far will have been paid for, is advanced by
+//     the time each write costs at the rate of the budget.
+//   - Writes are split into chunks of at most the burst, and each chunk waits until the timestamp
+//     of the key is no more than the burst ahead, so that up to the burst is written back to back,
+//     and the rate is sustained after that. Writes sharing a key wait in turn.
+//   - A wait is cut short when the context of the writer is done, and the write then fails with the
+//     error of the context.
+//   - If the store fails, writes are not throttled, so that downloads are not broken by an outage
+//     of the store. The first error is reported by [ThrottledResponseWriter.Err].
+//
+// A ThrottledResponseWriter is meant for a single response, like the writer it wraps.
+//
+// Example usage:
+//
+//	func serveFile(w http.ResponseWriter, r *http.Request) {
+//		key, _ := KeyByIP(r)
+//		tw := NewThrottledResponseWriter(r.Context(), w, store, "download:"+key, 1<<20, 64<<10)
+//		http.ServeFile(tw, r, path)
+//	}
+type ThrottledResponseWriter struct {
+	http.ResponseWriter
+
+	ctx       context.Context
+	store     Store
+	key       string
+	rate      float64
+	burst     int
+	tolerance time.Duration
+	clock     Clock
+	err       error
+}
+
+// NewThrottledResponseWriter creates a new [ThrottledResponseWriter] writing to w at most
+// bytesPerSecond on average, with bursts of up to burst bytes, for the budget stored under key in
+// store. ctx is the context of the request, usually r.Context(), which bounds waits and the
+// operations on the store.
+//
+// It panics if bytesPerSecond or burst is not positive.
+func NewThrottledResponseWriter(ctx context.Context, w http.ResponseWriter, store Store, key string, bytesPerSecond float64, burst int) *ThrottledResponseWriter {
+	if !(bytesPerSecond > 0) || burst <= 0 {
+		panic("cerberus: throttled writer rate and burst must be positive")
+	}
+	return &ThrottledResponseWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		store:          store,
+		key:            key,
+		rate:           bytesPerSecond,
+		burst:          burst,
+		tolerance:      bytesDuration(burst, bytesPerSecond),
+		clock:          SystemClock,
+	}
+}
+
+// Write writes b to the wrapped writer in chunks of at most the burst, each waiting for the budget
+// of the key to cover it.
+func (w *ThrottledResponseWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), w.burst)]
+		if err := w.wait(len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+// Unwrap returns the wrapped [http.ResponseWriter], for use by [http.ResponseController].
+func (w *ThrottledResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Err returns the first error returned by the store, if any, during which writes were not
+// throttled.
+func (w *ThrottledResponseWriter) Err() error {
+	return w.err
+}
+
+// wait charges n bytes to the budget of the key, and waits until they are within the burst. It
+// returns the error of the context of the writer if it is done first.
+func (w *ThrottledResponseWriter) wait(n int) error {
+	cost := bytesDuration(n, w.rate)
+	var delay time.Duration
+	err := modify(w.ctx, w.store, w.key, func(value []byte) ([]byte, time.Duration, error) {
+		now := w.clock.Now()
+		tat := now
+		if value != nil {
+			fields, ok := decodeUint64s(value)
+			if !ok || len(fields) != 1 {
+				return nil, 0, fmt.Errorf("%w: %q is not a bandwidth timestamp", ErrMalformedValue, w.key)
+			}
+			if t := time.Unix(0, int64(fields[0])); t.After(now) {
+				tat = t
+			}
+		}
+		tat = tat.Add(cost)
+		delay = tat.Sub(now) - w.tolerance
+		return encodeUint64s(uint64(tat.UnixNano())), tat.Sub(now), nil
+	})
+	if err != nil {
+		if ctxErr := w.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if w.err == nil {
+			w.err = err
+		}
+		return nil
+	}
+	if delay <= 0 {
+		return nil
+	}
+	timer := w.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// bytesDuration returns the time n bytes take at rate bytes per second.
+func bytesDuration(n int, rate float64) time.Duration {
+	return time.Duration(float64(n) / rate * float64(time.Second))
+}
@@ -0,0 +1,136 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestThrottledWriter returns a ThrottledResponseWriter writing to a recorder, waiting by clock.
+func newTestThrottledWriter(store Store, clock Clock, bytesPerSecond float64, burst int) (*ThrottledResponseWriter, *httptest.ResponseRecorder) {
+	rr := httptest.NewRecorder()
+	w := NewThrottledResponseWriter(context.Background(), rr, store, "download:192.0.2.1", bytesPerSecond, burst)
+	w.clock = clock
+	return w, rr
+}
+
+// TestThrottledResponseWriterPacesWrites tests that the bytes written beyond the burst are paced
+// at the rate of the writer, in chunks of at most the burst.
+func TestThrottledResponseWriterPacesWrites(t *testing.T) {
+	clock := newWaitRecordingClock()
+	store := NewMemoryStore()
+	store.now = clock.Now
+	w, rr := newTestThrottledWriter(store, clock, 1000, 500)
+
+	body := strings.Repeat("x", 2500)
+	n, err := io.WriteString(w, body)
+	if err != nil || n != len(body) {
+		t.Errorf("expected %d bytes written without error; got %d, %v", len(body), n, err)
+	}
+	if rr.Body.String() != body {
+		t.Errorf("expected the full body to be written; got %d bytes", rr.Body.Len())
+	}
+	if got := clock.total(); got != 2*time.Second {
+		t.Errorf("expected a total wait of 2s; got %v", got)
+	}
+	if len(clock.waits) != 4 {
+		t.Errorf("expected 4 waits of a chunk each; got %d", len(clock.waits))
+	}
+}
+
+// TestThrottledResponseWriterSharesBudget tests that writers of the same key share its budget, and
+// that writers of other keys do not.
+func TestThrottledResponseWriterSharesBudget(t *testing.T) {
+	clock := newWaitRecordingClock()
+	store := NewMemoryStore()
+	store.now = clock.Now
+	first, _ := newTestThrottledWriter(store, clock, 1000, 1000)
+	second, _ := newTestThrottledWriter(store, clock, 1000, 1000)
+	other := NewThrottledResponseWriter(context.Background(), httptest.NewRecorder(), store, "download:192.0.2.2", 1000, 1000)
+	other.clock = clock
+
+	io.WriteString(first, strings.Repeat("x", 1000))
+	io.WriteString(other, strings.Repeat("x", 1000))
+	if got := clock.total(); got != 0 {
+		t.Errorf("expected no wait within the burst of each key; got %v", got)
+	}
+
+	io.WriteString(second, strings.Repeat("x", 500))
+	if got := clock.total(); got != 500*time.Millisecond {
+		t.Errorf("expected a wait of 500ms once the burst of the key is used; got %v", got)
+	}
+}
+
+// TestThrottledResponseWriterContextDone tests that a wait fails with the error of the context of
+// the writer once it is done.
+func TestThrottledResponseWriterContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := NewThrottledResponseWriter(ctx, httptest.NewRecorder(), NewMemoryStore(), "download:192.0.2.1", 1, 1)
+
+	n, err := io.WriteString(w, "xxx")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+	if n != 1 {
+		t.Errorf("expected the burst to be written before waiting; got %d bytes", n)
+	}
+}
+
+// TestThrottledResponseWriterStoreError tests that writes are not throttled when the store fails,
+// and that the error is reported.
+func TestThrottledResponseWriterStoreError(t *testing.T) {
+	clock := newWaitRecordingClock()
+	store := NewMemoryStore()
+	store.Set(context.Background(), "download:192.0.2.1", []byte("malformed"), 0)
+	w, rr := newTestThrottledWriter(store, clock, 1, 1)
+
+	n, err := io.WriteString(w, "xxx")
+
+	if err != nil || n != 3 || rr.Body.String() != "xxx" {
+		t.Errorf("expected 3 bytes written without error; got %d, %v", n, err)
+	}
+	if got := clock.total(); got != 0 {
+		t.Errorf("expected no wait; got %v", got)
+	}
+	if !errors.Is(w.Err(), ErrMalformedValue) {
+		t.Errorf("expected %v; got %v", ErrMalformedValue, w.Err())
+	}
+}
+
+// TestThrottledResponseWriterUnwrap tests that the writer can be unwrapped by
+// http.ResponseController.
+func TestThrottledResponseWriterUnwrap(t *testing.T) {
+	rr := httptest.NewRecorder()
+	w := NewThrottledResponseWriter(context.Background(), rr, NewMemoryStore(), "download:192.0.2.1", 1000, 1000)
+
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("expected no error flushing; got %v", err)
+	}
+	if !rr.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+}
+
+// TestNewThrottledResponseWriterPanics tests that invalid rates and bursts panic.
+func TestNewThrottledResponseWriterPanics(t *testing.T) {
+	for _, tc := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {1, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for rate %v and burst %d", tc.rate, tc.burst)
+				}
+			}()
+			NewThrottledResponseWriter(context.Background(), httptest.NewRecorder(), NewMemoryStore(), "key", tc.rate, tc.burst)
+		}()
+	}
+}
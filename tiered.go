@@ -0,0 +1,110 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// ErrUnknownTier is returned by a [TieredLimiter] when a request is resolved to a tier that has
+// no rate limiter.
+var ErrUnknownTier = errors.New("cerberus: unknown tier")
+
+// TierFunc resolves the tier of the client making a request, such as the subscription plan
+// ("free", "pro", "enterprise") associated with its API key. It returns an error if the tier
+// cannot be determined, for example because the account service is unreachable.
+type TierFunc func(*http.Request) (string, error)
+
+// TieredLimiter is a [RateLimiter] that applies a different rate limiter to each tier of clients,
+// so that for example clients on a free plan get 60 requests per minute while clients on a paid
+// plan get 6000. The tier of each request is looked up with a user-supplied [TierFunc].
+//
+// TieredLimiter implements [AdvancedRateLimiter] and [RateLimiterContext] by delegating to the
+// rate limiter of the request's tier, so Limit and Remaining reflect the limits of that tier.
+//
+// Example usage:
+//
+//	limiter := NewTieredLimiter(planOf, map[string]RateLimiter{
+//		"free": NewTokenBucketLimiter(60, 1, WithKeyFunc(KeyByHeader("X-API-Key"))),
+//		"pro":  NewTokenBucketLimiter(6000, 100, WithKeyFunc(KeyByHeader("X-API-Key"))),
+//	})
+type TieredLimiter struct {
+	tierFunc TierFunc
+	tiers    map[string]RateLimiter
+}
+
+// NewTieredLimiter creates a new [TieredLimiter] that resolves the tier of each request with
+// tierFunc and checks it against the rate limiter of that tier in tiers.
+func NewTieredLimiter(tierFunc TierFunc, tiers map[string]RateLimiter) *TieredLimiter {
+	l := &TieredLimiter{
+		tierFunc: tierFunc,
+		tiers:    make(map[string]RateLimiter, len(tiers)),
+	}
+	for tier, rateLimiter := range tiers {
+		l.tiers[tier] = rateLimiter
+	}
+	return l
+}
+
+// IsAllowed checks the request against the rate limiter of its tier. An error is returned if
+// the tier cannot be resolved or has no rate limiter.
+func (l *TieredLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiter of the tier.
+func (l *TieredLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	rateLimiter, err := l.rateLimiter(r)
+	if err != nil {
+		return false, err
+	}
+	return isAllowed(ctx, rateLimiter, r)
+}
+
+// GetRateLimitData returns the rate limit data reported by the rate limiter of the request's
+// tier. The zero RateLimitData is returned if the tier cannot be resolved, or if its rate limiter
+// does not implement [AdvancedRateLimiter].
+func (l *TieredLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	rateLimiter, err := l.rateLimiter(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	if advanced, ok := rateLimiter.(AdvancedRateLimiter); ok {
+		return advanced.GetRateLimitData(r)
+	}
+	return RateLimitData{}
+}
+
+// Close closes the rate limiters of the tiers implementing [Closer]. It implements Closer.
+func (l *TieredLimiter) Close(ctx context.Context) error {
+	return closeAll(ctx, slices.Collect(maps.Values(l.tiers))...)
+}
+
+// key returns the key identifying the client making the request, as reported by the rate
+// limiter of the request's tier.
+func (l *TieredLimiter) key(r *http.Request) (string, error) {
+	rateLimiter, err := l.rateLimiter(r)
+	if err != nil {
+		return "", err
+	}
+	if k, ok := rateLimiter.(keyer); ok {
+		return k.key(r)
+	}
+	return "", ErrNoKey
+}
+
+// rateLimiter returns the rate limiter of the request's tier.
+func (l *TieredLimiter) rateLimiter(r *http.Request) (RateLimiter, error) {
+	tier, err := l.tierFunc(r)
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, ok := l.tiers[tier]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTier, tier)
+	}
+	return rateLimiter, nil
+}
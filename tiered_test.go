@@ -0,0 +1,56 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func planFromHeader(r *http.Request) (string, error) {
+	return r.Header.Get("X-Plan"), nil
+}
+
+func newPlanRequest(plan string) *http.Request {
+	req := newRequestFrom("192.0.2.1:1234")
+	req.Header.Set("X-Plan", plan)
+	return req
+}
+
+// Test each tier enforces its own limit and reports it
+func TestTieredLimiterAppliesTierLimits(t *testing.T) {
+	limiter := NewTieredLimiter(planFromHeader, map[string]RateLimiter{
+		"free": NewFixedWindowLimiter(1, time.Hour),
+		"pro":  NewFixedWindowLimiter(10, time.Hour),
+	})
+
+	limiter.IsAllowed(newPlanRequest("free"))
+	if isAllowed, _ := limiter.IsAllowed(newPlanRequest("free")); isAllowed {
+		t.Errorf("expected second free request to be denied")
+	}
+	if isAllowed, _ := limiter.IsAllowed(newPlanRequest("pro")); !isAllowed {
+		t.Errorf("expected pro request to be allowed")
+	}
+	data := limiter.GetRateLimitData(newPlanRequest("pro"))
+	if data.Limit != 10 || data.Remaining != 9 {
+		t.Errorf("expected {10 9}; got %+v", data)
+	}
+}
+
+// Test unknown tiers and resolver failures are reported as errors
+func TestTieredLimiterErrors(t *testing.T) {
+	limiter := NewTieredLimiter(planFromHeader, map[string]RateLimiter{
+		"free": NewFixedWindowLimiter(1, time.Hour),
+	})
+	if _, err := limiter.IsAllowed(newPlanRequest("gold")); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("expected ErrUnknownTier; got %v", err)
+	}
+
+	resolveErr := errors.New("account service unavailable")
+	limiter = NewTieredLimiter(func(r *http.Request) (string, error) {
+		return "", resolveErr
+	}, nil)
+	if _, err := limiter.IsAllowed(newPlanRequest("free")); err != resolveErr {
+		t.Errorf("expected resolver error; got %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// tokenBucketPrefix prefixes the store keys of the buckets of a [TokenBucketLimiter].
+const tokenBucketPrefix = "token_bucket:"
+
+// TokenBucketLimiter is a [RateLimiter] and [AdvancedRateLimiter] implementation of the
+// token bucket algorithm. Every client is assigned its own bucket, identified by the client's
+// key (its IP address by default), which holds up to capacity tokens and is refilled at a
+// constant rate.
+//
+// Each request consumes a single token from the bucket of its client. If the bucket is empty,
+// the request is denied until enough time has passed for a new token to be added. Because the
+// bucket can hold up to capacity tokens, clients are allowed short bursts of traffic on top of
+// the sustained refill rate.
+//
+// Buckets are kept in the limiter's [Store] and expire once they would have been refilled
+// completely, so idle clients do not occupy any storage. New buckets can start with a reduced
+// capacity that ramps up to the full capacity with [WithWarmUp].
+//
+// A TokenBucketLimiter is safe for concurrent use by multiple goroutines.
+//
+// Example usage: http.Handle("/resource", Middleware(NewTokenBucketLimiter(10, 1), myHandler))
+type TokenBucketLimiter struct {
+	capacity   float64
+	refillRate float64
+
+	keyFunc   KeyFunc
+	store     Store
+	costFunc  CostFunc
+	policy    string
+	now       func() time.Time
+	clock     Clock
+	topDenied *TopKeys
+
+	warmUp     time.Duration
+	warmUpFrom float64
+}
+
+// tokenBucket holds the state of a single client's bucket. created is the time the bucket was
+// created while it is warming up, and zero afterwards.
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	created time.Time
+}
+
+// NewTokenBucketLimiter creates a new [TokenBucketLimiter] where each bucket holds up to
+// capacity tokens and is refilled at refillRate tokens per second. Buckets start full.
+//
+// It panics if capacity or refillRate is not positive.
+func NewTokenBucketLimiter(capacity int, refillRate float64, opts ...LimiterOption) *TokenBucketLimiter {
+	if capacity <= 0 {
+		panic("cerberus: token bucket capacity must be positive")
+	}
+	if refillRate <= 0 {
+		panic("cerberus: token bucket refill rate must be positive")
+	}
+	options := newLimiterOptions(opts)
+	return &TokenBucketLimiter{
+		capacity:   float64(capacity),
+		refillRate: refillRate,
+		keyFunc:    options.keyFunc,
+		store:      options.store,
+		costFunc:   options.costFunc,
+		policy:     options.policy,
+		now:        options.clock.Now,
+		clock:      options.clock,
+		topDenied:  options.topDenied,
+		warmUp:     options.warmUp,
+		warmUpFrom: options.warmUpFrom,
+	}
+}
+
+// IsAllowed consumes as many tokens as the request costs from the bucket of the client making it.
+// It returns true if enough tokens were available, false otherwise. An error is returned if no key
+// can be derived from the request or the store fails.
+func (l *TokenBucketLimiter) IsAllowed(r *http.Request) (bool, error) {
+	return l.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but uses ctx for the operations on the store.
+func (l *TokenBucketLimiter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	return l.take(ctx, r, false)
+}
+
+// Peek reports whether the request would be allowed, without counting it. An error is returned if
+// no key can be derived from the request or the store fails.
+func (l *TokenBucketLimiter) Peek(ctx context.Context, r *http.Request) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	value, err := l.store.Get(ctx, tokenBucketPrefix+key)
+	if err != nil {
+		return false, err
+	}
+	bucket, err := l.refill(key, value)
+	if err != nil {
+		return false, err
+	}
+	return bucket.tokens >= float64(requestCost(l.costFunc, r)), nil
+}
+
+// Commit counts the request, even if that exceeds the limit. An error is returned if no key can be
+// derived from the request or the store fails.
+func (l *TokenBucketLimiter) Commit(ctx context.Context, r *http.Request) error {
+	_, err := l.take(ctx, r, true)
+	return err
+}
+
+// RefundRequest gives back the budget consumed by a request that was allowed. An error is returned
+// if no key can be derived from the request or the store fails.
+func (l *TokenBucketLimiter) RefundRequest(ctx context.Context, r *http.Request) error {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return err
+	}
+	return l.refund(ctx, key, float64(requestCost(l.costFunc, r)))
+}
+
+// Refund gives n tokens back to the bucket of the client identified by key, such as the key
+// reported by [KeyOf], for handlers that find out after the fact that a request should not have
+// cost that much, for example because it was served from a cache. The bucket is not filled above
+// its capacity. An error is returned if the store fails.
+//
+// Example usage:
+//
+//	if cached {
+//		key, _ := cerberus.KeyOf(limiter, r)
+//		limiter.Refund(r.Context(), key, 1)
+//	}
+func (l *TokenBucketLimiter) Refund(ctx context.Context, key string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	return l.refund(ctx, key, float64(n))
+}
+
+// refund gives n tokens back to the bucket of key, if it has one. A bucket refilled completely
+// and done warming up is deleted, since it is the bucket of a new client.
+func (l *TokenBucketLimiter) refund(ctx context.Context, key string, n float64) error {
+	return modifyOrDelete(ctx, l.store, tokenBucketPrefix+key, func(value []byte) ([]byte, time.Duration, bool, error) {
+		if value == nil {
+			return nil, 0, false, nil
+		}
+		bucket, err := l.refill(key, value)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		bucket.tokens = math.Min(l.capacityOf(bucket), bucket.tokens+n)
+		full := bucket.tokens >= l.capacity && bucket.created.IsZero()
+		return bucket.encode(), l.ttl(bucket), full, nil
+	})
+}
+
+// take counts the request if it is allowed, or regardless if force is true, and reports whether
+// it was allowed.
+func (l *TokenBucketLimiter) take(ctx context.Context, r *http.Request, force bool) (bool, error) {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return false, err
+	}
+	cost := float64(requestCost(l.costFunc, r))
+	var isAllowed bool
+	err = modify(ctx, l.store, tokenBucketPrefix+key, func(value []byte) ([]byte, time.Duration, error) {
+		bucket, err := l.refill(key, value)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Requests costing nothing are not written, so that they do not keep full buckets alive.
+		if isAllowed = bucket.tokens >= cost; cost == 0 || !isAllowed && !force {
+			return nil, 0, nil
+		}
+		bucket.tokens -= cost
+		return bucket.encode(), l.ttl(bucket), nil
+	})
+	if !isAllowed && !force && err == nil {
+		countDenied(l.topDenied, key)
+	}
+	return isAllowed, err
+}
+
+// GetRateLimitData returns the current state of the bucket of the client making the request. Limit
+// is the capacity of the bucket, reduced while it warms up, Remaining is the number of whole tokens left in it, and RetryAfter
+// is the time until enough tokens for the request are available if there are too few. The zero
+// RateLimitData is returned if no key can be derived from the request or the store fails.
+func (l *TokenBucketLimiter) GetRateLimitData(r *http.Request) RateLimitData {
+	key, err := l.keyFunc(r)
+	if err != nil {
+		return RateLimitData{}
+	}
+	data, _ := l.rateLimitData(r.Context(), key, requestCost(l.costFunc, r))
+	return data
+}
+
+// Rate returns the sustained rate of the limiter, the number of tokens added to each bucket per
+// second.
+func (l *TokenBucketLimiter) Rate() float64 {
+	return l.refillRate
+}
+
+// Burst returns the burst of the limiter, the number of tokens each bucket holds when full.
+func (l *TokenBucketLimiter) Burst() int {
+	return int(l.capacity)
+}
+
+// Usage returns the current state of the bucket of the client identified by key, as reported by
+// GetRateLimitData for a request of cost 1. An error is returned if the store fails.
+func (l *TokenBucketLimiter) Usage(ctx context.Context, key string) (RateLimitData, error) {
+	return l.rateLimitData(ctx, key, 1)
+}
+
+// Reset restores the full budget of the client identified by key, lifting any block. An error is
+// returned if the store fails.
+func (l *TokenBucketLimiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, tokenBucketPrefix+key)
+}
+
+// Block empties the bucket of the client identified by key, and puts it into debt for d worth of
+// refills, so that its requests are denied for d. An error is returned if the store fails.
+func (l *TokenBucketLimiter) Block(ctx context.Context, key string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	bucket := tokenBucket{tokens: -d.Seconds() * l.refillRate, last: l.now()}
+	return l.store.Set(ctx, tokenBucketPrefix+key, bucket.encode(), l.ttl(bucket))
+}
+
+// Keys returns the keys of the clients the limiter is tracking, in no particular order. Clients
+// whose state has expired are back to a full budget and are not listed. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner].
+func (l *TokenBucketLimiter) Keys(ctx context.Context) ([]string, error) {
+	return scanClientKeys(ctx, l.store, tokenBucketPrefix)
+}
+
+// TopDenied returns the keys of the clients denied the most, if enabled with [WithTopDenied].
+func (l *TokenBucketLimiter) TopDenied() ([]KeyCount, error) {
+	return topDenied(l.topDenied)
+}
+
+// Close stops the janitor of the default store of the limiter, if no store was set with
+// [WithStore]. It implements [Closer].
+func (l *TokenBucketLimiter) Close(ctx context.Context) error {
+	return closeOwnedStore(ctx, l.store)
+}
+
+// rateLimitData returns the rate limit data of the client identified by key for a request of the
+// given cost.
+func (l *TokenBucketLimiter) rateLimitData(ctx context.Context, key string, cost int) (RateLimitData, error) {
+	value, err := l.store.Get(ctx, tokenBucketPrefix+key)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	bucket, err := l.refill(key, value)
+	if err != nil {
+		return RateLimitData{}, err
+	}
+	capacity := l.capacityOf(bucket)
+	data := RateLimitData{
+		Limit:     int(capacity),
+		Remaining: int(math.Floor(math.Max(bucket.tokens, 0))),
+		Window:    time.Duration(capacity / l.refillRate * float64(time.Second)),
+		Policy:    l.policy,
+		Rate:      l.refillRate,
+		Burst:     int(capacity),
+	}
+	data.ResetAt = bucket.last.Add(time.Duration((capacity - bucket.tokens) / l.refillRate * float64(time.Second)))
+	if cost := float64(cost); bucket.tokens < cost && cost <= capacity {
+		data.RetryAfter = time.Duration((cost - bucket.tokens) / l.refillRate * float64(time.Second))
+	}
+	return data, nil
+}
+
+// refill decodes the bucket stored under key and adds the tokens accrued since it was last
+// refilled. A nil value yields a full bucket, or a bucket starting to warm up with [WithWarmUp].
+func (l *TokenBucketLimiter) refill(key string, value []byte) (tokenBucket, error) {
+	now := l.now()
+	if value == nil {
+		if l.warmUp > 0 {
+			return tokenBucket{tokens: l.capacity * l.warmUpFrom, last: now, created: now}, nil
+		}
+		return tokenBucket{tokens: l.capacity, last: now}, nil
+	}
+	fields, ok := decodeUint64s(value)
+	if !ok || len(fields) != 2 && len(fields) != 3 {
+		return tokenBucket{}, fmt.Errorf("%w: %q is not a token bucket", ErrMalformedValue, key)
+	}
+	bucket := tokenBucket{
+		tokens: math.Float64frombits(fields[0]),
+		last:   time.Unix(0, int64(fields[1])),
+	}
+	if len(fields) == 3 {
+		bucket.created = time.Unix(0, int64(fields[2]))
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.last = now
+		bucket.tokens = math.Min(l.capacityOf(bucket), bucket.tokens+elapsed.Seconds()*l.refillRate)
+	}
+	if now.Sub(bucket.created) >= l.warmUp {
+		bucket.created = time.Time{}
+	}
+	return bucket, nil
+}
+
+// capacityOf returns the capacity of bucket as of its last refill, which is reduced while it warms
+// up.
+func (l *TokenBucketLimiter) capacityOf(bucket tokenBucket) float64 {
+	return l.capacity * warmUpLimit(l.warmUp, l.warmUpFrom, bucket.created, bucket.last)
+}
+
+// ttl returns the time until bucket is full again and done warming up, after which keeping it in
+// the store is pointless. It is at least the time a token takes to refill, since a TTL of zero
+// would keep the bucket forever.
+func (l *TokenBucketLimiter) ttl(bucket tokenBucket) time.Duration {
+	ttl := time.Duration((l.capacity - bucket.tokens) / l.refillRate * float64(time.Second))
+	if !bucket.created.IsZero() {
+		ttl = max(ttl, bucket.created.Add(l.warmUp).Sub(bucket.last))
+	}
+	return max(ttl, time.Duration(float64(time.Second)/l.refillRate))
+}
+
+// encode serializes the bucket for storage. The creation time is only stored while the bucket
+// warms up.
+func (b tokenBucket) encode() []byte {
+	if !b.created.IsZero() {
+		return encodeUint64s(math.Float64bits(b.tokens), uint64(b.last.UnixNano()), uint64(b.created.UnixNano()))
+	}
+	return encodeUint64s(math.Float64bits(b.tokens), uint64(b.last.UnixNano()))
+}
+
+// key returns the key identifying the client making the request.
+func (l *TokenBucketLimiter) key(r *http.Request) (string, error) {
+	return l.keyFunc(r)
+}
+
+// timeSource returns the clock the limiter follows.
+func (l *TokenBucketLimiter) timeSource() Clock {
+	return l.clock
+}
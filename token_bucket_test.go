@@ -0,0 +1,174 @@
+package cerberus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Manually advanced clock for deterministic tests of time-based limiters
+type fakeClock struct {
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newRequestFrom(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+// benchmarkRateLimiter measures checking requests of a single client against rateLimiter, and
+// reading its rate limit data, with clock advanced by step after each request.
+func benchmarkRateLimiter(b *testing.B, rateLimiter AdvancedRateLimiter, clock *fakeClock, step time.Duration) {
+	req := newRequestFrom("192.0.2.1:1234")
+	b.Run("IsAllowed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rateLimiter.IsAllowed(req)
+			clock.Advance(step)
+		}
+	})
+	b.Run("GetRateLimitData", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rateLimiter.GetRateLimitData(req)
+		}
+	})
+	b.Run("Middleware", func(b *testing.B) {
+		handler := New(rateLimiter)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		w := discardResponseWriter{header: make(http.Header)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			handler.ServeHTTP(w, req)
+			clock.Advance(step)
+		}
+	})
+}
+
+// Test the bucket allows a burst up to its capacity
+func TestTokenBucketLimiterAllowsBurstUpToCapacity(t *testing.T) {
+	limiter := NewTokenBucketLimiter(3, 1)
+	limiter.now = newFakeClock().Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	for i := 0; i < 3; i++ {
+		if isAllowed, err := limiter.IsAllowed(req); err != nil || !isAllowed {
+			t.Fatalf("expected request %d to be allowed; got %v, %v", i+1, isAllowed, err)
+		}
+	}
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Errorf("expected request exceeding capacity to be denied")
+	}
+}
+
+// Test the bucket refills over time
+func TestTokenBucketLimiterRefills(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(1, 2)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+		t.Fatalf("expected request on empty bucket to be denied")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if isAllowed, _ := limiter.IsAllowed(req); !isAllowed {
+		t.Errorf("expected request to be allowed after refill")
+	}
+}
+
+// Test clients get independent buckets
+func TestTokenBucketLimiterSeparatesClients(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 1)
+	limiter.now = newFakeClock().Now
+
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	if isAllowed, _ := limiter.IsAllowed(newRequestFrom("192.0.2.2:1234")); !isAllowed {
+		t.Errorf("expected request from another client to be allowed")
+	}
+}
+
+// Test rate limit data reflects the bucket state
+func TestTokenBucketLimiterGetRateLimitData(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(2, 4)
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	limiter.IsAllowed(req)
+	data := limiter.GetRateLimitData(req)
+	if data.Limit != 2 || data.Remaining != 1 || data.RetryAfter != 0 {
+		t.Errorf("expected {2 1 0}; got %+v", data)
+	}
+	limiter.IsAllowed(req)
+	data = limiter.GetRateLimitData(req)
+	if data.Remaining != 0 || data.RetryAfter != 250*time.Millisecond {
+		t.Errorf("expected {2 0 250ms}; got %+v", data)
+	}
+	if !data.ResetAt.Equal(clock.Now().Add(500 * time.Millisecond)) {
+		t.Errorf("expected the bucket to be full in 500ms; got %v", data.ResetAt)
+	}
+}
+
+// Test the sustained rate and the burst are reported separately
+func TestTokenBucketLimiterRateAndBurst(t *testing.T) {
+	limiter := NewTokenBucketLimiter(20, 5)
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if rate, burst := limiter.Rate(), limiter.Burst(); rate != 5 || burst != 20 {
+		t.Errorf("expected rate 5 and burst 20; got %v and %v", rate, burst)
+	}
+	if data := limiter.GetRateLimitData(req); data.Rate != 5 || data.Burst != 20 {
+		t.Errorf("expected rate 5 and burst 20; got %+v", data)
+	}
+}
+
+// Test usage is reported by key, and keys require a scanning store
+func TestTokenBucketLimiterUsage(t *testing.T) {
+	limiter := NewTokenBucketLimiter(5, 1)
+	limiter.now = newFakeClock().Now
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+
+	data, err := limiter.Usage(context.Background(), "192.0.2.1")
+	if err != nil || data.Limit != 5 || data.Remaining != 4 {
+		t.Errorf("expected {5 4 0s}; got %+v, %v", data, err)
+	}
+
+	store := NewMemoryStore()
+	store.Set(context.Background(), "webhook:42", []byte("1"), time.Minute)
+	limiter = NewTokenBucketLimiter(5, 1, WithStore(store))
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	keys, err := limiter.Keys(context.Background())
+	if err != nil || len(keys) != 1 || keys[0] != "192.0.2.1" {
+		t.Errorf("expected [192.0.2.1] in a shared store; got %v, %v", keys, err)
+	}
+
+	limiter = NewTokenBucketLimiter(5, 1, WithStore(struct{ Store }{NewMemoryStore()}))
+	if _, err := limiter.Keys(context.Background()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported; got %v", err)
+	}
+}
+
+// Benchmark a client consuming tokens as fast as they are refilled
+func BenchmarkTokenBucketLimiter(b *testing.B) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(100, 100)
+	limiter.now = clock.Now
+	benchmarkRateLimiter(b, limiter, clock, 10*time.Millisecond)
+}
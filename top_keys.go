@@ -0,0 +1,229 @@
+package cerberus
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"sync"
+)
+
+// topKeysDepth is the number of rows of the count-min sketch of a [TopKeys]. The probability that
+// the count of a key is overestimated by more than the error bound decreases exponentially with it.
+const topKeysDepth = 4
+
+// topKeysMinWidth is the minimum number of counters per row of the count-min sketch of a
+// [TopKeys]. The count of a key is overestimated by at most a fraction of about e/width of the
+// total count.
+const topKeysMinWidth = 1024
+
+// KeyCount is the number of times a key was counted, such as the number of denials of the client
+// it identifies.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// TopKeys tracks the k keys counted the most in a stream of keys, in memory bounded by k rather
+// than by the number of distinct keys, so that the heaviest clients can be identified among
+// millions without keeping a counter for each of them.
+//
+// Keys are counted in a count-min sketch, which never underestimates counts and most likely
+// overestimates them by a small fraction of the total count at most, and the k keys with the
+// highest counts are kept in a min-heap. Counts are approximate, but the heaviest keys are reliably
+// found.
+//
+// A TopKeys is safe for concurrent use by multiple goroutines.
+type TopKeys struct {
+	k    int
+	seed maphash.Seed
+
+	mu     sync.Mutex
+	sketch [topKeysDepth][]int64
+	top    topKeysHeap
+	index  map[string]*topKeysEntry
+	total  int64
+}
+
+// NewTopKeys creates a new [TopKeys] tracking the k keys counted the most.
+//
+// It panics if k is not positive.
+func NewTopKeys(k int) *TopKeys {
+	if k <= 0 {
+		panic("cerberus: the number of top keys must be positive")
+	}
+	t := &TopKeys{k: k, seed: maphash.MakeSeed()}
+	t.init()
+	return t
+}
+
+// init allocates empty counters.
+func (t *TopKeys) init() {
+	width := max(topKeysMinWidth, 64*t.k)
+	for i := range t.sketch {
+		t.sketch[i] = make([]int64, width)
+	}
+	t.top = make(topKeysHeap, 0, t.k)
+	t.index = make(map[string]*topKeysEntry, t.k)
+	t.total = 0
+}
+
+// Add counts an occurrence of key.
+func (t *TopKeys) Add(key string) {
+	// Rows are indexed by combining two halves of a single hash, which is as accurate as using
+	// independent hash functions.
+	h := maphash.String(t.seed, key)
+	h1, h2 := h&0xffffffff, h>>32|1
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total++
+
+	// Conservative update: only the counters at the minimum are incremented, which reduces the
+	// overestimation caused by other keys sharing counters.
+	var cells [topKeysDepth]*int64
+	count := int64(-1)
+	for i := range t.sketch {
+		row := t.sketch[i]
+		cells[i] = &row[(h1+uint64(i)*h2)%uint64(len(row))]
+		if count < 0 || *cells[i] < count {
+			count = *cells[i]
+		}
+	}
+	count++
+	for _, cell := range cells {
+		*cell = max(*cell, count)
+	}
+
+	if entry, ok := t.index[key]; ok {
+		entry.Count = count
+		heap.Fix(&t.top, entry.index)
+		return
+	}
+	if len(t.top) < t.k {
+		entry := &topKeysEntry{KeyCount: KeyCount{Key: key, Count: count}}
+		t.index[key] = entry
+		heap.Push(&t.top, entry)
+		return
+	}
+	if lightest := t.top[0]; count > lightest.Count {
+		delete(t.index, lightest.Key)
+		lightest.Key, lightest.Count = key, count
+		t.index[key] = lightest
+		heap.Fix(&t.top, 0)
+	}
+}
+
+// Top returns the keys counted the most, at most k, from the most counted, with ties broken by
+// key.
+func (t *TopKeys) Top() []KeyCount {
+	t.mu.Lock()
+	top := make([]KeyCount, 0, len(t.top))
+	for _, entry := range t.top {
+		top = append(top, entry.KeyCount)
+	}
+	t.mu.Unlock()
+	slices.SortFunc(top, func(a, b KeyCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return top
+}
+
+// Total returns the number of occurrences of all keys counted.
+func (t *TopKeys) Total() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// Reset forgets all the keys counted, such as at the end of an incident.
+func (t *TopKeys) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+}
+
+// topKeysEntry is a key tracked by a [TopKeys], with its position in the heap.
+type topKeysEntry struct {
+	KeyCount
+	index int
+}
+
+// topKeysHeap is a min-heap of the entries of a [TopKeys] ordered by count, implementing
+// [heap.Interface].
+type topKeysHeap []*topKeysEntry
+
+func (h topKeysHeap) Len() int           { return len(h) }
+func (h topKeysHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h topKeysHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topKeysHeap) Push(x any) {
+	entry := x.(*topKeysEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *topKeysHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// TopDeniedLimiter is an extended version of the [RateLimiter] interface for rate limiters that
+// track the keys of the clients they deny the most, so that operators can identify abusive
+// clients without exporting every key. All built-in limiters implement it, and track denials when
+// created with [WithTopDenied].
+type TopDeniedLimiter interface {
+	RateLimiter
+	// TopDenied returns the keys of the clients denied the most, from the most denied. It returns
+	// an error wrapping [errors.ErrUnsupported] if the rate limiter does not track denials.
+	TopDenied() ([]KeyCount, error)
+}
+
+// WithTopDenied makes the limiter track the k keys of the clients it denies the most with a
+// [TopKeys], reported by its TopDenied method. Denials are counted by the instance of the limiter
+// that denies them, so each instance of a service reports its own.
+//
+// It panics if k is not positive.
+//
+// Example usage: NewTokenBucketLimiter(10, 1, WithTopDenied(20))
+func WithTopDenied(k int) LimiterOption {
+	if k <= 0 {
+		panic("cerberus: the number of top denied keys must be positive")
+	}
+	return func(o *limiterOptions) {
+		o.topDenied = NewTopKeys(k)
+	}
+}
+
+// withTopKeys makes the limiter count its denials in t, so that limiters replacing each other, as
+// in a [DynamicLimiter], keep counting in the same [TopKeys].
+func withTopKeys(t *TopKeys) LimiterOption {
+	return func(o *limiterOptions) {
+		o.topDenied = t
+	}
+}
+
+// topDenied returns the top keys of t, or an error wrapping [errors.ErrUnsupported] if t is nil
+// because denials are not tracked.
+func topDenied(t *TopKeys) ([]KeyCount, error) {
+	if t == nil {
+		return nil, fmt.Errorf("cerberus: denials are not tracked without WithTopDenied: %w", errors.ErrUnsupported)
+	}
+	return t.Top(), nil
+}
+
+// countDenied counts a denial of the client identified by key in t, unless t is nil because
+// denials are not tracked.
+func countDenied(t *TopKeys, key string) {
+	if t != nil {
+		t.Add(key)
+	}
+}
@@ -0,0 +1,121 @@
+package cerberus
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Test the heaviest keys are found among many light keys, with their exact counts
+func TestTopKeys(t *testing.T) {
+	top := NewTopKeys(3)
+	for i := range 10000 {
+		top.Add(fmt.Sprintf("light-%d", i))
+		if i%10 == 0 {
+			top.Add("heavy-1")
+		}
+		if i%20 == 0 {
+			top.Add("heavy-2")
+		}
+		if i%50 == 0 {
+			top.Add("heavy-3")
+		}
+	}
+
+	want := []KeyCount{{"heavy-1", 1000}, {"heavy-2", 500}, {"heavy-3", 200}}
+	if got := top.Top(); !slices.Equal(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	if total := top.Total(); total != 11700 {
+		t.Errorf("expected a total of 11700; got %d", total)
+	}
+}
+
+// Test keys are reported from the most counted, with ties broken by key
+func TestTopKeysOrder(t *testing.T) {
+	top := NewTopKeys(5)
+	for _, key := range []string{"b", "a", "c", "c", "b", "c"} {
+		top.Add(key)
+	}
+
+	want := []KeyCount{{"c", 3}, {"b", 2}, {"a", 1}}
+	if got := top.Top(); !slices.Equal(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+// Test Reset forgets all keys
+func TestTopKeysReset(t *testing.T) {
+	top := NewTopKeys(1)
+	top.Add("key")
+
+	top.Reset()
+	top.Add("other")
+
+	want := []KeyCount{{"other", 1}}
+	if got := top.Top(); !slices.Equal(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+// Test NewTopKeys and WithTopDenied panic on a non-positive number of keys
+func TestTopKeysPanics(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"NewTopKeys":    func() { NewTopKeys(0) },
+		"WithTopDenied": func() { WithTopDenied(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+// Test built-in limiters report the keys they deny the most with WithTopDenied
+func TestLimitersTopDenied(t *testing.T) {
+	dynamic, err := NewDynamicLimiter(Config{Algorithm: AlgorithmSlidingWindow, Limit: 1, Window: time.Minute}, WithTopDenied(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiters := map[string]TopDeniedLimiter{
+		"token bucket":   NewTokenBucketLimiter(1, 1.0/60, WithTopDenied(2)),
+		"leaky bucket":   NewLeakyBucketLimiter(1, 1.0/60, WithTopDenied(2)),
+		"fixed window":   NewFixedWindowLimiter(1, time.Minute, WithTopDenied(2)),
+		"sliding window": NewSlidingWindowLimiter(1, time.Minute, WithTopDenied(2)),
+		"GCRA":           NewGCRALimiter(time.Minute, 0, WithTopDenied(2)),
+		"quota":          NewQuotaLimiter(1, Daily(time.UTC), WithTopDenied(2)),
+		"adaptive":       NewAdaptiveLimiter(1, 1, time.Minute, func() float64 { return 0 }, 1, WithTopDenied(2)),
+		"dynamic":        dynamic,
+	}
+	for name, limiter := range limiters {
+		for addr, n := range map[string]int{"192.0.2.1:1234": 4, "192.0.2.2:1234": 3, "192.0.2.3:1234": 1} {
+			for range n {
+				limiter.IsAllowed(newRequestFrom(addr))
+			}
+		}
+
+		top, err := limiter.TopDenied()
+
+		want := []KeyCount{{"192.0.2.1", 3}, {"192.0.2.2", 2}}
+		if err != nil || !slices.Equal(top, want) {
+			t.Errorf("%s: expected %v; got %v, %v", name, want, top, err)
+		}
+	}
+}
+
+// Test limiters report ErrUnsupported when denials are not tracked
+func TestLimitersTopDeniedDisabled(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 1)
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+	limiter.IsAllowed(newRequestFrom("192.0.2.1:1234"))
+
+	if _, err := limiter.TopDenied(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported; got %v", err)
+	}
+}
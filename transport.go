@@ -0,0 +1,125 @@
+package cerberus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by the [http.RoundTripper] created with [Transport] when the rate
+// limiter denies a request, or the server asked the client to wait. If the rate limiter implements
+// [AdvancedRateLimiter], or the server said how long to wait, the error also reports when the
+// request may be retried.
+var ErrRateLimited = errors.New("cerberus: rate limited")
+
+// TransportOption configures the [http.RoundTripper] created with [Transport].
+type TransportOption func(*transport)
+
+// WithTransportWait makes the transport delay requests that exceed the rate limit until the rate
+// limiter allows them, instead of failing them right away, as long as waiting does not exceed
+// maxWait. Requests are checked again once the delay reported by an [AdvancedRateLimiter] has
+// elapsed, or at short intervals otherwise, as in [WithWaitMode]. A maxWait of zero or less turns
+// waiting off, which is the default.
+func WithTransportWait(maxWait time.Duration) TransportOption {
+	return func(t *transport) {
+		t.maxWait = maxWait
+	}
+}
+
+// transport is the [http.RoundTripper] created with [Transport].
+type transport struct {
+	rateLimiter  RateLimiter
+	base         http.RoundTripper
+	maxWait      time.Duration
+	serverLimits bool
+	clock        Clock
+
+	mu           sync.Mutex
+	blockedUntil map[string]time.Time
+}
+
+// Transport returns an [http.RoundTripper] that applies rate limiting with the provided
+// [RateLimiter] to outgoing requests before sending them with base, so that HTTP clients can
+// respect the quotas of third-party APIs with the same limiters as servers. If base is nil,
+// [http.DefaultTransport] is used.
+//
+// Behavior:
+//   - If the request is allowed by the rate limiter, it is sent with base.
+//   - If the request exceeds the rate limit, the round trip fails with an error wrapping
+//     [ErrRateLimited], unless waiting is enabled with [WithTransportWait].
+//   - If the rate limiter encounters an error, the round trip fails with that error.
+//   - If a response tells the client to slow down with rate limit headers, later requests to the
+//     same host are throttled in the same way until the time the server asked for has elapsed.
+//     See [WithServerRateLimits].
+//
+// The rate limiter may be nil, in which case requests are only throttled as asked by servers.
+//
+// Outgoing requests have no remote address, so with [KeyByIP], the default of the built-in
+// limiters, all requests share a single limit. [KeyByHost] limits each host separately.
+//
+// Example usage:
+//
+//	limiter := NewTokenBucketLimiter(10, 5, WithKeyFunc(KeyByHost))
+//	client := &http.Client{Transport: Transport(limiter, nil, WithTransportWait(time.Second))}
+func Transport(rateLimiter RateLimiter, base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{
+		rateLimiter:  rateLimiter,
+		base:         base,
+		serverLimits: true,
+		blockedUntil: make(map[string]time.Time),
+		clock:        SystemClock,
+	}
+	if rateLimiter != nil {
+		t.clock = clockOf(rateLimiter)
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip checks the request against the limits set by the server and the rate limiter, waiting
+// for it to be allowed if configured to, and sends it with the base transport if it is allowed.
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	allowed, err := true, t.waitServer(r)
+	if err == nil && t.rateLimiter != nil {
+		allowed, err = isAllowed(r.Context(), t.rateLimiter, r)
+		if !allowed && err == nil && t.maxWait > 0 {
+			allowed, err = waitAllowed(r, t.rateLimiter, t.maxWait)
+			if !allowed && err == nil {
+				err = r.Context().Err()
+			}
+		}
+		if err == nil && !allowed {
+			err = t.rateLimited(r)
+		}
+	}
+	if err != nil {
+		// RoundTrip must close the body of the request, even on errors.
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err == nil && t.serverLimits {
+		t.observe(r, resp)
+	}
+	return resp, err
+}
+
+// rateLimited returns the error for a request denied by the rate limiter, reporting when it may be
+// retried if the rate limiter implements [AdvancedRateLimiter].
+func (t *transport) rateLimited(r *http.Request) error {
+	if advanced, ok := t.rateLimiter.(AdvancedRateLimiter); ok {
+		if retryAfter := advanced.GetRateLimitData(r).RetryAfter; retryAfter > 0 {
+			return fmt.Errorf("%w: retry after %v", ErrRateLimited, retryAfter)
+		}
+	}
+	return ErrRateLimited
+}
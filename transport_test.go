@@ -0,0 +1,94 @@
+package cerberus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTransportServer returns a test server responding with an HTTP 200 (OK) and counting the
+// requests it receives.
+func newTransportServer(t *testing.T, received *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received++
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test requests exceeding the rate limit fail with ErrRateLimited without being sent
+func TestTransportRejects(t *testing.T) {
+	received := 0
+	server := newTransportServer(t, &received)
+	limiter := NewFixedWindowLimiter(2, time.Minute, WithKeyFunc(KeyByHost))
+	client := &http.Client{Transport: Transport(limiter, nil)}
+
+	for i := range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to be sent; got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+	_, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited; got %v", err)
+	}
+	if !strings.Contains(err.Error(), "retry after") {
+		t.Errorf("expected the error to report when to retry; got %v", err)
+	}
+	if received != 2 {
+		t.Errorf("expected 2 requests to be received; got %v", received)
+	}
+}
+
+// Test requests exceeding the rate limit are delayed in wait mode
+func TestTransportWaits(t *testing.T) {
+	received := 0
+	server := newTransportServer(t, &received)
+	limiter := NewTokenBucketLimiter(1, 20, WithKeyFunc(KeyByHost))
+	client := &http.Client{Transport: Transport(limiter, nil, WithTransportWait(time.Second))}
+
+	start := time.Now()
+	for i := range 3 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected request %d to be sent; got %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected requests to be delayed by about 100ms; got %v", elapsed)
+	}
+	if received != 3 {
+		t.Errorf("expected 3 requests to be received; got %v", received)
+	}
+}
+
+// Test rate limiter errors fail the round trip
+func TestTransportError(t *testing.T) {
+	received := 0
+	server := newTransportServer(t, &received)
+	errStore := errors.New("store unavailable")
+	limiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, errStore
+		},
+	}
+	client := &http.Client{Transport: Transport(limiter, nil)}
+
+	_, err := client.Get(server.URL)
+
+	if !errors.Is(err, errStore) {
+		t.Errorf("expected the rate limiter error; got %v", err)
+	}
+	if received != 0 {
+		t.Errorf("expected no request to be received; got %v", received)
+	}
+}
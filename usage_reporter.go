@@ -0,0 +1,341 @@
+package cerberus
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageRecord is the usage of a client over a period, in the units of the cost of its requests.
+type UsageRecord struct {
+	// Key identifies the client, as reported by [KeyOf].
+	Key   string `json:"key"`
+	Usage int64  `json:"usage"`
+}
+
+// UsageReport is the usage of every client over a period, as reported by [UsageReporter.Report].
+type UsageReport struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Final is set on the reports of periods that ended, whose usage no longer changes. Reports of
+	// the current period are running totals.
+	Final bool `json:"final"`
+	// Records are sorted by key, and omit the clients with no usage.
+	Records []UsageRecord `json:"records"`
+}
+
+// WriteCSV writes the report as CSV to w, with a header and a row per client, whose columns are the
+// key of the client, the start and the end of the period in RFC 3339 format, and the usage.
+func (r UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"key", "period_start", "period_end", "usage"})
+	start, end := r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339)
+	for _, record := range r.Records {
+		cw.Write([]string{record.Key, start, end, strconv.FormatInt(record.Usage, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the report as a JSON object to w.
+func (r UsageReport) WriteJSON(w io.Writer) error {
+	if r.Records == nil {
+		r.Records = []UsageRecord{}
+	}
+	return json.NewEncoder(w).Encode(r)
+}
+
+// UsageReporterConfig configures a [UsageReporter].
+type UsageReporterConfig struct {
+	// Period divides usage into billing periods, such as [Monthly] or [BillingCycle]. It is
+	// required.
+	Period Period
+	// CostFunc weighs the requests. It should be the one of the rate limiter, so that clients are
+	// billed for the budget they consumed. The default gives every request a cost of one.
+	CostFunc CostFunc
+	// Retention is how long the usage of a period is kept after its end, so that it can still be
+	// reported. The default, used if Retention is zero or less, is 90 days.
+	Retention time.Duration
+	// Store is where usage is counted. Usage should not be lost on a restart, and should be shared
+	// by the instances of a service, so it is best kept in a persistent store implementing
+	// [KeyScanner]. The default is a new [MemoryStore], which is closed by [UsageReporter.Close].
+	Store Store
+
+	// Export is called every ExportInterval with the report of the current period, and once with
+	// the final report of a period after its end. Reports can be encoded with
+	// [UsageReport.WriteCSV] or [UsageReport.WriteJSON]. It may be nil to disable exports.
+	Export func(ctx context.Context, report UsageReport) error
+	// ExportInterval is the time between two exports. The default, used if ExportInterval is zero
+	// or less, is 1 hour.
+	ExportInterval time.Duration
+	// OnError is called with the errors of the store and of Export. It may be nil to ignore them.
+	OnError func(error)
+}
+
+// UsageReporter is a [RateLimiter] and [AdvancedRateLimiter] that counts the consumption of each
+// client over billing periods, so that metered billing can be based directly on the requests a
+// rate limiter served. It wraps the rate limiter enforcing the limits of the clients, typically a
+// [QuotaLimiter] with the same period.
+//
+// Behavior:
+//   - The cost of every request allowed by the rate limiter is added to the usage of the client in
+//     the current period, and the cost of refunded requests is subtracted from it. Denied
+//     requests are not counted.
+//   - Usage is counted in the store. Errors of the store are reported to the error handler, and
+//     do not change the decision of the rate limiter, so that an unavailable store loses usage
+//     rather than failing requests.
+//   - The usage of a client or of all clients can be read for any retained period with
+//     [UsageReporter.Usage] and [UsageReporter.Report], and is exported periodically to the export
+//     callback, if any.
+//   - A final report of a period is exported once, after its end, by the first export of the next
+//     period. Requests refunded after the end of their period are subtracted from the next one.
+//
+// A UsageReporter is safe for concurrent use by multiple goroutines if its rate limiter is. It
+// must be closed with [UsageReporter.Close] on shutdown, which exports the report of the current
+// period a last time.
+//
+// Example usage:
+//
+//	quota := NewQuotaLimiter(10000, Monthly(time.UTC), WithKeyFunc(KeyByHeader("X-API-Key")), WithStore(myStore))
+//	usage := NewUsageReporter(quota, UsageReporterConfig{
+//		Period: Monthly(time.UTC),
+//		Store:  myStore,
+//		Export: func(ctx context.Context, report UsageReport) error {
+//			return report.WriteCSV(myBillingUpload(ctx, report.Start))
+//		},
+//	})
+//	defer usage.Close(context.Background())
+//	http.Handle("/api/", New(usage)(apiHandler))
+type UsageReporter struct {
+	rateLimiter RateLimiter
+	config      UsageReporterConfig
+
+	// exported is the start of the period of the last export.
+	exported  time.Time
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+
+	now func() time.Time
+}
+
+// NewUsageReporter creates a new [UsageReporter] counting the usage of the clients of rateLimiter
+// with config.
+//
+// It panics if rateLimiter does not report the keys of its clients, which is the case of rate
+// limiters other than the built-in ones, or if config has no Period.
+func NewUsageReporter(rateLimiter RateLimiter, config UsageReporterConfig) *UsageReporter {
+	if _, ok := rateLimiter.(keyer); !ok {
+		panic("cerberus: usage reporter needs a rate limiter reporting the keys of its clients")
+	}
+	if config.Period == nil {
+		panic("cerberus: usage period must not be nil")
+	}
+	if config.Retention <= 0 {
+		config.Retention = 90 * 24 * time.Hour
+	}
+	if config.ExportInterval <= 0 {
+		config.ExportInterval = time.Hour
+	}
+	if config.Store == nil {
+		store := NewMemoryStore()
+		store.owned = true
+		config.Store = store
+	}
+	u := &UsageReporter{
+		rateLimiter: rateLimiter,
+		config:      config,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		now:         time.Now,
+	}
+	go u.run()
+	return u
+}
+
+// IsAllowed checks the request against the rate limiter, and adds its cost to the usage of the
+// client if it is allowed.
+func (u *UsageReporter) IsAllowed(r *http.Request) (bool, error) {
+	return u.IsAllowedContext(r.Context(), r)
+}
+
+// IsAllowedContext is like IsAllowed, but passes ctx to the rate limiter and the store.
+func (u *UsageReporter) IsAllowedContext(ctx context.Context, r *http.Request) (bool, error) {
+	allowed, err := isAllowed(ctx, u.rateLimiter, r)
+	if err != nil || !allowed {
+		return allowed, err
+	}
+	u.record(ctx, r, 1)
+	return true, nil
+}
+
+// GetRateLimitData returns the rate limit data reported by the rate limiter, or the zero
+// RateLimitData if it does not implement [AdvancedRateLimiter].
+func (u *UsageReporter) GetRateLimitData(r *http.Request) RateLimitData {
+	if advanced, ok := u.rateLimiter.(AdvancedRateLimiter); ok {
+		return advanced.GetRateLimitData(r)
+	}
+	return RateLimitData{}
+}
+
+// RefundRequest gives back the budget consumed by the request, if the rate limiter implements
+// [RefundableRateLimiter], and subtracts its cost from the usage of the client. It implements
+// RefundableRateLimiter.
+func (u *UsageReporter) RefundRequest(ctx context.Context, r *http.Request) error {
+	if err := refund(ctx, []RateLimiter{u.rateLimiter}, r); err != nil {
+		return err
+	}
+	u.record(ctx, r, -1)
+	return nil
+}
+
+// Usage returns the usage of the client identified by key in the period containing at. An error is
+// returned if the store fails.
+func (u *UsageReporter) Usage(ctx context.Context, key string, at time.Time) (int64, error) {
+	start, _ := u.config.Period(at)
+	return u.usage(ctx, usageKey(start, key))
+}
+
+// Report returns the usage of every client in the period containing at. An error wrapping
+// [errors.ErrUnsupported] is returned if the store does not implement [KeyScanner], and an error
+// is returned if the store fails.
+func (u *UsageReporter) Report(ctx context.Context, at time.Time) (UsageReport, error) {
+	start, end := u.config.Period(at)
+	report := UsageReport{Start: start, End: end, Final: !end.After(u.now())}
+	prefix := usageKey(start, "")
+	keys, err := scanKeys(ctx, u.config.Store, prefix)
+	if err != nil {
+		return UsageReport{}, err
+	}
+	for _, key := range keys {
+		usage, err := u.usage(ctx, key)
+		if err != nil {
+			return UsageReport{}, err
+		}
+		if usage != 0 {
+			report.Records = append(report.Records, UsageRecord{Key: strings.TrimPrefix(key, prefix), Usage: usage})
+		}
+	}
+	slices.SortFunc(report.Records, func(a, b UsageRecord) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return report, nil
+}
+
+// Close stops the periodic exports, exports the report of the current period a last time, waiting
+// for that to complete until ctx is done, in which case it returns the error of ctx and the export
+// completes in the background, and closes the rate limiter, if it implements [Closer]. Calling it
+// more than once has no further effect. It implements Closer.
+func (u *UsageReporter) Close(ctx context.Context) error {
+	u.closeOnce.Do(func() {
+		close(u.done)
+	})
+	select {
+	case <-u.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return errors.Join(closeAll(ctx, u.rateLimiter), closeOwnedStore(ctx, u.config.Store))
+}
+
+// key returns the key identifying the client making the request, as reported by the rate limiter.
+func (u *UsageReporter) key(r *http.Request) (string, error) {
+	return KeyOf(u.rateLimiter, r)
+}
+
+// record adds the cost of r, multiplied by sign, to the usage of the client making it in the
+// current period.
+func (u *UsageReporter) record(ctx context.Context, r *http.Request, sign int64) {
+	cost := int64(requestCost(u.config.CostFunc, r))
+	if cost == 0 {
+		return
+	}
+	key, err := u.key(r)
+	if err == nil {
+		now := u.now()
+		start, end := u.config.Period(now)
+		_, err = u.config.Store.Increment(ctx, usageKey(start, key), sign*cost, end.Sub(now)+u.config.Retention)
+	}
+	if err != nil {
+		u.reportError(fmt.Errorf("cerberus: recording usage: %w", err))
+	}
+}
+
+// usage returns the counter stored under the store key of a usage.
+func (u *UsageReporter) usage(ctx context.Context, storeKey string) (int64, error) {
+	value, err := u.config.Store.Get(ctx, storeKey)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	usage, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a counter", ErrMalformedValue, storeKey)
+	}
+	return usage, nil
+}
+
+// run exports the reports every export interval until the reporter is closed, and exports the
+// report of the current period a last time then.
+func (u *UsageReporter) run() {
+	defer close(u.stopped)
+	if u.config.Export == nil {
+		<-u.done
+		return
+	}
+	ticker := time.NewTicker(u.config.ExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.export(context.Background())
+		case <-u.done:
+			u.export(context.Background())
+			return
+		}
+	}
+}
+
+// export exports the report of the current period, preceded by the final report of the period of
+// the previous export if it has ended since.
+func (u *UsageReporter) export(ctx context.Context) {
+	now := u.now()
+	start, _ := u.config.Period(now)
+	if !u.exported.IsZero() && !u.exported.Equal(start) {
+		u.exportReport(ctx, u.exported)
+	}
+	u.exported = start
+	u.exportReport(ctx, now)
+}
+
+// exportReport exports the report of the period containing at.
+func (u *UsageReporter) exportReport(ctx context.Context, at time.Time) {
+	report, err := u.Report(ctx, at)
+	if err == nil {
+		err = u.config.Export(ctx, report)
+	}
+	if err != nil {
+		u.reportError(fmt.Errorf("cerberus: exporting usage: %w", err))
+	}
+}
+
+// reportError reports err to the error handler, if any.
+func (u *UsageReporter) reportError(err error) {
+	if u.config.OnError != nil {
+		u.config.OnError(err)
+	}
+}
+
+// usageKey returns the store key of the usage of the client identified by key in the period
+// starting at start.
+func usageKey(start time.Time, key string) string {
+	return "usage:" + strconv.FormatInt(start.UnixNano(), 10) + ":" + key
+}
@@ -0,0 +1,170 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test allowed requests are counted with their cost, and refunds are subtracted
+func TestUsageReporterCountsUsage(t *testing.T) {
+	ctx := context.Background()
+	usage := NewUsageReporter(NewQuotaLimiter(3, Monthly(time.UTC)), UsageReporterConfig{
+		Period: Monthly(time.UTC),
+		CostFunc: func(r *http.Request) int {
+			if r.Method == http.MethodHead {
+				return 0
+			}
+			return 1
+		},
+	})
+	defer usage.Close(ctx)
+	a, b := newRequestFrom("192.0.2.1:1234"), newRequestFrom("192.0.2.2:1234")
+
+	for range 4 {
+		usage.IsAllowed(a)
+	}
+	usage.IsAllowed(b)
+	head := newRequestFrom("192.0.2.2:1234")
+	head.Method = http.MethodHead
+	usage.IsAllowed(head)
+	if err := usage.RefundRequest(ctx, a); err != nil {
+		t.Fatalf("expected refund to succeed; got %v", err)
+	}
+
+	if n, err := usage.Usage(ctx, "192.0.2.1", time.Now()); n != 2 || err != nil {
+		t.Errorf("expected usage 2 after a denial and a refund; got %v, %v", n, err)
+	}
+	report, err := usage.Report(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("expected report; got %v", err)
+	}
+	expected := []UsageRecord{{Key: "192.0.2.1", Usage: 2}, {Key: "192.0.2.2", Usage: 1}}
+	if !slices.Equal(report.Records, expected) || report.Final {
+		t.Errorf("expected running report %v; got %+v", expected, report)
+	}
+	if report, err := usage.Report(ctx, time.Now().AddDate(0, -1, 0)); len(report.Records) != 0 || !report.Final || err != nil {
+		t.Errorf("expected empty final report for the previous month; got %+v, %v", report, err)
+	}
+}
+
+// Test the final report of a period is exported once the next period began, and on close
+func TestUsageReporterExport(t *testing.T) {
+	var mu sync.Mutex
+	var exported []UsageReport
+	usage := NewUsageReporter(NewTokenBucketLimiter(10, 1), UsageReporterConfig{
+		Period: Daily(time.UTC),
+		Export: func(ctx context.Context, report UsageReport) error {
+			mu.Lock()
+			defer mu.Unlock()
+			exported = append(exported, report)
+			return nil
+		},
+	})
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	usage.now = func() time.Time { return now }
+	req := newRequestFrom("192.0.2.1:1234")
+
+	usage.IsAllowed(req)
+	usage.export(context.Background())
+	now = now.Add(2 * time.Hour)
+	usage.IsAllowed(req)
+	usage.IsAllowed(req)
+	usage.export(context.Background())
+	if err := usage.Close(context.Background()); err != nil {
+		t.Fatalf("expected close to succeed; got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	march1, march2 := now.AddDate(0, 0, -1).Truncate(24*time.Hour), now.Truncate(24*time.Hour)
+	expected := []struct {
+		start time.Time
+		final bool
+		usage int64
+	}{{march1, false, 1}, {march1, true, 1}, {march2, false, 2}, {march2, false, 2}}
+	if len(exported) != len(expected) {
+		t.Fatalf("expected %d exports; got %+v", len(expected), exported)
+	}
+	for i, e := range expected {
+		report := exported[i]
+		if !report.Start.Equal(e.start) || report.Final != e.final || len(report.Records) != 1 || report.Records[0].Usage != e.usage {
+			t.Errorf("export %d: expected usage %d from %v (final %v); got %+v", i, e.usage, e.start, e.final, report)
+		}
+	}
+}
+
+// Test errors of the store are reported without changing decisions
+func TestUsageReporterStoreErrors(t *testing.T) {
+	var errs []error
+	store := &countingStore{Store: NewMemoryStore(), err: errStoreDown}
+	usage := NewUsageReporter(NewTokenBucketLimiter(10, 1), UsageReporterConfig{
+		Period:  Monthly(time.UTC),
+		Store:   store,
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	defer usage.Close(context.Background())
+
+	if isAllowed, err := usage.IsAllowed(newRequestFrom("192.0.2.1:1234")); !isAllowed || err != nil {
+		t.Errorf("expected request to be allowed; got %v, %v", isAllowed, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], errStoreDown) {
+		t.Errorf("expected store error to be reported; got %v", errs)
+	}
+	if _, err := usage.Report(context.Background(), time.Now()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for a store that cannot list keys; got %v", err)
+	}
+}
+
+// Test reports are encoded as CSV and JSON
+func TestUsageReportEncoding(t *testing.T) {
+	report := UsageReport{
+		Start:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		Final:   true,
+		Records: []UsageRecord{{Key: "acme, inc", Usage: 42}},
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("expected CSV to be written; got %v", err)
+	}
+	expected := "key,period_start,period_end,usage\n\"acme, inc\",2026-03-01T00:00:00Z,2026-04-01T00:00:00Z,42\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q; got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("expected JSON to be written; got %v", err)
+	}
+	expected = `{"start":"2026-03-01T00:00:00Z","end":"2026-04-01T00:00:00Z","final":true,"records":[{"key":"acme, inc","usage":42}]}`
+	if body := strings.TrimSpace(buf.String()); body != expected {
+		t.Errorf("expected %s; got %s", expected, body)
+	}
+}
+
+// Test NewUsageReporter panics without a period or with a rate limiter not reporting keys
+func TestNewUsageReporterPanics(t *testing.T) {
+	for name, newReporter := range map[string]func(){
+		"no period": func() { NewUsageReporter(NewTokenBucketLimiter(10, 1), UsageReporterConfig{}) },
+		"no keys": func() {
+			NewUsageReporter(&MockRateLimiter{}, UsageReporterConfig{Period: Daily(time.UTC)})
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			newReporter()
+		}()
+	}
+}
@@ -0,0 +1,89 @@
+package cerberus
+
+import (
+	"net/http"
+	"time"
+)
+
+// waitPollInterval is how long the middlewares wait before checking a request again in wait mode
+// when the rate limiter does not report when the client may retry.
+const waitPollInterval = 10 * time.Millisecond
+
+// WithWaitMode makes the middlewares delay requests that exceed the rate limit instead of
+// rejecting them right away. A denied request is held until the rate limiter allows it, or until
+// waiting any longer would exceed maxWait, in which case it is denied as usual. This suits
+// internal, service-to-service traffic, where a short delay is cheaper than a rejected request
+// and its retry. A maxWait of zero or less turns wait mode off, which is the default.
+//
+// Behavior:
+//   - If the rate limiter implements [AdvancedRateLimiter], the request is checked again once the
+//     reported retry delay has elapsed. If that delay exceeds the remaining wait budget, the
+//     request is denied immediately rather than after a pointless wait.
+//   - Otherwise, the request is checked again at short intervals.
+//   - If the client goes away while its request is waiting, the request is denied.
+//
+// Waiting requests hold on to their connection and goroutine, so maxWait should be kept short.
+//
+// Example usage: http.Handle("/internal", New(myRateLimiter, WithWaitMode(500*time.Millisecond))(myHandler))
+func WithWaitMode(maxWait time.Duration) Option {
+	return func(o *options) {
+		o.maxWait = maxWait
+	}
+}
+
+// wait holds r until rateLimiter allows it or the wait budget is exhausted, and returns the
+// outcome of the last check.
+func (o *options) wait(r *http.Request, rateLimiter RateLimiter) (bool, error) {
+	return waitAllowed(r, rateLimiter, o.maxWait)
+}
+
+// Wait checks r against rateLimiter and, if it is denied, holds it until the rate limiter allows
+// it or waiting any longer would exceed maxWait, as the middlewares do in wait mode. It reports
+// whether r was allowed in the end, and returns the error of the rate limiter, if any. A maxWait of
+// zero or less checks r only once. Waiting stops early if the context of r is done.
+//
+// Wait lets adapters that do not handle requests as an [http.Handler], such as RPC interceptors,
+// offer the same waiting behavior. See [WithWaitMode].
+//
+// Example usage:
+//
+//	if allowed, err := Wait(r, myRateLimiter, 500*time.Millisecond); err != nil || !allowed {
+//		return ErrRateLimited
+//	}
+func Wait(r *http.Request, rateLimiter RateLimiter, maxWait time.Duration) (bool, error) {
+	allowed, err := isAllowed(r.Context(), rateLimiter, r)
+	if allowed || err != nil || maxWait <= 0 {
+		return allowed, err
+	}
+	return waitAllowed(r, rateLimiter, maxWait)
+}
+
+// waitAllowed holds r until rateLimiter allows it or waiting any longer would exceed maxWait, and
+// returns the outcome of the last check. It waits by the clock of rateLimiter.
+func waitAllowed(r *http.Request, rateLimiter RateLimiter, maxWait time.Duration) (bool, error) {
+	clock := clockOf(rateLimiter)
+	deadline := clock.Now().Add(maxWait)
+	advanced, isAdvanced := rateLimiter.(AdvancedRateLimiter)
+	for {
+		delay := waitPollInterval
+		if isAdvanced {
+			if retryAfter := advanced.GetRateLimitData(r).RetryAfter; retryAfter > 0 {
+				delay = retryAfter
+			}
+		}
+		if deadline.Sub(clock.Now()) < delay {
+			return false, nil
+		}
+		timer := clock.NewTimer(delay)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return false, nil
+		case <-timer.C():
+		}
+		isAllowed, err := isAllowed(r.Context(), rateLimiter, r)
+		if isAllowed || err != nil {
+			return isAllowed, err
+		}
+	}
+}
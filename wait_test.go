@@ -0,0 +1,118 @@
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test WithWaitMode delays a denied request until a token is available
+func TestWithWaitModeWaitsForToken(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 20)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(limiter, WithWaitMode(time.Second))(handler)
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	rr := httptest.NewRecorder()
+
+	start := time.Now()
+	middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status OK; got %v", rr.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("expected request to wait for a token; got %v", elapsed)
+	}
+}
+
+// Test WithWaitMode denies immediately when the retry delay exceeds the budget
+func TestWithWaitModeDeniesBeyondBudget(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 0.1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := New(limiter, WithWaitMode(time.Second))(handler)
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	rr := httptest.NewRecorder()
+
+	start := time.Now()
+	middleware.ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", rr.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected request to be denied without waiting; got %v", elapsed)
+	}
+}
+
+// Test WithWaitMode polls rate limiters that do not report a retry delay
+func TestWithWaitModePollsBasicRateLimiter(t *testing.T) {
+	calls := 0
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			calls++
+			return calls == 3, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rr := httptest.NewRecorder()
+
+	New(mockLimiter, WithWaitMode(time.Second))(handler).ServeHTTP(rr, newRequestFrom("192.0.2.1:1234"))
+
+	if rr.Code != http.StatusOK || calls != 3 {
+		t.Errorf("expected status OK after 3 checks; got %v after %v", rr.Code, calls)
+	}
+}
+
+// Test WithWaitMode stops waiting when the client goes away
+func TestWithWaitModeCanceled(t *testing.T) {
+	mockLimiter := &MockRateLimiter{
+		IsAllowedFunc: func(r *http.Request) (bool, error) {
+			return false, nil
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	req := newRequestFrom("192.0.2.1:1234").WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	start := time.Now()
+	New(mockLimiter, WithWaitMode(time.Minute))(handler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429; got %v", rr.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected waiting to stop with the request; got %v", elapsed)
+	}
+}
+
+// Test Wait checks a request once without a wait budget, and waits for a token with one
+func TestWait(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 20)
+	req := newRequestFrom("192.0.2.1:1234")
+	if allowed, err := Wait(req, limiter, 0); !allowed || err != nil {
+		t.Fatalf("expected the first request to be allowed; got %v, %v", allowed, err)
+	}
+	if allowed, _ := Wait(req, limiter, 0); allowed {
+		t.Errorf("expected the request to be denied without waiting")
+	}
+
+	start := time.Now()
+	if allowed, err := Wait(req, limiter, time.Second); !allowed || err != nil {
+		t.Errorf("expected the request to be allowed after waiting; got %v, %v", allowed, err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("expected request to wait for a token; got %v", elapsed)
+	}
+}
@@ -0,0 +1,40 @@
+package cerberus
+
+import "time"
+
+// WithWarmUp makes the limiter start new clients with a reduced limit, which ramps up linearly to
+// the full limit over period, also known as slow start. It protects backends from cold clients that
+// open with a burst, such as a fleet of workers restarting at once, while letting established
+// clients use their full budget. The limit of a new client starts at initial, a fraction of the
+// full limit between 0 and 1. There is no warm-up by default.
+//
+// Warm-up is supported by [TokenBucketLimiter], where it applies to the capacity of the buckets:
+// new buckets start with initial times the capacity, and can hold more tokens as they warm up. The
+// rate at which they are refilled is unchanged. A client whose bucket expired, after being idle
+// long enough to refill it, is new again. Other limiters ignore this option.
+//
+// It panics if period is not positive or initial is not between 0 and 1.
+//
+// Example usage: NewTokenBucketLimiter(100, 10, WithWarmUp(time.Minute, 0.1))
+func WithWarmUp(period time.Duration, initial float64) LimiterOption {
+	if period <= 0 {
+		panic("cerberus: warm-up period must be positive")
+	}
+	if !(initial >= 0 && initial <= 1) {
+		panic("cerberus: warm-up initial fraction must be between 0 and 1")
+	}
+	return func(o *limiterOptions) {
+		o.warmUp = period
+		o.warmUpFrom = initial
+	}
+}
+
+// warmUpLimit returns the fraction of the full limit available to a client that was first seen at
+// start, at time now.
+func warmUpLimit(period time.Duration, initial float64, start, now time.Time) float64 {
+	if period == 0 || start.IsZero() || now.Sub(start) >= period {
+		return 1
+	}
+	progress := float64(now.Sub(start)) / float64(period)
+	return initial + (1-initial)*max(progress, 0)
+}
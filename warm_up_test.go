@@ -0,0 +1,86 @@
+package cerberus
+
+import (
+	"testing"
+	"time"
+)
+
+// Test a new client starts with a reduced capacity that ramps up to the full capacity
+func TestWithWarmUpRampsUpCapacity(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(100, 1, WithWarmUp(time.Minute, 0.1))
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+
+	if data := limiter.GetRateLimitData(req); data.Limit != 10 || data.Remaining != 10 {
+		t.Errorf("expected {10 10} for a new client; got %+v", data)
+	}
+	allowed := 0
+	for range 20 {
+		if isAllowed, _ := limiter.IsAllowed(req); isAllowed {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("expected 10 requests to be allowed for a new client; got %v", allowed)
+	}
+
+	clock.Advance(30 * time.Second)
+	if data := limiter.GetRateLimitData(req); data.Limit != 55 || data.Remaining != 30 {
+		t.Errorf("expected {55 30} halfway through the warm-up; got %+v", data)
+	}
+	clock.Advance(30 * time.Second)
+	if data := limiter.GetRateLimitData(req); data.Limit != 100 || data.Remaining != 60 {
+		t.Errorf("expected {100 60} once warmed up; got %+v", data)
+	}
+}
+
+// Test the reduced capacity caps the tokens a warming bucket accrues
+func TestWithWarmUpCapsTokens(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(100, 10, WithWarmUp(time.Minute, 0.1))
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+
+	clock.Advance(12 * time.Second)
+	if data := limiter.GetRateLimitData(req); data.Limit != 28 || data.Remaining != 28 {
+		t.Errorf("expected {28 28}; got %+v", data)
+	}
+}
+
+// Test the bucket is stored until the end of the warm-up
+func TestWithWarmUpKeepsBucket(t *testing.T) {
+	store := NewMemoryStore()
+	clock := newFakeClock()
+	limiter := NewTokenBucketLimiter(10, 10, WithWarmUp(time.Minute, 0.5), WithStore(store))
+	limiter.now = clock.Now
+	req := newRequestFrom("192.0.2.1:1234")
+	limiter.IsAllowed(req)
+
+	value, _ := store.Get(req.Context(), tokenBucketPrefix+"192.0.2.1")
+	if value == nil {
+		t.Fatalf("expected the bucket to be stored")
+	}
+	if ttl, _ := store.TTL(req.Context(), tokenBucketPrefix+"192.0.2.1"); ttl < 59*time.Second {
+		t.Errorf("expected the bucket to be kept for the warm-up; got a TTL of %v", ttl)
+	}
+}
+
+// Test invalid warm-ups are rejected
+func TestWithWarmUpPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"zero period":      func() { WithWarmUp(0, 0.5) },
+		"negative initial": func() { WithWarmUp(time.Minute, -0.1) },
+		"initial above 1":  func() { WithWarmUp(time.Minute, 1.5) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
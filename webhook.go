@@ -0,0 +1,474 @@
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookSignatureHeader is the header holding the signature of the notifications sent by a
+// [WebhookNotifier], as verified by [VerifyWebhookSignature].
+const WebhookSignatureHeader = "X-Cerberus-Signature"
+
+// Types of [WebhookNotification].
+const (
+	// WebhookUsage is the type of the notifications of clients whose usage crossed one of the usage
+	// thresholds of the notifier.
+	WebhookUsage = "usage"
+
+	// WebhookDenials is the type of the notifications of clients denied as many times as the denial
+	// threshold of the notifier within its window.
+	WebhookDenials = "denials"
+)
+
+// WebhookNotification is the JSON body of the notifications sent by a [WebhookNotifier].
+type WebhookNotification struct {
+	// Type is [WebhookUsage] or [WebhookDenials].
+	Type string `json:"type"`
+	// Key identifies the client, as reported by [KeyOf].
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+
+	// Threshold is the fraction of the limit crossed by the usage of the client, and Limit and
+	// Remaining are the limit of the client and what is left of it, for usage notifications.
+	Threshold float64 `json:"threshold,omitempty"`
+	Limit     int     `json:"limit,omitempty"`
+	Remaining int     `json:"remaining,omitempty"`
+
+	// Denials is the number of denials of the client within Window, for denial notifications.
+	Denials int    `json:"denials,omitempty"`
+	Window  string `json:"window,omitempty"`
+}
+
+// WebhookConfig configures a [WebhookNotifier]. At least one of UsageThresholds and
+// DenialThreshold must be set.
+type WebhookConfig struct {
+	// URL is the endpoint notifications are posted to.
+	URL string
+	// Secret signs the notifications. It must be at least 16 bytes long.
+	Secret []byte
+
+	// UsageThresholds are the fractions of their limit, such as 0.8 and 0.95, whose crossing by
+	// the usage of a client is notified.
+	UsageThresholds []float64
+	// DenialThreshold is the number of denials of a client within DenialWindow that is notified.
+	// Zero disables the notifications of denials.
+	DenialThreshold int
+	// DenialWindow is the period over which denials are counted.
+	DenialWindow time.Duration
+
+	// Cooldown is the minimum time between two notifications of the same type and threshold for
+	// the same client. The default, used if Cooldown is zero or less, is 1 hour.
+	Cooldown time.Duration
+	// MaxRetries is the number of times the delivery of a notification is retried, with
+	// exponential backoff starting at 1 second, if the endpoint cannot be reached or responds with
+	// HTTP 429 or 5xx. The default, used if MaxRetries is zero, is 3. A negative value disables
+	// retries.
+	MaxRetries int
+	// Client sends the notifications. The default is a client with a timeout of 10 seconds.
+	Client *http.Client
+	// Store is where denials are counted and notifications deduplicated. Sharing a store between
+	// instances of a service makes them notify each client once. The default is a new
+	// [MemoryStore], which is closed by [WebhookNotifier.Close].
+	Store Store
+	// OnError is called with the errors of failed deliveries, after all retries, and of the store.
+	// It may be nil to ignore them.
+	OnError func(error)
+}
+
+// WebhookNotifier posts signed HTTP webhooks when clients cross thresholds of their limit, such as
+// 80% of their quota or repeated denials, so that customer success teams can contact customers
+// approaching their limits before they hit them. It observes the decisions of the middlewares it
+// is installed in with [WithHooks].
+//
+// Behavior:
+//   - A usage notification is sent when the share of its limit a client used, as reported by an
+//     [AdvancedRateLimiter], reaches one of the usage thresholds, for the highest threshold
+//     reached.
+//   - A denial notification is sent when a client is denied as many times as the denial threshold
+//     within the denial window.
+//   - Each client is notified at most once per cooldown for each type and threshold.
+//   - Notifications are posted as JSON [WebhookNotification] values, in the background, and
+//     retried with exponential backoff. Notifications that could not be delivered are reported to
+//     the error handler.
+//
+// Notifications are signed with HMAC-SHA256 in the X-Cerberus-Signature header, in the form
+// "t=<unix time>,v1=<hex signature>", where the signature covers the time, a dot, and the body,
+// so that receivers can check that they come from the service and are recent with
+// [VerifyWebhookSignature]. Decisions are observed without blocking requests: when the notifier
+// falls behind, such as during a flood of denials, the decisions it cannot keep up with are not
+// counted.
+//
+// A WebhookNotifier is safe for concurrent use by multiple goroutines, and may observe several
+// middlewares. It must be closed with [WebhookNotifier.Close] on shutdown.
+//
+// Example usage:
+//
+//	notifier := NewWebhookNotifier(WebhookConfig{
+//		URL:             "https://crm.example.com/hooks/rate-limits",
+//		Secret:          secret,
+//		UsageThresholds: []float64{0.8, 0.95},
+//	})
+//	defer notifier.Close(context.Background())
+//	quota := NewQuotaLimiter(10000, Monthly(time.UTC), WithKeyFunc(KeyByHeader("X-API-Key")))
+//	http.Handle("/", New(quota, WithHooks(notifier.Hooks(quota)))(myHandler))
+type WebhookNotifier struct {
+	config WebhookConfig
+
+	checks   chan WebhookNotification
+	mu       sync.Mutex
+	notified map[string]time.Time
+	sweptAt  time.Time
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	closeOnce  sync.Once
+	done       chan struct{}
+	deliveries sync.WaitGroup
+
+	backoff time.Duration
+	now     func() time.Time
+}
+
+// NewWebhookNotifier creates a new [WebhookNotifier] with config.
+//
+// It panics if URL is empty, if Secret is shorter than 16 bytes, if a usage threshold is not
+// between 0, excluded, and 1, if DenialThreshold is negative, if DenialWindow is not positive
+// while DenialThreshold is set, or if no threshold is set.
+func NewWebhookNotifier(config WebhookConfig) *WebhookNotifier {
+	if config.URL == "" {
+		panic("cerberus: webhook URL must not be empty")
+	}
+	if len(config.Secret) < minSecretSize {
+		panic("cerberus: webhook secret must be at least 16 bytes")
+	}
+	for _, threshold := range config.UsageThresholds {
+		if !(threshold > 0 && threshold <= 1) {
+			panic("cerberus: webhook usage thresholds must be between 0 and 1")
+		}
+	}
+	if config.DenialThreshold < 0 || config.DenialThreshold > 0 && config.DenialWindow <= 0 {
+		panic("cerberus: webhook denial threshold and window must be positive")
+	}
+	if len(config.UsageThresholds) == 0 && config.DenialThreshold == 0 {
+		panic("cerberus: webhook notifier needs a usage or denial threshold")
+	}
+	// The thresholds are sorted in decreasing order, so that the highest one reached comes first.
+	config.UsageThresholds = slices.Clone(config.UsageThresholds)
+	slices.Sort(config.UsageThresholds)
+	slices.Reverse(config.UsageThresholds)
+	if config.Cooldown <= 0 {
+		config.Cooldown = time.Hour
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Store == nil {
+		store := NewMemoryStore()
+		store.owned = true
+		config.Store = store
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{
+		config:   config,
+		checks:   make(chan WebhookNotification, 1000),
+		notified: make(map[string]time.Time),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		backoff:  time.Second,
+		now:      time.Now,
+	}
+	n.deliveries.Add(1)
+	go n.run()
+	return n
+}
+
+// Hooks returns the hooks observing the decisions of rateLimiter, to be passed to [WithHooks].
+// Clients are identified by the key reported by [KeyOf]; the decisions of rate limiters that do not
+// report keys are ignored.
+func (n *WebhookNotifier) Hooks(rateLimiter RateLimiter) Hooks {
+	return Hooks{
+		OnAllowed: func(r *http.Request, data RateLimitData) {
+			n.observeUsage(r, rateLimiter, data)
+		},
+		OnDenied: func(r *http.Request, data RateLimitData) {
+			n.observeUsage(r, rateLimiter, data)
+			if n.config.DenialThreshold == 0 {
+				return
+			}
+			if key, err := KeyOf(rateLimiter, r); err == nil {
+				n.check(WebhookNotification{Type: WebhookDenials, Key: key})
+			}
+		},
+	}
+}
+
+// Close stops observing decisions, and waits for the notifications in flight to be delivered,
+// including their retries, until ctx is done, in which case it returns the error of ctx and the
+// notifications not delivered yet are abandoned. The default store of the notifier is closed
+// afterwards. Calling it more than once has no further effect. It implements [Closer].
+func (n *WebhookNotifier) Close(ctx context.Context) error {
+	n.closeOnce.Do(func() {
+		close(n.done)
+	})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		n.deliveries.Wait()
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		n.cancel()
+		return ctx.Err()
+	}
+	n.cancel()
+	return closeOwnedStore(ctx, n.config.Store)
+}
+
+// observeUsage queues the usage of the client making r for checking, if it reached a usage
+// threshold for which the client was not notified recently by this instance.
+func (n *WebhookNotifier) observeUsage(r *http.Request, rateLimiter RateLimiter, data RateLimitData) {
+	if len(n.config.UsageThresholds) == 0 || data.Limit <= 0 {
+		return
+	}
+	used := float64(data.Limit-data.Remaining) / float64(data.Limit)
+	i := slices.IndexFunc(n.config.UsageThresholds, func(threshold float64) bool { return used >= threshold })
+	if i < 0 {
+		return
+	}
+	key, err := KeyOf(rateLimiter, r)
+	if err != nil {
+		return
+	}
+	notification := WebhookNotification{
+		Type:      WebhookUsage,
+		Key:       key,
+		Threshold: n.config.UsageThresholds[i],
+		Limit:     data.Limit,
+		Remaining: data.Remaining,
+	}
+	// Clients are remembered locally once notified, so that their next requests do not each cost
+	// a round trip to the store.
+	now := n.now()
+	id := notificationID(notification)
+	n.mu.Lock()
+	if now.Before(n.notified[id]) {
+		n.mu.Unlock()
+		return
+	}
+	n.notified[id] = now.Add(n.config.Cooldown)
+	n.sweep(now)
+	n.mu.Unlock()
+	n.check(notification)
+}
+
+// check queues notification for checking against the store, or drops it if the queue is full or
+// the notifier is closed.
+func (n *WebhookNotifier) check(notification WebhookNotification) {
+	select {
+	case <-n.done:
+		return
+	default:
+	}
+	select {
+	case n.checks <- notification:
+	default:
+	}
+}
+
+// sweep removes the expired entries of the local cache of notified clients, if the cooldown has
+// elapsed since the last sweep. n.mu must be held.
+func (n *WebhookNotifier) sweep(now time.Time) {
+	if now.Sub(n.sweptAt) < n.config.Cooldown {
+		return
+	}
+	n.sweptAt = now
+	for id, expiresAt := range n.notified {
+		if !now.Before(expiresAt) {
+			delete(n.notified, id)
+		}
+	}
+}
+
+// run checks the queued notifications against the store until the notifier is closed, and
+// delivers those that are due.
+func (n *WebhookNotifier) run() {
+	defer n.deliveries.Done()
+	for {
+		select {
+		case notification := <-n.checks:
+			n.process(notification)
+		case <-n.done:
+			for len(n.checks) > 0 {
+				n.process(<-n.checks)
+			}
+			return
+		}
+	}
+}
+
+// process counts the denial of a denial notification, and delivers notification in the background
+// if it reached its threshold and the client was not notified within the cooldown.
+func (n *WebhookNotifier) process(notification WebhookNotification) {
+	ctx := n.ctx
+	store := n.config.Store
+	if notification.Type == WebhookDenials {
+		denials, err := store.Increment(ctx, "webhook:denials:"+notification.Key, 1, n.config.DenialWindow)
+		if err != nil {
+			n.fail(err)
+			return
+		}
+		if denials != int64(n.config.DenialThreshold) {
+			return
+		}
+		notification.Denials = int(denials)
+		notification.Window = n.config.DenialWindow.String()
+	}
+	due, err := store.CompareAndSwap(ctx, "webhook:sent:"+notificationID(notification), nil, []byte{1}, n.config.Cooldown)
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	if !due {
+		return
+	}
+	notification.Time = n.now()
+	n.deliveries.Add(1)
+	go func() {
+		defer n.deliveries.Done()
+		if err := n.deliver(notification); err != nil {
+			n.fail(err)
+		}
+	}()
+}
+
+// deliver posts notification to the URL of the notifier, retrying with exponential backoff while
+// the endpoint cannot be reached or responds with HTTP 429 or 5xx.
+func (n *WebhookNotifier) deliver(notification WebhookNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.config.MaxRetries {
+			return fmt.Errorf("webhook notification of %q: %w", notification.Key, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-n.ctx.Done():
+			return fmt.Errorf("webhook notification of %q: %w", notification.Key, n.ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// post posts body, signed, to the URL of the notifier, and reports whether a failure may be
+// retried.
+func (n *WebhookNotifier) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signWebhook(n.config.Secret, n.now(), body))
+	resp, err := n.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// fail reports err to the error handler, if any.
+func (n *WebhookNotifier) fail(err error) {
+	if n.config.OnError != nil {
+		n.config.OnError(err)
+	}
+}
+
+// notificationID returns the identifier of the notifications of the same type and threshold for
+// the same client, which are sent at most once per cooldown.
+func notificationID(notification WebhookNotification) string {
+	return notification.Type + ":" + strconv.FormatFloat(notification.Threshold, 'g', -1, 64) + ":" + notification.Key
+}
+
+// VerifyWebhookSignature reports whether header, the value of the X-Cerberus-Signature header of a
+// notification received from a [WebhookNotifier], is a valid signature of body with any of
+// secrets, made less than maxAge before or after the current time, so that receivers can reject
+// forged and replayed notifications.
+//
+// Example usage:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	if !cerberus.VerifyWebhookSignature(r.Header.Get(cerberus.WebhookSignatureHeader), body, 5*time.Minute, secret) {
+//		http.Error(w, "invalid signature", http.StatusUnauthorized)
+//		return
+//	}
+func VerifyWebhookSignature(header string, body []byte, maxAge time.Duration, secrets ...[]byte) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(signedAt, 0)); age > maxAge || age < -maxAge {
+		return false
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	for _, secret := range secrets {
+		if hmac.Equal(mac, webhookMAC(secret, timestamp, body)) {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhook returns the value of the signature header of body signed with secret at signedAt.
+func signWebhook(secret []byte, signedAt time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(webhookMAC(secret, timestamp, body))
+}
+
+// webhookMAC returns the HMAC-SHA256, keyed with secret, of the timestamp and the body of a
+// notification.
+func webhookMAC(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
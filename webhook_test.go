@@ -0,0 +1,192 @@
+package cerberus
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var webhookSecret = []byte("0123456789abcdef")
+
+// webhookReceiver is a webhook endpoint recording the notifications it receives, and responding
+// with the status codes of statuses, in turn, then with 204.
+type webhookReceiver struct {
+	*httptest.Server
+	mu            sync.Mutex
+	notifications []WebhookNotification
+	attempts      int
+	statuses      []int
+	invalid       int
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.attempts++
+		if len(receiver.statuses) > 0 {
+			status := receiver.statuses[0]
+			receiver.statuses = receiver.statuses[1:]
+			w.WriteHeader(status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature(r.Header.Get(WebhookSignatureHeader), body, time.Minute, webhookSecret) {
+			receiver.invalid++
+		}
+		var notification WebhookNotification
+		json.Unmarshal(body, &notification)
+		receiver.notifications = append(receiver.notifications, notification)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// Test a notification is sent for each usage threshold reached by a client
+func TestWebhookNotifierUsage(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	notifier := NewWebhookNotifier(WebhookConfig{
+		URL:             receiver.URL,
+		Secret:          webhookSecret,
+		UsageThresholds: []float64{0.8, 0.5},
+	})
+	limiter := NewFixedWindowLimiter(10, time.Hour)
+	handler := New(limiter, WithHooks(notifier.Hooks(limiter)))(noContent)
+
+	for range 12 {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+	if err := notifier.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(receiver.notifications) != 2 || receiver.invalid != 0 {
+		t.Fatalf("expected 2 signed notifications; got %+v with %d invalid", receiver.notifications, receiver.invalid)
+	}
+	// Notifications are delivered concurrently, in any order.
+	slices.SortFunc(receiver.notifications, func(a, b WebhookNotification) int {
+		return cmp.Compare(a.Threshold, b.Threshold)
+	})
+	for i, threshold := range []float64{0.5, 0.8} {
+		notification := receiver.notifications[i]
+		if notification.Type != WebhookUsage || notification.Key != "192.0.2.1" || notification.Threshold != threshold || notification.Limit != 10 {
+			t.Errorf("expected a usage notification of 192.0.2.1 at %v; got %+v", threshold, notification)
+		}
+	}
+}
+
+// Test a notification is sent once a client is denied as many times as the denial threshold
+func TestWebhookNotifierDenials(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	notifier := NewWebhookNotifier(WebhookConfig{
+		URL:             receiver.URL,
+		Secret:          webhookSecret,
+		DenialThreshold: 3,
+		DenialWindow:    time.Minute,
+	})
+	limiter := NewFixedWindowLimiter(1, time.Hour)
+	handler := New(limiter, WithHooks(notifier.Hooks(limiter)))(noContent)
+
+	for range 6 {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+	}
+	notifier.Close(context.Background())
+
+	if len(receiver.notifications) != 1 {
+		t.Fatalf("expected 1 notification; got %+v", receiver.notifications)
+	}
+	if notification := receiver.notifications[0]; notification.Type != WebhookDenials || notification.Denials != 3 || notification.Window != "1m0s" {
+		t.Errorf("expected a notification of 3 denials within 1m0s; got %+v", notification)
+	}
+}
+
+// Test deliveries are retried on server errors but not on client errors
+func TestWebhookNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		failed   bool
+	}{
+		{"server error", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, false},
+		{"client error", []int{http.StatusBadRequest}, 1, true},
+		{"retries exhausted", []int{500, 500, 500, 500}, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, tt.statuses...)
+			var errs []error
+			notifier := NewWebhookNotifier(WebhookConfig{
+				URL:             receiver.URL,
+				Secret:          webhookSecret,
+				UsageThresholds: []float64{1},
+				OnError:         func(err error) { errs = append(errs, err) },
+			})
+			notifier.backoff = time.Millisecond
+			limiter := NewFixedWindowLimiter(1, time.Hour)
+			New(limiter, WithHooks(notifier.Hooks(limiter)))(noContent).ServeHTTP(httptest.NewRecorder(), newRequestFrom("192.0.2.1:1234"))
+			notifier.Close(context.Background())
+
+			if receiver.attempts != tt.attempts {
+				t.Errorf("expected %d attempts; got %d", tt.attempts, receiver.attempts)
+			}
+			if failed := len(errs) > 0; failed != tt.failed {
+				t.Errorf("expected failure %v; got %v", tt.failed, errs)
+			}
+		})
+	}
+}
+
+// Test signatures are rejected if forged, tampered with, or expired
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"type":"usage"}`)
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		want   bool
+	}{
+		{"valid", signWebhook(webhookSecret, now, body), body, true},
+		{"other secret", signWebhook([]byte("fedcba9876543210"), now, body), body, false},
+		{"tampered body", signWebhook(webhookSecret, now, body), []byte(`{"type":"denials"}`), false},
+		{"expired", signWebhook(webhookSecret, now.Add(-time.Hour), body), body, false},
+		{"malformed", "v1=abc", body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyWebhookSignature(tt.header, tt.body, time.Minute, webhookSecret); got != tt.want {
+				t.Errorf("expected %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test invalid configs panic
+func TestWebhookNotifierPanics(t *testing.T) {
+	for name, config := range map[string]WebhookConfig{
+		"no URL":        {Secret: webhookSecret, UsageThresholds: []float64{0.8}},
+		"short secret":  {URL: "http://example.com", Secret: []byte("short"), UsageThresholds: []float64{0.8}},
+		"invalid usage": {URL: "http://example.com", Secret: webhookSecret, UsageThresholds: []float64{80}},
+		"no window":     {URL: "http://example.com", Secret: webhookSecret, DenialThreshold: 3},
+		"no threshold":  {URL: "http://example.com", Secret: webhookSecret},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			NewWebhookNotifier(config)
+		}()
+	}
+}